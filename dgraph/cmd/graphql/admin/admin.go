@@ -0,0 +1,1158 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package admin serves the GraphQL admin API, which is used to change how the
+// GraphQL API is served - e.g. updating the GraphQL schema - while the server
+// is running.
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/parser"
+	"github.com/vektah/gqlparser/validator"
+)
+
+const graphqlAdminSchema = `
+type GQLSchema {
+	schema: String!
+	generatedSchema: String!
+
+	"""
+	The schema's version in the schema history.  It's null if the schema was
+	stored without being added to the history.
+	"""
+	version: Int
+
+	"""
+	How long it took to build the schema being served, including working out
+	everything the resolvers need to know about it, e.g. "1.5ms".
+	"""
+	buildTime: String!
+
+	"""
+	How the generated API changed from the schema that was served before this
+	one.  It's empty for the first schema a server serves.
+	"""
+	changelog: [APIChange!]!
+}
+
+"""
+A difference between the generated API and the one before it, e.g. "Query
+getPost was added.".  Breaking changes, like a removed field or a new
+required argument, can stop requests that used to work.
+"""
+type APIChange {
+	breaking: Boolean!
+	message: String!
+}
+
+input UpdateGQLSchemaInput {
+	set: GQLSchemaPatch!
+
+	"""
+	Apply the schema even if it makes breaking changes to the API, like
+	removing a field or adding a required argument.  Without force, a schema
+	that would break requests that work now is refused.
+	"""
+	force: Boolean
+}
+
+input GQLSchemaPatch {
+	schema: String!
+}
+
+type UpdateGQLSchemaPayload {
+	gqlSchema: GQLSchema
+}
+
+"""
+A schema that was applied.  Versions are numbered in the order they were
+applied.
+"""
+type GQLSchemaVersion {
+	version: Int!
+	schema: String!
+
+	"""
+	When the schema was applied, in RFC 3339 format.  It's null for a schema
+	that was applied before the history was kept.
+	"""
+	appliedAt: String
+}
+
+input RollbackGQLSchemaInput {
+	version: Int!
+
+	"""
+	Apply the old schema even if it makes breaking changes to the API being
+	served.
+	"""
+	force: Boolean
+}
+
+"""
+The tags of a schema in the registry, in the order that versions are
+promoted through them.
+"""
+enum GQLSchemaTag {
+	DEV
+	STAGING
+	PROD
+}
+
+"""
+A named schema in the schema registry.  Registering and promoting versions
+doesn't change the schema being served; applyRegisteredGQLSchema does that.
+"""
+type RegisteredGQLSchema {
+	name: String!
+
+	"""
+	The versions, newest first.  The latest 50 are kept, along with any that a
+	tag points, or pointed, at.
+	"""
+	versions: [RegisteredGQLSchemaVersion!]!
+
+	"""
+	The version each tag points at.  Tags that were never set aren't listed.
+	"""
+	tags: [GQLSchemaTagVersion!]!
+}
+
+type RegisteredGQLSchemaVersion {
+	version: Int!
+	schema: String!
+
+	"""
+	When the version was registered, in RFC 3339 format.
+	"""
+	registeredAt: String!
+
+	"""
+	The tags that point at the version.
+	"""
+	tags: [GQLSchemaTag!]!
+}
+
+type GQLSchemaTagVersion {
+	tag: GQLSchemaTag!
+	version: Int!
+}
+
+input RegisterGQLSchemaInput {
+	name: String!
+	schema: String!
+
+	"""
+	The tag to point at the new version.  By default, it's DEV.
+	"""
+	tag: GQLSchemaTag
+}
+
+input PromoteGQLSchemaInput {
+	name: String!
+	from: GQLSchemaTag!
+
+	"""
+	The tag to point at from's version.  By default, it's the tag after from:
+	STAGING after DEV, and PROD after STAGING.
+	"""
+	to: GQLSchemaTag
+}
+
+input RollbackGQLSchemaTagInput {
+	name: String!
+	tag: GQLSchemaTag!
+}
+
+"""
+Picks a version of a named schema, either by tag or by number; exactly one of
+tag and version must be given.
+"""
+input ApplyRegisteredGQLSchemaInput {
+	name: String!
+	tag: GQLSchemaTag
+	version: Int
+
+	"""
+	Apply the schema even if it makes breaking changes to the API being
+	served.
+	"""
+	force: Boolean
+}
+
+"""
+How the data stored for a type in the GraphQL schema is distributed.
+"""
+type TypeStats {
+	type: String!
+
+	"""
+	How many objects of the type there are.
+	"""
+	count: Int!
+
+	"""
+	How many of the objects the field statistics were worked out from.
+	"""
+	sampled: Int!
+
+	fields: [FieldStats!]!
+}
+
+type FieldStats {
+	field: String!
+
+	"""
+	The Dgraph predicate the field is stored in.
+	"""
+	predicate: String!
+
+	"""
+	How many of the sampled objects have a value for the field.
+	"""
+	present: Int!
+
+	"""
+	How many values, or linked objects, the sampled objects have for the field
+	in all.
+	"""
+	values: Int!
+}
+
+type Health {
+	ready: Boolean!
+	schemaLoaded: Boolean!
+
+	"""
+	When the schema being served was built, in RFC 3339 format.
+	"""
+	schemaGeneratedAt: String
+
+	dgraph: DgraphHealth!
+}
+
+type DgraphHealth {
+	available: Boolean!
+
+	"""
+	How long Dgraph took to answer a trivial query, e.g. "1.2ms".
+	"""
+	latency: String
+
+	error: String
+}
+
+"""
+A query document that requests can send by its hash, in the persistedQuery
+extension, instead of in full.
+"""
+type PersistedQuery {
+	sha256Hash: String!
+	query: String!
+
+	"""
+	The names of the query's operations.  Requests can run an operation by
+	sending just its name as the operationName.
+	"""
+	operationNames: [String!]!
+}
+
+"""
+Who can ask the GraphQL API about its schema, with __schema, __type and
+_service queries.
+"""
+enum IntrospectionMode {
+	ENABLED
+	DISABLED
+
+	"""
+	Only requests with a valid JWT.
+	"""
+	AUTHENTICATED
+
+	"""
+	Only requests whose JWT gives them the admin role.
+	"""
+	ADMIN
+}
+
+type Query {
+	"""
+	Whether the server is ready to serve the GraphQL API: it has a schema,
+	and Dgraph answers.
+	"""
+	health: Health!
+
+	getGQLSchema: GQLSchema
+
+	"""
+	The schemas that were applied, newest first.  Only the latest 20 are
+	kept.
+	"""
+	getGQLSchemaHistory: [GQLSchemaVersion!]!
+
+	"""
+	The named schemas in the schema registry, or just the one called 'name',
+	sorted by name.
+	"""
+	registeredGQLSchemas(name: String): [RegisteredGQLSchema!]!
+
+	"""
+	The registered queries.  If the server is started with --allowlist, they're
+	the only queries it runs.
+	"""
+	persistedQueries: [PersistedQuery!]!
+
+	"""
+	Who can ask the GraphQL API about its schema.
+	"""
+	introspectionMode: IntrospectionMode!
+
+	"""
+	Statistics for each type in the GraphQL schema.  Objects are counted
+	exactly, but the field statistics come from the first 'sample' (by
+	default, 1000) objects of each type.
+	"""
+	typeStats(sample: Int): [TypeStats!]!
+
+	"""
+	How the API generated from 'schema' would differ from the one being
+	served, without changing anything.  updateGQLSchema refuses a schema with
+	breaking changes unless it's forced.
+	"""
+	diffGQLSchema(schema: String!): [APIChange!]!
+
+	"""
+	A GraphQL schema for the data already in Dgraph, worked out from Dgraph's
+	types, predicates and indexes.  It's a best effort, to review before it's
+	applied with updateGQLSchema; what couldn't be mapped is listed in
+	comments.
+	"""
+	importDgraphSchema: String!
+}
+
+type Mutation {
+	updateGQLSchema(input: UpdateGQLSchemaInput!): UpdateGQLSchemaPayload
+
+	"""
+	Applies a schema from the history again, as updateGQLSchema would.  The
+	schema is added to the history as a new version, so a rollback can itself
+	be rolled back.
+	"""
+	rollbackGQLSchema(input: RollbackGQLSchemaInput!): UpdateGQLSchemaPayload
+
+	"""
+	Adds a version to a named schema in the registry, creating it if it's new,
+	and points a tag at it.  The schema must be valid, but it isn't applied.
+	Registering the same schema as the newest version only tags that version.
+	"""
+	registerGQLSchema(input: RegisterGQLSchemaInput!): RegisteredGQLSchema!
+
+	"""
+	Points a named schema's tag at the version that another of its tags points
+	at, e.g. from DEV to STAGING.
+	"""
+	promoteGQLSchema(input: PromoteGQLSchemaInput!): RegisteredGQLSchema!
+
+	"""
+	Points a named schema's tag back at the version it pointed at before the
+	last time it was set.
+	"""
+	rollbackGQLSchemaTag(input: RollbackGQLSchemaTagInput!): RegisteredGQLSchema!
+
+	"""
+	Applies a version of a named schema from the registry, as updateGQLSchema
+	would.
+	"""
+	applyRegisteredGQLSchema(input: ApplyRegisteredGQLSchemaInput!): UpdateGQLSchemaPayload
+
+	"""
+	Registers query documents, so that requests can send them by hash.  It
+	returns the queries, with their hashes.
+	"""
+	registerQueries(queries: [String!]!): [PersistedQuery!]!
+
+	"""
+	Removes the registered queries with the given hashes.  It returns the
+	queries that were removed.
+	"""
+	deregisterQueries(sha256Hashes: [String!]!): [PersistedQuery!]!
+
+	"""
+	Changes who can ask the GraphQL API about its schema, on every server
+	using the Dgraph cluster.  It returns the new mode.
+	"""
+	setIntrospectionMode(mode: IntrospectionMode!): IntrospectionMode!
+
+	"""
+	Empties this server's cache of parsed and validated operations, and of the
+	Dgraph queries they were rewritten to, so that each operation is parsed,
+	validated and rewritten again the next time it's sent.  It returns how many
+	operations were in the cache.
+	"""
+	flushOperationCache: Int!
+}
+`
+
+// An Admin manages the GraphQL schema that a GraphQL server serves.  The
+// schema is stored in Dgraph, so that it survives restarts and so that every
+// GraphQL server backed by the same Dgraph cluster serves the same API.
+type Admin struct {
+	// mu serialises schema changes, so that the schema in Dgraph and the
+	// schema being served can't get out of step.
+	mu sync.Mutex
+
+	dgraphClient dgraph.Client
+	gqlServer    *resolve.RequestResolver
+	resolver     *resolve.RequestResolver
+
+	// remotes are the remote GraphQL APIs stitched into every schema.  Their
+	// SDL is found with introspect each time a schema is applied, so changes
+	// to the remote APIs are picked up along with schema changes.
+	remotes    []schema.RemoteAPI
+	introspect func(ctx context.Context, url string) (string, error)
+
+	// schemaCheck is what happens if Dgraph's schema doesn't have what a
+	// GraphQL schema needs.
+	schemaCheck SchemaCheck
+
+	// webhook, if it's set, is sent the schema and its changelog after
+	// every schema update this server applies.  Secrets referenced in its
+	// URL are looked up in secrets when it's called.
+	webhook       string
+	webhookClient *external.Client
+	secrets       secrets.Provider
+
+	// current is the schema being served, or nil if there isn't one yet.
+	current *gqlSchema
+
+	// generatedAt is the time.Time when current was built.  It's kept apart
+	// from current, so that probes don't wait on a.mu during schema updates.
+	generatedAt atomic.Value
+}
+
+type gqlSchema struct {
+	handler         schema.Handler
+	schema          string
+	generatedSchema string
+	buildTime       time.Duration
+	version         int
+	api             schema.Schema
+	changelog       []schema.APIChange
+}
+
+// builtSchema is a schema that's ready to be served.  It was regenerated
+// from the schema that was being served when it was built, from, and delta is
+// how it differs from that schema.
+type builtSchema struct {
+	handler   schema.Handler
+	schema    schema.Schema
+	buildTime time.Duration
+	from      *gqlSchema
+	delta     *schema.SchemaDelta
+}
+
+// changesFrom returns how b's API differs from that of current, the schema
+// being served: b's delta, if it was built from current, or else the
+// changelog between them.
+func (b *builtSchema) changesFrom(current *gqlSchema) []schema.APIChange {
+	if b.from == current {
+		return b.delta.Changes
+	}
+	return schema.Changelog(current.api, b.schema)
+}
+
+// New returns an Admin that manages the schema served by gqlServer, storing
+// it in Dgraph with dgraphClient.  The remote APIs are stitched into every
+// schema that's served.
+func New(dgraphClient dgraph.Client, gqlServer *resolve.RequestResolver,
+	remotes ...schema.RemoteAPI) (*Admin, error) {
+
+	sch, gqlErr := validator.LoadSchema(validator.Prelude,
+		&ast.Source{Name: "admin", Input: graphqlAdminSchema})
+	if gqlErr != nil {
+		return nil, errors.Wrap(gqlErr, "while loading the admin schema")
+	}
+
+	client := external.NewClient(nil)
+	a := &Admin{
+		dgraphClient:  dgraphClient,
+		gqlServer:     gqlServer,
+		remotes:       remotes,
+		schemaCheck:   SchemaCheckWarn,
+		webhookClient: client,
+		introspect: func(ctx context.Context, url string) (string, error) {
+			return external.IntrospectSDL(ctx, client, url, client.Policy(url))
+		},
+	}
+	a.resolver = resolve.New(schema.AsSchema(sch), dgraphClient).
+		WithFieldResolver("health", a.health).
+		WithFieldResolver("getGQLSchema", a.getSchema).
+		WithFieldResolver("getGQLSchemaHistory", a.schemaHistory).
+		WithFieldResolver("typeStats", a.typeStats).
+		WithFieldResolver("diffGQLSchema", a.diffSchema).
+		WithFieldResolver("importDgraphSchema", a.importDgraphSchema).
+		WithFieldResolver("updateGQLSchema", a.updateSchema).
+		WithFieldResolver("rollbackGQLSchema", a.rollbackSchema).
+		WithFieldResolver("registeredGQLSchemas", a.registeredSchemas).
+		WithFieldResolver("registerGQLSchema", a.registerSchema).
+		WithFieldResolver("promoteGQLSchema", a.promoteSchema).
+		WithFieldResolver("rollbackGQLSchemaTag", a.rollbackSchemaTag).
+		WithFieldResolver("applyRegisteredGQLSchema", a.applyRegisteredSchema).
+		WithFieldResolver("persistedQueries", a.persistedQueries).
+		WithFieldResolver("registerQueries", a.registerQueries).
+		WithFieldResolver("deregisterQueries", a.deregisterQueries).
+		WithFieldResolver("introspectionMode", a.introspectionMode).
+		WithFieldResolver("setIntrospectionMode", a.setIntrospectionMode).
+		WithFieldResolver("flushOperationCache", a.flushOperationCache)
+	return a, nil
+}
+
+// SetSchemaCheck sets what happens when Dgraph's schema doesn't have what a
+// GraphQL schema needs.  By default, the problems are logged.
+func (a *Admin) SetSchemaCheck(check SchemaCheck) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.schemaCheck = check
+}
+
+// SetSchemaWebhook sets the URL that's notified of each schema update this
+// server applies.  The notification is a POST of the new schema as JSON, in
+// the same form as the admin API's GQLSchema type, so it includes the
+// changelog.  Updates picked up from Dgraph aren't notified, because the
+// server that applied them already did that.  "" turns notifications off.
+func (a *Admin) SetSchemaWebhook(url string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.webhook = url
+}
+
+// SetCallPolicies sets ps as the policies of the calls the admin API makes, to
+// the schema webhook and to introspect remote APIs.
+func (a *Admin) SetCallPolicies(ps external.Policies) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.webhookClient.SetPolicies(ps)
+}
+
+// SetSecrets sets p as the provider that the secrets the schema webhook's
+// URL references, as {{secrets.NAME}}, are looked up in.
+func (a *Admin) SetSecrets(p secrets.Provider) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.secrets = p
+}
+
+// Resolver returns the RequestResolver for the admin API.
+func (a *Admin) Resolver() *resolve.RequestResolver {
+	return a.resolver
+}
+
+// UpdateSchema validates input, applies the Dgraph schema generated from it,
+// stores it in Dgraph and then swaps it in as the schema being served.  If
+// input isn't a valid schema, nothing changes.  Nor does anything change if
+// the API generated from input has breaking changes from the one being
+// served, unless force; the error is then a *BreakingChangeError.
+func (a *Admin) UpdateSchema(ctx context.Context, input string, force bool) error {
+	built, err := a.buildSchema(ctx, input, a.serving())
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.current != nil && !force {
+		if breaking := breakingChanges(built.changesFrom(a.current)); breaking != nil {
+			return &BreakingChangeError{Changes: breaking}
+		}
+	}
+
+	if err := a.dgraphClient.Alter(ctx, built.handler.DGSchema()+storageSchema); err != nil {
+		return errors.Wrap(err, "while applying the new schema to Dgraph")
+	}
+	if err := a.checkDgraph(ctx, built); err != nil {
+		return err
+	}
+	version, err := storeSchema(ctx, a.dgraphClient, input)
+	if err != nil {
+		return err
+	}
+
+	a.serve(built, version)
+	glog.Infof("Successfully updated the GraphQL schema (built in %s, regenerating the API "+
+		"of %d types)", built.buildTime, len(built.delta.Regenerated))
+	if a.webhook != "" {
+		go a.notify(a.webhook, a.secrets, a.current)
+	}
+	return nil
+}
+
+// LoadStoredSchema serves the schema stored in Dgraph, if there is one and
+// it's not already being served, along with the registered queries.  It's
+// used on startup, and to pick up changes that were made through another
+// GraphQL server.
+func (a *Admin) LoadStoredSchema(ctx context.Context) error {
+	node, err := loadStored(ctx, a.dgraphClient)
+	if err != nil {
+		return err
+	}
+	queries, err := node.registered()
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.gqlServer.PersistedQueries().SetRegistered(queries)
+	if node.Introspection != "" && a.gqlServer.IntrospectionPolicy() != nil {
+		if err := a.gqlServer.IntrospectionPolicy().SetMode(node.Introspection); err != nil {
+			glog.Errorf("Ignoring the introspection mode stored in Dgraph: %s", err)
+		}
+	}
+	stored := node.Schema
+	if stored == "" {
+		return nil
+	}
+	if a.current != nil && a.current.schema == stored {
+		return nil
+	}
+
+	built, err := a.buildSchema(ctx, stored, a.current)
+	if err != nil {
+		return errors.Wrap(err, "couldn't build the GraphQL schema stored in Dgraph")
+	}
+	if err := a.checkDgraph(ctx, built); err != nil {
+		return err
+	}
+	version, err := node.version(stored)
+	if err != nil {
+		return err
+	}
+	a.serve(built, version)
+	glog.Infof("Loaded the GraphQL schema stored in Dgraph (built in %s)", built.buildTime)
+	return nil
+}
+
+// PollStoredSchema checks for changes to the schema stored in Dgraph every
+// interval, until ctx is done.
+func (a *Admin) PollStoredSchema(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.LoadStoredSchema(ctx); err != nil {
+				glog.Errorf("Failed to check for a new GraphQL schema: %v", err)
+			}
+		}
+	}
+}
+
+// serving returns the schema being served, or nil if there isn't one.
+func (a *Admin) serving() *gqlSchema {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.current
+}
+
+// buildSchema builds the schema for input, stitching in the remote APIs as
+// they are now.  Only the API of the types that differ from those of from, a
+// schema that was served, is generated; if from is nil, it's all generated.
+// Everything the resolvers need is worked out here, so that it's done once
+// per schema rather than on every request.
+func (a *Admin) buildSchema(ctx context.Context, input string,
+	from *gqlSchema) (*builtSchema, error) {
+
+	remotes := make([]schema.RemoteAPI, len(a.remotes))
+	for i, remote := range a.remotes {
+		sdl, err := a.introspect(ctx, remote.URL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while introspecting remote API %s", remote.Field)
+		}
+		remotes[i] = remote
+		remotes[i].SDL = sdl
+	}
+
+	var prev schema.Handler
+	if from != nil {
+		prev = from.handler
+	}
+	start := time.Now()
+	handler, delta, err := schema.Regenerate(prev, input, remotes...)
+	if err != nil {
+		return nil, err
+	}
+	return &builtSchema{
+		handler:   handler,
+		schema:    handler.Schema(),
+		buildTime: time.Since(start),
+		from:      from,
+		delta:     delta,
+	}, nil
+}
+
+// checkDgraph checks that Dgraph's schema has what built needs.  Any problems
+// are logged, or, if the check is set to fail, returned as a
+// *SchemaMismatchError.  a.mu must be held.
+func (a *Admin) checkDgraph(ctx context.Context, built *builtSchema) error {
+	if a.schemaCheck == SchemaCheckOff {
+		return nil
+	}
+
+	problems, err := checkDgraphSchema(ctx, a.dgraphClient, built.handler.DGPredicates())
+	if err != nil {
+		if a.schemaCheck == SchemaCheckFail {
+			return err
+		}
+		glog.Warningf("Couldn't check Dgraph's schema: %v", err)
+		return nil
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	mismatch := &SchemaMismatchError{Problems: problems}
+	if a.schemaCheck == SchemaCheckFail {
+		return mismatch
+	}
+	glog.Warning(mismatch)
+	return nil
+}
+
+// serve swaps in built, which is the given version in the schema history, as
+// the schema being served, recording how its API differs from the one it
+// replaces.  a.mu must be held.
+func (a *Admin) serve(built *builtSchema, version int) {
+	var changelog []schema.APIChange
+	if a.current != nil {
+		changelog = built.changesFrom(a.current)
+	}
+	for _, change := range changelog {
+		if change.Breaking {
+			glog.Warningf("Breaking change to the GraphQL API: %s", change.Message)
+		}
+	}
+
+	a.gqlServer.SetSchema(built.schema)
+	a.current = &gqlSchema{
+		handler:         built.handler,
+		schema:          built.handler.Input(),
+		generatedSchema: built.handler.GQLSchema(),
+		buildTime:       built.buildTime,
+		version:         version,
+		api:             built.schema,
+		changelog:       changelog,
+	}
+	a.generatedAt.Store(time.Now())
+}
+
+// BreakingChangeError reports the breaking changes that a schema update would
+// make to the API, which is why it wasn't applied.
+type BreakingChangeError struct {
+	Changes []schema.APIChange
+}
+
+func (e *BreakingChangeError) Error() string {
+	msgs := make([]string, len(e.Changes))
+	for i, change := range e.Changes {
+		msgs[i] = change.Message
+	}
+	return fmt.Sprintf("The new schema makes breaking changes to the GraphQL API:\n  %s\n"+
+		"Requests that work now might fail.  Update the schema with force: true to apply "+
+		"it anyway.", strings.Join(msgs, "\n  "))
+}
+
+// breakingChanges returns the changes that could break requests, or nil if
+// there aren't any.
+func breakingChanges(changes []schema.APIChange) []schema.APIChange {
+	var breaking []schema.APIChange
+	for _, change := range changes {
+		if change.Breaking {
+			breaking = append(breaking, change)
+		}
+	}
+	return breaking
+}
+
+// notify POSTs sch to the webhook at url, with the secrets it references
+// looked up in p.  It runs in the background, so a slow or failing webhook
+// can't hold up schema updates; failures are only logged, with url as it's
+// configured.
+func (a *Admin) notify(url string, p secrets.Provider, sch *gqlSchema) {
+	body, err := json.Marshal(sch.asResult())
+	if err != nil {
+		glog.Errorf("Couldn't encode the schema update for webhook %s: %v", url, err)
+		return
+	}
+	u, err := secrets.Expand(context.Background(), p, url)
+	if err != nil {
+		glog.Errorf("Couldn't notify webhook %s of the schema update: %v", url, err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		glog.Errorf("Couldn't make a request for webhook %s", url)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if _, err := a.webhookClient.Do(req, a.webhookClient.Policy(u)); err != nil {
+		glog.Errorf("Failed to notify webhook of the schema update: %v",
+			external.RedactURL(err, u, url))
+	}
+}
+
+func (a *Admin) getSchema(ctx context.Context, field schema.Field) (interface{}, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.current == nil {
+		return nil, nil
+	}
+	return a.current.asResult(), nil
+}
+
+// diffSchema builds the schema it's given, and returns how its API differs
+// from the one being served.  If nothing is being served, there's no
+// difference.
+func (a *Admin) diffSchema(ctx context.Context, field schema.Field) (interface{}, error) {
+	input, _ := field.ArgValue("schema").(string)
+	built, err := a.buildSchema(ctx, input, a.serving())
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.current == nil {
+		return changelogResult(nil), nil
+	}
+	return changelogResult(built.changesFrom(a.current)), nil
+}
+
+func (a *Admin) importDgraphSchema(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	return ImportDgraphSchema(ctx, a.dgraphClient)
+}
+
+func (a *Admin) updateSchema(ctx context.Context, field schema.Field) (interface{}, error) {
+	input, _ := field.ArgValue(schema.InputArgName).(map[string]interface{})
+	set, _ := input["set"].(map[string]interface{})
+	newSchema, _ := set["schema"].(string)
+	force, _ := input["force"].(bool)
+
+	if err := a.UpdateSchema(ctx, newSchema, force); err != nil {
+		return nil, err
+	}
+	return a.updatePayload(), nil
+}
+
+// schemaHistory returns the stored schema versions, newest first.
+func (a *Admin) schemaHistory(ctx context.Context, field schema.Field) (interface{}, error) {
+	node, err := loadStored(ctx, a.dgraphClient)
+	if err != nil {
+		return nil, err
+	}
+	history, err := node.history()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]interface{}, len(history))
+	for i, version := range history {
+		var appliedAt interface{}
+		if version.AppliedAt != nil {
+			appliedAt = version.AppliedAt.Format(time.RFC3339Nano)
+		}
+		res[len(history)-1-i] = map[string]interface{}{
+			"version":   version.Version,
+			"schema":    version.Schema,
+			"appliedAt": appliedAt,
+		}
+	}
+	return res, nil
+}
+
+// rollbackSchema applies the schema that's the given version in the history.
+func (a *Admin) rollbackSchema(ctx context.Context, field schema.Field) (interface{}, error) {
+	input, _ := field.ArgValue(schema.InputArgName).(map[string]interface{})
+	version, err := strconv.Atoi(fmt.Sprintf("%v", input["version"]))
+	if err != nil {
+		return nil, errors.Errorf("version must be a number, but it's %v", input["version"])
+	}
+	force, _ := input["force"].(bool)
+
+	node, err := loadStored(ctx, a.dgraphClient)
+	if err != nil {
+		return nil, err
+	}
+	history, err := node.history()
+	if err != nil {
+		return nil, err
+	}
+	var old *schemaVersion
+	for _, v := range history {
+		if v.Version == version {
+			old = v
+		}
+	}
+	if old == nil {
+		return nil, errors.Errorf("there's no version %d of the GraphQL schema in the history",
+			version)
+	}
+
+	if err := a.UpdateSchema(ctx, old.Schema, force); err != nil {
+		return nil, err
+	}
+	glog.Infof("Rolled the GraphQL schema back to version %d", version)
+	return a.updatePayload(), nil
+}
+
+// updatePayload is the result of a mutation that changed the schema.
+func (a *Admin) updatePayload() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return map[string]interface{}{"gqlSchema": a.current.asResult()}
+}
+
+func (a *Admin) persistedQueries(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	var queries []string
+	for _, pq := range a.gqlServer.PersistedQueries().Registered() {
+		queries = append(queries, pq.Query)
+	}
+	return persistedQueryResults(queries), nil
+}
+
+// registerQueries adds queries to those registered, storing them in Dgraph
+// so that every GraphQL server using the cluster gets them.  Each must be a
+// GraphQL document, but they aren't checked against the schema, which can
+// change.  An operation name can only be in one registered query, so that it
+// picks out which query to run.
+func (a *Admin) registerQueries(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	add := stringList(field.ArgValue("queries"))
+	for i, query := range add {
+		if _, gqlErr := parser.ParseQuery(&ast.Source{Input: query}); gqlErr != nil {
+			return nil, errors.Errorf("query %d isn't a GraphQL document: %s", i, gqlErr.Message)
+		}
+	}
+
+	err := a.updateQueries(ctx, func(queries []string) ([]string, error) {
+		known := make(map[string]bool, len(queries))
+		for _, query := range queries {
+			known[resolve.QueryHash(query)] = true
+		}
+		for _, query := range add {
+			if hash := resolve.QueryHash(query); !known[hash] {
+				known[hash] = true
+				queries = append(queries, query)
+			}
+		}
+		names := make(map[string]string)
+		for _, query := range queries {
+			for _, name := range resolve.OperationNames(query) {
+				if other, ok := names[name]; ok && other != query {
+					return nil, errors.Errorf(
+						"operation %s is in more than one registered query", name)
+				}
+				names[name] = query
+			}
+		}
+		return queries, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return persistedQueryResults(add), nil
+}
+
+// deregisterQueries removes the queries with the given hashes from those
+// registered.
+func (a *Admin) deregisterQueries(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	remove := make(map[string]bool)
+	for _, hash := range stringList(field.ArgValue("sha256Hashes")) {
+		remove[hash] = true
+	}
+
+	var removed []string
+	err := a.updateQueries(ctx, func(queries []string) ([]string, error) {
+		removed = nil
+		var kept []string
+		for _, query := range queries {
+			if remove[resolve.QueryHash(query)] {
+				removed = append(removed, query)
+			} else {
+				kept = append(kept, query)
+			}
+		}
+		return kept, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return persistedQueryResults(removed), nil
+}
+
+// updateQueries updates the registered queries, in Dgraph and on this
+// server, with update.
+func (a *Admin) updateQueries(ctx context.Context,
+	update func(queries []string) ([]string, error)) error {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	queries, err := storeQueries(ctx, a.dgraphClient, update)
+	if err != nil {
+		return err
+	}
+	a.gqlServer.PersistedQueries().SetRegistered(queries)
+	return nil
+}
+
+func (a *Admin) introspectionMode(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	return strings.ToUpper(a.gqlServer.IntrospectionPolicy().Mode()), nil
+}
+
+// setIntrospectionMode changes the introspection mode of this server, and
+// stores it in Dgraph, so that other servers pick it up with the schema.
+func (a *Admin) setIntrospectionMode(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	policy := a.gqlServer.IntrospectionPolicy()
+	if policy == nil {
+		return nil, errors.New("this server's introspection mode can't be changed")
+	}
+	arg, _ := field.ArgValue("mode").(string)
+	mode := strings.ToLower(arg)
+	if err := resolve.ValidIntrospectionMode(mode); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := storeIntrospection(ctx, a.dgraphClient, mode); err != nil {
+		return nil, err
+	}
+	if err := policy.SetMode(mode); err != nil {
+		return nil, err
+	}
+	glog.Infof("Introspection mode set to %s", mode)
+	return arg, nil
+}
+
+// flushOperationCache empties the cache of parsed and validated operations,
+// and their rewritten queries, of the schema that this server serves.
+func (a *Admin) flushOperationCache(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	sch := a.gqlServer.Schema()
+	if sch == nil {
+		return 0, nil
+	}
+	flushed := sch.FlushOperationCache()
+	glog.Infof("Flushed %d operations from the operation cache", flushed)
+	return flushed, nil
+}
+
+func persistedQueryResults(queries []string) []interface{} {
+	res := make([]interface{}, len(queries))
+	for i, query := range queries {
+		res[i] = map[string]interface{}{
+			"sha256Hash":     resolve.QueryHash(query),
+			"query":          query,
+			"operationNames": stringResults(resolve.OperationNames(query)),
+		}
+	}
+	return res
+}
+
+func stringResults(list []string) []interface{} {
+	res := make([]interface{}, len(list))
+	for i, s := range list {
+		res[i] = s
+	}
+	return res
+}
+
+func stringList(val interface{}) []string {
+	list, _ := val.([]interface{})
+	res := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func (s *gqlSchema) asResult() map[string]interface{} {
+	var version interface{}
+	if s.version > 0 {
+		version = s.version
+	}
+	return map[string]interface{}{
+		"schema":          s.schema,
+		"generatedSchema": s.generatedSchema,
+		"buildTime":       s.buildTime.String(),
+		"version":         version,
+		"changelog":       changelogResult(s.changelog),
+	}
+}
+
+func changelogResult(changelog []schema.APIChange) []interface{} {
+	res := make([]interface{}, len(changelog))
+	for i, change := range changelog {
+		res[i] = map[string]interface{}{
+			"breaking": change.Breaking,
+			"message":  change.Message,
+		}
+	}
+	return res
+}
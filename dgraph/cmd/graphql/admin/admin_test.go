@@ -0,0 +1,583 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// memDgraph records schema alterations, keeps the stored GraphQL schema,
+// registered queries, schema history, introspection mode and schema registry
+// in memory and answers every other query from answers, keyed by the query,
+// or else with the same result.  Its Dgraph schema is whatever predicates and types it's
+// given.
+type memDgraph struct {
+	altered       []string
+	stored        string
+	queries       string
+	history       string
+	introspection string
+	registry      string
+	result        string
+	answers       map[string]string
+	predicates    []*api.SchemaNode
+	types         []*dgraph.TypeNode
+}
+
+func (d *memDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	if query.Func.Name == "type" && query.Func.Args[0].Value == schemaType {
+		if d.stored == "" && d.queries == "" && d.history == "" && d.introspection == "" &&
+			d.registry == "" {
+			return []byte(`{"schema": []}`), nil
+		}
+		return json.Marshal(map[string]interface{}{"schema": []interface{}{
+			map[string]interface{}{
+				"uid": "0x1", schemaPredicate: d.stored, queriesPredicate: d.queries,
+				historyPredicate: d.history, introspectionPredicate: d.introspection,
+				registryPredicate: d.registry}}})
+	}
+	if answer, ok := d.answers[dgraph.AsString(query)]; ok {
+		return []byte(answer), nil
+	}
+	return []byte(d.result), nil
+}
+
+func (d *memDgraph) NewTxn() dgraph.Txn {
+	return &memTxn{memDgraph: d}
+}
+
+func (d *memDgraph) Alter(ctx context.Context, schema string) error {
+	d.altered = append(d.altered, schema)
+	return nil
+}
+
+func (d *memDgraph) Schema(ctx context.Context, preds []string) ([]*api.SchemaNode, error) {
+	want := make(map[string]bool, len(preds))
+	for _, pred := range preds {
+		want[pred] = true
+	}
+	var nodes []*api.SchemaNode
+	for _, node := range d.predicates {
+		if want[node.Predicate] {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+func (d *memDgraph) DescribeSchema(ctx context.Context) (*dgraph.FullSchema, error) {
+	return &dgraph.FullSchema{Predicates: d.predicates, Types: d.types}, nil
+}
+
+type memTxn struct {
+	*memDgraph
+	pending map[string]string
+}
+
+func (t *memTxn) Mutate(ctx context.Context, mut *api.Mutation) (map[string]string, error) {
+	return nil, json.Unmarshal(mut.SetJson, &t.pending)
+}
+
+func (t *memTxn) Commit(ctx context.Context) error {
+	if sch, ok := t.pending[schemaPredicate]; ok {
+		t.stored = sch
+	}
+	if queries, ok := t.pending[queriesPredicate]; ok {
+		t.queries = queries
+	}
+	if history, ok := t.pending[historyPredicate]; ok {
+		t.history = history
+	}
+	if mode, ok := t.pending[introspectionPredicate]; ok {
+		t.introspection = mode
+	}
+	if reg, ok := t.pending[registryPredicate]; ok {
+		t.registry = reg
+	}
+	return nil
+}
+
+func (t *memTxn) Discard(ctx context.Context) error {
+	return nil
+}
+
+func resolveToJSON(t *testing.T, r *resolve.RequestResolver, query string,
+	vars map[string]interface{}) (string, *schema.Response) {
+
+	resp := r.Resolve(context.Background(), &schema.Request{Query: query, Variables: vars})
+	var buf bytes.Buffer
+	_, err := resp.WriteTo(&buf)
+	require.NoError(t, err)
+	return buf.String(), resp
+}
+
+const updateSchema = `mutation($sch: String!) {
+	updateGQLSchema(input: { set: { schema: $sch } }) {
+		gqlSchema { schema }
+	}
+}`
+
+func TestUpdateGQLSchema(t *testing.T) {
+	dg := &memDgraph{result: `{"getAuthor": [{"name": "A.N. Author"}]}`}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+	adminServer := adm.Resolver()
+
+	getAuthor := `query { getAuthor(id: "0x1") { name } }`
+	_, resp := resolveToJSON(t, gqlServer, getAuthor, nil)
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message, "There's no GraphQL schema set yet")
+
+	got, _ := resolveToJSON(t, adminServer, `query { getGQLSchema { schema } }`, nil)
+	require.JSONEq(t, `{"data": {"getGQLSchema": null}}`, got)
+
+	newSchema := `type Author { id: ID! name: String! @search(by: [hash]) }`
+	got, _ = resolveToJSON(t, adminServer, updateSchema,
+		map[string]interface{}{"sch": newSchema})
+	require.JSONEq(t,
+		`{"data": {"updateGQLSchema": {"gqlSchema": {"schema": "`+newSchema+`"}}}}`, got)
+	require.Len(t, dg.altered, 1)
+	require.Contains(t, dg.altered[0], "Author.name: string @index(hash) .")
+	require.Contains(t, dg.altered[0], "dgraph.graphql.schema: string .")
+	require.Equal(t, newSchema, dg.stored)
+
+	got, _ = resolveToJSON(t, gqlServer, getAuthor, nil)
+	require.JSONEq(t, `{"data": {"getAuthor": {"name": "A.N. Author"}}}`, got)
+
+	got, _ = resolveToJSON(t, adminServer,
+		`query { getGQLSchema { sch: schema, gen: generatedSchema, buildTime } }`, nil)
+	require.Contains(t, got, `"sch":"`+newSchema+`"`)
+	require.Regexp(t, `"buildTime":"[0-9.]+[µnm]?s"`, got)
+	require.Contains(t, got, `queryAuthor(filter: AuthorFilter`)
+}
+
+func TestUpdateGQLSchemaInvalid(t *testing.T) {
+	dg := &memDgraph{}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+
+	initial := `type Author { id: ID! name: String! }`
+	require.NoError(t, adm.UpdateSchema(context.Background(), initial, false))
+	served := gqlServer.Schema()
+
+	got, resp := resolveToJSON(t, adm.Resolver(), updateSchema,
+		map[string]interface{}{"sch": `type Author { id: ID! name: Strin }`})
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, got, `"updateGQLSchema":null`)
+
+	require.Len(t, dg.altered, 1)
+	require.Equal(t, initial, dg.stored)
+	require.Equal(t, served, gqlServer.Schema())
+}
+
+func TestUpdateGQLSchemaBreaking(t *testing.T) {
+	dg := &memDgraph{}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+
+	initial := `type Author { id: ID! name: String! age: Int }`
+	require.NoError(t, adm.UpdateSchema(context.Background(), initial, false))
+
+	// Adding a field is fine, but removing one isn't, unless it's forced.
+	compatible := `type Author { id: ID! name: String! age: Int bio: String }`
+	_, resp := resolveToJSON(t, adm.Resolver(), updateSchema,
+		map[string]interface{}{"sch": compatible})
+	require.Empty(t, resp.Errors)
+	served := gqlServer.Schema()
+
+	breaking := `type Author { id: ID! name: String! bio: String }`
+	got, resp := resolveToJSON(t, adm.Resolver(), updateSchema,
+		map[string]interface{}{"sch": breaking})
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message,
+		"The new schema makes breaking changes to the GraphQL API:")
+	require.Contains(t, resp.Errors[0].Message, "\n  Field Author.age was removed.\n")
+	require.Contains(t, got, `"updateGQLSchema":null`)
+	require.Equal(t, compatible, dg.stored)
+	require.True(t, served == gqlServer.Schema())
+
+	err = adm.UpdateSchema(context.Background(), breaking, false)
+	require.IsType(t, &BreakingChangeError{}, err)
+
+	_, resp = resolveToJSON(t, adm.Resolver(), `mutation($sch: String!) {
+		updateGQLSchema(input: { set: { schema: $sch }, force: true }) {
+			gqlSchema { schema }
+		}
+	}`, map[string]interface{}{"sch": breaking})
+	require.Empty(t, resp.Errors)
+	require.Equal(t, breaking, dg.stored)
+}
+
+func TestDiffGQLSchema(t *testing.T) {
+	dg := &memDgraph{}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+
+	diff := `query($sch: String!) { diffGQLSchema(schema: $sch) { breaking message } }`
+	candidate := `type Author { id: ID! bio: String }`
+
+	// Nothing's served, so there's nothing to differ from.
+	got, resp := resolveToJSON(t, adm.Resolver(), diff, map[string]interface{}{"sch": candidate})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"data": {"diffGQLSchema": []}}`, got)
+
+	initial := `type Author { id: ID! name: String! }`
+	require.NoError(t, adm.UpdateSchema(context.Background(), initial, false))
+
+	got, resp = resolveToJSON(t, adm.Resolver(), diff, map[string]interface{}{"sch": candidate})
+	require.Empty(t, resp.Errors)
+	require.Contains(t, got, `{"breaking":true,"message":"Field Author.name was removed."}`)
+	require.Contains(t, got, `{"breaking":false,"message":"Field Author.bio was added."}`)
+	require.Equal(t, initial, dg.stored, "diffing doesn't change the schema")
+
+	_, resp = resolveToJSON(t, adm.Resolver(), diff, map[string]interface{}{"sch": "type {"})
+	require.Len(t, resp.Errors, 1)
+}
+
+func TestLoadStoredSchema(t *testing.T) {
+	dg := &memDgraph{result: `{"getAuthor": [{"name": "A.N. Author"}]}`}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+
+	// Nothing stored yet.
+	require.NoError(t, adm.LoadStoredSchema(context.Background()))
+	require.Nil(t, gqlServer.Schema())
+
+	// Stored by another server.
+	dg.stored = `type Author { id: ID! name: String! }`
+	require.NoError(t, adm.LoadStoredSchema(context.Background()))
+	served := gqlServer.Schema()
+	require.NotNil(t, served)
+
+	got, _ := resolveToJSON(t, gqlServer, `query { getAuthor(id: "0x1") { name } }`, nil)
+	require.JSONEq(t, `{"data": {"getAuthor": {"name": "A.N. Author"}}}`, got)
+
+	// Unchanged, so the served schema isn't rebuilt.
+	require.NoError(t, adm.LoadStoredSchema(context.Background()))
+	require.True(t, served == gqlServer.Schema())
+
+	dg.stored = `type Author { id: ID! name: Strin }`
+	require.Error(t, adm.LoadStoredSchema(context.Background()))
+	require.True(t, served == gqlServer.Schema())
+}
+
+func TestUpdateGQLSchemaWithRemote(t *testing.T) {
+	dg := &memDgraph{}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer,
+		schema.RemoteAPI{Field: "payments", Prefix: "Payments", URL: "http://payments"})
+	require.NoError(t, err)
+
+	var introspected []string
+	remoteSDL := `type Payment { amount: Float! } type Query { payment: Payment }`
+	adm.introspect = func(ctx context.Context, url string) (string, error) {
+		introspected = append(introspected, url)
+		return remoteSDL, nil
+	}
+
+	newSchema := `type Author { id: ID! name: String! }`
+	require.NoError(t, adm.UpdateSchema(context.Background(), newSchema, false))
+	require.Equal(t, []string{"http://payments"}, introspected)
+	require.Equal(t, newSchema, dg.stored, "only the local schema is stored")
+	require.NotContains(t, dg.altered[0], "Payment")
+
+	got, _ := resolveToJSON(t, adm.Resolver(), `query { getGQLSchema { generatedSchema } }`, nil)
+	require.Contains(t, got, `payments: PaymentsQuery!`)
+
+	adm.introspect = func(ctx context.Context, url string) (string, error) {
+		return "", errors.New("connection refused")
+	}
+	err = adm.UpdateSchema(context.Background(), `type Post { id: ID! }`, false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "while introspecting remote API payments")
+	require.Equal(t, newSchema, dg.stored)
+}
+
+func TestSchemaChangelog(t *testing.T) {
+	notified := make(chan map[string]interface{}, 2)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		notified <- body
+	}))
+	defer webhook.Close()
+
+	dg := &memDgraph{}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+	adm.SetSchemaWebhook(webhook.URL)
+
+	first := `type Author { id: ID! name: String! age: Int }`
+	require.NoError(t, adm.UpdateSchema(context.Background(), first, false))
+	got, _ := resolveToJSON(t, adm.Resolver(), `query { getGQLSchema { changelog { message } } }`,
+		nil)
+	require.JSONEq(t, `{"data": {"getGQLSchema": {"changelog": []}}}`, got)
+	require.Equal(t, first, (<-notified)["schema"])
+
+	second := `type Author { id: ID! name: String! }`
+	require.NoError(t, adm.UpdateSchema(context.Background(), second, true))
+	got, _ = resolveToJSON(t, adm.Resolver(),
+		`query { getGQLSchema { changelog { breaking message } } }`, nil)
+	require.Contains(t, got, `{"breaking":true,"message":"Field Author.age was removed."}`)
+
+	body := <-notified
+	require.Equal(t, second, body["schema"])
+	require.Contains(t, body["changelog"], map[string]interface{}{
+		"breaking": true,
+		"message":  "Field Author.age was removed.",
+	})
+
+	// Picking up a schema from Dgraph records a changelog, but doesn't notify.
+	dg.stored = `type Author { id: ID! name: String! bio: String }`
+	require.NoError(t, adm.LoadStoredSchema(context.Background()))
+	got, _ = resolveToJSON(t, adm.Resolver(),
+		`query { getGQLSchema { changelog { breaking message } } }`, nil)
+	require.Contains(t, got, `{"breaking":false,"message":"Field Author.bio was added."}`)
+	select {
+	case <-notified:
+		t.Fatal("the webhook shouldn't be notified of schemas loaded from Dgraph")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSchemaHistory(t *testing.T) {
+	dg := &memDgraph{}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+	admin := adm.Resolver()
+
+	// A schema stored before there was a history is its first version.
+	first := `type Author { id: ID! name: String! age: Int }`
+	dg.stored = first
+	require.NoError(t, adm.LoadStoredSchema(context.Background()))
+	got, _ := resolveToJSON(t, admin, `query { getGQLSchema { version } }`, nil)
+	require.JSONEq(t, `{"data": {"getGQLSchema": {"version": 1}}}`, got)
+
+	second := `type Author { id: ID! name: String! }`
+	require.NoError(t, adm.UpdateSchema(context.Background(), second, true))
+
+	var resp struct {
+		Data struct {
+			GetGQLSchemaHistory []struct {
+				Version   int
+				Schema    string
+				AppliedAt *string
+			}
+		}
+	}
+	got, _ = resolveToJSON(t, admin,
+		`query { getGQLSchemaHistory { version schema appliedAt } }`, nil)
+	require.NoError(t, json.Unmarshal([]byte(got), &resp))
+	history := resp.Data.GetGQLSchemaHistory
+	require.Len(t, history, 2)
+	require.Equal(t, 2, history[0].Version, "the newest version is first")
+	require.Equal(t, second, history[0].Schema)
+	require.NotNil(t, history[0].AppliedAt)
+	_, err = time.Parse(time.RFC3339Nano, *history[0].AppliedAt)
+	require.NoError(t, err)
+	require.Equal(t, 1, history[1].Version)
+	require.Equal(t, first, history[1].Schema)
+	require.Nil(t, history[1].AppliedAt)
+
+	rollback := `mutation($version: Int!, $force: Boolean) {
+		rollbackGQLSchema(input: { version: $version, force: $force }) {
+			gqlSchema { schema version }
+		}
+	}`
+
+	// Going back to version 1 adds Author.age back, which isn't breaking.
+	got, _ = resolveToJSON(t, admin, rollback, map[string]interface{}{"version": 1})
+	require.JSONEq(t, fmt.Sprintf(
+		`{"data": {"rollbackGQLSchema": {"gqlSchema": {"schema": %q, "version": 3}}}}`, first),
+		got)
+	require.Equal(t, first, dg.stored)
+
+	// Going back to version 2 removes it again, so it has to be forced.
+	_, resp2 := resolveToJSON(t, admin, rollback, map[string]interface{}{"version": 2})
+	require.Len(t, resp2.Errors, 1)
+	require.Contains(t, resp2.Errors[0].Message, "Field Author.age was removed.")
+	require.Equal(t, first, dg.stored)
+
+	got, _ = resolveToJSON(t, admin, rollback,
+		map[string]interface{}{"version": 2, "force": true})
+	require.JSONEq(t, fmt.Sprintf(
+		`{"data": {"rollbackGQLSchema": {"gqlSchema": {"schema": %q, "version": 4}}}}`, second),
+		got)
+
+	_, resp2 = resolveToJSON(t, admin, rollback, map[string]interface{}{"version": 7})
+	require.Len(t, resp2.Errors, 1)
+	require.Equal(t, "there's no version 7 of the GraphQL schema in the history",
+		resp2.Errors[0].Message)
+}
+
+func TestSchemaHistoryLimit(t *testing.T) {
+	dg := &memDgraph{}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+
+	for i := 1; i <= maxSchemaHistory+2; i++ {
+		sch := fmt.Sprintf("type Author { id: ID! name: String! field%d: Int }", i)
+		require.NoError(t, adm.UpdateSchema(context.Background(), sch, true))
+	}
+
+	node, err := loadStored(context.Background(), dg)
+	require.NoError(t, err)
+	history, err := node.history()
+	require.NoError(t, err)
+	require.Len(t, history, maxSchemaHistory)
+	require.Equal(t, 3, history[0].Version)
+	require.Equal(t, maxSchemaHistory+2, history[maxSchemaHistory-1].Version)
+}
+
+func TestRegisterQueries(t *testing.T) {
+	dg := &memDgraph{}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+	adminServer := adm.Resolver()
+
+	queries := []interface{}{`query { a }`, `query { b }`}
+	got, resp := resolveToJSON(t, adminServer,
+		`mutation($qs: [String!]!) { registerQueries(queries: $qs) { sha256Hash } }`,
+		map[string]interface{}{"qs": queries})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"data": {"registerQueries": [
+		{"sha256Hash": "`+resolve.QueryHash("query { a }")+`"},
+		{"sha256Hash": "`+resolve.QueryHash("query { b }")+`"}]}}`, got)
+	require.Len(t, gqlServer.PersistedQueries().Registered(), 2)
+
+	_, resp = resolveToJSON(t, adminServer,
+		`mutation { registerQueries(queries: ["query {"]) { sha256Hash } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message, "query 0 isn't a GraphQL document")
+
+	got, resp = resolveToJSON(t, adminServer,
+		`mutation($hs: [String!]!) { deregisterQueries(sha256Hashes: $hs) { query } }`,
+		map[string]interface{}{"hs": []interface{}{resolve.QueryHash("query { a }")}})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"data": {"deregisterQueries": [{"query": "query { a }"}]}}`, got)
+
+	got, _ = resolveToJSON(t, adminServer, `query { persistedQueries { query } }`, nil)
+	require.JSONEq(t, `{"data": {"persistedQueries": [{"query": "query { b }"}]}}`, got)
+
+	// Other servers pick the registered queries up from Dgraph.
+	other := resolve.New(nil, dg)
+	otherAdm, err := New(dg, other)
+	require.NoError(t, err)
+	require.NoError(t, otherAdm.LoadStoredSchema(context.Background()))
+	require.Equal(t, []resolve.PersistedQuery{
+		{Hash: resolve.QueryHash("query { b }"), Query: "query { b }"},
+	}, other.PersistedQueries().Registered())
+}
+
+func TestRegisterQueriesOperationNames(t *testing.T) {
+	dg := &memDgraph{}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+	adminServer := adm.Resolver()
+
+	register := `mutation($qs: [String!]!) {
+		registerQueries(queries: $qs) { operationNames } }`
+	got, resp := resolveToJSON(t, adminServer, register, map[string]interface{}{
+		"qs": []interface{}{`query A { a } query B { b }`, `query { c }`}})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"data": {"registerQueries": [
+		{"operationNames": ["A", "B"]}, {"operationNames": []}]}}`, got)
+
+	_, resp = resolveToJSON(t, adminServer, register, map[string]interface{}{
+		"qs": []interface{}{`query B { c }`}})
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message,
+		"operation B is in more than one registered query")
+	require.Len(t, gqlServer.PersistedQueries().Registered(), 2)
+}
+
+func TestSetIntrospectionMode(t *testing.T) {
+	dg := &memDgraph{}
+	policy, err := resolve.NewIntrospectionPolicy(resolve.IntrospectionEnabled, "role", "admin")
+	require.NoError(t, err)
+	gqlServer := resolve.New(nil, dg).WithIntrospectionPolicy(policy)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+	adminServer := adm.Resolver()
+
+	got, resp := resolveToJSON(t, adminServer, `query { introspectionMode }`, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"data": {"introspectionMode": "ENABLED"}}`, got)
+
+	got, resp = resolveToJSON(t, adminServer,
+		`mutation { setIntrospectionMode(mode: DISABLED) }`, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"data": {"setIntrospectionMode": "DISABLED"}}`, got)
+	require.Equal(t, resolve.IntrospectionDisabled, policy.Mode())
+	require.Equal(t, resolve.IntrospectionDisabled, dg.introspection)
+
+	// Other servers pick the mode up from Dgraph.
+	otherPolicy, err := resolve.NewIntrospectionPolicy(resolve.IntrospectionEnabled, "role",
+		"admin")
+	require.NoError(t, err)
+	otherAdm, err := New(dg, resolve.New(nil, dg).WithIntrospectionPolicy(otherPolicy))
+	require.NoError(t, err)
+	require.NoError(t, otherAdm.LoadStoredSchema(context.Background()))
+	require.Equal(t, resolve.IntrospectionDisabled, otherPolicy.Mode())
+}
+
+func TestFlushOperationCache(t *testing.T) {
+	dg := &memDgraph{result: `{"getAuthor": [{"name": "A.N. Author"}]}`}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+	adminServer := adm.Resolver()
+
+	flush := `mutation { flushOperationCache }`
+	got, resp := resolveToJSON(t, adminServer, flush, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"data": {"flushOperationCache": 0}}`, got)
+
+	require.NoError(t, adm.UpdateSchema(context.Background(),
+		`type Author { id: ID! name: String! }`, false))
+	for i := 0; i < 2; i++ {
+		_, resp = resolveToJSON(t, gqlServer, `query { getAuthor(id: "0x1") { name } }`, nil)
+		require.Empty(t, resp.Errors)
+	}
+
+	got, resp = resolveToJSON(t, adminServer, flush, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"data": {"flushOperationCache": 1}}`, got)
+	got, _ = resolveToJSON(t, adminServer, flush, nil)
+	require.JSONEq(t, `{"data": {"flushOperationCache": 0}}`, got)
+}
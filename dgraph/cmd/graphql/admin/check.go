@@ -0,0 +1,128 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+)
+
+// SchemaCheck says what happens when a GraphQL schema is served, or applied,
+// and Dgraph's schema doesn't have the predicates and indexes the resolvers
+// rely on - e.g. because someone altered Dgraph's schema directly.
+type SchemaCheck string
+
+const (
+	// SchemaCheckWarn logs what's wrong and serves the schema anyway.
+	SchemaCheckWarn SchemaCheck = "warn"
+
+	// SchemaCheckFail refuses to serve the schema.
+	SchemaCheckFail SchemaCheck = "fail"
+
+	// SchemaCheckOff doesn't check.
+	SchemaCheckOff SchemaCheck = "off"
+)
+
+// ParseSchemaCheck returns the SchemaCheck called name.
+func ParseSchemaCheck(name string) (SchemaCheck, error) {
+	switch c := SchemaCheck(name); c {
+	case SchemaCheckWarn, SchemaCheckFail, SchemaCheckOff:
+		return c, nil
+	}
+	return "", errors.Errorf("%q isn't a schema check; expected warn, fail or off", name)
+}
+
+// SchemaMismatchError reports the ways Dgraph's schema doesn't fit a GraphQL
+// schema.
+type SchemaMismatchError struct {
+	Problems []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	return fmt.Sprintf("Dgraph's schema doesn't have what the GraphQL schema needs:\n  %s\n"+
+		"Applying the GraphQL schema again, with updateGQLSchema in the admin API, "+
+		"alters Dgraph's schema to fit.", strings.Join(e.Problems, "\n  "))
+}
+
+// checkDgraphSchema compares Dgraph's schema with preds, the predicates that
+// a GraphQL schema needs, and describes every difference that would make the
+// resolvers fail.
+func checkDgraphSchema(ctx context.Context, dgraphClient dgraph.Client,
+	preds []schema.Predicate) ([]string, error) {
+
+	names := make([]string, len(preds))
+	for i, pred := range preds {
+		names[i] = pred.Name
+	}
+	nodes, err := dgraphClient.Schema(ctx, names)
+	if err != nil {
+		return nil, errors.Wrap(err, "while checking Dgraph's schema")
+	}
+	have := make(map[string]*api.SchemaNode, len(nodes))
+	for _, node := range nodes {
+		have[node.Predicate] = node
+	}
+
+	var problems []string
+	for _, pred := range preds {
+		node, ok := have[pred.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: isn't in Dgraph's schema", pred.Name))
+			continue
+		}
+
+		typ := node.Type
+		if node.List {
+			typ = "[" + typ + "]"
+		}
+		if !strings.EqualFold(typ, pred.Type) {
+			problems = append(problems, fmt.Sprintf("%s: has type %s in Dgraph, but needs %s",
+				pred.Name, typ, pred.Type))
+		}
+
+		tokenizers := make(map[string]bool, len(node.Tokenizer))
+		for _, tok := range node.Tokenizer {
+			tokenizers[tok] = true
+		}
+		var missing []string
+		for _, index := range pred.Indexes {
+			if !tokenizers[index] {
+				missing = append(missing, index)
+			}
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: needs @index(%s), but Dgraph's "+
+				"schema is missing %s", pred.Name, strings.Join(pred.Indexes, ", "),
+				strings.Join(missing, ", ")))
+		}
+		if pred.Reverse && !node.Reverse {
+			problems = append(problems,
+				fmt.Sprintf("%s: needs @reverse, but Dgraph's schema doesn't have it", pred.Name))
+		}
+		if pred.Upsert && !node.Upsert {
+			problems = append(problems,
+				fmt.Sprintf("%s: needs @upsert, but Dgraph's schema doesn't have it", pred.Name))
+		}
+	}
+	return problems, nil
+}
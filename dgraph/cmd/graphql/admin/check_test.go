@@ -0,0 +1,142 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const checkedSchema = `
+type Author {
+	id: ID!
+	name: String! @search(by: [term, exact])
+	posts: [Post]
+}
+
+type Post {
+	id: ID!
+	title: String
+}`
+
+func TestCheckDgraphSchema(t *testing.T) {
+	handler, err := schema.NewHandler(checkedSchema)
+	require.NoError(t, err)
+
+	dg := &memDgraph{predicates: []*api.SchemaNode{
+		{Predicate: "Author.name", Type: "string", Index: true, Tokenizer: []string{"term"}},
+		{Predicate: "Author.posts", Type: "uid"},
+	}}
+	problems, err := checkDgraphSchema(context.Background(), dg, handler.DGPredicates())
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Author.name: needs @index(term, exact), but Dgraph's schema is missing exact",
+		"Author.posts: has type uid in Dgraph, but needs [uid]",
+		"Post.title: isn't in Dgraph's schema",
+	}, problems)
+
+	dg.predicates = []*api.SchemaNode{
+		{Predicate: "Author.name", Type: "string", Index: true,
+			Tokenizer: []string{"exact", "term", "trigram"}},
+		{Predicate: "Author.posts", Type: "uid", List: true},
+		{Predicate: "Post.title", Type: "string"},
+	}
+	problems, err = checkDgraphSchema(context.Background(), dg, handler.DGPredicates())
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
+
+func TestCheckDgraphSchemaReverse(t *testing.T) {
+	handler, err := schema.NewHandler(`
+		type Author {
+			id: ID!
+			posts: [Post] @hasInverse(field: author, reverse: true)
+		}
+		type Post {
+			id: ID!
+			author: Author
+		}`)
+	require.NoError(t, err)
+
+	dg := &memDgraph{predicates: []*api.SchemaNode{{Predicate: "Post.author", Type: "uid"}}}
+	problems, err := checkDgraphSchema(context.Background(), dg, handler.DGPredicates())
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Post.author: needs @reverse, but Dgraph's schema doesn't have it",
+	}, problems)
+
+	dg.predicates[0].Reverse = true
+	problems, err = checkDgraphSchema(context.Background(), dg, handler.DGPredicates())
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
+
+func TestCheckDgraphSchemaXid(t *testing.T) {
+	handler, err := schema.NewHandler(`
+		type Customer {
+			id: ID!
+			customerID: String! @xid
+		}`)
+	require.NoError(t, err)
+
+	// The bulk loader's xid predicate, with --store_xids, isn't @upsert.
+	dg := &memDgraph{predicates: []*api.SchemaNode{
+		{Predicate: "xid", Type: "string", Index: true, Tokenizer: []string{"hash"}},
+	}}
+	problems, err := checkDgraphSchema(context.Background(), dg, handler.DGPredicates())
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"xid: needs @upsert, but Dgraph's schema doesn't have it",
+	}, problems)
+
+	dg.predicates[0].Upsert = true
+	problems, err = checkDgraphSchema(context.Background(), dg, handler.DGPredicates())
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
+
+func TestSchemaCheck(t *testing.T) {
+	dg := &memDgraph{stored: checkedSchema}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+
+	adm.SetSchemaCheck(SchemaCheckFail)
+	err = adm.LoadStoredSchema(context.Background())
+	require.IsType(t, &SchemaMismatchError{}, err)
+	require.Len(t, err.(*SchemaMismatchError).Problems, 3)
+	require.Contains(t, err.Error(), "Applying the GraphQL schema again")
+	require.Nil(t, gqlServer.Schema(), "a failed check stops the schema being served")
+
+	adm.SetSchemaCheck(SchemaCheckWarn)
+	require.NoError(t, adm.LoadStoredSchema(context.Background()))
+	require.NotNil(t, gqlServer.Schema())
+}
+
+func TestParseSchemaCheck(t *testing.T) {
+	check, err := ParseSchemaCheck("fail")
+	require.NoError(t, err)
+	require.Equal(t, SchemaCheckFail, check)
+
+	_, err = ParseSchemaCheck("strict")
+	require.EqualError(t, err, `"strict" isn't a schema check; expected warn, fail or off`)
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+)
+
+// ImportDgraphSchema returns a best-effort GraphQL schema for the data in
+// Dgraph, worked out from its types, predicates and indexes, as
+// schema.SDLFromDgraph describes.
+func ImportDgraphSchema(ctx context.Context, dgraphClient dgraph.Client) (string, error) {
+	full, err := dgraphClient.DescribeSchema(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	preds := make([]schema.Predicate, len(full.Predicates))
+	for i, node := range full.Predicates {
+		typ := node.Type
+		if node.List {
+			typ = "[" + typ + "]"
+		}
+		preds[i] = schema.Predicate{Name: node.Predicate, Type: typ, Indexes: node.Tokenizer,
+			Reverse: node.Reverse}
+	}
+
+	types := make([]schema.DgraphType, len(full.Types))
+	for i, node := range full.Types {
+		types[i].Name = node.Name
+		for _, fld := range node.Fields {
+			types[i].Fields = append(types[i].Fields,
+				schema.DgraphField{Predicate: fld.Name, Type: fld.Type})
+		}
+	}
+
+	return schema.SDLFromDgraph(types, preds), nil
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportDgraphSchema(t *testing.T) {
+	dg := &memDgraph{
+		predicates: []*api.SchemaNode{
+			{Predicate: "name", Type: "string", Index: true, Tokenizer: []string{"exact"}},
+			{Predicate: "Person.friends", Type: "uid", List: true},
+			{Predicate: "dgraph.type", Type: "string", List: true},
+		},
+		types: []*dgraph.TypeNode{{Name: "Person", Fields: []*dgraph.TypeField{
+			{Name: "name", Type: "string"},
+			{Name: "Person.friends", Type: "[Person]"},
+		}}},
+	}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+
+	_, resp := resolveToJSON(t, adm.Resolver(), `query { importDgraphSchema }`, nil)
+	require.Empty(t, resp.Errors)
+	require.Equal(t, `{"importDgraphSchema": "type Person {\n\tid: ID!\n\t`+
+		`name: String @dgraph(pred: \"name\") @search(by: [exact])\n\t`+
+		`friends: [Person]\n}\n"}`, resp.Data.String())
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+)
+
+// probeTimeout bounds how long a probe waits for Dgraph to answer.
+const probeTimeout = 5 * time.Second
+
+// Probe is whether the server is ready to serve the GraphQL API: it has a
+// schema to serve, and Dgraph answers.  It's what the admin API's health
+// query, and the /probe/graphql endpoints, report.
+type Probe struct {
+	Ready        bool `json:"ready"`
+	SchemaLoaded bool `json:"schemaLoaded"`
+
+	// SchemaGeneratedAt is when the schema being served was built.
+	SchemaGeneratedAt *time.Time `json:"schemaGeneratedAt,omitempty"`
+
+	Dgraph DgraphProbe `json:"dgraph"`
+}
+
+// DgraphProbe is how Dgraph answered a probe.
+type DgraphProbe struct {
+	Available bool `json:"available"`
+
+	// Latency is how long Dgraph took to answer a trivial query, e.g. "1.2ms".
+	Latency string `json:"latency,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Probe checks whether the server is ready to serve the GraphQL API.  It
+// doesn't wait for schema updates, so it can be used while one's running.
+func (a *Admin) Probe(ctx context.Context) *Probe {
+	probe := &Probe{}
+	if generated, ok := a.generatedAt.Load().(time.Time); ok {
+		probe.SchemaLoaded = true
+		probe.SchemaGeneratedAt = &generated
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	latency, err := dgraph.Ping(ctx, a.dgraphClient)
+	if err != nil {
+		probe.Dgraph.Error = err.Error()
+	} else {
+		probe.Dgraph.Available = true
+		probe.Dgraph.Latency = latency.String()
+	}
+
+	probe.Ready = probe.SchemaLoaded && probe.Dgraph.Available
+	return probe
+}
+
+func (a *Admin) health(ctx context.Context, field schema.Field) (interface{}, error) {
+	probe := a.Probe(ctx)
+	res := map[string]interface{}{
+		"ready":        probe.Ready,
+		"schemaLoaded": probe.SchemaLoaded,
+		"dgraph": map[string]interface{}{
+			"available": probe.Dgraph.Available,
+			"latency":   nullable(probe.Dgraph.Latency),
+			"error":     nullable(probe.Dgraph.Error),
+		},
+	}
+	if probe.SchemaGeneratedAt != nil {
+		res["schemaGeneratedAt"] = probe.SchemaGeneratedAt.Format(time.RFC3339Nano)
+	}
+	return res, nil
+}
+
+// nullable is s, or nil if it's "".
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// downDgraph can't be queried.
+type downDgraph struct {
+	*memDgraph
+}
+
+func (d *downDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestHealthQuery(t *testing.T) {
+	dg := &memDgraph{result: `{"health": [{"uid": "0x1"}]}`}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+	health := `query { health { ready schemaLoaded schemaGeneratedAt
+		dgraph { available latency error } } }`
+
+	got, _ := resolveToJSON(t, adm.Resolver(), health, nil)
+	var resp struct {
+		Data struct {
+			Health struct {
+				Ready             bool
+				SchemaLoaded      bool
+				SchemaGeneratedAt *string
+				Dgraph            struct {
+					Available bool
+					Latency   *string
+					Error     *string
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(got), &resp))
+	require.False(t, resp.Data.Health.Ready, "there's no schema yet")
+	require.False(t, resp.Data.Health.SchemaLoaded)
+	require.Nil(t, resp.Data.Health.SchemaGeneratedAt)
+	require.True(t, resp.Data.Health.Dgraph.Available)
+	require.NotNil(t, resp.Data.Health.Dgraph.Latency)
+	require.Nil(t, resp.Data.Health.Dgraph.Error)
+
+	before := time.Now()
+	require.NoError(t, adm.UpdateSchema(context.Background(),
+		`type Author { id: ID! name: String! }`, false))
+
+	got, _ = resolveToJSON(t, adm.Resolver(), health, nil)
+	require.NoError(t, json.Unmarshal([]byte(got), &resp))
+	require.True(t, resp.Data.Health.Ready)
+	require.True(t, resp.Data.Health.SchemaLoaded)
+	generated, err := time.Parse(time.RFC3339Nano, *resp.Data.Health.SchemaGeneratedAt)
+	require.NoError(t, err)
+	require.False(t, generated.Before(before.Truncate(time.Second)))
+}
+
+func TestProbeDgraphDown(t *testing.T) {
+	dg := &memDgraph{}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+	require.NoError(t, adm.UpdateSchema(context.Background(),
+		`type Author { id: ID! name: String! }`, false))
+
+	adm.dgraphClient = &downDgraph{dg}
+	probe := adm.Probe(context.Background())
+	require.False(t, probe.Ready)
+	require.True(t, probe.SchemaLoaded)
+	require.Equal(t, DgraphProbe{Error: "connection refused"}, probe.Dgraph)
+}
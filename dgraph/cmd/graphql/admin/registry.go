@@ -0,0 +1,413 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// The schema registry keeps named schemas, each with numbered versions, so
+// that a schema can be registered, tried out and promoted to production
+// without tooling outside the admin API.  Each named schema has the tags
+// dev, staging and prod, which point at one of its versions.  A tag keeps
+// the versions it pointed at before, so it can be rolled back.  Registering
+// and promoting schemas doesn't change the schema being served; that's
+// what applyRegisteredGQLSchema is for.
+
+// schemaTags are the registry's tags, in the order that schemas are
+// promoted through them.
+var schemaTags = []string{"dev", "staging", "prod"}
+
+// maxRegisteredVersions is how many versions of a named schema are kept.
+// Older versions are only dropped if no tag points, or pointed, at them.
+const maxRegisteredVersions = 50
+
+var registryName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// A registry is the schema registry, as it's stored in Dgraph.
+type registry struct {
+	Schemas map[string]*registeredSchema `json:"schemas"`
+}
+
+// A registeredSchema is the versions of a named schema.  Tags maps each tag
+// to the versions it has pointed at, oldest first, so the last is the
+// version it points at now.
+type registeredSchema struct {
+	Versions []*registeredVersion `json:"versions"`
+	Tags     map[string][]int     `json:"tags,omitempty"`
+}
+
+type registeredVersion struct {
+	Version      int       `json:"version"`
+	Schema       string    `json:"schema"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// register adds sch as the newest version of the schema called name and
+// points tag, unless it's "", at it.  If sch is the same as the newest
+// version, that version is tagged rather than a new one added.  It returns
+// the version.
+func (r *registry) register(name, sch, tag string) (int, error) {
+	if !registryName.MatchString(name) {
+		return 0, errors.Errorf("%q isn't a valid schema name; names can only have letters, "+
+			"digits, '_', '.' and '-'", name)
+	}
+	if r.Schemas == nil {
+		r.Schemas = make(map[string]*registeredSchema)
+	}
+	reg := r.Schemas[name]
+	if reg == nil {
+		reg = &registeredSchema{}
+		r.Schemas[name] = reg
+	}
+
+	var version int
+	if n := len(reg.Versions); n > 0 && reg.Versions[n-1].Schema == sch {
+		version = reg.Versions[n-1].Version
+	} else {
+		version = 1
+		if n > 0 {
+			version = reg.Versions[n-1].Version + 1
+		}
+		reg.Versions = append(reg.Versions, &registeredVersion{
+			Version:      version,
+			Schema:       sch,
+			RegisteredAt: time.Now().UTC(),
+		})
+	}
+	if tag != "" {
+		reg.setTag(tag, version)
+	}
+	reg.prune()
+	return version, nil
+}
+
+// promote points tag to at the version that from points at.  If to is "",
+// it's the tag after from.  It returns the version.
+func (r *registry) promote(name, from, to string) (int, error) {
+	reg, err := r.named(name)
+	if err != nil {
+		return 0, err
+	}
+	version := reg.tagged(from)
+	if version == 0 {
+		return 0, errors.Errorf("schema %s has no %s version to promote", name, from)
+	}
+	if to == "" {
+		next := tagIndex(from) + 1
+		if next >= len(schemaTags) {
+			return 0, errors.Errorf("there's no tag after %s to promote schema %s to",
+				from, name)
+		}
+		to = schemaTags[next]
+	}
+	if to == from {
+		return 0, errors.Errorf("can't promote schema %s from %s to itself", name, from)
+	}
+	reg.setTag(to, version)
+	return version, nil
+}
+
+// rollback points tag back at the version it pointed at before.  It returns
+// the version.
+func (r *registry) rollback(name, tag string) (int, error) {
+	reg, err := r.named(name)
+	if err != nil {
+		return 0, err
+	}
+	versions := reg.Tags[tag]
+	if len(versions) < 2 {
+		return 0, errors.Errorf("schema %s's %s tag has no earlier version to roll back to",
+			name, tag)
+	}
+	reg.Tags[tag] = versions[:len(versions)-1]
+	return versions[len(versions)-2], nil
+}
+
+// lookup finds the version of the schema called name that tag points at,
+// or, if tag is "", the given version.
+func (r *registry) lookup(name, tag string, version int) (*registeredVersion, error) {
+	reg, err := r.named(name)
+	if err != nil {
+		return nil, err
+	}
+	if tag != "" {
+		if version = reg.tagged(tag); version == 0 {
+			return nil, errors.Errorf("schema %s has no %s version", name, tag)
+		}
+	}
+	for _, v := range reg.Versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, errors.Errorf("there's no version %d of schema %s", version, name)
+}
+
+func (r *registry) named(name string) (*registeredSchema, error) {
+	reg := r.Schemas[name]
+	if reg == nil {
+		return nil, errors.Errorf("there's no schema called %s in the registry", name)
+	}
+	return reg, nil
+}
+
+// tagged returns the version that tag points at, or 0 if it isn't set.
+func (reg *registeredSchema) tagged(tag string) int {
+	versions := reg.Tags[tag]
+	if len(versions) == 0 {
+		return 0
+	}
+	return versions[len(versions)-1]
+}
+
+// setTag points tag at version.  Only the latest maxSchemaHistory versions
+// that a tag pointed at are kept.
+func (reg *registeredSchema) setTag(tag string, version int) {
+	if reg.tagged(tag) == version {
+		return
+	}
+	if reg.Tags == nil {
+		reg.Tags = make(map[string][]int)
+	}
+	versions := append(reg.Tags[tag], version)
+	if len(versions) > maxSchemaHistory {
+		versions = versions[len(versions)-maxSchemaHistory:]
+	}
+	reg.Tags[tag] = versions
+}
+
+// prune drops the oldest versions beyond maxRegisteredVersions that no tag
+// points, or pointed, at.
+func (reg *registeredSchema) prune() {
+	extra := len(reg.Versions) - maxRegisteredVersions
+	if extra <= 0 {
+		return
+	}
+	tagged := make(map[int]bool)
+	for _, versions := range reg.Tags {
+		for _, v := range versions {
+			tagged[v] = true
+		}
+	}
+	kept := reg.Versions[:0]
+	for _, v := range reg.Versions {
+		if extra > 0 && !tagged[v.Version] {
+			extra--
+			continue
+		}
+		kept = append(kept, v)
+	}
+	reg.Versions = kept
+}
+
+func tagIndex(tag string) int {
+	for i, t := range schemaTags {
+		if t == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// registeredSchemas returns the schemas in the registry, or just the one
+// called name, if it's given.
+func (a *Admin) registeredSchemas(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	node, err := loadStored(ctx, a.dgraphClient)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := node.registry()
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := field.ArgValue("name").(string)
+	names := make([]string, 0, len(reg.Schemas))
+	for n := range reg.Schemas {
+		if name == "" || n == name {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	res := make([]interface{}, len(names))
+	for i, n := range names {
+		res[i] = registeredResult(n, reg.Schemas[n])
+	}
+	return res, nil
+}
+
+// registerSchema adds a version to a named schema in the registry.  The
+// schema must be valid, but it isn't applied.
+func (a *Admin) registerSchema(ctx context.Context, field schema.Field) (interface{}, error) {
+	input, _ := field.ArgValue(schema.InputArgName).(map[string]interface{})
+	name, _ := input["name"].(string)
+	sch, _ := input["schema"].(string)
+	tag := schemaTags[0]
+	if t, ok := input["tag"].(string); ok {
+		tag = strings.ToLower(t)
+	}
+
+	if _, err := a.buildSchema(ctx, sch, a.serving()); err != nil {
+		return nil, err
+	}
+	return a.updateRegistry(ctx, name, func(reg *registry) error {
+		version, err := reg.register(name, sch, tag)
+		if err == nil {
+			glog.Infof("Registered version %d of GraphQL schema %s as %s", version, name, tag)
+		}
+		return err
+	})
+}
+
+// promoteSchema points a named schema's tag at the version another of its
+// tags points at.
+func (a *Admin) promoteSchema(ctx context.Context, field schema.Field) (interface{}, error) {
+	input, _ := field.ArgValue(schema.InputArgName).(map[string]interface{})
+	name, _ := input["name"].(string)
+	from, _ := input["from"].(string)
+	to, _ := input["to"].(string)
+
+	return a.updateRegistry(ctx, name, func(reg *registry) error {
+		version, err := reg.promote(name, strings.ToLower(from), strings.ToLower(to))
+		if err == nil {
+			glog.Infof("Promoted version %d of GraphQL schema %s from %s", version, name,
+				strings.ToLower(from))
+		}
+		return err
+	})
+}
+
+// rollbackSchemaTag points a named schema's tag back at the version it
+// pointed at before.
+func (a *Admin) rollbackSchemaTag(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	input, _ := field.ArgValue(schema.InputArgName).(map[string]interface{})
+	name, _ := input["name"].(string)
+	tag, _ := input["tag"].(string)
+
+	return a.updateRegistry(ctx, name, func(reg *registry) error {
+		version, err := reg.rollback(name, strings.ToLower(tag))
+		if err == nil {
+			glog.Infof("Rolled GraphQL schema %s's %s tag back to version %d", name,
+				strings.ToLower(tag), version)
+		}
+		return err
+	})
+}
+
+// applyRegisteredSchema applies a version of a named schema, as
+// updateGQLSchema would, picked either by a tag or by its number.
+func (a *Admin) applyRegisteredSchema(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	input, _ := field.ArgValue(schema.InputArgName).(map[string]interface{})
+	name, _ := input["name"].(string)
+	tag, _ := input["tag"].(string)
+	force, _ := input["force"].(bool)
+	var version int
+	if v, ok := input["version"]; ok && v != nil {
+		var err error
+		if version, err = strconv.Atoi(fmt.Sprintf("%v", v)); err != nil {
+			return nil, errors.Errorf("version must be a number, but it's %v", v)
+		}
+	}
+	if (tag == "") == (version == 0) {
+		return nil, errors.New("exactly one of tag and version must be given")
+	}
+
+	node, err := loadStored(ctx, a.dgraphClient)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := node.registry()
+	if err != nil {
+		return nil, err
+	}
+	registered, err := reg.lookup(name, strings.ToLower(tag), version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.UpdateSchema(ctx, registered.Schema, force); err != nil {
+		return nil, err
+	}
+	glog.Infof("Applied version %d of GraphQL schema %s", registered.Version, name)
+	return a.updatePayload(), nil
+}
+
+// updateRegistry updates the registry stored in Dgraph with update, and
+// returns the schema called name as it is afterwards.
+func (a *Admin) updateRegistry(ctx context.Context, name string,
+	update func(reg *registry) error) (interface{}, error) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	reg, err := storeRegistry(ctx, a.dgraphClient, update)
+	if err != nil {
+		return nil, err
+	}
+	return registeredResult(name, reg.Schemas[name]), nil
+}
+
+func registeredResult(name string, reg *registeredSchema) map[string]interface{} {
+	tagsOf := make(map[int][]interface{})
+	tags := make([]interface{}, 0, len(schemaTags))
+	for _, tag := range schemaTags {
+		if version := reg.tagged(tag); version != 0 {
+			tagsOf[version] = append(tagsOf[version], strings.ToUpper(tag))
+			tags = append(tags, map[string]interface{}{
+				"tag":     strings.ToUpper(tag),
+				"version": version,
+			})
+		}
+	}
+
+	versions := make([]interface{}, len(reg.Versions))
+	for i, v := range reg.Versions {
+		vTags := tagsOf[v.Version]
+		if vTags == nil {
+			vTags = []interface{}{}
+		}
+		versions[len(reg.Versions)-1-i] = map[string]interface{}{
+			"version":      v.Version,
+			"schema":       v.Schema,
+			"registeredAt": v.RegisteredAt.Format(time.RFC3339Nano),
+			"tags":         vTags,
+		}
+	}
+	return map[string]interface{}{
+		"name":     name,
+		"versions": versions,
+		"tags":     tags,
+	}
+}
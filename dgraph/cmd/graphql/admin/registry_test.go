@@ -0,0 +1,201 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := &registry{}
+
+	v, err := reg.register("blog", "type A { id: ID! }", "dev")
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+
+	// The same schema again only tags the newest version.
+	v, err = reg.register("blog", "type A { id: ID! }", "staging")
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+	require.Len(t, reg.Schemas["blog"].Versions, 1)
+
+	v, err = reg.register("blog", "type B { id: ID! }", "dev")
+	require.NoError(t, err)
+	require.Equal(t, 2, v)
+
+	v, err = reg.promote("blog", "dev", "")
+	require.NoError(t, err)
+	require.Equal(t, 2, v)
+	require.Equal(t, 2, reg.Schemas["blog"].tagged("staging"))
+
+	_, err = reg.promote("blog", "prod", "")
+	require.EqualError(t, err, "schema blog has no prod version to promote")
+	v, err = reg.promote("blog", "staging", "")
+	require.NoError(t, err)
+	require.Equal(t, 2, reg.Schemas["blog"].tagged("prod"))
+	_, err = reg.promote("blog", "prod", "")
+	require.EqualError(t, err, "there's no tag after prod to promote schema blog to")
+
+	v, err = reg.rollback("blog", "staging")
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+	require.Equal(t, 1, reg.Schemas["blog"].tagged("staging"))
+	_, err = reg.rollback("blog", "staging")
+	require.EqualError(t, err, "schema blog's staging tag has no earlier version to roll back to")
+
+	found, err := reg.lookup("blog", "prod", 0)
+	require.NoError(t, err)
+	require.Equal(t, "type B { id: ID! }", found.Schema)
+	found, err = reg.lookup("blog", "", 1)
+	require.NoError(t, err)
+	require.Equal(t, "type A { id: ID! }", found.Schema)
+	_, err = reg.lookup("blog", "", 3)
+	require.EqualError(t, err, "there's no version 3 of schema blog")
+	_, err = reg.lookup("shop", "dev", 0)
+	require.EqualError(t, err, "there's no schema called shop in the registry")
+
+	_, err = reg.register("my blog", "type A { id: ID! }", "dev")
+	require.Error(t, err)
+}
+
+func TestRegistryPrune(t *testing.T) {
+	reg := &registry{}
+	_, err := reg.register("blog", "type A0 { id: ID! }", "prod")
+	require.NoError(t, err)
+	for i := 1; i <= maxRegisteredVersions+1; i++ {
+		_, err := reg.register("blog", fmt.Sprintf("type A%d { id: ID! }", i), "")
+		require.NoError(t, err)
+	}
+
+	// Version 1 is kept because prod points at it, so versions 2 and 3 go.
+	versions := reg.Schemas["blog"].Versions
+	require.Len(t, versions, maxRegisteredVersions)
+	require.Equal(t, 1, versions[0].Version)
+	require.Equal(t, 4, versions[1].Version)
+	require.Equal(t, maxRegisteredVersions+2, versions[len(versions)-1].Version)
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	dg := &memDgraph{}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+	admin := adm.Resolver()
+
+	register := `mutation($sch: String!, $tag: GQLSchemaTag) {
+		registerGQLSchema(input: { name: "blog", schema: $sch, tag: $tag }) {
+			name
+			versions { version schema tags }
+			tags { tag version }
+		}
+	}`
+	first := `type Author { id: ID! name: String! }`
+	second := `type Author { id: ID! name: String! age: Int }`
+
+	got, _ := resolveToJSON(t, admin, register, map[string]interface{}{"sch": first})
+	require.JSONEq(t, fmt.Sprintf(`{"data": {"registerGQLSchema": {
+		"name": "blog",
+		"versions": [{"version": 1, "schema": %q, "tags": ["DEV"]}],
+		"tags": [{"tag": "DEV", "version": 1}]}}}`, first), got)
+	require.Empty(t, dg.altered, "registering a schema doesn't apply it")
+
+	_, resp := resolveToJSON(t, admin, register, map[string]interface{}{"sch": "type A {"})
+	require.Len(t, resp.Errors, 1)
+
+	promote := `mutation($from: GQLSchemaTag!) {
+		promoteGQLSchema(input: { name: "blog", from: $from }) { tags { tag version } }
+	}`
+	got, _ = resolveToJSON(t, admin, promote, map[string]interface{}{"from": "DEV"})
+	require.JSONEq(t, `{"data": {"promoteGQLSchema": {"tags": [
+		{"tag": "DEV", "version": 1}, {"tag": "STAGING", "version": 1}]}}}`, got)
+
+	_, _ = resolveToJSON(t, admin, register, map[string]interface{}{"sch": second})
+	got, _ = resolveToJSON(t, admin, promote, map[string]interface{}{"from": "DEV"})
+	require.JSONEq(t, `{"data": {"promoteGQLSchema": {"tags": [
+		{"tag": "DEV", "version": 2}, {"tag": "STAGING", "version": 2}]}}}`, got)
+
+	apply := `mutation($tag: GQLSchemaTag, $version: Int) {
+		applyRegisteredGQLSchema(input: { name: "blog", tag: $tag, version: $version }) {
+			gqlSchema { schema }
+		}
+	}`
+	got, _ = resolveToJSON(t, admin, apply, map[string]interface{}{"tag": "STAGING"})
+	require.JSONEq(t, fmt.Sprintf(
+		`{"data": {"applyRegisteredGQLSchema": {"gqlSchema": {"schema": %q}}}}`, second), got)
+	require.Equal(t, second, dg.stored)
+
+	_, resp = resolveToJSON(t, admin, apply,
+		map[string]interface{}{"tag": "STAGING", "version": 1})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "exactly one of tag and version must be given", resp.Errors[0].Message)
+	_, resp = resolveToJSON(t, admin, apply, map[string]interface{}{"tag": "PROD"})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "schema blog has no prod version", resp.Errors[0].Message)
+
+	got, _ = resolveToJSON(t, admin, `mutation {
+		rollbackGQLSchemaTag(input: { name: "blog", tag: STAGING }) { tags { tag version } }
+	}`, nil)
+	require.JSONEq(t, `{"data": {"rollbackGQLSchemaTag": {"tags": [
+		{"tag": "DEV", "version": 2}, {"tag": "STAGING", "version": 1}]}}}`, got)
+
+	// Going back to version 1 removes Author.age, so it has to be forced.
+	_, resp = resolveToJSON(t, admin, apply, map[string]interface{}{"version": 1})
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message, "Field Author.age was removed.")
+	require.Equal(t, second, dg.stored)
+
+	var list struct {
+		Data struct {
+			RegisteredGQLSchemas []struct {
+				Name     string
+				Versions []struct {
+					Version      int
+					RegisteredAt string
+				}
+			}
+		}
+	}
+	got, _ = resolveToJSON(t, admin,
+		`query { registeredGQLSchemas { name versions { version registeredAt } } }`, nil)
+	require.NoError(t, json.Unmarshal([]byte(got), &list))
+	require.Len(t, list.Data.RegisteredGQLSchemas, 1)
+	require.Equal(t, "blog", list.Data.RegisteredGQLSchemas[0].Name)
+	versions := list.Data.RegisteredGQLSchemas[0].Versions
+	require.Len(t, versions, 2)
+	require.Equal(t, 2, versions[0].Version, "the newest version is first")
+	require.NotEmpty(t, versions[0].RegisteredAt)
+
+	got, _ = resolveToJSON(t, admin, `query { registeredGQLSchemas(name: "shop") { name } }`, nil)
+	require.JSONEq(t, `{"data": {"registeredGQLSchemas": []}}`, got)
+
+	// Another server sees the same registry.
+	other, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+	node, err := loadStored(context.Background(), dg)
+	require.NoError(t, err)
+	reg, err := node.registry()
+	require.NoError(t, err)
+	require.Equal(t, 1, reg.Schemas["blog"].tagged("staging"))
+	got, _ = resolveToJSON(t, other.Resolver(),
+		`query { registeredGQLSchemas { name } }`, nil)
+	require.JSONEq(t, `{"data": {"registeredGQLSchemas": [{"name": "blog"}]}}`, got)
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+)
+
+// defaultStatsSample is how many nodes of each type the field statistics are
+// worked out from, unless the typeStats query asks for another number.
+const defaultStatsSample = 1000
+
+// typeStats answers the typeStats query: for each type in the schema being
+// served, how many nodes there are and, from a sample of those nodes, how
+// often each field is set.  Counting every node of a type is cheap, because
+// it's answered from the type index, but counting the values of every field
+// means reading each node, so that's done for a sample.
+func (a *Admin) typeStats(ctx context.Context, field schema.Field) (interface{}, error) {
+	sample := defaultStatsSample
+	if val := field.ArgValue("sample"); val != nil {
+		n, err := strconv.Atoi(fmt.Sprintf("%v", val))
+		if err != nil || n <= 0 {
+			return nil, errors.Errorf("sample must be a positive number, but it's %v", val)
+		}
+		sample = n
+	}
+
+	sch := a.gqlServer.Schema()
+	if sch == nil {
+		return []interface{}{}, nil
+	}
+
+	result := []interface{}{}
+	for _, typ := range sch.StoredTypes() {
+		stats, err := a.statsFor(ctx, typ, sample)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while counting %s", typ.Name())
+		}
+		result = append(result, stats)
+	}
+	return result, nil
+}
+
+// statsFor works out the statistics for typ, examining up to sample nodes to
+// find how often each field is set.
+func (a *Admin) statsFor(ctx context.Context, typ schema.Type,
+	sample int) (map[string]interface{}, error) {
+
+	ofType := &gql.Function{Name: "type", Args: []gql.Arg{{Value: typ.DgraphName()}}}
+
+	resp, err := a.dgraphClient.Query(ctx, &gql.GraphQuery{
+		Attr:          "stats",
+		Func:          ofType,
+		UidCount:      true,
+		UidCountAlias: "count",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var total struct {
+		Stats []struct {
+			Count int `json:"count"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(resp, &total); err != nil {
+		return nil, errors.Wrap(err, "couldn't unmarshal the node count")
+	}
+	count := 0
+	if len(total.Stats) > 0 {
+		count = total.Stats[0].Count
+	}
+
+	// Each sampled node has the number of values of each field; the fields
+	// are aliased by position because predicate names aren't valid aliases.
+	var fields []schema.FieldDefinition
+	sampleQuery := &gql.GraphQuery{
+		Attr:     "sample",
+		Func:     ofType,
+		Args:     map[string]string{"first": strconv.Itoa(sample)},
+		Children: []*gql.GraphQuery{{Attr: "uid"}},
+	}
+	for _, fld := range typ.Fields() {
+		if fld.IsID() {
+			continue
+		}
+		sampleQuery.Children = append(sampleQuery.Children, &gql.GraphQuery{
+			Alias:   fmt.Sprintf("f%d", len(fields)),
+			Attr:    fld.DgraphPredicate(),
+			IsCount: true,
+		})
+		fields = append(fields, fld)
+	}
+
+	resp, err = a.dgraphClient.Query(ctx, sampleQuery)
+	if err != nil {
+		return nil, err
+	}
+	var sampled struct {
+		Sample []map[string]interface{} `json:"sample"`
+	}
+	if err := json.Unmarshal(resp, &sampled); err != nil {
+		return nil, errors.Wrap(err, "couldn't unmarshal the sampled nodes")
+	}
+
+	fieldStats := make([]interface{}, len(fields))
+	for i, fld := range fields {
+		alias := fmt.Sprintf("f%d", i)
+		present, values := 0, 0
+		for _, node := range sampled.Sample {
+			if n, _ := node[alias].(float64); n > 0 {
+				present++
+				values += int(n)
+			}
+		}
+		fieldStats[i] = map[string]interface{}{
+			"field":     fld.Name(),
+			"predicate": fld.DgraphPredicate(),
+			"present":   present,
+			"values":    values,
+		}
+	}
+
+	return map[string]interface{}{
+		"type":    typ.Name(),
+		"count":   count,
+		"sampled": len(sampled.Sample),
+		"fields":  fieldStats,
+	}, nil
+}
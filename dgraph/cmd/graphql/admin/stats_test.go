@@ -0,0 +1,81 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeStats(t *testing.T) {
+	dg := &memDgraph{
+		stored: `type Author { id: ID! name: String! posts: [Post] }
+			type Post { id: ID! title: String! }`,
+		answers: map[string]string{
+			`query {
+  stats(func: type(Author)) {
+    count : count(uid)
+  }
+}`: `{"stats": [{"count": 120}]}`,
+			`query {
+  sample(func: type(Author), first: 2) {
+    uid
+    f0 : count(Author.name)
+    f1 : count(Author.posts)
+  }
+}`: `{"sample": [{"uid": "0x1", "f0": 1, "f1": 3}, {"uid": "0x2", "f0": 1, "f1": 0}]}`,
+			`query {
+  stats(func: type(Post)) {
+    count : count(uid)
+  }
+}`: `{"stats": [{"count": 0}]}`,
+			`query {
+  sample(func: type(Post), first: 2) {
+    uid
+    f0 : count(Post.title)
+  }
+}`: `{"sample": []}`,
+		},
+	}
+	gqlServer := resolve.New(nil, dg)
+	adm, err := New(dg, gqlServer)
+	require.NoError(t, err)
+	adm.SetSchemaCheck(SchemaCheckOff)
+	require.NoError(t, adm.LoadStoredSchema(context.Background()))
+
+	got, _ := resolveToJSON(t, adm.Resolver(), `query {
+		typeStats(sample: 2) {
+			type count sampled
+			fields { field predicate present values }
+		}
+	}`, nil)
+	require.JSONEq(t, `{"data": {"typeStats": [
+		{"type": "Author", "count": 120, "sampled": 2, "fields": [
+			{"field": "name", "predicate": "Author.name", "present": 2, "values": 2},
+			{"field": "posts", "predicate": "Author.posts", "present": 1, "values": 3}
+		]},
+		{"type": "Post", "count": 0, "sampled": 0, "fields": [
+			{"field": "title", "predicate": "Post.title", "present": 0, "values": 0}
+		]}
+	]}}`, got)
+
+	got, _ = resolveToJSON(t, adm.Resolver(), `query { typeStats(sample: 0) { type } }`, nil)
+	require.Contains(t, got, "sample must be a positive number, but it's 0")
+}
@@ -0,0 +1,306 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/pkg/errors"
+)
+
+// The GraphQL schema is stored in Dgraph as the single node of type
+// dgraph.graphql.  The queries registered with the admin API, and the
+// history of schema versions, are stored on the same node as JSON lists,
+// along with the schema registry and the introspection mode, if the admin API
+// has set one.
+const (
+	schemaType             = "dgraph.graphql"
+	schemaPredicate        = "dgraph.graphql.schema"
+	queriesPredicate       = "dgraph.graphql.queries"
+	historyPredicate       = "dgraph.graphql.history"
+	introspectionPredicate = "dgraph.graphql.introspection"
+	registryPredicate      = "dgraph.graphql.registry"
+
+	storageSchema = `
+type dgraph.graphql {
+	dgraph.graphql.schema: string
+	dgraph.graphql.queries: string
+	dgraph.graphql.history: string
+	dgraph.graphql.introspection: string
+	dgraph.graphql.registry: string
+}
+dgraph.graphql.schema: string .
+dgraph.graphql.queries: string .
+dgraph.graphql.history: string .
+dgraph.graphql.introspection: string .
+dgraph.graphql.registry: string .
+`
+
+	// maxSchemaHistory is how many schema versions are kept, including the
+	// one being served.
+	maxSchemaHistory = 20
+)
+
+// storedSchemaQuery finds the node that stores the GraphQL schema.
+func storedSchemaQuery() *gql.GraphQuery {
+	return &gql.GraphQuery{
+		Attr: "schema",
+		Func: &gql.Function{Name: "type", Args: []gql.Arg{{Value: schemaType}}},
+		Children: []*gql.GraphQuery{
+			{Attr: "uid"},
+			{Attr: schemaPredicate},
+			{Attr: queriesPredicate},
+			{Attr: historyPredicate},
+			{Attr: introspectionPredicate},
+			{Attr: registryPredicate},
+		},
+	}
+}
+
+// A storedNode is what's stored on the dgraph.graphql node.
+type storedNode struct {
+	UID     string `json:"uid"`
+	Schema  string `json:"dgraph.graphql.schema"`
+	Queries string `json:"dgraph.graphql.queries"`
+	History string `json:"dgraph.graphql.history"`
+
+	Introspection string `json:"dgraph.graphql.introspection"`
+	Registry      string `json:"dgraph.graphql.registry"`
+}
+
+// A schemaVersion is a schema that was stored, and when.  Versions are
+// numbered from 1, in the order they were stored.
+type schemaVersion struct {
+	Version   int        `json:"version"`
+	Schema    string     `json:"schema"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// registered decodes the registered queries.
+func (n *storedNode) registered() ([]string, error) {
+	if n.Queries == "" {
+		return nil, nil
+	}
+	var queries []string
+	if err := json.Unmarshal([]byte(n.Queries), &queries); err != nil {
+		return nil, errors.Wrap(err, "couldn't unmarshal the registered queries")
+	}
+	return queries, nil
+}
+
+// history decodes the schema versions, oldest first.  A schema that was
+// stored before there was a history is version 1, applied at an unknown time.
+func (n *storedNode) history() ([]*schemaVersion, error) {
+	if n.History == "" {
+		if n.Schema == "" {
+			return nil, nil
+		}
+		return []*schemaVersion{{Version: 1, Schema: n.Schema}}, nil
+	}
+	var history []*schemaVersion
+	if err := json.Unmarshal([]byte(n.History), &history); err != nil {
+		return nil, errors.Wrap(err, "couldn't unmarshal the GraphQL schema history")
+	}
+	return history, nil
+}
+
+// registry decodes the schema registry.
+func (n *storedNode) registry() (*registry, error) {
+	reg := &registry{}
+	if n.Registry == "" {
+		return reg, nil
+	}
+	if err := json.Unmarshal([]byte(n.Registry), reg); err != nil {
+		return nil, errors.Wrap(err, "couldn't unmarshal the GraphQL schema registry")
+	}
+	return reg, nil
+}
+
+// version returns the latest version of sch in the history, or 0 if it isn't
+// there.
+func (n *storedNode) version(sch string) (int, error) {
+	history, err := n.history()
+	if err != nil {
+		return 0, err
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Schema == sch {
+			return history[i].Version, nil
+		}
+	}
+	return 0, nil
+}
+
+// parseStoredNode parses the result of storedSchemaQuery.  If there's no
+// node yet, the result is an empty node.
+func parseStoredNode(resp []byte) (*storedNode, error) {
+	var stored struct {
+		Schema []*storedNode `json:"schema"`
+	}
+	if err := json.Unmarshal(resp, &stored); err != nil {
+		return nil, errors.Wrap(err, "couldn't unmarshal the stored GraphQL schema")
+	}
+	if len(stored.Schema) == 0 {
+		return &storedNode{}, nil
+	}
+	return stored.Schema[0], nil
+}
+
+// loadStored returns what's stored in Dgraph: the GraphQL schema, which is ""
+// if there isn't one, the registered queries and the schema history.
+func loadStored(ctx context.Context, dgraphClient dgraph.Client) (*storedNode, error) {
+	resp, err := dgraphClient.Query(ctx, storedSchemaQuery())
+	if err != nil {
+		return nil, errors.Wrap(err, "while loading the stored GraphQL schema")
+	}
+	return parseStoredNode(resp)
+}
+
+// storeSchema stores sch in Dgraph, replacing any schema already stored
+// there, and adds it to the history as a new version.  Only the latest
+// maxSchemaHistory versions are kept.  It returns sch's version.
+func storeSchema(ctx context.Context, dgraphClient dgraph.Client, sch string) (int, error) {
+	var version int
+	err := updateStored(ctx, dgraphClient, func(node *storedNode) (map[string]string, error) {
+		history, err := node.history()
+		if err != nil {
+			return nil, err
+		}
+
+		version = 1
+		if len(history) > 0 {
+			version = history[len(history)-1].Version + 1
+		}
+		now := time.Now().UTC()
+		history = append(history, &schemaVersion{Version: version, Schema: sch, AppliedAt: &now})
+		if len(history) > maxSchemaHistory {
+			history = history[len(history)-maxSchemaHistory:]
+		}
+
+		js, err := json.Marshal(history)
+		return map[string]string{schemaPredicate: sch, historyPredicate: string(js)}, err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "while storing the GraphQL schema")
+	}
+	return version, nil
+}
+
+// storeQueries updates the registered queries stored in Dgraph with update,
+// which is given the queries that are stored now.  It returns the queries it
+// stored.
+func storeQueries(ctx context.Context, dgraphClient dgraph.Client,
+	update func(queries []string) ([]string, error)) ([]string, error) {
+
+	var updated []string
+	err := updateStored(ctx, dgraphClient, func(node *storedNode) (map[string]string, error) {
+		queries, err := node.registered()
+		if err != nil {
+			return nil, err
+		}
+		if updated, err = update(queries); err != nil {
+			return nil, err
+		}
+		js, err := json.Marshal(updated)
+		return map[string]string{queriesPredicate: string(js)}, err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "while storing the registered queries")
+	}
+	return updated, nil
+}
+
+// storeRegistry updates the schema registry stored in Dgraph with update,
+// which is given the registry as it's stored now.  It returns the registry it
+// stored.
+func storeRegistry(ctx context.Context, dgraphClient dgraph.Client,
+	update func(reg *registry) error) (*registry, error) {
+
+	var reg *registry
+	err := updateStored(ctx, dgraphClient, func(node *storedNode) (map[string]string, error) {
+		var err error
+		if reg, err = node.registry(); err != nil {
+			return nil, err
+		}
+		if err := update(reg); err != nil {
+			return nil, err
+		}
+		js, err := json.Marshal(reg)
+		return map[string]string{registryPredicate: string(js)}, err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "while storing the GraphQL schema registry")
+	}
+	return reg, nil
+}
+
+// storeIntrospection stores the introspection mode in Dgraph.
+func storeIntrospection(ctx context.Context, dgraphClient dgraph.Client, mode string) error {
+	err := updateStored(ctx, dgraphClient, func(node *storedNode) (map[string]string, error) {
+		return map[string]string{introspectionPredicate: mode}, nil
+	})
+	return errors.Wrap(err, "while storing the introspection mode")
+}
+
+// updateStored sets predicates of the dgraph.graphql node, creating the node
+// if there isn't one yet.  update gives the predicates and their values, from
+// what's stored now, in the same transaction.
+func updateStored(ctx context.Context, dgraphClient dgraph.Client,
+	update func(node *storedNode) (map[string]string, error)) error {
+
+	txn := dgraphClient.NewTxn()
+	defer txn.Discard(ctx)
+
+	resp, err := txn.Query(ctx, storedSchemaQuery())
+	if err != nil {
+		return errors.Wrap(err, "while finding the stored GraphQL schema")
+	}
+	node, err := parseStoredNode(resp)
+	if err != nil {
+		return err
+	}
+	uid := node.UID
+	if uid == "" {
+		uid = "_:" + schemaType
+	}
+
+	preds, err := update(node)
+	if err != nil {
+		return err
+	}
+	set := map[string]interface{}{
+		"uid":         uid,
+		"dgraph.type": schemaType,
+	}
+	for pred, val := range preds {
+		set[pred] = val
+	}
+	setJSON, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+
+	if _, err := txn.Mutate(ctx, &api.Mutation{SetJson: setJSON}); err != nil {
+		return err
+	}
+	return txn.Commit(ctx)
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// watchDebounce is how long a SchemaWatcher waits after a change to the
+// schema file before applying it.  Editors often save a file in several
+// steps - truncating it, writing it, or writing a temporary file and
+// renaming it - so the file is only read once it's settled.
+const watchDebounce = 100 * time.Millisecond
+
+// applyTimeout is how long applying a changed schema file can take.
+const applyTimeout = time.Minute
+
+// A SchemaWatcher applies a schema file each time it changes, for a
+// development loop where saving the schema is all it takes to try it out.
+// Every change is applied as if it were forced, because breaking changes are
+// to be expected while a schema is being written; they're still logged.  If
+// a change can't be applied, the schema being served stays as it was, and
+// the error is logged and reported by Status.
+type SchemaWatcher struct {
+	admin *Admin
+	file  string
+
+	mu      sync.Mutex
+	status  SchemaFileStatus
+	applied string
+}
+
+// SchemaFileStatus is how applying a watched schema file went, the last time
+// it changed.
+type SchemaFileStatus struct {
+	File string `json:"file"`
+
+	// OK is false if the file couldn't be read or applied the last time it
+	// changed, with the reason in Error.
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+
+	// CheckedAt is when the file was last read.  AppliedAt is when it was
+	// last applied, and Version is the version in the schema history that
+	// it was applied as; they're unset if it never has been.
+	CheckedAt time.Time  `json:"checkedAt"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+	Version   int        `json:"version,omitempty"`
+}
+
+// WatchSchemaFile returns a SchemaWatcher that applies file with a.  Nothing
+// is applied until the watcher's Apply or Run is called.
+func (a *Admin) WatchSchemaFile(file string) *SchemaWatcher {
+	return &SchemaWatcher{
+		admin:  a,
+		file:   filepath.Clean(file),
+		status: SchemaFileStatus{File: file},
+	}
+}
+
+// Apply reads the schema file and applies it, unless it's what was last
+// applied.  The outcome is logged, and recorded for Status.
+func (w *SchemaWatcher) Apply(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.status.CheckedAt = time.Now()
+	err := w.apply(ctx)
+	if err != nil {
+		glog.Errorf("Couldn't apply GraphQL schema file %s: %v", w.file, err)
+		w.status.OK, w.status.Error = false, err.Error()
+		return err
+	}
+	w.status.OK, w.status.Error = true, ""
+	return nil
+}
+
+// apply does the work of Apply.  w.mu must be held.
+func (w *SchemaWatcher) apply(ctx context.Context) error {
+	input, err := ioutil.ReadFile(w.file)
+	if err != nil {
+		return errors.Wrap(err, "while reading the schema file")
+	}
+	if w.status.AppliedAt != nil && string(input) == w.applied {
+		return nil
+	}
+
+	if err := w.admin.UpdateSchema(ctx, string(input), true); err != nil {
+		return err
+	}
+	now := time.Now()
+	w.applied = string(input)
+	w.status.AppliedAt = &now
+	w.admin.mu.Lock()
+	w.status.Version = w.admin.current.version
+	w.admin.mu.Unlock()
+	glog.Infof("Applied GraphQL schema file %s as version %d", w.file, w.status.Version)
+	return nil
+}
+
+// Status reports how applying the schema file last went.
+func (w *SchemaWatcher) Status() SchemaFileStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Run watches the schema file, and applies it each time it changes, until
+// ctx is done.  The file's directory is what's watched, so that the file is
+// still followed when an editor saves it by replacing it.
+func (w *SchemaWatcher) Run(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "while watching the GraphQL schema file")
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(w.file)); err != nil {
+		return errors.Wrapf(err, "while watching the GraphQL schema file %s", w.file)
+	}
+
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) == w.file &&
+				event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				settled = time.After(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			glog.Errorf("Error watching GraphQL schema file %s: %v", w.file, err)
+		case <-settled:
+			settled = nil
+			applyCtx, cancel := context.WithTimeout(ctx, applyTimeout)
+			_ = w.Apply(applyCtx)
+			cancel()
+		}
+	}
+}
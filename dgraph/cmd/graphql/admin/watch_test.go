@@ -0,0 +1,115 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+func schemaFile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "graphql-watch")
+	require.NoError(t, err)
+	return filepath.Join(dir, "schema.graphql"), func() { os.RemoveAll(dir) }
+}
+
+func TestSchemaWatcherApply(t *testing.T) {
+	file, cleanup := schemaFile(t)
+	defer cleanup()
+
+	dg := &memDgraph{}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+	watcher := adm.WatchSchemaFile(file)
+
+	require.Error(t, watcher.Apply(context.Background()))
+	st := watcher.Status()
+	require.False(t, st.OK)
+	require.Contains(t, st.Error, "while reading the schema file")
+	require.Nil(t, st.AppliedAt)
+
+	first := `type Author { id: ID! name: String! age: Int }`
+	require.NoError(t, ioutil.WriteFile(file, []byte(first), 0644))
+	require.NoError(t, watcher.Apply(context.Background()))
+	st = watcher.Status()
+	require.True(t, st.OK)
+	require.Empty(t, st.Error)
+	require.NotNil(t, st.AppliedAt)
+	require.Equal(t, 1, st.Version)
+	require.Equal(t, first, dg.stored)
+
+	// The same file again isn't applied again.
+	require.NoError(t, watcher.Apply(context.Background()))
+	require.Len(t, dg.altered, 1)
+
+	// A mistake leaves the schema being served as it was.
+	require.NoError(t, ioutil.WriteFile(file, []byte(`type Author {`), 0644))
+	require.Error(t, watcher.Apply(context.Background()))
+	st = watcher.Status()
+	require.False(t, st.OK)
+	require.NotEmpty(t, st.Error)
+	require.Equal(t, 1, st.Version)
+	require.Equal(t, first, dg.stored)
+
+	// Breaking changes are applied.
+	second := `type Author { id: ID! name: String! }`
+	require.NoError(t, ioutil.WriteFile(file, []byte(second), 0644))
+	require.NoError(t, watcher.Apply(context.Background()))
+	st = watcher.Status()
+	require.True(t, st.OK)
+	require.Equal(t, 2, st.Version)
+	require.Equal(t, second, dg.stored)
+}
+
+func TestSchemaWatcherRun(t *testing.T) {
+	file, cleanup := schemaFile(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(file, []byte(`type Author { id: ID! }`), 0644))
+
+	dg := &memDgraph{}
+	adm, err := New(dg, resolve.New(nil, dg))
+	require.NoError(t, err)
+	watcher := adm.WatchSchemaFile(file)
+	require.NoError(t, watcher.Apply(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- watcher.Run(ctx) }()
+
+	// Replace the file, as editors that save to a temporary file do, until
+	// the watcher has started and picks the change up.
+	changed := `type Author { id: ID! name: String }`
+	deadline := time.Now().Add(10 * time.Second)
+	for watcher.Status().Version != 2 {
+		require.True(t, time.Now().Before(deadline), "the change wasn't applied")
+		tmp := file + ".tmp"
+		require.NoError(t, ioutil.WriteFile(tmp, []byte(changed), 0644))
+		require.NoError(t, os.Rename(tmp, file))
+		time.Sleep(3 * watchDebounce)
+	}
+	require.Equal(t, changed, dg.stored)
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package authorization carries what's known about who made a GraphQL
+// request - the claims that authenticating the request established - to the
+// @auth rules that decide what the request can see and change.
+package authorization
+
+import "context"
+
+type contextKey int
+
+const claimsKey contextKey = iota
+
+// WithClaims returns a copy of ctx that carries claims, the verified claims
+// of a request's token.
+func WithClaims(ctx context.Context, claims map[string]interface{}) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// Claims returns the claims carried by ctx, so that @auth rules and custom
+// resolvers can use them.  An unauthenticated request has no claims (nil),
+// so any @auth rule that needs a claim denies it access.
+func Claims(ctx context.Context) map[string]interface{} {
+	claims, _ := ctx.Value(claimsKey).(map[string]interface{})
+	return claims
+}
+
+// HasRole returns true if claim, the value of a claim that holds roles, is
+// one of roles or is a list with one of them in it.
+func HasRole(claim interface{}, roles ...string) bool {
+	switch claim := claim.(type) {
+	case string:
+		for _, role := range roles {
+			if claim == role {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, c := range claim {
+			if s, ok := c.(string); ok && HasRole(s, roles...) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package authorization
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasRole(t *testing.T) {
+	require.True(t, HasRole("ADMIN", "ADMIN"))
+	require.True(t, HasRole("HR", "ADMIN", "HR"))
+	require.True(t, HasRole([]interface{}{"USER", "ADMIN"}, "ADMIN"))
+	require.False(t, HasRole("USER", "ADMIN"))
+	require.False(t, HasRole([]interface{}{"USER", 1}, "ADMIN"))
+	require.False(t, HasRole(nil, "ADMIN"))
+	require.False(t, HasRole("ADMIN"))
+}
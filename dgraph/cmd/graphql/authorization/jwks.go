@@ -0,0 +1,160 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package authorization
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// minJWKSRefresh limits how often the key set is fetched again because a
+// token has a kid that isn't in it, so bad tokens can't make the server
+// hammer the key set's URL.
+const minJWKSRefresh = time.Minute
+
+// jwks is a JSON Web Key Set, fetched from url when it's first needed and
+// again when a key that isn't in it is asked for; that's how rotated keys
+// are found.  The set is fetched without holding mu, so tokens whose keys are
+// known are verified while it's fetched; tokens with unknown keys wait for
+// the one fetch in progress.
+type jwks struct {
+	url    string
+	client *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]*rsa.PublicKey
+	fetched  time.Time
+	fetching chan struct{}
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func newJWKS(url string, client *http.Client) *jwks {
+	return &jwks{url: url, client: client, now: time.Now}
+}
+
+// key returns the key with id kid.
+func (j *jwks) key(kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	if key, ok := j.keys[kid]; ok {
+		j.mu.Unlock()
+		return key, nil
+	}
+	if fetching := j.fetching; fetching != nil {
+		j.mu.Unlock()
+		<-fetching
+		return j.cached(kid)
+	}
+	if !j.fetched.IsZero() && j.now().Sub(j.fetched) < minJWKSRefresh {
+		j.mu.Unlock()
+		return nil, errors.Errorf("no key with kid %q", kid)
+	}
+	j.fetched = j.now()
+	done := make(chan struct{})
+	j.fetching = done
+	j.mu.Unlock()
+
+	keys, err := j.fetch()
+
+	j.mu.Lock()
+	if err == nil {
+		j.keys = keys
+	}
+	j.fetching = nil
+	close(done)
+	j.mu.Unlock()
+
+	if err != nil {
+		glog.Errorf("Couldn't fetch the JWT key set: %v", err)
+		return nil, err
+	}
+	return j.cached(kid)
+}
+
+// cached returns the key with id kid, if it's in the set already fetched.
+func (j *jwks) cached(kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.Errorf("no key with kid %q", kid)
+}
+
+func (j *jwks) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, errors.Wrap(err, "while fetching JWKS")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("while fetching JWKS: %s responded %s", j.url, resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, errors.Wrap(err, "while reading JWKS")
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := k.rsaKey()
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading key %q from JWKS", k.Kid)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k *jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(e)
+	if !exp.IsInt64() || exp.Int64() > 1<<31-1 || exp.Int64() < 3 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package authorization
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// DefaultHeader is the HTTP header that carries the JWT, unless another is
+// configured.
+const DefaultHeader = "Authorization"
+
+// JWTConfig says how the JWTs that authenticate requests are verified.
+// Exactly one of HMACSecret, PublicKey and JWKSURL must be set.
+type JWTConfig struct {
+	// Header is the HTTP header that carries the token, with or without a
+	// "Bearer " prefix.  It defaults to Authorization.
+	Header string
+
+	// Namespace, if set, is the claim that holds the claims for @auth
+	// rules, e.g. "https://example.com/jwt/claims".  Otherwise the token's
+	// top level claims are used.
+	Namespace string
+
+	// Audience and Issuer, if set, must match the token's aud and iss.
+	Audience string
+	Issuer   string
+
+	// HMACSecret verifies HS256, HS384 and HS512 tokens.
+	HMACSecret string
+
+	// PublicKey is a PEM encoded RSA public key that verifies RS256, RS384
+	// and RS512 tokens.
+	PublicKey []byte
+
+	// JWKSURL is where to fetch a JSON Web Key Set of RSA keys that verify
+	// RS256, RS384 and RS512 tokens.  The key is picked by the token's kid.
+	JWKSURL string
+}
+
+// A Verifier checks the JWTs that authenticate requests, and finds the
+// claims in them.  A Verifier is safe for concurrent use.
+type Verifier struct {
+	header    string
+	namespace string
+	audience  string
+	issuer    string
+	keyFunc   jwt.Keyfunc
+}
+
+// NewVerifier returns a Verifier for tokens as described by cfg.
+func NewVerifier(cfg JWTConfig) (*Verifier, error) {
+	v := &Verifier{
+		header:    cfg.Header,
+		namespace: cfg.Namespace,
+		audience:  cfg.Audience,
+		issuer:    cfg.Issuer,
+	}
+	if v.header == "" {
+		v.header = DefaultHeader
+	}
+
+	keys := 0
+	for _, set := range []bool{cfg.HMACSecret != "", len(cfg.PublicKey) > 0, cfg.JWKSURL != ""} {
+		if set {
+			keys++
+		}
+	}
+	if keys != 1 {
+		return nil, errors.New("exactly one of an HMAC secret, an RSA public key or " +
+			"a JWKS URL is needed to verify JWTs")
+	}
+
+	switch {
+	case cfg.HMACSecret != "":
+		secret := []byte(cfg.HMACSecret)
+		v.keyFunc = func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			return secret, nil
+		}
+	case len(cfg.PublicKey) > 0:
+		key, err := jwt.ParseRSAPublicKeyFromPEM(cfg.PublicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "while reading the RSA public key for JWTs")
+		}
+		v.keyFunc = func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, errors.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			return key, nil
+		}
+	default:
+		keySet := newJWKS(cfg.JWKSURL, &http.Client{Timeout: 10 * time.Second})
+		v.keyFunc = func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, errors.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			kid, _ := token.Header["kid"].(string)
+			return keySet.key(kid)
+		}
+	}
+
+	return v, nil
+}
+
+// Header is the name of the HTTP header that carries the token.
+func (v *Verifier) Header() string {
+	return v.header
+}
+
+// Token returns the token from value, the value of the token header, which
+// might have a "Bearer " prefix.
+func Token(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+		return strings.TrimSpace(value[7:])
+	}
+	return value
+}
+
+// Verify checks token - its signature, expiry, audience and issuer - and
+// returns the claims that @auth rules and resolvers see.
+func (v *Verifier) Verify(token string) (map[string]interface{}, error) {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, v.keyFunc); err != nil {
+		return nil, errors.Wrap(err, "invalid JWT")
+	}
+
+	if v.issuer != "" && !claims.VerifyIssuer(v.issuer, true) {
+		return nil, errors.New("invalid JWT: the issuer isn't trusted")
+	}
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return nil, errors.New("invalid JWT: the token isn't for this audience")
+	}
+
+	if v.namespace == "" {
+		return claims, nil
+	}
+	namespaced, ok := claims[v.namespace].(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("invalid JWT: there's no %s claim", v.namespace)
+	}
+	return namespaced, nil
+}
+
+// hasAudience returns true if aud, which can be a string or a list of
+// strings, includes audience.
+func hasAudience(aud interface{}, audience string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == audience
+	case []interface{}:
+		for _, s := range a {
+			if s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
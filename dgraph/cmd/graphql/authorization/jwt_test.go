@@ -0,0 +1,225 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package authorization
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string,
+	claims jwt.MapClaims) string {
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestToken(t *testing.T) {
+	require.Equal(t, "abc", Token("Bearer abc"))
+	require.Equal(t, "abc", Token("bearer  abc "))
+	require.Equal(t, "abc", Token("abc"))
+	require.Equal(t, "", Token(""))
+}
+
+func TestNewVerifier(t *testing.T) {
+	_, err := NewVerifier(JWTConfig{})
+	require.EqualError(t, err, "exactly one of an HMAC secret, an RSA public key or "+
+		"a JWKS URL is needed to verify JWTs")
+
+	_, err = NewVerifier(JWTConfig{HMACSecret: "s", JWKSURL: "http://keys"})
+	require.Error(t, err)
+
+	_, err = NewVerifier(JWTConfig{PublicKey: []byte("not a key")})
+	require.Error(t, err)
+
+	v, err := NewVerifier(JWTConfig{HMACSecret: "s"})
+	require.NoError(t, err)
+	require.Equal(t, "Authorization", v.Header())
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	v, err := NewVerifier(JWTConfig{
+		HMACSecret: "secret",
+		Namespace:  "https://example.com/claims",
+		Audience:   "graphql",
+		Issuer:     "https://issuer",
+	})
+	require.NoError(t, err)
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"aud":                        []interface{}{"other", "graphql"},
+			"iss":                        "https://issuer",
+			"exp":                        time.Now().Add(time.Hour).Unix(),
+			"https://example.com/claims": map[string]interface{}{"USER": "alice"},
+		}
+	}
+
+	claims, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", valid()))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"USER": "alice"}, claims)
+
+	tests := map[string]struct {
+		change func(jwt.MapClaims)
+		key    string
+		err    string
+	}{
+		"wrong secret": {
+			key: "guess",
+			err: "invalid JWT: signature is invalid",
+		},
+		"expired": {
+			change: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+			err:    "invalid JWT: Token is expired",
+		},
+		"wrong audience": {
+			change: func(c jwt.MapClaims) { c["aud"] = "other" },
+			err:    "invalid JWT: the token isn't for this audience",
+		},
+		"wrong issuer": {
+			change: func(c jwt.MapClaims) { c["iss"] = "https://elsewhere" },
+			err:    "invalid JWT: the issuer isn't trusted",
+		},
+		"no namespace": {
+			change: func(c jwt.MapClaims) { delete(c, "https://example.com/claims") },
+			err:    "invalid JWT: there's no https://example.com/claims claim",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims := valid()
+			if test.change != nil {
+				test.change(claims)
+			}
+			key := "secret"
+			if test.key != "" {
+				key = test.key
+			}
+			_, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte(key), "", claims))
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestVerifyRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	v, err := NewVerifier(JWTConfig{PublicKey: pemKey})
+	require.NoError(t, err)
+
+	claims, err := v.Verify(sign(t, jwt.SigningMethodRS256, key, "", jwt.MapClaims{"USER": "bob"}))
+	require.NoError(t, err)
+	require.Equal(t, "bob", claims["USER"])
+
+	// A token signed with HMAC, using the public key as the secret, mustn't
+	// pass for one signed with the private key.
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, pemKey, "", jwt.MapClaims{"USER": "eve"}))
+	require.EqualError(t, err, "invalid JWT: unexpected signing method HS256")
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		e := big.NewInt(int64(key.PublicKey.E)).Bytes()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(e),
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	v, err := NewVerifier(JWTConfig{JWKSURL: srv.URL})
+	require.NoError(t, err)
+
+	claims, err := v.Verify(sign(t, jwt.SigningMethodRS256, key, "key-1",
+		jwt.MapClaims{"USER": "carol"}))
+	require.NoError(t, err)
+	require.Equal(t, "carol", claims["USER"])
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, key, "key-1", jwt.MapClaims{}))
+	require.NoError(t, err)
+	require.Equal(t, 1, fetches, "the key set is cached")
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, key, "key-2", jwt.MapClaims{}))
+	require.EqualError(t, err, `invalid JWT: no key with kid "key-2"`)
+	require.Equal(t, 1, fetches, "unknown keys don't refetch the key set straight away")
+}
+
+func TestJWKSFetchDoesntBlockKnownKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{}})
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	set := newJWKS(srv.URL, srv.Client())
+	set.keys = map[string]*rsa.PublicKey{"key-1": &key.PublicKey}
+
+	fetched := make(chan error)
+	go func() {
+		_, err := set.key("key-2")
+		fetched <- err
+	}()
+	for fetching := false; !fetching; time.Sleep(time.Millisecond) {
+		set.mu.Lock()
+		fetching = set.fetching != nil
+		set.mu.Unlock()
+	}
+
+	// key-1 is found while the set is being fetched for key-2.
+	got, err := set.key("key-1")
+	require.NoError(t, err)
+	require.Equal(t, &key.PublicKey, got)
+
+	release <- struct{}{}
+	require.EqualError(t, <-fetched, `no key with kid "key-2"`)
+}
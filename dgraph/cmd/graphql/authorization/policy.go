@@ -0,0 +1,123 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package authorization
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// The kinds of GraphQL operation that an AnonymousPolicy allows.
+const (
+	Query        = "query"
+	Mutation     = "mutation"
+	Subscription = "subscription"
+)
+
+// An AnonymousPolicy says which queries, mutations and subscriptions can be
+// run by requests that aren't authenticated.  It allows kinds of operation,
+// and then allows or denies queries, mutations and subscriptions by name, so
+// that, say, queryPost is public but addPost isn't.  A nil policy allows
+// anything.
+type AnonymousPolicy struct {
+	kinds map[string]bool
+
+	// operations says whether the queries, mutations and subscriptions it
+	// names are allowed, whatever their kind.
+	operations map[string]bool
+}
+
+// NewAnonymousPolicy returns the policy that allows anonymous requests to
+// run the given kinds of operation.
+func NewAnonymousPolicy(kinds ...string) (*AnonymousPolicy, error) {
+	p := &AnonymousPolicy{kinds: make(map[string]bool), operations: make(map[string]bool)}
+	for _, kind := range kinds {
+		switch kind {
+		case Query, Mutation, Subscription:
+			p.kinds[kind] = true
+		default:
+			return nil, errors.Errorf("%q isn't a kind of operation; expected query, "+
+				"mutation or subscription", kind)
+		}
+	}
+	return p, nil
+}
+
+// Allow makes p allow the queries, mutations and subscriptions called names,
+// even if it doesn't allow their kind.  It returns p, so calls can be chained.
+func (p *AnonymousPolicy) Allow(names ...string) *AnonymousPolicy {
+	for _, name := range names {
+		p.operations[name] = true
+	}
+	return p
+}
+
+// Deny makes p refuse the queries, mutations and subscriptions called names,
+// even if it allows their kind.  It returns p, so calls can be chained.
+func (p *AnonymousPolicy) Deny(names ...string) *AnonymousPolicy {
+	for _, name := range names {
+		p.operations[name] = false
+	}
+	return p
+}
+
+// Allows returns true if the request with context ctx can run the query,
+// mutation or subscription called name, which is of kind.
+func (p *AnonymousPolicy) Allows(ctx context.Context, kind, name string) bool {
+	if p == nil || Claims(ctx) != nil {
+		return true
+	}
+	if allowed, ok := p.operations[name]; ok {
+		return allowed
+	}
+	return p.kinds[kind]
+}
+
+// Denies returns true if p refuses the query, mutation or subscription
+// called name by name, rather than by its kind.
+func (p *AnonymousPolicy) Denies(name string) bool {
+	if p == nil {
+		return false
+	}
+	allowed, ok := p.operations[name]
+	return ok && !allowed
+}
+
+// Restricts returns true if there's anything that p doesn't allow.
+func (p *AnonymousPolicy) Restricts() bool {
+	if p == nil {
+		return false
+	}
+	for _, allowed := range p.operations {
+		if !allowed {
+			return true
+		}
+	}
+	return len(p.kinds) < 3
+}
+
+// Kinds lists the kinds of operation that p allows, in order.
+func (p *AnonymousPolicy) Kinds() []string {
+	kinds := make([]string, 0, len(p.kinds))
+	for kind := range p.kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
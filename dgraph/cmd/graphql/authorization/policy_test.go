@@ -0,0 +1,57 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package authorization
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousPolicy(t *testing.T) {
+	anonymous := context.Background()
+	user := WithClaims(anonymous, map[string]interface{}{"sub": "u1"})
+
+	p, err := NewAnonymousPolicy(Query)
+	require.NoError(t, err)
+	p.Allow("addComment").Deny("queryAuditLog")
+
+	require.True(t, p.Allows(anonymous, Query, "queryPost"))
+	require.False(t, p.Allows(anonymous, Query, "queryAuditLog"))
+	require.True(t, p.Allows(user, Query, "queryAuditLog"))
+	require.False(t, p.Allows(anonymous, Mutation, "addPost"))
+	require.True(t, p.Allows(anonymous, Mutation, "addComment"))
+
+	require.True(t, p.Denies("queryAuditLog"))
+	require.False(t, p.Denies("addPost"), "addPost is refused by its kind")
+	require.True(t, p.Restricts())
+	require.Equal(t, []string{Query}, p.Kinds())
+
+	all, err := NewAnonymousPolicy(Query, Mutation, Subscription)
+	require.NoError(t, err)
+	require.False(t, all.Restricts())
+	require.True(t, all.Deny("addPost").Restricts())
+
+	var none *AnonymousPolicy
+	require.True(t, none.Allows(anonymous, Mutation, "addPost"))
+	require.False(t, none.Restricts())
+
+	_, err = NewAnonymousPolicy("delete")
+	require.EqualError(t, err,
+		`"delete" isn't a kind of operation; expected query, mutation or subscription`)
+}
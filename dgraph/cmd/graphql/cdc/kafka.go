@@ -0,0 +1,105 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cdc publishes the changes that GraphQL mutations make to systems
+// outside Dgraph, e.g. Kafka, so that they can follow the data as it
+// changes.
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/pkg/errors"
+)
+
+// DefaultTopicPrefix is put in front of the name of the type to make the
+// Kafka topic its changes are published to.
+const DefaultTopicPrefix = "graphql."
+
+// A KafkaSink publishes changes to Kafka.  Each type's changes go to their
+// own topic, and are keyed by the uid of the changed object, so the changes
+// to an object are kept in order.
+type KafkaSink struct {
+	producer sarama.SyncProducer
+	prefix   string
+}
+
+// NewKafkaSink connects to the Kafka cluster brokers, and returns a sink that
+// publishes the changes to type T to the topic prefix + T.
+func NewKafkaSink(brokers []string, prefix string) (*KafkaSink, error) {
+	conf := sarama.NewConfig()
+	conf.Producer.Return.Successes = true
+	conf.Producer.RequiredAcks = sarama.WaitForAll
+	producer, err := sarama.NewSyncProducer(brokers, conf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't connect to Kafka at %s",
+			strings.Join(brokers, ","))
+	}
+	return newKafkaSink(producer, prefix), nil
+}
+
+func newKafkaSink(producer sarama.SyncProducer, prefix string) *KafkaSink {
+	if prefix == "" {
+		prefix = DefaultTopicPrefix
+	}
+	return &KafkaSink{producer: producer, prefix: prefix}
+}
+
+// Publish implements resolve.ChangeSink.  The changes are sent together, and
+// Publish returns once Kafka has them all, or once ctx is done.  A producer
+// can't be stopped mid-send, so the changes of a send that ctx cut short
+// might still reach Kafka; since they're retried, they can be published more
+// than once.
+func (ks *KafkaSink) Publish(ctx context.Context, changes []*resolve.Change) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	msgs := make([]*sarama.ProducerMessage, 0, len(changes))
+	for _, change := range changes {
+		value, err := json.Marshal(change)
+		if err != nil {
+			return errors.Wrap(err, "couldn't marshal change to JSON")
+		}
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic: ks.Topic(change.Type),
+			Key:   sarama.StringEncoder(change.UID),
+			Value: sarama.ByteEncoder(value),
+		})
+	}
+
+	sent := make(chan error, 1)
+	go func() { sent <- ks.producer.SendMessages(msgs) }()
+	select {
+	case err := <-sent:
+		return errors.Wrap(err, "couldn't publish changes to Kafka")
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "couldn't publish changes to Kafka")
+	}
+}
+
+// Topic returns the topic that the changes to type typ are published to.
+func (ks *KafkaSink) Topic(typ string) string {
+	return ks.prefix + typ
+}
+
+// Close closes ks's connections to Kafka.
+func (ks *KafkaSink) Close() error {
+	return ks.producer.Close()
+}
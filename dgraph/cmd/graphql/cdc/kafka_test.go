@@ -0,0 +1,136 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProducer struct {
+	sent   []*sarama.ProducerMessage
+	err    error
+	closed bool
+
+	// block, if it's not nil, holds up sends until it's closed.
+	block chan struct{}
+}
+
+func (fp *fakeProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	return 0, 0, fp.SendMessages([]*sarama.ProducerMessage{msg})
+}
+
+func (fp *fakeProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	if fp.block != nil {
+		<-fp.block
+	}
+	if fp.err != nil {
+		return fp.err
+	}
+	fp.sent = append(fp.sent, msgs...)
+	return nil
+}
+
+func (fp *fakeProducer) Close() error {
+	fp.closed = true
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	producer := &fakeProducer{}
+	sink := newKafkaSink(producer, "")
+	require.Equal(t, "graphql.Post", sink.Topic("Post"))
+
+	changes := []*resolve.Change{
+		{
+			Timestamp: time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC),
+			Type:      "Post",
+			Operation: "update",
+			Mutation:  "updatePost",
+			UID:       "0x1",
+			Before:    map[string]interface{}{"postID": "0x1", "title": "old"},
+			After:     map[string]interface{}{"postID": "0x1", "title": "new"},
+		},
+		{
+			Timestamp: time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC),
+			Type:      "Post",
+			Operation: "update",
+			Mutation:  "updatePost",
+			UID:       "0x2",
+		},
+	}
+	require.NoError(t, sink.Publish(context.Background(), changes))
+	require.Len(t, producer.sent, 2)
+
+	msg := producer.sent[0]
+	require.Equal(t, "graphql.Post", msg.Topic)
+	key, err := msg.Key.Encode()
+	require.NoError(t, err)
+	require.Equal(t, "0x1", string(key))
+	value, err := msg.Value.Encode()
+	require.NoError(t, err)
+	var change resolve.Change
+	require.NoError(t, json.Unmarshal(value, &change))
+	require.Equal(t, *changes[0], change)
+
+	key, err = producer.sent[1].Key.Encode()
+	require.NoError(t, err)
+	require.Equal(t, "0x2", string(key))
+
+	require.NoError(t, sink.Close())
+	require.True(t, producer.closed)
+}
+
+func TestKafkaSinkPrefix(t *testing.T) {
+	producer := &fakeProducer{}
+	sink := newKafkaSink(producer, "blog.")
+	require.NoError(t, sink.Publish(context.Background(),
+		[]*resolve.Change{{Type: "Author", UID: "0x3"}}))
+	require.Len(t, producer.sent, 1)
+	require.Equal(t, "blog.Author", producer.sent[0].Topic)
+}
+
+func TestKafkaSinkError(t *testing.T) {
+	producer := &fakeProducer{err: errors.New("leader not available")}
+	sink := newKafkaSink(producer, "")
+	err := sink.Publish(context.Background(), []*resolve.Change{{Type: "Post", UID: "0x1"}})
+	require.EqualError(t, err, "couldn't publish changes to Kafka: leader not available")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, context.Canceled,
+		newKafkaSink(&fakeProducer{}, "").Publish(ctx, nil))
+}
+
+func TestKafkaSinkTimeout(t *testing.T) {
+	producer := &fakeProducer{block: make(chan struct{})}
+	defer close(producer.block)
+	sink := newKafkaSink(producer, "")
+
+	// A send that Kafka doesn't answer is given up on when ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := sink.Publish(ctx, []*resolve.Change{{Type: "Post", UID: "0x1"}})
+	require.EqualError(t, err, "couldn't publish changes to Kafka: context deadline exceeded")
+}
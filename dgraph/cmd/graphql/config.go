@@ -0,0 +1,1709 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/admin"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/cdc"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/signing"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/web"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Config is every setting of the GraphQL layer.  Settings come from flags,
+// then DGRAPH_GRAPHQL_* environment variables, then the config file given
+// with --config, which can be YAML, JSON, TOML or any other format viper
+// reads.  In a config file, settings that have a flag are keyed by the flag
+// name; the rest are grouped into sections, e.g.
+//
+//	schema: /data/schema.graphql
+//	remote:
+//	  - payments=http://payments:8080/graphql
+//	lambda:
+//	  url: http://lambda:8686/graphql-worker
+//	  signer: lambda
+//	signers:
+//	  lambda:
+//	    type: hmac
+//	    secret: "{{secrets.LAMBDA_KEY}}"
+//	jwt:
+//	  jwks_url: https://example.auth0.com/.well-known/jwks.json
+//	  anonymous: [query]
+//	  anonymous_deny: [queryAuditLog]
+//	retries:
+//	  writes:
+//	    max_attempts: 5
+//	cors:
+//	  allowed_origins:
+//	    - https://app.example.com
+//	secrets:
+//	  providers: [vault, env]
+//	  vault:
+//	    addr: https://vault.example.com:8200
+//	    path: secret/data/graphql
+//
+// A setting in a section is overridden in the environment by joining the
+// names with "_", e.g. DGRAPH_GRAPHQL_LAMBDA_URL.
+type Config struct {
+	Alpha                  string              `json:"alpha"`
+	Port                   int                 `json:"port"`
+	TLSDir                 string              `json:"tls_dir"`
+	TLSClientAuth          string              `json:"tls_client_auth"`
+	Schema                 string              `json:"schema"`
+	SchemaFile             string              `json:"graphql_schema_file"`
+	SchemaPollInterval     time.Duration       `json:"schema_poll_interval"`
+	SchemaCheck            string              `json:"schema_check"`
+	SchemaWebhook          string              `json:"schema_webhook"`
+	Remotes                []schema.RemoteAPI  `json:"remote"`
+	UI                     bool                `json:"ui"`
+	UIAssets               string              `json:"ui_assets"`
+	Allowlist              bool                `json:"allowlist"`
+	AllowlistIntrospection bool                `json:"allowlist_introspection"`
+	Trace                  float64             `json:"trace"`
+	Explain                bool                `json:"explain"`
+	Lambda                 LambdaConfig        `json:"lambda"`
+	Subscriptions          SubscriptionConfig  `json:"subscriptions"`
+	Batch                  BatchConfig         `json:"batch"`
+	Limits                 LimitsConfig        `json:"limits"`
+	Retries                RetriesConfig       `json:"retries"`
+	Reads                  ReadsConfig         `json:"reads"`
+	Timeouts               TimeoutsConfig      `json:"timeouts"`
+	CORS                   CORSConfig          `json:"cors"`
+	Compression            CompressionConfig   `json:"compression"`
+	Uploads                UploadsConfig       `json:"uploads"`
+	RequestLog             RequestLogConfig    `json:"request_log"`
+	Events                 RetryConfig         `json:"events"`
+	Audit                  AuditConfig         `json:"audit"`
+	CDC                    CDCConfig           `json:"cdc"`
+	RateLimit              RateLimitConfig     `json:"rate_limit"`
+	Namespaces             NamespacesConfig    `json:"namespaces"`
+	Introspection          IntrospectionConfig `json:"introspection"`
+	JWT                    JWTConfig           `json:"jwt"`
+	Secrets                SecretsConfig       `json:"secrets"`
+	Signers                SignersConfig       `json:"signers"`
+	Calls                  CallsConfig         `json:"calls"`
+}
+
+// MarshalJSON writes cfg with durations as strings like "30s", the same as
+// they're written in flags and config files.  The JWT secret, the Vault token
+// and the signers' secrets aren't written.
+func (cfg *Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	type subscriptions struct {
+		PollInterval string `json:"poll_interval"`
+	}
+	jwt := cfg.JWT
+	if jwt.HMACSecret != "" {
+		jwt.HMACSecret = "<redacted>"
+	}
+	sc := cfg.Secrets
+	if sc.Vault.Token != "" {
+		sc.Vault.Token = "<redacted>"
+	}
+	return json.Marshal(struct {
+		*plain
+		SchemaPollInterval string        `json:"schema_poll_interval"`
+		Subscriptions      subscriptions `json:"subscriptions"`
+		JWT                JWTConfig     `json:"jwt"`
+		Secrets            SecretsConfig `json:"secrets"`
+		Signers            SignersConfig `json:"signers"`
+	}{
+		plain:              (*plain)(cfg),
+		SchemaPollInterval: cfg.SchemaPollInterval.String(),
+		Subscriptions:      subscriptions{cfg.Subscriptions.PollInterval.String()},
+		JWT:                jwt,
+		Secrets:            sc,
+		Signers:            cfg.Signers.redacted(),
+	})
+}
+
+// LambdaConfig configures the server that runs @lambda resolvers.
+type LambdaConfig struct {
+	// URL is where lambda resolvers are called; "" if there isn't a lambda
+	// server.
+	URL string `json:"url"`
+
+	// Signer names the signer, in signers, that calls to the lambda server
+	// are signed with; "" if they aren't signed.
+	Signer string `json:"signer"`
+}
+
+// SubscriptionConfig configures GraphQL subscriptions.
+type SubscriptionConfig struct {
+	// PollInterval is how often subscriptions look for changes that weren't
+	// made through this server.
+	PollInterval time.Duration `json:"poll_interval"`
+}
+
+// BatchConfig configures how batches, requests sent together in one HTTP
+// request, are resolved.
+type BatchConfig struct {
+	// Concurrent resolves the consecutive queries in a batch concurrently.
+	// Mutations are always resolved one at a time, in order.
+	Concurrent bool `json:"concurrent"`
+}
+
+// LimitsConfig bounds how much work an operation can ask for.  Operations
+// over a limit are refused; 0 is no limit.
+type LimitsConfig struct {
+	// MaxDepth is how deeply selection sets can be nested.
+	MaxDepth int `json:"max_depth"`
+
+	// MaxFields is how many fields an operation can ask for.
+	MaxFields int `json:"max_fields"`
+
+	// MaxComplexity is an operation's largest estimated cost, with the fields
+	// asked for on list items counted once per item (the list's first
+	// argument, or 10).
+	MaxComplexity int `json:"max_complexity"`
+
+	// MaxCost is an operation's largest estimated cost, with the fields asked
+	// for on list items counted once for each node there is of the list's
+	// type, as Dgraph last counted them.
+	MaxCost int `json:"max_cost"`
+
+	// ReportCost adds each operation's estimated cost to its response's
+	// extensions.
+	ReportCost bool `json:"report_cost"`
+}
+
+// RetriesConfig configures how reads and writes are retried when Dgraph fails
+// in a way that's likely to pass: a transaction that was aborted by a
+// conflict, or a call that couldn't reach Dgraph.
+type RetriesConfig struct {
+	// Reads is how the queries of GraphQL queries and subscriptions are
+	// retried.
+	Reads RetryConfig `json:"reads"`
+
+	// Writes is how the transactions of mutations are retried.
+	Writes RetryConfig `json:"writes"`
+}
+
+// RetryConfig is how one kind of operation is retried: it's tried up to
+// MaxAttempts times in all, waiting InitialBackoff before the first retry,
+// and twice as long before each one after that, up to MaxBackoff.
+type RetryConfig struct {
+	MaxAttempts    int           `json:"max_attempts"`
+	InitialBackoff time.Duration `json:"initial_backoff"`
+	MaxBackoff     time.Duration `json:"max_backoff"`
+}
+
+// MarshalJSON writes rc with durations as strings like "50ms".
+func (rc RetryConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		MaxAttempts    int    `json:"max_attempts"`
+		InitialBackoff string `json:"initial_backoff"`
+		MaxBackoff     string `json:"max_backoff"`
+	}{rc.MaxAttempts, rc.InitialBackoff.String(), rc.MaxBackoff.String()})
+}
+
+// retryConfig reads the RetryConfig in section key of conf, with the
+// settings that aren't there from def.
+func retryConfig(conf *viper.Viper, key string, def resolve.RetryPolicy) RetryConfig {
+	rc := RetryConfig{
+		MaxAttempts:    def.MaxAttempts,
+		InitialBackoff: def.InitialBackoff,
+		MaxBackoff:     def.MaxBackoff,
+	}
+	if conf.IsSet(key + ".max_attempts") {
+		rc.MaxAttempts = conf.GetInt(key + ".max_attempts")
+	}
+	if conf.IsSet(key + ".initial_backoff") {
+		rc.InitialBackoff = conf.GetDuration(key + ".initial_backoff")
+	}
+	if conf.IsSet(key + ".max_backoff") {
+		rc.MaxBackoff = conf.GetDuration(key + ".max_backoff")
+	}
+	return rc
+}
+
+func (rc RetryConfig) policy() resolve.RetryPolicy {
+	return resolve.RetryPolicy{
+		MaxAttempts:    rc.MaxAttempts,
+		InitialBackoff: rc.InitialBackoff,
+		MaxBackoff:     rc.MaxBackoff,
+	}
+}
+
+// validate returns a description of each problem with rc, the retries of
+// section key.
+func (rc RetryConfig) validate(key string) []string {
+	var problems []string
+	if rc.MaxAttempts < 1 {
+		problems = append(problems, key+".max_attempts: must be at least 1")
+	}
+	if rc.InitialBackoff < 0 {
+		problems = append(problems, key+".initial_backoff: can't be negative")
+	}
+	if rc.MaxBackoff < 0 {
+		problems = append(problems, key+".max_backoff: can't be negative")
+	}
+	return problems
+}
+
+// CallsConfig configures the calls made to other services: @custom and
+// @lambda resolvers, remote APIs, event endpoints and webhooks.  A call is
+// made as the policy of the host it's to says, if Hosts has one, or else as
+// Default says.  A @custom directive's policy argument overrides both.
+type CallsConfig struct {
+	Default CallPolicyConfig `json:"default"`
+
+	// Hosts are the policies of particular hosts.  A host's policy has
+	// Default's settings for those it doesn't set.
+	Hosts []HostCallPolicyConfig `json:"hosts"`
+}
+
+// CallPolicyConfig is how calls are made: each attempt times out after
+// Timeout, and a failed idempotent call is retried up to MaxRetries times,
+// waiting Backoff before the first retry, and twice as long before each one
+// after that, up to MaxBackoff.  After BreakerThreshold consecutive failures,
+// calls to the endpoint fail fast for BreakerCooldown; 0 is no breaker.
+type CallPolicyConfig struct {
+	Timeout          time.Duration `json:"timeout"`
+	MaxRetries       int           `json:"max_retries"`
+	Backoff          time.Duration `json:"backoff"`
+	MaxBackoff       time.Duration `json:"max_backoff"`
+	BreakerThreshold int           `json:"breaker_threshold"`
+	BreakerCooldown  time.Duration `json:"breaker_cooldown"`
+}
+
+// HostCallPolicyConfig is the policy of calls to Host, a host[:port].
+type HostCallPolicyConfig struct {
+	Host string `json:"host"`
+	CallPolicyConfig
+}
+
+type callPolicyJSON struct {
+	Timeout          string `json:"timeout"`
+	MaxRetries       int    `json:"max_retries"`
+	Backoff          string `json:"backoff"`
+	MaxBackoff       string `json:"max_backoff"`
+	BreakerThreshold int    `json:"breaker_threshold"`
+	BreakerCooldown  string `json:"breaker_cooldown"`
+}
+
+func (cp CallPolicyConfig) json() callPolicyJSON {
+	return callPolicyJSON{
+		Timeout:          cp.Timeout.String(),
+		MaxRetries:       cp.MaxRetries,
+		Backoff:          cp.Backoff.String(),
+		MaxBackoff:       cp.MaxBackoff.String(),
+		BreakerThreshold: cp.BreakerThreshold,
+		BreakerCooldown:  cp.BreakerCooldown.String(),
+	}
+}
+
+// MarshalJSON writes cp with durations as strings like "10s".
+func (cp CallPolicyConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cp.json())
+}
+
+// MarshalJSON writes hc with durations as strings like "10s".
+func (hc HostCallPolicyConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Host string `json:"host"`
+		callPolicyJSON
+	}{hc.Host, hc.CallPolicyConfig.json()})
+}
+
+// loadCalls reads the calls section of conf.  The hosts are a list, rather
+// than a map by host, because config keys can't have dots in them.
+func loadCalls(conf *viper.Viper) CallsConfig {
+	def := callPolicyConfig(conf, "calls.default.", CallPolicyConfig{
+		Timeout:          external.DefaultPolicy.Timeout,
+		MaxRetries:       external.DefaultPolicy.MaxRetries,
+		Backoff:          external.DefaultPolicy.Backoff,
+		MaxBackoff:       external.DefaultPolicy.MaxBackoff,
+		BreakerThreshold: external.DefaultPolicy.BreakerThreshold,
+		BreakerCooldown:  external.DefaultPolicy.BreakerCooldown,
+	})
+	cc := CallsConfig{Default: def}
+	for _, entry := range cast.ToSlice(conf.Get("calls.hosts")) {
+		host := viper.New()
+		for k, v := range cast.ToStringMap(entry) {
+			host.Set(k, v)
+		}
+		cc.Hosts = append(cc.Hosts, HostCallPolicyConfig{
+			Host:             strings.ToLower(host.GetString("host")),
+			CallPolicyConfig: callPolicyConfig(host, "", def),
+		})
+	}
+	return cc
+}
+
+// callPolicyConfig reads the CallPolicyConfig whose settings are named with
+// prefix in conf, with the settings that aren't there from def.
+func callPolicyConfig(conf *viper.Viper, prefix string, def CallPolicyConfig) CallPolicyConfig {
+	cp := def
+	if conf.IsSet(prefix + "timeout") {
+		cp.Timeout = conf.GetDuration(prefix + "timeout")
+	}
+	if conf.IsSet(prefix + "max_retries") {
+		cp.MaxRetries = conf.GetInt(prefix + "max_retries")
+	}
+	if conf.IsSet(prefix + "backoff") {
+		cp.Backoff = conf.GetDuration(prefix + "backoff")
+	}
+	if conf.IsSet(prefix + "max_backoff") {
+		cp.MaxBackoff = conf.GetDuration(prefix + "max_backoff")
+	}
+	if conf.IsSet(prefix + "breaker_threshold") {
+		cp.BreakerThreshold = conf.GetInt(prefix + "breaker_threshold")
+	}
+	if conf.IsSet(prefix + "breaker_cooldown") {
+		cp.BreakerCooldown = conf.GetDuration(prefix + "breaker_cooldown")
+	}
+	return cp
+}
+
+func (cp CallPolicyConfig) policy() external.Policy {
+	return external.Policy{
+		Timeout:          cp.Timeout,
+		MaxRetries:       cp.MaxRetries,
+		Backoff:          cp.Backoff,
+		MaxBackoff:       cp.MaxBackoff,
+		BreakerThreshold: cp.BreakerThreshold,
+		BreakerCooldown:  cp.BreakerCooldown,
+	}
+}
+
+// policies returns the policies that calls are made with.
+func (cc CallsConfig) policies() external.Policies {
+	ps := external.Policies{Default: cc.Default.policy()}
+	if len(cc.Hosts) > 0 {
+		ps.Hosts = make(map[string]external.Policy, len(cc.Hosts))
+		for _, hc := range cc.Hosts {
+			ps.Hosts[hc.Host] = hc.policy()
+		}
+	}
+	return ps
+}
+
+// validate returns a description of each problem with the policy of section
+// key.
+func (cp CallPolicyConfig) validate(key string) []string {
+	var problems []string
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"timeout", cp.Timeout},
+		{"backoff", cp.Backoff},
+		{"max_backoff", cp.MaxBackoff},
+		{"breaker_cooldown", cp.BreakerCooldown},
+	} {
+		if d.value < 0 {
+			problems = append(problems, key+"."+d.name+": can't be negative")
+		}
+	}
+	if cp.MaxRetries < 0 {
+		problems = append(problems, key+".max_retries: can't be negative")
+	}
+	if cp.BreakerThreshold < 0 {
+		problems = append(problems, key+".breaker_threshold: can't be negative")
+	}
+	return problems
+}
+
+// validate returns a description of each problem with cc.
+func (cc CallsConfig) validate() []string {
+	problems := cc.Default.validate("calls.default")
+	seen := make(map[string]bool, len(cc.Hosts))
+	for i, hc := range cc.Hosts {
+		key := fmt.Sprintf("calls.hosts[%d]", i)
+		switch {
+		case hc.Host == "":
+			problems = append(problems, key+".host: can't be empty")
+		case strings.Contains(hc.Host, "/"):
+			problems = append(problems, fmt.Sprintf(
+				"%s.host: %q should be a host[:port], not a URL", key, hc.Host))
+		case seen[hc.Host]:
+			problems = append(problems, fmt.Sprintf(
+				"%s.host: %s has more than one policy", key, hc.Host))
+		}
+		seen[hc.Host] = true
+		problems = append(problems, hc.CallPolicyConfig.validate(key)...)
+	}
+	return problems
+}
+
+// ReadsConfig configures how the Dgraph queries that answer GraphQL queries
+// and subscriptions are run, to take load off the groups' leaders.  Mutations
+// always run in normal transactions, against alpha.
+type ReadsConfig struct {
+	// BestEffort runs the queries as best-effort queries, which don't get a
+	// timestamp from Zero, but might not see the very latest commits.
+	BestEffort bool `json:"best_effort"`
+
+	// Replicas is a comma-separated list of the alpha addresses, e.g. of
+	// follower replicas, that the queries are sent to instead of alpha.
+	Replicas string `json:"replicas"`
+}
+
+// TimeoutsConfig bounds how long each kind of operation can take; 0 is no
+// timeout.  A request can ask for a shorter timeout in its
+// X-Request-Timeout header.
+type TimeoutsConfig struct {
+	Query    time.Duration `json:"query"`
+	Mutation time.Duration `json:"mutation"`
+
+	// Subscription bounds each time a subscription's answer is resolved.
+	Subscription time.Duration `json:"subscription"`
+}
+
+// MarshalJSON writes tc with durations as strings like "30s".
+func (tc TimeoutsConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Query        string `json:"query"`
+		Mutation     string `json:"mutation"`
+		Subscription string `json:"subscription"`
+	}{tc.Query.String(), tc.Mutation.String(), tc.Subscription.String()})
+}
+
+// CORSConfig configures the cross-origin requests that /graphql allows, so
+// that browser apps served from other origins can call it directly.  With no
+// AllowedOrigins, the default, it allows none.
+type CORSConfig struct {
+	// AllowedOrigins are the origins, like https://app.example.com, allowed
+	// to call the API.  A host can start with a wildcard, as in
+	// https://*.example.com, and "*" allows every origin.
+	AllowedOrigins []string `json:"allowed_origins"`
+
+	// AllowedHeaders are the request headers that cross-origin requests can
+	// send.  By default, that's the headers the server reads: Content-Type,
+	// the JWT header, X-Request-Id and X-Request-Timeout.
+	AllowedHeaders []string `json:"allowed_headers"`
+
+	// AllowCredentials lets cross-origin requests send cookies and HTTP
+	// authentication.
+	AllowCredentials bool `json:"allow_credentials"`
+
+	// MaxAge is how long browsers cache the answer to a preflight request.
+	MaxAge time.Duration `json:"max_age"`
+}
+
+// defaultCORSMaxAge is how long preflight requests are cached by default.
+const defaultCORSMaxAge = 10 * time.Minute
+
+// MarshalJSON writes cc with MaxAge as a string like "10m0s".
+func (cc CORSConfig) MarshalJSON() ([]byte, error) {
+	type plain CORSConfig
+	return json.Marshal(struct {
+		plain
+		MaxAge string `json:"max_age"`
+	}{plain(cc), cc.MaxAge.String()})
+}
+
+func (cc CORSConfig) cors() web.CORS {
+	return web.CORS{
+		AllowedOrigins:   cc.AllowedOrigins,
+		AllowedHeaders:   cc.AllowedHeaders,
+		AllowCredentials: cc.AllowCredentials,
+		MaxAge:           cc.MaxAge,
+	}
+}
+
+// validate returns a description of each problem with cc.
+func (cc CORSConfig) validate() []string {
+	var problems []string
+	for _, origin := range cc.AllowedOrigins {
+		if origin == "*" {
+			if cc.AllowCredentials {
+				problems = append(problems, `cors.allow_credentials: can't be used when `+
+					`cors.allowed_origins has "*"; list the origins instead`)
+			}
+			continue
+		}
+		if err := checkOrigin(origin); err != nil {
+			problems = append(problems, fmt.Sprintf("cors.allowed_origins: %v", err))
+		}
+	}
+	for _, header := range cc.AllowedHeaders {
+		if strings.TrimSpace(header) == "" || strings.ContainsAny(header, " ,:") {
+			problems = append(problems, fmt.Sprintf(
+				"cors.allowed_headers: %q isn't a header name", header))
+		}
+	}
+	if cc.MaxAge < 0 {
+		problems = append(problems, "cors.max_age: can't be negative")
+	}
+	return problems
+}
+
+// CompressionConfig configures how responses are compressed, for clients
+// that accept it.
+type CompressionConfig struct {
+	// Gzip compresses responses with gzip.
+	Gzip bool `json:"gzip"`
+
+	// MinSize is the length, in bytes, of the shortest response compressed.
+	MinSize int `json:"min_size"`
+}
+
+// defaultCompressionMinSize is the default CompressionConfig.MinSize.  Much
+// less than this, and compression saves less than it costs.
+const defaultCompressionMinSize = 1024
+
+// UploadsConfig configures where the files uploaded to @upload fields are
+// stored.  Uploads aren't accepted unless Dir is set.
+type UploadsConfig struct {
+	// Dir is the directory the files are written to.
+	Dir string `json:"dir"`
+
+	// URL is where the files in Dir can be fetched from: a path, like the
+	// default /uploads/, that this server serves them at, or the http or
+	// https URL of a server, or CDN, that serves Dir.
+	URL string `json:"url"`
+}
+
+// defaultUploadsURL is where uploaded files are served by default.
+const defaultUploadsURL = "/uploads/"
+
+// servedPaths are the paths that uploads can't be served at, because the
+// server has other uses for them.
+var servedPaths = map[string]bool{
+	"/": true, "/graphql/": true, "/admin/": true, "/ui/": true, "/health/": true,
+}
+
+// served returns true if uc's files are served by this server.
+func (uc UploadsConfig) served() bool {
+	return strings.HasPrefix(uc.URL, "/")
+}
+
+// RequestLogConfig configures the structured log of the GraphQL requests
+// served.  Nothing is logged unless SampleRate or Errors is set.
+type RequestLogConfig struct {
+	// SampleRate is the fraction of requests logged, from 0 to 1.
+	SampleRate float64 `json:"sample_rate"`
+
+	// Errors logs every request that gets errors, sampled or not.
+	Errors bool `json:"errors"`
+
+	// Redact names the variables, and fields of input objects, whose values
+	// aren't logged.  By default, that's password, secret and token.
+	Redact []string `json:"redact"`
+}
+
+// defaultRedact is what's redacted from the request log by default.
+var defaultRedact = []string{"password", "secret", "token"}
+
+// AuditConfig configures the audit log of the mutations committed.  Nothing is
+// audited unless File, Webhook or Dgraph is set; entries are written to each
+// of them that is.
+type AuditConfig struct {
+	// File is a file that entries are appended to, as JSON lines.
+	File string `json:"file"`
+
+	// Webhook is a URL that each entry is POSTed to.  It can reference
+	// secrets, as {{secrets.NAME}}.
+	Webhook string `json:"webhook"`
+
+	// Dgraph stores entries in Dgraph, as dgraph.graphql.audit nodes.
+	Dgraph bool `json:"dgraph"`
+
+	// Redact names the arguments, and fields of input objects, whose values
+	// aren't recorded.  By default, it's the same as request_log.redact.
+	Redact []string `json:"redact"`
+
+	// ActorClaim is the token's claim that's recorded as who made the
+	// mutation.
+	ActorClaim string `json:"actor_claim"`
+}
+
+// defaultActorClaim is the claim that identifies who made a mutation.
+const defaultActorClaim = "sub"
+
+// enabled returns true if mutations are audited.
+func (ac *AuditConfig) enabled() bool {
+	return ac.File != "" || ac.Webhook != "" || ac.Dgraph
+}
+
+// sinks returns the sinks that are shared by every API: the file and the
+// webhook, whose secrets are looked up in p and whose calls are made as ps
+// say.  Entries stored in Dgraph go to each API's own cluster.
+func (ac *AuditConfig) sinks(p secrets.Provider,
+	ps external.Policies) ([]resolve.AuditSink, error) {
+
+	var sinks []resolve.AuditSink
+	if ac.File != "" {
+		sink, err := resolve.NewFileAuditSink(ac.File)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if ac.Webhook != "" {
+		sinks = append(sinks, resolve.NewWebhookAuditSink(ac.Webhook, p, ps))
+	}
+	return sinks, nil
+}
+
+func (ac *AuditConfig) validate() []string {
+	var problems []string
+	if ac.File != "" {
+		if dir, err := os.Stat(filepath.Dir(ac.File)); err != nil {
+			problems = append(problems, fmt.Sprintf("audit.file: %v", err))
+		} else if !dir.IsDir() {
+			problems = append(problems, fmt.Sprintf(
+				"audit.file: %s isn't a directory", filepath.Dir(ac.File)))
+		}
+	}
+	if ac.Webhook != "" {
+		if err := checkURL(ac.Webhook); err != nil {
+			problems = append(problems, fmt.Sprintf("audit.webhook: %v", err))
+		}
+	}
+	if ac.ActorClaim == "" {
+		problems = append(problems, "audit.actor_claim: can't be empty")
+	}
+	return problems
+}
+
+// CDCConfig configures the publishing of the changes that mutations make to
+// Kafka.  Nothing is published unless KafkaBrokers is set.
+type CDCConfig struct {
+	// KafkaBrokers are the host:port addresses of the Kafka brokers.
+	KafkaBrokers []string `json:"kafka_brokers"`
+
+	// TopicPrefix is put in front of a type's name to make the topic its
+	// changes are published to.
+	TopicPrefix string `json:"topic_prefix"`
+}
+
+// topicPrefix is what a topic prefix can be made of: the characters of a
+// Kafka topic name.  The type names that follow it are always allowed.
+var topicPrefix = regexp.MustCompile(`^[a-zA-Z0-9._-]*$`)
+
+// sink returns the sink that changes are published to, or nil if they
+// aren't published.
+func (cc *CDCConfig) sink() (resolve.ChangeSink, error) {
+	if len(cc.KafkaBrokers) == 0 {
+		return nil, nil
+	}
+	return cdc.NewKafkaSink(cc.KafkaBrokers, cc.TopicPrefix)
+}
+
+func (cc *CDCConfig) validate() []string {
+	var problems []string
+	for _, addr := range cc.KafkaBrokers {
+		if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+			problems = append(problems, fmt.Sprintf(
+				"cdc.kafka_brokers: %q isn't a host:port address", addr))
+		}
+	}
+	if !topicPrefix.MatchString(cc.TopicPrefix) {
+		problems = append(problems, fmt.Sprintf(
+			"cdc.topic_prefix: %q can only have letters, digits, '.', '_' and '-'",
+			cc.TopicPrefix))
+	}
+	return problems
+}
+
+// RateLimitConfig bounds how fast each client can make requests to /graphql,
+// and run mutations.  A client can make up to the burst at once, and then as
+// many per second as the rate; a rate of 0 is no limit, and a burst of 0 is a
+// second's worth of the rate.  Clients are told apart by their API key, in
+// APIKeyHeader, then by their token's subject, and then by their IP address.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	RequestBurst      int     `json:"request_burst"`
+	WritesPerSecond   float64 `json:"writes_per_second"`
+	WriteBurst        int     `json:"write_burst"`
+
+	// APIKeyHeader is the request header with the client's API key.  Keys
+	// aren't checked by this server, so it should only be set behind a
+	// gateway that checks them.
+	APIKeyHeader string `json:"api_key_header"`
+}
+
+// enabled returns true if there are any limits.
+func (rc RateLimitConfig) enabled() bool {
+	return rc.RequestsPerSecond > 0 || rc.WritesPerSecond > 0
+}
+
+func (rc RateLimitConfig) limits() resolve.RateLimits {
+	return resolve.RateLimits{
+		Requests:     rc.RequestsPerSecond,
+		RequestBurst: rc.RequestBurst,
+		Writes:       rc.WritesPerSecond,
+		WriteBurst:   rc.WriteBurst,
+	}
+}
+
+func (rc RateLimitConfig) validate() []string {
+	var problems []string
+	if rc.RequestsPerSecond < 0 {
+		problems = append(problems, "rate_limit.requests_per_second: can't be negative")
+	}
+	if rc.RequestBurst < 0 {
+		problems = append(problems, "rate_limit.request_burst: can't be negative")
+	}
+	if rc.WritesPerSecond < 0 {
+		problems = append(problems, "rate_limit.writes_per_second: can't be negative")
+	}
+	if rc.WriteBurst < 0 {
+		problems = append(problems, "rate_limit.write_burst: can't be negative")
+	}
+	return problems
+}
+
+// NamespacesConfig configures the namespaces: tenants that each get their own
+// GraphQL API, with its own schema, caches and registered queries, on the
+// same server.  Requests name their namespace in Header, and those that
+// don't name one get the default API; or, if Claim is set, in that claim of
+// their JWT, and those that don't are refused.  The admin API is chosen by
+// Header, whether Claim is set or not.
+//
+// This version of Dgraph doesn't have namespaces of its own, so each
+// namespace is stored in its own Dgraph cluster, which keeps their data, and
+// their GraphQL schemas, apart.
+type NamespacesConfig struct {
+	Header string `json:"header"`
+	Claim  string `json:"claim"`
+
+	// Alphas are the comma-separated alpha addresses of each namespace's
+	// Dgraph cluster, keyed by the namespace's name.
+	Alphas map[string]string `json:"alphas"`
+}
+
+// namespaceName is what a namespace can be called.  Names are lower case,
+// because config files' keys are read case-insensitively.
+var namespaceName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// names returns the names of the namespaces, in order.
+func (nc NamespacesConfig) names() []string {
+	names := make([]string, 0, len(nc.Alphas))
+	for name := range nc.Alphas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (nc NamespacesConfig) validate(jwt *JWTConfig) []string {
+	var problems []string
+	if nc.Header == "" {
+		problems = append(problems, "namespaces.header: can't be empty")
+	}
+	if nc.Claim != "" && !jwt.enabled() {
+		problems = append(problems, "namespaces.claim: needs JWTs, but jwt has no "+
+			"hmac_secret, public_key_file or jwks_url")
+	}
+	for _, name := range nc.names() {
+		if !namespaceName.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("namespaces.alphas: %q isn't a valid "+
+				"namespace name; use lower-case letters, digits, - and _", name))
+		}
+		if strings.TrimSpace(nc.Alphas[name]) == "" {
+			problems = append(problems, fmt.Sprintf(
+				"namespaces.alphas: namespace %s has no alpha addresses", name))
+		}
+	}
+	return problems
+}
+
+// IntrospectionConfig says who can ask the GraphQL API about its schema:
+// Mode is enabled (anyone), disabled (no one), authenticated (requests with a
+// valid JWT) or admin (requests whose JWT's RoleClaim claim is AdminRole, or
+// a list with AdminRole in it).  The admin API can change the mode.
+type IntrospectionConfig struct {
+	Mode      string `json:"mode"`
+	RoleClaim string `json:"role_claim"`
+	AdminRole string `json:"admin_role"`
+}
+
+// The claim, and its value, that give a request the admin role by default.
+const (
+	defaultRoleClaim = "role"
+	defaultAdminRole = "admin"
+)
+
+func (ic IntrospectionConfig) policy() (*resolve.IntrospectionPolicy, error) {
+	return resolve.NewIntrospectionPolicy(ic.Mode, ic.RoleClaim, ic.AdminRole)
+}
+
+func (ic IntrospectionConfig) validate(jwt *JWTConfig) []string {
+	if err := resolve.ValidIntrospectionMode(ic.Mode); err != nil {
+		return []string{fmt.Sprintf("introspection.mode: %v", err)}
+	}
+	var problems []string
+	switch ic.Mode {
+	case resolve.IntrospectionAuthenticated, resolve.IntrospectionAdmin:
+		if !jwt.enabled() {
+			problems = append(problems, fmt.Sprintf("introspection.mode: %s needs JWTs, but "+
+				"jwt has no hmac_secret, public_key_file or jwks_url", ic.Mode))
+		}
+	}
+	if ic.Mode == resolve.IntrospectionAdmin {
+		if ic.RoleClaim == "" {
+			problems = append(problems, "introspection.role_claim: can't be empty")
+		}
+		if ic.AdminRole == "" {
+			problems = append(problems, "introspection.admin_role: can't be empty")
+		}
+	}
+	return problems
+}
+
+// JWTConfig configures how requests are authenticated.  If one of
+// HMACSecret, PublicKeyFile or JWKSURL is set, requests can carry a JWT in
+// Header, and the token's claims are what @auth rules see.
+type JWTConfig struct {
+	Header    string `json:"header"`
+	Namespace string `json:"namespace"`
+	Audience  string `json:"audience"`
+	Issuer    string `json:"issuer"`
+
+	// HMACSecret can be, or reference, a secret, as {{secrets.NAME}}, so the
+	// key itself doesn't have to be in the config file.
+	HMACSecret    string `json:"hmac_secret"`
+	PublicKeyFile string `json:"public_key_file"`
+	JWKSURL       string `json:"jwks_url"`
+
+	// Anonymous lists the kinds of operation - query, mutation and
+	// subscription - that requests without a token can run.  By default,
+	// they can run anything, and @auth rules decide what they see.
+	Anonymous []string `json:"anonymous"`
+
+	// AnonymousAllow and AnonymousDeny name queries, mutations and
+	// subscriptions that requests without a token can, or can't, run,
+	// whatever Anonymous says about their kind.
+	AnonymousAllow []string `json:"anonymous_allow"`
+	AnonymousDeny  []string `json:"anonymous_deny"`
+}
+
+// enabled returns true if a key to verify tokens is configured.
+func (jc *JWTConfig) enabled() bool {
+	return jc.HMACSecret != "" || jc.PublicKeyFile != "" || jc.JWKSURL != ""
+}
+
+// verifier returns the Verifier for tokens, or nil if JWTs aren't enabled.
+// The secrets that HMACSecret references are looked up in p.
+func (jc *JWTConfig) verifier(p secrets.Provider) (*authorization.Verifier, error) {
+	if !jc.enabled() {
+		return nil, nil
+	}
+
+	hmacSecret, err := secrets.Expand(context.Background(), p, jc.HMACSecret)
+	if err != nil {
+		return nil, errors.Wrap(err, "while looking up hmac_secret")
+	}
+	vc := authorization.JWTConfig{
+		Header:     jc.Header,
+		Namespace:  jc.Namespace,
+		Audience:   jc.Audience,
+		Issuer:     jc.Issuer,
+		HMACSecret: hmacSecret,
+		JWKSURL:    jc.JWKSURL,
+	}
+	if jc.PublicKeyFile != "" {
+		key, err := ioutil.ReadFile(jc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		vc.PublicKey = key
+	}
+	return authorization.NewVerifier(vc)
+}
+
+// anonymousPolicy returns what requests without a token can run.
+func (jc *JWTConfig) anonymousPolicy() (*authorization.AnonymousPolicy, error) {
+	policy, err := authorization.NewAnonymousPolicy(jc.Anonymous...)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Allow(jc.AnonymousAllow...).Deny(jc.AnonymousDeny...), nil
+}
+
+// SecretsConfig configures where the secrets that are referenced as
+// {{secrets.NAME}} - in @custom calls, jwt.hmac_secret, schema_webhook and
+// audit.webhook - are looked up.
+type SecretsConfig struct {
+	// Providers are asked for a secret in turn, and the first that has it
+	// gives its value.  They're env, the environment, and vault.  By
+	// default, only the environment is used.
+	Providers []string `json:"providers"`
+
+	// EnvPrefix starts the names of the environment variables that env reads:
+	// by default, NAME is read from DGRAPH_GRAPHQL_SECRET_NAME.
+	EnvPrefix string `json:"env_prefix"`
+
+	Vault VaultConfig `json:"vault"`
+}
+
+// VaultConfig configures the Vault server that the vault secrets provider
+// reads: secrets are the keys of the key/value secret at Path.
+type VaultConfig struct {
+	Addr  string `json:"addr"`
+	Token string `json:"token"`
+	Path  string `json:"path"`
+}
+
+// Secrets providers, as named in secrets.providers.
+const (
+	envSecrets   = "env"
+	vaultSecrets = "vault"
+)
+
+// vaultCacheTTL is how long secrets read from Vault are kept before they're
+// read again, so that rotated secrets are picked up.
+const vaultCacheTTL = 5 * time.Minute
+
+// provider returns the provider that looks up secrets in the configured
+// providers, in order.
+func (sc *SecretsConfig) provider() secrets.Provider {
+	var providers []secrets.Provider
+	for _, name := range sc.Providers {
+		switch name {
+		case envSecrets:
+			providers = append(providers, secrets.NewEnvProvider(sc.EnvPrefix))
+		case vaultSecrets:
+			providers = append(providers, secrets.WithCache(
+				secrets.NewVaultProvider(sc.Vault.Addr, sc.Vault.Token, sc.Vault.Path),
+				vaultCacheTTL))
+		}
+	}
+	if len(providers) == 1 {
+		return providers[0]
+	}
+	return secrets.Chain(providers...)
+}
+
+func (sc *SecretsConfig) validate() []string {
+	var problems []string
+	vault := false
+	for _, name := range sc.Providers {
+		switch name {
+		case envSecrets:
+		case vaultSecrets:
+			vault = true
+		default:
+			problems = append(problems, fmt.Sprintf(
+				"secrets.providers: %q isn't a secrets provider; they're env and vault", name))
+		}
+	}
+	if !vault {
+		return problems
+	}
+	if err := checkURL(sc.Vault.Addr); err != nil {
+		problems = append(problems, fmt.Sprintf("secrets.vault.addr: %v", err))
+	}
+	if sc.Vault.Token == "" {
+		problems = append(problems, "secrets.vault.token: is needed for the vault provider")
+	}
+	if strings.Trim(sc.Vault.Path, "/") == "" {
+		problems = append(problems, "secrets.vault.path: is needed for the vault provider")
+	}
+	return problems
+}
+
+// SignersConfig configures, by name, the signers that sign the requests made
+// to external services, so the services can tell the requests came from this
+// server.  Names aren't case sensitive; they're kept in lower case.
+type SignersConfig map[string]SignerConfig
+
+// SignerConfig configures a signer.  An hmac signer signs each request with
+// Secret, which the service shares, as signing.VerifyHMAC checks.  An oauth2
+// signer adds a bearer token, got from TokenURL with the client credentials
+// grant.  Secret and ClientSecret can reference secrets, as {{secrets.NAME}}.
+type SignerConfig struct {
+	Type         string   `json:"type"`
+	Secret       string   `json:"secret"`
+	TokenURL     string   `json:"token_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+// Signer types, as set in a signer's type.
+const (
+	hmacSigner   = "hmac"
+	oauth2Signer = "oauth2"
+)
+
+// loadSigners reads the signers configured in conf.
+func loadSigners(conf *viper.Viper) SignersConfig {
+	names := conf.GetStringMap("signers")
+	if len(names) == 0 {
+		return nil
+	}
+	signers := make(SignersConfig, len(names))
+	for name := range names {
+		key := "signers." + name + "."
+		signers[name] = SignerConfig{
+			Type:         conf.GetString(key + "type"),
+			Secret:       conf.GetString(key + "secret"),
+			TokenURL:     conf.GetString(key + "token_url"),
+			ClientID:     conf.GetString(key + "client_id"),
+			ClientSecret: conf.GetString(key + "client_secret"),
+			Scopes:       conf.GetStringSlice(key + "scopes"),
+		}
+	}
+	return signers
+}
+
+// redacted returns sc without the signers' secrets.
+func (sc SignersConfig) redacted() SignersConfig {
+	if sc == nil {
+		return nil
+	}
+	redacted := make(SignersConfig, len(sc))
+	for name, signer := range sc {
+		if signer.Secret != "" {
+			signer.Secret = "<redacted>"
+		}
+		if signer.ClientSecret != "" {
+			signer.ClientSecret = "<redacted>"
+		}
+		redacted[name] = signer
+	}
+	return redacted
+}
+
+// signers returns the signers, by name, with the secrets they reference
+// looked up in p.
+func (sc SignersConfig) signers(p secrets.Provider) (map[string]signing.Signer, error) {
+	signers := make(map[string]signing.Signer, len(sc))
+	for name, conf := range sc {
+		signer, err := conf.signer(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "signers.%s", name)
+		}
+		signers[name] = signer
+	}
+	return signers, nil
+}
+
+func (sc SignerConfig) signer(p secrets.Provider) (signing.Signer, error) {
+	switch sc.Type {
+	case hmacSigner:
+		secret, err := secrets.Expand(context.Background(), p, sc.Secret)
+		if err != nil {
+			return nil, errors.Wrap(err, "while looking up secret")
+		}
+		return signing.NewHMACSigner(secret), nil
+	case oauth2Signer:
+		clientSecret, err := secrets.Expand(context.Background(), p, sc.ClientSecret)
+		if err != nil {
+			return nil, errors.Wrap(err, "while looking up client_secret")
+		}
+		return signing.NewOAuth2Signer(signing.ClientCredentials{
+			TokenURL:     sc.TokenURL,
+			ClientID:     sc.ClientID,
+			ClientSecret: clientSecret,
+			Scopes:       sc.Scopes,
+		}, nil), nil
+	}
+	return nil, errors.Errorf("%q isn't a signer type; expected hmac or oauth2", sc.Type)
+}
+
+// validate checks sc, looking up the secrets that the signers reference in p.
+// If p is nil, because the secrets providers aren't valid, the secrets aren't
+// looked up.
+func (sc SignersConfig) validate(p secrets.Provider) []string {
+	names := make([]string, 0, len(sc))
+	for name := range sc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		signer := sc[name]
+		key := "signers." + name
+		switch signer.Type {
+		case hmacSigner:
+			if signer.Secret == "" {
+				problems = append(problems, key+".secret: is needed for an hmac signer")
+				continue
+			}
+		case oauth2Signer:
+			bad := false
+			if err := checkURL(signer.TokenURL); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.token_url: %v", key, err))
+				bad = true
+			}
+			if signer.ClientID == "" || signer.ClientSecret == "" {
+				problems = append(problems, key+": client_id and client_secret are "+
+					"needed for an oauth2 signer")
+				bad = true
+			}
+			if bad {
+				continue
+			}
+		default:
+			problems = append(problems, fmt.Sprintf(
+				"%s.type: %q isn't a signer type; expected hmac or oauth2", key, signer.Type))
+			continue
+		}
+		if p != nil {
+			if _, err := signer.signer(p); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			}
+		}
+	}
+	return problems
+}
+
+// loadConfig reads the Config from conf and checks it.  Every problem with
+// the settings is reported, not just the first.
+func loadConfig(conf *viper.Viper) (*Config, error) {
+	cfg := &Config{
+		Alpha:                  conf.GetString("alpha"),
+		Port:                   conf.GetInt("port"),
+		TLSDir:                 conf.GetString("tls_dir"),
+		TLSClientAuth:          conf.GetString("tls_client_auth"),
+		Schema:                 conf.GetString("schema"),
+		SchemaFile:             conf.GetString("graphql_schema_file"),
+		SchemaPollInterval:     conf.GetDuration("schema_poll_interval"),
+		SchemaCheck:            conf.GetString("schema_check"),
+		SchemaWebhook:          conf.GetString("schema_webhook"),
+		UI:                     conf.GetBool("ui"),
+		UIAssets:               conf.GetString("ui_assets"),
+		Allowlist:              conf.GetBool("allowlist"),
+		AllowlistIntrospection: conf.GetBool("allowlist_introspection"),
+		Trace:                  conf.GetFloat64("trace"),
+		Explain:                conf.GetBool("explain"),
+		Lambda: LambdaConfig{
+			URL:    conf.GetString("lambda.url"),
+			Signer: strings.ToLower(conf.GetString("lambda.signer")),
+		},
+		Subscriptions: SubscriptionConfig{
+			PollInterval: resolve.DefaultPollInterval,
+		},
+		Batch: BatchConfig{
+			Concurrent: conf.GetBool("batch.concurrent"),
+		},
+		Limits: LimitsConfig{
+			MaxDepth:      conf.GetInt("limits.max_depth"),
+			MaxFields:     conf.GetInt("limits.max_fields"),
+			MaxComplexity: conf.GetInt("limits.max_complexity"),
+			MaxCost:       conf.GetInt("limits.max_cost"),
+			ReportCost:    conf.GetBool("limits.report_cost"),
+		},
+		Retries: RetriesConfig{
+			Reads:  retryConfig(conf, "retries.reads", resolve.DefaultRetries.Reads),
+			Writes: retryConfig(conf, "retries.writes", resolve.DefaultRetries.Writes),
+		},
+		Events: retryConfig(conf, "events", resolve.DefaultEventDelivery),
+		Reads: ReadsConfig{
+			BestEffort: conf.GetBool("reads.best_effort"),
+			Replicas:   conf.GetString("reads.replicas"),
+		},
+		Timeouts: TimeoutsConfig{
+			Query:        conf.GetDuration("timeouts.query"),
+			Mutation:     conf.GetDuration("timeouts.mutation"),
+			Subscription: conf.GetDuration("timeouts.subscription"),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   conf.GetStringSlice("cors.allowed_origins"),
+			AllowCredentials: conf.GetBool("cors.allow_credentials"),
+			MaxAge:           defaultCORSMaxAge,
+		},
+		Compression: CompressionConfig{
+			Gzip:    true,
+			MinSize: defaultCompressionMinSize,
+		},
+		Uploads: UploadsConfig{
+			Dir: conf.GetString("uploads.dir"),
+			URL: defaultUploadsURL,
+		},
+		RequestLog: RequestLogConfig{
+			SampleRate: conf.GetFloat64("request_log.sample_rate"),
+			Errors:     conf.GetBool("request_log.errors"),
+			Redact:     defaultRedact,
+		},
+		Audit: AuditConfig{
+			File:       conf.GetString("audit.file"),
+			Webhook:    conf.GetString("audit.webhook"),
+			Dgraph:     conf.GetBool("audit.dgraph"),
+			ActorClaim: defaultActorClaim,
+		},
+		CDC: CDCConfig{
+			KafkaBrokers: conf.GetStringSlice("cdc.kafka_brokers"),
+			TopicPrefix:  cdc.DefaultTopicPrefix,
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: conf.GetFloat64("rate_limit.requests_per_second"),
+			RequestBurst:      conf.GetInt("rate_limit.request_burst"),
+			WritesPerSecond:   conf.GetFloat64("rate_limit.writes_per_second"),
+			WriteBurst:        conf.GetInt("rate_limit.write_burst"),
+			APIKeyHeader:      conf.GetString("rate_limit.api_key_header"),
+		},
+		Namespaces: NamespacesConfig{
+			Header: web.DefaultNamespaceHeader,
+			Claim:  conf.GetString("namespaces.claim"),
+			Alphas: conf.GetStringMapString("namespaces.alphas"),
+		},
+		Introspection: IntrospectionConfig{
+			Mode:      resolve.IntrospectionEnabled,
+			RoleClaim: defaultRoleClaim,
+			AdminRole: defaultAdminRole,
+		},
+		JWT: JWTConfig{
+			Header:         conf.GetString("jwt.header"),
+			Namespace:      conf.GetString("jwt.namespace"),
+			Audience:       conf.GetString("jwt.audience"),
+			Issuer:         conf.GetString("jwt.issuer"),
+			HMACSecret:     conf.GetString("jwt.hmac_secret"),
+			PublicKeyFile:  conf.GetString("jwt.public_key_file"),
+			JWKSURL:        conf.GetString("jwt.jwks_url"),
+			AnonymousAllow: conf.GetStringSlice("jwt.anonymous_allow"),
+			AnonymousDeny:  conf.GetStringSlice("jwt.anonymous_deny"),
+			Anonymous: []string{
+				authorization.Query, authorization.Mutation, authorization.Subscription},
+		},
+		Secrets: SecretsConfig{
+			Providers: []string{envSecrets},
+			EnvPrefix: secretsEnvPrefix,
+			Vault: VaultConfig{
+				Addr:  conf.GetString("secrets.vault.addr"),
+				Token: conf.GetString("secrets.vault.token"),
+				Path:  conf.GetString("secrets.vault.path"),
+			},
+		},
+		Signers: loadSigners(conf),
+		Calls:   loadCalls(conf),
+	}
+	if cfg.JWT.Header == "" {
+		cfg.JWT.Header = authorization.DefaultHeader
+	}
+	if conf.IsSet("jwt.anonymous") {
+		cfg.JWT.Anonymous = conf.GetStringSlice("jwt.anonymous")
+	}
+	if conf.IsSet("request_log.redact") {
+		cfg.RequestLog.Redact = conf.GetStringSlice("request_log.redact")
+	}
+	cfg.Audit.Redact = cfg.RequestLog.Redact
+	if conf.IsSet("audit.redact") {
+		cfg.Audit.Redact = conf.GetStringSlice("audit.redact")
+	}
+	if conf.IsSet("cdc.topic_prefix") {
+		cfg.CDC.TopicPrefix = conf.GetString("cdc.topic_prefix")
+	}
+	if conf.IsSet("audit.actor_claim") {
+		cfg.Audit.ActorClaim = conf.GetString("audit.actor_claim")
+	}
+	if conf.IsSet("subscriptions.poll_interval") {
+		cfg.Subscriptions.PollInterval = conf.GetDuration("subscriptions.poll_interval")
+	}
+	if conf.IsSet("secrets.providers") {
+		cfg.Secrets.Providers = conf.GetStringSlice("secrets.providers")
+	}
+	if conf.IsSet("secrets.env_prefix") {
+		cfg.Secrets.EnvPrefix = conf.GetString("secrets.env_prefix")
+	}
+	if conf.IsSet("compression.gzip") {
+		cfg.Compression.Gzip = conf.GetBool("compression.gzip")
+	}
+	if conf.IsSet("compression.min_size") {
+		cfg.Compression.MinSize = conf.GetInt("compression.min_size")
+	}
+	if conf.IsSet("uploads.url") {
+		cfg.Uploads.URL = conf.GetString("uploads.url")
+	}
+	if cfg.Uploads.served() && !strings.HasSuffix(cfg.Uploads.URL, "/") {
+		cfg.Uploads.URL += "/"
+	}
+	if conf.IsSet("cors.max_age") {
+		cfg.CORS.MaxAge = conf.GetDuration("cors.max_age")
+	}
+	if conf.IsSet("namespaces.header") {
+		cfg.Namespaces.Header = conf.GetString("namespaces.header")
+	}
+	if conf.IsSet("introspection.mode") {
+		cfg.Introspection.Mode = conf.GetString("introspection.mode")
+	}
+	if conf.IsSet("introspection.role_claim") {
+		cfg.Introspection.RoleClaim = conf.GetString("introspection.role_claim")
+	}
+	if conf.IsSet("introspection.admin_role") {
+		cfg.Introspection.AdminRole = conf.GetString("introspection.admin_role")
+	}
+	if len(cfg.Namespaces.Alphas) == 0 {
+		cfg.Namespaces.Alphas = nil
+	}
+	if conf.IsSet("cors.allowed_headers") {
+		cfg.CORS.AllowedHeaders = conf.GetStringSlice("cors.allowed_headers")
+	} else {
+		extra := []string{cfg.JWT.Header}
+		if cfg.Namespaces.Alphas != nil && cfg.Namespaces.Claim == "" {
+			extra = append(extra, cfg.Namespaces.Header)
+		}
+		if cfg.RateLimit.APIKeyHeader != "" {
+			extra = append(extra, cfg.RateLimit.APIKeyHeader)
+		}
+		cfg.CORS.AllowedHeaders = corsHeaders(extra...)
+	}
+
+	var problems []string
+	for _, spec := range conf.GetStringSlice("remote") {
+		remote, err := parseRemote(spec)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		cfg.Remotes = append(cfg.Remotes, remote)
+	}
+	problems = append(problems, cfg.validate()...)
+
+	if len(problems) > 0 {
+		return nil, errors.Errorf("invalid GraphQL configuration:\n  %s",
+			strings.Join(problems, "\n  "))
+	}
+	return cfg, nil
+}
+
+// validate returns a description of each problem with the settings in cfg.
+func (cfg *Config) validate() []string {
+	var problems []string
+
+	if strings.TrimSpace(cfg.Alpha) == "" {
+		problems = append(problems, "alpha: at least one Dgraph alpha address is needed")
+	}
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port: %d isn't a valid port", cfg.Port))
+	}
+	if cfg.TLSDir != "" {
+		if _, err := cfg.tlsConfig(); err != nil {
+			problems = append(problems, fmt.Sprintf("tls_dir: %v", err))
+		}
+	}
+	if cfg.Schema != "" {
+		if _, err := os.Stat(cfg.Schema); err != nil {
+			problems = append(problems, fmt.Sprintf("schema: %v", err))
+		}
+	}
+	if cfg.SchemaFile != "" {
+		if _, err := os.Stat(cfg.SchemaFile); err != nil {
+			problems = append(problems, fmt.Sprintf("graphql_schema_file: %v", err))
+		}
+		if cfg.Schema != "" {
+			problems = append(problems, "graphql_schema_file: can't be used with schema")
+		}
+	}
+	if cfg.UIAssets != "" {
+		for _, file := range web.UIAssets {
+			if _, err := os.Stat(filepath.Join(cfg.UIAssets, file)); err != nil {
+				problems = append(problems, fmt.Sprintf("ui_assets: %v", err))
+			}
+		}
+	}
+	if cfg.SchemaPollInterval < 0 {
+		problems = append(problems, "schema_poll_interval: can't be negative")
+	}
+	if _, err := admin.ParseSchemaCheck(cfg.SchemaCheck); err != nil {
+		problems = append(problems, fmt.Sprintf("schema_check: %v", err))
+	}
+	if cfg.Trace < 0 || cfg.Trace > 1 {
+		problems = append(problems, fmt.Sprintf("trace: %v isn't a ratio between 0 and 1",
+			cfg.Trace))
+	}
+	if cfg.SchemaWebhook != "" {
+		if err := checkURL(cfg.SchemaWebhook); err != nil {
+			problems = append(problems, fmt.Sprintf("schema_webhook: %v", err))
+		}
+	}
+
+	fields := make(map[string]bool)
+	for _, remote := range cfg.Remotes {
+		if fields[remote.Field] {
+			problems = append(problems,
+				fmt.Sprintf("remote: field %s is used for more than one remote API", remote.Field))
+		}
+		fields[remote.Field] = true
+		if err := checkURL(remote.URL); err != nil {
+			problems = append(problems, fmt.Sprintf("remote: %s: %v", remote.Field, err))
+		}
+	}
+
+	if cfg.Subscriptions.PollInterval <= 0 {
+		problems = append(problems, "subscriptions.poll_interval: must be positive")
+	}
+
+	if cfg.Lambda.URL != "" {
+		if err := checkURL(cfg.Lambda.URL); err != nil {
+			problems = append(problems, fmt.Sprintf("lambda.url: %v", err))
+		}
+	}
+	if _, ok := cfg.Signers[cfg.Lambda.Signer]; cfg.Lambda.Signer != "" && !ok {
+		problems = append(problems, fmt.Sprintf("lambda.signer: there's no signer %s in signers",
+			cfg.Lambda.Signer))
+	}
+
+	if cfg.Limits.MaxDepth < 0 {
+		problems = append(problems, "limits.max_depth: can't be negative")
+	}
+	if cfg.Limits.MaxFields < 0 {
+		problems = append(problems, "limits.max_fields: can't be negative")
+	}
+	if cfg.Limits.MaxComplexity < 0 {
+		problems = append(problems, "limits.max_complexity: can't be negative")
+	}
+	if cfg.Limits.MaxCost < 0 {
+		problems = append(problems, "limits.max_cost: can't be negative")
+	}
+
+	problems = append(problems, cfg.Retries.Reads.validate("retries.reads")...)
+	problems = append(problems, cfg.Retries.Writes.validate("retries.writes")...)
+	problems = append(problems, cfg.Events.validate("events")...)
+	problems = append(problems, cfg.Calls.validate()...)
+
+	if cfg.Timeouts.Query < 0 {
+		problems = append(problems, "timeouts.query: can't be negative")
+	}
+	if cfg.Timeouts.Mutation < 0 {
+		problems = append(problems, "timeouts.mutation: can't be negative")
+	}
+	if cfg.Timeouts.Subscription < 0 {
+		problems = append(problems, "timeouts.subscription: can't be negative")
+	}
+
+	if cfg.Reads.Replicas != "" {
+		for _, addr := range strings.Split(cfg.Reads.Replicas, ",") {
+			if strings.TrimSpace(addr) == "" {
+				problems = append(problems, fmt.Sprintf(
+					"reads.replicas: %q has an empty address", cfg.Reads.Replicas))
+				break
+			}
+		}
+	}
+
+	problems = append(problems, cfg.CORS.validate()...)
+
+	if cfg.Compression.MinSize < 0 {
+		problems = append(problems, "compression.min_size: can't be negative")
+	}
+
+	if !cfg.Uploads.served() {
+		if err := checkURL(cfg.Uploads.URL); err != nil {
+			problems = append(problems, fmt.Sprintf(
+				"uploads.url: %v; it must be a URL or a path like /uploads/", err))
+		}
+	} else if servedPaths[cfg.Uploads.URL] {
+		problems = append(problems, fmt.Sprintf(
+			"uploads.url: %s is already used by the server", cfg.Uploads.URL))
+	}
+
+	if rate := cfg.RequestLog.SampleRate; rate < 0 || rate > 1 {
+		problems = append(problems, fmt.Sprintf(
+			"request_log.sample_rate: %v isn't a ratio between 0 and 1", rate))
+	}
+
+	problems = append(problems, cfg.Audit.validate()...)
+	problems = append(problems, cfg.CDC.validate()...)
+	problems = append(problems, cfg.RateLimit.validate()...)
+	problems = append(problems, cfg.Namespaces.validate(&cfg.JWT)...)
+	problems = append(problems, cfg.Introspection.validate(&cfg.JWT)...)
+	secretsProblems := cfg.Secrets.validate()
+	problems = append(problems, secretsProblems...)
+	var p secrets.Provider
+	if len(secretsProblems) == 0 {
+		p = cfg.Secrets.provider()
+	}
+	problems = append(problems, cfg.JWT.validate(p)...)
+	problems = append(problems, cfg.Signers.validate(p)...)
+
+	return problems
+}
+
+// validate checks jc, looking up the secrets that its key references in p.
+// If p is nil, because the secrets providers aren't valid, keys that
+// reference secrets aren't checked.
+func (jc *JWTConfig) validate(p secrets.Provider) []string {
+	var problems []string
+
+	keys := 0
+	for _, key := range []string{jc.HMACSecret, jc.PublicKeyFile, jc.JWKSURL} {
+		if key != "" {
+			keys++
+		}
+	}
+	if keys > 1 {
+		problems = append(problems,
+			"jwt: only one of hmac_secret, public_key_file and jwks_url can be set")
+	}
+	if jc.JWKSURL != "" {
+		if err := checkURL(jc.JWKSURL); err != nil {
+			problems = append(problems, fmt.Sprintf("jwt.jwks_url: %v", err))
+		}
+	}
+	if keys == 1 && jc.JWKSURL == "" && (p != nil || len(secrets.References(jc.HMACSecret)) == 0) {
+		if _, err := jc.verifier(p); err != nil {
+			problems = append(problems, fmt.Sprintf("jwt: %v", err))
+		}
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range jc.AnonymousAllow {
+		allowed[name] = true
+	}
+	for _, name := range jc.AnonymousDeny {
+		if allowed[name] {
+			problems = append(problems, fmt.Sprintf(
+				"jwt.anonymous_deny: %s is also in jwt.anonymous_allow", name))
+		}
+	}
+
+	policy, err := jc.anonymousPolicy()
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("jwt.anonymous: %v", err))
+	} else if keys == 0 && policy.Restricts() {
+		problems = append(problems, "jwt.anonymous: every request is anonymous unless "+
+			"hmac_secret, public_key_file or jwks_url is set")
+	}
+
+	return problems
+}
+
+// tlsConfig returns the TLS configuration that /graphql is served with, or
+// nil if cfg.TLSDir isn't set.  The directory is laid out as "dgraph cert"
+// writes it, as for alpha: the server's node.crt and node.key, and the ca.crt
+// that client certificates are verified with.  Unlike alpha, the system's CAs
+// aren't trusted for client certificates, so only those issued by ca.crt pass.
+func (cfg *Config) tlsConfig() (*tls.Config, error) {
+	if cfg.TLSDir == "" {
+		return nil, nil
+	}
+	return x.GenerateServerTLSConfig(&x.TLSHelperConfig{
+		CertDir:      cfg.TLSDir,
+		CertRequired: true,
+		Cert:         filepath.Join(cfg.TLSDir, "node.crt"),
+		Key:          filepath.Join(cfg.TLSDir, "node.key"),
+		RootCACert:   filepath.Join(cfg.TLSDir, "ca.crt"),
+		ClientAuth:   cfg.TLSClientAuth,
+	})
+}
+
+// checkURL checks that u is an absolute http or https URL.
+func checkURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.Errorf("%q isn't an http or https URL", u)
+	}
+	return nil
+}
+
+// checkOrigin checks that origin is an http or https origin, like
+// https://app.example.com or https://*.example.com:8443.
+func checkOrigin(origin string) error {
+	parsed, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") ||
+		parsed.Host == "" || parsed.Path != "" || parsed.RawQuery != "" || parsed.User != nil {
+		return errors.Errorf("%q isn't an origin, like https://app.example.com", origin)
+	}
+	return nil
+}
+
+// corsHeaders returns the headers that cross-origin requests can send by
+// default: web.DefaultCORSHeaders, and the extra headers, like the JWT
+// header, that aren't among them.
+func corsHeaders(extra ...string) []string {
+	headers := append([]string{}, web.DefaultCORSHeaders...)
+	for _, e := range extra {
+		known := false
+		for _, h := range headers {
+			known = known || strings.EqualFold(h, e)
+		}
+		if !known {
+			headers = append(headers, e)
+		}
+	}
+	return headers
+}
+
+// parseRemote parses a --remote flag value like "payments=http://..." or
+// "payments:Pay=http://...".
+func parseRemote(spec string) (schema.RemoteAPI, error) {
+	eq := strings.Index(spec, "=")
+	if eq <= 0 || eq == len(spec)-1 {
+		return schema.RemoteAPI{}, errors.Errorf(
+			"invalid remote API %q: expected field=url or field:Prefix=url", spec)
+	}
+
+	remote := schema.RemoteAPI{Field: spec[:eq], URL: spec[eq+1:]}
+	if colon := strings.Index(remote.Field, ":"); colon >= 0 {
+		remote.Prefix = remote.Field[colon+1:]
+		remote.Field = remote.Field[:colon]
+	}
+	if remote.Field == "" {
+		return schema.RemoteAPI{}, errors.Errorf("invalid remote API %q: no field name", spec)
+	}
+	if remote.Prefix == "" {
+		remote.Prefix = strings.ToUpper(remote.Field[:1]) + remote.Field[1:]
+	}
+	return remote, nil
+}
+
+// configCmd is "dgraph graphql config", which groups commands for working
+// with the configuration.
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the GraphQL server configuration",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "check",
+		Short: "Check the GraphQL server configuration and print the settings in effect",
+		Long: `
+Reads the configuration just as "dgraph graphql" would - from the config file
+given with --config and from DGRAPH_GRAPHQL_* environment variables - and
+reports every problem with it.  If there are none, the settings that would be
+used are printed as JSON.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(GraphQL.Conf)
+			if err != nil {
+				return err
+			}
+
+			out, err := json.MarshalIndent(cfg, "", "  ")
+			if err != nil {
+				return errors.Wrap(err, "while printing the configuration")
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(out))
+			return nil
+		},
+	})
+	return cmd
+}
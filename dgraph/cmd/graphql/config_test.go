@@ -0,0 +1,610 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/signing"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func testConf(t *testing.T, yaml string) *viper.Viper {
+	dir, err := ioutil.TempDir("", "graphql-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.yml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(yaml), 0644))
+
+	conf := viper.New()
+	conf.SetDefault("alpha", "127.0.0.1:9080")
+	conf.SetDefault("port", 9000)
+	conf.SetDefault("ui", true)
+	conf.SetDefault("schema_check", "warn")
+	conf.SetConfigFile(file)
+	require.NoError(t, conf.ReadInConfig())
+	return conf
+}
+
+func TestLoadConfig(t *testing.T) {
+	conf := testConf(t, `
+port: 8080
+schema_poll_interval: 1m
+schema_check: fail
+schema_webhook: http://hooks/schema
+allowlist: true
+allowlist_introspection: true
+trace: 0.5
+explain: true
+remote:
+  - payments=http://payments/graphql
+  - users:Acct=https://users/graphql
+lambda:
+  url: http://lambda:8686/graphql-worker
+  signer: Lambda
+subscriptions:
+  poll_interval: 5s
+batch:
+  concurrent: true
+limits:
+  max_depth: 10
+  max_complexity: 5000
+  max_cost: 100000
+  report_cost: true
+retries:
+  writes:
+    max_attempts: 5
+    initial_backoff: 10ms
+events:
+  max_attempts: 20
+reads:
+  best_effort: true
+  replicas: alpha2:9080,alpha3:9080
+timeouts:
+  query: 10s
+  mutation: 30s
+compression:
+  min_size: 4096
+uploads:
+  dir: /data/uploads
+  url: /files
+cors:
+  allowed_origins:
+    - https://app.example.com
+    - https://*.example.org
+  allow_credentials: true
+request_log:
+  sample_rate: 0.1
+  errors: true
+  redact: [password, ssn]
+audit:
+  webhook: http://audit/entries
+  dgraph: true
+  actor_claim: email
+cdc:
+  kafka_brokers:
+    - kafka1:9092
+    - kafka2:9092
+  topic_prefix: blog.
+rate_limit:
+  requests_per_second: 50
+  writes_per_second: 5
+  write_burst: 20
+  api_key_header: X-Api-Key
+namespaces:
+  claim: tenant
+  alphas:
+    acme: acme-alpha:9080
+    globex: globex-alpha1:9080,globex-alpha2:9080
+introspection:
+  mode: admin
+  role_claim: roles
+jwt:
+  header: X-Auth-Token
+  namespace: https://example.com/claims
+  hmac_secret: sssh
+  anonymous: [query]
+  anonymous_allow: [addComment]
+  anonymous_deny: [queryAuditLog]
+secrets:
+  providers: [vault, env]
+  env_prefix: BLOG_
+  vault:
+    addr: https://vault:8200
+    token: s.vaulttoken
+    path: secret/data/graphql
+signers:
+  lambda:
+    type: hmac
+    secret: lambda-key
+  payments:
+    type: oauth2
+    token_url: https://auth/token
+    client_id: dgraph
+    client_secret: oauth-secret
+    scopes: [payments]
+calls:
+  default:
+    timeout: 5s
+    max_retries: 1
+  hosts:
+    - host: Payments.Internal:8443
+      timeout: 30s
+      breaker_threshold: 0
+    - host: lambda:8686
+      max_backoff: 1s
+`)
+
+	cfg, err := loadConfig(conf)
+	require.NoError(t, err)
+	require.Equal(t, &Config{
+		Alpha:              "127.0.0.1:9080",
+		Port:               8080,
+		SchemaPollInterval: time.Minute,
+		SchemaCheck:        "fail",
+		SchemaWebhook:      "http://hooks/schema",
+		Remotes: []schema.RemoteAPI{
+			{Field: "payments", Prefix: "Payments", URL: "http://payments/graphql"},
+			{Field: "users", Prefix: "Acct", URL: "https://users/graphql"},
+		},
+		UI:                     true,
+		Allowlist:              true,
+		AllowlistIntrospection: true,
+		Trace:                  0.5,
+		Explain:                true,
+		Lambda: LambdaConfig{URL: "http://lambda:8686/graphql-worker",
+			Signer: "lambda"},
+		Subscriptions: SubscriptionConfig{PollInterval: 5 * time.Second},
+		Batch:         BatchConfig{Concurrent: true},
+		Limits: LimitsConfig{MaxDepth: 10, MaxComplexity: 5000, MaxCost: 100000,
+			ReportCost: true},
+		Retries: RetriesConfig{
+			Reads: RetryConfig{MaxAttempts: 3, InitialBackoff: 50 * time.Millisecond,
+				MaxBackoff: time.Second},
+			Writes: RetryConfig{MaxAttempts: 5, InitialBackoff: 10 * time.Millisecond,
+				MaxBackoff: time.Second},
+		},
+		Events: RetryConfig{MaxAttempts: 20, InitialBackoff: time.Second,
+			MaxBackoff: time.Minute},
+		Reads:    ReadsConfig{BestEffort: true, Replicas: "alpha2:9080,alpha3:9080"},
+		Timeouts: TimeoutsConfig{Query: 10 * time.Second, Mutation: 30 * time.Second},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+			AllowedHeaders: []string{
+				"Content-Type", "Authorization", "X-Request-Id", "X-Request-Timeout",
+				"X-Auth-Token", "X-Api-Key"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+		Compression: CompressionConfig{Gzip: true, MinSize: 4096},
+		Uploads:     UploadsConfig{Dir: "/data/uploads", URL: "/files/"},
+		RequestLog: RequestLogConfig{
+			SampleRate: 0.1,
+			Errors:     true,
+			Redact:     []string{"password", "ssn"},
+		},
+		Audit: AuditConfig{
+			Webhook:    "http://audit/entries",
+			Dgraph:     true,
+			Redact:     []string{"password", "ssn"},
+			ActorClaim: "email",
+		},
+		CDC: CDCConfig{
+			KafkaBrokers: []string{"kafka1:9092", "kafka2:9092"},
+			TopicPrefix:  "blog.",
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: 50,
+			WritesPerSecond:   5,
+			WriteBurst:        20,
+			APIKeyHeader:      "X-Api-Key",
+		},
+		Namespaces: NamespacesConfig{
+			Header: "X-Dgraph-Namespace",
+			Claim:  "tenant",
+			Alphas: map[string]string{
+				"acme":   "acme-alpha:9080",
+				"globex": "globex-alpha1:9080,globex-alpha2:9080",
+			},
+		},
+		Introspection: IntrospectionConfig{Mode: "admin", RoleClaim: "roles", AdminRole: "admin"},
+		JWT: JWTConfig{
+			Header:         "X-Auth-Token",
+			Namespace:      "https://example.com/claims",
+			HMACSecret:     "sssh",
+			Anonymous:      []string{"query"},
+			AnonymousAllow: []string{"addComment"},
+			AnonymousDeny:  []string{"queryAuditLog"},
+		},
+		Secrets: SecretsConfig{
+			Providers: []string{"vault", "env"},
+			EnvPrefix: "BLOG_",
+			Vault: VaultConfig{Addr: "https://vault:8200", Token: "s.vaulttoken",
+				Path: "secret/data/graphql"},
+		},
+		Signers: SignersConfig{
+			"lambda": {Type: "hmac", Secret: "lambda-key"},
+			"payments": {Type: "oauth2", TokenURL: "https://auth/token", ClientID: "dgraph",
+				ClientSecret: "oauth-secret", Scopes: []string{"payments"}},
+		},
+		Calls: CallsConfig{
+			Default: CallPolicyConfig{Timeout: 5 * time.Second, MaxRetries: 1,
+				Backoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second,
+				BreakerThreshold: 5, BreakerCooldown: 30 * time.Second},
+			Hosts: []HostCallPolicyConfig{
+				{Host: "payments.internal:8443", CallPolicyConfig: CallPolicyConfig{
+					Timeout: 30 * time.Second, MaxRetries: 1, Backoff: 100 * time.Millisecond,
+					MaxBackoff: 2 * time.Second, BreakerCooldown: 30 * time.Second}},
+				{Host: "lambda:8686", CallPolicyConfig: CallPolicyConfig{
+					Timeout: 5 * time.Second, MaxRetries: 1, Backoff: 100 * time.Millisecond,
+					MaxBackoff: time.Second, BreakerThreshold: 5,
+					BreakerCooldown: 30 * time.Second}},
+			},
+		},
+	}, cfg)
+
+	ps := cfg.Calls.policies()
+	require.Equal(t, 30*time.Second, ps.For("https://payments.internal:8443/charge").Timeout)
+	require.Equal(t, 5*time.Second, ps.For("https://payments.internal/charge").Timeout)
+
+	js, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.NotContains(t, string(js), "sssh", "the JWT secret isn't printed")
+	require.Contains(t, string(js), `"hmac_secret":"\u003credacted\u003e"`)
+	require.NotContains(t, string(js), "vaulttoken", "the Vault token isn't printed")
+	require.NotContains(t, string(js), "lambda-key", "signers' secrets aren't printed")
+	require.NotContains(t, string(js), "oauth-secret", "signers' secrets aren't printed")
+	require.Contains(t, string(js), `"initial_backoff":"10ms"`)
+	require.Contains(t, string(js), `"mutation":"30s"`)
+	require.Contains(t, string(js), `"max_age":"10m0s"`)
+	require.Contains(t, string(js), `"host":"payments.internal:8443","timeout":"30s"`)
+}
+
+func TestLoadConfigDefaultJWT(t *testing.T) {
+	cfg, err := loadConfig(testConf(t, "port: 8080"))
+	require.NoError(t, err)
+	require.Equal(t, JWTConfig{
+		Header:    "Authorization",
+		Anonymous: []string{"query", "mutation", "subscription"},
+	}, cfg.JWT)
+
+	verifier, err := cfg.JWT.verifier(cfg.Secrets.provider())
+	require.NoError(t, err)
+	require.Nil(t, verifier, "no key means no JWTs")
+	require.Equal(t, SecretsConfig{Providers: []string{"env"},
+		EnvPrefix: "DGRAPH_GRAPHQL_SECRET_"}, cfg.Secrets)
+
+	require.Empty(t, cfg.CORS.AllowedOrigins, "no cross-origin requests by default")
+	require.Equal(t, CompressionConfig{Gzip: true, MinSize: 1024}, cfg.Compression)
+	require.Equal(t, UploadsConfig{URL: "/uploads/"}, cfg.Uploads)
+	require.Equal(t, []string{"Content-Type", "Authorization", "X-Request-Id",
+		"X-Request-Timeout"}, cfg.CORS.AllowedHeaders)
+	require.Equal(t, NamespacesConfig{Header: "X-Dgraph-Namespace"}, cfg.Namespaces)
+	require.Equal(t, CDCConfig{TopicPrefix: "graphql."}, cfg.CDC)
+	require.Equal(t, IntrospectionConfig{Mode: "enabled", RoleClaim: "role",
+		AdminRole: "admin"}, cfg.Introspection)
+}
+
+func TestLoadConfigIntrospectionNeedsJWT(t *testing.T) {
+	_, err := loadConfig(testConf(t, `
+introspection:
+  mode: authenticated
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "introspection.mode: authenticated needs JWTs")
+}
+
+func TestLoadConfigNamespaceHeader(t *testing.T) {
+	cfg, err := loadConfig(testConf(t, `
+namespaces:
+  header: X-Tenant
+  alphas:
+    acme: acme-alpha:9080
+`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"acme"}, cfg.Namespaces.names())
+	require.Equal(t, []string{"Content-Type", "Authorization", "X-Request-Id",
+		"X-Request-Timeout", "X-Tenant"}, cfg.CORS.AllowedHeaders,
+		"browsers can send the namespace header")
+}
+
+func TestLoadConfigJWTSecretFromVault(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" || r.URL.Path != "/v1/secret/graphql" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"JWT_KEY": "from-vault"}}`))
+	}))
+	defer vault.Close()
+
+	cfg, err := loadConfig(testConf(t, `
+jwt:
+  hmac_secret: "{{secrets.JWT_KEY}}"
+secrets:
+  providers: [vault]
+  vault:
+    addr: `+vault.URL+`
+    token: s.token
+    path: secret/graphql
+`))
+	require.NoError(t, err)
+
+	verifier, err := cfg.JWT.verifier(cfg.Secrets.provider())
+	require.NoError(t, err)
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256,
+		jwt.MapClaims{"USER": "alice"}).SignedString([]byte("from-vault"))
+	require.NoError(t, err)
+	claims, err := verifier.Verify(signed)
+	require.NoError(t, err)
+	require.Equal(t, "alice", claims["USER"])
+
+	_, err = loadConfig(testConf(t, `
+jwt:
+  hmac_secret: "{{secrets.NO_SUCH_KEY}}"
+secrets:
+  providers: [vault]
+  vault:
+    addr: `+vault.URL+`
+    token: s.token
+    path: secret/graphql
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `jwt: while looking up hmac_secret: `+
+		`while resolving secret "NO_SUCH_KEY": secret not found`)
+}
+
+func TestLoadConfigSigners(t *testing.T) {
+	var verified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if signing.VerifyHMAC(r, "from-env", time.Minute) == nil {
+			verified++
+		}
+	}))
+	defer srv.Close()
+
+	require.NoError(t, os.Setenv("DGRAPH_GRAPHQL_SECRET_LAMBDA_KEY", "from-env"))
+	defer os.Unsetenv("DGRAPH_GRAPHQL_SECRET_LAMBDA_KEY")
+	cfg, err := loadConfig(testConf(t, `
+signers:
+  lambda:
+    type: hmac
+    secret: "{{secrets.LAMBDA_KEY}}"
+`))
+	require.NoError(t, err)
+
+	signers, err := cfg.Signers.signers(cfg.Secrets.provider())
+	require.NoError(t, err)
+	client := external.NewClient(nil).WithSigner(signers["lambda"])
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{}`))
+	require.NoError(t, err)
+	_, err = client.Do(req, external.DefaultPolicy)
+	require.NoError(t, err)
+	require.Equal(t, 1, verified, "the call is signed with the secret from the environment")
+
+	_, err = loadConfig(testConf(t, `
+signers:
+  lambda:
+    type: hmac
+    secret: "{{secrets.NO_SUCH_KEY}}"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `signers.lambda: while looking up secret: `+
+		`while resolving secret "NO_SUCH_KEY": secret not found`)
+}
+
+func TestLoadConfigTLS(t *testing.T) {
+	cfg, err := loadConfig(testConf(t, `
+tls_dir: ../../../tlstest/tls
+tls_client_auth: REQUIREANDVERIFY
+`))
+	require.NoError(t, err)
+
+	tlsCfg, err := cfg.tlsConfig()
+	require.NoError(t, err)
+	require.Len(t, tlsCfg.Certificates, 1)
+	require.Equal(t, tls.RequireAndVerifyClientCert, tlsCfg.ClientAuth)
+	require.Len(t, tlsCfg.ClientCAs.Subjects(), 1, "only ca.crt issues client certificates")
+
+	cfg, err = loadConfig(testConf(t, "port: 8080"))
+	require.NoError(t, err)
+	tlsCfg, err = cfg.tlsConfig()
+	require.NoError(t, err)
+	require.Nil(t, tlsCfg, "no tls_dir means plain HTTP")
+
+	_, err = loadConfig(testConf(t, `
+tls_dir: ../../../tlstest/tls
+tls_client_auth: ALWAYS
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tls_dir: Invalid client auth")
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	conf := testConf(t, `
+port: 70000
+tls_dir: /no/such/tls
+schema: /no/such/schema.graphql
+graphql_schema_file: /no/such/dev.graphql
+schema_poll_interval: -1s
+schema_check: strict
+schema_webhook: hooks/schema
+ui_assets: /no/such/ui
+trace: 2
+remote:
+  - payments
+  - users=http://users/graphql
+  - users=http://other-users/graphql
+lambda:
+  url: lambda:8686
+  signer: nosuch
+subscriptions:
+  poll_interval: 0s
+limits:
+  max_fields: -1
+  max_cost: -1
+retries:
+  reads:
+    max_attempts: 0
+  writes:
+    max_backoff: -1s
+events:
+  initial_backoff: -1s
+reads:
+  replicas: alpha2:9080,
+timeouts:
+  subscription: -1s
+compression:
+  min_size: -1
+uploads:
+  url: cdn.example.com/files
+cors:
+  allowed_origins:
+    - "*"
+    - app.example.com
+    - https://example.com/app
+  allowed_headers: [Content-Type, "X-A, X-B"]
+  allow_credentials: true
+  max_age: -1m
+request_log:
+  sample_rate: -0.5
+audit:
+  file: /no/such/dir/audit.log
+  webhook: audit/entries
+  actor_claim: ""
+cdc:
+  kafka_brokers: [kafka1]
+  topic_prefix: graphql/
+rate_limit:
+  requests_per_second: -1
+  write_burst: -5
+namespaces:
+  header: ""
+  alphas:
+    acme: ""
+introspection:
+  mode: public
+jwt:
+  hmac_secret: sssh
+  jwks_url: example.com/jwks.json
+  anonymous: [query, delete]
+  anonymous_allow: [queryPost]
+  anonymous_deny: [queryPost]
+secrets:
+  providers: [vault, consul]
+  vault:
+    addr: vault:8200
+signers:
+  hooks:
+    type: rsa
+  lambda:
+    type: hmac
+  payments:
+    type: oauth2
+    token_url: auth/token
+calls:
+  default:
+    timeout: -1s
+    max_retries: -1
+  hosts:
+    - timeout: 1s
+    - host: https://payments/
+    - host: lambda:8686
+    - host: LAMBDA:8686
+      breaker_threshold: -1
+`)
+
+	_, err := loadConfig(conf)
+	require.Error(t, err)
+	for _, problem := range []string{
+		`invalid remote API "payments": expected field=url or field:Prefix=url`,
+		"port: 70000 isn't a valid port",
+		"tls_dir: open /no/such/tls/node.crt: no such file or directory",
+		"schema: stat /no/such/schema.graphql: no such file or directory",
+		"graphql_schema_file: stat /no/such/dev.graphql: no such file or directory",
+		"graphql_schema_file: can't be used with schema",
+		"schema_poll_interval: can't be negative",
+		"ui_assets: stat /no/such/ui/graphiql.css: no such file or directory",
+		"ui_assets: stat /no/such/ui/graphiql.min.js: no such file or directory",
+		`schema_check: "strict" isn't a schema check; expected warn, fail or off`,
+		"trace: 2 isn't a ratio between 0 and 1",
+		`schema_webhook: "hooks/schema" isn't an http or https URL`,
+		"remote: field users is used for more than one remote API",
+		`lambda.url: "lambda:8686" isn't an http or https URL`,
+		"lambda.signer: there's no signer nosuch in signers",
+		`signers.hooks.type: "rsa" isn't a signer type; expected hmac or oauth2`,
+		"signers.lambda.secret: is needed for an hmac signer",
+		`signers.payments.token_url: "auth/token" isn't an http or https URL`,
+		"signers.payments: client_id and client_secret are needed for an oauth2 signer",
+		"subscriptions.poll_interval: must be positive",
+		"limits.max_fields: can't be negative",
+		"limits.max_cost: can't be negative",
+		"retries.reads.max_attempts: must be at least 1",
+		"retries.writes.max_backoff: can't be negative",
+		"events.initial_backoff: can't be negative",
+		"calls.default.timeout: can't be negative",
+		"calls.default.max_retries: can't be negative",
+		"calls.hosts[0].host: can't be empty",
+		`calls.hosts[1].host: "https://payments/" should be a host[:port], not a URL`,
+		"calls.hosts[3].host: lambda:8686 has more than one policy",
+		"calls.hosts[3].breaker_threshold: can't be negative",
+		`reads.replicas: "alpha2:9080," has an empty address`,
+		"timeouts.subscription: can't be negative",
+		`cors.allow_credentials: can't be used when cors.allowed_origins has "*"`,
+		`cors.allowed_origins: "app.example.com" isn't an origin`,
+		`cors.allowed_origins: "https://example.com/app" isn't an origin`,
+		`cors.allowed_headers: "X-A, X-B" isn't a header name`,
+		"cors.max_age: can't be negative",
+		"compression.min_size: can't be negative",
+		`uploads.url: "cdn.example.com/files" isn't an http or https URL`,
+		"request_log.sample_rate: -0.5 isn't a ratio between 0 and 1",
+		"audit.file: stat /no/such/dir: no such file or directory",
+		`audit.webhook: "audit/entries" isn't an http or https URL`,
+		"audit.actor_claim: can't be empty",
+		`cdc.kafka_brokers: "kafka1" isn't a host:port address`,
+		`cdc.topic_prefix: "graphql/" can only have letters, digits, '.', '_' and '-'`,
+		"rate_limit.requests_per_second: can't be negative",
+		"rate_limit.write_burst: can't be negative",
+		"namespaces.header: can't be empty",
+		"namespaces.alphas: namespace acme has no alpha addresses",
+		`introspection.mode: "public" isn't an introspection mode`,
+		"jwt: only one of hmac_secret, public_key_file and jwks_url can be set",
+		`jwt.jwks_url: "example.com/jwks.json" isn't an http or https URL`,
+		`jwt.anonymous: "delete" isn't a kind of operation`,
+		`secrets.providers: "consul" isn't a secrets provider; they're env and vault`,
+		`secrets.vault.addr: "vault:8200" isn't an http or https URL`,
+		"secrets.vault.token: is needed for the vault provider",
+		"secrets.vault.path: is needed for the vault provider",
+		"jwt.anonymous_deny: queryPost is also in jwt.anonymous_allow",
+	} {
+		require.Contains(t, err.Error(), problem)
+	}
+}
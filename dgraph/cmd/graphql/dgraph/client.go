@@ -0,0 +1,290 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dgraph is the GraphQL layer's view of Dgraph.  The resolvers only
+// ever talk to Dgraph through the Client and Txn interfaces here, so they can
+// be tested without a running cluster.  Queries, mutations and commits are
+// OpenCensus spans, which the gRPC connection carries on to Dgraph.
+package dgraph
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	otrace "go.opencensus.io/trace"
+)
+
+// Client can run queries and start transactions against Dgraph.
+type Client interface {
+	// Query runs query in a read-only transaction and returns the JSON result.
+	Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error)
+
+	// NewTxn starts a new read-write transaction.
+	NewTxn() Txn
+
+	// Alter applies schema, e.g. the schema generated from a GraphQL schema,
+	// to Dgraph.
+	Alter(ctx context.Context, schema string) error
+
+	// Schema returns Dgraph's schema for predicates.  Predicates that Dgraph
+	// doesn't have are left out.
+	Schema(ctx context.Context, predicates []string) ([]*api.SchemaNode, error)
+
+	// DescribeSchema returns all of Dgraph's schema: every predicate and
+	// every type.
+	DescribeSchema(ctx context.Context) (*FullSchema, error)
+}
+
+// FullSchema is all of Dgraph's schema, as a schema query returns it.
+type FullSchema struct {
+	Predicates []*api.SchemaNode `json:"schema"`
+	Types      []*TypeNode       `json:"types"`
+}
+
+// A TypeNode is a type in Dgraph's schema.
+type TypeNode struct {
+	Name   string       `json:"name"`
+	Fields []*TypeField `json:"fields"`
+}
+
+// A TypeField is a field of a type in Dgraph's schema: a predicate, and its
+// type in the type, e.g. "string", "[uid]" or "[Person]".
+type TypeField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Txn is a Dgraph read-write transaction.  As with dgo transactions, a Txn
+// must always be discarded, even after it's been committed.
+type Txn interface {
+	// Query runs query in the transaction and returns the JSON result.
+	Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error)
+
+	// Mutate runs mut in the transaction and returns the map of blank node
+	// names to assigned uids.
+	Mutate(ctx context.Context, mut *api.Mutation) (map[string]string, error)
+
+	Commit(ctx context.Context) error
+	Discard(ctx context.Context) error
+}
+
+type dgoClient struct {
+	dg *dgo.Dgraph
+
+	// reads runs the queries that aren't in a transaction; it's dg unless
+	// they're routed to replicas.
+	reads      *dgo.Dgraph
+	bestEffort bool
+}
+
+type dgoTxn struct {
+	txn *dgo.Txn
+}
+
+// AsDgraph wraps a dgo client as a Client.
+func AsDgraph(dg *dgo.Dgraph) Client {
+	return &dgoClient{dg: dg, reads: dg}
+}
+
+// ReadRouting says where, and how, a Client runs the queries that aren't in
+// a transaction: the ones that answer GraphQL queries and subscriptions.
+// Transactions, and so mutations, always run against the Client's own
+// alphas, as do the queries of Dgraph's schema.
+type ReadRouting struct {
+	// BestEffort runs the queries as best-effort queries: the alpha answers
+	// at the latest timestamp it knows of, instead of getting one from Zero,
+	// so a query might not see the very latest commits.
+	BestEffort bool
+
+	// Replicas, if it isn't nil, runs the queries instead, e.g. with
+	// connections to the alphas that aren't their groups' leaders.  Unless
+	// the queries are BestEffort too, each still gets a timestamp from Zero.
+	Replicas *dgo.Dgraph
+}
+
+// AsDgraphWithReads wraps a dgo client as a Client whose queries are routed
+// as reads says.
+func AsDgraphWithReads(dg *dgo.Dgraph, reads ReadRouting) Client {
+	c := &dgoClient{dg: dg, reads: dg, bestEffort: reads.BestEffort}
+	if reads.Replicas != nil {
+		c.reads = reads.Replicas
+	}
+	return c
+}
+
+// readTxn starts a read-only transaction for a query that isn't in a
+// transaction of its own.
+func (c *dgoClient) readTxn() *dgo.Txn {
+	txn := c.reads.NewReadOnlyTxn()
+	if c.bestEffort {
+		txn.BestEffort()
+	}
+	return txn
+}
+
+func (c *dgoClient) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	ctx, span := otrace.StartSpan(ctx, "dgraph.query")
+	defer span.End()
+
+	q := AsString(query)
+	if glog.V(3) {
+		glog.Infof("Executing Dgraph query: \n%s\n", q)
+	}
+
+	var resp *api.Response
+	var err error
+	if reads, ok := ctx.Value(sharedReadsKey{}).(*sharedReads); ok {
+		resp, err = reads.query(ctx, c, q)
+	} else {
+		resp, err = c.readTxn().Query(ctx, q)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "while querying Dgraph")
+	}
+	return resp.GetJson(), nil
+}
+
+type sharedReadsKey struct{}
+
+// sharedReads is the read-only transaction that the queries run with a
+// context from WithSharedReads share.  A dgo transaction can't be used by
+// more than one goroutine at once, so the queries take turns.
+type sharedReads struct {
+	mu  sync.Mutex
+	txn *dgo.Txn
+}
+
+// WithSharedReads returns a context in which all the queries that a Client
+// runs read the same snapshot of Dgraph: the one that's current when the
+// first of them runs.  That's how the queries sent together in a batch see
+// consistent data.  Queries in a Txn aren't affected.  If ctx's queries
+// already share their reads, ctx is returned as it is.
+func WithSharedReads(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(sharedReadsKey{}).(*sharedReads); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, sharedReadsKey{}, &sharedReads{})
+}
+
+func (sr *sharedReads) query(ctx context.Context, c *dgoClient,
+	q string) (*api.Response, error) {
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if sr.txn == nil {
+		sr.txn = c.readTxn()
+	}
+	return sr.txn.Query(ctx, q)
+}
+
+func (c *dgoClient) NewTxn() Txn {
+	return &dgoTxn{txn: c.dg.NewTxn()}
+}
+
+func (c *dgoClient) Alter(ctx context.Context, schema string) error {
+	if glog.V(3) {
+		glog.Infof("Altering Dgraph schema: \n%s\n", schema)
+	}
+
+	err := c.dg.Alter(ctx, &api.Operation{Schema: schema})
+	return errors.Wrap(err, "while altering Dgraph schema")
+}
+
+func (c *dgoClient) Schema(ctx context.Context,
+	predicates []string) ([]*api.SchemaNode, error) {
+
+	if len(predicates) == 0 {
+		return nil, nil
+	}
+	q := "schema(pred: [" + strings.Join(predicates, ", ") + "]) " +
+		"{ type index tokenizer list }"
+
+	resp, err := c.dg.NewReadOnlyTxn().Query(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "while querying Dgraph's schema")
+	}
+
+	var result struct {
+		Schema []*api.SchemaNode `json:"schema"`
+	}
+	if err := json.Unmarshal(resp.GetJson(), &result); err != nil {
+		return nil, errors.Wrap(err, "while reading Dgraph's schema")
+	}
+	return result.Schema, nil
+}
+
+func (c *dgoClient) DescribeSchema(ctx context.Context) (*FullSchema, error) {
+	resp, err := c.dg.NewReadOnlyTxn().Query(ctx, "schema {}")
+	if err != nil {
+		return nil, errors.Wrap(err, "while querying Dgraph's schema")
+	}
+
+	var result FullSchema
+	if err := json.Unmarshal(resp.GetJson(), &result); err != nil {
+		return nil, errors.Wrap(err, "while reading Dgraph's schema")
+	}
+	return &result, nil
+}
+
+func (t *dgoTxn) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	ctx, span := otrace.StartSpan(ctx, "dgraph.query")
+	defer span.End()
+
+	q := AsString(query)
+	if glog.V(3) {
+		glog.Infof("Executing Dgraph query: \n%s\n", q)
+	}
+
+	resp, err := t.txn.Query(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "while querying Dgraph")
+	}
+	return resp.GetJson(), nil
+}
+
+func (t *dgoTxn) Mutate(ctx context.Context, mut *api.Mutation) (map[string]string, error) {
+	ctx, span := otrace.StartSpan(ctx, "dgraph.mutate")
+	defer span.End()
+
+	if glog.V(3) {
+		glog.Infof("Executing Dgraph mutation; set: %s, delete: %s",
+			mut.GetSetJson(), mut.GetDeleteJson())
+	}
+
+	assigned, err := t.txn.Mutate(ctx, mut)
+	if err != nil {
+		return nil, errors.Wrap(err, "while mutating Dgraph")
+	}
+	return assigned.GetUids(), nil
+}
+
+func (t *dgoTxn) Commit(ctx context.Context) error {
+	ctx, span := otrace.StartSpan(ctx, "dgraph.commit")
+	defer span.End()
+
+	return errors.Wrap(t.txn.Commit(ctx), "while committing Dgraph transaction")
+}
+
+func (t *dgoTxn) Discard(ctx context.Context) error {
+	return t.txn.Discard(ctx)
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// recordingAlpha records the requests sent to it.  It only answers queries
+// and mutations.
+type recordingAlpha struct {
+	api.DgraphClient
+	queries   []*api.Request
+	mutations int
+}
+
+func (a *recordingAlpha) Query(ctx context.Context, in *api.Request,
+	opts ...grpc.CallOption) (*api.Response, error) {
+
+	a.queries = append(a.queries, in)
+	return &api.Response{Json: []byte(`{}`)}, nil
+}
+
+func (a *recordingAlpha) Mutate(ctx context.Context, in *api.Mutation,
+	opts ...grpc.CallOption) (*api.Assigned, error) {
+
+	a.mutations++
+	return &api.Assigned{}, nil
+}
+
+func TestReadRouting(t *testing.T) {
+	leader, replica := &recordingAlpha{}, &recordingAlpha{}
+	client := AsDgraphWithReads(dgo.NewDgraphClient(leader), ReadRouting{
+		BestEffort: true,
+		Replicas:   dgo.NewDgraphClient(replica),
+	})
+	query := &gql.GraphQuery{Attr: "q", Func: &gql.Function{Name: "uid", UID: []uint64{1}}}
+
+	_, err := client.Query(context.Background(), query)
+	require.NoError(t, err)
+	ctx := WithSharedReads(context.Background())
+	_, err = client.Query(ctx, query)
+	require.NoError(t, err)
+
+	require.Empty(t, leader.queries)
+	require.Len(t, replica.queries, 2)
+	for _, req := range replica.queries {
+		require.True(t, req.ReadOnly)
+		require.True(t, req.BestEffort)
+	}
+
+	txn := client.NewTxn()
+	_, err = txn.Query(context.Background(), query)
+	require.NoError(t, err)
+	_, err = txn.Mutate(context.Background(), &api.Mutation{SetJson: []byte(`{}`)})
+	require.NoError(t, err)
+
+	require.Len(t, leader.queries, 1, "transactions aren't routed to replicas")
+	require.False(t, leader.queries[0].BestEffort)
+	require.Equal(t, 1, leader.mutations)
+	require.Equal(t, 0, replica.mutations)
+}
+
+func TestDefaultReads(t *testing.T) {
+	alpha := &recordingAlpha{}
+	client := AsDgraph(dgo.NewDgraphClient(alpha))
+
+	_, err := client.Query(context.Background(), &gql.GraphQuery{Attr: "q"})
+	require.NoError(t, err)
+	require.Len(t, alpha.queries, 1)
+	require.True(t, alpha.queries[0].ReadOnly)
+	require.False(t, alpha.queries[0].BestEffort)
+}
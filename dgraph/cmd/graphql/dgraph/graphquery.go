@@ -0,0 +1,260 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/protos/pb"
+)
+
+// AsString writes query as an indented GraphQL+- query string.  AsString
+// doesn't validate query, and so doesn't return an error if query is
+// 'malformed' - it might just write something that wouldn't parse as a Dgraph
+// query.
+func AsString(query *gql.GraphQuery) string {
+	if query == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("query {\n")
+	writeQuery(&b, query, "  ", true)
+	b.WriteString("}")
+
+	return b.String()
+}
+
+func writeQuery(b *strings.Builder, query *gql.GraphQuery, prefix string, root bool) {
+	b.WriteString(prefix)
+	if query.Alias != "" {
+		b.WriteString(query.Alias)
+		b.WriteString(" : ")
+	}
+	if query.Var != "" {
+		b.WriteString(query.Var)
+		b.WriteString(" as ")
+	}
+	switch {
+	case query.MathExp != nil:
+		b.WriteString("math(")
+		writeMath(b, query.MathExp)
+		b.WriteString(")")
+	case query.IsCount:
+		b.WriteString("count(" + query.Attr + ")")
+	default:
+		b.WriteString(query.Attr)
+	}
+
+	if root {
+		b.WriteString("(func: ")
+		writeFunction(b, query.Func)
+		writeOrderAndPage(b, query, true)
+		b.WriteString(")")
+	} else if len(query.Order) > 0 || len(query.Args) > 0 {
+		b.WriteString(" (")
+		writeOrderAndPage(b, query, false)
+		b.WriteString(")")
+	}
+
+	if query.Facets != nil {
+		writeFacets(b, query.Facets)
+	}
+
+	if query.Filter != nil {
+		b.WriteString(" @filter(")
+		writeFilter(b, query.Filter)
+		b.WriteString(")")
+	}
+
+	if query.Cascade {
+		b.WriteString(" @cascade")
+	}
+
+	if len(query.Children) > 0 || query.UidCount {
+		b.WriteString(" {\n")
+		if query.UidCount {
+			b.WriteString(prefix + "  ")
+			if query.UidCountAlias != "" {
+				b.WriteString(query.UidCountAlias + " : ")
+			}
+			b.WriteString("count(uid)\n")
+		}
+		for _, c := range query.Children {
+			writeQuery(b, c, prefix+"  ", false)
+		}
+		b.WriteString(prefix)
+		b.WriteString("}")
+	}
+	b.WriteString("\n")
+}
+
+func writeFunction(b *strings.Builder, f *gql.Function) {
+	if f == nil {
+		return
+	}
+
+	switch f.Name {
+	case "uid":
+		uids := make([]string, len(f.UID))
+		for i, uid := range f.UID {
+			uids[i] = fmt.Sprintf("%#x", uid)
+		}
+		fmt.Fprintf(b, "uid(%s)", strings.Join(uids, ", "))
+	case "type":
+		fmt.Fprintf(b, "type(%s)", f.Args[0].Value)
+	case "regexp":
+		// The regular expression isn't quoted; it's between slashes.
+		fmt.Fprintf(b, "regexp(%s, %s)", f.Attr, f.Args[0].Value)
+	case "eq":
+		if len(f.Args) == 1 {
+			fmt.Fprintf(b, "eq(%s, %s)", f.Attr, strconv.Quote(f.Args[0].Value))
+			return
+		}
+		// eq with a list of values matches any of them.
+		vals := make([]string, len(f.Args))
+		for i, arg := range f.Args {
+			vals[i] = strconv.Quote(arg.Value)
+		}
+		fmt.Fprintf(b, "eq(%s, [%s])", f.Attr, strings.Join(vals, ", "))
+	default:
+		args := []string{f.Attr}
+		for _, arg := range f.Args {
+			args = append(args, strconv.Quote(arg.Value))
+		}
+		fmt.Fprintf(b, "%s(%s)", f.Name, strings.Join(args, ", "))
+	}
+}
+
+// writeFacets writes the facets asked for on an edge, with their aliases.
+func writeFacets(b *strings.Builder, facets *pb.FacetParams) {
+	b.WriteString(" @facets")
+	if facets.AllKeys {
+		return
+	}
+	b.WriteString("(")
+	for i, param := range facets.Param {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if param.Alias != "" {
+			b.WriteString(param.Alias + ": ")
+		}
+		b.WriteString(param.Key)
+	}
+	b.WriteString(")")
+}
+
+// writeMath writes t in the infix syntax of math().  The operands of
+// operators are bracketed if they're operations themselves.
+func writeMath(b *strings.Builder, t *gql.MathTree) {
+	switch {
+	case t.Var != "":
+		b.WriteString(t.Var)
+	case len(t.Child) == 0:
+		if f, ok := t.Const.Value.(float64); ok {
+			b.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+		}
+	case t.Fn == "u-":
+		b.WriteString("-")
+		writeMathOperand(b, t.Child[0])
+	case isMathOperator(t.Fn):
+		writeMathOperand(b, t.Child[0])
+		b.WriteString(" " + t.Fn + " ")
+		writeMathOperand(b, t.Child[1])
+	default:
+		b.WriteString(t.Fn + "(")
+		for i, c := range t.Child {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeMath(b, c)
+		}
+		b.WriteString(")")
+	}
+}
+
+func writeMathOperand(b *strings.Builder, t *gql.MathTree) {
+	if isMathOperator(t.Fn) || t.Fn == "u-" {
+		b.WriteString("(")
+		writeMath(b, t)
+		b.WriteString(")")
+		return
+	}
+	writeMath(b, t)
+}
+
+func isMathOperator(fn string) bool {
+	return fn == "+" || fn == "-" || fn == "*" || fn == "/" || fn == "%"
+}
+
+func writeFilter(b *strings.Builder, ft *gql.FilterTree) {
+	if ft.Func != nil {
+		writeFunction(b, ft.Func)
+		return
+	}
+
+	switch ft.Op {
+	case "not":
+		b.WriteString("NOT (")
+		writeFilter(b, ft.Child[0])
+		b.WriteString(")")
+	case "and", "or":
+		if len(ft.Child) == 1 {
+			writeFilter(b, ft.Child[0])
+			return
+		}
+		b.WriteString("(")
+		for i, child := range ft.Child {
+			if i > 0 {
+				b.WriteString(" " + strings.ToUpper(ft.Op) + " ")
+			}
+			writeFilter(b, child)
+		}
+		b.WriteString(")")
+	}
+}
+
+// writeOrderAndPage writes the ordering and pagination arguments of query.
+// At the root, they follow the func argument; elsewhere, they are the only
+// arguments.
+func writeOrderAndPage(b *strings.Builder, query *gql.GraphQuery, root bool) {
+	var args []string
+	for _, ord := range query.Order {
+		if ord.Desc {
+			args = append(args, "orderdesc: "+ord.Attr)
+		} else {
+			args = append(args, "orderasc: "+ord.Attr)
+		}
+	}
+	for _, arg := range []string{"first", "offset"} {
+		if val, ok := query.Args[arg]; ok {
+			args = append(args, arg+": "+val)
+		}
+	}
+
+	if len(args) == 0 {
+		return
+	}
+	if root {
+		b.WriteString(", ")
+	}
+	b.WriteString(strings.Join(args, ", "))
+}
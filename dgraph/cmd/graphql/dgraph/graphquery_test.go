@@ -0,0 +1,181 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsStringNil(t *testing.T) {
+	require.Equal(t, "", AsString(nil))
+}
+
+func TestAsStringByUIDs(t *testing.T) {
+	q := &gql.GraphQuery{
+		Attr: "getAuthor",
+		Func: &gql.Function{Name: "uid", UID: []uint64{1, 0x2a}},
+		Filter: &gql.FilterTree{
+			Func: &gql.Function{Name: "type", Args: []gql.Arg{{Value: "Author"}}},
+		},
+		Children: []*gql.GraphQuery{
+			{Alias: "id", Attr: "uid"},
+			{Alias: "name", Attr: "Author.name"},
+			{Alias: "posts", Attr: "Author.posts",
+				Children: []*gql.GraphQuery{{Alias: "title", Attr: "Post.title"}}},
+		},
+	}
+
+	require.Equal(t, `query {
+  getAuthor(func: uid(0x1, 0x2a)) @filter(type(Author)) {
+    id : uid
+    name : Author.name
+    posts : Author.posts {
+      title : Post.title
+    }
+  }
+}`, AsString(q))
+}
+
+func TestAsStringFilterOrderAndPage(t *testing.T) {
+	eq := func(attr, val string) *gql.FilterTree {
+		return &gql.FilterTree{
+			Func: &gql.Function{Name: "eq", Attr: attr, Args: []gql.Arg{{Value: val}}},
+		}
+	}
+
+	q := &gql.GraphQuery{
+		Attr:  "queryPost",
+		Func:  &gql.Function{Name: "type", Args: []gql.Arg{{Value: "Post"}}},
+		Order: []*pb.Order{{Attr: "Post.title"}, {Attr: "Post.text", Desc: true}},
+		Args:  map[string]string{"first": "10", "offset": "5"},
+		Filter: &gql.FilterTree{
+			Op: "or",
+			Child: []*gql.FilterTree{
+				{Op: "and", Child: []*gql.FilterTree{
+					eq("Post.title", `say "hi"`),
+					{Op: "not", Child: []*gql.FilterTree{eq("Post.isPublished", "true")}},
+				}},
+				{Op: "and", Child: []*gql.FilterTree{
+					{Func: &gql.Function{Name: "uid", UID: []uint64{3}}},
+				}},
+			},
+		},
+		Children: []*gql.GraphQuery{{Alias: "t", Attr: "Post.title"}},
+	}
+
+	require.Equal(t, `query {
+  queryPost(func: type(Post), orderasc: Post.title, orderdesc: Post.text, first: 10, offset: 5) `+
+		`@filter(((eq(Post.title, "say \"hi\"") AND NOT (eq(Post.isPublished, "true"))) OR uid(0x3))) {
+    t : Post.title
+  }
+}`, AsString(q))
+}
+
+func TestAsStringEqList(t *testing.T) {
+	q := &gql.GraphQuery{
+		Attr: "queryPost",
+		Func: &gql.Function{Name: "type", Args: []gql.Arg{{Value: "Post"}}},
+		Filter: &gql.FilterTree{
+			Func: &gql.Function{Name: "eq", Attr: "Post.tag",
+				Args: []gql.Arg{{Value: "go"}, {Value: "dgraph"}}},
+		},
+		Children: []*gql.GraphQuery{{Alias: "t", Attr: "Post.title"}},
+	}
+
+	require.Equal(t, `query {
+  queryPost(func: type(Post)) @filter(eq(Post.tag, ["go", "dgraph"])) {
+    t : Post.title
+  }
+}`, AsString(q))
+}
+
+func TestAsStringCounts(t *testing.T) {
+	q := &gql.GraphQuery{
+		Attr:          "stats",
+		Func:          &gql.Function{Name: "type", Args: []gql.Arg{{Value: "Author"}}},
+		Args:          map[string]string{"first": "100"},
+		UidCount:      true,
+		UidCountAlias: "total",
+		Children: []*gql.GraphQuery{
+			{Alias: "posts", Attr: "Author.posts", IsCount: true},
+		},
+	}
+
+	require.Equal(t, `query {
+  stats(func: type(Author), first: 100) {
+    total : count(uid)
+    posts : count(Author.posts)
+  }
+}`, AsString(q))
+}
+
+func TestAsStringMath(t *testing.T) {
+	num := func(f float64) *gql.MathTree {
+		return &gql.MathTree{Const: types.Val{Tid: types.FloatID, Value: f}}
+	}
+	q := &gql.GraphQuery{
+		Attr: "queryAuthor",
+		Func: &gql.Function{Name: "type", Args: []gql.Arg{{Value: "Author"}}},
+		Children: []*gql.GraphQuery{
+			{Var: "v1", Attr: "Author.likes"},
+			{Var: "v2", Attr: "Author.posts", IsCount: true},
+			{Alias: "score", Attr: "math", MathExp: &gql.MathTree{Fn: "/", Child: []*gql.MathTree{
+				{Fn: "-", Child: []*gql.MathTree{{Var: "v1"}, num(0.5)}},
+				{Fn: "max", Child: []*gql.MathTree{
+					{Var: "v2"},
+					{Fn: "u-", Child: []*gql.MathTree{num(1)}},
+				}},
+			}}},
+		},
+	}
+
+	require.Equal(t, `query {
+  queryAuthor(func: type(Author)) {
+    v1 as Author.likes
+    v2 as count(Author.posts)
+    score : math((v1 - 0.5) / max(v2, -1))
+  }
+}`, AsString(q))
+}
+
+func TestAsStringFacets(t *testing.T) {
+	q := &gql.GraphQuery{
+		Attr: "getPerson",
+		Func: &gql.Function{Name: "uid", UID: []uint64{1}},
+		Children: []*gql.GraphQuery{
+			{Alias: "friendsEdges", Attr: "Person.friends",
+				Facets: &pb.FacetParams{Param: []*pb.FacetParam{
+					{Key: "since", Alias: "facet.since"},
+					{Key: "closeness"},
+				}},
+				Children: []*gql.GraphQuery{{Alias: "name", Attr: "Person.name"}}},
+		},
+	}
+
+	require.Equal(t, `query {
+  getPerson(func: uid(0x1)) {
+    friendsEdges : Person.friends @facets(facet.since: since, closeness) {
+      name : Person.name
+    }
+  }
+}`, AsString(q))
+}
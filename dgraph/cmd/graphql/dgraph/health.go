@@ -0,0 +1,211 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnavailableError is the error from a call that failed because Dgraph
+// couldn't be reached.  Nothing is queued: the caller gets the error straight
+// away, and it's up to the caller to try again later.
+type UnavailableError struct {
+	// Err is the error that showed Dgraph was unreachable.
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return "Dgraph is unavailable, try again later"
+}
+
+// Health tracks whether Dgraph is reachable, as seen by the calls made
+// through a Client wrapped by WithHealth.  A call that fails because Dgraph
+// is unreachable marks it as unavailable, and any call that gets through
+// marks it as available again.
+type Health struct {
+	mu        sync.Mutex
+	available bool
+	since     time.Time
+	lastErr   error
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// HealthStatus is a snapshot of a Health.
+type HealthStatus struct {
+	Available bool      `json:"available"`
+	Since     time.Time `json:"since"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// NewHealth returns a Health that starts out assuming Dgraph is available.
+func NewHealth() *Health {
+	return &Health{available: true, since: time.Now(), now: time.Now}
+}
+
+// Status returns the current state of Dgraph.
+func (h *Health) Status() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st := HealthStatus{Available: h.available, Since: h.since}
+	if !h.available && h.lastErr != nil {
+		st.Error = h.lastErr.Error()
+	}
+	return st
+}
+
+// Ping runs a trivial query against Dgraph, to check that it's answering, and
+// returns how long it took.
+func Ping(ctx context.Context, client Client) (time.Duration, error) {
+	ping := &gql.GraphQuery{
+		Attr:     "health",
+		Func:     &gql.Function{Name: "uid", UID: []uint64{1}},
+		Children: []*gql.GraphQuery{{Attr: "uid"}},
+	}
+	start := time.Now()
+	_, err := client.Query(ctx, ping)
+	return time.Since(start), err
+}
+
+// Watch checks, every interval until ctx is done, whether an unavailable
+// Dgraph has come back, so that the health recovers even if there are no
+// requests.  client must be wrapped by WithHealth with h.
+func (h *Health) Watch(ctx context.Context, client Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !h.Status().Available {
+				// The result is recorded by the wrapped client.
+				_, _ = Ping(ctx, client)
+			}
+		}
+	}
+}
+
+// record notes the result of a call, and returns the error the caller
+// should see.
+func (h *Health) record(err error) error {
+	cause := errors.Cause(err)
+	if cause == context.Canceled || cause == context.DeadlineExceeded {
+		// The call gave up, so it doesn't show either way.
+		return err
+	}
+
+	switch status.Code(cause) {
+	case codes.Unavailable:
+		h.set(false, err)
+		return &UnavailableError{Err: err}
+	case codes.Canceled, codes.DeadlineExceeded:
+		return err
+	default:
+		// Any answer from Dgraph, even an error, shows it's reachable.
+		h.set(true, nil)
+		return err
+	}
+}
+
+func (h *Health) set(available bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastErr = err
+	if h.available == available {
+		return
+	}
+	h.available = available
+	h.since = h.now()
+	if available {
+		glog.Infof("Dgraph is available again")
+	} else {
+		glog.Warningf("Dgraph is unavailable: %v", err)
+	}
+}
+
+type healthClient struct {
+	client Client
+	health *Health
+}
+
+type healthTxn struct {
+	txn    Txn
+	health *Health
+}
+
+// WithHealth wraps client so that every call reports to h whether Dgraph was
+// reachable, and calls that fail because it wasn't return an
+// *UnavailableError.
+func WithHealth(client Client, h *Health) Client {
+	return &healthClient{client: client, health: h}
+}
+
+func (c *healthClient) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	resp, err := c.client.Query(ctx, query)
+	return resp, c.health.record(err)
+}
+
+func (c *healthClient) NewTxn() Txn {
+	return &healthTxn{txn: c.client.NewTxn(), health: c.health}
+}
+
+func (c *healthClient) Alter(ctx context.Context, schema string) error {
+	return c.health.record(c.client.Alter(ctx, schema))
+}
+
+func (c *healthClient) Schema(ctx context.Context,
+	predicates []string) ([]*api.SchemaNode, error) {
+
+	nodes, err := c.client.Schema(ctx, predicates)
+	return nodes, c.health.record(err)
+}
+
+func (c *healthClient) DescribeSchema(ctx context.Context) (*FullSchema, error) {
+	sch, err := c.client.DescribeSchema(ctx)
+	return sch, c.health.record(err)
+}
+
+func (t *healthTxn) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	resp, err := t.txn.Query(ctx, query)
+	return resp, t.health.record(err)
+}
+
+func (t *healthTxn) Mutate(ctx context.Context, mut *api.Mutation) (map[string]string, error) {
+	assigned, err := t.txn.Mutate(ctx, mut)
+	return assigned, t.health.record(err)
+}
+
+func (t *healthTxn) Commit(ctx context.Context) error {
+	return t.health.record(t.txn.Commit(ctx))
+}
+
+func (t *healthTxn) Discard(ctx context.Context) error {
+	return t.txn.Discard(ctx)
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyDgraph fails every call with its error.
+type flakyDgraph struct {
+	sync.Mutex
+	err     error
+	queries int
+}
+
+func (f *flakyDgraph) setErr(err error) {
+	f.Lock()
+	defer f.Unlock()
+	f.err = err
+}
+
+func (f *flakyDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.queries++
+	return []byte(`{}`), f.err
+}
+
+func (f *flakyDgraph) NewTxn() Txn {
+	return f
+}
+
+func (f *flakyDgraph) Alter(ctx context.Context, schema string) error {
+	f.Lock()
+	defer f.Unlock()
+	return f.err
+}
+
+func (f *flakyDgraph) Schema(ctx context.Context, preds []string) ([]*api.SchemaNode, error) {
+	f.Lock()
+	defer f.Unlock()
+	return nil, f.err
+}
+
+func (f *flakyDgraph) DescribeSchema(ctx context.Context) (*FullSchema, error) {
+	f.Lock()
+	defer f.Unlock()
+	return &FullSchema{}, f.err
+}
+
+func (f *flakyDgraph) Mutate(ctx context.Context, mut *api.Mutation) (map[string]string, error) {
+	f.Lock()
+	defer f.Unlock()
+	return nil, f.err
+}
+
+func (f *flakyDgraph) Commit(ctx context.Context) error {
+	f.Lock()
+	defer f.Unlock()
+	return f.err
+}
+
+func (f *flakyDgraph) Discard(ctx context.Context) error {
+	return nil
+}
+
+func TestHealth(t *testing.T) {
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHealth()
+	h.now = func() time.Time { return now }
+
+	dg := &flakyDgraph{}
+	client := WithHealth(dg, h)
+	ctx := context.Background()
+
+	_, err := client.Query(ctx, &gql.GraphQuery{})
+	require.NoError(t, err)
+	require.True(t, h.Status().Available)
+
+	dg.setErr(errors.Wrap(status.Error(codes.Unavailable, "connection refused"),
+		"while querying Dgraph"))
+	_, err = client.NewTxn().Mutate(ctx, &api.Mutation{})
+	require.IsType(t, &UnavailableError{}, err)
+	require.Equal(t, "Dgraph is unavailable, try again later", err.Error())
+	require.Equal(t, HealthStatus{
+		Available: false,
+		Since:     now,
+		Error:     "while querying Dgraph: rpc error: code = Unavailable desc = connection refused",
+	}, h.Status())
+
+	// Giving up on a call says nothing about Dgraph.
+	dg.setErr(context.DeadlineExceeded)
+	require.Equal(t, context.DeadlineExceeded, client.Alter(ctx, ""))
+	require.False(t, h.Status().Available)
+
+	// Dgraph rejecting a call shows that it's back.
+	now = now.Add(time.Minute)
+	conflict := status.Error(codes.Aborted, "conflict")
+	dg.setErr(conflict)
+	err = client.NewTxn().Commit(ctx)
+	require.Equal(t, conflict, err)
+	require.Equal(t, HealthStatus{Available: true, Since: now}, h.Status())
+}
+
+func TestHealthWatch(t *testing.T) {
+	h := NewHealth()
+	dg := &flakyDgraph{err: status.Error(codes.Unavailable, "connection refused")}
+	client := WithHealth(dg, h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Watch(ctx, client, time.Millisecond)
+
+	// Nothing's probed while Dgraph is available.
+	time.Sleep(10 * time.Millisecond)
+	dg.Lock()
+	require.Equal(t, 0, dg.queries)
+	dg.Unlock()
+	require.True(t, h.Status().Available)
+
+	_, err := client.Query(ctx, &gql.GraphQuery{})
+	require.Error(t, err)
+	require.False(t, h.Status().Available)
+
+	dg.setErr(nil)
+	for start := time.Now(); !h.Status().Available; time.Sleep(time.Millisecond) {
+		require.True(t, time.Since(start) < time.Second, "health didn't recover")
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package external
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// A breaker is a circuit breaker for one endpoint.  It's closed (calls are
+// allowed) until there are too many consecutive failures, then it's open
+// (calls fail fast) for the cooldown.  After the cooldown, it's half open: a
+// single trial call is allowed, which either closes the breaker again or
+// re-opens it for another cooldown.
+type breaker struct {
+	sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+	lastUsed  time.Time
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+func (b *breaker) allow(p Policy) bool {
+	if p.BreakerThreshold <= 0 {
+		return true
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	if b.failures < p.BreakerThreshold {
+		return true
+	}
+	if b.now().Before(b.openUntil) || b.trial {
+		return false
+	}
+
+	// Half open: let this call through as the trial.
+	b.trial = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *breaker) failure(p Policy) {
+	if p.BreakerThreshold <= 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.failures >= p.BreakerThreshold {
+		if b.failures == p.BreakerThreshold {
+			glog.Warningf("Circuit breaker opened after %d consecutive failures", b.failures)
+		}
+		b.openUntil = b.now().Add(p.BreakerCooldown)
+	}
+}
+
+// idle returns true if b hasn't been used since before cutoff and isn't
+// open, so forgetting it changes nothing.
+func (b *breaker) idle(cutoff time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+	return b.lastUsed.Before(cutoff) && !b.trial && !b.now().Before(b.openUntil)
+}
+
+// breakerIdleTime is how long a breaker that isn't open is kept after it was
+// last used, so endpoints that aren't called any more don't hold on to
+// memory.
+const breakerIdleTime = 10 * time.Minute
+
+type breakers struct {
+	sync.Mutex
+	byEndpoint map[string]*breaker
+	swept      time.Time
+	now        func() time.Time
+}
+
+func newBreakers() *breakers {
+	return &breakers{byEndpoint: make(map[string]*breaker), now: time.Now}
+}
+
+func (bs *breakers) get(endpoint string) *breaker {
+	bs.Lock()
+	defer bs.Unlock()
+
+	now := bs.now()
+	if now.Sub(bs.swept) >= breakerIdleTime {
+		bs.sweep(now.Add(-breakerIdleTime))
+		bs.swept = now
+	}
+
+	b, ok := bs.byEndpoint[endpoint]
+	if !ok {
+		b = &breaker{now: bs.now}
+		bs.byEndpoint[endpoint] = b
+	}
+	b.Lock()
+	b.lastUsed = now
+	b.Unlock()
+	return b
+}
+
+// sweep forgets the breakers that are idle since cutoff.
+func (bs *breakers) sweep(cutoff time.Time) {
+	for endpoint, b := range bs.byEndpoint {
+		if b.idle(cutoff) {
+			delete(bs.byEndpoint, endpoint)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package external makes the HTTP calls that the GraphQL layer sends to
+// external services (@custom and @lambda resolvers).  Calls are made with a
+// Policy that bounds how long they can take, retries idempotent calls that
+// fail, and stops calling services that are down.  A Mapping reshapes the
+// results into the GraphQL types they resolve.
+package external
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/signing"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// A Policy says how calls to a single external endpoint are made.
+type Policy struct {
+	// Timeout bounds each attempt of a call.  Zero means no timeout.
+	Timeout time.Duration
+
+	// MaxRetries is the number of times a failed idempotent call is retried.
+	MaxRetries int
+
+	// Backoff is the wait before the first retry.  It doubles for each
+	// following retry, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// After BreakerThreshold consecutive failed calls, the circuit breaker
+	// opens and calls fail fast until BreakerCooldown has passed.  Zero
+	// BreakerThreshold disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultPolicy is used for endpoints that don't configure their own.
+var DefaultPolicy = Policy{
+	Timeout:          10 * time.Second,
+	MaxRetries:       2,
+	Backoff:          100 * time.Millisecond,
+	MaxBackoff:       2 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
+// Policies choose the Policy of each call: the one for the host it's to, if
+// Hosts has one, or else Default.  Hosts are host[:port], in lower case.
+type Policies struct {
+	Default Policy
+	Hosts   map[string]Policy
+}
+
+// DefaultPolicies uses DefaultPolicy for every call.
+var DefaultPolicies = Policies{Default: DefaultPolicy}
+
+// For returns the Policy for calls to rawurl.
+func (ps Policies) For(rawurl string) Policy {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ps.Default
+	}
+	if p, ok := ps.Hosts[strings.ToLower(u.Host)]; ok {
+		return p
+	}
+	return ps.Default
+}
+
+// Error kinds, as reported in CallError.Kind.
+const (
+	ErrTimeout     = "TIMEOUT"
+	ErrUpstream    = "UPSTREAM_ERROR"
+	ErrCircuitOpen = "CIRCUIT_OPEN"
+)
+
+// A CallError is the error from a failed call to an external endpoint.
+type CallError struct {
+	Kind       string
+	URL        string
+	StatusCode int
+	Attempts   int
+	Err        error
+}
+
+func (e *CallError) Error() string {
+	msg := fmt.Sprintf("call to %s failed", e.URL)
+	switch e.Kind {
+	case ErrCircuitOpen:
+		return msg + ": service is unavailable (circuit breaker open)"
+	case ErrTimeout:
+		msg += ": timed out"
+	}
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(": HTTP status %d", e.StatusCode)
+	}
+	if e.Err != nil && e.Kind != ErrTimeout {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Attempts > 1 {
+		msg += fmt.Sprintf(" (after %d attempts)", e.Attempts)
+	}
+	return msg
+}
+
+// Extensions gives the details of the error for the extensions of a GraphQL
+// error, so clients can tell why the call failed.
+func (e *CallError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.Kind, "url": e.URL}
+	if e.StatusCode != 0 {
+		ext["status"] = e.StatusCode
+	}
+	return ext
+}
+
+// RedactURL replaces u, the URL that was called, with tmpl, the URL as it's
+// configured, in err.  tmpl is what's reported when u has secrets expanded in
+// it.
+func RedactURL(err error, u, tmpl string) error {
+	if callErr, ok := err.(*CallError); ok {
+		callErr.URL = tmpl
+		if callErr.Err != nil {
+			callErr.Err = errors.New(strings.Replace(callErr.Err.Error(), u, tmpl, -1))
+		}
+		return callErr
+	}
+	if strings.Contains(err.Error(), u) {
+		return errors.New(strings.Replace(err.Error(), u, tmpl, -1))
+	}
+	return err
+}
+
+// A Client makes calls to external endpoints.  Each endpoint - by default,
+// the host a call is to - gets its own circuit breaker.  A Client is safe for
+// concurrent use.
+type Client struct {
+	http     *http.Client
+	breakers *breakers
+	policies *Policies
+
+	// sleep is replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient returns a Client that makes requests with hc, or
+// http.DefaultClient if hc is nil.
+func NewClient(hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	policies := DefaultPolicies
+	return &Client{http: hc, breakers: newBreakers(), policies: &policies, sleep: sleepCtx}
+}
+
+// SetPolicies makes ps the policies that Policy chooses from, for c and the
+// Clients made from it by WithSigner.  It's for setting up c, before it makes
+// any calls.
+func (c *Client) SetPolicies(ps Policies) {
+	*c.policies = ps
+}
+
+// Policy returns the Policy for calls to rawurl.
+func (c *Client) Policy(rawurl string) Policy {
+	return c.policies.For(rawurl)
+}
+
+// WithSigner returns a Client that signs each attempt of every call with s
+// before it's sent, so the endpoint can tell the call came from this server.
+// It shares c's circuit breakers.
+func (c *Client) WithSigner(s signing.Signer) *Client {
+	hc := *c.http
+	hc.Transport = signing.NewTransport(c.http.Transport, s)
+	return &Client{http: &hc, breakers: c.breakers, policies: c.policies, sleep: c.sleep}
+}
+
+// Do sends req according to policy p and returns the response body of the
+// first successful (2xx) attempt.  Only idempotent requests are retried, and
+// a request with a body must have GetBody set (as http.NewRequest does) to
+// be retried.  The circuit breaker is the one for req's host.
+func (c *Client) Do(req *http.Request, p Policy) ([]byte, error) {
+	return c.DoEndpoint(strings.ToLower(req.URL.Host), req, p)
+}
+
+// DoEndpoint is Do with the circuit breaker for endpoint, instead of req's
+// host.  endpoint must be one of a bounded set of names, like the URL
+// templates of @custom calls, not something like the filled in URLs.
+func (c *Client) DoEndpoint(endpoint string, req *http.Request, p Policy) ([]byte, error) {
+	url := req.URL.String()
+	cb := c.breakers.get(endpoint)
+	if !cb.allow(p) {
+		return nil, &CallError{Kind: ErrCircuitOpen, URL: url}
+	}
+
+	retries := 0
+	if isIdempotent(req.Method) {
+		retries = p.MaxRetries
+	}
+
+	var lastErr *CallError
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		body, callErr := c.attempt(req, p)
+		if callErr == nil {
+			cb.success()
+			return body, nil
+		}
+		callErr.Attempts = attempt
+		lastErr = callErr
+
+		if attempt > retries || !retryable(callErr) || req.Context().Err() != nil {
+			break
+		}
+		if req.Body != nil && req.GetBody == nil {
+			break
+		}
+
+		glog.V(2).Infof("Retrying call to %s after error: %v", url, callErr)
+		if err := c.sleep(req.Context(), jitter(backoff)); err != nil {
+			break
+		}
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+
+	cb.failure(p)
+	return nil, lastErr
+}
+
+func (c *Client) attempt(req *http.Request, p Policy) ([]byte, *CallError) {
+	url := req.URL.String()
+
+	ctx := req.Context()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	attemptReq := req.WithContext(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, &CallError{Kind: ErrUpstream, URL: url, Err: err}
+		}
+		attemptReq.Body = body
+	}
+
+	resp, err := c.http.Do(attemptReq)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &CallError{Kind: ErrTimeout, URL: url, Err: err}
+		}
+		return nil, &CallError{Kind: ErrUpstream, URL: url, Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &CallError{Kind: ErrTimeout, URL: url, Err: err}
+		}
+		return nil, &CallError{Kind: ErrUpstream, URL: url,
+			Err: errors.Wrap(err, "while reading response")}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &CallError{Kind: ErrUpstream, URL: url, StatusCode: resp.StatusCode}
+	}
+	return body, nil
+}
+
+// retryable returns true if the failure might not happen again: network
+// errors, timeouts, throttling and server errors.  Other 4xx responses won't
+// change by retrying.
+func retryable(e *CallError) bool {
+	switch {
+	case e.StatusCode == 0:
+		return true
+	case e.StatusCode == http.StatusTooManyRequests:
+		return true
+	default:
+		return e.StatusCode >= 500
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut,
+		http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// jitter randomises d by up to +/-25%, so that retries from many requests
+// don't all arrive at the same time.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d - d/4 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
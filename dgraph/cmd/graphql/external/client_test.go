@@ -0,0 +1,286 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/signing"
+	"github.com/stretchr/testify/require"
+)
+
+// statusServer answers with the given statuses in order, and then with the
+// last status for every following request.
+func statusServer(statuses ...int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if n > len(statuses) {
+			n = len(statuses)
+		}
+		w.WriteHeader(statuses[n-1])
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	return srv, &calls
+}
+
+func testClient() *Client {
+	c := NewClient(nil)
+	c.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	return c
+}
+
+var testPolicy = Policy{MaxRetries: 2, Backoff: time.Millisecond}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int
+		calls    int32
+		status   int
+	}{
+		{name: "GET succeeds after server errors", method: http.MethodGet,
+			statuses: []int{500, 503, 200}, calls: 3},
+		{name: "GET gives up after MaxRetries", method: http.MethodGet,
+			statuses: []int{500}, calls: 3, status: 500},
+		{name: "GET retries when throttled", method: http.MethodGet,
+			statuses: []int{429, 200}, calls: 2},
+		{name: "GET doesn't retry client errors", method: http.MethodGet,
+			statuses: []int{404}, calls: 1, status: 404},
+		{name: "POST isn't retried", method: http.MethodPost,
+			statuses: []int{500, 200}, calls: 1, status: 500},
+		{name: "PUT with a body is retried", method: http.MethodPut,
+			statuses: []int{502, 200}, calls: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, calls := statusServer(test.statuses...)
+			defer srv.Close()
+
+			req, err := http.NewRequest(test.method, srv.URL, strings.NewReader(`{}`))
+			require.NoError(t, err)
+
+			body, err := testClient().Do(req, testPolicy)
+			require.Equal(t, test.calls, atomic.LoadInt32(calls))
+			if test.status == 0 {
+				require.NoError(t, err)
+				require.JSONEq(t, `{"ok": true}`, string(body))
+				return
+			}
+
+			require.Error(t, err)
+			callErr, ok := err.(*CallError)
+			require.True(t, ok)
+			require.Equal(t, ErrUpstream, callErr.Kind)
+			require.Equal(t, test.status, callErr.StatusCode)
+			require.Equal(t, int(test.calls), callErr.Attempts)
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, nil)
+	require.NoError(t, err)
+
+	_, err = testClient().Do(req, Policy{Timeout: 20 * time.Millisecond})
+	require.Error(t, err)
+	callErr, ok := err.(*CallError)
+	require.True(t, ok)
+	require.Equal(t, ErrTimeout, callErr.Kind)
+	require.Equal(t, map[string]interface{}{"code": ErrTimeout, "url": srv.URL},
+		callErr.Extensions())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	srv, calls := statusServer(500, 500, 500, 200)
+	defer srv.Close()
+
+	now := time.Now()
+	c := testClient()
+	c.breakers.now = func() time.Time { return now }
+
+	p := Policy{BreakerThreshold: 3, BreakerCooldown: time.Minute}
+	call := func() error {
+		req, err := http.NewRequest(http.MethodPost, srv.URL, nil)
+		require.NoError(t, err)
+		_, err = c.Do(req, p)
+		return err
+	}
+	kind := func(err error) string {
+		require.Error(t, err)
+		callErr, ok := err.(*CallError)
+		require.True(t, ok)
+		return callErr.Kind
+	}
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, ErrUpstream, kind(call()))
+	}
+
+	// Open: fails fast without calling the server.
+	require.Equal(t, ErrCircuitOpen, kind(call()))
+	require.Equal(t, int32(3), atomic.LoadInt32(calls))
+
+	// Half open after the cooldown: the trial call succeeds and closes it.
+	now = now.Add(time.Minute)
+	require.NoError(t, call())
+	require.NoError(t, call())
+	require.Equal(t, int32(5), atomic.LoadInt32(calls))
+}
+
+func TestBreakerReopensOnFailedTrial(t *testing.T) {
+	now := time.Now()
+	b := &breaker{now: func() time.Time { return now }}
+	p := Policy{BreakerThreshold: 1, BreakerCooldown: time.Second}
+
+	b.failure(p)
+	require.False(t, b.allow(p))
+
+	now = now.Add(time.Second)
+	require.True(t, b.allow(p))
+	require.False(t, b.allow(p), "only one trial call is allowed while half open")
+
+	b.failure(p)
+	require.False(t, b.allow(p))
+
+	now = now.Add(time.Second)
+	require.True(t, b.allow(p))
+	b.success()
+	require.True(t, b.allow(p))
+	require.True(t, b.allow(p))
+}
+
+func TestWithSigner(t *testing.T) {
+	var calls, verified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if signing.VerifyHMAC(r, "sssh", time.Minute) == nil {
+			atomic.AddInt32(&verified, 1)
+		}
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	c := testClient().WithSigner(signing.NewHMACSigner("sssh"))
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/things/1", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	_, err = c.Do(req, testPolicy)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	require.Equal(t, int32(2), atomic.LoadInt32(&verified), "every attempt is signed")
+
+	req, err = http.NewRequest(http.MethodPut, srv.URL+"/things/1", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	_, err = testClient().Do(req, testPolicy)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&verified), "the original client doesn't sign")
+}
+
+func TestBreakersByEndpoint(t *testing.T) {
+	srv, calls := statusServer(500)
+	defer srv.Close()
+
+	c := testClient()
+	p := Policy{BreakerThreshold: 1, BreakerCooldown: time.Minute}
+	call := func(path, endpoint string) *CallError {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.NoError(t, err)
+		if endpoint == "" {
+			_, err = c.Do(req, p)
+		} else {
+			_, err = c.DoEndpoint(endpoint, req, p)
+		}
+		require.Error(t, err)
+		return err.(*CallError)
+	}
+
+	require.Equal(t, ErrUpstream, call("/users/1", "").Kind)
+	require.Equal(t, ErrCircuitOpen, call("/users/2", "").Kind,
+		"calls to the same host share a breaker")
+	require.Equal(t, ErrUpstream, call("/users/3", "GET /users/$id").Kind,
+		"an endpoint has a breaker of its own")
+	require.Equal(t, ErrCircuitOpen, call("/users/4", "GET /users/$id").Kind)
+	require.Equal(t, int32(2), atomic.LoadInt32(calls))
+	require.Len(t, c.breakers.byEndpoint, 2)
+}
+
+func TestBreakersForgetIdleEndpoints(t *testing.T) {
+	now := time.Now()
+	bs := newBreakers()
+	bs.now = func() time.Time { return now }
+	p := Policy{BreakerThreshold: 1, BreakerCooldown: time.Hour}
+
+	bs.get("a").success()
+	bs.get("b").failure(p)
+	now = now.Add(breakerIdleTime / 2)
+	bs.get("c")
+	require.Len(t, bs.byEndpoint, 3)
+
+	now = now.Add(breakerIdleTime)
+	bs.get("d")
+	require.Contains(t, bs.byEndpoint, "b", "open breakers are kept")
+	require.Contains(t, bs.byEndpoint, "c")
+	require.Contains(t, bs.byEndpoint, "d")
+	require.NotContains(t, bs.byEndpoint, "a", "idle breakers are forgotten")
+
+	now = now.Add(time.Hour)
+	bs.get("d")
+	require.Equal(t, []string{"d"}, endpoints(bs))
+}
+
+func endpoints(bs *breakers) []string {
+	var names []string
+	for name := range bs.byEndpoint {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestPolicies(t *testing.T) {
+	slow := Policy{Timeout: time.Minute}
+	ps := Policies{Default: DefaultPolicy, Hosts: map[string]Policy{"slow:8080": slow}}
+	require.Equal(t, slow, ps.For("http://SLOW:8080/graphql"))
+	require.Equal(t, DefaultPolicy, ps.For("http://slow/graphql"))
+	require.Equal(t, DefaultPolicy, ps.For("http://fast:8080/graphql"))
+
+	c := NewClient(nil)
+	signed := c.WithSigner(signing.NewHMACSigner("sssh"))
+	require.Equal(t, DefaultPolicy, signed.Policy("http://slow:8080/graphql"))
+	c.SetPolicies(ps)
+	require.Equal(t, slow, signed.Policy("http://slow:8080/graphql"),
+		"clients made by WithSigner share the policies")
+}
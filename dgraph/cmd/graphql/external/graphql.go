@@ -0,0 +1,316 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A RemoteGraphQL resolves fields by forwarding them to a remote GraphQL API.
+type RemoteGraphQL struct {
+	client *Client
+	url    string
+	policy Policy
+	header http.Header
+}
+
+// A RemoteCall is a field to be resolved by a remote GraphQL API.  The remote
+// query named RemoteName is called with Args, and the selection set of Field
+// is forwarded as the selection set of the remote query.
+type RemoteCall struct {
+	Field      schema.Field
+	RemoteName string
+	Args       map[string]interface{}
+}
+
+// A RemoteResult is the result of a RemoteCall.  As with any GraphQL result,
+// there can be both data and errors.
+type RemoteResult struct {
+	Data   interface{}
+	Errors gqlerror.List
+}
+
+// NewRemoteGraphQL returns a RemoteGraphQL that makes its calls to the GraphQL
+// API at url with client, according to policy p.
+func NewRemoteGraphQL(client *Client, url string, p Policy) *RemoteGraphQL {
+	return &RemoteGraphQL{client: client, url: url, policy: p}
+}
+
+// WithHeader makes rg send the headers in h with each request.  It returns
+// rg, so calls can be chained.
+func (rg *RemoteGraphQL) WithHeader(h http.Header) *RemoteGraphQL {
+	rg.header = h
+	return rg
+}
+
+type remoteResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors gqlerror.List              `json:"errors"`
+}
+
+// Resolve makes all the calls in a single remote operation of type op
+// ("query" or "mutation") - e.g. one call for each parent object of a @custom
+// field - and returns the results in the same order.  An error is returned
+// only if the remote API couldn't be called, or didn't give a GraphQL
+// response; errors that the remote API reports are in the results.
+func (rg *RemoteGraphQL) Resolve(ctx context.Context, op string,
+	calls []RemoteCall) ([]RemoteResult, error) {
+
+	if len(calls) == 0 {
+		return nil, nil
+	}
+
+	resp, err := rg.post(ctx, remoteQuery(op, calls))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]RemoteResult, len(calls))
+	for i, call := range calls {
+		if raw, ok := resp.Data[remoteAlias(i)]; ok {
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			if err := dec.Decode(&results[i].Data); err != nil {
+				return nil, errors.Wrapf(err, "couldn't unmarshal result from %s", rg.url)
+			}
+		}
+		results[i].Errors = remoteErrors(resp.Errors, i, call.Field)
+	}
+
+	// Errors with no path can't be pinned on any one call, so rather than
+	// repeat them for every call, they go with the first call that has no
+	// data, or the first call if they all do.
+	blame := 0
+	for i := range results {
+		if results[i].Data == nil {
+			blame = i
+			break
+		}
+	}
+	for _, e := range resp.Errors {
+		if len(e.Path) == 0 {
+			results[blame].Errors = append(results[blame].Errors,
+				relocated(e, nil, calls[blame].Field))
+		}
+	}
+	return results, nil
+}
+
+// Forward sends the selection set of field, from an operation of type op
+// ("query" or "mutation"), to the remote API as the whole of a remote
+// operation.  That's how the fields of a stitched-in remote API are resolved:
+// the field the API is stitched in at stands for the remote root type.  It
+// returns the remote data, which is the value of field, and the remote
+// errors, relocated to field.
+func (rg *RemoteGraphQL) Forward(ctx context.Context, op string,
+	field schema.Field) (interface{}, gqlerror.List, error) {
+
+	var buf bytes.Buffer
+	buf.WriteString(op)
+	writeSelectionSet(&buf, field.SelectionSet(), "")
+
+	resp, err := rg.post(ctx, buf.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var data interface{}
+	if resp.Data != nil {
+		obj := make(map[string]interface{}, len(resp.Data))
+		for name, raw := range resp.Data {
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			var val interface{}
+			if err := dec.Decode(&val); err != nil {
+				return nil, nil, errors.Wrapf(err, "couldn't unmarshal result from %s", rg.url)
+			}
+			obj[name] = val
+		}
+		data = obj
+	}
+
+	var errs gqlerror.List
+	for _, e := range resp.Errors {
+		path := append([]interface{}{field.ResponseName()}, e.Path...)
+		errs = append(errs, relocated(e, path, field))
+	}
+	return data, errs, nil
+}
+
+// post sends query to the remote API and decodes the GraphQL response.
+func (rg *RemoteGraphQL) post(ctx context.Context, query string) (*remoteResponse, error) {
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		return nil, errors.Wrap(err, "while building the remote query")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rg.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "while building the remote request")
+	}
+	req = req.WithContext(ctx)
+	for name, values := range rg.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	respBody, err := rg.client.Do(req, rg.policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp remoteResponse
+	dec := json.NewDecoder(bytes.NewReader(respBody))
+	dec.UseNumber()
+	if err := dec.Decode(&resp); err != nil {
+		return nil, errors.Wrapf(err, "response from %s isn't a GraphQL response", rg.url)
+	}
+	return &resp, nil
+}
+
+// relocated copies remote error e, giving it path and the location of the
+// local field.  The remote locations mean nothing to the client.
+func relocated(e *gqlerror.Error, path []interface{}, field schema.Field) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:    e.Message,
+		Path:       path,
+		Locations:  []gqlerror.Location{*field.Location()},
+		Extensions: e.Extensions,
+	}
+}
+
+// remoteErrors picks out the errors that belong to the i'th call: those with
+// a path that starts at the i'th call.
+func remoteErrors(errs []*gqlerror.Error, i int, field schema.Field) gqlerror.List {
+	var result gqlerror.List
+	for _, e := range errs {
+		if len(e.Path) == 0 || e.Path[0] != remoteAlias(i) {
+			continue
+		}
+		path := append([]interface{}{field.ResponseName()}, e.Path[1:]...)
+		result = append(result, relocated(e, path, field))
+	}
+	return result
+}
+
+func remoteAlias(i int) string {
+	return "r" + strconv.Itoa(i)
+}
+
+// remoteQuery builds a GraphQL operation of type op that makes all the calls,
+// each aliased by its position.
+func remoteQuery(op string, calls []RemoteCall) string {
+	var buf bytes.Buffer
+	buf.WriteString(op + " {\n")
+	for i, call := range calls {
+		buf.WriteString("  ")
+		buf.WriteString(remoteAlias(i))
+		buf.WriteString(": ")
+		buf.WriteString(call.RemoteName)
+		writeArguments(&buf, call.Args)
+		writeSelectionSet(&buf, call.Field.SelectionSet(), "  ")
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+func writeSelectionSet(buf *bytes.Buffer, fields []schema.Field, indent string) {
+	if len(fields) == 0 {
+		return
+	}
+
+	buf.WriteString(" {\n")
+	for _, f := range fields {
+		buf.WriteString(indent + "  ")
+		if f.Alias() != "" && f.Alias() != f.Name() {
+			buf.WriteString(f.Alias())
+			buf.WriteString(": ")
+		}
+		buf.WriteString(f.Name())
+		writeArguments(buf, f.Arguments())
+		writeSelectionSet(buf, f.SelectionSet(), indent+"  ")
+		buf.WriteString("\n")
+	}
+	buf.WriteString(indent + "}")
+}
+
+func writeArguments(buf *bytes.Buffer, args map[string]interface{}) {
+	if len(args) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + ": " + asLiteral(args[name])
+	}
+	buf.WriteString("(")
+	buf.WriteString(strings.Join(parts, ", "))
+	buf.WriteString(")")
+}
+
+// asLiteral writes val as a GraphQL input value literal.
+func asLiteral(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "null"
+	case schema.EnumValue:
+		return string(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = asLiteral(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fields := make([]string, len(names))
+		for i, name := range names {
+			fields[i] = name + ": " + asLiteral(v[name])
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	default:
+		// GraphQL strings, numbers and booleans are written as in JSON.
+		js, err := json.Marshal(v)
+		if err != nil {
+			return strconv.Quote(fmt.Sprintf("%v", v))
+		}
+		return string(js)
+	}
+}
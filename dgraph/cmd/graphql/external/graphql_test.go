@@ -0,0 +1,165 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+const remoteSchema = `
+type Author {
+	id: ID!
+	name: String! @search(by: [hash])
+	posts(sort: Sort, titles: [String]): [Post]
+}
+
+enum Sort {
+	NEWEST
+	OLDEST
+}
+
+type Post {
+	postID: ID!
+	title: String!
+}`
+
+func testField(t *testing.T, query string, vars map[string]interface{}) schema.Field {
+	handler, err := schema.NewHandler(remoteSchema)
+	require.NoError(t, err)
+
+	op, err := handler.Schema().Operation(&schema.Request{Query: query, Variables: vars})
+	require.NoError(t, err)
+	require.Len(t, op.Queries(), 1)
+	return op.Queries()[0]
+}
+
+func TestRemoteGraphQL(t *testing.T) {
+	var gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		gotQuery = req["query"]
+
+		_, _ = w.Write([]byte(`{
+			"data": {
+				"r0": {"n": "Alice", "posts": [{"title": "GraphQL"}]},
+				"r1": null
+			},
+			"errors": [
+				{"message": "no such author", "path": ["r1"],
+					"locations": [{"line": 9, "column": 3}]},
+				{"message": "deprecated API", "extensions": {"code": "DEPRECATED"}}
+			]
+		}`))
+	}))
+	defer srv.Close()
+
+	field := testField(t, `query($titles: [String]) {
+		getAuthor(id: "0x1") {
+			n: name
+			posts(sort: NEWEST, titles: $titles) {
+				title
+			}
+		}
+	}`, map[string]interface{}{"titles": []interface{}{"GraphQL \"Dgraph\""}})
+
+	rg := NewRemoteGraphQL(NewClient(nil), srv.URL, Policy{})
+	results, err := rg.Resolve(context.Background(), "query", []RemoteCall{
+		{Field: field, RemoteName: "author", Args: map[string]interface{}{"id": "1"}},
+		{Field: field, RemoteName: "author", Args: map[string]interface{}{
+			"id":   "2",
+			"role": schema.EnumValue("ADMIN"),
+		}},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, `query {
+  r0: author(id: "1") {
+    n: name
+    posts(sort: NEWEST, titles: ["GraphQL \"Dgraph\""]) {
+      title
+    }
+  }
+  r1: author(id: "2", role: ADMIN) {
+    n: name
+    posts(sort: NEWEST, titles: ["GraphQL \"Dgraph\""]) {
+      title
+    }
+  }
+}`, gotQuery)
+
+	require.Len(t, results, 2)
+	js, err := json.Marshal(results[0].Data)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"n": "Alice", "posts": [{"title": "GraphQL"}]}`, string(js))
+	require.Nil(t, results[1].Data)
+
+	loc := []gqlerror.Location{{Line: 2, Column: 3}}
+	deprecated := &gqlerror.Error{Message: "deprecated API", Locations: loc,
+		Extensions: map[string]interface{}{"code": "DEPRECATED"}}
+	require.Empty(t, results[0].Errors, "errors without a path go with the call that failed")
+	require.Equal(t, gqlerror.List{
+		{Message: "no such author", Path: []interface{}{"getAuthor"}, Locations: loc},
+		deprecated,
+	}, results[1].Errors)
+}
+
+func TestRemoteGraphQLErrorWithoutPath(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"data": {"r0": {"n": "Alice"}, "r1": {"n": "Bob"}, "r2": {"n": "Carol"}},
+			"errors": [{"message": "deprecated API"}]
+		}`))
+	}))
+	defer srv.Close()
+
+	field := testField(t, `query { getAuthor(id: "0x1") { n: name } }`, nil)
+	rg := NewRemoteGraphQL(NewClient(nil), srv.URL, Policy{})
+	results, err := rg.Resolve(context.Background(), "query", []RemoteCall{
+		{Field: field, RemoteName: "author", Args: map[string]interface{}{"id": "1"}},
+		{Field: field, RemoteName: "author", Args: map[string]interface{}{"id": "2"}},
+		{Field: field, RemoteName: "author", Args: map[string]interface{}{"id": "3"}},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, gqlerror.List{{Message: "deprecated API",
+		Locations: []gqlerror.Location{{Line: 1, Column: 9}}}}, results[0].Errors,
+		"the error is reported once")
+	require.Empty(t, results[1].Errors)
+	require.Empty(t, results[2].Errors)
+}
+
+func TestRemoteGraphQLNotGraphQL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html>`))
+	}))
+	defer srv.Close()
+
+	field := testField(t, `query { getAuthor(id: "0x1") { name } }`, nil)
+	rg := NewRemoteGraphQL(NewClient(nil), srv.URL, Policy{})
+	_, err := rg.Resolve(context.Background(), "query", []RemoteCall{{Field: field, RemoteName: "author"}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "isn't a GraphQL response")
+}
@@ -0,0 +1,290 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const introspectionQuery = `query {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    types {
+      kind
+      name
+      description
+      fields(includeDeprecated: true) {
+        name
+        description
+        args { ...InputValue }
+        type { ...TypeRef }
+        isDeprecated
+        deprecationReason
+      }
+      inputFields { ...InputValue }
+      interfaces { name }
+      enumValues(includeDeprecated: true) {
+        name
+        description
+        isDeprecated
+        deprecationReason
+      }
+      possibleTypes { name }
+    }
+  }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+        }
+      }
+    }
+  }
+}`
+
+// builtinScalars are in every GraphQL schema, so they aren't written into the
+// SDL.
+var builtinScalars = map[string]bool{
+	"Int": true, "Float": true, "String": true, "Boolean": true, "ID": true,
+}
+
+// introspection is the result of introspectionQuery.
+type introspection struct {
+	QueryType    *namedRef   `json:"queryType"`
+	MutationType *namedRef   `json:"mutationType"`
+	Types        []*fullType `json:"types"`
+}
+
+type namedRef struct {
+	Name string `json:"name"`
+}
+
+type fullType struct {
+	Kind          string        `json:"kind"`
+	Name          string        `json:"name"`
+	Description   string        `json:"description"`
+	Fields        []*fieldDefn  `json:"fields"`
+	InputFields   []*inputValue `json:"inputFields"`
+	Interfaces    []*namedRef   `json:"interfaces"`
+	EnumValues    []*enumValue  `json:"enumValues"`
+	PossibleTypes []*namedRef   `json:"possibleTypes"`
+}
+
+type fieldDefn struct {
+	Name              string        `json:"name"`
+	Description       string        `json:"description"`
+	Args              []*inputValue `json:"args"`
+	Type              *typeRef      `json:"type"`
+	IsDeprecated      bool          `json:"isDeprecated"`
+	DeprecationReason *string       `json:"deprecationReason"`
+}
+
+type inputValue struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Type         *typeRef `json:"type"`
+	DefaultValue *string  `json:"defaultValue"`
+}
+
+type enumValue struct {
+	Name              string  `json:"name"`
+	Description       string  `json:"description"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
+}
+
+type typeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *typeRef `json:"ofType"`
+}
+
+// IntrospectSDL runs an introspection query against the GraphQL API at url and
+// returns the API's schema as SDL.
+func IntrospectSDL(ctx context.Context, client *Client, url string, p Policy) (string, error) {
+	rg := NewRemoteGraphQL(client, url, p)
+	resp, err := rg.post(ctx, introspectionQuery)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Errors) > 0 {
+		return "", errors.Errorf("introspection of %s failed: %s", url, resp.Errors.Error())
+	}
+
+	raw, ok := resp.Data["__schema"]
+	if !ok {
+		return "", errors.Errorf("introspection of %s returned no schema", url)
+	}
+	var intro introspection
+	if err := json.Unmarshal(raw, &intro); err != nil {
+		return "", errors.Wrapf(err, "couldn't unmarshal introspection result from %s", url)
+	}
+
+	return intro.sdl(), nil
+}
+
+// sdl writes the introspected schema as SDL.
+func (intro *introspection) sdl() string {
+	var buf bytes.Buffer
+
+	if intro.QueryType != nil {
+		buf.WriteString("schema {\n")
+		buf.WriteString("  query: " + intro.QueryType.Name + "\n")
+		if intro.MutationType != nil {
+			buf.WriteString("  mutation: " + intro.MutationType.Name + "\n")
+		}
+		buf.WriteString("}\n")
+	}
+
+	for _, t := range intro.Types {
+		if strings.HasPrefix(t.Name, "__") || builtinScalars[t.Name] {
+			continue
+		}
+		buf.WriteString("\n")
+		writeDescription(&buf, t.Description, "")
+		switch t.Kind {
+		case "SCALAR":
+			buf.WriteString("scalar " + t.Name + "\n")
+		case "ENUM":
+			buf.WriteString("enum " + t.Name + " {\n")
+			for _, ev := range t.EnumValues {
+				writeDescription(&buf, ev.Description, "  ")
+				buf.WriteString("  " + ev.Name)
+				writeDeprecated(&buf, ev.IsDeprecated, ev.DeprecationReason)
+				buf.WriteString("\n")
+			}
+			buf.WriteString("}\n")
+		case "UNION":
+			members := make([]string, len(t.PossibleTypes))
+			for i, pt := range t.PossibleTypes {
+				members[i] = pt.Name
+			}
+			buf.WriteString("union " + t.Name + " = " + strings.Join(members, " | ") + "\n")
+		case "INPUT_OBJECT":
+			buf.WriteString("input " + t.Name + " {\n")
+			for _, iv := range t.InputFields {
+				writeDescription(&buf, iv.Description, "  ")
+				buf.WriteString("  ")
+				writeInputValue(&buf, iv)
+				buf.WriteString("\n")
+			}
+			buf.WriteString("}\n")
+		case "INTERFACE", "OBJECT":
+			if t.Kind == "INTERFACE" {
+				buf.WriteString("interface " + t.Name)
+			} else {
+				buf.WriteString("type " + t.Name)
+			}
+			if len(t.Interfaces) > 0 {
+				ifaces := make([]string, len(t.Interfaces))
+				for i, iface := range t.Interfaces {
+					ifaces[i] = iface.Name
+				}
+				buf.WriteString(" implements " + strings.Join(ifaces, " & "))
+			}
+			buf.WriteString(" {\n")
+			for _, f := range t.Fields {
+				writeDescription(&buf, f.Description, "  ")
+				buf.WriteString("  " + f.Name)
+				if len(f.Args) > 0 {
+					args := make([]string, len(f.Args))
+					for i, arg := range f.Args {
+						var argBuf bytes.Buffer
+						writeInputValue(&argBuf, arg)
+						args[i] = argBuf.String()
+					}
+					buf.WriteString("(" + strings.Join(args, ", ") + ")")
+				}
+				buf.WriteString(": " + f.Type.String())
+				writeDeprecated(&buf, f.IsDeprecated, f.DeprecationReason)
+				buf.WriteString("\n")
+			}
+			buf.WriteString("}\n")
+		}
+	}
+
+	return buf.String()
+}
+
+func (t *typeRef) String() string {
+	switch {
+	case t == nil:
+		return ""
+	case t.Kind == "NON_NULL":
+		return t.OfType.String() + "!"
+	case t.Kind == "LIST":
+		return "[" + t.OfType.String() + "]"
+	default:
+		return t.Name
+	}
+}
+
+func writeInputValue(buf *bytes.Buffer, iv *inputValue) {
+	buf.WriteString(iv.Name + ": " + iv.Type.String())
+	if iv.DefaultValue != nil {
+		buf.WriteString(" = " + *iv.DefaultValue)
+	}
+}
+
+func writeDescription(buf *bytes.Buffer, desc, indent string) {
+	if desc == "" {
+		return
+	}
+	buf.WriteString(indent + quoted(desc) + "\n")
+}
+
+func writeDeprecated(buf *bytes.Buffer, isDeprecated bool, reason *string) {
+	if !isDeprecated {
+		return
+	}
+	buf.WriteString(" @deprecated")
+	if reason != nil {
+		buf.WriteString("(reason: " + quoted(*reason) + ")")
+	}
+}
+
+// quoted writes s as a GraphQL string, which is quoted as in JSON.
+func quoted(s string) string {
+	js, _ := json.Marshal(s)
+	return string(js)
+}
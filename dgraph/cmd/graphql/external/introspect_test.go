@@ -0,0 +1,90 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+// introspectionServer answers introspection queries for sch, as a remote
+// GraphQL API would.
+func introspectionServer(t *testing.T, sch schema.Schema) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req schema.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		op, err := sch.Operation(&req)
+		require.NoError(t, err)
+		require.Len(t, op.Queries(), 1)
+
+		val, err := schema.Introspect(op.Queries()[0])
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		buf.WriteString(`{"data": {"__schema": `)
+		buf.Write(val)
+		buf.WriteString(`}}`)
+		_, _ = w.Write(buf.Bytes())
+	}))
+}
+
+func TestIntrospectSDL(t *testing.T) {
+	handler, err := schema.NewHandler(remoteSchema)
+	require.NoError(t, err)
+
+	srv := introspectionServer(t, handler.Schema())
+	defer srv.Close()
+
+	sdl, err := IntrospectSDL(context.Background(), NewClient(nil), srv.URL, DefaultPolicy)
+	require.NoError(t, err)
+
+	require.Contains(t, sdl, "schema {\n  query: Query\n  mutation: Mutation\n}")
+	require.Contains(t, sdl, "type Author {")
+	require.Contains(t, sdl, "  posts(sort: Sort, titles: [String]): [Post]")
+	require.Contains(t, sdl, "enum Sort {\n  NEWEST\n  OLDEST\n}")
+	require.Contains(t, sdl, "input AuthorFilter {")
+	require.NotContains(t, sdl, "__Type")
+	require.NotContains(t, sdl, "scalar String")
+
+	// The introspected schema can be stitched into another.
+	_, err = schema.NewHandler(`type Local { id: ID! }`, schema.RemoteAPI{
+		Field:  "authors",
+		Prefix: "Authors",
+		URL:    srv.URL,
+		SDL:    sdl,
+	})
+	require.NoError(t, err)
+}
+
+func TestIntrospectSDLErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errors": [{"message": "introspection is disabled"}]}`))
+	}))
+	defer srv.Close()
+
+	_, err := IntrospectSDL(context.Background(), NewClient(nil), srv.URL, DefaultPolicy)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "introspection is disabled")
+}
@@ -0,0 +1,349 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package external
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// A Mapping reshapes the JSON response of a REST endpoint into the shape of
+// a GraphQL type, so that APIs that don't return GraphQL shaped results can
+// back @custom fields.
+//
+// Mappings are written as a path, that picks out the part of the response to
+// use, followed by an optional template that builds an object from the
+// picked value.  For example:
+//
+//	$.data.users[*] {
+//	  id: $.user_id,
+//	  name: $.profile["display-name"],
+//	  posts: $.items { title: $.headline }
+//	}
+//
+// A path starts at $, the value being mapped, and is followed by any number
+// of steps: .name and ["name"] pick out a field of an object, [n] picks out
+// the n'th item of a list and [*] picks out all the items of a list.  Any
+// steps after a [*] are applied to each item.  A template maps each of its
+// fields to a path, evaluated relative to the value the template is applied
+// to, and optionally a nested template.  If a template is applied to a list,
+// it's applied to each item.
+//
+// Paths that don't exist in the response evaluate to null.
+type Mapping struct {
+	path     []pathStep
+	template []templateField
+}
+
+type stepKind int
+
+const (
+	fieldStep stepKind = iota
+	indexStep
+	allStep
+)
+
+type pathStep struct {
+	kind  stepKind
+	name  string
+	index int
+}
+
+type templateField struct {
+	name    string
+	mapping *Mapping
+}
+
+// ParseMapping parses a mapping.
+func ParseMapping(spec string) (*Mapping, error) {
+	p := &mappingParser{input: []rune(spec)}
+	m, err := p.mapping(true)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.done() {
+		return nil, p.errorf("unexpected %q after the end of the mapping", p.peek())
+	}
+	return m, nil
+}
+
+// ApplyJSON applies m to the JSON document js.
+func (m *Mapping) ApplyJSON(js []byte) (interface{}, error) {
+	var val interface{}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	if err := dec.Decode(&val); err != nil {
+		return nil, errors.Wrap(err, "couldn't unmarshal the response")
+	}
+	return m.Apply(val), nil
+}
+
+// Apply applies m to val, which should be a value decoded from JSON.
+func (m *Mapping) Apply(val interface{}) interface{} {
+	val = evalPath(m.path, val)
+	if m.template == nil {
+		return val
+	}
+	return m.applyTemplate(val)
+}
+
+func (m *Mapping) applyTemplate(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = m.applyTemplate(item)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(m.template))
+		for _, f := range m.template {
+			res[f.name] = f.mapping.Apply(v)
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+func evalPath(path []pathStep, val interface{}) interface{} {
+	for i, step := range path {
+		if step.kind == allStep {
+			items, ok := val.([]interface{})
+			if !ok {
+				return nil
+			}
+			res := make([]interface{}, len(items))
+			for j, item := range items {
+				res[j] = evalPath(path[i+1:], item)
+			}
+			return res
+		}
+
+		switch v := val.(type) {
+		case map[string]interface{}:
+			if step.kind != fieldStep {
+				return nil
+			}
+			val = v[step.name]
+		case []interface{}:
+			if step.kind != indexStep || step.index >= len(v) {
+				return nil
+			}
+			val = v[step.index]
+		default:
+			return nil
+		}
+	}
+	return val
+}
+
+type mappingParser struct {
+	input []rune
+	pos   int
+}
+
+// mapping parses `path [template]`.  At the top level the path is optional
+// and defaults to $.
+func (p *mappingParser) mapping(topLevel bool) (*Mapping, error) {
+	m := &Mapping{}
+
+	p.skipSpace()
+	if !topLevel || p.peek() == '$' {
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		m.path = path
+	}
+
+	p.skipSpace()
+	if p.peek() == '{' {
+		tmpl, err := p.template()
+		if err != nil {
+			return nil, err
+		}
+		m.template = tmpl
+	}
+	return m, nil
+}
+
+func (p *mappingParser) path() ([]pathStep, error) {
+	if err := p.expect('$'); err != nil {
+		return nil, err
+	}
+
+	var steps []pathStep
+	for {
+		switch p.peek() {
+		case '.':
+			p.pos++
+			name := p.ident()
+			if name == "" {
+				return nil, p.errorf("expected a field name after '.'")
+			}
+			steps = append(steps, pathStep{kind: fieldStep, name: name})
+		case '[':
+			p.pos++
+			step, err := p.bracketStep()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		default:
+			return steps, nil
+		}
+	}
+}
+
+func (p *mappingParser) bracketStep() (pathStep, error) {
+	var step pathStep
+	switch r := p.peek(); {
+	case r == '*':
+		p.pos++
+		step = pathStep{kind: allStep}
+	case r == '"':
+		name, err := p.quoted()
+		if err != nil {
+			return step, err
+		}
+		step = pathStep{kind: fieldStep, name: name}
+	case unicode.IsDigit(r):
+		start := p.pos
+		for unicode.IsDigit(p.peek()) {
+			p.pos++
+		}
+		n, err := strconv.Atoi(string(p.input[start:p.pos]))
+		if err != nil {
+			return step, p.errorf("bad list index: %s", err)
+		}
+		step = pathStep{kind: indexStep, index: n}
+	default:
+		return step, p.errorf("expected *, a list index or a quoted field name after '['")
+	}
+	return step, p.expect(']')
+}
+
+func (p *mappingParser) template() ([]templateField, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+
+	tmpl := []templateField{}
+	seen := make(map[string]bool)
+	for {
+		p.skipSpace()
+		if p.peek() == '}' && len(tmpl) > 0 {
+			p.pos++
+			return tmpl, nil
+		}
+
+		name := p.ident()
+		if name == "" {
+			return nil, p.errorf("expected a field name in template")
+		}
+		if seen[name] {
+			return nil, p.errorf("field %s is mapped more than once", name)
+		}
+		seen[name] = true
+
+		p.skipSpace()
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		m, err := p.mapping(false)
+		if err != nil {
+			return nil, err
+		}
+		tmpl = append(tmpl, templateField{name: name, mapping: m})
+
+		p.skipSpace()
+		if p.peek() == ',' {
+			p.pos++
+		}
+	}
+}
+
+func (p *mappingParser) quoted() (string, error) {
+	start := p.pos
+	p.pos++
+	for !p.done() && p.peek() != '"' {
+		if p.peek() == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.done() {
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+	s, err := strconv.Unquote(string(p.input[start:p.pos]))
+	if err != nil {
+		return "", p.errorf("bad string: %s", err)
+	}
+	return s, nil
+}
+
+func (p *mappingParser) ident() string {
+	start := p.pos
+	for !p.done() {
+		r := p.peek()
+		if r != '_' && !unicode.IsLetter(r) && (p.pos == start || !unicode.IsDigit(r)) {
+			break
+		}
+		p.pos++
+	}
+	return string(p.input[start:p.pos])
+}
+
+func (p *mappingParser) expect(r rune) error {
+	if p.peek() != r {
+		if p.done() {
+			return p.errorf("expected %q but the mapping ended", r)
+		}
+		return p.errorf("expected %q but found %q", r, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *mappingParser) skipSpace() {
+	for !p.done() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *mappingParser) peek() rune {
+	if p.done() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *mappingParser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *mappingParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("invalid mapping at position %d: "+format,
+		append([]interface{}{p.pos + 1}, args...)...)
+}
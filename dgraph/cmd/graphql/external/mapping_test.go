@@ -0,0 +1,132 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package external
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const usersResponse = `{
+	"data": {
+		"users": [
+			{
+				"user_id": 1,
+				"profile": {"display-name": "Alice", "tags": ["a", "b"]},
+				"items": [{"headline": "one"}, {"headline": "two"}]
+			},
+			{
+				"user_id": 2,
+				"profile": {"display-name": "Bob", "tags": []}
+			}
+		]
+	}
+}`
+
+func TestMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapping  string
+		expected string
+	}{
+		{
+			name:     "identity",
+			mapping:  `$`,
+			expected: usersResponse,
+		},
+		{
+			name:     "nested extraction",
+			mapping:  `$.data.users[1].profile["display-name"]`,
+			expected: `"Bob"`,
+		},
+		{
+			name:     "all items of a list",
+			mapping:  `$.data.users[*].user_id`,
+			expected: `[1, 2]`,
+		},
+		{
+			name: "template renames fields",
+			mapping: `$.data.users[*] {
+				id: $.user_id,
+				name: $.profile["display-name"],
+				firstTag: $.profile.tags[0],
+				posts: $.items { title: $.headline }
+			}`,
+			expected: `[
+				{"id": 1, "name": "Alice", "firstTag": "a",
+					"posts": [{"title": "one"}, {"title": "two"}]},
+				{"id": 2, "name": "Bob", "firstTag": null, "posts": null}
+			]`,
+		},
+		{
+			name:     "template without a path",
+			mapping:  `{ count: $.data.users[*].user_id }`,
+			expected: `{"count": [1, 2]}`,
+		},
+		{
+			name:     "missing paths are null",
+			mapping:  `$.data.accounts[0].id`,
+			expected: `null`,
+		},
+		{
+			name:     "steps that don't fit the value are null",
+			mapping:  `$.data[0]`,
+			expected: `null`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := ParseMapping(test.mapping)
+			require.NoError(t, err)
+
+			val, err := m.ApplyJSON([]byte(usersResponse))
+			require.NoError(t, err)
+
+			js, err := json.Marshal(val)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, string(js))
+		})
+	}
+}
+
+func TestMappingErrors(t *testing.T) {
+	tests := []struct {
+		mapping string
+		err     string
+	}{
+		{`data.users`, `invalid mapping at position 1: unexpected 'd' after the end of the mapping`},
+		{`$.`, `invalid mapping at position 3: expected a field name after '.'`},
+		{`$[x]`, `invalid mapping at position 3: expected *, a list index or a quoted ` +
+			`field name after '['`},
+		{`$["a]`, `invalid mapping at position 6: unterminated string`},
+		{`$[0`, `invalid mapping at position 4: expected ']' but the mapping ended`},
+		{`{ id: $.a, id: $.b }`, `invalid mapping at position 14: field id is mapped more than once`},
+		{`{ id $.a }`, `invalid mapping at position 6: expected ':' but found '$'`},
+		{`{ }`, `invalid mapping at position 3: expected a field name in template`},
+		{`{ id: uid }`, `invalid mapping at position 7: expected '$' but found 'u'`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mapping, func(t *testing.T) {
+			_, err := ParseMapping(test.mapping)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
@@ -0,0 +1,290 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/admin"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// defaultLoadBatch is how many objects are added by each mutation of a load.
+const defaultLoadBatch = 100
+
+func loadCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "load",
+		Short: "Load a dataset into Dgraph through the GraphQL API's add mutations",
+		Long: `
+Loads the objects in the JSON or YAML file given with --data into the Dgraph
+cluster at --alpha, through the add mutations of the GraphQL schema stored
+there, so they're checked and stored just as if they'd been added through the
+API - with their defaults, @xid and all.  The file maps the names of types to
+lists of objects, each of them that type's add input, e.g.
+
+	Author:
+	  - username: ann
+	    name: Ann
+	Post:
+	  - title: Hello
+	    author: { username: ann }
+
+Every object is validated before anything is loaded, and nothing is loaded if
+any of them aren't valid.  --dry-run stops there; with --schema, it checks the
+data against a schema file, without a Dgraph cluster.  Types are loaded in
+order of name, --batch objects to a mutation.  An object whose @xid is already
+stored updates that object, so a dataset can be loaded again, and objects can
+refer to each other by @xid whichever is loaded first.  --claims is a JSON
+object of the JWT claims that @auth rules see, e.g. {"role": "ADMIN"}.  TLS is
+set up as for "dgraph graphql".`,
+		Args: cobra.NoArgs,
+	}
+	file := cmd.Flags().String("data", "", "JSON or YAML file with the objects to load.")
+	alpha := cmd.Flags().StringP("alpha", "a", "127.0.0.1:9080",
+		"Dgraph Alpha gRPC server address.")
+	schemaFile := cmd.Flags().String("schema", "",
+		"With --dry-run, a file with the GraphQL schema to check the data against.")
+	dryRun := cmd.Flags().Bool("dry-run", false, "Only check the data; don't load it.")
+	batch := cmd.Flags().Int("batch", defaultLoadBatch,
+		"How many objects each mutation adds.")
+	claims := cmd.Flags().String("claims", "",
+		"JSON object of the JWT claims that @auth rules see.")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *file == "" {
+			return errors.New("a --data file is needed")
+		}
+		if *batch <= 0 {
+			return errors.New("--batch must be positive")
+		}
+		if *schemaFile != "" && !*dryRun {
+			return errors.New("--schema can only be used with --dry-run; " +
+				"data is loaded with the schema stored in Dgraph")
+		}
+		ctx := context.Background()
+		if *claims != "" {
+			var c map[string]interface{}
+			if err := json.Unmarshal([]byte(*claims), &c); err != nil {
+				return errors.Wrap(err, "--claims isn't a JSON object")
+			}
+			ctx = authorization.WithClaims(ctx, c)
+		}
+		data, err := readDataset(*file)
+		if err != nil {
+			return err
+		}
+
+		if *schemaFile != "" {
+			handler, err := loadSchemaFile(*schemaFile)
+			if err != nil {
+				return err
+			}
+			if _, err := loadBatches(handler.Schema(), data, *batch); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is valid for %s.\n", *file, *schemaFile)
+			return nil
+		}
+
+		tlsCfg, err := x.LoadClientTLSConfig(GraphQL.Conf)
+		if err != nil {
+			return errors.Wrap(err, "while loading the TLS configuration")
+		}
+		conn, err := x.SetupConnection(*alpha, tlsCfg, false)
+		if err != nil {
+			return errors.Wrapf(err, "while connecting to Dgraph at %s", *alpha)
+		}
+		defer conn.Close()
+
+		dg := dgraph.AsDgraph(dgo.NewDgraphClient(api.NewDgraphClient(conn)))
+		resolver := resolve.New(nil, dg)
+		adm, err := admin.New(dg, resolver)
+		if err != nil {
+			return err
+		}
+		if err := adm.LoadStoredSchema(ctx); err != nil {
+			return errors.Wrap(err, "while loading the GraphQL schema stored in Dgraph")
+		}
+		if resolver.Schema() == nil {
+			return errors.Errorf("Dgraph at %s has no GraphQL schema", *alpha)
+		}
+		batches, err := loadBatches(resolver.Schema(), data, *batch)
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is valid for the schema in Dgraph.\n", *file)
+			return nil
+		}
+		return loadData(ctx, resolver, batches, cmd.OutOrStdout())
+	}
+	return cmd
+}
+
+// A dataset is the objects to load, each of them its type's add input, keyed
+// by the name of their type.
+type dataset map[string][]interface{}
+
+// readDataset reads the dataset in file, which is YAML if it's named .yaml
+// or .yml, and otherwise JSON.
+func readDataset(file string) (dataset, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while reading data file %s", file)
+	}
+
+	var data dataset
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &data)
+		for name, objs := range data {
+			data[name] = fromYAML(objs).([]interface{})
+		}
+	default:
+		err = json.Unmarshal(raw, &data)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "while parsing data file %s", file)
+	}
+	return data, nil
+}
+
+// fromYAML converts the maps in val, which YAML decodes with keys of any
+// type, to the map[string]interface{} that JSON decodes to.
+func fromYAML(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			m[fmt.Sprint(key)] = fromYAML(elem)
+		}
+		return m
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = fromYAML(elem)
+		}
+		return v
+	}
+	return val
+}
+
+// A loadBatch is one mutation of a load: the add mutation of the objects of
+// type typ from index from, up to but not including to.
+type loadBatch struct {
+	typ      string
+	from, to int
+	req      *schema.Request
+}
+
+func (b *loadBatch) String() string {
+	return fmt.Sprintf("%s objects %d to %d", b.typ, b.from+1, b.to)
+}
+
+// loadBatches checks data against the add inputs of sch, and splits it into
+// the mutations that load it, of up to size objects each.  If any objects
+// aren't valid, the error lists all their problems.
+func loadBatches(sch schema.Schema, data dataset, size int) ([]*loadBatch, error) {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var batches []*loadBatch
+	var problems []string
+	for _, name := range names {
+		add := sch.MutationName(schema.AddMutation, name)
+		if add == "" {
+			problems = append(problems, fmt.Sprintf("%s isn't a type that can be added", name))
+			continue
+		}
+		query := fmt.Sprintf("mutation ($input: [Add%sInput!]!) {\n"+
+			"  %s(input: $input) { __typename }\n}", name, add)
+
+		objs := data[name]
+		for from := 0; from < len(objs); from += size {
+			to := from + size
+			if to > len(objs) {
+				to = len(objs)
+			}
+			b := &loadBatch{typ: name, from: from, to: to, req: &schema.Request{
+				Query:     query,
+				Variables: map[string]interface{}{"input": objs[from:to]},
+			}}
+			if _, err := sch.Operation(b.req); err != nil {
+				for _, gqlErr := range schema.AsGQLErrors(err) {
+					problems = append(problems, fmt.Sprintf("%s: %s", b, gqlErr.Message))
+				}
+			}
+			batches = append(batches, b)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, errors.Errorf("the data isn't valid:\n%s", strings.Join(problems, "\n"))
+	}
+	return batches, nil
+}
+
+// A requestResolver resolves GraphQL requests, like a
+// resolve.RequestResolver.
+type requestResolver interface {
+	Resolve(ctx context.Context, req *schema.Request) *schema.Response
+}
+
+// loadData runs the mutations of batches with resolver, in order, printing
+// the progress to out.  Each batch is its own transaction, so if one fails,
+// the batches before it have been loaded.
+func loadData(ctx context.Context, resolver requestResolver, batches []*loadBatch,
+	out io.Writer) error {
+
+	loaded := make(map[string]int)
+	for _, b := range batches {
+		resp := resolver.Resolve(ctx, b.req)
+		if len(resp.Errors) > 0 {
+			return errors.Errorf("couldn't load %s: %s", b,
+				strings.TrimSpace(resp.Errors.Error()))
+		}
+		fmt.Fprintf(out, "Loaded %s.\n", b)
+		loaded[b.typ] += b.to - b.from
+	}
+
+	names := make([]string, 0, len(loaded))
+	for name := range loaded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "%d %s objects loaded.\n", loaded[name], name)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+const loadSchema = `
+type Author {
+	id: ID!
+	username: String! @xid
+	name: String!
+	posts: [Post] @hasInverse(field: author)
+}
+
+type Post {
+	id: ID!
+	title: String!
+	likes: Int
+	author: Author
+}
+`
+
+const loadJSON = `{
+	"Post": [
+		{"title": "Hello", "likes": 3, "author": {"username": "ann"}},
+		{"title": "Again", "author": {"username": "ann"}},
+		{"title": "Bye", "author": {"username": "bob"}}
+	],
+	"Author": [
+		{"username": "ann", "name": "Ann"},
+		{"username": "bob", "name": "Bob"}
+	]
+}`
+
+const loadYAML = `
+Post:
+  - title: Hello
+    likes: 3
+    author: { username: ann }
+  - title: Again
+    author: { username: ann }
+  - title: Bye
+    author: { username: bob }
+Author:
+  - username: ann
+    name: Ann
+  - username: bob
+    name: Bob
+`
+
+// writeLoadFiles writes files, keyed by name, to a temporary directory, and
+// returns it.
+func writeLoadFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "graphql-load")
+	require.NoError(t, err)
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestReadDataset(t *testing.T) {
+	dir := writeLoadFiles(t, map[string]string{
+		"data.json": loadJSON, "data.yaml": loadYAML, "bad.yml": "Post: [",
+	})
+	defer os.RemoveAll(dir)
+
+	fromJSON, err := readDataset(filepath.Join(dir, "data.json"))
+	require.NoError(t, err)
+	require.Len(t, fromJSON["Post"], 3)
+	require.Equal(t, map[string]interface{}{"username": "ann", "name": "Ann"},
+		fromJSON["Author"][0])
+
+	fromYAML, err := readDataset(filepath.Join(dir, "data.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"username": "ann"},
+		fromYAML["Post"][0].(map[string]interface{})["author"])
+
+	// The datasets only differ in how the numbers are decoded.
+	require.Equal(t, 3, fromYAML["Post"][0].(map[string]interface{})["likes"])
+	fromYAML["Post"][0].(map[string]interface{})["likes"] = float64(3)
+	require.Equal(t, fromJSON, fromYAML)
+
+	_, err = readDataset(filepath.Join(dir, "bad.yml"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "while parsing data file")
+}
+
+func TestLoadBatches(t *testing.T) {
+	handler, err := schema.NewHandler(loadSchema)
+	require.NoError(t, err)
+	dir := writeLoadFiles(t, map[string]string{"data.json": loadJSON})
+	defer os.RemoveAll(dir)
+	data, err := readDataset(filepath.Join(dir, "data.json"))
+	require.NoError(t, err)
+
+	batches, err := loadBatches(handler.Schema(), data, 2)
+	require.NoError(t, err)
+	var names []string
+	for _, b := range batches {
+		names = append(names, b.String())
+	}
+	require.Equal(t, []string{
+		"Author objects 1 to 2",
+		"Post objects 1 to 2",
+		"Post objects 3 to 3",
+	}, names)
+	require.Equal(t, "mutation ($input: [AddPostInput!]!) {\n"+
+		"  addPost(input: $input) { __typename }\n}", batches[2].req.Query)
+	require.Equal(t, data["Post"][2:], batches[2].req.Variables["input"])
+
+	data["Post"] = append(data["Post"], map[string]interface{}{"likes": "lots"})
+	data["Comment"] = []interface{}{map[string]interface{}{"text": "Hi"}}
+	_, err = loadBatches(handler.Schema(), data, 2)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the data isn't valid:")
+	require.Contains(t, err.Error(), "Comment isn't a type that can be added")
+	require.Contains(t, err.Error(), "Post objects 3 to 4: ")
+	require.NotContains(t, err.Error(), "Post objects 1 to 2")
+}
+
+type fakeResolver struct {
+	reqs []*schema.Request
+	fail int
+}
+
+func (fr *fakeResolver) Resolve(ctx context.Context, req *schema.Request) *schema.Response {
+	fr.reqs = append(fr.reqs, req)
+	if len(fr.reqs) == fr.fail {
+		return &schema.Response{Errors: gqlerror.List{gqlerror.Errorf("transaction aborted")}}
+	}
+	return &schema.Response{}
+}
+
+func TestLoadData(t *testing.T) {
+	batches := []*loadBatch{
+		{typ: "Author", from: 0, to: 2, req: &schema.Request{Query: "a"}},
+		{typ: "Post", from: 0, to: 2, req: &schema.Request{Query: "b"}},
+		{typ: "Post", from: 2, to: 3, req: &schema.Request{Query: "c"}},
+	}
+
+	resolver := &fakeResolver{}
+	var out bytes.Buffer
+	require.NoError(t, loadData(context.Background(), resolver, batches, &out))
+	require.Len(t, resolver.reqs, 3)
+	require.Equal(t, "Loaded Author objects 1 to 2.\n"+
+		"Loaded Post objects 1 to 2.\n"+
+		"Loaded Post objects 3 to 3.\n"+
+		"2 Author objects loaded.\n"+
+		"3 Post objects loaded.\n", out.String())
+
+	resolver = &fakeResolver{fail: 2}
+	out.Reset()
+	err := loadData(context.Background(), resolver, batches, &out)
+	require.EqualError(t, err,
+		"couldn't load Post objects 1 to 2: input: transaction aborted")
+	require.Len(t, resolver.reqs, 2, "the load stops at the batch that fails")
+}
+
+func TestLoadCmdDryRun(t *testing.T) {
+	dir := writeLoadFiles(t, map[string]string{
+		"schema.graphql": loadSchema,
+		"data.yaml":      loadYAML,
+		"bad.json":       `{"Post": [{"title": 7}]}`,
+	})
+	defer os.RemoveAll(dir)
+
+	run := func(args ...string) (string, error) {
+		cmd := loadCmd()
+		var out bytes.Buffer
+		cmd.SetOutput(&out)
+		cmd.SetArgs(args)
+		cmd.SilenceUsage = true
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	data, sch := filepath.Join(dir, "data.yaml"), filepath.Join(dir, "schema.graphql")
+	out, err := run("--data", data, "--schema", sch, "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, out, "data.yaml is valid for "+sch)
+
+	_, err = run("--data", filepath.Join(dir, "bad.json"), "--schema", sch, "--dry-run")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Post objects 1 to 1: ")
+
+	_, err = run("--data", data, "--schema", sch)
+	require.EqualError(t, err, "--schema can only be used with --dry-run; "+
+		"data is loaded with the schema stored in Dgraph")
+
+	_, err = run("--schema", sch, "--dry-run")
+	require.EqualError(t, err, "a --data file is needed")
+}
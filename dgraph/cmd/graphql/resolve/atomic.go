@@ -0,0 +1,93 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+)
+
+// resolveAtomic resolves the mutations of an @atomic operation, in order, in
+// one Dgraph transaction, adding the results to resp.  The transaction is
+// only committed if all the mutations succeed, and the payloads are only
+// completed once it is.  If a mutation fails, the rest aren't run, nothing is
+// committed and all the mutation fields are null: the one that failed has its
+// error, and the others say that they weren't committed because of it.  An
+// aborted transaction is run again, from the first mutation, as r's write
+// RetryPolicy says.
+func (r *RequestResolver) resolveAtomic(ctx context.Context, mutations []schema.Mutation,
+	resp *schema.Response) {
+
+	resolvers := make([]*mutationResolver, len(mutations))
+	failed := -1
+	var err error
+	for i, m := range mutations {
+		resolvers[i] = r.mutationResolver(m)
+		if m.MutationType() == schema.TypenameMutation {
+			continue
+		}
+		if _, ok := r.fieldResolvers[m.Name()]; ok && err == nil {
+			failed = i
+			err = errors.Errorf("mutation %s can't be part of an @atomic operation", m.Name())
+		}
+	}
+
+	payloads := make([]map[string]interface{}, len(mutations))
+	if err == nil {
+		err = r.retries.Writes.transaction(ctx, r.dgraphClient, func(txn dgraph.Txn) error {
+			failed = -1
+			for i, mr := range resolvers {
+				if mr.mutation.MutationType() == schema.TypenameMutation {
+					continue
+				}
+				mctx, end := startResolver(ctx, mr.mutation)
+				var err error
+				payloads[i], err = mr.mutate(mctx, txn)
+				end()
+				if err != nil {
+					failed = i
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	for i, mr := range resolvers {
+		var res *resolved
+		switch {
+		case mr.mutation.MutationType() == schema.TypenameMutation:
+			// It's the same whether or not the mutations were committed.
+			res = resolveWith(ctx, mr.mutation, resolveTypename)
+		case err == nil:
+			mr.audit.record(ctx, mr.mutation, mr.uids)
+			mr.sendEvent(ctx)
+			mr.publishChanges(ctx)
+			res = mr.complete(ctx, payloads[i])
+		case failed >= 0 && i != failed:
+			res = mr.failed(errors.Errorf("mutation %s wasn't committed, because mutation %s "+
+				"in the same @atomic operation failed", mr.mutation.ResponseName(),
+				mutations[failed].ResponseName()))
+		default:
+			res = mr.failed(err)
+		}
+		res.addTo(resp)
+	}
+}
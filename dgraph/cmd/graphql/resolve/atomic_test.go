@@ -0,0 +1,106 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtomicCommitsOnce(t *testing.T) {
+	client := &countingDgraph{mockDgraph: mockDgraph{
+		assigned: map[string]string{"Document1": "0x1"},
+		results: []string{
+			`{"document": [{"text": "A", "version": 1}]}`,
+			`{"updateDocument": [{"uid": "0x2", "Document.version": 3}]}`,
+			`{"document": [{"text": "B", "version": 4}]}`,
+		},
+	}}
+
+	resp := resolverFor(t, versionSchema, client).Resolve(context.Background(),
+		&schema.Request{Query: `mutation @atomic {
+			addDocument(input: [{text: "A"}]) { document { text } }
+			updateDocument(input: {filter: {id: ["0x2"]}, set: {text: "B"}, version: 3}) {
+				document { text version }
+			}
+		}`})
+	require.Empty(t, resp.Errors)
+
+	require.JSONEq(t, `{
+		"addDocument": {"document": [{"text": "A"}]},
+		"updateDocument": {"document": [{"text": "B", "version": 4}]}
+	}`, resp.Data.String())
+	require.Len(t, client.mutations, 2)
+	require.Equal(t, 1, client.txns)
+	require.Equal(t, 1, client.commits)
+}
+
+func TestAtomicRollsBack(t *testing.T) {
+	client := &countingDgraph{mockDgraph: mockDgraph{
+		assigned: map[string]string{"Document1": "0x1"},
+		results: []string{
+			`{"document": [{"text": "A"}]}`,
+			`{"updateDocument": [{"uid": "0x2", "Document.version": 4}]}`,
+		},
+	}}
+
+	resp := resolverFor(t, versionSchema, client).Resolve(context.Background(),
+		&schema.Request{Query: `mutation @atomic {
+			addDocument(input: [{text: "A"}]) { document { text } }
+			updateDocument(input: {filter: {id: ["0x2"]}, set: {text: "B"}, version: 3}) {
+				document { text }
+			}
+			deleteDocument(filter: {id: ["0x3"]}) { msg }
+		}`})
+
+	require.JSONEq(t, `{"addDocument": null, "updateDocument": null, "deleteDocument": null}`,
+		resp.Data.String())
+	require.Len(t, resp.Errors, 3)
+	require.Equal(t, "mutation addDocument wasn't committed, because mutation updateDocument "+
+		"in the same @atomic operation failed", resp.Errors[0].Message)
+	require.Equal(t, "Document 0x2 is at version 4, not version 3, so it's been updated "+
+		"since that version was read", resp.Errors[1].Message)
+	require.Equal(t, "mutation deleteDocument wasn't committed, because mutation "+
+		"updateDocument in the same @atomic operation failed", resp.Errors[2].Message)
+
+	// The delete never ran, and the add was discarded with the transaction.
+	require.Len(t, client.mutations, 1)
+	require.Len(t, client.queries, 2)
+	require.Equal(t, 0, client.commits)
+}
+
+// countingDgraph is a mockDgraph that counts the transactions it starts and
+// commits.
+type countingDgraph struct {
+	mockDgraph
+	txns    int
+	commits int
+}
+
+func (c *countingDgraph) NewTxn() dgraph.Txn {
+	c.txns++
+	return c
+}
+
+func (c *countingDgraph) Commit(ctx context.Context) error {
+	c.commits++
+	return c.mockDgraph.Commit(ctx)
+}
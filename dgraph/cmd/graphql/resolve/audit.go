@@ -0,0 +1,289 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// auditTimeout bounds how long an audit entry can take to write to a sink.
+// Entries are written after their mutation is committed, so they don't use
+// the mutation's context, which might have run out of time.
+const auditTimeout = 10 * time.Second
+
+// Auditing configures the audit log that a RequestResolver keeps of the
+// mutations it commits: an AuditEntry for each successful mutation, written
+// to every sink.
+type Auditing struct {
+	Sinks []AuditSink
+
+	// Redact names the arguments, and the fields of input objects, whose
+	// values aren't recorded.  Names match regardless of case.
+	Redact []string
+
+	// ActorClaim is the claim of the request's token that says who made the
+	// mutation; "sub" if it's empty.
+	ActorClaim string
+
+	// Namespace is recorded in each entry, so that the namespaces sharing a
+	// sink can be told apart; "" for the default API.
+	Namespace string
+}
+
+// An AuditEntry records a mutation that was committed.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	RequestID string    `json:"requestId,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+
+	// Mutation is the mutation field, e.g. addPost, and Type is the type it
+	// mutated, if it's one of the mutations generated for a type.
+	Mutation string `json:"mutation"`
+	Type     string `json:"type,omitempty"`
+
+	// Arguments are the mutation's arguments, with the values of the
+	// request's variables, redacted.
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+
+	// Actor is the actor claim of the request's token; it's empty if the
+	// request wasn't authenticated.
+	Actor         string `json:"actor,omitempty"`
+	Authenticated bool   `json:"authenticated"`
+
+	// UIDs are the nodes the mutation added, updated, deleted or restored.
+	UIDs []string `json:"uids,omitempty"`
+}
+
+// An AuditSink is where audit entries are written.
+type AuditSink interface {
+	Write(ctx context.Context, entry *AuditEntry) error
+}
+
+// An auditLog writes the audit entries that an Auditing configures.
+type auditLog struct {
+	Auditing
+	redact map[string]bool
+}
+
+func newAuditLog(cfg Auditing) *auditLog {
+	if len(cfg.Sinks) == 0 {
+		return nil
+	}
+	if cfg.ActorClaim == "" {
+		cfg.ActorClaim = "sub"
+	}
+	al := &auditLog{Auditing: cfg, redact: make(map[string]bool, len(cfg.Redact))}
+	for _, name := range cfg.Redact {
+		al.redact[strings.ToLower(name)] = true
+	}
+	return al
+}
+
+// record writes the entry for m, which was committed having mutated the nodes
+// uids, to each of al's sinks.  A sink that fails is logged; the mutation
+// has already happened, so its response isn't changed.
+func (al *auditLog) record(ctx context.Context, m schema.Mutation, uids []uint64) {
+	if al == nil {
+		return
+	}
+
+	entry := &AuditEntry{
+		Timestamp: time.Now().UTC(),
+		RequestID: RequestID(ctx),
+		Namespace: al.Namespace,
+		Mutation:  m.Name(),
+	}
+	switch m.MutationType() {
+	case schema.AddMutation, schema.UpdateMutation, schema.DeleteMutation,
+		schema.RestoreMutation:
+		entry.Type = m.MutatedType().Name()
+	}
+	if args := m.Arguments(); len(args) > 0 {
+		entry.Arguments = redactValue(al.redact, args).(map[string]interface{})
+	}
+	if claims := authorization.Claims(ctx); claims != nil {
+		entry.Authenticated = true
+		if actor, ok := claims[al.ActorClaim]; ok {
+			entry.Actor = fmt.Sprint(actor)
+		}
+	}
+	for _, uid := range uids {
+		entry.UIDs = append(entry.UIDs, fmt.Sprintf("%#x", uid))
+	}
+
+	wctx, cancel := context.WithTimeout(context.Background(), auditTimeout)
+	defer cancel()
+	for _, sink := range al.Sinks {
+		if err := sink.Write(wctx, entry); err != nil {
+			glog.Errorf("Error writing the audit entry for mutation %s of request %s: %v",
+				entry.Mutation, entry.RequestID, err)
+		}
+	}
+}
+
+type fileAuditSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAuditSink returns an AuditSink that appends entries to the file at
+// path, a JSON object per line.  The file is created if it doesn't exist.
+func NewFileAuditSink(path string) (AuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "while opening the audit log")
+	}
+	return &fileAuditSink{file: file}, nil
+}
+
+func (s *fileAuditSink) Write(ctx context.Context, entry *AuditEntry) error {
+	js, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(js, '\n'))
+	return err
+}
+
+type webhookAuditSink struct {
+	url     string
+	secrets secrets.Provider
+	client  *external.Client
+}
+
+// NewWebhookAuditSink returns an AuditSink that POSTs each entry, as JSON, to
+// url, with the secrets url references, as {{secrets.NAME}}, looked up in p.
+// Failed calls are retried as url's policy, in ps, says.
+func NewWebhookAuditSink(url string, p secrets.Provider, ps external.Policies) AuditSink {
+	client := external.NewClient(nil)
+	client.SetPolicies(ps)
+	return &webhookAuditSink{url: url, secrets: p, client: client}
+}
+
+func (s *webhookAuditSink) Write(ctx context.Context, entry *AuditEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	u, err := secrets.Expand(ctx, s.secrets, s.url)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return errors.Errorf("couldn't make a request for %s", s.url)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if _, err = s.client.Do(req.WithContext(ctx), s.client.Policy(u)); err != nil {
+		return external.RedactURL(err, u, s.url)
+	}
+	return nil
+}
+
+const (
+	auditType               = "dgraph.graphql.audit"
+	auditEntryPredicate     = "dgraph.graphql.audit.entry"
+	auditTimestampPredicate = "dgraph.graphql.audit.timestamp"
+
+	auditSchema = `
+type dgraph.graphql.audit {
+	dgraph.graphql.audit.entry: string
+	dgraph.graphql.audit.timestamp: datetime
+}
+dgraph.graphql.audit.entry: string .
+dgraph.graphql.audit.timestamp: datetime @index(hour) .
+`
+)
+
+type dgraphAuditSink struct {
+	client dgraph.Client
+
+	mu      sync.Mutex
+	altered bool
+}
+
+// NewDgraphAuditSink returns an AuditSink that stores each entry in Dgraph,
+// as a node of type dgraph.graphql.audit with the entry's JSON in
+// dgraph.graphql.audit.entry and its time in dgraph.graphql.audit.timestamp.
+// The predicates are added to Dgraph's schema before the first entry is
+// stored.
+func NewDgraphAuditSink(client dgraph.Client) AuditSink {
+	return &dgraphAuditSink{client: client}
+}
+
+func (s *dgraphAuditSink) Write(ctx context.Context, entry *AuditEntry) error {
+	if err := s.alter(ctx); err != nil {
+		return err
+	}
+
+	js, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	setJSON, err := json.Marshal(map[string]interface{}{
+		"uid":                   "_:entry",
+		"dgraph.type":           auditType,
+		auditEntryPredicate:     string(js),
+		auditTimestampPredicate: entry.Timestamp.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return err
+	}
+
+	txn := s.client.NewTxn()
+	defer txn.Discard(ctx)
+	if _, err := txn.Mutate(ctx, &api.Mutation{SetJson: setJSON}); err != nil {
+		return err
+	}
+	return txn.Commit(ctx)
+}
+
+// alter adds the audit predicates to Dgraph's schema, if s hasn't yet.
+func (s *dgraphAuditSink) alter(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.altered {
+		return nil
+	}
+	if err := s.client.Alter(ctx, auditSchema); err != nil {
+		return errors.Wrap(err, "while adding the audit predicates to Dgraph's schema")
+	}
+	s.altered = true
+	return nil
+}
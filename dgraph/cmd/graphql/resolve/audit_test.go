@@ -0,0 +1,178 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+type memAuditSink struct {
+	entries []*AuditEntry
+}
+
+func (s *memAuditSink) Write(ctx context.Context, entry *AuditEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestAuditLog(t *testing.T) {
+	sink := &memAuditSink{}
+	client := &mockDgraph{
+		assigned: map[string]string{"Author1": "0x1"},
+		results:  []string{`{"author": [{"name": "A.N. Author"}]}`},
+	}
+	resolver := resolverFor(t, testSchema, client).WithAuditing(Auditing{
+		Sinks:     []AuditSink{sink},
+		Redact:    []string{"DOB"},
+		Namespace: "acme",
+	})
+	ctx := authorization.WithClaims(WithRequestID(context.Background(), "req-1"),
+		map[string]interface{}{"sub": "user-1"})
+
+	resp := resolver.Resolve(ctx, &schema.Request{
+		Query: `mutation addAuthor($auth: AddAuthorInput!) {
+			addAuthor(input: [$auth]) { author { name } }
+		}`,
+		Variables: map[string]interface{}{
+			"auth": map[string]interface{}{"name": "A.N. Author", "dob": "2000-01-01"},
+		},
+	})
+	require.Empty(t, resp.Errors)
+
+	require.Len(t, sink.entries, 1)
+	entry := sink.entries[0]
+	require.WithinDuration(t, time.Now(), entry.Timestamp, time.Minute)
+	entry.Timestamp = time.Time{}
+	require.Equal(t, &AuditEntry{
+		RequestID: "req-1",
+		Namespace: "acme",
+		Mutation:  "addAuthor",
+		Type:      "Author",
+		Arguments: map[string]interface{}{
+			"input": []interface{}{
+				map[string]interface{}{"name": "A.N. Author", "dob": "<redacted>"},
+			},
+		},
+		Actor:         "user-1",
+		Authenticated: true,
+		UIDs:          []string{"0x1"},
+	}, entry)
+
+	// A mutation that isn't committed isn't audited.
+	client.mutateErr = errors.New("Dgraph's down")
+	resp = resolver.Resolve(context.Background(), &schema.Request{
+		Query: `mutation { addAuthor(input: [{name: "B"}]) { author { name } } }`,
+	})
+	require.NotEmpty(t, resp.Errors)
+	require.Len(t, sink.entries, 1)
+}
+
+func TestFileAuditSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	sink, err := NewFileAuditSink(path)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(context.Background(),
+		&AuditEntry{Mutation: "addAuthor", UIDs: []string{"0x1"}}))
+	require.NoError(t, sink.Write(context.Background(),
+		&AuditEntry{Mutation: "deleteAuthor", Actor: "user-1"}))
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.Len(t, lines, 2)
+	var entry AuditEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	require.Equal(t, "deleteAuthor", entry.Mutation)
+	require.Equal(t, "user-1", entry.Actor)
+
+	_, err = NewFileAuditSink(filepath.Join(dir, "no", "such", "audit.log"))
+	require.Error(t, err)
+}
+
+func TestWebhookAuditSink(t *testing.T) {
+	var got []*AuditEntry
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "s3cr3t" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var entry AuditEntry
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&entry))
+		got = append(got, &entry)
+	}))
+	defer hook.Close()
+
+	require.NoError(t, os.Setenv("AUDIT_TEST_KEY", "s3cr3t"))
+	defer os.Unsetenv("AUDIT_TEST_KEY")
+	env := secrets.NewEnvProvider("AUDIT_TEST_")
+
+	sink := NewWebhookAuditSink(hook.URL+"/?key={{secrets.KEY}}", env,
+		external.DefaultPolicies)
+	require.NoError(t, sink.Write(context.Background(), &AuditEntry{Mutation: "addAuthor"}))
+	require.Len(t, got, 1)
+	require.Equal(t, "addAuthor", got[0].Mutation)
+
+	require.NoError(t, os.Setenv("AUDIT_TEST_KEY", "wrong"))
+	err := sink.Write(context.Background(), &AuditEntry{Mutation: "addAuthor"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "{{secrets.KEY}}")
+	require.NotContains(t, err.Error(), "wrong", "the secret isn't reported")
+}
+
+func TestDgraphAuditSink(t *testing.T) {
+	client := &mockDgraph{}
+	sink := NewDgraphAuditSink(client)
+
+	entry := &AuditEntry{
+		Timestamp: time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC),
+		Mutation:  "addAuthor",
+	}
+	require.NoError(t, sink.Write(context.Background(), entry))
+	require.True(t, client.committed)
+
+	require.Len(t, client.mutations, 1)
+	var node map[string]interface{}
+	require.NoError(t, json.Unmarshal(client.mutations[0].SetJson, &node))
+	js, err := json.Marshal(entry)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"uid":                            "_:entry",
+		"dgraph.type":                    "dgraph.graphql.audit",
+		"dgraph.graphql.audit.entry":     string(js),
+		"dgraph.graphql.audit.timestamp": "2019-07-01T12:00:00Z",
+	}, node)
+}
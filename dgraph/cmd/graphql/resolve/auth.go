@@ -0,0 +1,300 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// An authorizer applies the @auth rules of the schema's types, and the @auth
+// roles of its fields, to one request, using the request's claims.
+//
+// Rules are applied by rewriting: a query rule is and-ed into the filter of
+// every query, and every edge, that reaches nodes of its type; update and
+// delete rules are and-ed into the filter that picks the nodes to change; and
+// nodes that are added, at any depth of a mutation's input, are checked
+// against the add rule before the transaction commits.  Existing nodes that
+// the input links to, and so writes an inverse edge to, or that an @xid
+// input writes the fields of, are checked against the update rule before the
+// mutation is run.
+//
+// A field that the request doesn't have the roles for isn't fetched from
+// Dgraph, and is null, with an error, in the result.  Filtering or ordering
+// by it, or setting it in a mutation, is an error.
+type authorizer struct {
+	claims map[string]interface{}
+
+	// read, if it's not nil, collects the claims that the rules and field
+	// roles applied so far have looked at.
+	read map[string]bool
+}
+
+func newAuthorizer(ctx context.Context) *authorizer {
+	return &authorizer{claims: authorization.Claims(ctx)}
+}
+
+// filter builds the Dgraph filter that restricts op on typ to the nodes the
+// request is allowed.  It's nil if typ has no rule for op.  If the rule needs
+// a claim that the request doesn't have, no nodes are allowed.
+//
+// Interfaces don't have rules of their own, so an interface's nodes are
+// restricted by the rules of the types implementing it: each node must either
+// not be of an implementing type or satisfy that type's rule.
+func (a *authorizer) filter(typ schema.Type, op schema.AuthOperation) (*gql.FilterTree, error) {
+	if impls := typ.Implementations(); len(impls) > 0 {
+		var fts []*gql.FilterTree
+		for _, impl := range impls {
+			ft, err := a.filter(impl, op)
+			if err != nil {
+				return nil, err
+			}
+			if ft != nil {
+				notImpl := &gql.FilterTree{Op: "not", Child: []*gql.FilterTree{typeFilter(impl)}}
+				fts = append(fts, combine("or", notImpl, ft))
+			}
+		}
+		return combine("and", fts...), nil
+	}
+
+	rule := typ.AuthRule(op)
+	if rule == nil {
+		return nil, nil
+	}
+
+	a.reading(rule.Claims()...)
+	filter, err := rule.Filter(a.claims)
+	if err != nil {
+		if glog.V(3) {
+			glog.Infof("Denying %s on %s: %s", op, typ.Name(), err)
+		}
+		return denyAll(typ), nil
+	}
+	return buildFilter(typ, filter)
+}
+
+// checkNodes checks that nodes, which a mutation in txn added or wrote the
+// edges of, satisfy the rule for op of their types.  Blank nodes are looked
+// up in assigned, the uids that txn gave them.
+func (a *authorizer) checkNodes(ctx context.Context, txn dgraph.Txn, op schema.AuthOperation,
+	nodes *authNodes, assigned map[string]string) error {
+
+	for _, name := range nodes.typeNames() {
+		typ := nodes.types[name]
+		ft, err := a.filter(typ, op)
+		if err != nil {
+			return err
+		}
+		if ft == nil {
+			continue
+		}
+
+		uids := make([]uint64, 0, len(nodes.uids[name]))
+		for _, node := range nodes.uids[name] {
+			id := node
+			if strings.HasPrefix(node, "_:") {
+				id = assigned[strings.TrimPrefix(node, "_:")]
+			}
+			uid, err := strconv.ParseUint(id, 0, 64)
+			if err != nil {
+				return errors.Errorf("couldn't find the uid assigned to new node %s", node)
+			}
+			uids = append(uids, uid)
+		}
+
+		authorized, err := queryNodes(ctx, txn, &gql.GraphQuery{
+			Attr:     "authorized",
+			Func:     &gql.Function{Name: "uid", UID: uids},
+			Filter:   ft,
+			Children: []*gql.GraphQuery{{Attr: "uid"}},
+		})
+		if err != nil {
+			return err
+		}
+		if len(authorized) != len(uids) {
+			return &UnauthorizedError{Action: string(op), Type: typ.Name()}
+		}
+	}
+	return nil
+}
+
+// authNodes are nodes of a mutation, grouped by type, that must be checked
+// against a rule of their types: the new nodes the input adds, by blank node
+// name, or the existing nodes it writes fields or inverse edges to, by uid.
+type authNodes struct {
+	types map[string]schema.Type
+	uids  map[string][]string
+}
+
+func (an *authNodes) add(typ schema.Type, uid string) {
+	if an.types == nil {
+		an.types = make(map[string]schema.Type)
+		an.uids = make(map[string][]string)
+	}
+	for _, u := range an.uids[typ.Name()] {
+		if u == uid {
+			return
+		}
+	}
+	an.types[typ.Name()] = typ
+	an.uids[typ.Name()] = append(an.uids[typ.Name()], uid)
+}
+
+// typeNames are the names of the types with nodes in an, in order, so that
+// they're always checked in the same order.
+func (an *authNodes) typeNames() []string {
+	names := make([]string, 0, len(an.types))
+	for name := range an.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// allows returns true if the request can see and set fields with the @auth
+// directive fa.
+func (a *authorizer) allows(fa *schema.FieldAuth) bool {
+	if fa == nil {
+		return true
+	}
+	a.reading(fa.Claim)
+	return authorization.HasRole(a.claims[fa.Claim], fa.Roles...)
+}
+
+// reading notes that claims have been looked at, if a is collecting them.
+func (a *authorizer) reading(claims ...string) {
+	if a.read == nil {
+		return
+	}
+	for _, c := range claims {
+		a.read[c] = true
+	}
+}
+
+// denyFields puts a failedValue in val, the Dgraph result for field at path,
+// in place of each field that the request can't see, so that completing the
+// result makes it null with an error.  Paths are as they are in
+// findCustomCalls.
+func (a *authorizer) denyFields(path []interface{}, field schema.Field, val interface{}) {
+	switch v := val.(type) {
+	case []interface{}:
+		isList := field.Type().ListType() != nil
+		for i, item := range v {
+			itemPath := path
+			if isList {
+				itemPath = append(path[:len(path):len(path)], i)
+			}
+			a.denyFields(itemPath, field, item)
+		}
+	case map[string]interface{}:
+		for _, f := range allSelections(field) {
+			fPath := append(path[:len(path):len(path)], f.ResponseName())
+			if !a.allows(f.Auth()) {
+				errs := fieldErrors(f, &UnauthorizedError{
+					Action: "query", Type: f.GetObjectName(), Field: f.Name()})
+				for _, e := range errs {
+					e.Path = fPath
+				}
+				v[f.ResponseName()] = &failedValue{errs: errs}
+				continue
+			}
+			a.denyFields(fPath, f, v[f.ResponseName()])
+		}
+	}
+}
+
+// checkArguments returns an error if filter or order, the arguments of a
+// query on typ, use a field that the request can't see.  Otherwise, which
+// objects are found, or their order, could give the field's values away.
+func (a *authorizer) checkArguments(typ schema.Type, filter, order map[string]interface{}) error {
+	var names []interface{}
+	var addFilter func(filter map[string]interface{})
+	addFilter = func(filter map[string]interface{}) {
+		for key, val := range filter {
+			switch key {
+			case "and", "or", "not":
+				sub, _ := val.(map[string]interface{})
+				addFilter(sub)
+			case "has":
+				if list, ok := val.([]interface{}); ok {
+					names = append(names, list...)
+				} else {
+					names = append(names, val)
+				}
+			default:
+				names = append(names, key)
+			}
+		}
+	}
+	addFilter(filter)
+	for order != nil {
+		names = append(names, order["asc"], order["desc"])
+		order, _ = order["then"].(map[string]interface{})
+	}
+
+	for _, name := range names {
+		s, _ := name.(string)
+		if fd := typ.Field(s); fd != nil && !a.allows(fd.Auth()) {
+			return &UnauthorizedError{Action: "filter by", Type: typ.Name(), Field: fd.Name()}
+		}
+	}
+	return nil
+}
+
+// checkInput returns an error if val, the input for objects of typ in a
+// mutation, sets a field that the request can't.  Objects of other types,
+// that the input adds or links to, are checked too.
+func (a *authorizer) checkInput(typ schema.Type, val interface{}) error {
+	switch v := val.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if err := a.checkInput(typ, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for key, fv := range v {
+			fd := typ.Field(key)
+			if fd == nil {
+				continue
+			}
+			if !a.allows(fd.Auth()) {
+				return &UnauthorizedError{Action: "set", Type: typ.Name(), Field: fd.Name()}
+			}
+			if len(fd.Type().Fields()) > 0 {
+				if err := a.checkInput(fd.Type(), fv); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// denyAll is a filter that no node of type typ satisfies.
+func denyAll(typ schema.Type) *gql.FilterTree {
+	return &gql.FilterTree{Op: "not", Child: []*gql.FilterTree{typeFilter(typ)}}
+}
@@ -0,0 +1,375 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const authSchema = `
+type User {
+	username: String! @search(by: [hash])
+	todos: [Todo]
+	team: Team
+}
+
+type Team @auth(update: "{ owner: { eq: $USER } }") {
+	id: ID!
+	owner: String! @search(by: [hash])
+	members: [User] @hasInverse(field: team)
+}
+
+type Todo @auth(
+	query: "{ or: { isPublic: true }, owner: { eq: $USER } }",
+	add: "{ owner: { eq: $USER } }",
+	update: "{ owner: { eq: $USER } }",
+	delete: "{ owner: { eq: $USER }, isPublic: false }") {
+	id: ID!
+	text: String
+	owner: String! @search(by: [hash])
+	isPublic: Boolean @search
+}
+
+interface Entry {
+	id: ID!
+	title: String
+}
+
+type Diary implements Entry @auth(query: "{ owner: { eq: $USER } }") {
+	id: ID!
+	title: String
+	owner: String! @search(by: [hash])
+}
+
+type Notice implements Entry {
+	id: ID!
+	title: String
+}
+
+type Report @auth(rule: "{ or: { $ROLE: { eq: \"ADMIN\" } }, owner: { eq: $USER } }") {
+	id: ID!
+	owner: String! @search(by: [hash])
+}
+
+type Employee {
+	id: ID!
+	name: String @search(by: [hash])
+	salary: Int @search @auth(roles: ["HR", "ADMIN"])
+}
+`
+
+func TestAuthQueryRewriting(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		claims   map[string]interface{}
+		expected string
+	}{
+		{
+			name:   "query rule",
+			query:  `query { queryTodo(filter: { isPublic: false }) { text } }`,
+			claims: map[string]interface{}{"USER": "alice"},
+			expected: `query {
+  queryTodo(func: type(Todo)) @filter((eq(Todo.isPublic, "false") AND ` +
+				`(eq(Todo.owner, "alice") OR eq(Todo.isPublic, "true")))) {
+    text : Todo.text
+  }
+}`,
+		},
+		{
+			name:   "get",
+			query:  `query { getTodo(id: "0x1") { text } }`,
+			claims: map[string]interface{}{"USER": "alice"},
+			expected: `query {
+  getTodo(func: uid(0x1)) @filter((type(Todo) AND ` +
+				`(eq(Todo.owner, "alice") OR eq(Todo.isPublic, "true")))) {
+    text : Todo.text
+  }
+}`,
+		},
+		{
+			name:   "edges to the type are filtered",
+			query:  `query { queryUser { todos { text } } }`,
+			claims: map[string]interface{}{"USER": "alice"},
+			expected: `query {
+  queryUser(func: type(User)) {
+    todos : User.todos @filter((eq(Todo.owner, "alice") OR eq(Todo.isPublic, "true"))) {
+      text : Todo.text
+    }
+  }
+}`,
+		},
+		{
+			name:   "interfaces apply the rules of their types",
+			query:  `query { queryEntry { title } }`,
+			claims: map[string]interface{}{"USER": "alice"},
+			expected: `query {
+  queryEntry(func: type(Entry)) @filter((NOT (type(Diary)) OR eq(Diary.owner, "alice"))) {
+    dgraph.type : dgraph.type
+    title : Entry.title
+  }
+}`,
+		},
+		{
+			name:   "claim conditions that hold don't filter",
+			query:  `query { queryReport { owner } }`,
+			claims: map[string]interface{}{"ROLE": "ADMIN"},
+			expected: `query {
+  queryReport(func: type(Report)) {
+    owner : Report.owner
+  }
+}`,
+		},
+		{
+			name:   "claim conditions that don't hold leave the rest of the rule",
+			query:  `query { queryReport { owner } }`,
+			claims: map[string]interface{}{"ROLE": "USER", "USER": "alice"},
+			expected: `query {
+  queryReport(func: type(Report)) @filter(eq(Report.owner, "alice")) {
+    owner : Report.owner
+  }
+}`,
+		},
+		{
+			name:  "missing claims deny everything",
+			query: `query { queryUser { todos { text } } }`,
+			expected: `query {
+  queryUser(func: type(User)) {
+    todos : User.todos @filter(NOT (type(Todo))) {
+      text : Todo.text
+    }
+  }
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			op := operationFor(t, authSchema, test.query)
+			require.Len(t, op.Queries(), 1)
+
+			dgQuery, err := rewriteAsQuery(op.Queries()[0], &authorizer{claims: test.claims})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, dgraph.AsString(dgQuery))
+		})
+	}
+}
+
+func TestAuthMutations(t *testing.T) {
+	ctx := authorization.WithClaims(context.Background(),
+		map[string]interface{}{"USER": "alice"})
+	resolve := func(t *testing.T, client *mockDgraph, gqlQuery string) *resolved {
+		op := operationFor(t, authSchema, gqlQuery)
+		require.Len(t, op.Mutations(), 1)
+		mr := &mutationResolver{mutation: op.Mutations()[0], dgraphClient: client}
+		return mr.resolve(ctx)
+	}
+
+	t.Run("add is checked before commit", func(t *testing.T) {
+		client := &mockDgraph{
+			assigned: map[string]string{"Todo1": "0x1", "Todo2": "0x2"},
+			results:  []string{`{"authorized": [{"uid": "0x1"}]}`},
+		}
+		res := resolve(t, client, `mutation {
+			addTodo(input: [{ owner: "alice" }, { owner: "bob" }]) { todo { text } }
+		}`)
+
+		errs := schema.AsGQLErrors(res.err)
+		require.Len(t, errs, 1)
+		require.Equal(t, "Not authorized to add Todo", errs[0].Message)
+		require.Equal(t, unauthorizedCode, errs[0].Extensions["code"])
+		require.False(t, client.committed)
+		require.Equal(t, []string{`query {
+  authorized(func: uid(0x1, 0x2)) @filter(eq(Todo.owner, "alice")) {
+    uid
+  }
+}`}, client.queries)
+	})
+
+	t.Run("nested adds are checked against their own type's rule", func(t *testing.T) {
+		client := &mockDgraph{
+			assigned: map[string]string{"User1": "0x1", "Todo2": "0x2"},
+			results:  []string{`{"authorized": []}`},
+		}
+		res := resolve(t, client, `mutation {
+			addUser(input: [{ username: "x", todos: [{ owner: "bob", text: "forged" }] }]) {
+				user { username }
+			}
+		}`)
+
+		errs := schema.AsGQLErrors(res.err)
+		require.Len(t, errs, 1)
+		require.Equal(t, "Not authorized to add Todo", errs[0].Message)
+		require.False(t, client.committed)
+		require.Equal(t, []string{`query {
+  authorized(func: uid(0x2)) @filter(eq(Todo.owner, "alice")) {
+    uid
+  }
+}`}, client.queries)
+	})
+
+	t.Run("linking to a node checks its update rule", func(t *testing.T) {
+		tests := []struct {
+			mutation string
+			results  []string
+		}{
+			{
+				mutation: `mutation {
+					addUser(input: [{ username: "x", team: { id: "0x9" } }]) {
+						user { username }
+					}
+				}`,
+				results: []string{`{"authorized": []}`},
+			},
+			{
+				mutation: `mutation {
+					updateUser(input: { filter: { username: { eq: "x" } },
+						set: { team: { id: "0x9" } } }) { user { username } }
+				}`,
+				results: []string{`{"updateUser": [{"uid": "0x1"}]}`, `{"authorized": []}`},
+			},
+		}
+		for _, test := range tests {
+			client := &mockDgraph{results: test.results}
+			res := resolve(t, client, test.mutation)
+
+			errs := schema.AsGQLErrors(res.err)
+			require.Len(t, errs, 1, test.mutation)
+			require.Equal(t, "Not authorized to update Team", errs[0].Message, test.mutation)
+			require.Empty(t, client.mutations, test.mutation)
+			require.False(t, client.committed, test.mutation)
+			require.Equal(t, `query {
+  authorized(func: uid(0x9)) @filter(eq(Team.owner, "alice")) {
+    uid
+  }
+}`, client.queries[len(client.queries)-1], test.mutation)
+		}
+	})
+
+	t.Run("update only changes what the rule allows", func(t *testing.T) {
+		client := &mockDgraph{}
+		res := resolve(t, client, `mutation {
+			updateTodo(input: { filter: { isPublic: true }, set: { text: "X" } }) {
+				todo { text }
+			}
+		}`)
+
+		require.NoError(t, res.err)
+		require.Equal(t, `query {
+  updateTodo(func: type(Todo)) @filter((eq(Todo.isPublic, "true") AND eq(Todo.owner, "alice"))) {
+    uid
+  }
+}`, client.queries[0])
+	})
+
+	t.Run("delete only removes what the rule allows", func(t *testing.T) {
+		client := &mockDgraph{}
+		res := resolve(t, client, `mutation { deleteTodo(filter: { id: ["0x1"] }) { msg } }`)
+
+		require.NoError(t, res.err)
+		expected := `query {
+  deleteTodo(func: type(Todo)) @filter((uid(0x1) AND ` +
+			`(eq(Todo.isPublic, "false") AND eq(Todo.owner, "alice")))) {
+    uid
+  }
+}`
+		require.Equal(t, expected, client.queries[0])
+	})
+}
+
+func TestFieldAuth(t *testing.T) {
+	user := authorization.WithClaims(context.Background(),
+		map[string]interface{}{"USER": "alice", "ROLE": "USER"})
+	hr := authorization.WithClaims(context.Background(),
+		map[string]interface{}{"USER": "bob", "ROLE": []interface{}{"USER", "HR"}})
+
+	t.Run("fields are only fetched for requests with the roles", func(t *testing.T) {
+		op := operationFor(t, authSchema, `query { queryEmployee { name salary } }`)
+		dgQuery, err := rewriteAsQuery(op.Queries()[0], newAuthorizer(user))
+		require.NoError(t, err)
+		require.Equal(t, `query {
+  queryEmployee(func: type(Employee)) {
+    name : Employee.name
+  }
+}`, dgraph.AsString(dgQuery))
+
+		dgQuery, err = rewriteAsQuery(op.Queries()[0], newAuthorizer(hr))
+		require.NoError(t, err)
+		require.Contains(t, dgraph.AsString(dgQuery), "salary : Employee.salary")
+	})
+
+	t.Run("fields are null, with an error, for others", func(t *testing.T) {
+		client := &mockDgraph{results: []string{`{"queryEmployee": [{"name": "Ann"}]}`}}
+		resp := resolverFor(t, authSchema, client).Resolve(user,
+			&schema.Request{Query: `query { queryEmployee { name salary } }`})
+		require.JSONEq(t, `{"queryEmployee": [{"name": "Ann", "salary": null}]}`,
+			resp.Data.String())
+		require.Len(t, resp.Errors, 1)
+		require.Equal(t, "Not authorized to query Employee.salary", resp.Errors[0].Message)
+		require.Equal(t, []interface{}{"queryEmployee", 0, "salary"}, resp.Errors[0].Path)
+		require.Equal(t, unauthorizedCode, resp.Errors[0].Extensions["code"])
+
+		client = &mockDgraph{results: []string{
+			`{"queryEmployee": [{"name": "Ann", "salary": 100}]}`}}
+		resp = resolverFor(t, authSchema, client).Resolve(hr,
+			&schema.Request{Query: `query { queryEmployee { name salary } }`})
+		require.Empty(t, resp.Errors)
+		require.JSONEq(t, `{"queryEmployee": [{"name": "Ann", "salary": 100}]}`,
+			resp.Data.String())
+	})
+
+	t.Run("others can't filter or order by the fields", func(t *testing.T) {
+		for _, query := range []string{
+			`query { queryEmployee(filter: { salary: { gt: 100 } }) { name } }`,
+			`query { queryEmployee(filter: { not: { has: salary } }) { name } }`,
+			`query { queryEmployee(order: { asc: name, then: { desc: salary } }) { name } }`,
+		} {
+			op := operationFor(t, authSchema, query)
+			_, err := rewriteAsQuery(op.Queries()[0], newAuthorizer(user))
+			require.EqualError(t, err, "Not authorized to filter by Employee.salary", query)
+
+			_, err = rewriteAsQuery(op.Queries()[0], newAuthorizer(hr))
+			require.NoError(t, err, query)
+		}
+	})
+
+	t.Run("others can't set the fields", func(t *testing.T) {
+		for _, mutation := range []string{
+			`mutation { addEmployee(input: [{ name: "Ann", salary: 100 }]) { employee { name } } }`,
+			`mutation { updateEmployee(input: { filter: { name: { eq: "Ann" } },
+				set: { salary: 100 } }) { employee { name } } }`,
+			`mutation { updateEmployee(input: { filter: { salary: { gt: 100 } },
+				set: { name: "Ann" } }) { employee { name } } }`,
+		} {
+			op := operationFor(t, authSchema, mutation)
+			client := &mockDgraph{}
+			mr := &mutationResolver{mutation: op.Mutations()[0], dgraphClient: client}
+			res := mr.resolve(user)
+			errs := schema.AsGQLErrors(res.err)
+			require.Len(t, errs, 1, mutation)
+			require.Contains(t, errs[0].Message, "Not authorized to", mutation)
+			require.Equal(t, unauthorizedCode, errs[0].Extensions["code"])
+			require.False(t, client.committed)
+		}
+	})
+}
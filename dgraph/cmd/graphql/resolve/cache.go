@@ -0,0 +1,236 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+)
+
+const (
+	// maxCachedBytes limits the total size of the responses in a
+	// responseCache; once it's full, the oldest responses are forgotten.
+	maxCachedBytes = 64 << 20
+
+	// maxCachedResponse is the size of the largest response that's cached.
+	maxCachedResponse = 1 << 20
+)
+
+// cachePolicy returns how long the response to op, a request with the claims
+// in ctx, can be cached, and by whom, or nil if it can't be.  That's worked
+// out from the @cacheControl hints of the fields op asks for, as Apollo
+// Server does:
+//   - the response can be cached for as long as the shortest maxAge of its
+//     fields,
+//   - a field without a maxAge has its type's maxAge, or, if it's a root
+//     field or returns an object and its type has no maxAge, it can't be
+//     cached; other fields take the maxAge of the object they're in,
+//   - a response is private if any field's scope is, or if the request was
+//     authenticated, as what it sees depends on who it is.
+//
+// Only queries can be cached.
+func cachePolicy(ctx context.Context, op schema.Operation) *schema.CacheHint {
+	if !op.IsQuery() {
+		return nil
+	}
+
+	policy := &schema.CacheHint{MaxAge: math.MaxInt64, HasMaxAge: true, Scope: schema.PublicCache}
+	var roots []schema.Field
+	for _, q := range op.Queries() {
+		roots = append(roots, q)
+	}
+	restrictPolicy(policy, roots, 0, true)
+	if policy.MaxAge <= 0 || policy.MaxAge == math.MaxInt64 {
+		return nil
+	}
+
+	if authorization.Claims(ctx) != nil {
+		policy.Scope = schema.PrivateCache
+	}
+	return policy
+}
+
+// restrictPolicy restricts policy by the hints of fields, whose object can be
+// cached for parentAge.
+func restrictPolicy(policy *schema.CacheHint, fields []schema.Field,
+	parentAge time.Duration, root bool) {
+
+	for _, f := range fields {
+		// __typename can't change.
+		if f.Name() == "__typename" {
+			continue
+		}
+
+		hint := f.CacheHint()
+		age := parentAge
+		switch {
+		case hint.HasMaxAge:
+			age = hint.MaxAge
+		case root || len(f.Type().Fields()) > 0:
+			age = 0
+		}
+		if age < policy.MaxAge {
+			policy.MaxAge = age
+		}
+		if hint.Scope == schema.PrivateCache {
+			policy.Scope = schema.PrivateCache
+		}
+
+		restrictPolicy(policy, allSelections(f), age, false)
+	}
+}
+
+// A responseCache keeps the responses to queries that can be cached, until
+// they're too old.  Responses are keyed by the query, its variables and the
+// claims of the request, so an authenticated request only ever sees the
+// responses to requests with the same claims.
+//
+// The cache is for one schema at a time, and each time it's cleared it
+// starts a new generation.  A response is only cached if it was resolved
+// against the cache's schema, and in its current generation, so a query that
+// was still being resolved when the schema changed, or when a mutation
+// cleared the cache, can't put back what was cleared.
+type responseCache struct {
+	mu         sync.Mutex
+	entries    map[[sha256.Size]byte]*cachedResponse
+	order      [][sha256.Size]byte
+	size       int
+	sch        schema.Schema
+	generation uint64
+}
+
+type cachedResponse struct {
+	data    []byte
+	scope   schema.CacheScope
+	expires time.Time
+}
+
+func newResponseCache(sch schema.Schema) *responseCache {
+	return &responseCache{entries: make(map[[sha256.Size]byte]*cachedResponse), sch: sch}
+}
+
+// cacheKey returns the key that the response to gqlReq, a request with the
+// claims in ctx, is cached under.
+func cacheKey(ctx context.Context, gqlReq *schema.Request) [sha256.Size]byte {
+	// A query sent by hash is the same query as the one sent in full.
+	hash := QueryHash(gqlReq.Query)
+	if gqlReq.Query == "" {
+		ext, _ := gqlReq.Extensions["persistedQuery"].(map[string]interface{})
+		hash, _ = ext["sha256Hash"].(string)
+	}
+
+	// Maps are marshalled with sorted keys, so equal requests have equal keys.
+	key, _ := json.Marshal(struct {
+		Hash          string                 `json:"hash"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+		Claims        map[string]interface{} `json:"claims"`
+	}{hash, gqlReq.OperationName, gqlReq.Variables, authorization.Claims(ctx)})
+	return sha256.Sum256(key)
+}
+
+// get returns the response cached under key, for a request resolved against
+// sch, or nil if there isn't one that's still fresh.  It also returns the
+// cache's generation, which a response resolved now is put in.
+func (rc *responseCache) get(sch schema.Schema,
+	key [sha256.Size]byte) (*schema.Response, uint64) {
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry := rc.entries[key]
+	if entry == nil || sch != rc.sch {
+		return nil, rc.generation
+	}
+	maxAge := time.Until(entry.expires)
+	if maxAge < time.Second {
+		// Leave it to be evicted; it won't be found again.
+		return nil, rc.generation
+	}
+
+	resp := &schema.Response{Cache: &schema.CacheHint{
+		MaxAge: maxAge.Truncate(time.Second), HasMaxAge: true, Scope: entry.scope}}
+	resp.Data.Write(entry.data)
+	return resp, rc.generation
+}
+
+// put caches resp, resolved against sch in generation gen, under key, for as
+// long as resp.Cache allows.  If the cache has since been cleared, or is for
+// another schema now, resp isn't cached.
+func (rc *responseCache) put(sch schema.Schema, gen uint64, key [sha256.Size]byte,
+	resp *schema.Response) {
+
+	if resp.Cache == nil || len(resp.Errors) > 0 || resp.Data.Len() > maxCachedResponse {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if sch != rc.sch || gen != rc.generation {
+		return
+	}
+
+	entry := &cachedResponse{
+		data:    append([]byte(nil), resp.Data.Bytes()...),
+		scope:   resp.Cache.Scope,
+		expires: time.Now().Add(resp.Cache.MaxAge),
+	}
+	if old := rc.entries[key]; old != nil {
+		// It's replacing a response that's gone stale, in the same place.
+		rc.size += len(entry.data) - len(old.data)
+		rc.entries[key] = entry
+		return
+	}
+
+	for rc.size+len(entry.data) > maxCachedBytes && len(rc.order) > 0 {
+		rc.size -= len(rc.entries[rc.order[0]].data)
+		delete(rc.entries, rc.order[0])
+		rc.order = rc.order[1:]
+	}
+	rc.entries[key] = entry
+	rc.order = append(rc.order, key)
+	rc.size += len(entry.data)
+}
+
+// clear forgets every cached response, and starts a new generation.
+func (rc *responseCache) clear() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.clearLocked()
+}
+
+// reset clears the cache, which is for sch from now on.
+func (rc *responseCache) reset(sch schema.Schema) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.clearLocked()
+	rc.sch = sch
+}
+
+func (rc *responseCache) clearLocked() {
+	rc.entries = make(map[[sha256.Size]byte]*cachedResponse)
+	rc.order = nil
+	rc.size = 0
+	rc.generation++
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const cacheSchema = `
+type Author @cacheControl(maxAge: 60) {
+	id: ID!
+	name: String!
+	bio: String @cacheControl(maxAge: 10)
+	email: String @cacheControl(scope: PRIVATE)
+	posts: [Post]
+}
+
+type Post {
+	id: ID!
+	title: String!
+}
+`
+
+func TestCachePolicy(t *testing.T) {
+	public := func(secs int) *schema.CacheHint {
+		return &schema.CacheHint{
+			MaxAge: time.Duration(secs) * time.Second, HasMaxAge: true, Scope: schema.PublicCache}
+	}
+	private := func(secs int) *schema.CacheHint {
+		hint := public(secs)
+		hint.Scope = schema.PrivateCache
+		return hint
+	}
+
+	tests := map[string]struct {
+		query  string
+		claims map[string]interface{}
+		policy *schema.CacheHint
+	}{
+		"type's maxAge": {
+			query:  `query { getAuthor(id: "0x1") { __typename name } }`,
+			policy: public(60),
+		},
+		"field's maxAge": {
+			query:  `query { getAuthor(id: "0x1") { name bio } }`,
+			policy: public(10),
+		},
+		"private field": {
+			query:  `query { getAuthor(id: "0x1") { name email } }`,
+			policy: private(60),
+		},
+		"authenticated": {
+			query:  `query { getAuthor(id: "0x1") { name } }`,
+			claims: map[string]interface{}{"USER": "alice"},
+			policy: private(60),
+		},
+		"object without a maxAge": {
+			query: `query { getAuthor(id: "0x1") { name posts { title } } }`,
+		},
+		"introspection": {
+			query: `query { __schema { queryType { name } } }`,
+		},
+		"mutation": {
+			query: `mutation { deletePost(filter: {}) { msg } }`,
+		},
+	}
+
+	for name, tcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			op := operationFor(t, cacheSchema, tcase.query)
+
+			ctx := context.Background()
+			if tcase.claims != nil {
+				ctx = authorization.WithClaims(ctx, tcase.claims)
+			}
+			require.Equal(t, tcase.policy, cachePolicy(ctx, op))
+		})
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Author1": "0x2"},
+		results: []string{
+			`{"getAuthor": [{"name": "A.N. Author"}]}`,
+			`{"getAuthor": [{"name": "A.N. Author"}]}`,
+			`{"author": [{"name": "Another"}]}`,
+			`{"getAuthor": [{"name": "Renamed"}]}`,
+		},
+	}
+	resolver := resolverFor(t, cacheSchema, client)
+	getAuthor := &schema.Request{Query: `query { getAuthor(id: "0x1") { name } }`}
+
+	resp := resolver.Resolve(context.Background(), getAuthor)
+	require.Empty(t, resp.Errors)
+	require.Equal(t, 60*time.Second, resp.Cache.MaxAge)
+
+	// The same query is answered from the cache, but not for someone else.
+	resp = resolver.Resolve(context.Background(), getAuthor)
+	require.JSONEq(t, `{"getAuthor": {"name": "A.N. Author"}}`, resp.Data.String())
+	require.Equal(t, schema.PublicCache, resp.Cache.Scope)
+	require.Len(t, client.queries, 1)
+
+	ctx := authorization.WithClaims(context.Background(), map[string]interface{}{"USER": "a"})
+	resp = resolver.Resolve(ctx, getAuthor)
+	require.Equal(t, schema.PrivateCache, resp.Cache.Scope)
+	require.Len(t, client.queries, 2)
+
+	// A mutation empties the cache.
+	resp = resolver.Resolve(context.Background(), &schema.Request{
+		Query: `mutation { addAuthor(input: [{name: "Another"}]) { author { name } } }`})
+	require.Empty(t, resp.Errors)
+	require.Nil(t, resp.Cache)
+
+	resp = resolver.Resolve(context.Background(), getAuthor)
+	require.JSONEq(t, `{"getAuthor": {"name": "Renamed"}}`, resp.Data.String())
+	require.Len(t, client.queries, 4)
+}
+
+func TestResponseCacheDropsSupersededPuts(t *testing.T) {
+	handler, err := schema.NewHandler(cacheSchema)
+	require.NoError(t, err)
+	oldSch := handler.Schema()
+	handler, err = schema.NewHandler(cacheSchema)
+	require.NoError(t, err)
+	newSch := handler.Schema()
+
+	response := func() *schema.Response {
+		resp := &schema.Response{Cache: &schema.CacheHint{MaxAge: time.Minute}}
+		resp.Data.WriteString(`{"getAuthor": {"name": "A.N. Author"}}`)
+		return resp
+	}
+	key := cacheKey(context.Background(), &schema.Request{Query: `query { getAuthor }`})
+
+	// A response resolved against the old schema isn't cached after the
+	// schema changes, nor is one resolved before the cache was cleared.
+	rc := newResponseCache(oldSch)
+	_, gen := rc.get(oldSch, key)
+	rc.reset(newSch)
+	rc.put(oldSch, gen, key, response())
+	resp, gen := rc.get(newSch, key)
+	require.Nil(t, resp)
+
+	rc.clear()
+	rc.put(newSch, gen, key, response())
+	resp, gen = rc.get(newSch, key)
+	require.Nil(t, resp)
+
+	rc.put(newSch, gen, key, response())
+	resp, _ = rc.get(newSch, key)
+	require.NotNil(t, resp)
+	resp, _ = rc.get(oldSch, key)
+	require.Nil(t, resp)
+}
@@ -0,0 +1,318 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// changesTimeout bounds how long each attempt to publish the changes of a
+// mutation can take.  They're published after the mutation is committed, so
+// they don't use the mutation's context, which might have run out of time.
+const changesTimeout = 10 * time.Second
+
+// maxQueuedChanges is how many changes can be waiting to be published;
+// the changes of mutations committed once there are that many are dropped.
+const maxQueuedChanges = 10000
+
+// DefaultChangeDelivery is how publishing changes is retried, unless a
+// ChangeCapture has its own policy: for a little over five minutes.
+var DefaultChangeDelivery = DefaultEventDelivery
+
+// A Change is a change that a committed mutation made to one object.  Before
+// is the object as it was, for updates, deletes and restores, and After is
+// the object as the mutation left it, for adds, updates, restores and the
+// deletes of @softDelete types.  An image has the object's ID, the values of
+// its stored fields and the IDs of the objects its edges link to.
+type Change struct {
+	Timestamp time.Time `json:"timestamp"`
+	RequestID string    `json:"requestId,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+
+	// Type is the GraphQL type of the object, Operation is add, update,
+	// delete or restore, and Mutation is the mutation field, e.g. addPost.
+	Type      string `json:"type"`
+	Operation string `json:"operation"`
+	Mutation  string `json:"mutation"`
+
+	UID    string                 `json:"uid"`
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+}
+
+// A ChangeSink publishes the changes that mutations make, e.g. to keep a
+// search index or a cache up to date.  The changes of a mutation are
+// published together, once it's committed.
+type ChangeSink interface {
+	Publish(ctx context.Context, changes []*Change) error
+}
+
+// ChangeCapture configures the changes that a RequestResolver publishes.
+type ChangeCapture struct {
+	Sink ChangeSink
+
+	// Namespace is in each change, so that the namespaces sharing a sink can
+	// be told apart; "" for the default API.
+	Namespace string
+
+	// Delivery is how publishing the changes of a mutation is retried; the
+	// zero value is DefaultChangeDelivery.
+	Delivery RetryPolicy
+}
+
+// A changePublisher publishes the changes of mutations in the background, in
+// the order the mutations were committed, so that the mutations don't wait on
+// the sink.  A mutation's changes are retried until they're published or
+// the policy gives up on them, and the changes of later mutations wait for
+// them.  Changes are dropped, and counted, if they're given up on or there
+// are already maxQueuedChanges waiting; changes that are waiting when the
+// server stops are lost.
+type changePublisher struct {
+	capture ChangeCapture
+
+	// sleep waits between attempts; it's replaced in tests.
+	sleep func(d time.Duration)
+
+	mu      sync.Mutex
+	queue   [][]*Change
+	queued  int
+	running bool
+
+	// pending are the mutations whose changes are still being published.
+	pending sync.WaitGroup
+}
+
+func newChangePublisher(cc ChangeCapture) *changePublisher {
+	if cc.Delivery == (RetryPolicy{}) {
+		cc.Delivery = DefaultChangeDelivery
+	}
+	return &changePublisher{capture: cc, sleep: time.Sleep}
+}
+
+// publish queues changes to be published, and starts publishing the queue if
+// it isn't already being published.
+func (cp *changePublisher) publish(changes []*Change) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.queued > 0 && cp.queued+len(changes) > maxQueuedChanges {
+		glog.Errorf("Dropped the %d changes of mutation %s: there are already %d waiting "+
+			"to be published", len(changes), changes[0].Mutation, cp.queued)
+		recordDropped("queue_full", len(changes))
+		return
+	}
+
+	cp.queue = append(cp.queue, changes)
+	cp.queued += len(changes)
+	cp.pending.Add(1)
+	if !cp.running {
+		cp.running = true
+		go cp.drain()
+	}
+}
+
+// drain publishes the queue, oldest first, until it's empty.
+func (cp *changePublisher) drain() {
+	for {
+		cp.mu.Lock()
+		if len(cp.queue) == 0 {
+			cp.running = false
+			cp.mu.Unlock()
+			return
+		}
+		changes := cp.queue[0]
+		cp.queue[0] = nil
+		cp.queue = cp.queue[1:]
+		cp.mu.Unlock()
+
+		cp.send(changes)
+
+		cp.mu.Lock()
+		cp.queued -= len(changes)
+		cp.mu.Unlock()
+		cp.pending.Done()
+	}
+}
+
+// send publishes changes, trying again until the sink takes them or cp's
+// policy gives up on them.
+func (cp *changePublisher) send(changes []*Change) {
+	policy := cp.capture.Delivery
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), changesTimeout)
+		err := cp.capture.Sink.Publish(ctx, changes)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= policy.MaxAttempts {
+			glog.Errorf("Gave up publishing the %d changes of mutation %s of request %s "+
+				"after %d attempts: %v", len(changes), changes[0].Mutation,
+				changes[0].RequestID, attempt, err)
+			recordDropped("failed", len(changes))
+			return
+		}
+		glog.V(2).Infof("Retrying the changes of mutation %s after error: %v",
+			changes[0].Mutation, err)
+		cp.sleep(backoff)
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// readBefore reads, in txn, the images of the objects uids as they are before
+// mr's mutation changes them, if mr's changes are published.
+func (mr *mutationResolver) readBefore(ctx context.Context, txn dgraph.Txn,
+	uids []uint64) error {
+
+	if mr.changes == nil {
+		return nil
+	}
+	var err error
+	mr.before, err = readImages(ctx, txn, mr.mutation.MutatedType(), uids)
+	return err
+}
+
+// readAfter reads, in txn, the images of the objects that mr's mutation
+// changed, as it left them, if mr's changes are published.
+func (mr *mutationResolver) readAfter(ctx context.Context, txn dgraph.Txn) error {
+	if mr.changes == nil || len(mr.uids) == 0 {
+		return nil
+	}
+	typ := mr.mutation.MutatedType()
+	if mr.mutation.MutationType() == schema.DeleteMutation && typ.SoftDelete() == nil {
+		return nil
+	}
+	var err error
+	mr.after, err = readImages(ctx, txn, typ, mr.uids)
+	return err
+}
+
+// publishChanges queues the changes that mr's committed mutation made to be
+// published.  A sink that fails is logged; the mutation has already
+// happened, so its response isn't changed.
+func (mr *mutationResolver) publishChanges(ctx context.Context) {
+	if mr.changes == nil || len(mr.uids) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	changes := make([]*Change, 0, len(mr.uids))
+	for _, uid := range mr.uids {
+		id := fmt.Sprintf("%#x", uid)
+		changes = append(changes, &Change{
+			Timestamp: now,
+			RequestID: RequestID(ctx),
+			Namespace: mr.changes.capture.Namespace,
+			Type:      mr.mutation.MutatedType().Name(),
+			Operation: string(mr.mutation.MutationType()),
+			Mutation:  mr.mutation.Name(),
+			UID:       id,
+			Before:    mr.before[id],
+			After:     mr.after[id],
+		})
+	}
+	mr.changes.publish(changes)
+}
+
+// readImages reads, in txn, the objects uids of type typ, keyed by their
+// uids.
+func readImages(ctx context.Context, txn dgraph.Txn, typ schema.Type,
+	uids []uint64) (map[string]map[string]interface{}, error) {
+
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	nodes, err := queryNodes(ctx, txn, imageQuery(typ, uids))
+	if err != nil {
+		return nil, err
+	}
+
+	images := make(map[string]map[string]interface{}, len(nodes))
+	for _, node := range nodes {
+		uid, _ := node["uid"].(string)
+		delete(node, "uid")
+		if id := typ.IDField(); id != nil {
+			node[id.Name()] = uid
+		}
+		images[uid] = node
+	}
+	return images, nil
+}
+
+// imageQuery is the query for the images of the objects uids of type typ:
+// the values of the fields stored in Dgraph, and the uids of the objects
+// that its edges link to.  @private fields, like password hashes, are left
+// out.
+func imageQuery(typ schema.Type, uids []uint64) *gql.GraphQuery {
+	query := &gql.GraphQuery{
+		Attr:     "images",
+		Func:     &gql.Function{Name: "uid", UID: uids},
+		Children: []*gql.GraphQuery{{Attr: "uid"}},
+	}
+	for _, fld := range typ.Fields() {
+		pred := fld.DgraphPredicate()
+		if pred == "" || fld.IsID() || fld.Private() || fld.Type().IsEdge() {
+			continue
+		}
+		child := &gql.GraphQuery{Alias: fld.Name(), Attr: pred}
+		if len(fld.Type().Fields()) > 0 {
+			child.Children = []*gql.GraphQuery{{Attr: "uid"}}
+		}
+		query.Children = append(query.Children, child)
+	}
+	return query
+}
+
+var (
+	droppedChanges = stats.Int64("graphql_dropped_changes_total",
+		"Number of changes made by mutations that weren't published",
+		stats.UnitDimensionless)
+	reasonKey, _ = tag.NewKey("reason")
+)
+
+// recordDropped counts n changes dropped because of reason.
+func recordDropped(reason string, n int) {
+	if tagged, err := tag.New(context.Background(), tag.Upsert(reasonKey, reason)); err == nil {
+		stats.Record(tagged, droppedChanges.M(int64(n)))
+	}
+}
+
+func init() {
+	if err := view.Register(&view.View{
+		Name:        droppedChanges.Name(),
+		Measure:     droppedChanges,
+		Description: droppedChanges.Description(),
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{reasonKey},
+	}); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const changesSchema = `
+type Post {
+	id: ID!
+	title: String! @search(by: [hash])
+	author: Author
+}
+
+type Author {
+	id: ID!
+	name: String!
+	password: String! @private
+}
+`
+
+type memorySink struct {
+	changes  []*Change
+	err      error
+	attempts int
+}
+
+func (ms *memorySink) Publish(ctx context.Context, changes []*Change) error {
+	ms.attempts++
+	if ms.err != nil {
+		return ms.err
+	}
+	ms.changes = append(ms.changes, changes...)
+	return nil
+}
+
+func TestChangeCapture(t *testing.T) {
+	client := &mockDgraph{}
+	sink := &memorySink{}
+	resolver := resolverFor(t, changesSchema, client).
+		WithChangeCapture(ChangeCapture{Sink: sink, Namespace: "blog"})
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	client.results = []string{
+		`{"updatePost": [{"uid": "0x1"}]}`,
+		`{"images": [{"uid": "0x1", "title": "Old", "author": {"uid": "0x2"}}]}`,
+		`{"post": [{"title": "New"}]}`,
+		`{"images": [{"uid": "0x1", "title": "New", "author": {"uid": "0x2"}}]}`,
+	}
+	resp := resolver.Resolve(ctx, &schema.Request{
+		Query: `mutation {
+			updatePost(input: {filter: {id: ["0x1"]}, set: {title: "New"}}) { post { title } }
+		}`,
+	})
+	require.Empty(t, resp.Errors)
+	resolver.changeCapture.pending.Wait()
+	require.Len(t, sink.changes, 1)
+	change := sink.changes[0]
+	require.False(t, change.Timestamp.IsZero())
+	require.Equal(t, &Change{
+		Timestamp: change.Timestamp,
+		RequestID: "req-1",
+		Namespace: "blog",
+		Type:      "Post",
+		Operation: "update",
+		Mutation:  "updatePost",
+		UID:       "0x1",
+		Before: map[string]interface{}{
+			"id": "0x1", "title": "Old", "author": map[string]interface{}{"uid": "0x2"},
+		},
+		After: map[string]interface{}{
+			"id": "0x1", "title": "New", "author": map[string]interface{}{"uid": "0x2"},
+		},
+	}, change)
+
+	// The image query reads the stored fields, but not @private ones.
+	require.Equal(t, `query {
+  images(func: uid(0x1)) {
+    uid
+    title : Post.title
+    author : Post.author {
+      uid
+    }
+  }
+}`, client.queries[1])
+
+	client.queries = nil
+	client.results = []string{
+		`{"deleteAuthor": [{"uid": "0x2"}]}`,
+		`{"images": [{"uid": "0x2", "name": "Ann"}]}`,
+	}
+	resp = resolver.Resolve(ctx, &schema.Request{
+		Query: `mutation { deleteAuthor(filter: {id: ["0x2"]}) { msg } }`,
+	})
+	require.Empty(t, resp.Errors)
+	require.NotContains(t, client.queries[1], "Author.password")
+	resolver.changeCapture.pending.Wait()
+	require.Len(t, sink.changes, 2)
+	require.Equal(t, "delete", sink.changes[1].Operation)
+	require.Equal(t, map[string]interface{}{"id": "0x2", "name": "Ann"}, sink.changes[1].Before)
+	require.Nil(t, sink.changes[1].After)
+}
+
+func TestChangeCaptureFailure(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Post1": "0x1"},
+		results: []string{
+			`{"post": [{"title": "Hello"}]}`,
+			`{"images": [{"uid": "0x1", "title": "Hello"}]}`,
+		},
+	}
+	sink := &memorySink{err: errors.New("Kafka is down")}
+	resolver := resolverFor(t, changesSchema, client).WithChangeCapture(ChangeCapture{
+		Sink:     sink,
+		Delivery: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second},
+	})
+	var waits []time.Duration
+	resolver.changeCapture.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	// A sink that fails doesn't fail the mutation, which has been committed;
+	// it's retried, and then the changes are dropped.
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query: `mutation { addPost(input: [{title: "Hello"}]) { post { title } } }`,
+	})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"addPost": {"post": [{"title": "Hello"}]}}`, resp.Data.String())
+	resolver.changeCapture.pending.Wait()
+	require.Equal(t, 3, sink.attempts)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+	require.Empty(t, sink.changes)
+
+	// Once it's back, the changes are published.
+	sink.err = nil
+	client.results = []string{
+		`{"post": [{"title": "Hello"}]}`,
+		`{"images": [{"uid": "0x1", "title": "Hello"}]}`,
+	}
+	resp = resolver.Resolve(context.Background(), &schema.Request{
+		Query: `mutation { addPost(input: [{title: "Hello"}]) { post { title } } }`,
+	})
+	require.Empty(t, resp.Errors)
+	resolver.changeCapture.pending.Wait()
+	require.Len(t, sink.changes, 1)
+	require.Equal(t, "add", sink.changes[0].Operation)
+	require.Nil(t, sink.changes[0].Before)
+	require.Equal(t, map[string]interface{}{"id": "0x1", "title": "Hello"}, sink.changes[0].After)
+}
+
+// blockingSink publishes nothing until it's released.
+type blockingSink struct {
+	memorySink
+	release chan struct{}
+}
+
+func (bs *blockingSink) Publish(ctx context.Context, changes []*Change) error {
+	<-bs.release
+	return bs.memorySink.Publish(ctx, changes)
+}
+
+func TestChangeQueue(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	cp := newChangePublisher(ChangeCapture{Sink: sink})
+	changes := func(n int, mutation string) []*Change {
+		batch := make([]*Change, n)
+		for i := range batch {
+			batch[i] = &Change{Mutation: mutation}
+		}
+		return batch
+	}
+
+	// Publishing doesn't wait on the sink, and the queue is bounded, but a
+	// mutation's changes are queued if nothing else is waiting.
+	cp.publish(changes(maxQueuedChanges+1, "first"))
+	cp.publish(changes(1, "dropped"))
+	close(sink.release)
+	cp.pending.Wait()
+	cp.publish(changes(2, "second"))
+	cp.pending.Wait()
+
+	require.Len(t, sink.changes, maxQueuedChanges+3)
+	require.Equal(t, "first", sink.changes[0].Mutation)
+	require.Equal(t, "second", sink.changes[maxQueuedChanges+1].Mutation)
+	require.Equal(t, 0, cp.queued)
+}
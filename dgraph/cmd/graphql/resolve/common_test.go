@@ -0,0 +1,74 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+// operationFor returns the operation in query, for the schema sch.
+func operationFor(t *testing.T, sch, query string) schema.Operation {
+	return operationWith(t, sch, &schema.Request{Query: query})
+}
+
+// operationWith returns the operation of req, for the schema sch.
+func operationWith(t *testing.T, sch string, req *schema.Request) schema.Operation {
+	handler, err := schema.NewHandler(sch)
+	require.NoError(t, err)
+
+	op, err := handler.Schema().Operation(req)
+	require.NoError(t, err)
+	return op
+}
+
+// resolverFor returns a RequestResolver for the schema sch that runs its
+// queries and mutations with client.
+func resolverFor(t *testing.T, sch string, client dgraph.Client) *RequestResolver {
+	handler, err := schema.NewHandler(sch)
+	require.NoError(t, err)
+	return New(handler.Schema(), client)
+}
+
+// resolveRequest resolves query, for the schema sch, with client.  The
+// request's ID is req-1.
+func resolveRequest(t *testing.T, sch string, client dgraph.Client, query string) *schema.Response {
+	return resolverFor(t, sch, client).Resolve(WithRequestID(context.Background(), "req-1"),
+		&schema.Request{Query: query})
+}
+
+// resolveMutationFor resolves the mutation in query, for the schema sch,
+// with client.
+func resolveMutationFor(t *testing.T, sch string, client *mockDgraph, query string) *resolved {
+	return resolveMutationWith(t, sch, client, &schema.Request{Query: query})
+}
+
+// resolveMutationWith resolves the mutation in req, for the schema sch, with
+// client.
+func resolveMutationWith(t *testing.T, sch string, client *mockDgraph,
+	req *schema.Request) *resolved {
+
+	op := operationWith(t, sch, req)
+	require.Len(t, op.Mutations(), 1)
+
+	mr := &mutationResolver{mutation: op.Mutations()[0], dgraphClient: client}
+	return mr.resolve(context.Background())
+}
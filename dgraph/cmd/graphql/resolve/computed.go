@@ -0,0 +1,117 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/types"
+)
+
+// addComputed adds f, a @computed field, to q.  A count of a list field is
+// just Dgraph's count().  Other expressions are a math() of value variables
+// that are added to q for the fields the expression uses.  The variables
+// only need to be unique in q, until nameVars names them for the whole
+// query.
+func addComputed(q *gql.GraphQuery, f schema.Field) {
+	if existing := childWithAlias(q, f.ResponseName()); existing != nil {
+		// f was asked for in more than one fragment.
+		return
+	}
+
+	expr := f.Computed()
+	if expr.Count {
+		q.Children = append(q.Children,
+			&gql.GraphQuery{Alias: f.ResponseName(), Attr: expr.Predicate, IsCount: true})
+		return
+	}
+	q.Children = append(q.Children,
+		&gql.GraphQuery{Alias: f.ResponseName(), Attr: "math", MathExp: mathTree(q, expr)})
+}
+
+// mathTree builds the math() tree for expr, adding the value variables it
+// uses to q.
+func mathTree(q *gql.GraphQuery, expr *schema.ComputedExpr) *gql.MathTree {
+	switch {
+	case expr.IsNumber():
+		return &gql.MathTree{Const: types.Val{Tid: types.FloatID, Value: expr.Number}}
+	case expr.Field != "":
+		return &gql.MathTree{Var: valueVar(q, expr.Predicate, expr.Count)}
+	}
+
+	fn := expr.Op
+	if fn == "-" && len(expr.Args) == 1 {
+		fn = "u-"
+	}
+	t := &gql.MathTree{Fn: fn}
+	for _, arg := range expr.Args {
+		t.Child = append(t.Child, mathTree(q, arg))
+	}
+	return t
+}
+
+// valueVar returns the variable in q that holds the value of pred, or its
+// count, adding it if it's not there yet.
+func valueVar(q *gql.GraphQuery, pred string, count bool) string {
+	for _, child := range q.Children {
+		if child.Var != "" && child.Attr == pred && child.IsCount == count {
+			return child.Var
+		}
+	}
+	v := fmt.Sprintf("v%d", len(q.Children))
+	q.Children = append(q.Children, &gql.GraphQuery{Var: v, Attr: pred, IsCount: count})
+	return v
+}
+
+// nameVars gives the value variables in q, which are only unique in the
+// block they're in, names that are unique in the whole query.  Dgraph
+// doesn't allow a variable to be defined twice.
+func nameVars(q *gql.GraphQuery) {
+	var count int
+	var name func(q *gql.GraphQuery)
+	name = func(q *gql.GraphQuery) {
+		names := make(map[string]string)
+		for _, child := range q.Children {
+			if child.Var != "" {
+				count++
+				names[child.Var] = fmt.Sprintf("%s%d", computedVarPrefix, count)
+				child.Var = names[child.Var]
+			}
+		}
+		for _, child := range q.Children {
+			if child.MathExp != nil {
+				renameMathVars(child.MathExp, names)
+			}
+			name(child)
+		}
+	}
+	name(q)
+}
+
+// computedVarPrefix starts the names of the variables of @computed fields.
+const computedVarPrefix = "computed"
+
+func renameMathVars(t *gql.MathTree, names map[string]string) {
+	if t.Var != "" {
+		t.Var = names[t.Var]
+	}
+	for _, c := range t.Child {
+		renameMathVars(c, names)
+	}
+}
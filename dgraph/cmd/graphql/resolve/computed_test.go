@@ -0,0 +1,90 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const computedSchema = `
+type Author {
+	id: ID!
+	name: String!
+	likes: Int
+	dislikes: Int
+	posts: [Post] @hasInverse(field: author)
+	postCount: Int @computed(expr: "count(posts)")
+	score: Int @computed(expr: "(likes - dislikes) / max(count(posts), 1)")
+}
+
+type Post {
+	id: ID!
+	title: String!
+	author: Author
+	words: Int
+	minutes: Float @computed(expr: "words / 200")
+}
+`
+
+func TestComputedFields(t *testing.T) {
+	client := &mockDgraph{results: []string{`{"queryAuthor": [{
+		"name": "A",
+		"postCount": 2,
+		"score": 2.5,
+		"posts": [{"title": "P", "minutes": 1.5}]
+	}]}`}}
+	resp := resolverFor(t, computedSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: `query {
+			queryAuthor {
+				name
+				postCount
+				score
+				posts {
+					title
+					minutes
+				}
+			}
+		}`,
+	})
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, []string{`query {
+  queryAuthor(func: type(Author)) {
+    name : Author.name
+    postCount : count(Author.posts)
+    computed1 as Author.likes
+    computed2 as Author.dislikes
+    computed3 as count(Author.posts)
+    score : math((computed1 - computed2) / max(computed3, 1))
+    posts : Author.posts {
+      title : Post.title
+      computed4 as Post.words
+      minutes : math(computed4 / 200)
+    }
+  }
+}`}, client.queries)
+	require.JSONEq(t, `{"queryAuthor": [{
+		"name": "A",
+		"postCount": 2,
+		"score": 2,
+		"posts": [{"title": "P", "minutes": 1.5}]
+	}]}`, resp.Data.String())
+}
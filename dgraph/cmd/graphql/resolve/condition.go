@@ -0,0 +1,110 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+)
+
+// conditionFailedCode is the error code, in the error's extensions, for
+// mutations that fail because objects don't satisfy their condition.
+const conditionFailedCode = "CONDITION_FAILED"
+
+// A ConditionFailedError is the error from an update or delete with a
+// condition, when some of the objects it found don't satisfy it.
+type ConditionFailedError struct {
+	Type string
+	IDs  []string
+}
+
+func (e *ConditionFailedError) Error() string {
+	return fmt.Sprintf("the mutation's condition isn't satisfied by %s %s, so nothing was "+
+		"changed", e.Type, strings.Join(e.IDs, ", "))
+}
+
+// Extensions gives the details of the error for the extensions of a GraphQL
+// error.
+func (e *ConditionFailedError) Extensions() map[string]interface{} {
+	ids := make([]interface{}, len(e.IDs))
+	for i, id := range e.IDs {
+		ids[i] = id
+	}
+	return map[string]interface{}{"code": conditionFailedCode, "ids": ids}
+}
+
+// mutationCondition returns the condition of m, an update or delete, or nil
+// if it doesn't have one.
+func mutationCondition(m schema.Mutation) map[string]interface{} {
+	if m.MutationType() == schema.UpdateMutation {
+		input, _ := m.ArgValue(schema.InputArgName).(map[string]interface{})
+		cond, _ := input[schema.ConditionArgName].(map[string]interface{})
+		return cond
+	}
+	cond, _ := m.ArgValue(schema.ConditionArgName).(map[string]interface{})
+	return cond
+}
+
+// checkCondition checks, in txn, that the nodes with the given uids, found by
+// m, all satisfy m's condition.
+func checkCondition(ctx context.Context, txn dgraph.Txn, m schema.Mutation,
+	uids []uint64) error {
+
+	cond := mutationCondition(m)
+	if cond == nil || len(uids) == 0 {
+		return nil
+	}
+
+	typ := m.MutatedType()
+	ft, err := buildFilter(typ, cond)
+	if err != nil {
+		return err
+	}
+	nodes, err := queryNodes(ctx, txn, &gql.GraphQuery{
+		Attr:     m.ResponseName(),
+		Func:     &gql.Function{Name: "uid", UID: uids},
+		Filter:   ft,
+		Children: []*gql.GraphQuery{{Attr: "uid"}},
+	})
+	if err != nil {
+		return err
+	}
+	satisfied, err := nodeUIDs(nodes)
+	if err != nil {
+		return err
+	}
+	if len(satisfied) == len(uids) {
+		return nil
+	}
+
+	ok := make(map[uint64]bool, len(satisfied))
+	for _, uid := range satisfied {
+		ok[uid] = true
+	}
+	failed := &ConditionFailedError{Type: typ.Name()}
+	for _, uid := range uids {
+		if !ok[uid] {
+			failed.IDs = append(failed.IDs, fmt.Sprintf("%#x", uid))
+		}
+	}
+	return failed
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const conditionSchema = `
+type Post {
+	id: ID!
+	title: String! @search(by: [hash])
+	status: String @search(by: [hash])
+}
+`
+
+func TestConditionalUpdate(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"updatePost": [{"uid": "0x1"}, {"uid": "0x2"}]}`,
+		`{"updatePost": [{"uid": "0x1"}, {"uid": "0x2"}]}`,
+		`{"post": [{"status": "PUBLISHED"}, {"status": "PUBLISHED"}]}`,
+	}}
+	resp := resolveRequest(t, conditionSchema, client, `mutation {
+		updatePost(input: {
+			filter: {id: ["0x1", "0x2"]},
+			condition: {status: {eq: "DRAFT"}},
+			set: {status: "PUBLISHED"}
+		}) { post { status } }
+	}`)
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, `query {
+  updatePost(func: uid(0x1, 0x2)) @filter(eq(Post.status, "DRAFT")) {
+    uid
+  }
+}`, client.queries[1])
+	require.Len(t, client.mutations, 1)
+	require.True(t, client.committed)
+}
+
+func TestConditionFailed(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"updatePost": [{"uid": "0x1"}, {"uid": "0x2"}]}`,
+		`{"updatePost": [{"uid": "0x1"}]}`,
+	}}
+	resp := resolveRequest(t, conditionSchema, client, `mutation {
+		updatePost(input: {
+			filter: {id: ["0x1", "0x2"]},
+			condition: {status: {eq: "DRAFT"}},
+			set: {status: "PUBLISHED"}
+		}) { post { status } }
+	}`)
+
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "the mutation's condition isn't satisfied by Post 0x2, so nothing was "+
+		"changed", resp.Errors[0].Message)
+	require.Equal(t, map[string]interface{}{"code": conditionFailedCode,
+		"ids": []interface{}{"0x2"}, "requestId": "req-1"}, resp.Errors[0].Extensions)
+	require.Empty(t, client.mutations)
+	require.False(t, client.committed)
+}
+
+func TestConditionalDelete(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"deletePost": [{"uid": "0x1"}]}`,
+		`{"deletePost": []}`,
+	}}
+	resp := resolveRequest(t, conditionSchema, client, `mutation {
+		deletePost(filter: {title: {eq: "A"}}, condition: {not: {has: [status]}}) { msg }
+	}`)
+
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "the mutation's condition isn't satisfied by Post 0x1, so nothing was "+
+		"changed", resp.Errors[0].Message)
+	require.Empty(t, client.mutations)
+
+	// Without a condition, nothing else is asked of Dgraph.
+	client = &mockDgraph{results: []string{`{"deletePost": [{"uid": "0x1"}]}`}}
+	resp = resolveRequest(t, conditionSchema, client, `mutation {
+		deletePost(filter: {title: {eq: "A"}}) { msg }
+	}`)
+	require.Empty(t, resp.Errors)
+	require.Len(t, client.queries, 1)
+	require.Len(t, client.mutations, 1)
+}
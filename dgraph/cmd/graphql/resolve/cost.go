@@ -0,0 +1,234 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// nodeCountTTL is how long the count of a type's nodes is used for, before
+// it's counted again.
+const nodeCountTTL = 5 * time.Minute
+
+// A Cost is the estimated cost of an operation, before it's run.  It's in a
+// response's extensions, as cost, if the resolver reports costs.
+type Cost struct {
+	// Estimated is how many nodes and values the operation is estimated to
+	// read or write.  Each field costs 1, and the fields asked for on the
+	// items of a list count once for each item there could be: the list's
+	// first argument, or else, for a query of a type, the number of nodes of
+	// that type, or for a list in an object, defaultListWeight, or fewer if
+	// there aren't that many nodes of its type.  If there's a budget, the
+	// estimate stops as soon as it's over it, at Budget + 1.
+	Estimated int `json:"estimated"`
+
+	// Depth is how deeply the operation's selection sets are nested, as far
+	// as they were estimated.
+	Depth int `json:"depth"`
+
+	// Budget is the most that an operation can cost; 0 if there's no budget.
+	Budget int `json:"budget,omitempty"`
+}
+
+// estimateCost returns the estimated cost of op, counting the nodes of the
+// types it queries, if they haven't been counted recently.
+func (r *RequestResolver) estimateCost(ctx context.Context, op schema.Operation) *Cost {
+	var roots []schema.Field
+	for _, q := range op.Queries() {
+		if q.QueryType() != schema.SchemaQuery {
+			roots = append(roots, q)
+		}
+	}
+	for _, m := range op.Mutations() {
+		roots = append(roots, m)
+	}
+	for _, s := range op.Subscriptions() {
+		roots = append(roots, s)
+	}
+
+	cost := &Cost{Budget: r.limits.MaxCost}
+	ceiling := maxInt
+	if cost.Budget > 0 {
+		ceiling = cost.Budget + 1
+	}
+	cost.Estimated, cost.Depth = r.nodeCounts.estimate(ctx, roots, 1, ceiling)
+	return cost
+}
+
+// checkCost returns the error response for op, if it's over r's cost budget.
+func (r *RequestResolver) checkCost(ctx context.Context, op schema.Operation) *schema.Response {
+	if r.limits.MaxCost <= 0 {
+		return nil
+	}
+	cost := r.estimateCost(ctx, op)
+	if cost.Estimated <= cost.Budget {
+		return nil
+	}
+	return &schema.Response{Errors: gqlerror.List{{
+		Message: fmt.Sprintf("The operation's estimated cost is more than %d, the most "+
+			"that's allowed.", cost.Budget),
+		Extensions: map[string]interface{}{"code": costExceededCode, "cost": cost},
+	}}}
+}
+
+// withCost returns resp with cost in its extensions.  resp might be cached,
+// so it's a copy of resp that has the cost.
+func withCost(resp *schema.Response, cost *Cost) *schema.Response {
+	costed := &schema.Response{
+		Errors:     resp.Errors,
+		Extensions: map[string]interface{}{"cost": cost},
+		Cache:      resp.Cache,
+	}
+	for name, ext := range resp.Extensions {
+		costed.Extensions[name] = ext
+	}
+	costed.Data.Write(resp.Data.Bytes())
+	return costed
+}
+
+// nodeCounts are the numbers of nodes of each type, as Dgraph last counted
+// them.
+type nodeCounts struct {
+	client dgraph.Client
+
+	mu     sync.Mutex
+	counts map[string]nodeCount
+}
+
+type nodeCount struct {
+	count   int
+	counted time.Time
+}
+
+func newNodeCounts(client dgraph.Client) *nodeCounts {
+	return &nodeCounts{client: client, counts: make(map[string]nodeCount)}
+}
+
+// estimate returns the estimated cost of fields, which are at depth, and how
+// deeply they're nested.  The cost saturates at ceiling, and once it's there
+// the rest of fields aren't estimated, so no more nodes are counted.
+func (nc *nodeCounts) estimate(ctx context.Context, fields []schema.Field,
+	depth, ceiling int) (cost, maxDepth int) {
+
+	for _, f := range fields {
+		// __typename is filled in without asking Dgraph.
+		if f.Name() == "__typename" {
+			continue
+		}
+
+		sel := allSelections(f)
+		childCost, childDepth := nc.estimate(ctx, sel, depth+1, ceiling)
+		if maxDepth < depth {
+			maxDepth = depth
+		}
+		if maxDepth < childDepth {
+			maxDepth = childDepth
+		}
+		items := nc.items(ctx, f, depth, len(sel) > 0)
+		cost = addSaturated(cost,
+			addSaturated(1, mulSaturated(items, childCost, ceiling), ceiling), ceiling)
+		if cost == ceiling {
+			return
+		}
+	}
+	return
+}
+
+// items is how many items f, at depth, is estimated to have, or 1 if it isn't
+// a list.  Only the nodes of object types are counted.
+func (nc *nodeCounts) items(ctx context.Context, f schema.Field, depth int, object bool) int {
+	if f.Type().ListType() == nil {
+		return 1
+	}
+	if first, ok := f.ArgValue("first").(int64); ok && first >= 0 {
+		if first > int64(maxInt) {
+			return maxInt
+		}
+		return int(first)
+	}
+	count := 0
+	if object {
+		count = nc.count(ctx, f.Type())
+	}
+	switch {
+	case count <= 0:
+		return defaultListWeight
+	case depth == 1:
+		return count
+	case count < defaultListWeight:
+		return count
+	}
+	return defaultListWeight
+}
+
+// count returns the number of nodes of typ, counting them if they haven't
+// been counted in the last nodeCountTTL, or 0 if they can't be counted.
+func (nc *nodeCounts) count(ctx context.Context, typ schema.Type) int {
+	name := typ.DgraphName()
+	nc.mu.Lock()
+	c, ok := nc.counts[name]
+	nc.mu.Unlock()
+	if ok && time.Since(c.counted) < nodeCountTTL {
+		return c.count
+	}
+
+	n, err := nc.countNodes(ctx, name)
+	if err != nil {
+		glog.V(2).Infof("Estimating costs without the number of %s nodes: %v", name, err)
+		return 0
+	}
+	nc.mu.Lock()
+	nc.counts[name] = nodeCount{count: n, counted: time.Now()}
+	nc.mu.Unlock()
+	return n
+}
+
+// countNodes asks Dgraph how many nodes have the type called name.
+func (nc *nodeCounts) countNodes(ctx context.Context, name string) (int, error) {
+	resp, err := nc.client.Query(ctx, &gql.GraphQuery{
+		Attr:          "nodes",
+		Func:          &gql.Function{Name: "type", Args: []gql.Arg{{Value: name}}},
+		UidCount:      true,
+		UidCountAlias: "count",
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total struct {
+		Nodes []struct {
+			Count int `json:"count"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(resp, &total); err != nil {
+		return 0, errors.Wrap(err, "couldn't unmarshal the node count")
+	}
+	if len(total.Nodes) == 0 {
+		return 0, nil
+	}
+	return total.Nodes[0].Count, nil
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCost(t *testing.T) {
+	// There are 3 posts, fewer than defaultListWeight, so each author's posts
+	// cost 1 + 3*1 = 4, and with their name, 5.  There are 1000 authors, so
+	// queryAuthor costs 1 + 1000*5 = 5001.
+	query := &schema.Request{Query: `query { queryAuthor { name posts { title } } }`}
+	counts := []string{`{"nodes": [{"count": 3}]}`, `{"nodes": [{"count": 1000}]}`}
+
+	client := &mockDgraph{results: counts}
+	resp := resolverFor(t, testSchema, client).
+		WithLimits(QueryLimits{MaxCost: 5000}).
+		Resolve(context.Background(), query)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "The operation's estimated cost is more than 5000, the most that's "+
+		"allowed.", resp.Errors[0].Message)
+	require.Equal(t, costExceededCode, resp.Errors[0].Extensions["code"])
+	require.Equal(t, &Cost{Estimated: 5001, Depth: 3, Budget: 5000},
+		resp.Errors[0].Extensions["cost"])
+	require.Len(t, client.queries, 2, "refused after counting, before the query")
+	require.Contains(t, client.queries[0], "type(Post)")
+	require.Contains(t, client.queries[1], "type(Author)")
+
+	client = &mockDgraph{results: append(counts, `{"queryAuthor": []}`)}
+	resolver := resolverFor(t, testSchema, client).
+		WithLimits(QueryLimits{MaxCost: 6000, ReportCost: true})
+	resp = resolver.Resolve(context.Background(), query)
+	require.Nil(t, resp.Errors)
+	require.Equal(t, &Cost{Estimated: 5001, Depth: 3, Budget: 6000}, resp.Extensions["cost"])
+	require.Len(t, client.queries, 3)
+
+	// The counts are remembered.
+	resp = resolver.Resolve(context.Background(), query)
+	require.Nil(t, resp.Errors)
+	require.Equal(t, &Cost{Estimated: 5001, Depth: 3, Budget: 6000}, resp.Extensions["cost"])
+	require.Len(t, client.queries, 4)
+}
+
+func TestCostWithoutCounts(t *testing.T) {
+	// Types that can't be counted, and lists with first, are estimated as
+	// the limits' complexity is.
+	resp := resolverFor(t, testSchema, &mockDgraph{results: []string{"not JSON"}}).
+		WithLimits(QueryLimits{ReportCost: true}).
+		Resolve(context.Background(), &schema.Request{Query: `query {
+			queryAuthor(first: 2) { name posts { title } }
+		}`})
+	require.Equal(t, &Cost{Estimated: 1 + 2*(1+1+defaultListWeight), Depth: 3},
+		resp.Extensions["cost"])
+}
+
+func TestCostOverflow(t *testing.T) {
+	// 2147483647 * 2147483647 * 2147483647 is more than an int can hold, but
+	// the estimate stops just over the budget.
+	client := &mockDgraph{}
+	resp := resolverFor(t, testSchema, client).
+		WithLimits(QueryLimits{MaxCost: 1000}).
+		Resolve(context.Background(), &schema.Request{Query: `query {
+			queryAuthor(first: 2147483647) {
+				posts(first: 2147483647) { author { posts(first: 2147483647) { title } } }
+			}
+		}`})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "The operation's estimated cost is more than 1000, the most that's "+
+		"allowed.", resp.Errors[0].Message)
+	require.Equal(t, &Cost{Estimated: 1001, Depth: 5, Budget: 1000},
+		resp.Errors[0].Extensions["cost"])
+	require.Empty(t, client.queries, "refused before reaching Dgraph")
+}
@@ -0,0 +1,595 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// customResolver makes the HTTP calls that resolve @custom fields, and the
+// calls to the lambda server at lambdaURL, made with lambdaClient, that
+// resolve @lambda fields.  @custom calls are made with client, or, if they
+// name a signer, with the client in signed that signs them.  Secrets
+// referenced in @custom calls are looked up in secrets, which can be nil if
+// none are configured.  lambdaURL is "" if there's no lambda server.
+type customResolver struct {
+	client       *external.Client
+	signed       map[string]*external.Client
+	secrets      secrets.Provider
+	lambdaURL    string
+	lambdaClient *external.Client
+}
+
+// customVarPrefix starts the keys under which the fields that @custom calls
+// need are found in Dgraph results.  It can't clash with a response name,
+// because GraphQL names can't contain a dot.
+const customVarPrefix = "custom."
+
+// resolve resolves a @custom query or mutation by making its HTTP call with
+// the field's arguments as the variables.  It's a FieldResolverFunc.
+func (cr *customResolver) resolve(ctx context.Context, field schema.Field) (interface{}, error) {
+	return cr.call(ctx, field.CustomHTTP(), field.Arguments())
+}
+
+// A customCall is a @custom or @lambda field, found in a Dgraph result, that
+// needs its call made.  parent is the object in the result that it's a field of.
+type customCall struct {
+	path   []interface{}
+	field  schema.Field
+	parent map[string]interface{}
+}
+
+// resolveFields resolves the @custom and @lambda fields in val, the Dgraph
+// result for field found at path, by making their calls - concurrently - and
+// adding the results to their parent objects, ready to be completed.  Each
+// @custom HTTP call is made on its own, but a @lambda field, a @custom field
+// that calls a GraphQL API, and a BATCH @custom field is resolved for all its
+// parents in one request, in which parents that need the same thing only ask
+// for it once.  A failed call leaves a failedValue in place of each of its
+// fields, so that completing the result reports the errors and applies the
+// fields' error policies.
+func (cr *customResolver) resolveFields(ctx context.Context, path []interface{},
+	field schema.Field, val interface{}) {
+
+	var calls []customCall
+	findCustomCalls(path, field, val, &calls)
+	if len(calls) == 0 {
+		return
+	}
+
+	results := make([]interface{}, len(calls))
+	batches := make(map[string][]int)
+	var wg sync.WaitGroup
+	for i, c := range calls {
+		if c.field.Lambda() || c.field.CustomHTTP().GraphQL != nil || c.field.CustomHTTP().Batch {
+			key := batchKey(c.path)
+			batches[key] = append(batches[key], i)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, c customCall) {
+			defer wg.Done()
+			res, err := cr.call(ctx, c.field.CustomHTTP(), c.variables())
+			if err != nil {
+				results[i] = &failedValue{errs: c.errors(err)}
+				return
+			}
+			results[i] = aliased(c.field.SelectionSet(), res)
+		}(i, c)
+	}
+	for _, batch := range batches {
+		wg.Add(1)
+		go func(batch []int) {
+			defer wg.Done()
+			batchCalls := make([]customCall, len(batch))
+			for j, i := range batch {
+				batchCalls[j] = calls[i]
+			}
+
+			var res []interface{}
+			switch {
+			case batchCalls[0].field.Lambda():
+				res = cr.resolveLambdaCalls(ctx, batchCalls)
+			case batchCalls[0].field.CustomHTTP().GraphQL != nil:
+				res = cr.resolveGraphQLCalls(ctx, batchCalls)
+			default:
+				res = cr.resolveBatchCalls(ctx, batchCalls)
+			}
+			for j, i := range batch {
+				results[i] = res[j]
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	// The parents are only written once all the calls are done, because
+	// calls for different fields of the same object would otherwise race.
+	for i, c := range calls {
+		c.parent[c.field.ResponseName()] = results[i]
+	}
+}
+
+// variables gives the values of the variables of c's @custom call, from its
+// parent.
+func (c customCall) variables() map[string]interface{} {
+	vars := make(map[string]interface{})
+	for _, name := range c.field.CustomHTTP().Variables {
+		vars[name] = c.parent[customVarPrefix+name]
+	}
+	return vars
+}
+
+// batchKey is the same for every call of the field at path, whatever the
+// parent, so it groups the calls that can be made together: it's the path
+// without its list indexes.
+func batchKey(path []interface{}) string {
+	var names []string
+	for _, p := range path {
+		if name, ok := p.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+// errors gives the errors for c's call failing with err, at c's path.
+func (c customCall) errors(err error) gqlerror.List {
+	errs := fieldErrors(c.field, err)
+	for _, e := range errs {
+		e.Path = append([]interface{}(nil), c.path...)
+	}
+	return errs
+}
+
+// findCustomCalls adds the @custom and @lambda fields in val, the Dgraph result for field
+// at path, to calls.  Paths are as they will be once the result is completed,
+// so a list that Dgraph returns for a single object doesn't add an index.
+func findCustomCalls(path []interface{}, field schema.Field, val interface{},
+	calls *[]customCall) {
+
+	switch v := val.(type) {
+	case []interface{}:
+		isList := field.Type().ListType() != nil
+		for i, item := range v {
+			itemPath := path
+			if isList {
+				itemPath = append(path[:len(path):len(path)], i)
+			}
+			findCustomCalls(itemPath, field, item, calls)
+		}
+	case map[string]interface{}:
+		for _, f := range field.SelectionSet() {
+			fPath := append(path[:len(path):len(path)], f.ResponseName())
+			if _, denied := v[f.ResponseName()].(*failedValue); denied {
+				// The request can't see the field, so there's no call to make.
+				continue
+			}
+			if f.CustomHTTP() != nil || f.Lambda() {
+				*calls = append(*calls, customCall{path: fPath, field: f, parent: v})
+				continue
+			}
+			findCustomCalls(fPath, f, v[f.ResponseName()], calls)
+		}
+	}
+}
+
+// addCustomVariables adds to q the fields of typ called names, which a
+// @custom or @lambda call needs, each under customVarPrefix and its name.
+func addCustomVariables(q *gql.GraphQuery, typ schema.Type, names []string) {
+	for _, name := range names {
+		alias := customVarPrefix + name
+		if hasChild(q, alias) {
+			continue
+		}
+
+		fld := typ.Field(name)
+		if fld == nil {
+			continue
+		}
+		child := &gql.GraphQuery{Alias: alias, Attr: fld.DgraphPredicate()}
+		if fld.IsID() {
+			child.Attr = "uid"
+		}
+		q.Children = append(q.Children, child)
+	}
+}
+
+func hasChild(q *gql.GraphQuery, alias string) bool {
+	return childWithAlias(q, alias) != nil
+}
+
+// call makes the HTTP call ch, with vars filling in its variables, and
+// returns the JSON response.  Variables are URL encoded in the URL and JSON
+// encoded in the body.
+//
+// Secret references are only expanded in the call's own text, never in the
+// values of variables, and errors report the URL as it's written in the
+// schema, so they can't give a secret away.
+func (cr *customResolver) call(ctx context.Context, ch *schema.CustomHTTP,
+	vars map[string]interface{}) (interface{}, error) {
+
+	u, err := cr.fillURL(ctx, ch, vars)
+	if err != nil {
+		return nil, err
+	}
+	body, err := cr.fillBody(ctx, ch, vars)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := cr.fillHeader(ctx, ch, vars)
+	if err != nil {
+		return nil, err
+	}
+	return cr.send(ctx, ch, u, body, hdr)
+}
+
+// send makes the HTTP call ch to u, with the filled in body and headers, and
+// returns the JSON response.  If the operation has a callLoader, the same call
+// is only made once.
+func (cr *customResolver) send(ctx context.Context, ch *schema.CustomHTTP, u, body string,
+	hdr http.Header) (interface{}, error) {
+
+	hdr.Set("Accept", "application/json")
+	if body != "" {
+		hdr.Set("Content-Type", "application/json")
+	}
+
+	var key strings.Builder
+	key.WriteString(ch.Method + " " + u + "\n")
+	_ = hdr.Write(&key)
+	key.WriteString(body)
+
+	return loadCall(ctx, key.String(), func() (interface{}, error) {
+		var rd io.Reader
+		if body != "" {
+			rd = strings.NewReader(body)
+		}
+		req, err := http.NewRequest(ch.Method, u, rd)
+		if err != nil {
+			return nil, errors.Errorf("couldn't make a request for %s", ch.URL)
+		}
+		req = req.WithContext(ctx)
+		req.Header = hdr
+
+		client, err := cr.clientFor(ch)
+		if err != nil {
+			return nil, err
+		}
+		// Each of the call's URLs has the breaker of the call, so a failing
+		// endpoint doesn't leave a breaker for every URL it was called with.
+		resp, err := client.DoEndpoint(ch.Method+" "+ch.URL, req,
+			callPolicy(client.Policy(u), ch.Policy))
+		if err != nil {
+			return nil, external.RedactURL(err, u, ch.URL)
+		}
+		return decodeResponse(resp, ch.URL)
+	})
+}
+
+// clientFor returns the client that makes the call ch: if ch names a signer,
+// one that signs it.
+func (cr *customResolver) clientFor(ch *schema.CustomHTTP) (*external.Client, error) {
+	if ch.Signer == "" {
+		return cr.client, nil
+	}
+	client, ok := cr.signed[ch.Signer]
+	if !ok {
+		return nil, errors.Errorf("the call to %s is signed by %s, but there's no signer "+
+			"called %s configured", ch.URL, ch.Signer, ch.Signer)
+	}
+	return client, nil
+}
+
+// callPolicy returns p with the settings that o, the policy of a @custom
+// call, overrides.  o is nil if the call doesn't override any.
+func callPolicy(p external.Policy, o *schema.CustomPolicy) external.Policy {
+	if o == nil {
+		return p
+	}
+	if o.Timeout > 0 {
+		p.Timeout = o.Timeout
+	}
+	if o.MaxRetries != nil {
+		p.MaxRetries = *o.MaxRetries
+	}
+	if o.BreakerThreshold != nil {
+		p.BreakerThreshold = *o.BreakerThreshold
+	}
+	if o.BreakerCooldown > 0 {
+		p.BreakerCooldown = o.BreakerCooldown
+	}
+	return p
+}
+
+// resolveBatchCalls resolves calls, which are for the same BATCH @custom field
+// of different parents, with a single call.  The call's body is the list of
+// the distinct inputs of the calls, and its response must be the list of
+// their results.  It returns the result of each call.
+func (cr *customResolver) resolveBatchCalls(ctx context.Context,
+	calls []customCall) []interface{} {
+
+	results := make([]interface{}, len(calls))
+	fail := func(err error) []interface{} {
+		for i, c := range calls {
+			results[i] = &failedValue{errs: c.errors(err)}
+		}
+		return results
+	}
+
+	ch := calls[0].field.CustomHTTP()
+	inputs := make([]interface{}, len(calls))
+	for i, c := range calls {
+		vars := c.variables()
+		if ch.Body == "" {
+			inputs[i] = vars
+			continue
+		}
+		body, err := cr.fillBody(ctx, ch, vars)
+		if err != nil {
+			return fail(err)
+		}
+		inputs[i] = json.RawMessage(body)
+	}
+	unique, index := dedupe(inputs)
+
+	body, err := json.Marshal(unique)
+	if err != nil {
+		return fail(errors.Errorf("the body for %s isn't JSON: %s", ch.URL, err))
+	}
+	u, err := cr.fillURL(ctx, ch, nil)
+	if err != nil {
+		return fail(err)
+	}
+	hdr, err := cr.fillHeader(ctx, ch, nil)
+	if err != nil {
+		return fail(err)
+	}
+	val, err := cr.send(ctx, ch, u, string(body), hdr)
+	if err != nil {
+		return fail(err)
+	}
+
+	res, ok := val.([]interface{})
+	if !ok || len(res) != len(unique) {
+		return fail(errors.Errorf("%s should have answered with a list of %d results, one "+
+			"for each input", ch.URL, len(unique)))
+	}
+	for i, c := range calls {
+		results[i] = aliased(c.field.SelectionSet(), res[index[i]])
+	}
+	return results
+}
+
+// resolveRoot resolves a @custom query or mutation.
+func (cr *customResolver) resolveRoot(ctx context.Context, field schema.Field) *resolved {
+	if field.CustomHTTP().GraphQL == nil {
+		return resolveWith(ctx, field, cr.resolve)
+	}
+
+	res, err := cr.callGraphQL(ctx, field, []map[string]interface{}{field.Arguments()})
+	if err != nil {
+		null, _ := completeField(field, nil)
+		return &resolved{data: null, err: fieldErrors(field, err)}
+	}
+
+	val := res[0].Data
+	if len(res[0].Errors) > 0 {
+		path := []interface{}{field.ResponseName()}
+		val = &failedValue{val: val, errs: atPath(res[0].Errors, path)}
+	}
+	data, errs := completeField(field, val)
+	if len(errs) > 0 {
+		return &resolved{data: data, err: errs}
+	}
+	return &resolved{data: data}
+}
+
+// resolveGraphQLCalls resolves calls, which are for the same @custom field of
+// different parents, with a single request to the GraphQL API the field
+// calls, which has each distinct set of variables once.  It returns the
+// result of each call.
+func (cr *customResolver) resolveGraphQLCalls(ctx context.Context,
+	calls []customCall) []interface{} {
+
+	inputs := make([]interface{}, len(calls))
+	for i, c := range calls {
+		inputs[i] = c.variables()
+	}
+	unique, index := dedupe(inputs)
+	vars := make([]map[string]interface{}, len(unique))
+	for i, v := range unique {
+		vars[i] = v.(map[string]interface{})
+	}
+	res, err := cr.callGraphQL(ctx, calls[0].field, vars)
+
+	results := make([]interface{}, len(calls))
+	for i, c := range calls {
+		switch {
+		case err != nil:
+			results[i] = &failedValue{errs: c.errors(err)}
+		case len(res[index[i]].Errors) > 0:
+			r := res[index[i]]
+			results[i] = &failedValue{val: r.Data, errs: atPath(r.Errors, c.path)}
+		default:
+			results[i] = res[index[i]].Data
+		}
+	}
+	return results
+}
+
+// callGraphQL calls the remote query of field's @custom directive once for
+// each of vars, all in one request, asking for field's selection set.  The
+// remote results are keyed by response name, so they're ready to complete.
+func (cr *customResolver) callGraphQL(ctx context.Context, field schema.Field,
+	vars []map[string]interface{}) ([]external.RemoteResult, error) {
+
+	ch := field.CustomHTTP()
+	u, err := cr.fillURL(ctx, ch, nil)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := cr.fillHeader(ctx, ch, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	op := "query"
+	if field.GetObjectName() == "Mutation" {
+		op = "mutation"
+	}
+	calls := make([]external.RemoteCall, len(vars))
+	for i, v := range vars {
+		calls[i] = external.RemoteCall{
+			Field:      field,
+			RemoteName: ch.GraphQL.Name,
+			Args:       ch.GraphQL.Args(v),
+		}
+	}
+
+	client, err := cr.clientFor(ch)
+	if err != nil {
+		return nil, err
+	}
+	rg := external.NewRemoteGraphQL(client, u, callPolicy(client.Policy(u), ch.Policy)).
+		WithHeader(hdr)
+	res, err := rg.Resolve(ctx, op, calls)
+	if err != nil {
+		return nil, external.RedactURL(err, u, ch.URL)
+	}
+	return res, nil
+}
+
+// atPath returns errs, the errors of a remote call for the field at path,
+// moved to path.  Their paths start with the field's response name.  The
+// remote call can be shared, so errs are copied rather than changed.
+func atPath(errs gqlerror.List, path []interface{}) gqlerror.List {
+	res := make(gqlerror.List, len(errs))
+	for i, e := range errs {
+		moved := *e
+		moved.Path = append([]interface{}(nil), path...)
+		if len(e.Path) > 1 {
+			moved.Path = append(moved.Path, e.Path[1:]...)
+		}
+		res[i] = &moved
+	}
+	return res
+}
+
+func (cr *customResolver) expander(ctx context.Context) func(string) (string, error) {
+	return func(s string) (string, error) {
+		return secrets.Expand(ctx, cr.secrets, s)
+	}
+}
+
+// fillURL fills in the URL of ch, with vars URL encoded.
+func (cr *customResolver) fillURL(ctx context.Context, ch *schema.CustomHTTP,
+	vars map[string]interface{}) (string, error) {
+
+	return schema.FillTemplate(ch.URL, cr.expander(ctx), func(name string) string {
+		return urlValue(vars[name])
+	})
+}
+
+// fillBody fills in the body of ch, with vars JSON encoded.  It's "" if ch
+// has no body.
+func (cr *customResolver) fillBody(ctx context.Context, ch *schema.CustomHTTP,
+	vars map[string]interface{}) (string, error) {
+
+	if ch.Body == "" {
+		return "", nil
+	}
+	return schema.FillTemplate(ch.Body, cr.expander(ctx), func(name string) string {
+		js, err := json.Marshal(vars[name])
+		if err != nil {
+			return "null"
+		}
+		return string(js)
+	})
+}
+
+// fillHeader fills in the headers of ch, with vars as they are.
+func (cr *customResolver) fillHeader(ctx context.Context, ch *schema.CustomHTTP,
+	vars map[string]interface{}) (http.Header, error) {
+
+	hdr := make(http.Header)
+	for _, tmpl := range ch.Headers {
+		h, err := schema.FillTemplate(tmpl, cr.expander(ctx), func(name string) string {
+			if vars[name] == nil {
+				return ""
+			}
+			return asString(vars[name])
+		})
+		if err != nil {
+			return nil, err
+		}
+		colon := strings.Index(h, ":")
+		if colon < 0 {
+			continue
+		}
+		hdr.Add(strings.TrimSpace(h[:colon]), strings.TrimSpace(h[colon+1:]))
+	}
+	return hdr, nil
+}
+
+// decodeResponse decodes resp, the JSON response from url.  An empty response
+// is null.
+func decodeResponse(resp []byte, url string) (interface{}, error) {
+	if len(bytes.TrimSpace(resp)) == 0 {
+		return nil, nil
+	}
+	var val interface{}
+	dec := json.NewDecoder(bytes.NewReader(resp))
+	dec.UseNumber()
+	if err := dec.Decode(&val); err != nil {
+		return nil, errors.Errorf("the response from %s isn't JSON: %s", url, err)
+	}
+	return val, nil
+}
+
+// urlValue formats val for a URL: lists become comma-separated and null
+// becomes empty.
+func urlValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = urlValue(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		// QueryEscape is safe in paths too, once spaces are %20.
+		return strings.Replace(url.QueryEscape(fmt.Sprintf("%v", v)), "+", "%20", -1)
+	}
+}
@@ -0,0 +1,460 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/signing"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const customSchema = `
+type User {
+	id: ID!
+	username: String! @search(by: [hash])
+	avatar: String @custom(http: {url: "%s/avatars/$username", method: GET})
+	followers: Int @custom(http: {
+		url: "%s/followers",
+		method: POST,
+		body: "{\"user\": $username, \"id\": $id}",
+		headers: ["Authorization: Bearer {{secrets.TOKEN}}"]
+	})
+}
+
+type Query {
+	weather(city: String!): String @custom(http: {url: "%s/weather?city=$city", method: GET})
+}
+`
+
+// customServer answers the calls that the fields of customSchema make, and
+// records the ones to /followers.
+type customServer struct {
+	mu        sync.Mutex
+	followers []string
+	auth      []string
+}
+
+func (cs *customServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/avatars/bob smith":
+		w.WriteHeader(http.StatusNotFound)
+	case strings.HasPrefix(r.URL.Path, "/avatars/"):
+		json.NewEncoder(w).Encode("https://img.example.com/" + r.URL.Path[len("/avatars/"):])
+	case r.URL.Path == "/followers":
+		body, _ := ioutil.ReadAll(r.Body)
+		cs.mu.Lock()
+		cs.followers = append(cs.followers, string(body))
+		cs.auth = append(cs.auth, r.Header.Get("Authorization"))
+		cs.mu.Unlock()
+		w.Write([]byte(`42`))
+	case r.URL.Path == "/weather":
+		json.NewEncoder(w).Encode("sunny in " + r.URL.Query().Get("city"))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// customSecrets are the secrets that the custom schemas' headers use, from
+// the TEST_CUSTOM_ environment variables.
+var customSecrets = secrets.NewEnvProvider("TEST_CUSTOM_")
+
+// served is sch with srv's URL in place of each %s.
+func served(sch string, srv *httptest.Server) string {
+	return strings.Replace(sch, "%s", srv.URL, -1)
+}
+
+func TestCustomQueryRewriting(t *testing.T) {
+	srv := httptest.NewServer(&customServer{})
+	defer srv.Close()
+	resolver := resolverFor(t, served(customSchema, srv), &mockDgraph{}).WithSecrets(customSecrets)
+
+	op, err := resolver.Schema().Operation(&schema.Request{
+		Query: `query { queryUser { avatar followers } }`,
+	})
+	require.NoError(t, err)
+
+	dgQuery, err := rewriteAsQuery(op.Queries()[0], &authorizer{})
+	require.NoError(t, err)
+	require.Equal(t, `query {
+  queryUser(func: type(User)) {
+    custom.username : User.username
+    custom.id : uid
+  }
+}`, dgraph.AsString(dgQuery))
+}
+
+func TestCustomFields(t *testing.T) {
+	require.NoError(t, os.Setenv("TEST_CUSTOM_TOKEN", "s3cret"))
+	defer os.Unsetenv("TEST_CUSTOM_TOKEN")
+
+	cs := &customServer{}
+	srv := httptest.NewServer(cs)
+	defer srv.Close()
+
+	client := &mockDgraph{results: []string{`{"queryUser": [
+		{"username": "alice", "custom.username": "alice", "custom.id": "0x1"},
+		{"username": "bob smith", "custom.username": "bob smith", "custom.id": "0x2"}]}`}}
+	resolver := resolverFor(t, served(customSchema, srv), client).WithSecrets(customSecrets)
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query: `query { queryUser { username pic: avatar followers } }`,
+	})
+
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "call to "+srv.URL+"/avatars/$username failed: HTTP status 404",
+		resp.Errors[0].Message)
+	require.Equal(t, []interface{}{"queryUser", 1, "pic"}, resp.Errors[0].Path)
+	require.Equal(t, "UPSTREAM_ERROR", resp.Errors[0].Extensions["code"])
+
+	require.JSONEq(t, `{"queryUser": [
+		{"username": "alice", "pic": "https://img.example.com/alice", "followers": 42},
+		{"username": "bob smith", "pic": null, "followers": 42}]}`,
+		resp.Data.String())
+
+	sort.Strings(cs.followers)
+	require.Equal(t, []string{`{"user": "alice", "id": "0x1"}`,
+		`{"user": "bob smith", "id": "0x2"}`}, cs.followers)
+	require.Equal(t, []string{"Bearer s3cret", "Bearer s3cret"}, cs.auth)
+}
+
+func TestCustomQuery(t *testing.T) {
+	srv := httptest.NewServer(&customServer{})
+	defer srv.Close()
+
+	client := &mockDgraph{}
+	resolver := resolverFor(t, served(customSchema, srv), client).WithSecrets(customSecrets)
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query: `query { weather(city: "Sydney & Melbourne") }`,
+	})
+
+	require.Empty(t, resp.Errors)
+	require.Empty(t, client.queries, "custom queries don't go to Dgraph")
+	require.JSONEq(t, `{"weather": "sunny in Sydney & Melbourne"}`, resp.Data.String())
+}
+
+func TestCustomSecretNotFound(t *testing.T) {
+	srv := httptest.NewServer(&customServer{})
+	defer srv.Close()
+
+	client := &mockDgraph{results: []string{
+		`{"getUser": [{"username": "alice", "custom.username": "alice", "custom.id": "0x1"}]}`}}
+	resolver := resolverFor(t, served(customSchema, srv), client).WithSecrets(customSecrets)
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query: `query { getUser(id: "0x1") { followers } }`,
+	})
+
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, `while resolving secret "TOKEN": secret not found`, resp.Errors[0].Message)
+	require.Equal(t, []interface{}{"getUser", "followers"}, resp.Errors[0].Path)
+}
+
+func TestCustomSigned(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := signing.VerifyHMAC(r, "weather-key", time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode("sunny in " + r.URL.Query().Get("city"))
+	}))
+	defer srv.Close()
+
+	sch := `type Query {
+		weather(city: String!): String @custom(http: {
+			url: "%s/weather?city=$city",
+			method: GET,
+			signer: "Weather"
+		})
+	}`
+	req := &schema.Request{Query: `query { weather(city: "Sydney") }`}
+
+	resp := resolverFor(t, served(sch, srv), &mockDgraph{}).Resolve(context.Background(), req)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "the call to "+srv.URL+"/weather?city=$city is signed by weather, "+
+		"but there's no signer called weather configured", resp.Errors[0].Message)
+
+	resp = resolverFor(t, served(sch, srv), &mockDgraph{}).
+		WithSigners(map[string]signing.Signer{"weather": signing.NewHMACSigner("weather-key")}).
+		Resolve(context.Background(), req)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"weather": "sunny in Sydney"}`, resp.Data.String())
+}
+
+func TestCustomCallPolicy(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	policies := external.Policies{Default: external.Policy{MaxRetries: 2,
+		Backoff: time.Millisecond, MaxBackoff: time.Millisecond}}
+	sch := `type Query {
+		weather(city: String!): String @custom(http: {
+			url: "%s/weather?city=$city",
+			method: GET
+		})
+		forecast(city: String!): String @custom(http: {
+			url: "%s/forecast?city=$city",
+			method: GET,
+			policy: {maxRetries: 0}
+		})
+	}`
+
+	resp := resolverFor(t, served(sch, srv), &mockDgraph{}).WithCallPolicies(policies).
+		Resolve(context.Background(), &schema.Request{Query: `query { weather(city: "Sydney") }`})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls), "the call is retried as configured")
+
+	atomic.StoreInt32(&calls, 0)
+	resp = resolverFor(t, served(sch, srv), &mockDgraph{}).WithCallPolicies(policies).
+		Resolve(context.Background(), &schema.Request{Query: `query { forecast(city: "Sydney") }`})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls), "the directive's policy overrides it")
+}
+
+func TestCustomErrorPolicy(t *testing.T) {
+	srv := httptest.NewServer(&customServer{})
+	defer srv.Close()
+
+	sch := strings.Replace(customSchema, "avatar: String @custom",
+		"avatar: String @onError(policy: ABORT_OBJECT) @custom", 1)
+	client := &mockDgraph{results: []string{`{"queryUser": [
+		{"username": "alice", "custom.username": "alice"},
+		{"username": "bob smith", "custom.username": "bob smith"}]}`}}
+	resp := resolverFor(t, served(sch, srv), client).Resolve(context.Background(),
+		&schema.Request{Query: `query { queryUser { username avatar } }`})
+
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, []interface{}{"queryUser", 1, "avatar"}, resp.Errors[0].Path)
+	require.JSONEq(t, `{"queryUser": [
+		{"username": "alice", "avatar": "https://img.example.com/alice"}, null]}`,
+		resp.Data.String())
+}
+
+const remoteSchema = `
+type Post {
+	id: ID!
+	title: String!
+	authorID: String!
+	author: User @custom(http: {
+		url: "%s",
+		method: POST,
+		graphql: "user(id: $authorID)",
+		headers: ["Authorization: Bearer {{secrets.TOKEN}}"]
+	})
+}
+
+type User @remote {
+	id: ID!
+	name: String
+}
+
+type Query {
+	users(country: String!): [User] @custom(http: {
+		url: "%s",
+		method: POST,
+		graphql: "usersIn(country: $country, active: true)"
+	})
+}
+`
+
+// remoteServer is a GraphQL API for remoteSchema that records the queries
+// it's sent.
+type remoteServer struct {
+	mu      sync.Mutex
+	queries []string
+	auth    []string
+}
+
+func (rs *remoteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rs.mu.Lock()
+	rs.queries = append(rs.queries, req["query"])
+	rs.auth = append(rs.auth, r.Header.Get("Authorization"))
+	rs.mu.Unlock()
+
+	if strings.Contains(req["query"], "usersIn") {
+		w.Write([]byte(`{"data": {"r0": [{"n": "Ann"}, {"n": "Bo"}]}}`))
+		return
+	}
+	w.Write([]byte(`{
+		"data": {"r0": {"name": "Ann"}, "r1": null},
+		"errors": [{"message": "no such user", "path": ["r1"]}]
+	}`))
+}
+
+func TestCustomGraphQLFields(t *testing.T) {
+	require.NoError(t, os.Setenv("TEST_CUSTOM_TOKEN", "s3cret"))
+	defer os.Unsetenv("TEST_CUSTOM_TOKEN")
+
+	rs := &remoteServer{}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	client := &mockDgraph{results: []string{`{"queryPost": [
+		{"title": "A", "custom.authorID": "u1"},
+		{"title": "B", "custom.authorID": "u2"}]}`}}
+	resolver := resolverFor(t, served(remoteSchema, srv), client).WithSecrets(customSecrets)
+	resp := resolver.Resolve(context.Background(),
+		&schema.Request{Query: `query { queryPost { title author { name } } }`})
+
+	require.Len(t, rs.queries, 1, "the parents are resolved in one request")
+	require.Equal(t, `query {
+  r0: user(id: "u1") {
+    name
+  }
+  r1: user(id: "u2") {
+    name
+  }
+}`, rs.queries[0])
+	require.Equal(t, []string{"Bearer s3cret"}, rs.auth)
+
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "no such user", resp.Errors[0].Message)
+	require.Equal(t, []interface{}{"queryPost", 1, "author"}, resp.Errors[0].Path)
+	require.JSONEq(t, `{"queryPost": [
+		{"title": "A", "author": {"name": "Ann"}},
+		{"title": "B", "author": null}]}`, resp.Data.String())
+}
+
+func TestCustomGraphQLQuery(t *testing.T) {
+	rs := &remoteServer{}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	client := &mockDgraph{}
+	resolver := resolverFor(t, served(remoteSchema, srv), client).WithSecrets(customSecrets)
+	resp := resolver.Resolve(context.Background(),
+		&schema.Request{Query: `query { users(country: "NZ") { n: name } }`})
+
+	require.Empty(t, resp.Errors)
+	require.Empty(t, client.queries, "remote types don't go to Dgraph")
+	require.Equal(t, []string{`query {
+  r0: usersIn(active: true, country: "NZ") {
+    n: name
+  }
+}`}, rs.queries)
+	require.JSONEq(t, `{"users": [{"n": "Ann"}, {"n": "Bo"}]}`, resp.Data.String())
+}
+
+const batchSchema = `
+type User {
+	id: ID!
+	username: String! @search(by: [hash])
+	avatar: String @custom(http: {url: "%s/avatars/$username", method: GET})
+	score: Int @custom(http: {
+		url: "%s/scores",
+		method: POST,
+		body: "{\"user\": $username}",
+		mode: BATCH
+	})
+}
+`
+
+// batchServer answers the calls that the fields of batchSchema make, and
+// records them.
+type batchServer struct {
+	mu      sync.Mutex
+	avatars []string
+	scores  []string
+}
+
+func (bs *batchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/avatars/") {
+		bs.avatars = append(bs.avatars, r.URL.Path)
+		json.NewEncoder(w).Encode("https://img.example.com/" + r.URL.Path[len("/avatars/"):])
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	bs.scores = append(bs.scores, string(body))
+	var inputs []struct{ User string }
+	json.Unmarshal(body, &inputs)
+	scores := make([]int, len(inputs))
+	for i, in := range inputs {
+		scores[i] = len(in.User)
+	}
+	json.NewEncoder(w).Encode(scores)
+}
+
+func TestCustomCallsDeduplicated(t *testing.T) {
+	bs := &batchServer{}
+	srv := httptest.NewServer(bs)
+	defer srv.Close()
+
+	client := &mockDgraph{results: []string{`{"queryUser": [
+		{"username": "alice", "custom.username": "alice"},
+		{"username": "bob", "custom.username": "bob"},
+		{"username": "alice", "custom.username": "alice"}]}`}}
+	resp := resolverFor(t, served(batchSchema, srv), client).Resolve(context.Background(),
+		&schema.Request{Query: `query { queryUser { username avatar score } }`})
+	require.Empty(t, resp.Errors)
+
+	sort.Strings(bs.avatars)
+	require.Equal(t, []string{"/avatars/alice", "/avatars/bob"}, bs.avatars,
+		"the same call is only made once")
+	require.Equal(t, []string{`[{"user":"alice"},{"user":"bob"}]`}, bs.scores,
+		"a BATCH field is resolved for every parent in one call, with each input once")
+	require.JSONEq(t, `{"queryUser": [
+		{"username": "alice", "avatar": "https://img.example.com/alice", "score": 5},
+		{"username": "bob", "avatar": "https://img.example.com/bob", "score": 3},
+		{"username": "alice", "avatar": "https://img.example.com/alice", "score": 5}]}`,
+		resp.Data.String())
+}
+
+func TestCustomBatchWrongResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[1]`))
+	}))
+	defer srv.Close()
+
+	client := &mockDgraph{results: []string{`{"queryUser": [
+		{"username": "alice", "custom.username": "alice"},
+		{"username": "bob", "custom.username": "bob"}]}`}}
+	resp := resolverFor(t, served(batchSchema, srv), client).Resolve(context.Background(),
+		&schema.Request{Query: `query { queryUser { username score } }`})
+
+	require.Len(t, resp.Errors, 2)
+	require.Equal(t, srv.URL+"/scores should have answered with a list of 2 results, one for "+
+		"each input", resp.Errors[0].Message)
+	require.JSONEq(t, `{"queryUser": [
+		{"username": "alice", "score": null},
+		{"username": "bob", "score": null}]}`, resp.Data.String())
+}
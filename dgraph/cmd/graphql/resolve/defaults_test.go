@@ -0,0 +1,125 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const defaultsSchema = `
+enum Status { OPEN, CLOSED }
+
+type Ticket {
+	id: ID!
+	title: String!
+	status: Status! @default(add: {value: "OPEN"})
+	priority: Int @default(add: {value: "3"})
+	created: DateTime @default(add: {value: "$now"})
+	modified: DateTime @default(add: {value: "$now"}, update: {value: "$now"})
+}
+`
+
+func resolveDefaultsMutation(t *testing.T, client *mockDgraph, mutation string) []interface{} {
+	require.NoError(t, resolveMutationFor(t, defaultsSchema, client, mutation).err)
+
+	require.Len(t, client.mutations, 1)
+	var sets []interface{}
+	require.NoError(t, json.Unmarshal(client.mutations[0].SetJson, &sets))
+	return sets
+}
+
+// requireNow checks that val is a $now value from the time of the test.
+func requireNow(t *testing.T, start time.Time, val interface{}) {
+	s, ok := val.(string)
+	require.True(t, ok)
+	ts, err := time.Parse(time.RFC3339, s)
+	require.NoError(t, err)
+	require.False(t, ts.Before(start.Truncate(time.Second)))
+	require.False(t, ts.After(time.Now()))
+}
+
+func TestAddDefaults(t *testing.T) {
+	start := time.Now()
+	client := &mockDgraph{
+		assigned: map[string]string{"Ticket1": "0x1", "Ticket2": "0x2"},
+		results:  []string{`{"ticket": [{"title": "One"}, {"title": "Two"}]}`},
+	}
+	sets := resolveDefaultsMutation(t, client, `mutation {
+		addTicket(input: [{title: "One"}, {title: "Two", status: CLOSED, priority: 1}]) {
+			ticket { title }
+		}
+	}`)
+
+	require.Len(t, sets, 2)
+	one, two := sets[0].(map[string]interface{}), sets[1].(map[string]interface{})
+	requireNow(t, start, one["Ticket.created"])
+	requireNow(t, start, one["Ticket.modified"])
+	delete(one, "Ticket.created")
+	delete(one, "Ticket.modified")
+	delete(two, "Ticket.created")
+	delete(two, "Ticket.modified")
+	require.Equal(t, map[string]interface{}{
+		"uid":             "_:Ticket1",
+		"dgraph.type":     "Ticket",
+		"Ticket.title":    "One",
+		"Ticket.status":   "OPEN",
+		"Ticket.priority": float64(3),
+	}, one)
+	require.Equal(t, map[string]interface{}{
+		"uid":             "_:Ticket2",
+		"dgraph.type":     "Ticket",
+		"Ticket.title":    "Two",
+		"Ticket.status":   "CLOSED",
+		"Ticket.priority": float64(1),
+	}, two)
+}
+
+func TestUpdateDefaults(t *testing.T) {
+	start := time.Now()
+	client := &mockDgraph{results: []string{
+		`{"updateTicket": [{"uid": "0x1"}]}`,
+		`{"ticket": [{"title": "One"}]}`,
+	}}
+	sets := resolveDefaultsMutation(t, client, `mutation {
+		updateTicket(input: { filter: { id: ["0x1"] }, set: { status: CLOSED } }) {
+			ticket { title }
+		}
+	}`)
+
+	require.Len(t, sets, 1)
+	node := sets[0].(map[string]interface{})
+	requireNow(t, start, node["Ticket.modified"])
+	delete(node, "Ticket.modified")
+	require.Equal(t, map[string]interface{}{"uid": "0x1", "Ticket.status": "CLOSED"}, node)
+
+	// The update default is set even when nothing else is.
+	client = &mockDgraph{results: []string{
+		`{"updateTicket": [{"uid": "0x1"}]}`,
+		`{"ticket": [{"title": "One"}]}`,
+	}}
+	sets = resolveDefaultsMutation(t, client, `mutation {
+		updateTicket(input: { filter: { id: ["0x1"] } }) {
+			ticket { title }
+		}
+	}`)
+	require.Len(t, sets, 1)
+	requireNow(t, start, sets[0].(map[string]interface{})["Ticket.modified"])
+}
@@ -0,0 +1,304 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// The aliases under which the similarity search finds the uid and the vector
+// of each object it compares.
+const (
+	similarUID    = "similar.uid"
+	similarVector = "similar.vector"
+)
+
+// maxSimilarCandidates is how many objects a similarity search can compare.
+// Every object that satisfies the search's filter is read from Dgraph and
+// compared, so a search that would compare more is an error, rather than a
+// scan of the whole type.
+const maxSimilarCandidates = 10000
+
+// resolveSimilar answers a similarity search query, which finds the topK
+// objects whose vectors in the by field are nearest to the vector argument.
+// Dgraph can't compare vectors, so that takes two Dgraph queries: one for the
+// vectors of all the objects that satisfy the filter, and, once they've been
+// ranked here, one for the nearest objects.  Both read the same snapshot of
+// Dgraph, so the objects that are ranked are the ones that are answered.
+func (qr *queryResolver) resolveSimilar(ctx context.Context, custom *customResolver) *resolved {
+	val, err := qr.findSimilar(ctx)
+	if err != nil {
+		null, _ := completeField(qr.query, nil)
+		return &resolved{data: null, err: fieldErrors(qr.query, err)}
+	}
+
+	newAuthorizer(ctx).denyFields([]interface{}{qr.query.ResponseName()}, qr.query, val)
+	custom.resolveFields(ctx, []interface{}{qr.query.ResponseName()}, qr.query, val)
+	data, errs := completeField(qr.query, val)
+	if len(errs) > 0 {
+		return &resolved{data: data, err: errs}
+	}
+	return &resolved{data: data}
+}
+
+func (qr *queryResolver) findSimilar(ctx context.Context) ([]interface{}, error) {
+	field := qr.query
+	vector, err := asVector(field.ArgValue("vector"))
+	if err != nil {
+		return nil, errors.Wrap(err, "vector")
+	}
+	topK, _ := strconv.Atoi(asString(field.ArgValue("topK")))
+	if topK < 0 {
+		return nil, errors.Errorf("topK can't be negative")
+	}
+
+	ctx = dgraph.WithSharedReads(ctx)
+	auth := newAuthorizer(ctx)
+	rankQuery, err := rewriteAsRankQuery(field, auth)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := qr.queryObjects(ctx, rankQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) > maxSimilarCandidates {
+		return nil, errors.Errorf("the search would compare more than %d objects; "+
+			"narrow it with a filter", maxSimilarCandidates)
+	}
+	uids := nearest(objs, vector, topK)
+	if len(uids) == 0 {
+		return []interface{}{}, nil
+	}
+
+	dgQuery, err := rewriteAsQueryByIds(field, uids, auth)
+	if err != nil {
+		return nil, err
+	}
+	dgQuery.Children = append(dgQuery.Children, &gql.GraphQuery{Alias: similarUID, Attr: "uid"})
+	objs, err = qr.queryObjects(ctx, dgQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dgraph answers in order of uid, so the objects are put back in order of
+	// distance.
+	byUID := make(map[uint64]interface{}, len(objs))
+	for _, obj := range objs {
+		if uid, ok := objectUID(obj); ok {
+			byUID[uid] = obj
+		}
+	}
+	val := make([]interface{}, 0, len(uids))
+	for _, uid := range uids {
+		if obj, ok := byUID[uid]; ok {
+			val = append(val, obj)
+		}
+	}
+	return val, nil
+}
+
+// queryObjects runs dgQuery and returns the objects it finds.
+func (qr *queryResolver) queryObjects(ctx context.Context,
+	dgQuery *gql.GraphQuery) ([]map[string]interface{}, error) {
+
+	resp, err := qr.dgraphClient.Query(ctx, dgQuery)
+	if err != nil {
+		glog.Infof("Dgraph query failed: %v", err)
+		return nil, err
+	}
+	res, errs := decodeDgraphResult(qr.query, resp)
+	if errs != nil {
+		return nil, errs
+	}
+
+	vals, _ := res[dgQuery.Attr].([]interface{})
+	objs := make([]map[string]interface{}, 0, len(vals))
+	for _, val := range vals {
+		if obj, ok := val.(map[string]interface{}); ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
+
+// rewriteAsRankQuery builds the query for the uid and the vector in the by
+// field of each object of field's type that satisfies field's filter.  It
+// reads one more than maxSimilarCandidates, to tell when there are too many.
+func rewriteAsRankQuery(field schema.Field, auth *authorizer) (*gql.GraphQuery, error) {
+	typ := field.Type()
+	by, _ := field.ArgValue("by").(string)
+	fld := typ.Field(by)
+	if fld == nil {
+		return nil, errors.Errorf("%s is not a field of type %s", by, typ.Name())
+	}
+
+	var filter *gql.FilterTree
+	if f, ok := field.ArgValue(schema.FilterArgName).(map[string]interface{}); ok {
+		var err error
+		if filter, err = buildFilter(typ, f); err != nil {
+			return nil, err
+		}
+	}
+	authFilter, err := auth.filter(typ, schema.AuthQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	has := &gql.FilterTree{Func: &gql.Function{Name: "has", Attr: fld.DgraphPredicate()}}
+	return &gql.GraphQuery{
+		Attr:   field.ResponseName(),
+		Func:   &gql.Function{Name: "type", Args: []gql.Arg{{Value: typ.DgraphName()}}},
+		Filter: combine("and", has, filter, authFilter, notDeleted(typ)),
+		Args:   map[string]string{"first": strconv.Itoa(maxSimilarCandidates + 1)},
+		Children: []*gql.GraphQuery{
+			{Alias: similarUID, Attr: "uid"},
+			{Alias: similarVector, Attr: fld.DgraphPredicate()},
+		},
+	}, nil
+}
+
+// nearest returns the uids of the topK objects in objs whose vectors are
+// nearest to vector, nearest first, and in the order of objs if they're as
+// near.  Objects whose vectors can't be read, or have a different number of
+// dimensions, aren't compared.  Only the topK nearest so far are kept as
+// objs are compared.
+func nearest(objs []map[string]interface{}, vector []float64, topK int) []uint64 {
+	if topK == 0 {
+		return nil
+	}
+
+	ranks := make(rankHeap, 0, topK)
+	for i, obj := range objs {
+		uid, ok := objectUID(obj)
+		if !ok {
+			continue
+		}
+		stored, _ := obj[similarVector].(string)
+		var v []float64
+		if err := json.Unmarshal([]byte(stored), &v); err != nil || len(v) != len(vector) {
+			continue
+		}
+		var dist float64
+		for d := range v {
+			dist += (v[d] - vector[d]) * (v[d] - vector[d])
+		}
+
+		r := rank{uid: uid, dist: dist, pos: i}
+		switch {
+		case len(ranks) < topK:
+			heap.Push(&ranks, r)
+		case r.nearer(ranks[0]):
+			ranks[0] = r
+			heap.Fix(&ranks, 0)
+		}
+	}
+
+	sort.Slice(ranks, func(i, j int) bool { return ranks[i].nearer(ranks[j]) })
+	uids := make([]uint64, len(ranks))
+	for i, r := range ranks {
+		uids[i] = r.uid
+	}
+	return uids
+}
+
+// A rank is how far an object's vector is from a search's; pos is where the
+// object is among those compared, which breaks ties.
+type rank struct {
+	uid  uint64
+	dist float64
+	pos  int
+}
+
+func (r rank) nearer(other rank) bool {
+	return r.dist < other.dist || (r.dist == other.dist && r.pos < other.pos)
+}
+
+// A rankHeap is a heap.Interface that keeps the farthest rank on top, so it's
+// the one that's replaced by a nearer one.
+type rankHeap []rank
+
+func (h rankHeap) Len() int            { return len(h) }
+func (h rankHeap) Less(i, j int) bool  { return h[j].nearer(h[i]) }
+func (h rankHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *rankHeap) Push(x interface{}) { *h = append(*h, x.(rank)) }
+
+func (h *rankHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// objectUID returns the uid that a similarity search found obj under.
+func objectUID(obj interface{}) (uint64, bool) {
+	o, _ := obj.(map[string]interface{})
+	s, _ := o[similarUID].(string)
+	uid, err := strconv.ParseUint(s, 0, 64)
+	return uid, err == nil
+}
+
+// asVector converts val, a Float32Vector from a request, to a list of floats.
+func asVector(val interface{}) ([]float64, error) {
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("a %s must be a list of numbers", schema.VectorType)
+	}
+	vector := make([]float64, len(list))
+	for i, item := range list {
+		f, err := strconv.ParseFloat(asString(item), 64)
+		if err != nil {
+			return nil, errors.Errorf("a %s must be a list of numbers", schema.VectorType)
+		}
+		vector[i] = f
+	}
+	return vector, nil
+}
+
+// storedValue is val, the value of fld in a mutation, as it's stored in
+// Dgraph.  That's val itself, except for vectors, which are stored as JSON,
+// and uploaded files, which are stored as their URLs.
+func storedValue(fld schema.FieldDefinition, val interface{}) (interface{}, error) {
+	if upload, ok := val.(*schema.Upload); ok {
+		if upload.URL == "" {
+			return nil, errors.Errorf("%s: file %q wasn't stored", fld.Name(), upload.Filename)
+		}
+		return upload.URL, nil
+	}
+	if val == nil || fld.Type().Name() != schema.VectorType {
+		return val, nil
+	}
+	vector, err := asVector(val)
+	if err != nil {
+		return nil, errors.Wrap(err, fld.Name())
+	}
+	js, err := json.Marshal(vector)
+	if err != nil {
+		return nil, err
+	}
+	return string(js), nil
+}
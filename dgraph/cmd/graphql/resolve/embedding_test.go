@@ -0,0 +1,137 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const embeddingSchema = `
+type Product {
+	id: ID!
+	title: String! @search(by: [term])
+	title_v: Float32Vector @embedding
+}
+`
+
+func TestSimilarQuery(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"querySimilarProductByEmbedding": [
+			{"similar.uid": "0x1", "similar.vector": "[0, 0]"},
+			{"similar.uid": "0x2", "similar.vector": "[1, 1]"},
+			{"similar.uid": "0x3", "similar.vector": "[0.9, 1.2]"},
+			{"similar.uid": "0x4", "similar.vector": "[1, 1, 1]"}
+		]}`,
+		`{"querySimilarProductByEmbedding": [
+			{"similar.uid": "0x2", "title": "Two", "title_v": "[1,1]"},
+			{"similar.uid": "0x3", "title": "Three", "title_v": "[0.9,1.2]"}
+		]}`,
+	}}
+	resp := resolverFor(t, embeddingSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: `query {
+			querySimilarProductByEmbedding(by: title_v, topK: 2, vector: [0.9, 1.15],
+				filter: { title: { anyofterms: "two three" } }) {
+				title
+				title_v
+			}
+		}`,
+	})
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, []string{`query {
+  querySimilarProductByEmbedding(func: type(Product), first: 10001) ` +
+		`@filter((has(Product.title_v) AND anyofterms(Product.title, "two three"))) {
+    similar.uid : uid
+    similar.vector : Product.title_v
+  }
+}`, `query {
+  querySimilarProductByEmbedding(func: uid(0x3, 0x2)) {
+    title : Product.title
+    title_v : Product.title_v
+    similar.uid : uid
+  }
+}`}, client.queries)
+	require.JSONEq(t, `{"querySimilarProductByEmbedding": [
+		{"title": "Three", "title_v": [0.9, 1.2]},
+		{"title": "Two", "title_v": [1, 1]}
+	]}`, resp.Data.String())
+}
+
+func TestSimilarQueryLimit(t *testing.T) {
+	var candidates bytes.Buffer
+	candidates.WriteString(`{"querySimilarProductByEmbedding": [`)
+	for i := 0; i <= maxSimilarCandidates; i++ {
+		if i > 0 {
+			candidates.WriteString(",")
+		}
+		fmt.Fprintf(&candidates, `{"similar.uid": "%#x", "similar.vector": "[%d]"}`, i+1, i)
+	}
+	candidates.WriteString("]}")
+
+	client := &mockDgraph{results: []string{candidates.String()}}
+	resp := resolverFor(t, embeddingSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: `query {
+			querySimilarProductByEmbedding(by: title_v, topK: 2, vector: [3]) { title }
+		}`,
+	})
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message,
+		"the search would compare more than 10000 objects; narrow it with a filter")
+	require.Len(t, client.queries, 1)
+}
+
+func TestNearest(t *testing.T) {
+	objs := []map[string]interface{}{
+		{"similar.uid": "0x1", "similar.vector": "[5]"},
+		{"similar.uid": "0x2", "similar.vector": "[1]"},
+		{"similar.uid": "0x3", "similar.vector": "[-1]"},
+		{"similar.uid": "0x4", "similar.vector": "[3]"},
+		{"similar.uid": "0x5", "similar.vector": "[1, 2]"},
+		{"similar.uid": "0x6", "similar.vector": "[0.5]"},
+	}
+
+	// Ties, like 0x1 and 0x4 from 4, are kept in the order they were found.
+	require.Equal(t, []uint64{0x6, 0x2, 0x3}, nearest(objs, []float64{0}, 3))
+	require.Equal(t, []uint64{0x1, 0x4, 0x2, 0x6, 0x3}, nearest(objs, []float64{4}, 10))
+	require.Empty(t, nearest(objs, []float64{0}, 0))
+}
+
+func TestVectorMutation(t *testing.T) {
+	client := &mockDgraph{assigned: map[string]string{"Product1": "0x1"}}
+	resolveMutationFor(t, embeddingSchema, client, `mutation {
+		addProduct(input: [{title: "One", title_v: [0.5, 1]}]) { product { title } }
+	}`)
+
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `[{
+		"uid": "_:Product1",
+		"dgraph.type": "Product",
+		"Product.title": "One",
+		"Product.title_v": "[0.5,1]"
+	}]`, string(client.mutations[0].SetJson))
+
+	res := resolveMutationFor(t, embeddingSchema, &mockDgraph{}, `mutation {
+		addProduct(input: [{title: "One", title_v: "not a vector"}]) { product { title } }
+	}`)
+	require.NotNil(t, res.err)
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// entityKeyPrefix starts the keys under which the @key fields of entities
+// are found in Dgraph results, so each object can be matched up with the
+// representation it answers.
+const entityKeyPrefix = "entity."
+
+// resolveEntities answers an _entities query, with which a federation
+// gateway fetches the fields this server has for objects that other services
+// refer to.  The entities of each type are found with one Dgraph query.  An
+// entity that isn't found, or that auth doesn't allow, is null.
+func (qr *queryResolver) resolveEntities(ctx context.Context,
+	custom *customResolver) *resolved {
+
+	entities, err := schema.Entities(qr.query)
+	if err != nil {
+		null, _ := completeField(qr.query, nil)
+		return &resolved{data: null, err: fieldErrors(qr.query, err)}
+	}
+
+	// Entities of the same type share a field, so they're grouped by it.
+	var fields []schema.Field
+	groups := make(map[schema.Field][]int)
+	for i, e := range entities {
+		if groups[e.Field] == nil {
+			fields = append(fields, e.Field)
+		}
+		groups[e.Field] = append(groups[e.Field], i)
+	}
+
+	auth := newAuthorizer(ctx)
+	vals := make([]interface{}, len(entities))
+	for _, field := range fields {
+		group := make([]*schema.Entity, len(groups[field]))
+		for j, i := range groups[field] {
+			group[j] = entities[i]
+		}
+
+		found, err := qr.findEntities(ctx, field, group, auth)
+		if err != nil {
+			null, _ := completeField(qr.query, nil)
+			return &resolved{data: null, err: fieldErrors(qr.query, err)}
+		}
+		for j, i := range groups[field] {
+			vals[i] = found[j]
+		}
+	}
+
+	var errs gqlerror.List
+	var buf bytes.Buffer
+	buf.WriteString(strconv.Quote(qr.query.ResponseName()))
+	buf.WriteString(": [")
+	for i, e := range entities {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		path := []interface{}{qr.query.ResponseName(), i}
+		auth.denyFields(path, e.Field, vals[i])
+		custom.resolveFields(ctx, path, e.Field, vals[i])
+		completed, err := completeValue(path, e.Field, e.Field.Type(), vals[i])
+		errs = append(errs, err...)
+		buf.Write(completed)
+	}
+	buf.WriteRune(']')
+	return &resolved{data: buf.Bytes(), err: errs}
+}
+
+// findEntities queries Dgraph for entities, which are all of field's type,
+// and returns the object found for each, or nil if there isn't one.
+func (qr *queryResolver) findEntities(ctx context.Context, field schema.Field,
+	entities []*schema.Entity, auth *authorizer) ([]interface{}, error) {
+
+	dgQuery, err := rewriteAsEntitiesQuery(field, entities, auth)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := qr.dgraphClient.Query(ctx, dgQuery)
+	if err != nil {
+		glog.Infof("Dgraph query failed: %v", err)
+		return nil, err
+	}
+	res, errs := decodeDgraphResult(field, resp)
+	if errs != nil {
+		return nil, errs
+	}
+
+	typ := field.Type()
+	byKey := make(map[string]interface{})
+	objs, _ := res[field.ResponseName()].([]interface{})
+	for _, obj := range objs {
+		if o, ok := obj.(map[string]interface{}); ok {
+			byKey[entityKey(typ, o, entityKeyPrefix)] = o
+		}
+	}
+
+	found := make([]interface{}, len(entities))
+	for i, e := range entities {
+		found[i] = byKey[entityKey(typ, e.Keys, "")]
+	}
+	return found, nil
+}
+
+// rewriteAsEntitiesQuery builds a query for the objects of field's type that
+// have the keys of entities.  The key fields are added to the selection set,
+// so the objects can be matched up with the entities.
+func rewriteAsEntitiesQuery(field schema.Field, entities []*schema.Entity,
+	auth *authorizer) (*gql.GraphQuery, error) {
+
+	typ := field.Type()
+	authFilter, err := auth.filter(typ, schema.AuthQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	dgQuery := &gql.GraphQuery{Attr: field.ResponseName()}
+	keys := sortedKeys(entities[0].Keys)
+	if len(keys) == 1 && typ.Field(keys[0]).IsID() {
+		// Entities keyed by their ID are found directly.
+		ids := make([]interface{}, len(entities))
+		for i, e := range entities {
+			ids[i] = e.Keys[keys[0]]
+		}
+		uids, err := convertIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		dgQuery.Func = &gql.Function{Name: "uid", UID: uids}
+		dgQuery.Filter = combine("and", typeFilter(typ), authFilter, notDeleted(typ))
+	} else {
+		conds := make([]*gql.FilterTree, len(entities))
+		for i, e := range entities {
+			var keyConds []*gql.FilterTree
+			for _, name := range keys {
+				fld := typ.Field(name)
+				var cond interface{} = map[string]interface{}{"eq": e.Keys[name]}
+				if fld.IsID() {
+					cond = []interface{}{e.Keys[name]}
+				}
+				fts, err := buildFieldFilter(fld, cond)
+				if err != nil {
+					return nil, err
+				}
+				keyConds = append(keyConds, fts...)
+			}
+			conds[i] = combine("and", keyConds...)
+		}
+		dgQuery.Func = &gql.Function{Name: "type", Args: []gql.Arg{{Value: typ.DgraphName()}}}
+		dgQuery.Filter = combine("and", combine("or", conds...), authFilter, notDeleted(typ))
+	}
+
+	for _, name := range keys {
+		child := &gql.GraphQuery{Alias: entityKeyPrefix + name}
+		if fld := typ.Field(name); fld.IsID() {
+			child.Attr = "uid"
+		} else {
+			child.Attr = fld.DgraphPredicate()
+		}
+		dgQuery.Children = append(dgQuery.Children, child)
+	}
+	if err := addSelectionSetFrom(dgQuery, field, auth); err != nil {
+		return nil, err
+	}
+	nameVars(dgQuery)
+	return dgQuery, nil
+}
+
+// entityKey identifies an object of type typ by the values of its key fields,
+// which are in obj under their names with prefix.  IDs are compared as
+// uids, so "0x1" and "1" are the same object.
+func entityKey(typ schema.Type, obj map[string]interface{}, prefix string) string {
+	var key bytes.Buffer
+	for _, name := range sortedKeys(obj) {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		fld := typ.Field(strings.TrimPrefix(name, prefix))
+		val := asString(obj[name])
+		if fld != nil && fld.IsID() {
+			if uid, err := strconv.ParseUint(val, 0, 64); err == nil {
+				val = strconv.FormatUint(uid, 10)
+			}
+		}
+		key.WriteString(strconv.Quote(val))
+		key.WriteRune(' ')
+	}
+	return key.String()
+}
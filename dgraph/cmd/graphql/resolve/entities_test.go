@@ -0,0 +1,104 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const federationSchema = `
+type Author @key(fields: "id") {
+	id: ID!
+	name: String! @search(by: [hash])
+}
+
+type User @key(fields: "email") @extends {
+	email: String! @external @search(by: [hash])
+	nickname: String
+}
+`
+
+const entitiesQuery = `query($reps: [_Any!]!) {
+	_entities(representations: $reps) {
+		__typename
+		... on Author { name }
+		... on User { nickname }
+	}
+}`
+
+func TestEntities(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"_entities": [
+			{"entity.email": "b@example.com", "nickname": "B"},
+			{"entity.email": "a@example.com", "nickname": "A"}]}`,
+		`{"_entities": [{"entity.id": "0x1", "name": "Ann"}]}`,
+	}}
+
+	resp := resolverFor(t, federationSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: entitiesQuery,
+		Variables: map[string]interface{}{"reps": []interface{}{
+			map[string]interface{}{"__typename": "User", "email": "a@example.com"},
+			map[string]interface{}{"__typename": "Author", "id": "0x01"},
+			map[string]interface{}{"__typename": "User", "email": "b@example.com"},
+			map[string]interface{}{"__typename": "Author", "id": "0x9"},
+		}},
+	})
+	require.Nil(t, resp.Errors)
+	require.JSONEq(t, `{"_entities": [
+		{"__typename": "User", "nickname": "A"},
+		{"__typename": "Author", "name": "Ann"},
+		{"__typename": "User", "nickname": "B"},
+		null]}`, resp.Data.String())
+
+	require.Equal(t, []string{`query {
+  _entities(func: type(User)) @filter((eq(User.email, "a@example.com") OR eq(User.email, "b@example.com"))) {
+    entity.email : User.email
+    nickname : User.nickname
+  }
+}`, `query {
+  _entities(func: uid(0x1, 0x9)) @filter(type(Author)) {
+    entity.id : uid
+    name : Author.name
+  }
+}`}, client.queries)
+}
+
+func TestEntitiesInvalidRepresentation(t *testing.T) {
+	client := &mockDgraph{}
+	resp := resolverFor(t, federationSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: entitiesQuery,
+		Variables: map[string]interface{}{"reps": []interface{}{
+			map[string]interface{}{"__typename": "Post", "id": "0x1"},
+		}},
+	})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, `representation 0 is of "Post", which isn't an entity type`,
+		resp.Errors[0].Message)
+	require.Empty(t, client.queries)
+}
+
+func TestService(t *testing.T) {
+	resp := resolverFor(t, federationSchema, &mockDgraph{}).Resolve(context.Background(),
+		&schema.Request{Query: `query { _service { sdl } }`})
+	require.Nil(t, resp.Errors)
+	require.Contains(t, resp.Data.String(),
+		`type User @key(fields: \"email\") @extends {\n\temail: String! @external\n`)
+}
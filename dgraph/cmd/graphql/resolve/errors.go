@@ -0,0 +1,187 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/gqlerror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Every error in a response has a code in its extensions, so clients can
+// branch on the kind of error instead of parsing its message, and the ID of
+// the request, so it can be found in the server's logs.  These are the codes
+// for the general kinds of errors; errors with more to say, like
+// UNAUTHENTICATED or VERSION_CONFLICT, have their own codes.  An error that
+// doesn't fit any of them is a REQUEST_ERROR, if it stopped the request from
+// running, or a FIELD_ERROR.
+const (
+	// BadRequestCode is for HTTP requests that aren't GraphQL requests.
+	BadRequestCode = "BAD_REQUEST"
+
+	// ForbiddenCode is for authenticated requests that aren't allowed to use
+	// the API at all, like those whose token doesn't name their namespace.
+	ForbiddenCode = "FORBIDDEN"
+
+	// validationFailedCode is for operations that can't be parsed, or aren't
+	// valid for the schema.
+	validationFailedCode = "VALIDATION_FAILED"
+
+	// limitExceededCode is for operations that are over the server's limits.
+	limitExceededCode = "LIMIT_EXCEEDED"
+
+	// costExceededCode is for operations whose estimated cost is over the
+	// server's budget.  The error's extensions have the cost.
+	costExceededCode = "COST_EXCEEDED"
+
+	// unauthorizedCode is for changes that @auth rules don't allow.
+	unauthorizedCode = "UNAUTHORIZED"
+
+	// conflictCode is for transactions that Dgraph aborted because they
+	// conflicted with another one.  They can be tried again.
+	conflictCode = "CONFLICT"
+
+	// dgraphErrorCode is for errors that Dgraph returned.
+	dgraphErrorCode = "DGRAPH_ERROR"
+
+	// timeoutCode is for operations that ran out of time.
+	timeoutCode = "TIMEOUT"
+
+	// cancelledCode is for operations whose requests were abandoned.
+	cancelledCode = "CANCELLED"
+)
+
+// UnauthorizedError is the error from a mutation that @auth rules don't
+// allow to change some of the objects it would.
+type UnauthorizedError struct {
+	// Action is what the mutation would do, e.g. "add" or "delete".
+	Action string
+
+	// Type is the type of the objects.
+	Type string
+
+	// Field is the field with an @auth directive, if it's the field, rather
+	// than the objects, that the request isn't authorized for.
+	Field string
+}
+
+func (e *UnauthorizedError) Error() string {
+	if e.Field != "" {
+		return "Not authorized to " + e.Action + " " + e.Type + "." + e.Field
+	}
+	return "Not authorized to " + e.Action + " " + e.Type
+}
+
+// Extensions gives the details of the error for the extensions of a GraphQL
+// error.
+func (e *UnauthorizedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": unauthorizedCode}
+}
+
+// errorCode returns the code for err, from resolving a field, if it came
+// from Dgraph or from the field's context being done, and "" otherwise.
+func errorCode(err error) string {
+	if reason := transient(err); reason != "" {
+		return reason
+	}
+	cause := errors.Cause(err)
+	switch {
+	case cause == context.DeadlineExceeded || status.Code(cause) == codes.DeadlineExceeded:
+		return timeoutCode
+	case cause == context.Canceled || status.Code(cause) == codes.Canceled:
+		return cancelledCode
+	}
+	if cause != nil {
+		if _, ok := status.FromError(cause); ok {
+			return dgraphErrorCode
+		}
+	}
+	return ""
+}
+
+// withCode gives each of errs that doesn't have a code yet the code code.
+func withCode(errs gqlerror.List, code string) gqlerror.List {
+	for _, e := range errs {
+		if c, _ := e.Extensions["code"].(string); c != "" {
+			continue
+		}
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]interface{})
+		}
+		e.Extensions["code"] = code
+	}
+	return errs
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx in which requests are resolved with
+// the ID id, e.g. from the X-Request-Id header of an HTTP request.  Without
+// one, each request that's resolved gets a new ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID that requests are resolved with in ctx, or "" if
+// there isn't one.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a new, random, request ID.
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// requestContext returns ctx, with a new request ID if it doesn't have one.
+func requestContext(ctx context.Context) context.Context {
+	if RequestID(ctx) != "" {
+		return ctx
+	}
+	return WithRequestID(ctx, NewRequestID())
+}
+
+// finishErrors gives each of resp's errors a code, if it doesn't have one,
+// and the ID of the request, from ctx.
+func finishErrors(ctx context.Context, resp *schema.Response) *schema.Response {
+	if resp == nil {
+		return nil
+	}
+	id := RequestID(ctx)
+	for _, e := range resp.Errors {
+		withCode(gqlerror.List{e}, errorClass(e))
+		if id != "" {
+			e.Extensions["requestId"] = id
+		}
+	}
+	return resp
+}
+
+// ErrorResponse is the response to a request in ctx that failed with err,
+// before it could be resolved.  The errors have the code code, unless they
+// have their own, and the request's ID.
+func ErrorResponse(ctx context.Context, code string, err error) *schema.Response {
+	resp := schema.ErrorResponse(err)
+	withCode(resp.Errors, code)
+	return finishErrors(ctx, resp)
+}
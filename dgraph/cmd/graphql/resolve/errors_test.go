@@ -0,0 +1,92 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgo/y"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const errorsSchema = `
+type Note {
+	id: ID!
+	text: String
+}
+`
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		mutateErr error
+		code      string
+	}{
+		{
+			name:  "invalid operation",
+			query: `query { getNote { text } }`,
+			code:  validationFailedCode,
+		},
+		{
+			name:      "aborted transaction",
+			query:     `mutation { addNote(input: [{text: "A"}]) { note { text } } }`,
+			mutateErr: errors.Wrap(y.ErrAborted, "while mutating Dgraph"),
+			code:      conflictCode,
+		},
+		{
+			name:      "error from Dgraph",
+			query:     `mutation { addNote(input: [{text: "A"}]) { note { text } } }`,
+			mutateErr: status.Error(codes.Unknown, "predicate is full"),
+			code:      dgraphErrorCode,
+		},
+		{
+			name:      "other error",
+			query:     `mutation { addNote(input: [{text: "A"}]) { note { text } } }`,
+			mutateErr: errors.New("something else"),
+			code:      "FIELD_ERROR",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &mockDgraph{mutateErr: test.mutateErr}
+			resp := resolverFor(t, errorsSchema, client).WithRetries(Retries{}).Resolve(
+				WithRequestID(context.Background(), "req-1"), &schema.Request{Query: test.query})
+
+			require.Len(t, resp.Errors, 1)
+			require.Equal(t, test.code, resp.Errors[0].Extensions["code"])
+			require.Equal(t, "req-1", resp.Errors[0].Extensions["requestId"])
+		})
+	}
+}
+
+func TestNewRequestIDs(t *testing.T) {
+	r := resolverFor(t, errorsSchema, &mockDgraph{})
+
+	req := &schema.Request{Query: `query { getNote { text } }`}
+	first := r.Resolve(context.Background(), req).Errors[0].Extensions["requestId"]
+	second := r.Resolve(context.Background(), req).Errors[0].Extensions["requestId"]
+	require.NotEmpty(t, first)
+	require.NotEmpty(t, second)
+	require.NotEqual(t, first, second)
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/golang/glog"
+)
+
+// webhookResolver stands in for the resolver in the calls that send mutation
+// events to the lambda server, so it can tell them from calls to resolve
+// fields.
+const webhookResolver = "$webhook"
+
+// DefaultEventDelivery is how the events of @lambdaOnMutate types are
+// retried, unless a RequestResolver is given its own policy: for a little
+// over five minutes.
+var DefaultEventDelivery = RetryPolicy{
+	MaxAttempts:    10,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+}
+
+// eventPolicy returns the policy of each attempt to send an event to url: it
+// has the timeout of url's policy, but the sender retries failed attempts
+// itself, for longer than a call's retries would, and keeps on trying a
+// failing endpoint rather than tripping a breaker.
+func (es *eventSender) eventPolicy(url string) external.Policy {
+	return external.Policy{Timeout: es.client.Policy(url).Timeout}
+}
+
+// A mutationEvent says what a committed mutation of a @lambdaOnMutate type
+// changed.
+type mutationEvent struct {
+	ID        string `json:"id"`
+	RequestID string `json:"requestId,omitempty"`
+	TypeName  string `json:"__typename"`
+
+	// Operation is add, update or delete, and Mutation is the mutation
+	// field, e.g. addPost.
+	Operation string `json:"operation"`
+	Mutation  string `json:"mutation"`
+
+	// RootUIDs are the objects that were added, updated or deleted, and Input
+	// is the mutation's arguments.
+	RootUIDs []string               `json:"rootUIDs"`
+	Input    map[string]interface{} `json:"input,omitempty"`
+}
+
+// An eventRequest sends an event, with the claims of the request that made
+// the mutation, if it was authenticated.
+type eventRequest struct {
+	Resolver string                 `json:"resolver"`
+	Event    *mutationEvent         `json:"event"`
+	Claims   map[string]interface{} `json:"claims,omitempty"`
+}
+
+// An eventSender delivers events at least once: each is sent, in the
+// background, until it's answered with a 2xx response or it's been tried as
+// many times as the policy allows.  Events that are still being sent when the
+// server stops are lost.  Since an event can be delivered more than once, it
+// has an ID to tell that it's been seen.
+type eventSender struct {
+	policy RetryPolicy
+	client *external.Client
+
+	// sleep waits between attempts; it's replaced in tests.
+	sleep func(d time.Duration)
+
+	// pending are the events still being sent.
+	pending sync.WaitGroup
+}
+
+func newEventSender(policy RetryPolicy, client *external.Client) *eventSender {
+	return &eventSender{policy: policy, client: client, sleep: time.Sleep}
+}
+
+// sendEvent sends the event of mr's mutation, which was committed having
+// changed the objects mr.uids, if its type has @lambdaOnMutate for it.
+func (mr *mutationResolver) sendEvent(ctx context.Context) {
+	if mr.events == nil || len(mr.uids) == 0 {
+		return
+	}
+	kind := mr.mutation.MutationType()
+	typ := mr.mutation.MutatedType()
+	events := typ.MutationEvents()
+	if !events.Sends(kind) {
+		return
+	}
+
+	url := events.URL
+	if url == "" {
+		url = mr.lambdaURL
+	}
+	if url == "" {
+		glog.Errorf("Type %s has @lambdaOnMutate, but there's no lambda server configured, "+
+			"so the event of mutation %s wasn't sent.  Set lambda.url to the URL of one.",
+			typ.Name(), mr.mutation.Name())
+		return
+	}
+
+	event := &mutationEvent{
+		ID:        eventID(),
+		RequestID: RequestID(ctx),
+		TypeName:  typ.Name(),
+		Operation: string(kind),
+		Mutation:  mr.mutation.Name(),
+		Input:     mr.mutation.Arguments(),
+	}
+	for _, uid := range mr.uids {
+		event.RootUIDs = append(event.RootUIDs, fmt.Sprintf("%#x", uid))
+	}
+	mr.events.send(url, &eventRequest{
+		Resolver: webhookResolver,
+		Event:    event,
+		Claims:   authorization.Claims(ctx),
+	})
+}
+
+// send POSTs req to url in the background, trying again until it's
+// delivered or es's policy gives up on it.
+func (es *eventSender) send(url string, req *eventRequest) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		glog.Errorf("Couldn't encode the event of mutation %s: %v", req.Event.Mutation, err)
+		return
+	}
+
+	es.pending.Add(1)
+	go func() {
+		defer es.pending.Done()
+
+		backoff := es.policy.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			if err != nil {
+				glog.Errorf("Couldn't make a request for %s: %v", url, err)
+				return
+			}
+			httpReq.Header.Set("Content-Type", "application/json")
+
+			_, err = es.client.Do(httpReq, es.eventPolicy(url))
+			if err == nil {
+				return
+			}
+			if attempt >= es.policy.MaxAttempts {
+				glog.Errorf("Gave up sending event %s of mutation %s after %d attempts: %v",
+					req.Event.ID, req.Event.Mutation, attempt, err)
+				return
+			}
+			glog.V(2).Infof("Retrying event %s after error: %v", req.Event.ID, err)
+			es.sleep(backoff)
+			backoff *= 2
+			if es.policy.MaxBackoff > 0 && backoff > es.policy.MaxBackoff {
+				backoff = es.policy.MaxBackoff
+			}
+		}
+	}()
+}
+
+// eventID returns a new random ID for an event.
+func eventID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		glog.Errorf("Couldn't make a random event ID: %v", err)
+	}
+	return hex.EncodeToString(id)
+}
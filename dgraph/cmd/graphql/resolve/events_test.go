@@ -0,0 +1,119 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const eventsSchema = `
+type Post @lambdaOnMutate(add: true, delete: true) {
+	id: ID!
+	title: String! @search(by: [hash])
+}
+`
+
+func TestMutationEvents(t *testing.T) {
+	var mu sync.Mutex
+	var events []map[string]interface{}
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &req))
+		events = append(events, req)
+	}))
+	defer srv.Close()
+
+	client := &mockDgraph{
+		assigned: map[string]string{"Post1": "0x1"},
+		results:  []string{`{"post": [{"title": "Hello"}]}`},
+	}
+	resolver := resolverFor(t, eventsSchema, client).
+		WithLambda(srv.URL).
+		WithEventDelivery(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	resolver.events.sleep = func(d time.Duration) {}
+	ctx := authorization.WithClaims(WithRequestID(context.Background(), "req-1"),
+		map[string]interface{}{"sub": "user-1"})
+
+	resp := resolver.Resolve(ctx, &schema.Request{
+		Query: `mutation { addPost(input: [{title: "Hello"}]) { post { title } } }`,
+	})
+	require.Empty(t, resp.Errors)
+	resolver.events.pending.Wait()
+
+	require.Len(t, events, 1, "the event is delivered once it's accepted")
+	event := events[0]["event"].(map[string]interface{})
+	require.NotEmpty(t, event["id"])
+	delete(event, "id")
+	require.Equal(t, map[string]interface{}{
+		"resolver": "$webhook",
+		"event": map[string]interface{}{
+			"requestId":  "req-1",
+			"__typename": "Post",
+			"operation":  "add",
+			"mutation":   "addPost",
+			"rootUIDs":   []interface{}{"0x1"},
+			"input": map[string]interface{}{
+				"input": []interface{}{map[string]interface{}{"title": "Hello"}},
+			},
+		},
+		"claims": map[string]interface{}{"sub": "user-1"},
+	}, events[0])
+
+	// Updates don't send events.
+	client.results = []string{`{"updatePost": [{"uid": "0x1"}]}`, `{"post": [{"title": "Hi"}]}`}
+	resp = resolver.Resolve(ctx, &schema.Request{
+		Query: `mutation {
+			updatePost(input: {filter: {id: ["0x1"]}, set: {title: "Hi"}}) { post { title } }
+		}`,
+	})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"updatePost": {"post": [{"title": "Hi"}]}}`, resp.Data.String())
+	resolver.events.pending.Wait()
+	require.Len(t, events, 1)
+
+	// An event that's never accepted is given up on.
+	failures = 10
+	client.results = []string{`{"deletePost": [{"uid": "0x1"}]}`}
+	resp = resolver.Resolve(ctx, &schema.Request{
+		Query: `mutation { deletePost(filter: {id: ["0x1"]}) { msg } }`,
+	})
+	require.Empty(t, resp.Errors)
+	resolver.events.pending.Wait()
+	require.Len(t, events, 1)
+	require.Equal(t, 7, failures, "the event is tried 3 times")
+}
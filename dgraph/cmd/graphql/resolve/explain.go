@@ -0,0 +1,217 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+)
+
+// An operation can be explained: its response's extensions then have, as
+// explain, the DQL queries and mutations that it was rewritten into, in the
+// order they were sent to Dgraph, and how long each took.  It's for finding
+// out why an operation gets the result it does, or why it's slow.  The DQL
+// shows what @auth rules add to queries, so operations are only explained if
+// the resolver is WithExplain.  Explained operations are still run, and their
+// responses aren't cached.
+
+// explainExtension is the request extension that asks for an explanation.
+const explainExtension = "explain"
+
+type explainRequestKey struct{}
+
+type explanationKey struct{}
+
+// WithExplainRequest returns ctx asking for the operations that are resolved
+// with it to be explained, as if each request had the explain extension.
+func WithExplainRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, explainRequestKey{}, true)
+}
+
+// An Explanation is what an operation sent to Dgraph, and how long it took.
+type Explanation struct {
+	// Steps are what was sent to Dgraph, in the order it was sent.
+	Steps []*ExplainStep `json:"steps"`
+
+	// Duration is how long the operation took, and DgraphDuration how much of
+	// that was spent waiting for Dgraph, in nanoseconds.  Steps that ran
+	// concurrently are all counted in DgraphDuration.
+	Duration       int64 `json:"duration"`
+	DgraphDuration int64 `json:"dgraphDuration"`
+
+	start time.Time
+	mu    sync.Mutex
+}
+
+// An ExplainStep is one query, mutation or commit that an operation sent to
+// Dgraph.
+type ExplainStep struct {
+	// Kind is "query", "mutation" or "commit".
+	Kind string `json:"kind"`
+
+	// Query is the DQL of a query.
+	Query string `json:"query,omitempty"`
+
+	// Set and Delete are the JSON of a mutation.
+	Set    json.RawMessage `json:"set,omitempty"`
+	Delete json.RawMessage `json:"delete,omitempty"`
+
+	// StartOffset is when the step started, after the start of the
+	// operation, and Duration is how long it took, in nanoseconds.
+	StartOffset int64 `json:"startOffset"`
+	Duration    int64 `json:"duration"`
+
+	// Error is the error from Dgraph, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// WithExplain makes r explain the operations of requests that ask for it, if
+// allow is true.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithExplain(allow bool) *RequestResolver {
+	r.explain = allow
+	return r
+}
+
+// explanation starts the explanation of gqlReq, in a copy of ctx, if it asks
+// for one and r allows it.  Otherwise, the explanation is nil.
+func (r *RequestResolver) explanation(ctx context.Context,
+	gqlReq *schema.Request) (context.Context, *Explanation) {
+
+	requested, _ := gqlReq.Extensions[explainExtension].(bool)
+	if !r.explain || !(requested || ctx.Value(explainRequestKey{}) != nil) {
+		return ctx, nil
+	}
+	e := &Explanation{Steps: []*ExplainStep{}, start: time.Now()}
+	return explainedWith(ctx, e), e
+}
+
+// explainedWith returns ctx with e, so that what's sent to Dgraph with it is
+// added to e.  If e is nil, it's ctx.
+func explainedWith(ctx context.Context, e *Explanation) context.Context {
+	if e == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, explanationKey{}, e)
+}
+
+// explanationFrom returns the explanation in ctx, or nil if there isn't one.
+func explanationFrom(ctx context.Context) *Explanation {
+	e, _ := ctx.Value(explanationKey{}).(*Explanation)
+	return e
+}
+
+// withExplanation finishes e and adds it to resp's extensions, if there is
+// one.  Explained responses aren't cached, so resp is the response's own.
+func withExplanation(resp *schema.Response, e *Explanation) *schema.Response {
+	if e == nil {
+		return resp
+	}
+	e.mu.Lock()
+	e.Duration = int64(time.Since(e.start))
+	e.mu.Unlock()
+	if resp.Extensions == nil {
+		resp.Extensions = make(map[string]interface{})
+	}
+	resp.Extensions[explainExtension] = e
+	return resp
+}
+
+// record adds step, which started at start and failed with err, if it
+// failed, to e.  If e is nil, it does nothing.
+func (e *Explanation) record(step *ExplainStep, start time.Time, err error) {
+	if e == nil {
+		return
+	}
+	step.StartOffset = int64(start.Sub(e.start))
+	step.Duration = int64(time.Since(start))
+	if err != nil {
+		step.Error = err.Error()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Steps = append(e.Steps, step)
+	e.DgraphDuration += step.Duration
+}
+
+// explainingClient is a dgraph.Client that adds the queries and mutations
+// it runs to the explanation in their context, if there is one.
+type explainingClient struct {
+	dgraph.Client
+}
+
+func (c explainingClient) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	start := time.Now()
+	resp, err := c.Client.Query(ctx, query)
+	explainQuery(ctx, query, start, err)
+	return resp, err
+}
+
+func (c explainingClient) NewTxn() dgraph.Txn {
+	return explainingTxn{c.Client.NewTxn()}
+}
+
+// explainingTxn is a dgraph.Txn that adds what it runs to the explanation
+// in its context, if there is one.
+type explainingTxn struct {
+	dgraph.Txn
+}
+
+func (t explainingTxn) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	start := time.Now()
+	resp, err := t.Txn.Query(ctx, query)
+	explainQuery(ctx, query, start, err)
+	return resp, err
+}
+
+func (t explainingTxn) Mutate(ctx context.Context, mut *api.Mutation) (map[string]string, error) {
+	start := time.Now()
+	assigned, err := t.Txn.Mutate(ctx, mut)
+	if e := explanationFrom(ctx); e != nil {
+		e.record(&ExplainStep{Kind: "mutation", Set: rawJSON(mut.SetJson),
+			Delete: rawJSON(mut.DeleteJson)}, start, err)
+	}
+	return assigned, err
+}
+
+func (t explainingTxn) Commit(ctx context.Context) error {
+	start := time.Now()
+	err := t.Txn.Commit(ctx)
+	explanationFrom(ctx).record(&ExplainStep{Kind: "commit"}, start, err)
+	return err
+}
+
+func explainQuery(ctx context.Context, query *gql.GraphQuery, start time.Time, err error) {
+	if e := explanationFrom(ctx); e != nil {
+		e.record(&ExplainStep{Kind: "query", Query: dgraph.AsString(query)}, start, err)
+	}
+}
+
+// rawJSON returns js as raw JSON, or nil if it's empty.
+func rawJSON(js []byte) json.RawMessage {
+	if len(js) == 0 {
+		return nil
+	}
+	return js
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	query := `query { queryAuthor(filter: {name: {eq: "A"}}) { name } }`
+
+	// Without WithExplain, requests can't ask for an explanation.
+	client := &mockDgraph{results: []string{`{"queryAuthor": [{"name": "A"}]}`}}
+	resp := resolverFor(t, testSchema, client).Resolve(context.Background(),
+		&schema.Request{Query: query, Extensions: map[string]interface{}{"explain": true}})
+	require.Nil(t, resp.Errors)
+	require.Nil(t, resp.Extensions)
+
+	client = &mockDgraph{results: []string{`{"queryAuthor": [{"name": "A"}]}`}}
+	resolver := resolverFor(t, testSchema, client).WithExplain(true)
+	resp = resolver.Resolve(WithExplainRequest(context.Background()),
+		&schema.Request{Query: query})
+	require.Nil(t, resp.Errors)
+	require.JSONEq(t, `{"queryAuthor": [{"name": "A"}]}`, resp.Data.String())
+
+	e, ok := resp.Extensions["explain"].(*Explanation)
+	require.True(t, ok)
+	require.Len(t, e.Steps, 1)
+	require.Equal(t, "query", e.Steps[0].Kind)
+	require.Equal(t, client.queries[0], e.Steps[0].Query)
+	require.Contains(t, e.Steps[0].Query, `eq(Author.name, "A")`)
+	require.True(t, e.Duration >= e.DgraphDuration)
+	require.Equal(t, e.Steps[0].Duration, e.DgraphDuration)
+
+	// Requests that don't ask aren't explained.
+	resp = resolver.Resolve(context.Background(), &schema.Request{Query: query})
+	require.Nil(t, resp.Extensions)
+}
+
+func TestExplainMutation(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Post1": "0x2"},
+		results:  []string{`{"post": [{"title": "T"}]}`},
+	}
+	resp := resolverFor(t, testSchema, client).WithExplain(true).Resolve(context.Background(),
+		&schema.Request{
+			Query: `mutation {
+				addPost(input: [{title: "T", author: {id: "0x1"}}]) { post { title } }
+			}`,
+			Extensions: map[string]interface{}{"explain": true},
+		})
+	require.Nil(t, resp.Errors)
+
+	e := resp.Extensions["explain"].(*Explanation)
+	var kinds []string
+	for _, step := range e.Steps {
+		kinds = append(kinds, step.Kind)
+	}
+	require.Contains(t, kinds, "mutation")
+	require.Equal(t, "commit", kinds[len(kinds)-1])
+	for _, step := range e.Steps {
+		if step.Kind == "mutation" {
+			require.Contains(t, string(step.Set), `"Post.title":"T"`)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// DefaultExportPageSize is how many objects each page of an export reads.
+const DefaultExportPageSize = 1000
+
+// An ExportFunc is given the pages of an exported query's results, in order:
+// the query, and the JSON of each object in the page.  It's called at least
+// once, with an empty page if there are no results, so that it can start its
+// output.
+type ExportFunc func(q schema.Query, page []json.RawMessage) error
+
+// Export resolves gqlReq, which must be a single query of a list of stored
+// objects, a page of up to pageSize objects at a time, giving each page to
+// fn as it's read, so the results don't have to fit in memory.  The pages all
+// read the same snapshot of Dgraph, and the query's own first and offset
+// still hold.  Each page has the operation's timeout.  If the request fails,
+// the result is its error response; if it fails after some pages have been
+// given to fn, so does the export, and the response has its errors.
+func (r *RequestResolver) Export(ctx context.Context, gqlReq *schema.Request,
+	pageSize int, fn ExportFunc) *schema.Response {
+
+	ctx = requestContext(ctx)
+	sch := r.Schema()
+	if sch == nil {
+		resp := schema.ErrorResponse(errors.New(
+			"There's no GraphQL schema set yet.  Use the /admin API to add one."))
+		withCode(resp.Errors, unavailableCode)
+		return finishErrors(ctx, resp)
+	}
+
+	start := time.Now()
+	op, resp := r.operation(ctx, sch, gqlReq)
+	if resp == nil {
+		resp = r.export(ctx, op, pageSize, fn)
+	}
+	resp = finishErrors(ctx, resp)
+	logged := resp
+	if logged == nil {
+		logged = &schema.Response{}
+	}
+	r.requestLog.log(ctx, gqlReq, op, logged, time.Since(start), 0)
+	return resp
+}
+
+func (r *RequestResolver) export(ctx context.Context, op schema.Operation, pageSize int,
+	fn ExportFunc) *schema.Response {
+
+	if !op.IsQuery() || len(op.Queries()) != 1 ||
+		op.Queries()[0].QueryType() != schema.FilterQuery {
+		resp := schema.ErrorResponse(errors.New(
+			"Only a single query of a list of objects can be exported"))
+		withCode(resp.Errors, BadRequestCode)
+		return resp
+	}
+	q := op.Queries()[0]
+	first, limited := q.ArgValue("first").(int64)
+	offset, _ := q.ArgValue("offset").(int64)
+
+	reads := dgraph.WithSharedReads(withCallLoader(ctx))
+	for read := int64(0); ; {
+		size := int64(pageSize)
+		if limited && first-read < size {
+			size = first - read
+		}
+
+		page := []json.RawMessage{}
+		if size > 0 {
+			var err error
+			page, err = r.exportPage(reads, op,
+				&pagedQuery{Query: q, first: size, offset: offset + read})
+			if err != nil {
+				return &schema.Response{Errors: schema.AsGQLErrors(err)}
+			}
+		}
+		if err := fn(q, page); err != nil {
+			return schema.ErrorResponse(err)
+		}
+		read += int64(len(page))
+		if int64(len(page)) < size || (limited && read >= first) {
+			return nil
+		}
+	}
+}
+
+// exportPage resolves q, a page of the query in op, and returns the JSON of
+// each of its objects, compacted so that each is one line.
+func (r *RequestResolver) exportPage(ctx context.Context, op schema.Operation,
+	q *pagedQuery) ([]json.RawMessage, error) {
+
+	ctx, cancel := r.withTimeout(ctx, op)
+	defer cancel()
+	resp := &schema.Response{}
+	r.resolveQueries(ctx, []schema.Query{q}, resp)
+	if len(resp.Errors) > 0 {
+		return nil, resp.Errors
+	}
+
+	var data map[string][]json.RawMessage
+	if err := json.Unmarshal(resp.Data.Bytes(), &data); err != nil {
+		return nil, gqlerror.Errorf("couldn't read the page of results: %s", err)
+	}
+	page := data[q.ResponseName()]
+	for i, obj := range page {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, obj); err != nil {
+			return nil, gqlerror.Errorf("couldn't read the page of results: %s", err)
+		}
+		page[i] = buf.Bytes()
+	}
+	return page, nil
+}
+
+// A pagedQuery is a page of a query: the query with its first and offset
+// arguments replaced.
+type pagedQuery struct {
+	schema.Query
+	first, offset int64
+}
+
+func (pq *pagedQuery) ArgValue(name string) interface{} {
+	switch name {
+	case "first":
+		return pq.first
+	case "offset":
+		return pq.offset
+	}
+	return pq.Query.ArgValue(name)
+}
+
+func (pq *pagedQuery) Arguments() map[string]interface{} {
+	args := make(map[string]interface{})
+	for name, val := range pq.Query.Arguments() {
+		args[name] = val
+	}
+	args["first"], args["offset"] = pq.first, pq.offset
+	return args
+}
+
+// Plan finds nothing: a page's arguments aren't those of its operation, so its
+// plans would be wrong for the operation's query, and aren't cached.
+func (pq *pagedQuery) Plan(key string) (interface{}, bool) {
+	return nil, false
+}
+
+func (pq *pagedQuery) SetPlan(key string, p interface{}) {}
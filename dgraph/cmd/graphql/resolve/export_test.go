@@ -0,0 +1,118 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const exportSchema = `
+type Post {
+	id: ID!
+	title: String!
+	likes: Int
+}
+`
+
+// exportPages exports query with resolver, pageSize objects at a time,
+// returning the pages.
+func exportPages(resolver *RequestResolver, query string,
+	pageSize int) ([][]string, *schema.Response) {
+
+	var pages [][]string
+	resp := resolver.Export(context.Background(), &schema.Request{Query: query}, pageSize,
+		func(q schema.Query, page []json.RawMessage) error {
+			objs := []string{}
+			for _, obj := range page {
+				objs = append(objs, string(obj))
+			}
+			pages = append(pages, objs)
+			return nil
+		})
+	return pages, resp
+}
+
+func TestExport(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"queryPost": [{"title": "A"}, {"title": "B"}]}`,
+		`{"queryPost": [{"title": "C"}]}`,
+	}}
+	resolver := resolverFor(t, exportSchema, client)
+
+	pages, resp := exportPages(resolver, `query { queryPost { title } }`, 2)
+	require.Nil(t, resp)
+	require.Equal(t, [][]string{
+		{`{"title":"A"}`, `{"title":"B"}`},
+		{`{"title":"C"}`},
+	}, pages)
+	require.Len(t, client.queries, 2)
+	require.Contains(t, client.queries[0], "queryPost(func: type(Post), first: 2, offset: 0)")
+	require.Contains(t, client.queries[1], "queryPost(func: type(Post), first: 2, offset: 2)")
+
+	// The query's own first and offset still hold.
+	client.queries = nil
+	client.results = []string{
+		`{"queryPost": [{"title": "B"}, {"title": "C"}]}`,
+		`{"queryPost": [{"title": "D"}]}`,
+	}
+	pages, resp = exportPages(resolver, `query { queryPost(first: 3, offset: 1) { title } }`, 2)
+	require.Nil(t, resp)
+	require.Len(t, pages, 2)
+	require.Len(t, client.queries, 2)
+	require.Contains(t, client.queries[0], "first: 2, offset: 1")
+	require.Contains(t, client.queries[1], "first: 1, offset: 3")
+
+	// With no results, there's still a page, which is empty.
+	client.queries, client.results = nil, nil
+	pages, resp = exportPages(resolver, `query { queryPost { title } }`, 2)
+	require.Nil(t, resp)
+	require.Equal(t, [][]string{{}}, pages)
+}
+
+func TestExportErrors(t *testing.T) {
+	client := &mockDgraph{}
+	resolver := resolverFor(t, exportSchema, client)
+
+	for _, query := range []string{
+		`query { getPost(id: "0x1") { title } }`,
+		`query { a: queryPost { title } b: queryPost { title } }`,
+		`mutation { deletePost(filter: {id: ["0x1"]}) { msg } }`,
+	} {
+		pages, resp := exportPages(resolver, query, 2)
+		require.Empty(t, pages)
+		require.NotNil(t, resp)
+		require.Equal(t, "Only a single query of a list of objects can be exported",
+			resp.Errors[0].Message)
+	}
+	require.Empty(t, client.queries, "nothing's resolved")
+
+	pages, resp := exportPages(resolver, `query { queryPost { titel } }`, 2)
+	require.Empty(t, pages)
+	require.Contains(t, resp.Errors[0].Message, "Cannot query field")
+
+	// An export that fails part way has the pages read before it failed.
+	client.results = []string{`{"queryPost": [{"title": "A"}, {"title": "B"}]}`, `not JSON`}
+	pages, resp = exportPages(resolver, `query { queryPost { title } }`, 2)
+	require.Len(t, pages, 1)
+	require.NotNil(t, resp)
+	require.NotEmpty(t, resp.Errors)
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/pkg/errors"
+)
+
+// addEdges adds f, a field like friendsEdges whose values are the edges of a
+// @facets field, to q.  It's the edge to the objects, with the facets asked
+// for.  Dgraph puts the facets in the objects at the end of the edges, so
+// they're aliased by facetAlias to keep them apart from the objects' fields,
+// and completion turns each object back into an edge.
+func addEdges(q *gql.GraphQuery, f schema.Field, auth *authorizer) error {
+	if existing := childWithAlias(q, f.ResponseName()); existing != nil {
+		// f was asked for in more than one fragment.
+		return nil
+	}
+
+	node := f.Type().Field(schema.EdgeNodeField).Type()
+	authFilter, err := auth.filter(node, schema.AuthQuery)
+	if err != nil {
+		return err
+	}
+
+	child := &gql.GraphQuery{Alias: f.ResponseName(), Attr: f.DgraphPredicate(),
+		Filter: combine("and", authFilter, notDeleted(node))}
+	var facets []*pb.FacetParam
+	for _, sel := range f.SelectionSet() {
+		switch sel.Name() {
+		case "__typename":
+		case schema.EdgeNodeField:
+			if err := addSelectionSetFrom(child, sel, auth); err != nil {
+				return err
+			}
+		default:
+			facets = append(facets,
+				&pb.FacetParam{Key: sel.DgraphPredicate(), Alias: facetAlias(sel)})
+		}
+	}
+	if len(facets) > 0 {
+		child.Facets = &pb.FacetParams{Param: facets}
+	}
+	if len(child.Children) == 0 {
+		// Dgraph doesn't return edges to objects that nothing's asked of.
+		child.Children = append(child.Children, &gql.GraphQuery{Attr: "uid"})
+	}
+	q.Children = append(q.Children, child)
+	return nil
+}
+
+// facetDelimiter separates a predicate from the name of a facet on its edges
+// in Dgraph's JSON mutations.
+const facetDelimiter = "|"
+
+// facetAlias is the alias of facet f in the Dgraph query.  GraphQL names
+// can't have a ".", so it can't be the same as any field of the object.
+func facetAlias(f schema.Field) string {
+	return "facet." + f.ResponseName()
+}
+
+// asEdge turns an object from the result of addEdges into the edge asked for
+// by fields: the object is the node and the facets are the other fields.
+func asEdge(fields []schema.Field, obj map[string]interface{}) map[string]interface{} {
+	edge := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if f.Name() == schema.EdgeNodeField {
+			edge[f.ResponseName()] = obj
+		} else {
+			edge[f.ResponseName()] = obj[facetAlias(f)]
+		}
+	}
+	return edge
+}
+
+// rewriteEdge builds the JSON for val, an edge (a TEdgeRef input) of field
+// fld, which has the edges of a @facets field.  It's the reference to the
+// edge's node, with the edge's facets.
+func (mrw *mutationRewriter) rewriteEdge(fld schema.FieldDefinition, srcUID string,
+	val interface{}) (map[string]interface{}, error) {
+
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("couldn't understand the value of field %s", fld.Name())
+	}
+
+	ref, err := mrw.rewriteReference(fld.EdgeOf(), srcUID, obj[schema.EdgeNodeField])
+	if err != nil {
+		return nil, err
+	}
+	for _, facet := range fld.Type().Fields() {
+		val, ok := obj[facet.Name()]
+		if !ok || val == nil || facet.Name() == schema.EdgeNodeField {
+			continue
+		}
+		ref[fld.DgraphPredicate()+facetDelimiter+facet.DgraphPredicate()] = val
+	}
+	return ref, nil
+}
+
+// edgeNodes returns the nodes of edges, the value of a field like
+// friendsEdges in a mutation's input.
+func edgeNodes(edges interface{}) []interface{} {
+	var nodes []interface{}
+	for _, e := range asList(edges) {
+		edge, _ := e.(map[string]interface{})
+		nodes = append(nodes, edge[schema.EdgeNodeField])
+	}
+	return nodes
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const facetsSchema = `
+type Friendship @edge {
+	since: DateTime
+	closeness: Int
+}
+
+type Person {
+	id: ID!
+	name: String!
+	friends: [Person] @facets(type: "Friendship")
+	bestFriend: Person @facets(type: "Friendship")
+}
+`
+
+func TestFacetsQuery(t *testing.T) {
+	client := &mockDgraph{results: []string{`{"getPerson": [{
+		"name": "A",
+		"friendsEdges": [
+			{"name": "B", "facet.since": "2019-01-01T00:00:00Z", "facet.how": 3},
+			{"name": "C"}
+		],
+		"bestFriendEdge": [{"uid": "0x3", "facet.since": "2018-01-01T00:00:00Z"}]
+	}]}`}}
+	resp := resolverFor(t, facetsSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: `query {
+			getPerson(id: "0x1") {
+				name
+				friendsEdges {
+					since
+					how: closeness
+					node { name }
+				}
+				bestFriendEdge { since }
+			}
+		}`,
+	})
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, []string{`query {
+  getPerson(func: uid(0x1)) @filter(type(Person)) {
+    name : Person.name
+    friendsEdges : Person.friends @facets(facet.since: since, facet.how: closeness) {
+      name : Person.name
+    }
+    bestFriendEdge : Person.bestFriend @facets(facet.since: since) {
+      uid
+    }
+  }
+}`}, client.queries)
+	require.JSONEq(t, `{"getPerson": {
+		"name": "A",
+		"friendsEdges": [
+			{"since": "2019-01-01T00:00:00Z", "how": 3, "node": {"name": "B"}},
+			{"since": null, "how": null, "node": {"name": "C"}}
+		],
+		"bestFriendEdge": {"since": "2018-01-01T00:00:00Z"}
+	}}`, resp.Data.String())
+}
+
+func TestFacetsMutation(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Person1": "0x1", "Person2": "0x4"},
+		results:  []string{`{"person": [{"name": "A"}]}`},
+	}
+	res := resolveMutationFor(t, facetsSchema, client, `mutation {
+		addPerson(input: [{
+			name: "A",
+			friends: [{id: "0x2"}],
+			friendsEdges: [
+				{node: {id: "0x3"}, since: "2019-01-01T00:00:00Z", closeness: 2},
+				{node: {name: "D"}}
+			]
+		}]) {
+			person { name }
+		}
+	}`)
+	require.NoError(t, res.err)
+
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `[{
+		"uid": "_:Person1",
+		"dgraph.type": "Person",
+		"Person.name": "A",
+		"Person.friends": [
+			{"uid": "0x2"},
+			{"uid": "0x3", "Person.friends|since": "2019-01-01T00:00:00Z",
+				"Person.friends|closeness": 2},
+			{"uid": "_:Person2", "dgraph.type": "Person", "Person.name": "D"}
+		]
+	}]`, string(client.mutations[0].SetJson))
+
+	client = &mockDgraph{results: []string{
+		`{"updatePerson": [{"uid": "0x1"}]}`,
+		`{"person": [{"name": "A"}]}`,
+	}}
+	res = resolveMutationFor(t, facetsSchema, client, `mutation {
+		updatePerson(input: {
+			filter: { id: ["0x1"] },
+			remove: { friendsEdges: [{node: {id: "0x3"}}] }
+		}) {
+			person { name }
+		}
+	}`)
+	require.NoError(t, res.err)
+	require.JSONEq(t, `[{"uid": "0x1", "Person.friends": [{"uid": "0x3"}]}]`,
+		string(client.mutations[0].DeleteJson))
+}
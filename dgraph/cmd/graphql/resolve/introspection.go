@@ -0,0 +1,145 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// The modes of an IntrospectionPolicy.
+const (
+	IntrospectionEnabled       = "enabled"
+	IntrospectionDisabled      = "disabled"
+	IntrospectionAuthenticated = "authenticated"
+	IntrospectionAdmin         = "admin"
+)
+
+// IntrospectionDisabledCode is the error code, in the error's extensions, for
+// operations that ask about the schema when the request isn't allowed to.
+const IntrospectionDisabledCode = "INTROSPECTION_DISABLED"
+
+// An IntrospectionPolicy says who can ask about the schema, with __schema and
+// __type queries and the federation _service query: anyone, no one, requests
+// with a valid JWT, or only requests whose JWT gives them the admin role.
+// The mode can be changed while requests are being served.  A nil policy
+// allows anyone.
+type IntrospectionPolicy struct {
+	mu        sync.RWMutex
+	mode      string
+	roleClaim string
+	adminRole string
+}
+
+// NewIntrospectionPolicy returns the policy with the given mode.  In admin
+// mode, a request is allowed if its roleClaim claim is adminRole, or is a
+// list with adminRole in it.
+func NewIntrospectionPolicy(mode, roleClaim, adminRole string) (*IntrospectionPolicy, error) {
+	p := &IntrospectionPolicy{roleClaim: roleClaim, adminRole: adminRole}
+	if err := p.SetMode(mode); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ValidIntrospectionMode returns an error if mode isn't one of the modes of an
+// IntrospectionPolicy.
+func ValidIntrospectionMode(mode string) error {
+	switch mode {
+	case IntrospectionEnabled, IntrospectionDisabled, IntrospectionAuthenticated,
+		IntrospectionAdmin:
+		return nil
+	}
+	return errors.Errorf("%q isn't an introspection mode; expected enabled, disabled, "+
+		"authenticated or admin", mode)
+}
+
+// Mode returns p's mode.
+func (p *IntrospectionPolicy) Mode() string {
+	if p == nil {
+		return IntrospectionEnabled
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.mode
+}
+
+// SetMode changes p's mode.
+func (p *IntrospectionPolicy) SetMode(mode string) error {
+	if err := ValidIntrospectionMode(mode); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mode = mode
+	return nil
+}
+
+// check returns an error response if op asks about the schema and the request
+// with context ctx isn't allowed to, and nil if it can go ahead.
+func (p *IntrospectionPolicy) check(ctx context.Context, op schema.Operation) *schema.Response {
+	if p == nil || !asksAboutSchema(op) {
+		return nil
+	}
+
+	var msg string
+	claims := authorization.Claims(ctx)
+	switch p.Mode() {
+	case IntrospectionEnabled:
+		return nil
+	case IntrospectionAuthenticated:
+		if claims != nil {
+			return nil
+		}
+		msg = "Introspection is only allowed for requests with a valid JWT"
+	case IntrospectionAdmin:
+		if authorization.HasRole(claims[p.roleClaim], p.adminRole) {
+			return nil
+		}
+		msg = "Introspection is only allowed for admins"
+	default:
+		msg = "Introspection is disabled on this server"
+	}
+	return &schema.Response{Errors: gqlerror.List{{
+		Message:    msg,
+		Extensions: map[string]interface{}{"code": IntrospectionDisabledCode},
+	}}}
+}
+
+// asksAboutSchema returns true if op has a __schema, __type or _service query.
+// A __typename query doesn't give anything away, so it's always allowed.
+func asksAboutSchema(op schema.Operation) bool {
+	if !op.IsQuery() {
+		return false
+	}
+	for _, q := range op.Queries() {
+		switch q.QueryType() {
+		case schema.SchemaQuery:
+			if q.Name() != "__typename" {
+				return true
+			}
+		case schema.ServiceQuery:
+			return true
+		}
+	}
+	return false
+}
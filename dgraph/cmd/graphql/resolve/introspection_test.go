@@ -0,0 +1,80 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospectionPolicy(t *testing.T) {
+	policy, err := NewIntrospectionPolicy(IntrospectionEnabled, "roles", "admin")
+	require.NoError(t, err)
+	resolver := resolverFor(t, testSchema, &mockDgraph{}).WithIntrospectionPolicy(policy)
+
+	anonymous := context.Background()
+	user := authorization.WithClaims(anonymous, map[string]interface{}{"sub": "u1"})
+	admin := authorization.WithClaims(anonymous, map[string]interface{}{
+		"sub": "u2", "roles": []interface{}{"editor", "admin"}})
+	introspect := func(ctx context.Context) *schema.Response {
+		return resolver.Resolve(ctx,
+			&schema.Request{Query: `query { __type(name: "Author") { name } }`})
+	}
+
+	tests := []struct {
+		mode    string
+		allowed []context.Context
+		refused []context.Context
+		message string
+	}{
+		{mode: IntrospectionEnabled, allowed: []context.Context{anonymous, user, admin}},
+		{mode: IntrospectionDisabled, refused: []context.Context{anonymous, user, admin},
+			message: "Introspection is disabled on this server"},
+		{mode: IntrospectionAuthenticated, allowed: []context.Context{user, admin},
+			refused: []context.Context{anonymous},
+			message: "Introspection is only allowed for requests with a valid JWT"},
+		{mode: IntrospectionAdmin, allowed: []context.Context{admin},
+			refused: []context.Context{anonymous, user},
+			message: "Introspection is only allowed for admins"},
+	}
+	for _, tc := range tests {
+		require.NoError(t, policy.SetMode(tc.mode))
+		for _, ctx := range tc.allowed {
+			resp := introspect(ctx)
+			require.Empty(t, resp.Errors, tc.mode)
+			require.JSONEq(t, `{"__type": {"name": "Author"}}`, resp.Data.String())
+		}
+		for _, ctx := range tc.refused {
+			resp := introspect(ctx)
+			require.Len(t, resp.Errors, 1, tc.mode)
+			require.Equal(t, tc.message, resp.Errors[0].Message)
+			require.Equal(t, IntrospectionDisabledCode, resp.Errors[0].Extensions["code"])
+		}
+	}
+
+	// Only queries that ask about the schema are refused.
+	require.NoError(t, policy.SetMode(IntrospectionDisabled))
+	resp := resolver.Resolve(anonymous, &schema.Request{Query: `query { __typename }`})
+	require.Empty(t, resp.Errors)
+
+	require.Error(t, policy.SetMode("public"))
+	require.Equal(t, IntrospectionDisabled, policy.Mode())
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+)
+
+// A lambdaRequest asks the lambda server to resolve a @lambda field.
+// Resolver names the field as Type.field, e.g. Author.bio or Query.authors.
+// Parents are the objects that a field of a type is resolved for, each with
+// the fields of the type that are stored in Dgraph; they're missing for
+// queries and mutations.  Claims are those of the request, if it was
+// authenticated.
+type lambdaRequest struct {
+	Resolver string                   `json:"resolver"`
+	Args     map[string]interface{}   `json:"args"`
+	Parents  []map[string]interface{} `json:"parents,omitempty"`
+	Claims   map[string]interface{}   `json:"claims,omitempty"`
+}
+
+// resolveLambda resolves a @lambda query or mutation with a call to the
+// lambda server.  It's a FieldResolverFunc.
+func (cr *customResolver) resolveLambda(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	return cr.callLambda(ctx, &lambdaRequest{
+		Resolver: field.GetObjectName() + "." + field.Name(),
+		Args:     field.Arguments(),
+	})
+}
+
+// resolveLambdaCalls resolves calls, which are for the same @lambda field of
+// different parents, with a single call to the lambda server, which is given
+// each distinct parent once.  It returns the result of each call.
+func (cr *customResolver) resolveLambdaCalls(ctx context.Context,
+	calls []customCall) []interface{} {
+
+	inputs := make([]interface{}, len(calls))
+	for i, c := range calls {
+		inputs[i] = lambdaParent(c.parent)
+	}
+	unique, index := dedupe(inputs)
+	parents := make([]map[string]interface{}, len(unique))
+	for i, p := range unique {
+		parents[i] = p.(map[string]interface{})
+	}
+	res, err := cr.resolveLambdaParents(ctx, calls[0].field, parents)
+
+	results := make([]interface{}, len(calls))
+	for i, c := range calls {
+		if err != nil {
+			results[i] = &failedValue{errs: c.errors(err)}
+			continue
+		}
+		results[i] = aliased(c.field.SelectionSet(), res[index[i]])
+	}
+	return results
+}
+
+// resolveLambdaParents resolves the @lambda field for each of parents with a
+// single call to the lambda server, which must answer with a list of
+// results in the same order as the parents.
+func (cr *customResolver) resolveLambdaParents(ctx context.Context, field schema.Field,
+	parents []map[string]interface{}) ([]interface{}, error) {
+
+	val, err := cr.callLambda(ctx, &lambdaRequest{
+		Resolver: field.GetObjectName() + "." + field.Name(),
+		Args:     field.Arguments(),
+		Parents:  parents,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, ok := val.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("the lambda server should have answered %s with a list of "+
+			"results, one for each parent", field.Name())
+	}
+	if len(res) != len(parents) {
+		return nil, errors.Errorf("the lambda server answered %s with %d results for %d parents",
+			field.Name(), len(res), len(parents))
+	}
+	return res, nil
+}
+
+// callLambda sends req, with the request's claims, to the lambda server and
+// returns the JSON response.
+func (cr *customResolver) callLambda(ctx context.Context,
+	req *lambdaRequest) (interface{}, error) {
+
+	if cr.lambdaURL == "" {
+		return nil, errors.Errorf("%s is a @lambda field, but there's no lambda server "+
+			"configured.  Set lambda.url to the URL of one.", req.Resolver)
+	}
+
+	req.Claims = authorization.Claims(ctx)
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't encode the call to the lambda server for %s",
+			req.Resolver)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, cr.lambdaURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Errorf("couldn't make a request for %s", cr.lambdaURL)
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := cr.lambdaClient.Do(httpReq, cr.lambdaClient.Policy(cr.lambdaURL))
+	if err != nil {
+		return nil, err
+	}
+	return decodeResponse(resp, cr.lambdaURL)
+}
+
+// lambdaParentFields returns the names of the fields of typ that the lambda
+// server is given for each parent: those that are stored in Dgraph and
+// aren't objects.
+func lambdaParentFields(typ schema.Type) []string {
+	var names []string
+	for _, fd := range typ.Fields() {
+		if (fd.DgraphPredicate() == "" && !fd.IsID()) || len(fd.Type().Fields()) > 0 {
+			continue
+		}
+		names = append(names, fd.Name())
+	}
+	return names
+}
+
+// lambdaParent gives the fields of parent, an object in a Dgraph result, that
+// were added for the lambda server by addCustomVariables.
+func lambdaParent(parent map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{})
+	for key, val := range parent {
+		if strings.HasPrefix(key, customVarPrefix) {
+			res[strings.TrimPrefix(key, customVarPrefix)] = val
+		}
+	}
+	return res
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/signing"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const lambdaSchema = `
+type Author {
+	id: ID!
+	firstName: String!
+	lastName: String!
+	fullName: String @lambda
+	posts: [Post]
+}
+
+type Post {
+	id: ID!
+	title: String!
+}
+
+type Query {
+	greeting(name: String!): String @lambda
+}
+`
+
+// lambdaServer is a lambda server for lambdaSchema that records the calls
+// it's sent.
+type lambdaServer struct {
+	mu    sync.Mutex
+	calls []lambdaRequest
+}
+
+func (ls *lambdaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req lambdaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ls.mu.Lock()
+	ls.calls = append(ls.calls, req)
+	ls.mu.Unlock()
+
+	switch req.Resolver {
+	case "Author.fullName":
+		names := make([]string, len(req.Parents))
+		for i, p := range req.Parents {
+			names[i] = p["firstName"].(string) + " " + p["lastName"].(string)
+		}
+		json.NewEncoder(w).Encode(names)
+	case "Query.greeting":
+		json.NewEncoder(w).Encode("Hello, " + req.Args["name"].(string))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestLambdaQueryRewriting(t *testing.T) {
+	resolver := resolverFor(t, lambdaSchema, &mockDgraph{}).WithLambda("")
+
+	op, err := resolver.Schema().Operation(&schema.Request{
+		Query: `query { queryAuthor { firstName fullName } }`,
+	})
+	require.NoError(t, err)
+
+	dgQuery, err := rewriteAsQuery(op.Queries()[0], &authorizer{})
+	require.NoError(t, err)
+	require.Equal(t, `query {
+  queryAuthor(func: type(Author)) {
+    firstName : Author.firstName
+    custom.id : uid
+    custom.firstName : Author.firstName
+    custom.lastName : Author.lastName
+  }
+}`, dgraph.AsString(dgQuery))
+}
+
+func TestLambdaFields(t *testing.T) {
+	ls := &lambdaServer{}
+	srv := httptest.NewServer(ls)
+	defer srv.Close()
+
+	client := &mockDgraph{results: []string{`{"queryAuthor": [
+		{"custom.id": "0x1", "custom.firstName": "Ann", "custom.lastName": "Author"},
+		{"custom.id": "0x2", "custom.firstName": "Bo", "custom.lastName": "Writer"}]}`}}
+	ctx := authorization.WithClaims(context.Background(),
+		map[string]interface{}{"USER": "ann"})
+	resp := resolverFor(t, lambdaSchema, client).WithLambda(srv.URL).Resolve(ctx, &schema.Request{
+		Query: `query { queryAuthor { name: fullName } }`,
+	})
+
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"queryAuthor": [{"name": "Ann Author"}, {"name": "Bo Writer"}]}`,
+		resp.Data.String())
+
+	require.Len(t, ls.calls, 1, "the parents are resolved in one call")
+	require.Equal(t, []map[string]interface{}{
+		{"id": "0x1", "firstName": "Ann", "lastName": "Author"},
+		{"id": "0x2", "firstName": "Bo", "lastName": "Writer"}}, ls.calls[0].Parents)
+	require.Equal(t, map[string]interface{}{"USER": "ann"}, ls.calls[0].Claims)
+}
+
+func TestLambdaQuery(t *testing.T) {
+	ls := &lambdaServer{}
+	srv := httptest.NewServer(ls)
+	defer srv.Close()
+
+	client := &mockDgraph{}
+	resp := resolverFor(t, lambdaSchema, client).WithLambda(srv.URL).Resolve(context.Background(),
+		&schema.Request{Query: `query { greeting(name: "Ann") }`})
+
+	require.Empty(t, resp.Errors)
+	require.Empty(t, client.queries, "lambda queries don't go to Dgraph")
+	require.JSONEq(t, `{"greeting": "Hello, Ann"}`, resp.Data.String())
+	require.Len(t, ls.calls, 1)
+	require.Nil(t, ls.calls[0].Parents)
+	require.Nil(t, ls.calls[0].Claims)
+}
+
+func TestLambdaSigned(t *testing.T) {
+	ls := &lambdaServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := signing.VerifyHMAC(r, "lambda-key", time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ls.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	req := &schema.Request{Query: `query { greeting(name: "Ann") }`}
+	resp := resolverFor(t, lambdaSchema, &mockDgraph{}).WithLambda(srv.URL).
+		Resolve(context.Background(), req)
+	require.NotEmpty(t, resp.Errors, "unsigned calls are refused")
+	require.Contains(t, resp.Errors.Error(), "HTTP status 401")
+
+	resp = resolverFor(t, lambdaSchema, &mockDgraph{}).WithLambda(srv.URL).
+		WithLambdaSigner(signing.NewHMACSigner("lambda-key")).
+		Resolve(context.Background(), req)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"greeting": "Hello, Ann"}`, resp.Data.String())
+}
+
+func TestLambdaErrors(t *testing.T) {
+	wrongLength := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`["Ann Author"]`)) }))
+	defer wrongLength.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		message string
+	}{
+		{
+			name: "no lambda server",
+			message: "Author.fullName is a @lambda field, but there's no lambda server " +
+				"configured.  Set lambda.url to the URL of one.",
+		},
+		{
+			name:    "a result for each parent",
+			url:     wrongLength.URL,
+			message: "the lambda server answered fullName with 1 results for 2 parents",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &mockDgraph{results: []string{`{"queryAuthor": [
+				{"custom.firstName": "Ann"}, {"custom.firstName": "Bo"}]}`}}
+			resp := resolverFor(t, lambdaSchema, client).WithLambda(test.url).Resolve(context.Background(),
+				&schema.Request{Query: `query { queryAuthor { fullName } }`})
+
+			require.Len(t, resp.Errors, 2, "every parent has an error")
+			require.Equal(t, test.message, resp.Errors[0].Message)
+			require.Equal(t, []interface{}{"queryAuthor", 0, "fullName"}, resp.Errors[0].Path)
+			require.JSONEq(t, `{"queryAuthor": [{"fullName": null}, {"fullName": null}]}`,
+				resp.Data.String())
+		})
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// defaultListWeight is how many items a list field is estimated to return,
+// when the query doesn't limit it with first.
+const defaultListWeight = 10
+
+// maxInt is the largest int.
+const maxInt = int(^uint(0) >> 1)
+
+// QueryLimits bound how much work an operation can ask for, so that a deeply
+// nested or very broad query can't tie up Dgraph.  Operations over a limit
+// are refused before anything is sent to Dgraph.  A limit of 0 is no limit.
+// Introspection isn't limited.
+type QueryLimits struct {
+	// MaxDepth limits how deeply selection sets can be nested.  A query's
+	// root fields are at depth 1.
+	MaxDepth int
+
+	// MaxFields limits how many fields an operation can ask for.
+	MaxFields int
+
+	// MaxComplexity limits an operation's estimated cost: each field costs 1,
+	// and the fields asked for on the items of a list count once for each
+	// item there could be, which is the list's first argument, or
+	// defaultListWeight if first isn't given.  No list's first can be more
+	// than MaxComplexity.
+	MaxComplexity int
+
+	// MaxCost limits an operation's estimated Cost, which, unlike its
+	// complexity, takes into account how many nodes of each type there are.
+	MaxCost int
+
+	// ReportCost adds each operation's estimated Cost to its response's
+	// extensions, as cost.
+	ReportCost bool
+}
+
+// check returns the errors for the limits that op is over.
+func (l QueryLimits) check(op schema.Operation) gqlerror.List {
+	if l.MaxDepth <= 0 && l.MaxFields <= 0 && l.MaxComplexity <= 0 {
+		return nil
+	}
+
+	var roots []schema.Field
+	for _, q := range op.Queries() {
+		if q.QueryType() != schema.SchemaQuery {
+			roots = append(roots, q)
+		}
+	}
+	for _, m := range op.Mutations() {
+		roots = append(roots, m)
+	}
+	for _, s := range op.Subscriptions() {
+		roots = append(roots, s)
+	}
+	// Complexity is only counted up to just over the limit, so that lists
+	// of many items can't overflow it.
+	ceiling := maxInt
+	if l.MaxComplexity > 0 {
+		ceiling = l.MaxComplexity + 1
+	}
+	depth, fields, complexity, longest := measure(roots, 1, ceiling)
+
+	var errs gqlerror.List
+	over := func(limit, val int, format string) {
+		if limit > 0 && val > limit {
+			errs = append(errs, &gqlerror.Error{
+				Message:    fmt.Sprintf(format, val, limit),
+				Extensions: map[string]interface{}{"code": limitExceededCode},
+			})
+		}
+	}
+	over(l.MaxDepth, depth,
+		"The operation is nested %d levels deep, but the most that's allowed is %d.")
+	over(l.MaxFields, fields,
+		"The operation asks for %d fields, but the most that's allowed is %d.")
+	// A list of more items than the complexity allows can't be within it.
+	over(l.MaxComplexity, longest,
+		"The operation asks for a list of %d items, but the most that's allowed is %d.")
+	over(l.MaxComplexity, complexity,
+		"The operation's estimated complexity is more than %[2]d, the most that's allowed.")
+	return errs
+}
+
+// measure returns how deeply nested fields, which are at depth, are, how
+// many fields there are in all, their estimated complexity, up to ceiling,
+// and the most items that any of their lists asks for.
+func measure(fields []schema.Field, depth, ceiling int) (maxDepth, count, complexity,
+	longest int) {
+
+	for _, f := range fields {
+		// __typename is filled in without asking Dgraph.
+		if f.Name() == "__typename" {
+			continue
+		}
+
+		childDepth, childCount, childComplexity, childLongest :=
+			measure(allSelections(f), depth+1, ceiling)
+		if maxDepth < depth {
+			maxDepth = depth
+		}
+		if maxDepth < childDepth {
+			maxDepth = childDepth
+		}
+		weight := listWeight(f)
+		if longest < weight {
+			longest = weight
+		}
+		if longest < childLongest {
+			longest = childLongest
+		}
+		count += 1 + childCount
+		complexity = addSaturated(complexity,
+			addSaturated(1, mulSaturated(weight, childComplexity, ceiling), ceiling), ceiling)
+	}
+	return
+}
+
+// listWeight is how many items f is estimated to have, or 1 if it isn't a
+// list.
+func listWeight(f schema.Field) int {
+	if f.Type().ListType() == nil {
+		return 1
+	}
+	if first, ok := f.ArgValue("first").(int64); ok && first >= 0 {
+		if first > int64(maxInt) {
+			return maxInt
+		}
+		return int(first)
+	}
+	return defaultListWeight
+}
+
+// addSaturated is a + b, or ceiling if that's more, for a, b >= 0.
+func addSaturated(a, b, ceiling int) int {
+	if a > ceiling-b {
+		return ceiling
+	}
+	return a + b
+}
+
+// mulSaturated is a * b, or ceiling if that's more, for a, b >= 0.
+func mulSaturated(a, b, ceiling int) int {
+	if a != 0 && b > ceiling/a {
+		return ceiling
+	}
+	return a * b
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLimits(t *testing.T) {
+	// 4 levels deep, with 6 fields.  author costs 2 (itself and name), posts,
+	// as defaultListWeight items, 1 + 10*(1 + 2) = 31 and queryAuthor
+	// 1 + 2*(1 + 31) = 65.
+	query := `query {
+		queryAuthor(first: 2) {
+			name
+			posts { __typename title author { name } }
+		}
+	}`
+
+	tests := map[string]struct {
+		limits QueryLimits
+		errs   []string
+	}{
+		"no limits":     {},
+		"within limits": {limits: QueryLimits{MaxDepth: 4, MaxFields: 6, MaxComplexity: 65}},
+		"too deep": {
+			limits: QueryLimits{MaxDepth: 3},
+			errs: []string{
+				"The operation is nested 4 levels deep, but the most that's allowed is 3."},
+		},
+		"too many fields and too complex": {
+			limits: QueryLimits{MaxFields: 5, MaxComplexity: 60},
+			errs: []string{
+				"The operation asks for 6 fields, but the most that's allowed is 5.",
+				"The operation's estimated complexity is more than 60, the most that's allowed."},
+		},
+	}
+
+	for name, tcase := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &mockDgraph{}
+			resp := resolverFor(t, testSchema, client).WithLimits(tcase.limits).Resolve(
+				context.Background(), &schema.Request{Query: query})
+
+			var errs []string
+			for _, err := range resp.Errors {
+				errs = append(errs, err.Message)
+			}
+			require.Equal(t, tcase.errs, errs)
+			if len(tcase.errs) > 0 {
+				require.Empty(t, client.queries, "refused before reaching Dgraph")
+			}
+		})
+	}
+}
+
+func TestQueryLimitsListWeight(t *testing.T) {
+	// Without first, a list is counted as defaultListWeight items, and
+	// introspection isn't limited.
+	resp := resolverFor(t, testSchema, &mockDgraph{}).
+		WithLimits(QueryLimits{MaxComplexity: 1 + defaultListWeight}).
+		Resolve(context.Background(), &schema.Request{Query: `query {
+			queryAuthor { name }
+			__schema { types { name fields { name type { name } } } }
+		}`})
+	require.Empty(t, resp.Errors)
+
+	resp = resolverFor(t, testSchema, &mockDgraph{}).
+		WithLimits(QueryLimits{MaxComplexity: defaultListWeight}).
+		Resolve(context.Background(), &schema.Request{Query: `query { queryAuthor { name } }`})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, limitExceededCode, resp.Errors[0].Extensions["code"])
+}
+
+func TestQueryLimitsOverflow(t *testing.T) {
+	// 1 + 2147483647*(1 + 2147483647*(1 + 1 + 2147483647*1)) is more than an
+	// int can hold.
+	query := `query {
+		queryAuthor(first: 2147483647) {
+			posts(first: 2147483647) { author { posts(first: 2147483647) { title } } }
+		}
+	}`
+	client := &mockDgraph{}
+	resp := resolverFor(t, testSchema, client).
+		WithLimits(QueryLimits{MaxComplexity: 1000}).
+		Resolve(context.Background(), &schema.Request{Query: query})
+
+	var errs []string
+	for _, err := range resp.Errors {
+		errs = append(errs, err.Message)
+	}
+	require.Equal(t, []string{
+		"The operation asks for a list of 2147483647 items, but the most that's allowed is 1000.",
+		"The operation's estimated complexity is more than 1000, the most that's allowed.",
+	}, errs)
+	require.Empty(t, client.queries, "refused before reaching Dgraph")
+
+	// Without a limit, it stops at the largest int.
+	op := operationFor(t, testSchema, query)
+	_, _, complexity, _ := measure([]schema.Field{op.Queries()[0]}, 1, maxInt)
+	require.Equal(t, maxInt, complexity)
+}
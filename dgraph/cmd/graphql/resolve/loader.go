@@ -0,0 +1,105 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// A callLoader makes the @custom HTTP calls of one operation, making each
+// distinct call only once.  A call that's the same as one that's been made,
+// or is being made, shares its result.  Together with resolveFields, which
+// gathers the calls of a field for all its parents so they can be batched,
+// that's a dataloader: an operation that asks for the same thing many times
+// over only asks the external service for it once.
+type callLoader struct {
+	mu    sync.Mutex
+	calls map[string]*loadedCall
+}
+
+// A loadedCall is the result of a call, which is ready once done is closed.
+type loadedCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+type callLoaderKey struct{}
+
+// withCallLoader returns a copy of ctx with a new callLoader, so the calls
+// made with it are only made once.
+func withCallLoader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callLoaderKey{},
+		&callLoader{calls: make(map[string]*loadedCall)})
+}
+
+// loadCall returns the result of the call that key identifies.  If ctx's
+// callLoader has made the call already, it's that result; otherwise it's the
+// result of fn, which makes the call.  If ctx has no callLoader, fn is always
+// called.
+func loadCall(ctx context.Context, key string,
+	fn func() (interface{}, error)) (interface{}, error) {
+
+	cl, _ := ctx.Value(callLoaderKey{}).(*callLoader)
+	if cl == nil {
+		return fn()
+	}
+
+	cl.mu.Lock()
+	call, ok := cl.calls[key]
+	if !ok {
+		call = &loadedCall{done: make(chan struct{})}
+		cl.calls[key] = call
+	}
+	cl.mu.Unlock()
+
+	if !ok {
+		call.val, call.err = fn()
+		close(call.done)
+		return call.val, call.err
+	}
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// dedupe returns the distinct values in items, by their JSON encoding, and
+// the index of each of items in those distinct values.
+func dedupe(items []interface{}) ([]interface{}, []int) {
+	var unique []interface{}
+	index := make([]int, len(items))
+	seen := make(map[string]int)
+	for i, item := range items {
+		js, err := json.Marshal(item)
+		if j, ok := seen[string(js)]; ok && err == nil {
+			index[i] = j
+			continue
+		}
+		if err == nil {
+			seen[string(js)] = len(unique)
+		}
+		index[i] = len(unique)
+		unique = append(unique, item)
+	}
+	return unique, index
+}
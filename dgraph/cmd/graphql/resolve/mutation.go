@@ -0,0 +1,457 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/graphql/tracing"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const deletedMsg = "Deleted"
+
+// mutationResolver can resolve a single GraphQL mutation field.
+//
+// All the work for a mutation happens in one Dgraph transaction:
+//  1. for updates and deletes, find the uids of the nodes the filter matches,
+//  2. rewrite the GraphQL mutation into a Dgraph mutation and run it,
+//  3. query (in the same transaction) the mutated nodes for the payload,
+//  4. commit,
+//  5. record the mutation in the audit log, if there is one, send its
+//     event, if its type has @lambdaOnMutate, and publish its changes, if
+//     they're captured.
+//
+// If the transaction is aborted, or Dgraph can't be reached before the
+// commit, it's all run again as retries says.  The mutations of an @atomic
+// operation share one transaction, which is only committed once they've all
+// run.
+type mutationResolver struct {
+	mutation     schema.Mutation
+	dgraphClient dgraph.Client
+	retries      RetryPolicy
+	files        FileStore
+	remoteClient *external.Client
+	secrets      secrets.Provider
+	lambdaURL    string
+	lambdaClient *external.Client
+	signed       map[string]*external.Client
+	audit        *auditLog
+	events       *eventSender
+	changes      *changePublisher
+
+	// uids are the nodes that the last run of mutate added, updated, deleted
+	// or restored.  before and after are their images, keyed by uid, if
+	// changes are published.
+	uids   []uint64
+	before map[string]map[string]interface{}
+	after  map[string]map[string]interface{}
+}
+
+func (mr *mutationResolver) resolve(ctx context.Context) *resolved {
+	if glog.V(3) {
+		glog.Infof("Resolving mutation %s", mr.mutation.Name())
+	}
+
+	var res *resolved
+	switch mr.mutation.MutationType() {
+	case schema.TypenameMutation:
+		return resolveWith(ctx, mr.mutation, resolveTypename)
+	case schema.RemoteMutation:
+		res = resolveRemote(ctx, mr.remoteClient, "mutation", mr.mutation, mr.mutation.Remote())
+	case schema.CustomMutation:
+		res = mr.custom().resolveRoot(ctx, mr.mutation)
+	case schema.LambdaMutation:
+		res = resolveWith(ctx, mr.mutation, mr.custom().resolveLambda)
+	}
+	if res != nil {
+		if len(schema.AsGQLErrors(res.err)) == 0 {
+			mr.audit.record(ctx, mr.mutation, nil)
+		}
+		return res
+	}
+
+	var payload map[string]interface{}
+	err := mr.retries.transaction(ctx, mr.dgraphClient, func(txn dgraph.Txn) error {
+		var err error
+		payload, err = mr.mutate(ctx, txn)
+		return err
+	})
+	if err != nil {
+		return mr.failed(err)
+	}
+	mr.audit.record(ctx, mr.mutation, mr.uids)
+	mr.sendEvent(ctx)
+	mr.publishChanges(ctx)
+	return mr.complete(ctx, payload)
+}
+
+func (mr *mutationResolver) custom() *customResolver {
+	return &customResolver{client: mr.remoteClient, secrets: mr.secrets,
+		lambdaURL: mr.lambdaURL, lambdaClient: mr.lambdaClient, signed: mr.signed}
+}
+
+// mutate runs the mutation in txn, without committing it, and returns the
+// payload read back from txn.
+func (mr *mutationResolver) mutate(ctx context.Context,
+	txn dgraph.Txn) (map[string]interface{}, error) {
+
+	mr.uids = nil
+	mr.before, mr.after = nil, nil
+
+	// A mutation whose request was abandoned, or ran out of time, isn't
+	// rewritten or sent to Dgraph.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := storeUploads(ctx, mr.files, mr.mutation.Arguments()); err != nil {
+		return nil, err
+	}
+	auth := newAuthorizer(ctx)
+	var payload map[string]interface{}
+	var err error
+	switch mr.mutation.MutationType() {
+	case schema.AddMutation:
+		payload, err = mr.resolveAdd(ctx, txn, auth)
+	case schema.UpdateMutation:
+		payload, err = mr.resolveUpdate(ctx, txn, auth)
+	case schema.DeleteMutation:
+		if mr.mutation.MutatedType().SoftDelete() != nil {
+			payload, err = mr.resolveSoftDelete(ctx, txn, auth)
+		} else {
+			payload, err = mr.resolveDelete(ctx, txn, auth)
+		}
+	case schema.RestoreMutation:
+		payload, err = mr.resolveRestore(ctx, txn, auth)
+	default:
+		return nil, errors.Errorf("mutation %s is not supported", mr.mutation.Name())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := mr.readAfter(ctx, txn); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// failed is the result of the mutation when it failed with err: the mutation
+// field is null.
+func (mr *mutationResolver) failed(err error) *resolved {
+	null, _ := completeField(mr.mutation, nil)
+	return &resolved{data: null, err: fieldErrors(mr.mutation, err)}
+}
+
+// complete completes the result of the mutation from payload, once the
+// mutation is committed.
+func (mr *mutationResolver) complete(ctx context.Context,
+	payload map[string]interface{}) *resolved {
+
+	// The payload's @custom and @lambda fields are resolved after the commit, so they see
+	// the mutation's changes.
+	newAuthorizer(ctx).denyFields([]interface{}{mr.mutation.ResponseName()}, mr.mutation,
+		payload)
+	mr.custom().resolveFields(ctx, []interface{}{mr.mutation.ResponseName()}, mr.mutation,
+		payload)
+	_, end := tracing.StartPhase(ctx, tracing.Complete)
+	data, errs := completeField(mr.mutation, payload)
+	end()
+	if len(errs) > 0 {
+		return &resolved{data: data, err: errs}
+	}
+	return &resolved{data: data}
+}
+
+func (mr *mutationResolver) resolveAdd(ctx context.Context, txn dgraph.Txn,
+	auth *authorizer) (map[string]interface{}, error) {
+
+	err := auth.checkInput(mr.mutation.MutatedType(), mr.mutation.ArgValue(schema.InputArgName))
+	if err != nil {
+		return nil, err
+	}
+	xids, err := lookupXids(ctx, txn, mutationXids(mr.mutation))
+	if err != nil {
+		return nil, err
+	}
+
+	mrw := &mutationRewriter{xids: xids}
+	_, end := tracing.StartPhase(ctx, tracing.Rewrite)
+	mut, blankNodes, err := mrw.rewriteAdd(mr.mutation)
+	end()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := auth.checkNodes(ctx, txn, schema.AuthUpdate, &mrw.linked, nil); err != nil {
+		return nil, err
+	}
+	assigned, err := txn.Mutate(ctx, mut)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.checkNodes(ctx, txn, schema.AuthAdd, &mrw.added, assigned); err != nil {
+		return nil, err
+	}
+
+	uids := make([]uint64, 0, len(blankNodes))
+	for _, blank := range blankNodes {
+		// Nodes that already existed, because of their @xid, aren't assigned
+		// a uid; they're named by theirs.
+		id, ok := assigned[blank]
+		if !ok {
+			id = blank
+		}
+		uid, err := strconv.ParseUint(id, 0, 64)
+		if err != nil {
+			return nil, errors.Errorf("couldn't find the uid assigned to new node %s", blank)
+		}
+		uids = append(uids, uid)
+	}
+
+	return mr.payload(ctx, txn, uids, auth)
+}
+
+func (mr *mutationResolver) resolveUpdate(ctx context.Context, txn dgraph.Txn,
+	auth *authorizer) (map[string]interface{}, error) {
+
+	input, _ := mr.mutation.ArgValue(schema.InputArgName).(map[string]interface{})
+	for _, patch := range []string{"set", "remove"} {
+		if err := auth.checkInput(mr.mutation.MutatedType(), input[patch]); err != nil {
+			return nil, err
+		}
+	}
+	filter, _ := input[schema.FilterArgName].(map[string]interface{})
+	query, err := rewriteAsFilterQuery(mr.mutation.ResponseName(),
+		mr.mutation.MutatedType(), filter, auth, schema.AuthUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Soft-deleted nodes can't be updated until they're restored.
+	typ := mr.mutation.MutatedType()
+	query.Filter = combine("and", query.Filter, notDeleted(typ))
+	if version := typ.VersionField(); version != nil {
+		query.Children = append(query.Children, &gql.GraphQuery{Attr: version.DgraphPredicate()})
+	}
+
+	nodes, err := queryNodes(ctx, txn, query)
+	if err != nil {
+		return nil, err
+	}
+	if version := typ.VersionField(); version != nil {
+		expected, err := expectedVersion(mr.mutation)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVersions(typ, version, nodes, expected); err != nil {
+			return nil, err
+		}
+	}
+	uids, err := nodeUIDs(nodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCondition(ctx, txn, mr.mutation, uids); err != nil {
+		return nil, err
+	}
+	if err := mr.readBefore(ctx, txn, uids); err != nil {
+		return nil, err
+	}
+
+	if len(uids) > 0 {
+		xids, err := lookupXids(ctx, txn, mutationXids(mr.mutation))
+		if err != nil {
+			return nil, err
+		}
+		mrw := &mutationRewriter{xids: xids}
+		_, end := tracing.StartPhase(ctx, tracing.Rewrite)
+		mut, err := mrw.rewriteUpdate(mr.mutation, uids)
+		end()
+		if err != nil {
+			return nil, err
+		}
+
+		err = auth.checkNodes(ctx, txn, schema.AuthUpdate, &mrw.linked, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(mut.SetJson) > 0 || len(mut.DeleteJson) > 0 {
+			assigned, err := txn.Mutate(ctx, mut)
+			if err != nil {
+				return nil, err
+			}
+			err = auth.checkNodes(ctx, txn, schema.AuthAdd, &mrw.added, assigned)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return mr.payload(ctx, txn, uids, auth)
+}
+
+func (mr *mutationResolver) resolveDelete(ctx context.Context, txn dgraph.Txn,
+	auth *authorizer) (map[string]interface{}, error) {
+
+	query, err := deleteQuery(mr.mutation, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := queryNodes(ctx, txn, query)
+	if err != nil {
+		return nil, err
+	}
+	uids, err := nodeUIDs(nodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCondition(ctx, txn, mr.mutation, uids); err != nil {
+		return nil, err
+	}
+	if err := mr.readBefore(ctx, txn, uids); err != nil {
+		return nil, err
+	}
+
+	if len(nodes) > 0 {
+		dels, err := deleteNodes(ctx, txn, mr.mutation.MutatedType(), nodes, auth)
+		if err != nil {
+			return nil, err
+		}
+
+		delJSON, err := json.Marshal(dels)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't marshal mutation to JSON")
+		}
+		if _, err := txn.Mutate(ctx, &api.Mutation{DeleteJson: delJSON}); err != nil {
+			return nil, err
+		}
+	}
+
+	mr.uids = uids
+	return map[string]interface{}{"msg": deletedMsg}, nil
+}
+
+// payload queries, in txn, the nodes with the given uids, which the mutation
+// mutated, for its payload.  The result is the payload object, before it's
+// completed.
+func (mr *mutationResolver) payload(ctx context.Context, txn dgraph.Txn,
+	uids []uint64, auth *authorizer) (map[string]interface{}, error) {
+
+	mr.uids = uids
+	queryField := mr.mutation.QueryField()
+	if queryField == nil {
+		return map[string]interface{}{}, nil
+	}
+	if len(uids) == 0 {
+		return map[string]interface{}{queryField.ResponseName(): []interface{}{}}, nil
+	}
+
+	query, err := rewriteAsQueryByIds(queryField, uids, auth)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := txn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var res map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(resp))
+	dec.UseNumber()
+	if err := dec.Decode(&res); err != nil {
+		return nil, errors.Wrap(err, "couldn't unmarshal response from Dgraph")
+	}
+	return res, nil
+}
+
+// deleteQuery builds the query that finds the nodes a delete mutation
+// removes.  Along with each node's uid, it finds the nodes linked by any edge
+// with an inverse, so those inverse edges can be removed too, and the nodes
+// linking to it by @onDelete fields.
+func deleteQuery(m schema.Mutation, auth *authorizer) (*gql.GraphQuery, error) {
+	filter, _ := m.ArgValue(schema.FilterArgName).(map[string]interface{})
+	query, err := rewriteAsFilterQuery(
+		m.ResponseName(), m.MutatedType(), filter, auth, schema.AuthDelete)
+	if err != nil {
+		return nil, err
+	}
+
+	query.Children = append(query.Children, deleteChildren(m.MutatedType())...)
+	return query, nil
+}
+
+// deleteChildren are the edges of a node of type typ that deleting it needs
+// to know about: the edges with an inverse, and the reverse edges of the
+// @onDelete fields that link to it.
+func deleteChildren(typ schema.Type) []*gql.GraphQuery {
+	var children []*gql.GraphQuery
+	for _, fld := range inverseFields(typ) {
+		children = append(children, &gql.GraphQuery{
+			Attr:     fld.DgraphPredicate(),
+			Children: []*gql.GraphQuery{{Attr: "uid"}},
+		})
+	}
+	for _, fld := range typ.ReferencedBy() {
+		children = append(children, &gql.GraphQuery{
+			Alias:    referrersAlias(fld),
+			Attr:     "~" + fld.DgraphPredicate(),
+			Filter:   typeFilter(fld.ParentType()),
+			Children: []*gql.GraphQuery{{Attr: "uid"}},
+		})
+	}
+	return children
+}
+
+// nodeUIDs returns the uids of nodes, the result of queryNodes.
+func nodeUIDs(nodes []map[string]interface{}) ([]uint64, error) {
+	uids := make([]uint64, 0, len(nodes))
+	for _, node := range nodes {
+		uid, err := convertIDs([]interface{}{node["uid"]})
+		if err != nil {
+			return nil, err
+		}
+		uids = append(uids, uid[0])
+	}
+	return uids, nil
+}
+
+// queryNodes runs query in txn and returns the list of nodes in the result.
+func queryNodes(ctx context.Context, txn dgraph.Txn,
+	query *gql.GraphQuery) ([]map[string]interface{}, error) {
+
+	resp, err := txn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var res map[string][]map[string]interface{}
+	if err := json.Unmarshal(resp, &res); err != nil {
+		return nil, errors.Wrap(err, "couldn't unmarshal response from Dgraph")
+	}
+	return res[query.Attr], nil
+}
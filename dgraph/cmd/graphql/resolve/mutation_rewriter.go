@@ -0,0 +1,498 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+)
+
+// A mutationRewriter turns the input of a GraphQL mutation into the JSON of
+// a Dgraph mutation.  New nodes are given blank node names like _:Author1,
+// so the uids Dgraph assigns can be matched back to the input.
+type mutationRewriter struct {
+	counter int
+
+	// now is the time of the mutation, that's the value of $now defaults.
+	now time.Time
+
+	// linking are the objects written through reverse fields.  Their edges
+	// back to the nodes that have the reverse fields are set from their end,
+	// so they're in the mutation as well as those nodes.
+	linking []interface{}
+
+	// xids maps the external IDs of @xid fields to the uids, or blank nodes,
+	// of their nodes: those already in Dgraph, and those added by the mutation.
+	xids map[string]string
+
+	// added are the new nodes of the input, at any depth, and linked are the
+	// existing nodes that it writes to, other than the nodes an update
+	// mutation's filter found, so they can be checked against @auth rules.
+	added, linked authNodes
+}
+
+func (mrw *mutationRewriter) nextBlankNode(typ schema.Type) string {
+	mrw.counter++
+	return fmt.Sprintf("%s%v", typ.Name(), mrw.counter)
+}
+
+// rewriteAdd builds the Dgraph mutation for an addT mutation.  It returns the
+// mutation and the blank node names (without the "_:") of the top level
+// objects that are added, in the order they appeared in the input.  Objects
+// written to existing nodes, because of their @xid, have those nodes' uids
+// instead.
+func (mrw *mutationRewriter) rewriteAdd(m schema.Mutation) (*api.Mutation, []string, error) {
+	inputs, ok := m.ArgValue(schema.InputArgName).([]interface{})
+	if !ok {
+		return nil, nil, errors.Errorf("couldn't understand the input of mutation %s", m.Name())
+	}
+
+	mrw.now, mrw.linking = time.Now(), nil
+	typ := m.MutatedType()
+	var objs []interface{}
+	var blankNodes []string
+	for _, input := range inputs {
+		obj, ok := input.(map[string]interface{})
+		if !ok {
+			return nil, nil,
+				errors.Errorf("couldn't understand the input of mutation %s", m.Name())
+		}
+
+		node, uid, err := mrw.rewriteNode(typ, obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		objs = append(objs, node)
+		blankNodes = append(blankNodes, strings.TrimPrefix(uid, "_:"))
+	}
+	objs = append(objs, mrw.linking...)
+
+	setJSON, err := json.Marshal(objs)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "couldn't marshal mutation to JSON")
+	}
+
+	return &api.Mutation{SetJson: setJSON}, blankNodes, nil
+}
+
+// rewriteUpdate builds the Dgraph mutation for an updateT mutation that's
+// applied to the nodes with the given uids.  The set patch is added to each
+// node and the values in the remove patch are deleted from each node.
+func (mrw *mutationRewriter) rewriteUpdate(m schema.Mutation, uids []uint64) (*api.Mutation, error) {
+	input, ok := m.ArgValue(schema.InputArgName).(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("couldn't understand the input of mutation %s", m.Name())
+	}
+	setPatch, _ := input["set"].(map[string]interface{})
+	removePatch, _ := input["remove"].(map[string]interface{})
+
+	mrw.now, mrw.linking = time.Now(), nil
+	typ := m.MutatedType()
+	setPatch = mrw.withDefaults(typ, schema.UpdateMutation, setPatch)
+	// The nodes were checked to be at the expected version before the update,
+	// which moves them on to the next one.
+	if version := typ.VersionField(); version != nil {
+		expected, err := expectedVersion(m)
+		if err != nil {
+			return nil, err
+		}
+		setPatch = withValue(setPatch, version.Name(), expected+1)
+	}
+	var sets, dels []interface{}
+	for _, uid := range uids {
+		uidStr := fmt.Sprintf("%#x", uid)
+
+		if len(setPatch) > 0 {
+			node, err := mrw.rewriteFields(typ, uidStr, setPatch)
+			if err != nil {
+				return nil, err
+			}
+			sets = append(sets, node)
+		}
+
+		if len(removePatch) > 0 {
+			ds, err := rewriteRemove(typ, uidStr, removePatch)
+			if err != nil {
+				return nil, err
+			}
+			dels = append(dels, ds...)
+		}
+	}
+
+	sets = append(sets, mrw.linking...)
+
+	mut := &api.Mutation{}
+	var err error
+	if len(sets) > 0 {
+		if mut.SetJson, err = json.Marshal(sets); err != nil {
+			return nil, errors.Wrap(err, "couldn't marshal mutation to JSON")
+		}
+	}
+	if len(dels) > 0 {
+		if mut.DeleteJson, err = json.Marshal(dels); err != nil {
+			return nil, errors.Wrap(err, "couldn't marshal mutation to JSON")
+		}
+	}
+
+	return mut, nil
+}
+
+// rewriteDelete builds the deletions of the given nodes of type typ.  Each
+// node is the result of a query with the edges from deleteChildren: its uid,
+// the uids of any nodes it links to by edges that have an inverse, and the
+// uids of the nodes linking to it by @onDelete fields.  The inverse edges
+// pointing back at the deleted node are removed along with the node, and so
+// are the edges of DISCONNECT fields.  The nodes linking to it by CASCADE
+// fields are returned, to be deleted in turn.  Nodes already in deleted are
+// skipped, and the rest are added to it.
+func rewriteDelete(typ schema.Type, nodes []map[string]interface{},
+	deleted map[string]bool) ([]interface{}, []*cascade, error) {
+
+	var dels []interface{}
+	var cascades []*cascade
+	for _, node := range nodes {
+		uid, ok := node["uid"].(string)
+		if !ok {
+			return nil, nil, errors.New("couldn't find uid of node to delete")
+		}
+		if deleted[uid] {
+			continue
+		}
+		deleted[uid] = true
+		dels = append(dels, map[string]interface{}{"uid": uid})
+
+		for _, fld := range inverseFields(typ) {
+			for _, linked := range asList(node[fld.DgraphPredicate()]) {
+				link, ok := linked.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				dels = append(dels, map[string]interface{}{
+					"uid":                           link["uid"],
+					fld.Inverse().DgraphPredicate(): map[string]interface{}{"uid": uid},
+				})
+			}
+		}
+
+		refDels, more, err := rewriteReferrers(typ, uid, node, deleted)
+		if err != nil {
+			return nil, nil, err
+		}
+		dels = append(dels, refDels...)
+		cascades = append(cascades, more...)
+	}
+	return dels, cascades, nil
+}
+
+// rewriteNewNode builds the JSON for a new node of type typ, identified by
+// the blank node uid, from the input object obj.
+func (mrw *mutationRewriter) rewriteNewNode(typ schema.Type, uid string,
+	obj map[string]interface{}) (map[string]interface{}, error) {
+
+	obj = mrw.withDefaults(typ, schema.AddMutation, obj)
+	if version := typ.VersionField(); version != nil {
+		obj = withValue(obj, version.Name(), 1)
+	}
+	node, err := mrw.rewriteFields(typ, uid, obj)
+	if err != nil {
+		return nil, err
+	}
+	node[dgraphTypePredicate] = dgraphTypes(typ)
+	return node, nil
+}
+
+// dgraphTypes is the dgraph.type of nodes of type typ.  A node is also of all
+// the interfaces its type implements, so that queries on the interface find
+// it.
+func dgraphTypes(typ schema.Type) interface{} {
+	if ifaces := typ.Interfaces(); len(ifaces) > 0 {
+		return append([]string{typ.DgraphName()}, ifaces...)
+	}
+	return typ.DgraphName()
+}
+
+// withDefaults returns obj with the @default values, for mutations of type
+// mut, of the fields of typ that obj doesn't set.  obj isn't changed.
+func (mrw *mutationRewriter) withDefaults(typ schema.Type, mut schema.MutationType,
+	obj map[string]interface{}) map[string]interface{} {
+
+	var res map[string]interface{}
+	for _, fld := range typ.Fields() {
+		if _, ok := obj[fld.Name()]; ok {
+			continue
+		}
+		val, ok := fld.DefaultValue(mut, mrw.now)
+		if !ok {
+			continue
+		}
+		if res == nil {
+			res = make(map[string]interface{}, len(obj)+1)
+			for k, v := range obj {
+				res[k] = v
+			}
+		}
+		res[fld.Name()] = val
+	}
+	if res == nil {
+		return obj
+	}
+	return res
+}
+
+// withValue returns a copy of obj with field fld set to val.
+func withValue(obj map[string]interface{}, fld string, val interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(obj)+1)
+	for k, v := range obj {
+		res[k] = v
+	}
+	res[fld] = val
+	return res
+}
+
+// rewriteFields builds the JSON that sets the fields in obj on the node uid
+// of type typ.
+func (mrw *mutationRewriter) rewriteFields(typ schema.Type, uid string,
+	obj map[string]interface{}) (map[string]interface{}, error) {
+
+	node := map[string]interface{}{"uid": uid}
+	for _, name := range sortedKeys(obj) {
+		val := obj[name]
+		fld := typ.Field(name)
+		if fld == nil {
+			return nil, errors.Errorf("%s is not a field of type %s", name, typ.Name())
+		}
+
+		if !isReference(fld) {
+			stored, err := storedValue(fld, val)
+			if err != nil {
+				return nil, err
+			}
+			node[fld.DgraphPredicate()] = stored
+			continue
+		}
+
+		rewrite := mrw.rewriteReference
+		if fld.EdgeOf() != nil {
+			rewrite = mrw.rewriteEdge
+		}
+		if isReverse(fld) {
+			for _, r := range asList(val) {
+				ref, err := rewrite(fld, uid, r)
+				if err != nil {
+					return nil, err
+				}
+				mrw.linking = append(mrw.linking, ref)
+			}
+			continue
+		}
+
+		switch v := val.(type) {
+		case []interface{}:
+			// A list field and its edges can both be given.
+			refs, _ := node[fld.DgraphPredicate()].([]interface{})
+			for _, r := range v {
+				ref, err := rewrite(fld, uid, r)
+				if err != nil {
+					return nil, err
+				}
+				refs = append(refs, ref)
+			}
+			node[fld.DgraphPredicate()] = refs
+		case nil:
+			// A null reference is no link at all.
+		default:
+			if _, ok := node[fld.DgraphPredicate()]; ok && fld.EdgeOf() != nil {
+				return nil, errors.Errorf("%s and %s can't both be given",
+					fld.EdgeOf().Name(), fld.Name())
+			}
+			ref, err := rewrite(fld, uid, v)
+			if err != nil {
+				return nil, err
+			}
+			node[fld.DgraphPredicate()] = ref
+		}
+	}
+
+	return node, nil
+}
+
+// rewriteReference builds the JSON for val, a reference (a TRef input) that's
+// linked from node srcUID by field fld.  If the reference has an ID, or an
+// @xid value that's in Dgraph, it links to that existing node, otherwise it's
+// a new node - unless new nodes of its type can't be added, in which case
+// it's an error.  If fld has an inverse, the inverse edge back to srcUID is
+// added too.
+func (mrw *mutationRewriter) rewriteReference(fld schema.FieldDefinition, srcUID string,
+	val interface{}) (map[string]interface{}, error) {
+
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("couldn't understand the value of field %s", fld.Name())
+	}
+
+	typ := fld.Type()
+	var ref map[string]interface{}
+	if id, ok := referenceID(typ, obj); ok {
+		uid, err := convertIDs([]interface{}{id})
+		if err != nil {
+			return nil, err
+		}
+		ref = map[string]interface{}{"uid": fmt.Sprintf("%#x", uid[0])}
+		if inv := fld.Inverse(); inv != nil && !isReverse(inv) {
+			mrw.linked.add(typ, ref["uid"].(string))
+		}
+	} else if !typ.Addable() {
+		return nil, errors.Errorf("a reference to a %s in field %s needs its %s, "+
+			"because new %s objects can't be added", typ.Name(), fld.Name(),
+			typ.IDField().Name(), typ.Name())
+	} else {
+		var err error
+		ref, _, err = mrw.rewriteNode(typ, obj)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Dgraph keeps the reverse edges of a predicate itself.
+	if inv := fld.Inverse(); inv != nil && !isReverse(inv) {
+		ref[inv.DgraphPredicate()] = map[string]interface{}{"uid": srcUID}
+	}
+
+	return ref, nil
+}
+
+// rewriteRemove builds the JSON that deletes the values in patch from the
+// node uid.  References must be by ID, and the inverse edges are removed too.
+func rewriteRemove(typ schema.Type, uid string,
+	patch map[string]interface{}) ([]interface{}, error) {
+
+	node := map[string]interface{}{"uid": uid}
+	dels := []interface{}{node}
+	for _, name := range sortedKeys(patch) {
+		val := patch[name]
+		fld := typ.Field(name)
+		if fld == nil {
+			return nil, errors.Errorf("%s is not a field of type %s", name, typ.Name())
+		}
+
+		if !isReference(fld) {
+			stored, err := storedValue(fld, val)
+			if err != nil {
+				return nil, err
+			}
+			node[fld.DgraphPredicate()] = stored
+			continue
+		}
+
+		// Removing an edge removes its facets too.
+		if edgeOf := fld.EdgeOf(); edgeOf != nil {
+			fld, val = edgeOf, edgeNodes(val)
+		}
+		refs, _ := node[fld.DgraphPredicate()].([]interface{})
+		for _, r := range asList(val) {
+			obj, _ := r.(map[string]interface{})
+			id, ok := referenceID(fld.Type(), obj)
+			if !ok {
+				return nil, errors.Errorf("removing a reference from field %s requires "+
+					"the ID of the referenced object", fld.Name())
+			}
+			refUID, err := convertIDs([]interface{}{id})
+			if err != nil {
+				return nil, err
+			}
+
+			ref := fmt.Sprintf("%#x", refUID[0])
+			refs = append(refs, map[string]interface{}{"uid": ref})
+			if inv := fld.Inverse(); inv != nil && !isReverse(inv) {
+				dels = append(dels, map[string]interface{}{
+					"uid":                 ref,
+					inv.DgraphPredicate(): map[string]interface{}{"uid": uid},
+				})
+			}
+		}
+		// Reverse edges are removed by removing the inverse's edges.
+		if !isReverse(fld) {
+			node[fld.DgraphPredicate()] = refs
+		}
+	}
+
+	return dels, nil
+}
+
+// isReference returns true if fld links to another object, rather than
+// storing a scalar or enum value.  Only object types have fields.
+func isReference(fld schema.FieldDefinition) bool {
+	return len(fld.Type().Fields()) > 0
+}
+
+// referenceID returns the ID in a reference to an object of type typ, if the
+// reference has one.
+func referenceID(typ schema.Type, obj map[string]interface{}) (interface{}, bool) {
+	idField := typ.IDField()
+	if idField == nil || obj == nil {
+		return nil, false
+	}
+	id, ok := obj[idField.Name()]
+	return id, ok && id != nil
+}
+
+// inverseFields returns the fields of typ that have an inverse that's stored
+// in its own edges, rather than being the reverse of the field's.
+func inverseFields(typ schema.Type) []schema.FieldDefinition {
+	var result []schema.FieldDefinition
+	for _, fld := range typ.Fields() {
+		if inv := fld.Inverse(); inv != nil && !isReverse(inv) {
+			result = append(result, fld)
+		}
+	}
+	return result
+}
+
+// isReverse returns true if fld is read from the reverse edges of its
+// inverse's predicate, so it's written by writing its inverse.
+func isReverse(fld schema.FieldDefinition) bool {
+	return strings.HasPrefix(fld.DgraphPredicate(), "~")
+}
+
+// sortedKeys returns the keys of m in order, so that blank nodes are always
+// numbered in the same way for the same input.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func asList(val interface{}) []interface{} {
+	switch v := val.(type) {
+	case []interface{}:
+		return v
+	case nil:
+		return nil
+	default:
+		return []interface{}{v}
+	}
+}
@@ -0,0 +1,336 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `
+type Author {
+	id: ID!
+	name: String! @search(by: [hash, term])
+	dob: DateTime
+	posts: [Post] @hasInverse(field: author)
+}
+
+type Post {
+	postID: ID!
+	title: String! @search(by: [term, trigram, fulltext])
+	isPublished: Boolean @search
+	numLikes: Int @search
+	author: Author!
+}
+`
+
+// mockDgraph records the queries and mutations it's asked to run and answers
+// queries, in order, from its results.
+type mockDgraph struct {
+	queries   []string
+	mutations []*api.Mutation
+	results   []string
+	assigned  map[string]string
+	mutateErr error
+	committed bool
+}
+
+func (m *mockDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	m.queries = append(m.queries, dgraph.AsString(query))
+	if len(m.results) == 0 {
+		return []byte(`{}`), nil
+	}
+	res := m.results[0]
+	m.results = m.results[1:]
+	return []byte(res), nil
+}
+
+func (m *mockDgraph) NewTxn() dgraph.Txn {
+	return m
+}
+
+func (m *mockDgraph) Alter(ctx context.Context, schema string) error {
+	return nil
+}
+
+func (m *mockDgraph) Schema(ctx context.Context, preds []string) ([]*api.SchemaNode, error) {
+	return nil, nil
+}
+
+func (m *mockDgraph) DescribeSchema(ctx context.Context) (*dgraph.FullSchema, error) {
+	return &dgraph.FullSchema{}, nil
+}
+
+func (m *mockDgraph) Mutate(ctx context.Context, mut *api.Mutation) (map[string]string, error) {
+	m.mutations = append(m.mutations, mut)
+	return m.assigned, m.mutateErr
+}
+
+func (m *mockDgraph) Commit(ctx context.Context) error {
+	m.committed = true
+	return nil
+}
+
+func (m *mockDgraph) Discard(ctx context.Context) error {
+	return nil
+}
+
+func TestAddMutation(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Author1": "0x1", "Post2": "0x2"},
+		results: []string{`{"author": [{"id": "0x1", "name": "A.N. Author",
+			"posts": [{"title": "New Post"}, {"title": "Old Post"}]}]}`},
+	}
+
+	res := resolveMutationWith(t, testSchema, client, &schema.Request{
+		Query: `mutation addAuthor($auth: AddAuthorInput!) {
+			addAuthor(input: [$auth]) {
+				author {
+					id
+					name
+					posts { title }
+				}
+			}
+		}`,
+		Variables: map[string]interface{}{
+			"auth": map[string]interface{}{
+				"name": "A.N. Author",
+				"posts": []interface{}{
+					map[string]interface{}{"title": "New Post"},
+					map[string]interface{}{"postID": "0x5"},
+				},
+			},
+		},
+	})
+
+	require.NoError(t, res.err)
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `[{
+		"uid": "_:Author1",
+		"dgraph.type": "Author",
+		"Author.name": "A.N. Author",
+		"Author.posts": [
+			{"uid": "_:Post2", "dgraph.type": "Post", "Post.title": "New Post",
+				"Post.author": {"uid": "_:Author1"}},
+			{"uid": "0x5", "Post.author": {"uid": "_:Author1"}}
+		]
+	}]`, string(client.mutations[0].SetJson))
+
+	require.Equal(t, []string{`query {
+  author(func: uid(0x1)) {
+    id : uid
+    name : Author.name
+    posts : Author.posts {
+      title : Post.title
+    }
+  }
+}`}, client.queries)
+
+	require.True(t, client.committed)
+	require.JSONEq(t, `{"addAuthor": {"author": [{"id": "0x1", "name": "A.N. Author",
+		"posts": [{"title": "New Post"}, {"title": "Old Post"}]}]}}`,
+		"{"+string(res.data)+"}")
+}
+
+func TestAddMutationError(t *testing.T) {
+	client := &mockDgraph{mutateErr: errors.New("Dgraph is broken")}
+
+	res := resolveMutationFor(t, testSchema, client, `mutation {
+		addPost(input: [{title: "T", author: {id: "0x1"}}]) {
+			post { title }
+		}
+	}`)
+
+	errs := schema.AsGQLErrors(res.err)
+	require.Len(t, errs, 1)
+	require.Equal(t, "Dgraph is broken", errs[0].Message)
+	require.Equal(t, 2, errs[0].Locations[0].Line)
+	require.Equal(t, `"addPost": null`, string(res.data))
+	require.False(t, client.committed)
+}
+
+func TestUpdateMutation(t *testing.T) {
+	client := &mockDgraph{
+		results: []string{
+			`{"updatePost": [{"uid": "0x1"}, {"uid": "0x2"}]}`,
+			`{"post": [{"title": "Updated"}, {"title": "Updated"}]}`,
+		},
+	}
+
+	res := resolveMutationFor(t, testSchema, client, `mutation {
+		updatePost(input: {
+			filter: { title: { anyofterms: "GraphQL" }, isPublished: true },
+			set: { title: "Updated" },
+			remove: { author: { id: "0x9" } }
+		}) {
+			post { title }
+		}
+	}`)
+
+	require.NoError(t, res.err)
+	require.Equal(t, `query {
+  updatePost(func: type(Post)) @filter((eq(Post.isPublished, "true") AND anyofterms(Post.title, "GraphQL"))) {
+    uid
+  }
+}`, client.queries[0])
+
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `[
+		{"uid": "0x1", "Post.title": "Updated"},
+		{"uid": "0x2", "Post.title": "Updated"}
+	]`, string(client.mutations[0].SetJson))
+	require.JSONEq(t, `[
+		{"uid": "0x1", "Post.author": [{"uid": "0x9"}]},
+		{"uid": "0x9", "Author.posts": {"uid": "0x1"}},
+		{"uid": "0x2", "Post.author": [{"uid": "0x9"}]},
+		{"uid": "0x9", "Author.posts": {"uid": "0x2"}}
+	]`, string(client.mutations[0].DeleteJson))
+
+	require.Equal(t, `query {
+  post(func: uid(0x1, 0x2)) {
+    title : Post.title
+  }
+}`, client.queries[1])
+
+	require.True(t, client.committed)
+	require.Equal(t,
+		`"updatePost": {"post": [{"title": "Updated"}, {"title": "Updated"}]}`,
+		string(res.data))
+}
+
+func TestUpdateMutationNoMatches(t *testing.T) {
+	client := &mockDgraph{results: []string{`{"updatePost": []}`}}
+
+	res := resolveMutationFor(t, testSchema, client, `mutation {
+		updatePost(input: { filter: { postID: ["0x1"] }, set: { title: "Updated" } }) {
+			post { title }
+		}
+	}`)
+
+	require.NoError(t, res.err)
+	require.Len(t, client.queries, 1)
+	require.Empty(t, client.mutations)
+	require.Equal(t, `"updatePost": {"post": []}`, string(res.data))
+}
+
+func TestDeleteMutation(t *testing.T) {
+	client := &mockDgraph{
+		results: []string{`{"deleteAuthor": [
+			{"uid": "0x1", "Author.posts": [{"uid": "0x2"}, {"uid": "0x3"}]}]}`},
+	}
+
+	res := resolveMutationFor(t, testSchema, client, `mutation {
+		deleteAuthor(filter: { name: { eq: "A.N. Author" }, not: { id: ["0x4"] } }) {
+			msg
+		}
+	}`)
+
+	require.NoError(t, res.err)
+	require.Equal(t, []string{`query {
+  deleteAuthor(func: type(Author)) @filter((eq(Author.name, "A.N. Author") AND NOT (uid(0x4)))) {
+    uid
+    Author.posts {
+      uid
+    }
+  }
+}`}, client.queries)
+
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `[
+		{"uid": "0x1"},
+		{"uid": "0x2", "Post.author": {"uid": "0x1"}},
+		{"uid": "0x3", "Post.author": {"uid": "0x1"}}
+	]`, string(client.mutations[0].DeleteJson))
+
+	require.True(t, client.committed)
+	require.Equal(t, `"deleteAuthor": {"msg": "Deleted"}`, string(res.data))
+}
+
+func TestPrivateFields(t *testing.T) {
+	handler, err := schema.NewHandler(`
+		interface Account { id: ID! username: String! passwordHash: String! @private }
+		type User implements Account {
+			id: ID!
+			username: String!
+			passwordHash: String! @private
+			audit: String @private(writable: false)
+		}`)
+	require.NoError(t, err)
+	sch := handler.Schema()
+
+	op, err := sch.Operation(&schema.Request{Query: `mutation {
+		addUser(input: [{ username: "alice", passwordHash: "xyz" }]) { user { username } }
+	}`})
+	require.NoError(t, err)
+	client := &mockDgraph{
+		assigned: map[string]string{"User1": "0x1"},
+		results:  []string{`{"user": [{"username": "alice"}]}`},
+	}
+	mr := &mutationResolver{mutation: op.Mutations()[0], dgraphClient: client}
+	require.NoError(t, mr.resolve(context.Background()).err)
+	require.JSONEq(t, `[{"uid": "_:User1", "dgraph.type": ["User", "Account"],
+		"Account.username": "alice", "Account.passwordHash": "xyz"}]`,
+		string(client.mutations[0].SetJson))
+
+	for _, query := range []string{
+		`query { getUser(id: "0x1") { passwordHash } }`,
+		`query { queryUser(filter: { passwordHash: { eq: "xyz" } }) { username } }`,
+		`mutation { addUser(input: [{ username: "bob", passwordHash: "x", audit: "y" }]) {
+			user { username } } }`,
+	} {
+		_, err := sch.Operation(&schema.Request{Query: query})
+		require.Error(t, err, query)
+	}
+}
+
+func TestReadOnlyReferences(t *testing.T) {
+	sch := `
+		type City {
+			id: ID!
+			name: String!
+			country: Country
+		}
+		type Country @generate(mutation: {add: false, update: false, delete: false}) {
+			id: ID!
+			name: String!
+		}`
+
+	client := &mockDgraph{
+		assigned: map[string]string{"City1": "0x1"},
+		results:  []string{`{"city": [{"name": "X"}]}`},
+	}
+	res := resolveMutationFor(t, sch, client, `mutation {
+		addCity(input: [{ name: "X", country: { id: "0x2" } }]) { city { name } }
+	}`)
+	require.NoError(t, res.err)
+	require.JSONEq(t, `[{"uid": "_:City1", "dgraph.type": "City", "City.name": "X",
+		"City.country": {"uid": "0x2"}}]`, string(client.mutations[0].SetJson))
+
+	// A Country can't be created, so a reference to one needs its id.
+	client = &mockDgraph{}
+	resp := resolveRequest(t, sch, client,
+		`mutation { addCity(input: [{ name: "X", country: {} }]) { city { name } } }`)
+	require.NotEmpty(t, resp.Errors)
+	require.Empty(t, client.mutations)
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+)
+
+// A cascade is the nodes of a type that deleting other nodes cascades to, by
+// @onDelete(action: CASCADE).
+type cascade struct {
+	typ  schema.Type
+	uids []string
+}
+
+// deleteNodes builds the deletions of nodes, which are nodes of type typ
+// found by deleteQuery, and of all the nodes that deleting them cascades to.
+// Each cascade is found by another query in txn, which only finds the nodes
+// that the delete rule of their type allows deleting.
+func deleteNodes(ctx context.Context, txn dgraph.Txn, typ schema.Type,
+	nodes []map[string]interface{}, auth *authorizer) ([]interface{}, error) {
+
+	var dels []interface{}
+	deleted := make(map[string]bool)
+	var pending []*cascade
+	for {
+		nodeDels, cascades, err := rewriteDelete(typ, nodes, deleted)
+		if err != nil {
+			return nil, err
+		}
+		dels = append(dels, nodeDels...)
+		pending = append(pending, cascades...)
+
+		if len(pending) == 0 {
+			return dels, nil
+		}
+		next := pending[0]
+		pending = pending[1:]
+		typ = next.typ
+		if nodes, err = cascadeNodes(ctx, txn, next, deleted, auth); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// cascadeNodes queries txn for the nodes of c that aren't already deleted,
+// with the edges that deleting them needs.
+func cascadeNodes(ctx context.Context, txn dgraph.Txn, c *cascade, deleted map[string]bool,
+	auth *authorizer) ([]map[string]interface{}, error) {
+
+	var ids []interface{}
+	for _, uid := range c.uids {
+		if !deleted[uid] {
+			ids = append(ids, uid)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	uids, err := convertIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	authFilter, err := auth.filter(c.typ, schema.AuthDelete)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := queryNodes(ctx, txn, &gql.GraphQuery{
+		Attr:     "cascade",
+		Func:     &gql.Function{Name: "uid", UID: uids},
+		Filter:   combine("and", typeFilter(c.typ), authFilter),
+		Children: append([]*gql.GraphQuery{{Attr: "uid"}}, deleteChildren(c.typ)...),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != len(uids) {
+		return nil, &UnauthorizedError{Action: "delete", Type: c.typ.Name()}
+	}
+	return nodes, nil
+}
+
+// rewriteReferrers acts on the nodes that link to the node uid, of type typ,
+// by @onDelete fields.  The node is the result of a query with the edges from
+// deleteChildren.  It returns the deletions of the edges of DISCONNECT
+// fields, and the nodes to CASCADE to, or an error if a RESTRICT field still
+// links to the node.
+func rewriteReferrers(typ schema.Type, uid string, node map[string]interface{},
+	deleted map[string]bool) ([]interface{}, []*cascade, error) {
+
+	var dels []interface{}
+	var cascades []*cascade
+	for _, fld := range typ.ReferencedBy() {
+		var referrers []string
+		for _, r := range asList(node[referrersAlias(fld)]) {
+			if ref, ok := r.(map[string]interface{}); ok {
+				if refUID, ok := ref["uid"].(string); ok && !deleted[refUID] {
+					referrers = append(referrers, refUID)
+				}
+			}
+		}
+		if len(referrers) == 0 {
+			continue
+		}
+
+		switch fld.OnDelete() {
+		case schema.Restrict:
+			return nil, nil, errors.Errorf(
+				"can't delete %s %s, because %s %s still links to it by field %s",
+				typ.Name(), uid, fld.ParentType().Name(), referrers[0], fld.Name())
+		case schema.Disconnect:
+			for _, refUID := range referrers {
+				dels = append(dels, map[string]interface{}{
+					"uid":                 refUID,
+					fld.DgraphPredicate(): map[string]interface{}{"uid": uid},
+				})
+			}
+		case schema.Cascade:
+			cascades = append(cascades, &cascade{typ: fld.ParentType(), uids: referrers})
+		}
+	}
+	return dels, cascades, nil
+}
+
+// referrersAlias is the alias of the edge that finds the nodes linking to a
+// node by the @onDelete field fld.
+func referrersAlias(fld schema.FieldDefinition) string {
+	return "~" + fld.ParentType().Name() + "." + fld.Name()
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const onDeleteSchema = `
+type Author {
+	id: ID!
+	name: String!
+}
+
+type Post {
+	id: ID!
+	title: String!
+	author: Author! @onDelete(action: CASCADE)
+	editor: Author @onDelete(action: DISCONNECT)
+	reviewer: Author @onDelete(action: RESTRICT)
+}
+
+type Comment {
+	id: ID!
+	text: String!
+	post: Post! @onDelete(action: CASCADE)
+}
+`
+
+func TestOnDelete(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"deleteAuthor": [{
+			"uid": "0x1",
+			"~Post.author": [{"uid": "0x2"}, {"uid": "0x3"}],
+			"~Post.editor": [{"uid": "0x4"}]
+		}]}`,
+		`{"cascade": [{"uid": "0x2", "~Comment.post": [{"uid": "0x5"}]}, {"uid": "0x3"}]}`,
+		`{"cascade": [{"uid": "0x5"}]}`,
+	}}
+	res := resolveMutationFor(t, onDeleteSchema, client, `mutation {
+		deleteAuthor(filter: { id: ["0x1"] }) { msg }
+	}`)
+	require.NoError(t, res.err)
+
+	require.Equal(t, []string{`query {
+  deleteAuthor(func: type(Author)) @filter(uid(0x1)) {
+    uid
+    ~Post.author : ~Post.author @filter(type(Post)) {
+      uid
+    }
+    ~Post.editor : ~Post.editor @filter(type(Post)) {
+      uid
+    }
+    ~Post.reviewer : ~Post.reviewer @filter(type(Post)) {
+      uid
+    }
+  }
+}`, `query {
+  cascade(func: uid(0x2, 0x3)) @filter(type(Post)) {
+    uid
+    ~Comment.post : ~Comment.post @filter(type(Comment)) {
+      uid
+    }
+  }
+}`, `query {
+  cascade(func: uid(0x5)) @filter(type(Comment)) {
+    uid
+  }
+}`}, client.queries)
+
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `[
+		{"uid": "0x1"},
+		{"uid": "0x4", "Post.editor": {"uid": "0x1"}},
+		{"uid": "0x2"},
+		{"uid": "0x3"},
+		{"uid": "0x5"}
+	]`, string(client.mutations[0].DeleteJson))
+	require.True(t, client.committed)
+}
+
+func TestOnDeleteRestrict(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"deleteAuthor": [{"uid": "0x1", "~Post.reviewer": [{"uid": "0x2"}]}]}`,
+	}}
+	res := resolveMutationFor(t, onDeleteSchema, client, `mutation {
+		deleteAuthor(filter: { id: ["0x1"] }) { msg }
+	}`)
+
+	require.Error(t, res.err)
+	require.Contains(t, res.err.Error(),
+		"can't delete Author 0x1, because Post 0x2 still links to it by field reviewer")
+	require.Empty(t, client.mutations)
+	require.False(t, client.committed)
+}
+
+func TestOnDeleteCascadeNotAuthorized(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"deletePost": [{"uid": "0x2", "~Comment.post": [{"uid": "0x5"}, {"uid": "0x6"}]}]}`,
+		`{"cascade": [{"uid": "0x5"}]}`,
+	}}
+	res := resolveMutationFor(t, onDeleteSchema, client, `mutation {
+		deletePost(filter: { id: ["0x2"] }) { msg }
+	}`)
+
+	require.Error(t, res.err)
+	require.Contains(t, res.err.Error(), "Not authorized to delete Comment")
+	require.Empty(t, client.mutations)
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+	"github.com/vektah/gqlparser/parser"
+)
+
+// maxAutomaticQueries limits how many queries clients can persist; once
+// there are that many, the oldest is forgotten.  Clients just send the full
+// query again when theirs has been forgotten.
+const maxAutomaticQueries = 10000
+
+// The error codes, in the error's extensions, that clients of persisted
+// queries look for.
+const (
+	persistedQueryNotFound   = "PERSISTED_QUERY_NOT_FOUND"
+	persistedQueryNotAllowed = "PERSISTED_QUERY_NOT_ALLOWED"
+)
+
+// PersistedQueries are the query documents that requests can send by their
+// SHA-256 hash, in the persistedQuery extension, instead of in full.  That's
+// Apollo's automatic persisted queries: a client sends just the hash, and if
+// it's not known, sends the hash and the query, which persists it.
+//
+// Queries can also be registered, as the admin API does.  If only registered
+// queries are allowed, then nothing else is run and clients can't persist
+// queries of their own.  A registered query can also be run by the name of
+// one of its operations, sent as the request's operationName without a
+// query.
+type PersistedQueries struct {
+	mu             sync.RWMutex
+	registered     map[string]string
+	names          map[string]string
+	automatic      map[string]string
+	automaticOrder []string
+	registeredOnly bool
+}
+
+// A PersistedQuery is a query document and its hash.
+type PersistedQuery struct {
+	Hash  string
+	Query string
+}
+
+// NewPersistedQueries returns an empty PersistedQueries that allows any
+// query.
+func NewPersistedQueries() *PersistedQueries {
+	return &PersistedQueries{
+		registered: make(map[string]string),
+		names:      make(map[string]string),
+		automatic:  make(map[string]string),
+	}
+}
+
+// OperationNames returns the names of the named operations in query, or nil
+// if it isn't a GraphQL document.
+func OperationNames(query string) []string {
+	doc, gqlErr := parser.ParseQuery(&ast.Source{Input: query})
+	if gqlErr != nil {
+		return nil
+	}
+	var names []string
+	for _, op := range doc.Operations {
+		if op.Name != "" {
+			names = append(names, op.Name)
+		}
+	}
+	return names
+}
+
+// QueryHash returns the hash that query is persisted by: its SHA-256 hash,
+// in hex.
+func QueryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetRegisteredOnly sets whether only registered queries are allowed.
+func (pq *PersistedQueries) SetRegisteredOnly(only bool) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	pq.registeredOnly = only
+}
+
+// SetRegistered makes queries the registered queries, replacing any that
+// were registered before.  An operation name that's in more than one of the
+// queries doesn't pick either, so those queries can only be run by hash.
+func (pq *PersistedQueries) SetRegistered(queries []string) {
+	registered := make(map[string]string, len(queries))
+	names := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	for _, query := range queries {
+		registered[QueryHash(query)] = query
+		for _, name := range OperationNames(query) {
+			if other, ok := names[name]; ok && other != query {
+				ambiguous[name] = true
+			}
+			names[name] = query
+		}
+	}
+	for name := range ambiguous {
+		delete(names, name)
+	}
+
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	pq.registered = registered
+	pq.names = names
+}
+
+// Registered returns the registered queries, ordered by hash.
+func (pq *PersistedQueries) Registered() []PersistedQuery {
+	pq.mu.RLock()
+	defer pq.mu.RUnlock()
+
+	res := make([]PersistedQuery, 0, len(pq.registered))
+	for hash, query := range pq.registered {
+		res = append(res, PersistedQuery{Hash: hash, Query: query})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Hash < res[j].Hash })
+	return res
+}
+
+// expand returns gqlReq with the query it sends by hash, or by the name of a
+// registered operation, filled in.  It's an error if the hash or name isn't
+// known, or if the hash doesn't match the query sent with it; the request is
+// then nil.  It's also an error if the query isn't allowed, but then the
+// request is returned with the error, so that it can be let through if it's
+// only introspection.
+func (pq *PersistedQueries) expand(gqlReq *schema.Request) (*schema.Request, error) {
+	ext, _ := gqlReq.Extensions["persistedQuery"].(map[string]interface{})
+	if ext == nil && gqlReq.Query == "" && gqlReq.OperationName != "" {
+		query, ok := pq.lookupName(gqlReq.OperationName)
+		if !ok {
+			return nil, &gqlerror.Error{
+				Message: fmt.Sprintf("There's no registered operation named %s",
+					gqlReq.OperationName),
+				Extensions: map[string]interface{}{"code": persistedQueryNotFound},
+			}
+		}
+		expanded := *gqlReq
+		expanded.Query = query
+		return &expanded, nil
+	}
+	if ext == nil {
+		if gqlReq.Query != "" && !pq.allowed(QueryHash(gqlReq.Query)) {
+			return gqlReq, notAllowed()
+		}
+		return gqlReq, nil
+	}
+
+	if version, ok := ext["version"]; ok && fmt.Sprint(version) != "1" {
+		return nil, &gqlerror.Error{
+			Message: fmt.Sprintf("Unsupported persisted query version %v", version)}
+	}
+	hash, _ := ext["sha256Hash"].(string)
+	if hash == "" {
+		return nil, &gqlerror.Error{Message: "The persistedQuery extension needs a sha256Hash"}
+	}
+
+	if gqlReq.Query == "" {
+		query, ok := pq.lookup(hash)
+		if !ok {
+			return nil, &gqlerror.Error{
+				Message:    "PersistedQueryNotFound",
+				Extensions: map[string]interface{}{"code": persistedQueryNotFound},
+			}
+		}
+		expanded := *gqlReq
+		expanded.Query = query
+		return &expanded, nil
+	}
+
+	if QueryHash(gqlReq.Query) != hash {
+		return nil, &gqlerror.Error{Message: "provided sha does not match query"}
+	}
+	if !pq.allowed(hash) {
+		return gqlReq, notAllowed()
+	}
+	pq.persist(hash, gqlReq.Query)
+	return gqlReq, nil
+}
+
+func notAllowed() error {
+	return &gqlerror.Error{
+		Message:    "Only registered queries can be run on this server",
+		Extensions: map[string]interface{}{"code": persistedQueryNotAllowed},
+	}
+}
+
+func (pq *PersistedQueries) allowed(hash string) bool {
+	pq.mu.RLock()
+	defer pq.mu.RUnlock()
+	_, registered := pq.registered[hash]
+	return registered || !pq.registeredOnly
+}
+
+func (pq *PersistedQueries) lookup(hash string) (string, bool) {
+	pq.mu.RLock()
+	defer pq.mu.RUnlock()
+	if query, ok := pq.registered[hash]; ok {
+		return query, true
+	}
+	if pq.registeredOnly {
+		return "", false
+	}
+	query, ok := pq.automatic[hash]
+	return query, ok
+}
+
+func (pq *PersistedQueries) lookupName(name string) (string, bool) {
+	pq.mu.RLock()
+	defer pq.mu.RUnlock()
+	query, ok := pq.names[name]
+	return query, ok
+}
+
+// persist remembers query, a query that a client sent with its hash, unless
+// it's already known.
+func (pq *PersistedQueries) persist(hash, query string) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if _, ok := pq.registered[hash]; ok || pq.registeredOnly {
+		return
+	}
+	if _, ok := pq.automatic[hash]; ok {
+		return
+	}
+
+	if len(pq.automaticOrder) >= maxAutomaticQueries {
+		delete(pq.automatic, pq.automaticOrder[0])
+		pq.automaticOrder = pq.automaticOrder[1:]
+	}
+	pq.automatic[hash] = query
+	pq.automaticOrder = append(pq.automaticOrder, hash)
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistedQueries(t *testing.T) {
+	client := &mockDgraph{}
+	resolver := resolverFor(t, testSchema, client)
+
+	query := `query { getAuthor(id: "0x1") { name } }`
+	byHash := func(hash string) map[string]interface{} {
+		return map[string]interface{}{"persistedQuery": map[string]interface{}{
+			"version": 1, "sha256Hash": hash}}
+	}
+	resolveWith := func(query, hash string) *schema.Response {
+		return resolver.Resolve(context.Background(),
+			&schema.Request{Query: query, Extensions: byHash(hash)})
+	}
+
+	// The hash isn't known until the client sends the query with it.
+	resp := resolveWith("", QueryHash(query))
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "PersistedQueryNotFound", resp.Errors[0].Message)
+	require.Equal(t, persistedQueryNotFound, resp.Errors[0].Extensions["code"])
+
+	resp = resolveWith(query, "not-the-hash")
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "provided sha does not match query", resp.Errors[0].Message)
+
+	require.Empty(t, resolveWith(query, QueryHash(query)).Errors)
+	require.Empty(t, resolveWith("", QueryHash(query)).Errors)
+	require.Len(t, client.queries, 2)
+
+	// Only registered queries run, and clients can't persist others.
+	registered := `query { queryAuthor { name } }`
+	resolver.WithRegisteredQueriesOnly(true).PersistedQueries().SetRegistered(
+		[]string{registered})
+
+	resp = resolveWith("", QueryHash(query))
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, persistedQueryNotFound, resp.Errors[0].Extensions["code"])
+
+	resp = resolver.Resolve(context.Background(), &schema.Request{Query: query})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "Only registered queries can be run on this server",
+		resp.Errors[0].Message)
+	require.Equal(t, persistedQueryNotAllowed, resp.Errors[0].Extensions["code"])
+
+	require.Empty(t, resolveWith("", QueryHash(registered)).Errors)
+	require.Empty(t, resolver.Resolve(context.Background(),
+		&schema.Request{Query: registered}).Errors)
+}
+
+func TestRegisteredQueriesByName(t *testing.T) {
+	client := &mockDgraph{}
+	resolver := resolverFor(t, testSchema, client).WithRegisteredQueriesOnly(true)
+	resolver.PersistedQueries().SetRegistered([]string{
+		`query Authors { queryAuthor { name } } query Author { getAuthor(id: "0x1") { name } }`,
+		`query Both { queryAuthor { name } }`,
+		`query Both { queryPost { title } }`,
+	})
+	byName := func(name string) *schema.Response {
+		return resolver.Resolve(context.Background(), &schema.Request{OperationName: name})
+	}
+
+	require.Empty(t, byName("Author").Errors)
+	require.Len(t, client.queries, 1)
+	require.Contains(t, client.queries[0], "getAuthor")
+
+	resp := byName("Unknown")
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "There's no registered operation named Unknown", resp.Errors[0].Message)
+	require.Equal(t, persistedQueryNotFound, resp.Errors[0].Extensions["code"])
+
+	// A name in more than one registered query doesn't pick either.
+	resp = byName("Both")
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, persistedQueryNotFound, resp.Errors[0].Extensions["code"])
+}
+
+func TestAllowlistIntrospection(t *testing.T) {
+	resolver := resolverFor(t, testSchema, &mockDgraph{}).WithRegisteredQueriesOnly(true)
+	resolve := func(query string) *schema.Response {
+		return resolver.Resolve(context.Background(), &schema.Request{Query: query})
+	}
+	introspection := `query { __schema { queryType { name } } }`
+
+	resp := resolve(introspection)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, persistedQueryNotAllowed, resp.Errors[0].Extensions["code"])
+
+	resolver.WithIntrospection(true)
+	resp = resolve(introspection)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"__schema": {"queryType": {"name": "Query"}}}`, resp.Data.String())
+
+	// Anything else still has to be registered.
+	for _, query := range []string{
+		`query { queryAuthor { name } }`,
+		`query { __schema { queryType { name } } queryAuthor { name } }`,
+		`query { notAField }`,
+	} {
+		resp = resolve(query)
+		require.Len(t, resp.Errors, 1, query)
+		require.Equal(t, persistedQueryNotAllowed, resp.Errors[0].Extensions["code"], query)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/graphql/tracing"
+	"github.com/golang/glog"
+)
+
+// queryResolver can resolve a single GraphQL query field.
+type queryResolver struct {
+	query        schema.Query
+	dgraphClient dgraph.Client
+	remoteClient *external.Client
+	secrets      secrets.Provider
+	lambdaURL    string
+	lambdaClient *external.Client
+	signed       map[string]*external.Client
+}
+
+func (qr *queryResolver) resolve(ctx context.Context) *resolved {
+	if glog.V(3) {
+		glog.Infof("Resolving query %s", qr.query.Name())
+	}
+
+	if qr.query.QueryType() == schema.SchemaQuery {
+		return resolveIntrospection(qr.query)
+	}
+	if qr.query.QueryType() == schema.RemoteQuery {
+		return resolveRemote(ctx, qr.remoteClient, "query", qr.query, qr.query.Remote())
+	}
+	custom := &customResolver{client: qr.remoteClient, secrets: qr.secrets,
+		lambdaURL: qr.lambdaURL, lambdaClient: qr.lambdaClient, signed: qr.signed}
+	switch qr.query.QueryType() {
+	case schema.CustomQuery:
+		return custom.resolveRoot(ctx, qr.query)
+	case schema.LambdaQuery:
+		return resolveWith(ctx, qr.query, custom.resolveLambda)
+	case schema.EntitiesQuery:
+		return qr.resolveEntities(ctx, custom)
+	case schema.ServiceQuery:
+		return resolveWith(ctx, qr.query, qr.resolveService)
+	case schema.SimilarQuery:
+		return qr.resolveSimilar(ctx, custom)
+	}
+
+	// A query whose request was abandoned, or ran out of time, isn't
+	// rewritten or sent to Dgraph.
+	if err := ctx.Err(); err != nil {
+		null, _ := completeField(qr.query, nil)
+		return &resolved{data: null, err: fieldErrors(qr.query, err)}
+	}
+
+	_, end := tracing.StartPhase(ctx, tracing.Rewrite)
+	auth := newAuthorizer(ctx)
+	dgQuery, err := rewriteCached(qr.query, auth)
+	end()
+	if err != nil {
+		null, _ := completeField(qr.query, nil)
+		return &resolved{data: null, err: fieldErrors(qr.query, err)}
+	}
+
+	resp, err := qr.dgraphClient.Query(ctx, dgQuery)
+	if err != nil {
+		glog.Infof("Dgraph query failed: %v", err)
+		null, _ := completeField(qr.query, nil)
+		return &resolved{data: null, err: fieldErrors(qr.query, err)}
+	}
+
+	res, errs := decodeDgraphResult(qr.query, resp)
+	if errs != nil {
+		null, _ := completeField(qr.query, nil)
+		return &resolved{data: null, err: errs}
+	}
+	val := res[qr.query.ResponseName()]
+	auth.denyFields([]interface{}{qr.query.ResponseName()}, qr.query, val)
+	custom.resolveFields(ctx, []interface{}{qr.query.ResponseName()}, qr.query, val)
+
+	_, end = tracing.StartPhase(ctx, tracing.Complete)
+	data, errs := completeField(qr.query, val)
+	end()
+	if len(errs) > 0 {
+		return &resolved{data: data, err: errs}
+	}
+	return &resolved{data: data}
+}
+
+// rewriteCached is rewriteAsQuery, with the rewritten query cached as one of
+// query's plans.  Queries whose operations have variables aren't cached, so
+// they're always rewritten.
+//
+// The rewritten query depends on only those claims that auth's rules and
+// field roles look at, so it's cached under their values alone, not under
+// claims, like exp and iat, that change with each token.  Which claims are
+// looked at is cached too: rewriting is the same for any request with the
+// same values of those claims, so another request that has them gets the
+// same query, and one that doesn't is rewritten again.
+func rewriteCached(query schema.Query, auth *authorizer) (*gql.GraphQuery, error) {
+	if names, ok := query.Plan("dql-claims"); ok {
+		if key, err := claimsKey(names.([]string), auth.claims); err == nil {
+			if plan, ok := query.Plan("dql:" + key); ok {
+				return plan.(*gql.GraphQuery), nil
+			}
+		}
+	}
+
+	auth.read = make(map[string]bool)
+	dgQuery, err := rewriteAsQuery(query, auth)
+	names := make([]string, 0, len(auth.read))
+	for name := range auth.read {
+		names = append(names, name)
+	}
+	auth.read = nil
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	if key, err := claimsKey(names, auth.claims); err == nil {
+		query.SetPlan("dql-claims", names)
+		query.SetPlan("dql:"+key, dgQuery)
+	}
+	return dgQuery, nil
+}
+
+// claimsKey is the key, among a query's plans, for the values in claims of
+// the claims names.  A claim that's missing is keyed apart from any value
+// it could have.
+func claimsKey(names []string, claims map[string]interface{}) (string, error) {
+	type claim struct {
+		Name  string      `json:"name"`
+		Value interface{} `json:"value,omitempty"`
+		Set   bool        `json:"set"`
+	}
+	key := make([]claim, len(names))
+	for i, name := range names {
+		val, ok := claims[name]
+		key[i] = claim{Name: name, Value: val, Set: ok}
+	}
+	b, err := json.Marshal(key)
+	return string(b), err
+}
+
+// resolveService answers a _service query with the SDL of the API.  It's a
+// FieldResolverFunc.
+func (qr *queryResolver) resolveService(ctx context.Context,
+	field schema.Field) (interface{}, error) {
+
+	sdl, err := schema.ServiceSDL(qr.query)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"sdl": sdl}, nil
+}
+
+// resolveIntrospection answers an introspection query from the schema.
+func resolveIntrospection(q schema.Query) *resolved {
+	val, err := schema.Introspect(q)
+	if err != nil {
+		null, _ := completeField(q, nil)
+		return &resolved{data: null, err: fieldErrors(q, err)}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(strconv.Quote(q.ResponseName()))
+	buf.WriteString(": ")
+	buf.Write(val)
+	return &resolved{data: buf.Bytes()}
+}
@@ -0,0 +1,599 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/pkg/errors"
+)
+
+// rewriteAsQuery rewrites a GraphQL query into the Dgraph query that finds
+// the answer, as far as auth allows.
+func rewriteAsQuery(query schema.Query, auth *authorizer) (*gql.GraphQuery, error) {
+	switch query.QueryType() {
+	case schema.GetQuery:
+		xid, byXid, err := getXid(query)
+		if err != nil {
+			return nil, err
+		}
+		if byXid {
+			return rewriteAsGetByXid(query, xid, auth)
+		}
+		uid, err := query.IDArgValue()
+		if err != nil {
+			return nil, err
+		}
+		return rewriteAsGet(query, uid, auth)
+	case schema.FilterQuery:
+		return rewriteAsQueryByType(query, auth)
+	default:
+		return nil, errors.Errorf("query %s is not supported", query.Name())
+	}
+}
+
+// rewriteAsGet builds a query for the single node with the given uid, making
+// sure that node has field's type.
+func rewriteAsGet(field schema.Field, uid uint64, auth *authorizer) (*gql.GraphQuery, error) {
+	return rewriteAsGetAt(field, &gql.Function{Name: "uid", UID: []uint64{uid}}, auth)
+}
+
+// rewriteAsGetAt builds a query for the single node that fn finds, making
+// sure that node has field's type.
+func rewriteAsGetAt(field schema.Field, fn *gql.Function,
+	auth *authorizer) (*gql.GraphQuery, error) {
+
+	authFilter, err := auth.filter(field.Type(), schema.AuthQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	dgQuery := &gql.GraphQuery{
+		Attr:   field.ResponseName(),
+		Func:   fn,
+		Filter: combine("and", typeFilter(field.Type()), authFilter, deletedFilter(field)),
+	}
+	if err := addSelectionSetFrom(dgQuery, field, auth); err != nil {
+		return nil, err
+	}
+	nameVars(dgQuery)
+	return dgQuery, nil
+}
+
+// rewriteAsQueryByIds builds a query for field's selection set, starting at
+// the nodes with the given uids.
+func rewriteAsQueryByIds(field schema.Field, uids []uint64,
+	auth *authorizer) (*gql.GraphQuery, error) {
+
+	authFilter, err := auth.filter(field.Type(), schema.AuthQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	dgQuery := &gql.GraphQuery{
+		Attr:   field.ResponseName(),
+		Func:   &gql.Function{Name: "uid", UID: uids},
+		Filter: combine("and", authFilter, deletedFilter(field)),
+	}
+	if err := addSelectionSetFrom(dgQuery, field, auth); err != nil {
+		return nil, err
+	}
+	nameVars(dgQuery)
+	return dgQuery, nil
+}
+
+// rewriteAsQueryByType builds a query for all the nodes of field's type that
+// satisfy the filter, order and pagination arguments of field.
+func rewriteAsQueryByType(field schema.Field, auth *authorizer) (*gql.GraphQuery, error) {
+	dgQuery := &gql.GraphQuery{
+		Attr: field.ResponseName(),
+		Func: &gql.Function{Name: "type", Args: []gql.Arg{{Value: field.Type().DgraphName()}}},
+	}
+
+	if err := addArguments(dgQuery, field, auth); err != nil {
+		return nil, err
+	}
+	if err := addSelectionSetFrom(dgQuery, field, auth); err != nil {
+		return nil, err
+	}
+	nameVars(dgQuery)
+
+	return dgQuery, nil
+}
+
+// addArguments adds field's filter, order and pagination arguments, the auth
+// rule for querying field's type and the filter that leaves out deleted
+// nodes, to q.  They're the arguments of queryT queries, and of fields that
+// are lists of T.
+func addArguments(q *gql.GraphQuery, field schema.Field, auth *authorizer) error {
+	filter, _ := field.ArgValue(schema.FilterArgName).(map[string]interface{})
+	order, _ := field.ArgValue("order").(map[string]interface{})
+	if err := auth.checkArguments(field.Type(), filter, order); err != nil {
+		return err
+	}
+
+	if filter != nil {
+		ft, err := buildFilter(field.Type(), filter)
+		if err != nil {
+			return err
+		}
+		q.Filter = ft
+	}
+
+	authFilter, err := auth.filter(field.Type(), schema.AuthQuery)
+	if err != nil {
+		return err
+	}
+	q.Filter = combine("and", q.Filter, authFilter, deletedFilter(field))
+
+	if order != nil {
+		addOrder(q, field.Type(), order)
+	}
+	addPagination(q, field)
+	return nil
+}
+
+// rewriteAsFilterQuery builds a query that finds the uids of all the nodes of
+// type typ that satisfy filter and the auth rule for op.  It's the first step
+// of updates and deletes.
+func rewriteAsFilterQuery(name string, typ schema.Type, filter map[string]interface{},
+	auth *authorizer, op schema.AuthOperation) (*gql.GraphQuery, error) {
+
+	if err := auth.checkArguments(typ, filter, nil); err != nil {
+		return nil, err
+	}
+	ft, err := buildFilter(typ, filter)
+	if err != nil {
+		return nil, err
+	}
+	authFilter, err := auth.filter(typ, op)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gql.GraphQuery{
+		Attr:     name,
+		Func:     &gql.Function{Name: "type", Args: []gql.Arg{{Value: typ.DgraphName()}}},
+		Filter:   combine("and", ft, authFilter),
+		Children: []*gql.GraphQuery{{Attr: "uid"}},
+	}, nil
+}
+
+func typeFilter(typ schema.Type) *gql.FilterTree {
+	return &gql.FilterTree{
+		Func: &gql.Function{Name: "type", Args: []gql.Arg{{Value: typ.DgraphName()}}},
+	}
+}
+
+// addSelectionSetFrom adds field's selection set, and its @cascade, to q.
+// Edges to nodes of types with an @auth query rule are filtered by the rule,
+// and by the filter, order and pagination asked for on the edge.  Fields
+// whose @auth roles the request doesn't have are left out.
+// @custom and @lambda fields aren't in Dgraph, but the fields their calls
+// need are added instead.  @computed fields are worked out by Dgraph.
+func addSelectionSetFrom(q *gql.GraphQuery, field schema.Field, auth *authorizer) error {
+	addCascade(q, field)
+
+	if isAbstract(field.Type()) && !hasChild(q, dgraphTypePredicate) {
+		// Which fragments apply depends on the type of each node, so that's
+		// fetched too.
+		q.Children = append(q.Children,
+			&gql.GraphQuery{Alias: dgraphTypePredicate, Attr: dgraphTypePredicate})
+	}
+
+	for _, f := range allSelections(field) {
+		// __typename isn't stored in Dgraph; it's filled in when the result
+		// is completed.
+		if f.Name() == "__typename" {
+			continue
+		}
+		// A field the request can't see isn't fetched at all.
+		if !auth.allows(f.Auth()) {
+			continue
+		}
+		if ch := f.CustomHTTP(); ch != nil {
+			addCustomVariables(q, field.Type(), ch.Variables)
+			continue
+		}
+		if f.Lambda() {
+			addCustomVariables(q, field.Type(), lambdaParentFields(field.Type()))
+			continue
+		}
+		if f.Computed() != nil {
+			addComputed(q, f)
+			continue
+		}
+		if f.Type().IsEdge() {
+			if err := addEdges(q, f, auth); err != nil {
+				return err
+			}
+			continue
+		}
+
+		child := &gql.GraphQuery{Alias: f.ResponseName()}
+		if f.Type().Name() == schema.IDType {
+			child.Attr = "uid"
+		} else {
+			child.Attr = f.DgraphPredicate()
+		}
+
+		// The same field can be asked for in the fragments of more than one
+		// type, possibly with different selections; they're all fetched.
+		if existing := childWithAlias(q, child.Alias); existing != nil &&
+			existing.Attr == child.Attr {
+			if err := addSelectionSetFrom(existing, f, auth); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := addArguments(child, f, auth); err != nil {
+			return err
+		}
+		if err := addSelectionSetFrom(child, f, auth); err != nil {
+			return err
+		}
+		q.Children = append(q.Children, child)
+	}
+	return nil
+}
+
+// dgraphTypePredicate is the Dgraph predicate that holds the types of a node.
+const dgraphTypePredicate = "dgraph.type"
+
+// isAbstract is true if typ is an interface, so the fields of a value of typ
+// depend on which type implementing it the value is.
+func isAbstract(typ schema.Type) bool {
+	possible := typ.PossibleTypes()
+	return len(possible) > 0 && (len(possible) > 1 || possible[0] != typ.Name())
+}
+
+// allSelections returns the fields asked for on field's value, whichever
+// type it turns out to be.  If field's type is an interface, that's the
+// fields asked for on each of the types that implement it, so a field can
+// be in the result more than once.
+func allSelections(field schema.Field) []schema.Field {
+	if !isAbstract(field.Type()) {
+		return field.SelectionSet()
+	}
+	var sel []schema.Field
+	for _, typName := range field.Type().PossibleTypes() {
+		sel = append(sel, field.SelectionSetFor(typName)...)
+	}
+	return sel
+}
+
+func childWithAlias(q *gql.GraphQuery, alias string) *gql.GraphQuery {
+	for _, child := range q.Children {
+		if child.Alias == alias {
+			return child
+		}
+	}
+	return nil
+}
+
+// addCascade adds field's @cascade directive, if it has one, to q.  On its
+// own, it's Dgraph's @cascade, which drops the nodes that are missing any of
+// the predicates asked for, at every level of q.  If it names fields, it's a
+// filter that keeps only the nodes that have all of them.
+func addCascade(q *gql.GraphQuery, field schema.Field) {
+	cascade, names := field.Cascade()
+	if !cascade {
+		return
+	}
+	if len(names) == 0 {
+		q.Cascade = true
+		return
+	}
+
+	var has []*gql.FilterTree
+	for _, name := range names {
+		// Every node has a uid, so the ID never needs checking.
+		fd := field.Type().Field(name)
+		if fd == nil || fd.IsID() || fd.DgraphPredicate() == "" {
+			continue
+		}
+		has = append(has, &gql.FilterTree{
+			Func: &gql.Function{Name: "has", Attr: fd.DgraphPredicate()},
+		})
+	}
+	q.Filter = combine("and", q.Filter, combine("and", has...))
+}
+
+func addOrder(q *gql.GraphQuery, typ schema.Type, order map[string]interface{}) {
+	for order != nil {
+		if asc, ok := order["asc"].(string); ok {
+			q.Order = append(q.Order, &pb.Order{Attr: typ.Field(asc).DgraphPredicate()})
+		}
+		if desc, ok := order["desc"].(string); ok {
+			q.Order = append(q.Order,
+				&pb.Order{Attr: typ.Field(desc).DgraphPredicate(), Desc: true})
+		}
+		order, _ = order["then"].(map[string]interface{})
+	}
+}
+
+func addPagination(q *gql.GraphQuery, field schema.Field) {
+	for _, arg := range []string{"first", "offset"} {
+		if val := field.ArgValue(arg); val != nil {
+			if q.Args == nil {
+				q.Args = make(map[string]string)
+			}
+			q.Args[arg] = fmt.Sprintf("%v", val)
+		}
+	}
+}
+
+// buildFilter builds a Dgraph filter from a GraphQL filter argument.  The
+// conditions on the fields of the filter are and-ed together with the and
+// filter; that's or-ed with the or filter, and any not filter is and-ed with
+// the whole thing.  An empty filter builds a nil filter.
+func buildFilter(typ schema.Type, filter map[string]interface{}) (*gql.FilterTree, error) {
+	var keys []string
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var conds []*gql.FilterTree
+	for _, key := range keys {
+		switch key {
+		case "and", "or", "not":
+			continue
+		case "has":
+			fts, err := buildHasFilter(typ, filter[key])
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, fts...)
+			continue
+		}
+
+		fld := typ.Field(key)
+		if fld == nil {
+			return nil, errors.Errorf("%s is not a field of type %s", key, typ.Name())
+		}
+
+		fts, err := buildFieldFilter(fld, filter[key])
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, fts...)
+	}
+
+	sub := make(map[string]*gql.FilterTree)
+	for _, op := range []string{"and", "or", "not"} {
+		subFilter, ok := filter[op].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ft, err := buildFilter(typ, subFilter)
+		if err != nil {
+			return nil, err
+		}
+		if ft != nil {
+			sub[op] = ft
+		}
+	}
+
+	if ft, ok := sub["and"]; ok {
+		conds = append(conds, ft)
+	}
+	result := combine("and", conds...)
+	if ft, ok := sub["or"]; ok {
+		result = combine("or", result, ft)
+	}
+	if ft, ok := sub["not"]; ok {
+		result = combine("and", result, &gql.FilterTree{Op: "not", Child: []*gql.FilterTree{ft}})
+	}
+
+	return result, nil
+}
+
+// buildHasFilter builds has(pred) for each of the fields named in val, the
+// value of a filter's has.
+func buildHasFilter(typ schema.Type, val interface{}) ([]*gql.FilterTree, error) {
+	names, ok := val.([]interface{})
+	if !ok {
+		names = []interface{}{val}
+	}
+
+	var fts []*gql.FilterTree
+	for _, name := range names {
+		fldName, _ := name.(string)
+		fld := typ.Field(fldName)
+		if fld == nil || fld.DgraphPredicate() == "" {
+			return nil, errors.Errorf("%v is not a field of type %s", name, typ.Name())
+		}
+		fts = append(fts, &gql.FilterTree{
+			Func: &gql.Function{Name: "has", Attr: fld.DgraphPredicate()},
+		})
+	}
+	return fts, nil
+}
+
+// combine joins the non-nil filters in fts with op.
+func combine(op string, fts ...*gql.FilterTree) *gql.FilterTree {
+	var child []*gql.FilterTree
+	for _, ft := range fts {
+		if ft != nil {
+			child = append(child, ft)
+		}
+	}
+
+	switch len(child) {
+	case 0:
+		return nil
+	case 1:
+		return child[0]
+	default:
+		return &gql.FilterTree{Op: op, Child: child}
+	}
+}
+
+// buildFieldFilter builds the filter functions for the condition val on field
+// fld.  For example, {eq: "x", anyofterms: "y z"} on Post.title becomes
+// eq(Post.title, "x") and anyofterms(Post.title, "y z"), and
+// {between: {min: 1, max: 5}} on Post.numLikes becomes ge(Post.numLikes, "1")
+// and le(Post.numLikes, "5").
+func buildFieldFilter(fld schema.FieldDefinition, val interface{}) ([]*gql.FilterTree, error) {
+	if fld.IsID() {
+		ids, ok := val.([]interface{})
+		if !ok {
+			return nil, errors.Errorf("filter on %s must be a list of IDs", fld.Name())
+		}
+		uids, err := convertIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		return []*gql.FilterTree{{Func: &gql.Function{Name: "uid", UID: uids}}}, nil
+	}
+
+	pred := fld.DgraphPredicate()
+	switch v := val.(type) {
+	case map[string]interface{}:
+		var ops []string
+		for op := range v {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+
+		var fts []*gql.FilterTree
+		for _, op := range ops {
+			if op == "between" {
+				// The Dgraph query language has no between, so a range is
+				// the two bounds it includes.
+				rng, _ := v[op].(map[string]interface{})
+				fts = append(fts,
+					&gql.FilterTree{Func: &gql.Function{
+						Name: "ge", Attr: pred, Args: []gql.Arg{{Value: asString(rng["min"])}},
+					}},
+					&gql.FilterTree{Func: &gql.Function{
+						Name: "le", Attr: pred, Args: []gql.Arg{{Value: asString(rng["max"])}},
+					}})
+				continue
+			}
+			if op == "regexp" {
+				if re, _ := v[op].(string); !dgraphRegexp.MatchString(re) {
+					return nil, errors.Errorf("regexp on %s must be a regular expression "+
+						"like /^Dg.*h$/ or /dgraph/i", fld.Name())
+				}
+			}
+			if op == "eqIgnoreCase" {
+				ft, err := buildEqIgnoreCaseFilter(fld, v[op])
+				if err != nil {
+					return nil, err
+				}
+				fts = append(fts, ft)
+				continue
+			}
+			if op == "in" {
+				ft, err := buildInFilter(fld, v[op])
+				if err != nil {
+					return nil, err
+				}
+				fts = append(fts, ft)
+				continue
+			}
+			fts = append(fts, &gql.FilterTree{
+				Func: &gql.Function{
+					Name: op,
+					Attr: pred,
+					Args: []gql.Arg{{Value: asString(v[op])}},
+				},
+			})
+		}
+		return fts, nil
+	case nil:
+		return nil, nil
+	default:
+		// Booleans are filtered on directly, e.g. isPublished: true.
+		return []*gql.FilterTree{{
+			Func: &gql.Function{Name: "eq", Attr: pred, Args: []gql.Arg{{Value: asString(v)}}},
+		}}, nil
+	}
+}
+
+// dgraphRegexp matches the regular expressions that Dgraph's regexp takes:
+// a pattern, without unescaped slashes, between slashes, optionally followed
+// by i to ignore case.  The expression is written into the query as is.
+var dgraphRegexp = regexp.MustCompile(`^/(?:[^/\\\n]|\\.)+/i?$`)
+
+// buildEqIgnoreCaseFilter builds regexp(pred, /^val$/i), which matches the
+// values of fld that are val, ignoring case.
+func buildEqIgnoreCaseFilter(fld schema.FieldDefinition,
+	val interface{}) (*gql.FilterTree, error) {
+
+	re := "/^" + strings.Replace(regexp.QuoteMeta(asString(val)), "/", `\/`, -1) + "$/i"
+	if !dgraphRegexp.MatchString(re) {
+		return nil, errors.Errorf("eqIgnoreCase on %s can't match %q", fld.Name(), val)
+	}
+	return &gql.FilterTree{
+		Func: &gql.Function{Name: "regexp", Attr: fld.DgraphPredicate(),
+			Args: []gql.Arg{{Value: re}}},
+	}, nil
+}
+
+// buildInFilter builds eq(pred, [...]), which matches a value of fld that's
+// any of the values in val, the list given to a filter's in.
+func buildInFilter(fld schema.FieldDefinition, val interface{}) (*gql.FilterTree, error) {
+	vals, ok := val.([]interface{})
+	if !ok {
+		vals = []interface{}{val}
+	}
+	if len(vals) == 0 {
+		return nil, errors.Errorf("in on %s needs at least one value", fld.Name())
+	}
+
+	args := make([]gql.Arg, len(vals))
+	for i, v := range vals {
+		args[i] = gql.Arg{Value: asString(v)}
+	}
+	return &gql.FilterTree{
+		Func: &gql.Function{Name: "eq", Attr: fld.DgraphPredicate(), Args: args},
+	}, nil
+}
+
+func convertIDs(ids []interface{}) ([]uint64, error) {
+	uids := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		str, ok := id.(string)
+		if !ok {
+			return nil, errors.Errorf("ID argument (%v) was not able to be parsed", id)
+		}
+		uid, err := strconv.ParseUint(str, 0, 64)
+		if err != nil || uid == 0 {
+			return nil, errors.Errorf("ID argument (%s) was not able to be parsed", str)
+		}
+		uids = append(uids, uid)
+	}
+	return uids, nil
+}
+
+func asString(val interface{}) string {
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", val)
+}
@@ -0,0 +1,612 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryRewriting(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		vars     map[string]interface{}
+		expected string
+	}{
+		{
+			name:  "get by id",
+			query: `query { getAuthor(id: "0x1") { name } }`,
+			expected: `query {
+  getAuthor(func: uid(0x1)) @filter(type(Author)) {
+    name : Author.name
+  }
+}`,
+		},
+		{
+			name: "filter, order and pagination",
+			query: `query($f: PostFilter) {
+				queryPost(filter: $f, order: { asc: title, then: { desc: numLikes } },
+					first: 10, offset: 20) {
+					id: postID
+					title
+					author { name }
+				}
+			}`,
+			vars: map[string]interface{}{
+				"f": map[string]interface{}{
+					"title": map[string]interface{}{"allofterms": "GraphQL Dgraph"},
+					"or":    map[string]interface{}{"isPublished": true},
+				},
+			},
+			expected: `query {
+  queryPost(func: type(Post), orderasc: Post.title, orderdesc: Post.numLikes, first: 10, offset: 20) ` +
+				`@filter((allofterms(Post.title, "GraphQL Dgraph") OR eq(Post.isPublished, "true"))) {
+    id : uid
+    title : Post.title
+    author : Post.author {
+      name : Author.name
+    }
+  }
+}`,
+		},
+		{
+			name:  "between",
+			query: `query { queryPost(filter: { numLikes: { between: { min: 1, max: 5 } } }) { title } }`,
+			expected: `query {
+  queryPost(func: type(Post)) @filter((ge(Post.numLikes, "1") AND le(Post.numLikes, "5"))) {
+    title : Post.title
+  }
+}`,
+		},
+		{
+			name:  "in",
+			query: `query { queryAuthor(filter: { name: { in: ["Alice", "Bob"] } }) { name } }`,
+			expected: `query {
+  queryAuthor(func: type(Author)) @filter(eq(Author.name, ["Alice", "Bob"])) {
+    name : Author.name
+  }
+}`,
+		},
+		{
+			name: "regexp and full-text search",
+			query: `query {
+				queryPost(filter: { title: { regexp: "/^GraphQL.*\\/s$/i", anyoftext: "run" } }) {
+					title
+				}
+			}`,
+			expected: `query {
+  queryPost(func: type(Post)) @filter((anyoftext(Post.title, "run") AND ` +
+				`regexp(Post.title, /^GraphQL.*\/s$/i))) {
+    title : Post.title
+  }
+}`,
+		},
+		{
+			name:  "eq ignoring case",
+			query: `query { queryPost(filter: { title: { eqIgnoreCase: "Why/How? (2.0)" } }) { title } }`,
+			expected: `query {
+  queryPost(func: type(Post)) @filter(regexp(Post.title, /^Why\/How\? \(2\.0\)$/i)) {
+    title : Post.title
+  }
+}`,
+		},
+		{
+			name:  "has",
+			query: `query { queryAuthor(filter: { has: [dob, posts] }) { name } }`,
+			expected: `query {
+  queryAuthor(func: type(Author)) @filter((has(Author.dob) AND has(Author.posts))) {
+    name : Author.name
+  }
+}`,
+		},
+		{
+			name:  "cascade",
+			query: `query { queryAuthor @cascade { name dob posts { title } } }`,
+			expected: `query {
+  queryAuthor(func: type(Author)) @cascade {
+    name : Author.name
+    dob : Author.dob
+    posts : Author.posts {
+      title : Post.title
+    }
+  }
+}`,
+		},
+		{
+			name: "cascade on some fields",
+			query: `query {
+				getAuthor(id: "0x1") {
+					name
+					posts @cascade(fields: ["postID", "numLikes", "isPublished"]) { title }
+				}
+			}`,
+			expected: `query {
+  getAuthor(func: uid(0x1)) @filter(type(Author)) {
+    name : Author.name
+    posts : Author.posts @filter((has(Post.numLikes) AND has(Post.isPublished))) {
+      title : Post.title
+    }
+  }
+}`,
+		},
+		{
+			name: "filter, order and pagination on edges",
+			query: `query {
+				getAuthor(id: "0x1") {
+					posts(filter: { isPublished: true }, order: { desc: numLikes }, first: 5) {
+						title
+					}
+				}
+			}`,
+			expected: `query {
+  getAuthor(func: uid(0x1)) @filter(type(Author)) {
+    posts : Author.posts (orderdesc: Post.numLikes, first: 5) ` +
+				`@filter(eq(Post.isPublished, "true")) {
+      title : Post.title
+    }
+  }
+}`,
+		},
+		{
+			name: "skip and include",
+			query: `query ($withPosts: Boolean!) {
+				queryAuthor {
+					name @skip(if: true)
+					dob @include(if: $withPosts)
+					... on Author @include(if: $withPosts) { posts { title } }
+					...AuthorName
+				}
+			}
+			fragment AuthorName on Author { name }`,
+			vars: map[string]interface{}{"withPosts": false},
+			expected: `query {
+  queryAuthor(func: type(Author)) {
+    name : Author.name
+  }
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			op := operationWith(t, testSchema,
+				&schema.Request{Query: test.query, Variables: test.vars})
+			require.Len(t, op.Queries(), 1)
+
+			dgQuery, err := rewriteAsQuery(op.Queries()[0], &authorizer{})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, dgraph.AsString(dgQuery))
+		})
+	}
+}
+
+func TestSearchOperators(t *testing.T) {
+	handler, err := schema.NewHandler(testSchema)
+	require.NoError(t, err)
+
+	// Author.name has no trigram index, so it can't be searched by regexp.
+	_, err = handler.Schema().Operation(&schema.Request{
+		Query: `query { queryAuthor(filter: { name: { regexp: "/^A/" } }) { name } }`,
+	})
+	require.Error(t, err)
+
+	for _, re := range []string{"^A", "/^A/) OR uid(0x1", "/a/b/", "/A/x"} {
+		op, err := handler.Schema().Operation(&schema.Request{
+			Query:     `query($re: String) { queryPost(filter: { title: { regexp: $re } }) { title } }`,
+			Variables: map[string]interface{}{"re": re},
+		})
+		require.NoError(t, err)
+		_, err = rewriteAsQuery(op.Queries()[0], &authorizer{})
+		require.Error(t, err, re)
+	}
+}
+
+func TestRequestResolver(t *testing.T) {
+	client := &mockDgraph{results: []string{`{"getAuthor": [{"name": "A.N. Author"}]}`}}
+	resolver := resolverFor(t, testSchema, client)
+
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query: `query { getAuthor(id: "0x1") { name } }`,
+	})
+	require.Empty(t, resp.Errors)
+
+	var buf bytes.Buffer
+	_, err := resp.WriteTo(&buf)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data": {"getAuthor": {"name": "A.N. Author"}}}`, buf.String())
+
+	resp = resolver.Resolve(context.Background(), &schema.Request{
+		Query: `query { getAuthor(id: "0x1") { notAField } }`,
+	})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, `Cannot query field "notAField" on type "Author".`, resp.Errors[0].Message)
+	require.Equal(t, 0, resp.Data.Len())
+}
+
+func TestRewriteCached(t *testing.T) {
+	handler, err := schema.NewHandler(testSchema)
+	require.NoError(t, err)
+	sch := handler.Schema()
+
+	query := `query { getAuthor(id: "0x1") { name } }`
+	op, err := sch.Operation(&schema.Request{Query: query})
+	require.NoError(t, err)
+	rewritten, err := rewriteCached(op.Queries()[0], &authorizer{})
+	require.NoError(t, err)
+
+	// The same operation, sent again, isn't rewritten again.
+	op, err = sch.Operation(&schema.Request{Query: query})
+	require.NoError(t, err)
+	again, err := rewriteCached(op.Queries()[0], &authorizer{})
+	require.NoError(t, err)
+	require.True(t, rewritten == again)
+
+	// Authors have no @auth rules, so the claims don't matter.
+	claims := &authorizer{claims: map[string]interface{}{"USER": "alice"}}
+	again, err = rewriteCached(op.Queries()[0], claims)
+	require.NoError(t, err)
+	require.True(t, rewritten == again)
+
+	// Operations with variables are rewritten each time.
+	withVars := &schema.Request{Query: `query ($id: ID!) { getAuthor(id: $id) { name } }`,
+		Variables: map[string]interface{}{"id": "0x1"}}
+	op, err = sch.Operation(withVars)
+	require.NoError(t, err)
+	rewritten, err = rewriteCached(op.Queries()[0], &authorizer{})
+	require.NoError(t, err)
+	op, err = sch.Operation(withVars)
+	require.NoError(t, err)
+	again, err = rewriteCached(op.Queries()[0], &authorizer{})
+	require.NoError(t, err)
+	require.False(t, rewritten == again)
+	require.Equal(t, dgraph.AsString(rewritten), dgraph.AsString(again))
+}
+
+func TestRewriteCachedByClaimsRead(t *testing.T) {
+	handler, err := schema.NewHandler(authSchema)
+	require.NoError(t, err)
+	sch := handler.Schema()
+	rewrite := func(query string, claims map[string]interface{}) *gql.GraphQuery {
+		op, err := sch.Operation(&schema.Request{Query: query})
+		require.NoError(t, err)
+		rewritten, err := rewriteCached(op.Queries()[0], &authorizer{claims: claims})
+		require.NoError(t, err)
+		return rewritten
+	}
+
+	// Only the claims that the rules read key the rewritten query, so a
+	// token with another expiry gets the same query.
+	todos := `query { queryTodo { text } }`
+	alice := rewrite(todos, map[string]interface{}{"USER": "alice", "exp": 1})
+	again := rewrite(todos, map[string]interface{}{"USER": "alice", "exp": 2})
+	require.True(t, alice == again)
+	bob := rewrite(todos, map[string]interface{}{"USER": "bob", "exp": 1})
+	require.False(t, alice == bob)
+	require.Contains(t, dgraph.AsString(bob), `"bob"`)
+	anonymous := rewrite(todos, nil)
+	require.False(t, alice == anonymous)
+
+	// A field's roles are read too.
+	salaries := `query { queryEmployee { name salary } }`
+	hr := rewrite(salaries, map[string]interface{}{"ROLE": "HR", "iat": 1})
+	require.True(t, hr == rewrite(salaries, map[string]interface{}{"ROLE": "HR", "iat": 2}))
+	require.False(t, hr == rewrite(salaries, map[string]interface{}{"ROLE": "USER"}))
+	require.NotContains(t, dgraph.AsString(rewrite(salaries, nil)), "salary")
+}
+
+func TestPluralNaming(t *testing.T) {
+	client := &mockDgraph{
+		results: []string{
+			`{"authors": [{"name": "A.N. Author"}]}`,
+			`{"post": [{"title": "A Post"}]}`,
+		},
+		assigned: map[string]string{"Post1": "0x2"},
+	}
+	resolver := resolverFor(t, "schema @api(naming: PLURAL)\n"+testSchema, client)
+
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query: `query { authors(first: 1) { name } }`,
+	})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"authors": [{"name": "A.N. Author"}]}`, resp.Data.String())
+	require.Equal(t, []string{`query {
+  authors(func: type(Author), first: 1) {
+    name : Author.name
+  }
+}`}, client.queries)
+
+	resp = resolver.Resolve(context.Background(), &schema.Request{
+		Query: `mutation { addPost(input: [{title: "A Post", author: {id: "0x1"}}]) {
+			post { title }
+		} }`,
+	})
+	require.Empty(t, resp.Errors)
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `{"addPost": {"post": [{"title": "A Post"}]}}`, resp.Data.String())
+}
+
+func TestNamedPredicates(t *testing.T) {
+	sch := `
+		type Person {
+			id: ID!
+			name: String! @dgraph(pred: "name") @search(by: [hash])
+			friends: [Person] @dgraph(pred: "friend")
+		}`
+	client := &mockDgraph{
+		results: []string{
+			`{"queryPerson": [{"name": "Ann", "friends": [{"name": "Bob"}]}]}`,
+			`{"person": [{"name": "Cat"}]}`,
+		},
+		assigned: map[string]string{"Person1": "0x2"},
+	}
+	resolver := resolverFor(t, sch, client)
+
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query: `query { queryPerson(filter: { name: { eq: "Ann" } }) { name friends { name } } }`,
+	})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"queryPerson": [{"name": "Ann", "friends": [{"name": "Bob"}]}]}`,
+		resp.Data.String())
+	require.Equal(t, []string{`query {
+  queryPerson(func: type(Person)) @filter(eq(name, "Ann")) {
+    name : name
+    friends : friend {
+      name : name
+    }
+  }
+}`}, client.queries)
+
+	resp = resolver.Resolve(context.Background(), &schema.Request{
+		Query: `mutation { addPerson(input: [{name: "Cat", friends: [{id: "0x1"}]}]) {
+			person { name }
+		} }`,
+	})
+	require.Empty(t, resp.Errors)
+	require.Len(t, client.mutations, 1)
+	require.Contains(t, string(client.mutations[0].SetJson), `"name":"Cat"`)
+	require.Contains(t, string(client.mutations[0].SetJson), `"friend":[{"uid":"0x1"}]`)
+}
+
+func TestIntrospectionQuery(t *testing.T) {
+	client := &mockDgraph{}
+	resp := resolverFor(t, testSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: `query { t: __type(name: "Author") { name } }`,
+	})
+	require.Empty(t, resp.Errors)
+	require.Empty(t, client.queries, "introspection doesn't need Dgraph")
+	require.JSONEq(t, `{"t": {"name": "Author"}}`, resp.Data.String())
+}
+
+func TestRootTypename(t *testing.T) {
+	handler, err := schema.NewHandler(testSchema)
+	require.NoError(t, err)
+
+	tests := map[string]string{
+		`query { __typename }`:                      `{"__typename": "Query"}`,
+		`query { t: __typename }`:                   `{"t": "Query"}`,
+		`mutation { __typename }`:                   `{"__typename": "Mutation"}`,
+		`mutation @atomic { t: __typename }`:        `{"t": "Mutation"}`,
+		`query { __typename queryAuthor { name } }`: `{"__typename": "Query", "queryAuthor": []}`,
+	}
+	for query, expected := range tests {
+		client := &mockDgraph{results: []string{`{"queryAuthor": []}`}}
+		resp := New(handler.Schema(), client).Resolve(context.Background(),
+			&schema.Request{Query: query})
+		require.Empty(t, resp.Errors, query)
+		require.JSONEq(t, expected, resp.Data.String(), query)
+		require.Empty(t, client.mutations, query)
+	}
+}
+
+func TestRemoteAPI(t *testing.T) {
+	var gotQueries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req schema.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		gotQueries = append(gotQueries, req.Query)
+
+		_, _ = w.Write([]byte(`{
+			"data": {"p": {"amount": 1.5, "status": null}},
+			"errors": [{"message": "status unknown", "path": ["p", "status"]}]
+		}`))
+	}))
+	defer srv.Close()
+
+	handler, err := schema.NewHandler(testSchema, schema.RemoteAPI{
+		Field:  "payments",
+		Prefix: "Payments",
+		URL:    srv.URL,
+		SDL: `type Payment { amount: Float!, status: String }
+			type Query { payment(id: ID!): Payment }
+			type Mutation { pay(amount: Float!): Payment }`,
+	})
+	require.NoError(t, err)
+
+	client := &mockDgraph{}
+	resolver := New(handler.Schema(), client)
+
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query: `query { pay: payments { p: payment(id: "1") { amount, status } } }`,
+	})
+	require.Empty(t, client.queries, "remote queries don't go to Dgraph")
+	require.JSONEq(t, `{"pay": {"p": {"amount": 1.5, "status": null}}}`, resp.Data.String())
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "status unknown", resp.Errors[0].Message)
+	require.Equal(t, []interface{}{"pay", "p", "status"}, resp.Errors[0].Path)
+
+	resp = resolver.Resolve(context.Background(), &schema.Request{
+		Query: `mutation { payments { p: pay(amount: 1.5) { amount } } }`,
+	})
+	require.JSONEq(t, `{"payments": {"p": {"amount": 1.5}}}`, resp.Data.String())
+	require.Nil(t, client.mutations, "remote mutations don't go to Dgraph")
+
+	require.Equal(t, []string{
+		"query {\n  p: payment(id: \"1\") {\n    amount\n    status\n  }\n}",
+		"mutation {\n  p: pay(amount: 1.5) {\n    amount\n  }\n}",
+	}, gotQueries)
+}
+
+const interfaceSchema = `
+interface Character {
+	id: ID!
+	name: String! @search(by: [hash])
+}
+
+type Human implements Character {
+	id: ID!
+	name: String! @search(by: [hash])
+	totalCredits: Int
+}
+
+type Droid implements Character {
+	id: ID!
+	name: String! @search(by: [hash])
+	primaryFunction: String
+}
+
+type Film {
+	id: ID!
+	title: String!
+	characters: [Character]
+}
+`
+
+func TestInterfaceFragments(t *testing.T) {
+	client := &mockDgraph{results: []string{`{"getFilm": [{"characters": [
+		{"dgraph.type": ["Human", "Character"], "name": "Luke", "totalCredits": 10},
+		{"dgraph.type": ["Droid", "Character"], "name": "R2-D2", "primaryFunction": "Astromech"}
+	]}]}`}}
+	resp := resolverFor(t, interfaceSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: `query {
+			getFilm(id: "0x1") {
+				characters {
+					__typename
+					name
+					... on Human { name totalCredits }
+					...droid
+				}
+			}
+		}
+		fragment droid on Droid { primaryFunction }`,
+	})
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, []string{`query {
+  getFilm(func: uid(0x1)) @filter(type(Film)) {
+    characters : Film.characters {
+      dgraph.type : dgraph.type
+      name : Character.name
+      totalCredits : Human.totalCredits
+      primaryFunction : Droid.primaryFunction
+    }
+  }
+}`}, client.queries)
+	require.JSONEq(t, `{"getFilm": {"characters": [
+		{"__typename": "Human", "name": "Luke", "totalCredits": 10},
+		{"__typename": "Droid", "name": "R2-D2", "primaryFunction": "Astromech"}
+	]}}`, resp.Data.String())
+}
+
+func TestInterfaceQueries(t *testing.T) {
+	client := &mockDgraph{results: []string{`{"queryCharacter": [
+		{"dgraph.type": ["Human", "Character"], "name": "Luke", "totalCredits": 10},
+		{"dgraph.type": ["Droid", "Character"], "name": "R2-D2"}
+	]}`}}
+	resp := resolverFor(t, interfaceSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: `query {
+			queryCharacter(filter: { name: { eq: "Luke" } }, order: { asc: name }) {
+				__typename
+				name
+				... on Human { totalCredits }
+			}
+		}`,
+	})
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, []string{`query {
+  queryCharacter(func: type(Character), orderasc: Character.name) ` +
+		`@filter(eq(Character.name, "Luke")) {
+    dgraph.type : dgraph.type
+    name : Character.name
+    totalCredits : Human.totalCredits
+  }
+}`}, client.queries)
+	require.JSONEq(t, `{"queryCharacter": [
+		{"__typename": "Human", "name": "Luke", "totalCredits": 10},
+		{"__typename": "Droid", "name": "R2-D2"}
+	]}`, resp.Data.String())
+
+	client = &mockDgraph{results: []string{`{"getCharacter": [
+		{"dgraph.type": ["Droid", "Character"], "name": "R2-D2"}
+	]}`}}
+	resp = resolverFor(t, interfaceSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: `query { getCharacter(id: "0x2") { __typename name } }`,
+	})
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, []string{`query {
+  getCharacter(func: uid(0x2)) @filter(type(Character)) {
+    dgraph.type : dgraph.type
+    name : Character.name
+  }
+}`}, client.queries)
+	require.JSONEq(t, `{"getCharacter": {"__typename": "Droid", "name": "R2-D2"}}`,
+		resp.Data.String())
+}
+
+func TestResolveBatch(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Author1": "0x1"},
+		results: []string{
+			`{"getAuthor": [{"name": "Before"}]}`,
+			`{"author": [{"name": "After"}]}`,
+			`{"getAuthor": [{"name": "After"}]}`,
+		},
+	}
+	getAuthor := &schema.Request{Query: `query { getAuthor(id: "0x1") { name } }`}
+	resps := resolverFor(t, testSchema, client).ResolveBatch(context.Background(),
+		[]*schema.Request{
+			getAuthor,
+			{Query: `query { getAuthor(id: "0x1") { notAField } }`},
+			{Query: `mutation { addAuthor(input: [{name: "After"}]) { author { name } } }`},
+			getAuthor,
+		})
+	require.Len(t, resps, 4)
+
+	require.Empty(t, resps[0].Errors)
+	require.JSONEq(t, `{"getAuthor": {"name": "Before"}}`, resps[0].Data.String())
+	require.Len(t, resps[1].Errors, 1)
+	require.Equal(t, `Cannot query field "notAField" on type "Author".`, resps[1].Errors[0].Message)
+	require.Empty(t, resps[2].Errors)
+	require.JSONEq(t, `{"addAuthor": {"author": [{"name": "After"}]}}`, resps[2].Data.String())
+	require.Empty(t, resps[3].Errors)
+	require.JSONEq(t, `{"getAuthor": {"name": "After"}}`, resps[3].Data.String())
+
+	// The mutation ran between the queries, in the order they were sent.
+	require.Len(t, client.mutations, 1)
+	require.Len(t, client.queries, 3)
+}
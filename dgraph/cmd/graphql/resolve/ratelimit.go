@@ -0,0 +1,225 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/vektah/gqlparser/gqlerror"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// RateLimitedCode is the error code, in the error's extensions, for requests
+// and mutations refused because their client is over its rate limit.  The
+// error's retryAfter extension is how many seconds to wait before trying
+// again.
+const RateLimitedCode = "RATE_LIMITED"
+
+// RateLimits bound how fast each client can make requests and run mutations.
+// Each is a token bucket: a client can make up to the burst at once, and then
+// as many per second as the rate.  A rate of 0 is no limit.
+type RateLimits struct {
+	Requests     float64
+	RequestBurst int
+	Writes       float64
+	WriteBurst   int
+}
+
+// A RateLimiter enforces RateLimits for each client.  Clients are told apart
+// by the key that WithClientKey adds to the context; requests without a key
+// aren't limited.
+type RateLimiter struct {
+	requests *tokenBuckets
+	writes   *tokenBuckets
+}
+
+// NewRateLimiter returns a RateLimiter for limits.  A burst that isn't set is
+// a second's worth of the rate.
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{
+		requests: newTokenBuckets(limits.Requests, limits.RequestBurst),
+		writes:   newTokenBuckets(limits.Writes, limits.WriteBurst),
+	}
+}
+
+// AllowRequest takes a request from the client's allowance.  If the client
+// is over its limit, it returns false, and how long until it can try again.
+func (l *RateLimiter) AllowRequest(ctx context.Context) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	return l.allow(ctx, l.requests, "request")
+}
+
+func (l *RateLimiter) allow(ctx context.Context, buckets *tokenBuckets,
+	limit string) (bool, time.Duration) {
+
+	key := ClientKey(ctx)
+	if buckets == nil || key == "" {
+		return true, 0
+	}
+	wait := buckets.take(key, time.Now())
+	if wait == 0 {
+		return true, 0
+	}
+	if tagged, err := tag.New(ctx, tag.Upsert(limitKey, limit)); err == nil {
+		stats.Record(tagged, throttled.M(1))
+	}
+	return false, wait
+}
+
+// checkWrite returns an error response if the client running op, a mutation,
+// is over its limit for writes, and nil if op can go ahead.
+func (l *RateLimiter) checkWrite(ctx context.Context, op schema.Operation) *schema.Response {
+	if l == nil || !op.IsMutation() {
+		return nil
+	}
+	ok, wait := l.allow(ctx, l.writes, "write")
+	if ok {
+		return nil
+	}
+	return &schema.Response{Errors: gqlerror.List{RateLimitError(
+		fmt.Sprintf("Too many mutations; try again in %ds", RetryAfter(wait)), wait)}}
+}
+
+// RateLimitError is the error for a client that's over its rate limit, and
+// can try again after wait.
+func RateLimitError(msg string, wait time.Duration) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message: msg,
+		Extensions: map[string]interface{}{
+			"code":       RateLimitedCode,
+			"retryAfter": RetryAfter(wait),
+		},
+	}
+}
+
+// RetryAfter is wait in whole seconds, rounded up, as in a Retry-After
+// header.
+func RetryAfter(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
+
+type clientKey struct{}
+
+// WithClientKey returns a copy of ctx in which requests are rate limited as
+// the client key, e.g. the client's API key or its token's subject.
+func WithClientKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, clientKey{}, key)
+}
+
+// ClientKey returns the key that ctx's requests are rate limited as, or "" if
+// there isn't one.
+func ClientKey(ctx context.Context) string {
+	key, _ := ctx.Value(clientKey{}).(string)
+	return key
+}
+
+// sweepInterval is how often buckets that have filled up again are
+// forgotten, so that clients that have gone away don't use memory.
+const sweepInterval = time.Minute
+
+// tokenBuckets are the token buckets of each client, for one limit.
+type tokenBuckets struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBuckets returns the buckets for rate, or nil if rate is no limit.
+func newTokenBuckets(rate float64, burst int) *tokenBuckets {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &tokenBuckets{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// take takes a token from key's bucket at now.  It returns 0 if there was a
+// token, and otherwise how long until there is one.
+func (tb *tokenBuckets) take(key string, now time.Time) time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if now.Sub(tb.lastSweep) >= sweepInterval {
+		tb.sweep(now)
+	}
+
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: tb.burst, last: now}
+		tb.buckets[key] = b
+	}
+	b.tokens = math.Min(tb.burst, b.tokens+now.Sub(b.last).Seconds()*tb.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / tb.rate * float64(time.Second))
+}
+
+// sweep forgets the buckets that would be full by now.  tb.mu must be held.
+func (tb *tokenBuckets) sweep(now time.Time) {
+	for key, b := range tb.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*tb.rate >= tb.burst {
+			delete(tb.buckets, key)
+		}
+	}
+	tb.lastSweep = now
+}
+
+var (
+	throttled = stats.Int64("graphql_throttled_requests_total",
+		"Number of GraphQL requests and mutations refused by rate limits",
+		stats.UnitDimensionless)
+	limitKey, _ = tag.NewKey("limit")
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Name:        throttled.Name(),
+		Measure:     throttled,
+		Description: throttled.Description(),
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{limitKey},
+	}); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBuckets(t *testing.T) {
+	tb := newTokenBuckets(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		require.Zero(t, tb.take("alice", now), "the burst is allowed")
+	}
+	require.Equal(t, 500*time.Millisecond, tb.take("alice", now))
+	require.Zero(t, tb.take("bob", now), "each client has its own bucket")
+
+	now = now.Add(250 * time.Millisecond)
+	require.Equal(t, 250*time.Millisecond, tb.take("alice", now))
+	now = now.Add(250 * time.Millisecond)
+	require.Zero(t, tb.take("alice", now), "a token every half second")
+
+	// Buckets that have filled up are forgotten.
+	now = now.Add(sweepInterval)
+	require.Zero(t, tb.take("carol", now))
+	require.Len(t, tb.buckets, 1)
+
+	require.Nil(t, newTokenBuckets(0, 10), "a rate of 0 is no limit")
+	require.Equal(t, float64(3), newTokenBuckets(2.5, 0).burst)
+}
+
+func TestRateLimitedWrites(t *testing.T) {
+	client := &mockDgraph{assigned: map[string]string{"Note1": "0x1"}}
+	resolver := resolverFor(t, errorsSchema, client).
+		WithRateLimiter(NewRateLimiter(RateLimits{Writes: 0.001, WriteBurst: 1}))
+
+	add := &schema.Request{Query: `mutation { addNote(input: [{text: "A"}]) { note { text } } }`}
+	get := &schema.Request{Query: `query { getNote(id: "0x1") { text } }`}
+	ctx := WithClientKey(context.Background(), "sub:alice")
+
+	// The mock can't answer the mutation's query, but that doesn't matter;
+	// only whether it's refused does.
+	limited := func(resp *schema.Response) bool {
+		for _, e := range resp.Errors {
+			if e.Extensions["code"] == RateLimitedCode {
+				return true
+			}
+		}
+		return false
+	}
+
+	require.False(t, limited(resolver.Resolve(ctx, add)))
+
+	resp := resolver.Resolve(ctx, add)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, RateLimitedCode, resp.Errors[0].Extensions["code"])
+	require.Equal(t, 1000, resp.Errors[0].Extensions["retryAfter"])
+	require.Equal(t, "Too many mutations; try again in 1000s", resp.Errors[0].Message)
+	require.Len(t, client.mutations, 1, "the refused mutation isn't run")
+
+	require.False(t, limited(resolver.Resolve(ctx, get)), "queries aren't writes")
+	require.False(t, limited(
+		resolver.Resolve(WithClientKey(context.Background(), "sub:bob"), add)))
+	require.False(t, limited(resolver.Resolve(context.Background(), add)),
+		"requests without a client key aren't limited")
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+)
+
+// resolveRemote resolves field, the root field that remote API api is
+// stitched in at, by forwarding its selection set to api as an operation of
+// type op.  The remote result is already keyed by response name, so it's
+// completed as is.
+func resolveRemote(ctx context.Context, client *external.Client, op string,
+	field schema.Field, api *schema.RemoteAPI) *resolved {
+
+	if glog.V(3) {
+		glog.Infof("Forwarding %s %s to %s", op, field.Name(), api.URL)
+	}
+
+	rg := external.NewRemoteGraphQL(client, api.URL, client.Policy(api.URL))
+	val, remoteErrs, err := rg.Forward(ctx, op, field)
+	if err != nil {
+		null, _ := completeField(field, nil)
+		return &resolved{data: null, err: fieldErrors(field, err)}
+	}
+
+	data, errs := completeField(field, val)
+	errs = append(remoteErrs, errs...)
+	if len(errs) > 0 {
+		return &resolved{data: data, err: errs}
+	}
+	return &resolved{data: data}
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// redacted is logged instead of the value of a redacted variable.
+const redacted = "<redacted>"
+
+// RequestLogging configures the log that a RequestResolver writes of the
+// requests it resolves: a JSON object per request, with its ID, its
+// operation name and type, its variables, the subject of its token, how long
+// it took and the codes of any errors.  The query itself isn't logged, only
+// its hash.
+// The requests in a batch are each logged with how long the batch took.
+type RequestLogging struct {
+	// SampleRate is the fraction of requests that are logged, from 0 (none)
+	// to 1 (all).
+	SampleRate float64
+
+	// Errors logs every request whose response has errors, whether it's
+	// sampled or not.
+	Errors bool
+
+	// Redact names the variables, and the fields of input objects in
+	// variables, whose values aren't logged.  Names match regardless of case.
+	Redact []string
+}
+
+// A requestLog writes the log that a RequestLogging configures.
+type requestLog struct {
+	RequestLogging
+	redact map[string]bool
+
+	// write writes an entry; it's glog unless a test needs the entries.
+	write func(entry []byte)
+}
+
+// A requestLogEntry is what's logged of a request.
+type requestLogEntry struct {
+	RequestID     string                 `json:"requestId,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	OperationType string                 `json:"operationType,omitempty"`
+	QueryHash     string                 `json:"queryHash,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Authenticated bool                   `json:"authenticated"`
+	Subject       string                 `json:"subject,omitempty"`
+	Batch         int                    `json:"batch,omitempty"`
+	DurationMs    float64                `json:"durationMs"`
+	Errors        map[string]int         `json:"errors,omitempty"`
+}
+
+func newRequestLog(cfg RequestLogging) *requestLog {
+	rl := &requestLog{
+		RequestLogging: cfg,
+		redact:         make(map[string]bool, len(cfg.Redact)),
+		write: func(entry []byte) {
+			glog.Infof("GraphQL request: %s", entry)
+		},
+	}
+	for _, name := range cfg.Redact {
+		rl.redact[strings.ToLower(name)] = true
+	}
+	return rl
+}
+
+// log logs gqlReq, which was answered with resp after taking elapsed, if
+// it's sampled.  op is gqlReq's operation, or nil if it didn't get that far.
+// batch is how many requests were sent with it in a batch, or 0 if it
+// wasn't in one.
+func (rl *requestLog) log(ctx context.Context, gqlReq *schema.Request, op schema.Operation,
+	resp *schema.Response, elapsed time.Duration, batch int) {
+
+	if rl == nil {
+		return
+	}
+	if !(rl.Errors && len(resp.Errors) > 0) && rand.Float64() >= rl.SampleRate {
+		return
+	}
+
+	entry := &requestLogEntry{
+		RequestID:     RequestID(ctx),
+		OperationName: gqlReq.OperationName,
+		OperationType: operationType(op),
+		QueryHash:     requestHash(gqlReq),
+		Batch:         batch,
+		DurationMs:    float64(elapsed) / float64(time.Millisecond),
+	}
+	if len(gqlReq.Variables) > 0 {
+		entry.Variables = redactValue(rl.redact, gqlReq.Variables).(map[string]interface{})
+	}
+	if claims := authorization.Claims(ctx); claims != nil {
+		entry.Authenticated = true
+		entry.Subject, _ = claims["sub"].(string)
+	}
+	for _, err := range resp.Errors {
+		if entry.Errors == nil {
+			entry.Errors = make(map[string]int)
+		}
+		entry.Errors[errorClass(err)]++
+	}
+
+	js, err := json.Marshal(entry)
+	if err != nil {
+		glog.Errorf("Error logging GraphQL request: %v", err)
+		return
+	}
+	rl.write(js)
+}
+
+// redactValue returns a copy of val with the values of the fields named in
+// redact, by their lower case names, replaced at any depth.
+func redactValue(redact map[string]bool, val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			if redact[strings.ToLower(k)] {
+				res[k] = redacted
+			} else {
+				res[k] = redactValue(redact, v)
+			}
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(val))
+		for i, v := range val {
+			res[i] = redactValue(redact, v)
+		}
+		return res
+	default:
+		return val
+	}
+}
+
+func operationType(op schema.Operation) string {
+	switch {
+	case op == nil:
+		return ""
+	case op.IsQuery():
+		return "query"
+	case op.IsMutation():
+		return "mutation"
+	case op.IsSubscription():
+		return "subscription"
+	}
+	return ""
+}
+
+// requestHash is the hash of the query gqlReq sends, whether it sends the
+// query or just the hash.
+func requestHash(gqlReq *schema.Request) string {
+	if gqlReq.Query != "" {
+		return QueryHash(gqlReq.Query)
+	}
+	ext, _ := gqlReq.Extensions["persistedQuery"].(map[string]interface{})
+	hash, _ := ext["sha256Hash"].(string)
+	return hash
+}
+
+// errorClass classifies err, for the request log and for errors that don't
+// have a code yet: it's the code in err's extensions, if there is one;
+// otherwise, errors that stopped the request from running are REQUEST_ERROR
+// and the errors of fields are FIELD_ERROR.
+func errorClass(err *gqlerror.Error) string {
+	if code, ok := err.Extensions["code"].(string); ok && code != "" {
+		return code
+	}
+	if len(err.Path) == 0 {
+		return "REQUEST_ERROR"
+	}
+	return "FIELD_ERROR"
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLog(t *testing.T) {
+	var logged []map[string]interface{}
+	logTo := func(resolver *RequestResolver) *RequestResolver {
+		resolver.requestLog.write = func(entry []byte) {
+			var e map[string]interface{}
+			require.NoError(t, json.Unmarshal(entry, &e))
+			logged = append(logged, e)
+		}
+		return resolver
+	}
+
+	query := `query authors($filter: AuthorFilter, $first: Int) {
+		queryAuthor(filter: $filter, first: $first) { name }
+	}`
+	vars := map[string]interface{}{
+		"filter": map[string]interface{}{
+			"name": map[string]interface{}{"anyofterms": "Alice"},
+		},
+		"first": 10,
+	}
+	ctx := authorization.WithClaims(WithRequestID(context.Background(), "req-1"),
+		map[string]interface{}{"sub": "user-1", "ROLE": "admin"})
+
+	resolver := logTo(resolverFor(t, testSchema, &mockDgraph{}).
+		WithRequestLogging(RequestLogging{SampleRate: 1, Redact: []string{"NAME"}}))
+	resp := resolver.Resolve(ctx,
+		&schema.Request{Query: query, OperationName: "authors", Variables: vars})
+	require.Empty(t, resp.Errors)
+
+	require.Len(t, logged, 1)
+	require.True(t, logged[0]["durationMs"].(float64) > 0)
+	delete(logged[0], "durationMs")
+	require.Equal(t, map[string]interface{}{
+		"requestId":     "req-1",
+		"operationName": "authors",
+		"operationType": "query",
+		"queryHash":     QueryHash(query),
+		"variables": map[string]interface{}{
+			"filter": map[string]interface{}{"name": "<redacted>"},
+			"first":  10.0,
+		},
+		"authenticated": true,
+		"subject":       "user-1",
+	}, logged[0])
+	require.Equal(t, "Alice",
+		vars["filter"].(map[string]interface{})["name"].(map[string]interface{})["anyofterms"],
+		"the request's variables aren't changed")
+
+	// Unsampled requests are only logged if they have errors and errors are
+	// always logged.
+	logged = nil
+	resolver = logTo(resolverFor(t, testSchema, &mockDgraph{}).
+		WithRequestLogging(RequestLogging{Errors: true}))
+	resolver.Resolve(context.Background(), &schema.Request{Query: query})
+	require.Empty(t, logged)
+
+	resolver.ResolveBatch(context.Background(), []*schema.Request{
+		{Query: `query { getAuthor(id: "0x1") { nope } }`},
+		{Query: query},
+	})
+	require.Len(t, logged, 1)
+	require.Equal(t, map[string]interface{}{validationFailedCode: 1.0}, logged[0]["errors"])
+	require.Equal(t, 2.0, logged[0]["batch"])
+	require.Equal(t, false, logged[0]["authenticated"])
+	require.NotContains(t, logged[0], "operationType")
+}
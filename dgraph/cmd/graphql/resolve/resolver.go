@@ -0,0 +1,1135 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package resolve resolves GraphQL queries and mutations by rewriting them
+// into Dgraph queries and mutations, running those against Dgraph and then
+// completing the Dgraph results into the shape the GraphQL request asked for.
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/external"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/signing"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/graphql/tracing"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/gqlerror"
+	otrace "go.opencensus.io/trace"
+)
+
+// A RequestResolver can resolve GraphQL requests against a schema, using
+// Dgraph to answer the queries and mutations, or the remote GraphQL APIs that
+// are stitched into the schema, or the HTTP endpoints of @custom fields, or
+// the lambda server for @lambda fields.  The schema can be swapped while
+// requests are being served; each request is resolved entirely against the
+// schema that was current when it arrived.
+type RequestResolver struct {
+	mu     sync.RWMutex
+	schema schema.Schema
+
+	dgraphClient   dgraph.Client
+	remoteClient   *external.Client
+	fieldResolvers map[string]FieldResolverFunc
+
+	// secrets expands the secrets referenced by @custom calls.
+	secrets secrets.Provider
+
+	// lambdaURL is where @lambda fields are resolved; "" if there's no
+	// lambda server.  The calls are made with lambdaClient, which signs them
+	// if the lambda server needs that.
+	lambdaURL    string
+	lambdaClient *external.Client
+
+	// signed are the clients that sign the @custom calls that name a signer,
+	// by the signer's name.
+	signed map[string]*external.Client
+
+	// files stores the files uploaded to @upload fields; nil if uploads
+	// aren't accepted.
+	files FileStore
+
+	// anonymous is what requests without claims can run; nil allows anything.
+	anonymous *authorization.AnonymousPolicy
+
+	// introspectionPolicy is who can ask about the schema; nil allows anyone.
+	introspectionPolicy *IntrospectionPolicy
+
+	// limits bound how much work an operation can ask for.
+	limits QueryLimits
+
+	// nodeCounts are the numbers of nodes of each type, for estimating
+	// operations' costs.
+	nodeCounts *nodeCounts
+
+	// rateLimiter limits how fast each client can run mutations; nil if it
+	// isn't limited.
+	rateLimiter *RateLimiter
+
+	// retries say how reads and writes that fail transiently are retried.
+	retries Retries
+
+	// timeouts bound how long operations can take.
+	timeouts Timeouts
+
+	// persisted are the queries that requests can send by their hash.
+	persisted *PersistedQueries
+
+	// cache keeps the responses to queries whose @cacheControl hints allow
+	// them to be cached.
+	cache *responseCache
+
+	// requestLog logs the requests r resolves; nil if they aren't logged.
+	requestLog *requestLog
+
+	// auditLog records the mutations r commits; nil if they aren't audited.
+	auditLog *auditLog
+
+	// events delivers the events of @lambdaOnMutate types.
+	events *eventSender
+
+	// changeCapture publishes the changes that r's mutations make; nil if
+	// they aren't captured.
+	changeCapture *changePublisher
+
+	// concurrentBatches resolves the queries in a batch concurrently.
+	concurrentBatches bool
+
+	// introspection allows introspection queries even when only registered
+	// queries are.
+	introspection bool
+
+	// explain allows requests to ask for their operations to be explained.
+	explain bool
+
+	// changes tells subscriptions when data might have changed.
+	changes      *changeFeed
+	pollInterval time.Duration
+}
+
+// A FieldResolverFunc resolves a query or mutation field without going to
+// Dgraph.  The value it returns, with objects keyed by field name, is
+// completed into the shape of the field's selection set just like a Dgraph
+// result is.  The claims of the request, if it was authenticated, are in ctx;
+// see authorization.Claims.
+type FieldResolverFunc func(ctx context.Context, field schema.Field) (interface{}, error)
+
+// resolved is the result of resolving a single query or mutation.  data is a
+// JSON fragment like `"q": {...}`, ready to be added to a schema.Response.
+// There can be data and errors at the same time; for example, if some of the
+// fields were null when they shouldn't have been.
+type resolved struct {
+	data []byte
+	err  error
+}
+
+// addTo adds res to resp.  If res has no data, because its field is
+// non-nullable but is null, the error propagates: resp's data is null.
+func (res *resolved) addTo(resp *schema.Response) {
+	if res.data == nil {
+		resp.NullData()
+	} else {
+		resp.AddData(res.data)
+	}
+	resp.WithError(res.err)
+}
+
+// New creates a RequestResolver for GraphQL schema s that resolves requests
+// with dgraphClient.
+// s can be nil if there's no schema yet.
+func New(s schema.Schema, dgraphClient dgraph.Client) *RequestResolver {
+	remoteClient := external.NewClient(nil)
+	return &RequestResolver{
+		schema:         s,
+		dgraphClient:   explainingClient{dgraphClient},
+		nodeCounts:     newNodeCounts(dgraphClient),
+		remoteClient:   remoteClient,
+		lambdaClient:   remoteClient,
+		fieldResolvers: make(map[string]FieldResolverFunc),
+		retries:        DefaultRetries,
+		persisted:      NewPersistedQueries(),
+		cache:          newResponseCache(s),
+		changes:        newChangeFeed(),
+		pollInterval:   DefaultPollInterval,
+		events:         newEventSender(DefaultEventDelivery, remoteClient),
+	}
+}
+
+// WithFieldResolver makes r resolve the query or mutation called name with
+// fn, instead of with Dgraph.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithFieldResolver(name string, fn FieldResolverFunc) *RequestResolver {
+	r.fieldResolvers[name] = fn
+	return r
+}
+
+// WithAnonymousPolicy makes r refuse operations from unauthenticated requests
+// - requests whose context carries no claims - unless p allows them.  It
+// returns r, so calls can be chained.
+func (r *RequestResolver) WithAnonymousPolicy(p *authorization.AnonymousPolicy) *RequestResolver {
+	r.anonymous = p
+	return r
+}
+
+// WithIntrospectionPolicy makes r refuse operations that ask about the schema
+// unless p allows them.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithIntrospectionPolicy(p *IntrospectionPolicy) *RequestResolver {
+	r.introspectionPolicy = p
+	return r
+}
+
+// IntrospectionPolicy returns who can ask r about the schema; nil if anyone
+// can.
+func (r *RequestResolver) IntrospectionPolicy() *IntrospectionPolicy {
+	return r.introspectionPolicy
+}
+
+// WithSecrets makes r look up the secrets that @custom calls reference, as
+// {{secrets.NAME}}, in p.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithSecrets(p secrets.Provider) *RequestResolver {
+	r.secrets = p
+	return r
+}
+
+// WithLambda makes r resolve @lambda fields by calling the lambda server at
+// url.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithLambda(url string) *RequestResolver {
+	r.lambdaURL = url
+	return r
+}
+
+// WithCallPolicies makes r choose the policy of each call it makes to an
+// external service - to @custom and @lambda resolvers, remote APIs and event
+// endpoints - from ps.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithCallPolicies(ps external.Policies) *RequestResolver {
+	// The signing clients share remoteClient's policies.
+	r.remoteClient.SetPolicies(ps)
+	return r
+}
+
+// WithSigners makes r sign the @custom calls that name one of signers with
+// it.  Names are lower case, as the calls have them.  It returns r, so calls
+// can be chained.
+func (r *RequestResolver) WithSigners(signers map[string]signing.Signer) *RequestResolver {
+	r.signed = make(map[string]*external.Client, len(signers))
+	for name, s := range signers {
+		r.signed[name] = r.remoteClient.WithSigner(s)
+	}
+	return r
+}
+
+// WithLambdaSigner makes r sign the calls it makes to the lambda server with
+// s, so the lambda server can tell they came from r.  It returns r, so calls
+// can be chained.
+func (r *RequestResolver) WithLambdaSigner(s signing.Signer) *RequestResolver {
+	r.lambdaClient = r.remoteClient.WithSigner(s)
+	return r
+}
+
+// WithFileStore makes r store the files uploaded to @upload fields in store.
+// It returns r, so calls can be chained.
+func (r *RequestResolver) WithFileStore(store FileStore) *RequestResolver {
+	r.files = store
+	return r
+}
+
+// WithConcurrentBatches makes r resolve the consecutive queries in a batch
+// concurrently, rather than one after another.  It returns r, so calls can be
+// chained.
+func (r *RequestResolver) WithConcurrentBatches(concurrent bool) *RequestResolver {
+	r.concurrentBatches = concurrent
+	return r
+}
+
+// WithLimits makes r refuse operations that are over limits.  It returns r,
+// so calls can be chained.
+func (r *RequestResolver) WithLimits(limits QueryLimits) *RequestResolver {
+	r.limits = limits
+	return r
+}
+
+// WithRateLimiter makes r refuse mutations from clients that are over their
+// limit for writes in l.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithRateLimiter(l *RateLimiter) *RequestResolver {
+	r.rateLimiter = l
+	return r
+}
+
+// WithRetries makes r retry reads and writes that fail transiently as
+// retries says, instead of as DefaultRetries says.  It returns r, so calls
+// can be chained.
+func (r *RequestResolver) WithRetries(retries Retries) *RequestResolver {
+	r.retries = retries
+	return r
+}
+
+// WithTimeouts makes r give up on operations that take longer than timeouts
+// allow.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithTimeouts(timeouts Timeouts) *RequestResolver {
+	r.timeouts = timeouts
+	return r
+}
+
+// WithRequestLogging makes r log the requests it resolves, as cfg says.  It
+// returns r, so calls can be chained.
+func (r *RequestResolver) WithRequestLogging(cfg RequestLogging) *RequestResolver {
+	r.requestLog = newRequestLog(cfg)
+	return r
+}
+
+// WithAuditing makes r record the mutations it commits, as cfg says.  It
+// returns r, so calls can be chained.
+func (r *RequestResolver) WithAuditing(cfg Auditing) *RequestResolver {
+	r.auditLog = newAuditLog(cfg)
+	return r
+}
+
+// WithEventDelivery makes r retry the events of @lambdaOnMutate types that
+// fail to be delivered as policy says.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithEventDelivery(policy RetryPolicy) *RequestResolver {
+	r.events = newEventSender(policy, r.remoteClient)
+	return r
+}
+
+// WithChangeCapture makes r publish the changes its mutations make, as cc
+// says.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithChangeCapture(cc ChangeCapture) *RequestResolver {
+	r.changeCapture = nil
+	if cc.Sink != nil {
+		r.changeCapture = newChangePublisher(cc)
+	}
+	return r
+}
+
+// WithRegisteredQueriesOnly makes r run only the queries registered in its
+// PersistedQueries, if only is true.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithRegisteredQueriesOnly(only bool) *RequestResolver {
+	r.persisted.SetRegisteredOnly(only)
+	return r
+}
+
+// WithIntrospection makes r run introspection queries, if allow is true, even
+// when only registered queries are allowed, e.g. so that tools can explore
+// the API in development.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithIntrospection(allow bool) *RequestResolver {
+	r.introspection = allow
+	return r
+}
+
+// PersistedQueries returns the queries that requests to r can send by hash.
+func (r *RequestResolver) PersistedQueries() *PersistedQueries {
+	return r.persisted
+}
+
+// Schema returns the schema that r is currently resolving requests against.
+func (r *RequestResolver) Schema() schema.Schema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schema
+}
+
+// SetSchema swaps the schema that r resolves requests against.  Requests that
+// are already being resolved carry on with the schema they started with.  The
+// cached responses, which were for the old schema, are forgotten.
+func (r *RequestResolver) SetSchema(s schema.Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schema = s
+	r.cache.reset(s)
+}
+
+// Resolve processes gqlReq and returns the GraphQL response.  gqlReq is
+// validated against the schema before anything is run.  As per the GraphQL
+// spec, the queries in an operation are resolved concurrently and the
+// mutations are resolved one after the other, in order.  If gqlReq's
+// tracing extension is true, the response's tracing extension has the
+// request's timings, in Apollo's tracing format, and if its explain extension
+// is true, and r allows it, the explain extension has the DQL it ran.  Each
+// error in the response has a code and the request's ID, from ctx, or a new
+// one, in its extensions.
+func (r *RequestResolver) Resolve(ctx context.Context, gqlReq *schema.Request) *schema.Response {
+	resp, _ := r.resolve(ctx, gqlReq)
+	return resp
+}
+
+// ResolveIncremental resolves gqlReq, as Resolve does, and splits the response
+// into its initial payload and the parts that are delivered after it, as
+// gqlReq's @defer and @stream directives ask.  If there aren't any parts, the
+// response is the whole of it, just as from Resolve.
+func (r *RequestResolver) ResolveIncremental(ctx context.Context,
+	gqlReq *schema.Request) (*schema.Response, []*schema.Incremental) {
+
+	ctx = requestContext(ctx)
+	resp, op := r.resolve(ctx, gqlReq)
+	if op == nil || resp.Data.Len() == 0 {
+		return resp, nil
+	}
+	initial, parts, err := op.Incremental(resp.Data.Bytes())
+	if err != nil {
+		glog.Errorf("Sending the whole response to request %s: %s", RequestID(ctx), err)
+		return resp, nil
+	}
+	if parts == nil {
+		return resp, nil
+	}
+
+	// resp might be cached, so the initial payload is a response of its own.
+	split := &schema.Response{Errors: resp.Errors, Extensions: resp.Extensions, Cache: resp.Cache}
+	split.Data.Write(initial)
+	return split, parts
+}
+
+// resolve resolves gqlReq, returning its response and, if gqlReq was a valid
+// operation, the operation.
+func (r *RequestResolver) resolve(ctx context.Context,
+	gqlReq *schema.Request) (*schema.Response, schema.Operation) {
+
+	ctx = requestContext(ctx)
+	if r == nil {
+		glog.Error("Call to Resolve with nil RequestResolver")
+		return finishErrors(ctx, schema.ErrorResponse(errors.New("Internal error"))), nil
+	}
+
+	sch := r.Schema()
+	if sch == nil {
+		resp := schema.ErrorResponse(errors.New(
+			"There's no GraphQL schema set yet.  Use the /admin API to add one."))
+		withCode(resp.Errors, unavailableCode)
+		return finishErrors(ctx, resp), nil
+	}
+
+	ctx, span := otrace.StartSpan(ctx, "graphql.request")
+	defer span.End()
+	ctx, trace := apolloTrace(ctx, gqlReq)
+	ctx, explanation := r.explanation(ctx, gqlReq)
+	start := time.Now()
+
+	op, resp := r.operation(ctx, sch, gqlReq)
+	if resp == nil {
+		opCtx, cancel := r.withTimeout(ctx, op)
+		resp = r.resolveCached(opCtx, sch, gqlReq, op)
+		cancel()
+	}
+	resp = finishErrors(ctx, resp)
+	if op != nil && r.limits.ReportCost {
+		resp = withCost(resp, r.estimateCost(ctx, op))
+	}
+	r.requestLog.log(ctx, gqlReq, op, resp, time.Since(start), 0)
+	return withExplanation(withTrace(resp, trace), explanation), op
+}
+
+// apolloTrace starts the Apollo trace of gqlReq, in a copy of ctx, if gqlReq
+// asks for one.  Otherwise, the trace is nil.
+func apolloTrace(ctx context.Context,
+	gqlReq *schema.Request) (context.Context, *tracing.ApolloTrace) {
+
+	if on, _ := gqlReq.Extensions["tracing"].(bool); !on {
+		return ctx, nil
+	}
+	trace := tracing.NewApolloTrace()
+	return tracing.WithApolloTrace(ctx, trace), trace
+}
+
+// withTrace finishes trace and adds it to resp's extensions, if there is one.
+func withTrace(resp *schema.Response, trace *tracing.ApolloTrace) *schema.Response {
+	if trace == nil {
+		return resp
+	}
+	trace.Finish()
+	if resp.Extensions == nil {
+		resp.Extensions = make(map[string]interface{})
+	}
+	resp.Extensions["tracing"] = trace
+	return resp
+}
+
+// ResolveBatch resolves reqs, requests that were sent together, giving the
+// response to each.  Consecutive queries read the same snapshot of Dgraph; a
+// mutation starts a new snapshot, so the queries after it see its changes.
+// Mutations are resolved one at a time, in order, and so are queries, unless
+// r is WithConcurrentBatches.
+func (r *RequestResolver) ResolveBatch(ctx context.Context,
+	reqs []*schema.Request) []*schema.Response {
+
+	ctx = requestContext(ctx)
+	resps := make([]*schema.Response, len(reqs))
+	sch := r.Schema()
+	if sch == nil {
+		for i := range resps {
+			resps[i] = r.Resolve(ctx, reqs[i])
+		}
+		return resps
+	}
+
+	ctx, span := otrace.StartSpan(ctx, "graphql.batch")
+	defer span.End()
+	start := time.Now()
+
+	ops := make([]schema.Operation, len(reqs))
+	traces := make([]*tracing.ApolloTrace, len(reqs))
+	explanations := make([]*Explanation, len(reqs))
+	for i, req := range reqs {
+		var reqCtx context.Context
+		reqCtx, traces[i] = apolloTrace(ctx, req)
+		reqCtx, explanations[i] = r.explanation(reqCtx, req)
+		ops[i], resps[i] = r.operation(reqCtx, sch, req)
+	}
+
+	// traced is ctx with request i's timeout, and its Apollo trace and
+	// explanation, if it asked for them.  The timeouts are released once the
+	// batch is resolved.
+	cancels := make([]context.CancelFunc, len(reqs))
+	traced := func(ctx context.Context, i int) context.Context {
+		ctx, cancels[i] = r.withTimeout(ctx, ops[i])
+		ctx = explainedWith(ctx, explanations[i])
+		if traces[i] == nil {
+			return ctx
+		}
+		return tracing.WithApolloTrace(ctx, traces[i])
+	}
+	defer func() {
+		for _, cancel := range cancels {
+			if cancel != nil {
+				cancel()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	reads := dgraph.WithSharedReads(ctx)
+	for i, op := range ops {
+		switch {
+		case op == nil:
+			continue
+		case !op.IsQuery():
+			wg.Wait()
+			resps[i] = r.resolveOperation(traced(ctx, i), op)
+			reads = dgraph.WithSharedReads(ctx)
+		case r.concurrentBatches:
+			wg.Add(1)
+			go func(i int, op schema.Operation) {
+				defer wg.Done()
+				resps[i] = r.resolveCached(traced(reads, i), sch, reqs[i], op)
+			}(i, op)
+		default:
+			resps[i] = r.resolveCached(traced(reads, i), sch, reqs[i], op)
+		}
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	for i := range resps {
+		resps[i] = finishErrors(ctx, resps[i])
+		if ops[i] != nil && r.limits.ReportCost {
+			resps[i] = withCost(resps[i], r.estimateCost(ctx, ops[i]))
+		}
+		r.requestLog.log(ctx, reqs[i], ops[i], resps[i], elapsed, len(reqs))
+		resps[i] = withExplanation(withTrace(resps[i], traces[i]), explanations[i])
+	}
+	return resps
+}
+
+// operation finds the operation in gqlReq, expanding a persisted query, and
+// checks that the request can run it and that it's within r's limits.  If it
+// can't, the response to gqlReq is the error response.
+func (r *RequestResolver) operation(ctx context.Context, sch schema.Schema,
+	gqlReq *schema.Request) (schema.Operation, *schema.Response) {
+
+	gqlReq, notAllowed := r.persisted.expand(gqlReq)
+	if gqlReq == nil {
+		return nil, schema.ErrorResponse(notAllowed)
+	}
+	op, err := sch.OperationContext(ctx, gqlReq)
+	if notAllowed != nil && !(r.introspection && err == nil && isIntrospection(op)) {
+		return nil, schema.ErrorResponse(notAllowed)
+	}
+	if err != nil {
+		resp := schema.ErrorResponse(err)
+		withCode(resp.Errors, validationFailedCode)
+		return nil, resp
+	}
+	if !op.IsQuery() && readOnly(ctx) {
+		kind := "mutation"
+		if op.IsSubscription() {
+			kind = "subscription"
+		}
+		resp := schema.ErrorResponse(errors.Errorf(
+			"A %s can't be sent in a read-only request, like an HTTP GET.  Use POST.", kind))
+		withCode(resp.Errors, MethodNotAllowedCode)
+		return nil, resp
+	}
+	if errResp := r.authenticate(ctx, op); errResp != nil {
+		return nil, errResp
+	}
+	if errResp := r.introspectionPolicy.check(ctx, op); errResp != nil {
+		return nil, errResp
+	}
+	if errs := r.limits.check(op); errs != nil {
+		return nil, &schema.Response{Errors: errs}
+	}
+	if errResp := r.checkCost(ctx, op); errResp != nil {
+		return nil, errResp
+	}
+	if errResp := r.rateLimiter.checkWrite(ctx, op); errResp != nil {
+		return nil, errResp
+	}
+	return op, nil
+}
+
+// isIntrospection returns true if op only asks about the schema.
+func isIntrospection(op schema.Operation) bool {
+	if !op.IsQuery() || len(op.Queries()) == 0 {
+		return false
+	}
+	for _, q := range op.Queries() {
+		if q.QueryType() != schema.SchemaQuery {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveCached resolves op, the operation in gqlReq.  If op's response can
+// be cached, it's answered from r's cache when it can be, and otherwise
+// cached once it's resolved.  Operations that are being explained are always
+// resolved, so that there's something to explain.
+func (r *RequestResolver) resolveCached(ctx context.Context, sch schema.Schema,
+	gqlReq *schema.Request, op schema.Operation) *schema.Response {
+
+	policy := cachePolicy(ctx, op)
+	if policy == nil || explanationFrom(ctx) != nil {
+		return r.resolveOperation(ctx, op)
+	}
+
+	key := cacheKey(ctx, gqlReq)
+	resp, gen := r.cache.get(sch, key)
+	if resp != nil {
+		return resp
+	}
+	resp = r.resolveOperation(ctx, op)
+	if len(resp.Errors) == 0 {
+		resp.Cache = policy
+		r.cache.put(sch, gen, key, resp)
+	}
+	return resp
+}
+
+func (r *RequestResolver) resolveOperation(ctx context.Context,
+	op schema.Operation) *schema.Response {
+
+	resp := &schema.Response{}
+	switch {
+	case op.IsQuery():
+		// The queries' @custom calls are only made once each, however many
+		// times they're needed.
+		r.resolveQueries(withCallLoader(ctx), op.Queries(), resp)
+	case op.IsMutation() && op.Atomic():
+		r.resolveAtomic(ctx, op.Mutations(), resp)
+		r.mutated()
+	case op.IsMutation():
+		for _, m := range op.Mutations() {
+			mctx, end := startResolver(ctx, m)
+			var res *resolved
+			if fn, ok := r.fieldResolvers[m.Name()]; ok {
+				res = resolveWith(mctx, m, fn)
+			} else {
+				res = r.mutationResolver(m).resolve(mctx)
+			}
+			end()
+			res.addTo(resp)
+		}
+		r.mutated()
+	case op.IsSubscription():
+		resp.WithError(errors.New("Subscriptions are only served over WebSockets"))
+		withCode(resp.Errors, BadRequestCode)
+	}
+
+	return resp
+}
+
+// mutated tells subscriptions to take another look, and clears the cached
+// responses, after a mutation operation.  Even a mutation that failed might
+// have changed something, so they aren't trusted.
+func (r *RequestResolver) mutated() {
+	r.changes.notify()
+	r.cache.clear()
+}
+
+func (r *RequestResolver) mutationResolver(m schema.Mutation) *mutationResolver {
+	return &mutationResolver{
+		mutation:     m,
+		dgraphClient: r.dgraphClient,
+		retries:      r.retries.Writes,
+		files:        r.files,
+		remoteClient: r.remoteClient,
+		secrets:      r.secrets,
+		lambdaURL:    r.lambdaURL,
+		lambdaClient: r.lambdaClient,
+		signed:       r.signed,
+		audit:        r.auditLog,
+		events:       r.events,
+		changes:      r.changeCapture,
+	}
+}
+
+// resolveQueries resolves queries concurrently, adding the results to resp in
+// the order of queries.
+func (r *RequestResolver) resolveQueries(ctx context.Context, queries []schema.Query,
+	resp *schema.Response) {
+
+	results := make([]*resolved, len(queries))
+
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q schema.Query) {
+			defer wg.Done()
+			ctx, end := startResolver(ctx, q)
+			defer end()
+			if fn, ok := r.fieldResolvers[q.Name()]; ok {
+				results[i] = resolveWith(ctx, q, fn)
+				return
+			}
+			qr := &queryResolver{
+				query:        q,
+				dgraphClient: &retryingReads{Client: r.dgraphClient, policy: r.retries.Reads},
+				remoteClient: r.remoteClient,
+				secrets:      r.secrets,
+				lambdaURL:    r.lambdaURL,
+				lambdaClient: r.lambdaClient,
+				signed:       r.signed,
+			}
+			results[i] = qr.resolve(ctx)
+		}(i, q)
+	}
+	wg.Wait()
+
+	for _, res := range results {
+		res.addTo(resp)
+	}
+}
+
+// startResolver starts resolving field, a query or mutation, as a span that's
+// a child of ctx's and in the request's Apollo trace.  It returns the context
+// of the span and a func that ends it.
+func startResolver(ctx context.Context, field schema.Field) (context.Context, func()) {
+	return tracing.StartResolver(ctx, []interface{}{field.ResponseName()},
+		field.GetObjectName(), field.Name(), field.Type().String())
+}
+
+// resolveTypename resolves __typename at the root of an operation, which is
+// the name of the root operation type.
+func resolveTypename(ctx context.Context, field schema.Field) (interface{}, error) {
+	return field.GetObjectName(), nil
+}
+
+// resolveWith resolves field with fn and completes the result.
+func resolveWith(ctx context.Context, field schema.Field, fn FieldResolverFunc) *resolved {
+	val, err := fn(ctx, field)
+	if err != nil {
+		null, _ := completeField(field, nil)
+		return &resolved{data: null, err: fieldErrors(field, err)}
+	}
+
+	data, errs := completeField(field, aliased(field.SelectionSet(), val))
+	if len(errs) > 0 {
+		return &resolved{data: data, err: errs}
+	}
+	return &resolved{data: data}
+}
+
+// aliased rekeys the objects in val from field names to the response names
+// that fields ask for, which is how Dgraph results are keyed.
+func aliased(fields []schema.Field, val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			if fv, ok := v[f.Name()]; ok {
+				res[f.ResponseName()] = aliased(f.SelectionSet(), fv)
+			}
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = aliased(fields, item)
+		}
+		return res
+	default:
+		return val
+	}
+}
+
+// completeDgraphResult takes the JSON result of a Dgraph query that was
+// built for field and completes it into the GraphQL result for field.  The
+// result is a JSON fragment `"responseName": value`.
+func completeDgraphResult(field schema.Field, dgResult []byte) ([]byte, gqlerror.List) {
+	res, errs := decodeDgraphResult(field, dgResult)
+	if errs != nil {
+		return nil, errs
+	}
+	return completeField(field, res[field.ResponseName()])
+}
+
+// decodeDgraphResult decodes the JSON result of a Dgraph query that was built
+// for field.
+func decodeDgraphResult(field schema.Field, dgResult []byte) (map[string]interface{},
+	gqlerror.List) {
+
+	var res map[string]interface{}
+	if len(dgResult) > 0 {
+		// Numbers are kept as they came from Dgraph, rather than being
+		// converted to float64 and back.
+		dec := json.NewDecoder(bytes.NewReader(dgResult))
+		dec.UseNumber()
+		if err := dec.Decode(&res); err != nil {
+			return nil, gqlerror.List{&gqlerror.Error{
+				Message:   "Couldn't unmarshal response from Dgraph: " + err.Error(),
+				Locations: []gqlerror.Location{*field.Location()},
+			}}
+		}
+	}
+	return res, nil
+}
+
+// completeField completes val as the value of field, giving the JSON fragment
+// `"responseName": value`.  If field is non-nullable and val can't be
+// completed, the result is nil: the error propagates to the whole of the
+// data.
+func completeField(field schema.Field, val interface{}) ([]byte, gqlerror.List) {
+	path := []interface{}{field.ResponseName()}
+	completed, errs := completeValue(path, field, field.Type(), val)
+	if completed == nil {
+		return nil, errs
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(strconv.Quote(field.ResponseName()))
+	buf.WriteString(": ")
+	buf.Write(completed)
+
+	return buf.Bytes(), errs
+}
+
+// A failedValue stands in a result for a field that couldn't be resolved, or
+// could only be partly resolved into val, so that completing the result
+// reports errs where the field is.
+type failedValue struct {
+	val  interface{}
+	errs gqlerror.List
+}
+
+// completeValue completes val as a value of type typ for field.  If val can't
+// be completed - e.g. because it's null and typ is non-nullable - the result
+// is nil and the error propagates up to the nearest nullable parent, as per
+// the GraphQL spec.
+func completeValue(path []interface{}, field schema.Field, typ schema.Type,
+	val interface{}) ([]byte, gqlerror.List) {
+
+	switch v := val.(type) {
+	case *failedValue:
+		if v.val == nil {
+			return failed(typ, v.errs)
+		}
+		completed, errs := completeValue(path, field, typ, v.val)
+		return completed, append(v.errs, errs...)
+	case map[string]interface{}:
+		if typ.ListType() != nil {
+			return completeList(path, field, typ, []interface{}{v})
+		}
+		typName, fields := objectType(field, typ, v)
+		if typ.IsEdge() {
+			v = asEdge(fields, v)
+		}
+		completed, errs := completeObject(path, typName, fields, v)
+		if completed == nil && typ.Nullable() {
+			return []byte("null"), errs
+		}
+		return completed, errs
+	case []interface{}:
+		if typ.ListType() != nil {
+			return completeList(path, field, typ, v)
+		}
+
+		// Dgraph returns a list for edges that GraphQL treats as a single
+		// object (and for the root of get queries).
+		switch len(v) {
+		case 0:
+			return completeValue(path, field, typ, nil)
+		case 1:
+			return completeValue(path, field, typ, v[0])
+		default:
+			return failed(typ, gqlerror.List{fieldError(path, field,
+				"A list was returned, but GraphQL was expecting just one item.")})
+		}
+	case nil:
+		if !typ.Nullable() {
+			return nil, gqlerror.List{fieldError(path, field, fmt.Sprintf(
+				"Non-nullable field '%s' (type %s) was not present in result from Dgraph.  "+
+					"GraphQL error propagation triggered.", field.Name(), typ))}
+		}
+		return []byte("null"), nil
+	default:
+		if typ.ListType() != nil {
+			return completeList(path, field, typ, []interface{}{v})
+		}
+		if s, ok := v.(string); ok && typ.Name() == schema.VectorType && json.Valid([]byte(s)) {
+			// Vectors are stored as JSON.
+			return []byte(s), nil
+		}
+		if n, ok := v.(json.Number); ok && typ.Name() == "Int" {
+			// Dgraph's math() always gives a Float, so @computed Int fields
+			// are the whole part of that.
+			if _, err := n.Int64(); err != nil {
+				if f, err := n.Float64(); err == nil {
+					v = int64(f)
+				}
+			}
+		}
+
+		js, err := json.Marshal(v)
+		if err != nil {
+			return failed(typ, gqlerror.List{fieldError(path, field, err.Error())})
+		}
+		return js, nil
+	}
+}
+
+// failed completes a value of type typ that couldn't be resolved because of
+// errs.  It's null, unless typ is non-nullable, in which case the result is
+// nil and the error propagates.
+func failed(typ schema.Type, errs gqlerror.List) ([]byte, gqlerror.List) {
+	if typ.Nullable() {
+		return []byte("null"), errs
+	}
+	return nil, errs
+}
+
+func completeList(path []interface{}, field schema.Field, typ schema.Type,
+	vals []interface{}) ([]byte, gqlerror.List) {
+
+	var errs gqlerror.List
+	var buf bytes.Buffer
+	buf.WriteRune('[')
+	for i, val := range vals {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		completed, err := completeValue(append(path, i), field, typ.ListType(), val)
+		errs = append(errs, err...)
+		if completed == nil {
+			// A non-nullable item was null, so the whole list is null.
+			if typ.Nullable() {
+				return []byte("null"), errs
+			}
+			return nil, errs
+		}
+		buf.Write(completed)
+	}
+	buf.WriteRune(']')
+
+	return buf.Bytes(), errs
+}
+
+// objectType works out the type of obj, a value of field with type typ, and
+// the fields asked for on it.  If typ is an interface, that's the type in
+// obj's dgraph.type that implements it.  Values that don't say, such as those
+// from @custom calls, are completed with the fields asked for on the
+// interface.
+func objectType(field schema.Field, typ schema.Type,
+	obj map[string]interface{}) (string, []schema.Field) {
+
+	if !isAbstract(typ) {
+		return typ.Name(), field.SelectionSet()
+	}
+
+	var types []interface{}
+	switch t := obj[dgraphTypePredicate].(type) {
+	case string:
+		types = []interface{}{t}
+	case []interface{}:
+		types = t
+	}
+	for _, typName := range typ.PossibleTypes() {
+		for _, t := range types {
+			if t == typName {
+				return typName, field.SelectionSetFor(typName)
+			}
+		}
+	}
+	return typ.Name(), field.SelectionSet()
+}
+
+// completeObject completes res as an object of type typName with fields.  If
+// a field can't be completed, or has errors and its error policy is to abort
+// its object, the result is nil.
+func completeObject(path []interface{}, typName string, fields []schema.Field,
+	res map[string]interface{}) ([]byte, gqlerror.List) {
+
+	var errs gqlerror.List
+	var buf bytes.Buffer
+	buf.WriteRune('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(strconv.Quote(f.ResponseName()))
+		buf.WriteString(": ")
+
+		if f.Name() == "__typename" {
+			buf.WriteString(strconv.Quote(typName))
+			continue
+		}
+
+		completed, err := completeValue(
+			append(path, f.ResponseName()), f, f.Type(), res[f.ResponseName()])
+		errs = append(errs, err...)
+		if completed == nil || (len(err) > 0 && f.ErrorPolicy() == schema.AbortObject) {
+			return nil, errs
+		}
+		buf.Write(completed)
+	}
+	buf.WriteRune('}')
+
+	return buf.Bytes(), errs
+}
+
+// UnauthenticatedCode is the error code, in the error's extensions, for
+// operations that anonymous requests aren't allowed to run, and for requests
+// with tokens that aren't valid.
+const UnauthenticatedCode = "UNAUTHENTICATED"
+
+// authenticate returns an error response if op can't be run because the
+// request isn't authenticated, and nil if it can go ahead.  Every query,
+// mutation or subscription in op has to be allowed.
+func (r *RequestResolver) authenticate(ctx context.Context, op schema.Operation) *schema.Response {
+	var kind, plural string
+	var names []string
+	switch {
+	case op.IsQuery():
+		kind, plural = authorization.Query, "queries"
+		for _, q := range op.Queries() {
+			names = append(names, q.Name())
+		}
+	case op.IsMutation():
+		kind, plural = authorization.Mutation, "mutations"
+		for _, m := range op.Mutations() {
+			names = append(names, m.Name())
+		}
+	default:
+		kind, plural = authorization.Subscription, "subscriptions"
+		for _, s := range op.Subscriptions() {
+			names = append(names, s.Name())
+		}
+	}
+
+	for _, name := range names {
+		if r.anonymous.Allows(ctx, kind, name) {
+			continue
+		}
+		what := plural
+		if r.anonymous.Denies(name) {
+			what = name
+		}
+		return &schema.Response{Errors: gqlerror.List{{
+			Message:    "A valid JWT is required to run " + what,
+			Extensions: map[string]interface{}{"code": UnauthenticatedCode},
+		}}}
+	}
+	return nil
+}
+
+// unavailableCode is the error code, in the error's extensions, for errors
+// that happened because Dgraph couldn't be reached.
+const unavailableCode = "UNAVAILABLE"
+
+// fieldErrors turns err, from resolving field, into GraphQL errors that are
+// located at field, and have its path unless they say otherwise.  If Dgraph
+// couldn't be reached, the error has the code UNAVAILABLE, so clients can
+// tell it's worth trying again.  A failed call to an external endpoint, an
+// update that found an object at a version it didn't expect, a mutation
+// whose condition failed, or one that @auth rules didn't allow, has the
+// details in its extensions.  Other errors from Dgraph are a CONFLICT, if
+// the transaction was aborted, or a DGRAPH_ERROR; a field that ran out of
+// time is a TIMEOUT.
+func fieldErrors(field schema.Field, err error) gqlerror.List {
+	_, unavailable := errors.Cause(err).(*dgraph.UnavailableError)
+	detailed, _ := errors.Cause(err).(interface {
+		Extensions() map[string]interface{}
+	})
+	errs := schema.AsGQLErrors(err)
+	for _, e := range errs {
+		if len(e.Locations) == 0 {
+			e.Locations = []gqlerror.Location{*field.Location()}
+		}
+		if len(e.Path) == 0 {
+			e.Path = []interface{}{field.ResponseName()}
+		}
+		if unavailable {
+			if e.Extensions == nil {
+				e.Extensions = make(map[string]interface{})
+			}
+			e.Extensions["code"] = unavailableCode
+		}
+		if detailed != nil && e.Extensions == nil {
+			e.Extensions = detailed.Extensions()
+		}
+	}
+	if code := errorCode(err); code != "" {
+		withCode(errs, code)
+	}
+	return errs
+}
+
+func fieldError(path []interface{}, field schema.Field, msg string) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:   msg,
+		Path:      append([]interface{}(nil), path...),
+		Locations: []gqlerror.Location{*field.Location()},
+	}
+}
+
+// MethodNotAllowedCode is the error code, in the error's extensions, for
+// operations that change data in requests that can only read, like HTTP GET
+// requests.
+const MethodNotAllowedCode = "METHOD_NOT_ALLOWED"
+
+type readOnlyKey struct{}
+
+// WithReadOnly returns a copy of ctx in which only queries can be resolved,
+// e.g. for an HTTP GET request, which links, prefetchers and caches can send
+// without anyone meaning to change anything.  Other operations are refused
+// with a METHOD_NOT_ALLOWED error.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// readOnly returns true if only queries can be resolved in ctx.
+func readOnly(ctx context.Context) bool {
+	only, _ := ctx.Value(readOnlyKey{}).(bool)
+	return only
+}
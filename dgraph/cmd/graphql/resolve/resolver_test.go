@@ -0,0 +1,246 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/graphql/tracing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+func TestCompleteDgraphResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		dgResult string
+		expected string
+		errPaths [][]interface{}
+	}{
+		{
+			name:     "get query returns a single object",
+			query:    `query { getAuthor(id: "0x1") { name, __typename } }`,
+			dgResult: `{"getAuthor": [{"name": "A.N. Author"}]}`,
+			expected: `"getAuthor": {"name": "A.N. Author", "__typename": "Author"}`,
+		},
+		{
+			name:     "missing nullable fields are null",
+			query:    `query { getAuthor(id: "0x1") { name, dob } }`,
+			dgResult: `{"getAuthor": [{"name": "A.N. Author"}]}`,
+			expected: `"getAuthor": {"name": "A.N. Author", "dob": null}`,
+		},
+		{
+			name:     "numbers are kept as they are",
+			query:    `query { queryAuthor { name } }`,
+			dgResult: `{"queryAuthor": [{"name": 12345678901234}]}`,
+			expected: `"queryAuthor": [{"name": 12345678901234}]`,
+		},
+		{
+			name:     "missing non-nullable field nulls the nearest nullable parent",
+			query:    `query { queryPost { title, author { name } } }`,
+			dgResult: `{"queryPost": [{"title": "T1", "author": [{}]}]}`,
+			expected: `"queryPost": [null]`,
+			errPaths: [][]interface{}{{"queryPost", 0, "author", "name"}},
+		},
+		{
+			name:     "no result",
+			query:    `query { getAuthor(id: "0x1") { name } }`,
+			dgResult: `{"getAuthor": []}`,
+			expected: `"getAuthor": null`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			op := operationFor(t, testSchema, test.query)
+			require.Len(t, op.Queries(), 1)
+
+			data, errs := completeDgraphResult(op.Queries()[0], []byte(test.dgResult))
+			require.Equal(t, test.expected, string(data))
+
+			require.Len(t, errs, len(test.errPaths))
+			for i, path := range test.errPaths {
+				require.Equal(t, path, errs[i].Path)
+			}
+		})
+	}
+}
+
+const errorPolicySchema = `
+type Author {
+	id: ID!
+	name: String!
+	bio: String
+	country: String @onError(policy: ABORT_OBJECT)
+}
+
+type Post @onError(policy: ABORT_OBJECT) {
+	id: ID!
+	title: String!
+	score: Int
+	tag: String @onError(policy: NULL_FIELD)
+}
+`
+
+func TestErrorPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		dgResult string
+		expected string
+	}{
+		{
+			name:     "a field that fails is null by default",
+			query:    `query { queryAuthor { name bio } }`,
+			dgResult: `{"queryAuthor": [{"name": "A", "bio": ["x", "y"]}]}`,
+			expected: `"queryAuthor": [{"name": "A", "bio": null}]`,
+		},
+		{
+			name:     "ABORT_OBJECT nulls the field's object",
+			query:    `query { queryAuthor { name country } }`,
+			dgResult: `{"queryAuthor": [{"name": "A", "country": ["x", "y"]}]}`,
+			expected: `"queryAuthor": [null]`,
+		},
+		{
+			name:     "a type's policy is its fields' policy",
+			query:    `query { queryPost { title score } }`,
+			dgResult: `{"queryPost": [{"title": "T", "score": [1, 2]}]}`,
+			expected: `"queryPost": [null]`,
+		},
+		{
+			name:     "a field's policy overrides its type's",
+			query:    `query { queryPost { title tag } }`,
+			dgResult: `{"queryPost": [{"title": "T", "tag": ["x", "y"]}]}`,
+			expected: `"queryPost": [{"title": "T", "tag": null}]`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			op := operationFor(t, errorPolicySchema, test.query)
+
+			data, errs := completeDgraphResult(op.Queries()[0], []byte(test.dgResult))
+			require.Equal(t, test.expected, string(data))
+			require.Len(t, errs, 1)
+			require.Equal(t, "A list was returned, but GraphQL was expecting just one item.",
+				errs[0].Message)
+		})
+	}
+}
+
+func TestUnavailableErrors(t *testing.T) {
+	client := &mockDgraph{
+		mutateErr: &dgraph.UnavailableError{Err: errors.New("connection refused")},
+	}
+	res := resolveMutationFor(t, testSchema, client, `mutation {
+		addPost(input: [{title: "T", author: {id: "0x1"}}]) {
+			post { title }
+		}
+	}`)
+
+	errs := schema.AsGQLErrors(res.err)
+	require.Len(t, errs, 1)
+	require.Equal(t, "Dgraph is unavailable, try again later", errs[0].Message)
+	require.Equal(t, map[string]interface{}{"code": "UNAVAILABLE"}, errs[0].Extensions)
+	require.Equal(t, `"addPost": null`, string(res.data))
+}
+
+const propagationSchema = `
+type Author {
+	id: ID!
+	name: String!
+}
+
+type Query {
+	motd: String! @lambda
+	tip: String @lambda
+}
+`
+
+func TestErrorPropagation(t *testing.T) {
+	fail := func(ctx context.Context, field schema.Field) (interface{}, error) {
+		return nil, errors.New("no " + field.Name() + " today")
+	}
+	client := &mockDgraph{results: []string{`{"getAuthor": [{"name": "A"}]}`}}
+	r := resolverFor(t, propagationSchema, client).
+		WithFieldResolver("motd", fail).
+		WithFieldResolver("tip", fail)
+
+	// A nullable root field that fails is null, and the others still have
+	// their data.
+	resp := r.Resolve(context.Background(), &schema.Request{
+		Query: `query { getAuthor(id: "0x1") { name } tip }`})
+	require.JSONEq(t, `{"getAuthor": {"name": "A"}, "tip": null}`, resp.Data.String())
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "no tip today", resp.Errors[0].Message)
+	require.Equal(t, []interface{}{"tip"}, resp.Errors[0].Path)
+	require.Equal(t, []gqlerror.Location{{Line: 1, Column: 39}}, resp.Errors[0].Locations)
+
+	// A non-nullable one makes the data null.
+	client.results = []string{`{"getAuthor": [{"name": "A"}]}`}
+	resp = r.Resolve(WithRequestID(context.Background(), "req-1"), &schema.Request{
+		Query: `query { getAuthor(id: "0x1") { name } motd }`})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, []interface{}{"motd"}, resp.Errors[0].Path)
+
+	var buf bytes.Buffer
+	_, err := resp.WriteTo(&buf)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"errors": [{
+			"message": "no motd today",
+			"path": ["motd"],
+			"locations": [{"line": 1, "column": 39}],
+			"extensions": {"code": "FIELD_ERROR", "requestId": "req-1"}
+		}],
+		"data": null
+	}`, buf.String())
+}
+
+func TestApolloTracing(t *testing.T) {
+	resolver := resolverFor(t, testSchema, &mockDgraph{})
+	query := `query { a: getAuthor(id: "0x1") { name } }`
+
+	resp := resolver.Resolve(context.Background(), &schema.Request{Query: query})
+	require.Empty(t, resp.Errors)
+	require.NotContains(t, resp.Extensions, "tracing", "tracing is only sent when asked for")
+
+	resp = resolver.Resolve(context.Background(), &schema.Request{
+		Query:      query,
+		Extensions: map[string]interface{}{"tracing": true},
+	})
+	require.Empty(t, resp.Errors)
+	trace, ok := resp.Extensions["tracing"].(*tracing.ApolloTrace)
+	require.True(t, ok)
+	require.Equal(t, 1, trace.Version)
+	require.NotNil(t, trace.Parsing)
+	require.NotNil(t, trace.Validation)
+	require.True(t, trace.EndTime.After(trace.StartTime))
+
+	require.Len(t, trace.Execution.Resolvers, 1)
+	resolved := trace.Execution.Resolvers[0]
+	require.Equal(t, []interface{}{"a"}, resolved.Path)
+	require.Equal(t, "Query", resolved.ParentType)
+	require.Equal(t, "getAuthor", resolved.FieldName)
+	require.Equal(t, "Author", resolved.ReturnType)
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/dgo/y"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retriesExhaustedCode is the error code, in the error's extensions, for
+// errors that were retried as many times as the RetryPolicy allows.
+const retriesExhaustedCode = "RETRIES_EXHAUSTED"
+
+// A RetryPolicy says how reads or writes are retried when Dgraph fails in a
+// way that's likely to pass: a transaction that was aborted because it
+// conflicted with another, or a call that couldn't reach Dgraph.  After a
+// failed attempt, the next waits InitialBackoff, and each after that waits
+// twice as long as the one before, up to MaxBackoff.
+type RetryPolicy struct {
+	// MaxAttempts is how many times, in all, something is tried.  0 or 1
+	// means it isn't retried.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait before a retry; 0 is no cap.
+	MaxBackoff time.Duration
+}
+
+// Retries are the RetryPolicy for each kind of operation.  Reads are the
+// Dgraph queries of GraphQL queries and subscriptions; each is retried on
+// its own.  Writes are mutations: the whole of a mutation's transaction, or
+// an @atomic operation's, is run again.  A write whose commit didn't reach
+// Dgraph isn't retried, because it might have been committed.
+type Retries struct {
+	Reads  RetryPolicy
+	Writes RetryPolicy
+}
+
+// DefaultRetries is how a RequestResolver retries, unless it's set
+// WithRetries.
+var DefaultRetries = Retries{
+	Reads:  defaultRetryPolicy,
+	Writes: defaultRetryPolicy,
+}
+
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 50 * time.Millisecond,
+	MaxBackoff:     time.Second,
+}
+
+// RetriesExhaustedError is the error from something that failed in a way
+// that's usually transient on every attempt that its RetryPolicy allowed.
+type RetriesExhaustedError struct {
+	// Attempts is how many times it was tried.
+	Attempts int
+
+	// Reason is the code for why the last attempt failed: CONFLICT or
+	// UNAVAILABLE.
+	Reason string
+
+	// Err is the error from the last attempt.
+	Err error
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts: %s", e.Attempts, e.Err)
+}
+
+// Extensions gives the details of the error for the extensions of a GraphQL
+// error.
+func (e *RetriesExhaustedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":     retriesExhaustedCode,
+		"reason":   e.Reason,
+		"attempts": e.Attempts,
+	}
+}
+
+// transient returns the code for why err happened, if it's an error that's
+// worth retrying, and "" otherwise.
+func transient(err error) string {
+	cause := errors.Cause(err)
+	if _, ok := cause.(*dgraph.UnavailableError); ok {
+		return unavailableCode
+	}
+	switch {
+	case cause == nil:
+		return ""
+	case cause == y.ErrAborted:
+		return conflictCode
+	}
+	switch status.Code(cause) {
+	case codes.Aborted:
+		return conflictCode
+	case codes.Unavailable:
+		return unavailableCode
+	}
+	return ""
+}
+
+// finalError is an error that mustn't be retried, even if it looks
+// transient.
+type finalError struct {
+	err error
+}
+
+func (e *finalError) Error() string {
+	return e.err.Error()
+}
+
+// retry runs attempt until it succeeds, fails with an error that isn't
+// transient, ctx is done, or p's attempts run out; then, the error is a
+// *RetriesExhaustedError.  attempt can return a *finalError to stop.
+func (p RetryPolicy) retry(ctx context.Context, attempt func() error) error {
+	backoff := p.InitialBackoff
+	for n := 1; ; n++ {
+		err := attempt()
+		if final, ok := err.(*finalError); ok {
+			return final.err
+		}
+		reason := transient(err)
+		switch {
+		case reason == "" || p.MaxAttempts <= 1:
+			return err
+		case n >= p.MaxAttempts:
+			return &RetriesExhaustedError{Attempts: n, Reason: reason, Err: err}
+		}
+
+		glog.V(2).Infof("Retrying after %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// transaction runs run in a new transaction of client and commits it,
+// retrying both as p says.  If the commit fails because Dgraph couldn't be
+// reached, it isn't retried: the transaction might have been committed.
+func (p RetryPolicy) transaction(ctx context.Context, client dgraph.Client,
+	run func(txn dgraph.Txn) error) error {
+
+	return p.retry(ctx, func() error {
+		txn := client.NewTxn()
+		defer txn.Discard(ctx)
+
+		if err := run(txn); err != nil {
+			return err
+		}
+		err := txn.Commit(ctx)
+		if transient(err) == unavailableCode {
+			return &finalError{err: err}
+		}
+		return err
+	})
+}
+
+// retryingReads is a dgraph.Client whose queries are retried as policy says.
+// The queries in its transactions aren't: they're retried with the whole
+// transaction.
+type retryingReads struct {
+	dgraph.Client
+	policy RetryPolicy
+}
+
+func (c *retryingReads) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	var resp []byte
+	err := c.policy.retry(ctx, func() error {
+		var err error
+		resp, err = c.Client.Query(ctx, query)
+		return err
+	})
+	return resp, err
+}
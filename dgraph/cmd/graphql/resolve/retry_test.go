@@ -0,0 +1,137 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgo/y"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyDgraph fails the first failQueries queries, failMutations mutations
+// and failCommits commits with err.
+type flakyDgraph struct {
+	countingDgraph
+	err           error
+	failQueries   int
+	failMutations int
+	failCommits   int
+}
+
+func (f *flakyDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	if f.failQueries > 0 {
+		f.failQueries--
+		return nil, f.err
+	}
+	return f.countingDgraph.Query(ctx, query)
+}
+
+func (f *flakyDgraph) NewTxn() dgraph.Txn {
+	f.txns++
+	return f
+}
+
+func (f *flakyDgraph) Mutate(ctx context.Context, mut *api.Mutation) (map[string]string, error) {
+	if f.failMutations > 0 {
+		f.failMutations--
+		return nil, f.err
+	}
+	return f.countingDgraph.Mutate(ctx, mut)
+}
+
+func (f *flakyDgraph) Commit(ctx context.Context) error {
+	if f.failCommits > 0 {
+		f.failCommits--
+		return f.err
+	}
+	return f.countingDgraph.Commit(ctx)
+}
+
+var testRetries = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+func resolveFlaky(t *testing.T, client *flakyDgraph, query string) *schema.Response {
+	return resolverFor(t, errorsSchema, client).
+		WithRetries(Retries{Reads: testRetries, Writes: testRetries}).
+		Resolve(context.Background(), &schema.Request{Query: query})
+}
+
+const addNote = `mutation { addNote(input: [{text: "A"}]) { note { text } } }`
+
+func TestRetryAbortedMutation(t *testing.T) {
+	client := &flakyDgraph{err: y.ErrAborted, failMutations: 2}
+	client.assigned = map[string]string{"Note1": "0x1"}
+	client.results = []string{`{"note": [{"text": "A"}]}`}
+
+	resp := resolveFlaky(t, client, addNote)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"addNote": {"note": [{"text": "A"}]}}`, resp.Data.String())
+	require.Equal(t, 3, client.txns)
+	require.Equal(t, 1, client.commits)
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	client := &flakyDgraph{err: y.ErrAborted, failMutations: 5}
+
+	resp := resolveFlaky(t, client, addNote)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "gave up after 3 attempts: "+y.ErrAborted.Error(), resp.Errors[0].Message)
+	require.Equal(t, retriesExhaustedCode, resp.Errors[0].Extensions["code"])
+	require.Equal(t, conflictCode, resp.Errors[0].Extensions["reason"])
+	require.Equal(t, 3, resp.Errors[0].Extensions["attempts"])
+	require.Equal(t, 3, client.txns)
+	require.Equal(t, 0, client.commits)
+}
+
+func TestNoRetryAfterUnavailableCommit(t *testing.T) {
+	client := &flakyDgraph{
+		err:         status.Error(codes.Unavailable, "connection refused"),
+		failCommits: 1,
+	}
+	client.assigned = map[string]string{"Note1": "0x1"}
+
+	resp := resolveFlaky(t, client, addNote)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, unavailableCode, resp.Errors[0].Extensions["code"])
+	require.Equal(t, 1, client.txns, "the commit might have gone through")
+}
+
+func TestRetryReads(t *testing.T) {
+	client := &flakyDgraph{
+		err:         status.Error(codes.Unavailable, "connection refused"),
+		failQueries: 2,
+	}
+	client.results = []string{`{"getNote": [{"text": "A"}]}`}
+
+	resp := resolveFlaky(t, client, `query { getNote(id: "0x1") { text } }`)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"getNote": {"text": "A"}}`, resp.Data.String())
+
+	client.failQueries = 3
+	resp = resolveFlaky(t, client, `query { getNote(id: "0x1") { text } }`)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, retriesExhaustedCode, resp.Errors[0].Extensions["code"])
+	require.Equal(t, unavailableCode, resp.Errors[0].Extensions["reason"])
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const reverseSchema = `
+type Author {
+	id: ID!
+	name: String!
+	posts: [Post] @hasInverse(field: author, reverse: true)
+}
+
+type Post {
+	postID: ID!
+	title: String!
+	author: Author
+}
+`
+
+func TestReverseQuery(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"getAuthor": [{"name": "A", "posts": [{"title": "P"}]}]}`}}
+	resp := resolverFor(t, reverseSchema, client).Resolve(context.Background(), &schema.Request{
+		Query: `query { getAuthor(id: "0x1") { name posts { title } } }`,
+	})
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, []string{`query {
+  getAuthor(func: uid(0x1)) @filter(type(Author)) {
+    name : Author.name
+    posts : ~Post.author {
+      title : Post.title
+    }
+  }
+}`}, client.queries)
+	require.JSONEq(t, `{"getAuthor": {"name": "A", "posts": [{"title": "P"}]}}`,
+		resp.Data.String())
+}
+
+func TestReverseAddMutation(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Author1": "0x1", "Post2": "0x3"},
+		results:  []string{`{"author": [{"name": "A"}]}`},
+	}
+	res := resolveMutationFor(t, reverseSchema, client, `mutation {
+		addAuthor(input: [{name: "A", posts: [{postID: "0x2"}, {title: "New"}]}]) {
+			author { name }
+		}
+	}`)
+	require.NoError(t, res.err)
+
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `[
+		{"uid": "_:Author1", "dgraph.type": "Author", "Author.name": "A"},
+		{"uid": "0x2", "Post.author": {"uid": "_:Author1"}},
+		{"uid": "_:Post2", "dgraph.type": "Post", "Post.title": "New",
+			"Post.author": {"uid": "_:Author1"}}
+	]`, string(client.mutations[0].SetJson))
+
+	// The forward side doesn't write the reverse edge; Dgraph keeps it.
+	client = &mockDgraph{
+		assigned: map[string]string{"Post1": "0x2"},
+		results:  []string{`{"post": [{"title": "P"}]}`},
+	}
+	res = resolveMutationFor(t, reverseSchema, client, `mutation {
+		addPost(input: [{title: "P", author: {id: "0x1"}}]) { post { title } }
+	}`)
+	require.NoError(t, res.err)
+	require.JSONEq(t, `[{"uid": "_:Post1", "dgraph.type": "Post", "Post.title": "P",
+		"Post.author": {"uid": "0x1"}}]`, string(client.mutations[0].SetJson))
+}
+
+func TestReverseUpdateAndDelete(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"updateAuthor": [{"uid": "0x1"}]}`,
+		`{"author": [{"name": "A"}]}`,
+	}}
+	res := resolveMutationFor(t, reverseSchema, client, `mutation {
+		updateAuthor(input: {
+			filter: { id: ["0x1"] },
+			set: { posts: [{postID: "0x2"}] },
+			remove: { posts: [{postID: "0x3"}] }
+		}) {
+			author { name }
+		}
+	}`)
+	require.NoError(t, res.err)
+
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `[{"uid": "0x1"}, {"uid": "0x2", "Post.author": {"uid": "0x1"}}]`,
+		string(client.mutations[0].SetJson))
+	require.JSONEq(t, `[{"uid": "0x1"}, {"uid": "0x3", "Post.author": {"uid": "0x1"}}]`,
+		string(client.mutations[0].DeleteJson))
+
+	client = &mockDgraph{results: []string{
+		`{"deleteAuthor": [{"uid": "0x1", "~Post.author": [{"uid": "0x2"}]}]}`}}
+	res = resolveMutationFor(t, reverseSchema, client,
+		`mutation { deleteAuthor(filter: { id: ["0x1"] }) { msg } }`)
+	require.NoError(t, res.err)
+
+	require.Equal(t, `query {
+  deleteAuthor(func: type(Author)) @filter(uid(0x1)) {
+    uid
+    ~Post.author {
+      uid
+    }
+  }
+}`, client.queries[0])
+	require.JSONEq(t, `[{"uid": "0x1"}, {"uid": "0x2", "Post.author": {"uid": "0x1"}}]`,
+		string(client.mutations[0].DeleteJson))
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+)
+
+// notDeleted builds the filter that leaves out the soft-deleted nodes of typ.
+// It's nil if typ isn't a @softDelete type.  An interface's nodes are
+// filtered by the types implementing it, as they are by auth rules.
+func notDeleted(typ schema.Type) *gql.FilterTree {
+	if impls := typ.Implementations(); len(impls) > 0 {
+		var fts []*gql.FilterTree
+		for _, impl := range impls {
+			if ft := notDeleted(impl); ft != nil {
+				notImpl := &gql.FilterTree{Op: "not", Child: []*gql.FilterTree{typeFilter(impl)}}
+				fts = append(fts, combine("or", notImpl, ft))
+			}
+		}
+		return combine("and", fts...)
+	}
+
+	deletedAt := typ.SoftDelete()
+	if deletedAt == nil {
+		return nil
+	}
+	return &gql.FilterTree{Op: "not", Child: []*gql.FilterTree{isDeleted(deletedAt)}}
+}
+
+// deletedFilter is notDeleted for field's type, unless field asks for the
+// deleted nodes too with includeDeleted.
+func deletedFilter(field schema.Field) *gql.FilterTree {
+	if include, _ := field.ArgValue(schema.IncludeDeletedArgName).(bool); include {
+		return nil
+	}
+	return notDeleted(field.Type())
+}
+
+// isDeleted is the filter for the nodes that are marked as deleted by
+// deletedAt, the deletedAt field of a @softDelete type.
+func isDeleted(deletedAt schema.FieldDefinition) *gql.FilterTree {
+	return &gql.FilterTree{Func: &gql.Function{Name: "has", Attr: deletedAt.DgraphPredicate()}}
+}
+
+// resolveSoftDelete marks the nodes that a delete mutation on a @softDelete
+// type finds as deleted, rather than deleting them.  Nodes that are already
+// deleted keep the time they were deleted.
+func (mr *mutationResolver) resolveSoftDelete(ctx context.Context, txn dgraph.Txn,
+	auth *authorizer) (map[string]interface{}, error) {
+
+	typ := mr.mutation.MutatedType()
+	nodes, err := softDeleteNodes(ctx, txn, mr.mutation, auth, notDeleted(typ))
+	if err != nil {
+		return nil, err
+	}
+	uids, err := nodeUIDs(nodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCondition(ctx, txn, mr.mutation, uids); err != nil {
+		return nil, err
+	}
+	if err := mr.readBefore(ctx, txn, uids); err != nil {
+		return nil, err
+	}
+
+	if len(nodes) > 0 {
+		now := time.Now().UTC().Format(time.RFC3339)
+		sets := make([]interface{}, 0, len(nodes))
+		for _, node := range nodes {
+			sets = append(sets, map[string]interface{}{
+				"uid":                              node["uid"],
+				typ.SoftDelete().DgraphPredicate(): now,
+			})
+		}
+		setJSON, err := json.Marshal(sets)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't marshal mutation to JSON")
+		}
+		if _, err := txn.Mutate(ctx, &api.Mutation{SetJson: setJSON}); err != nil {
+			return nil, err
+		}
+	}
+
+	mr.uids = uids
+	return map[string]interface{}{"msg": deletedMsg}, nil
+}
+
+// resolveRestore undoes the soft deletion of the deleted nodes that a
+// restore mutation finds.  The payload is the restored nodes.
+func (mr *mutationResolver) resolveRestore(ctx context.Context, txn dgraph.Txn,
+	auth *authorizer) (map[string]interface{}, error) {
+
+	typ := mr.mutation.MutatedType()
+	deletedAt := typ.SoftDelete()
+	if deletedAt == nil {
+		return nil, errors.Errorf("%s isn't a @softDelete type", typ.Name())
+	}
+	nodes, err := softDeleteNodes(ctx, txn, mr.mutation, auth, isDeleted(deletedAt))
+	if err != nil {
+		return nil, err
+	}
+	uids, err := nodeUIDs(nodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := mr.readBefore(ctx, txn, uids); err != nil {
+		return nil, err
+	}
+
+	if len(nodes) > 0 {
+		dels := make([]interface{}, 0, len(nodes))
+		for _, node := range nodes {
+			dels = append(dels, map[string]interface{}{
+				"uid":                       node["uid"],
+				deletedAt.DgraphPredicate(): nil,
+			})
+		}
+		delJSON, err := json.Marshal(dels)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't marshal mutation to JSON")
+		}
+		if _, err := txn.Mutate(ctx, &api.Mutation{DeleteJson: delJSON}); err != nil {
+			return nil, err
+		}
+	}
+
+	return mr.payload(ctx, txn, uids, auth)
+}
+
+// softDeleteNodes finds, in txn, the nodes that m's filter, the delete rule
+// of m's type and deleted find.  Deleting and restoring nodes are both
+// governed by the delete rule.
+func softDeleteNodes(ctx context.Context, txn dgraph.Txn, m schema.Mutation, auth *authorizer,
+	deleted *gql.FilterTree) ([]map[string]interface{}, error) {
+
+	filter, _ := m.ArgValue(schema.FilterArgName).(map[string]interface{})
+	query, err := rewriteAsFilterQuery(
+		m.ResponseName(), m.MutatedType(), filter, auth, schema.AuthDelete)
+	if err != nil {
+		return nil, err
+	}
+	query.Filter = combine("and", query.Filter, deleted)
+	return queryNodes(ctx, txn, query)
+}
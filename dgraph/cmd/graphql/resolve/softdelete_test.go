@@ -0,0 +1,108 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const softDeleteSchema = `
+type Author {
+	id: ID!
+	name: String!
+	posts: [Post]
+}
+
+type Post @softDelete {
+	id: ID!
+	title: String! @search(by: [term])
+}
+`
+
+func TestSoftDeleteQuery(t *testing.T) {
+	for query, expected := range map[string]string{
+		`query { queryAuthor { name posts { title } } }`: `query {
+  queryAuthor(func: type(Author)) {
+    name : Author.name
+    posts : Author.posts @filter(NOT (has(Post.deletedAt))) {
+      title : Post.title
+    }
+  }
+}`,
+		`query { getPost(id: "0x1") { title } }`: `query {
+  getPost(func: uid(0x1)) @filter((type(Post) AND NOT (has(Post.deletedAt)))) {
+    title : Post.title
+  }
+}`,
+		`query { all: queryPost(includeDeleted: true) { title deletedAt } }`: `query {
+  all(func: type(Post)) {
+    title : Post.title
+    deletedAt : Post.deletedAt
+  }
+}`,
+	} {
+		client := &mockDgraph{}
+		resp := resolverFor(t, softDeleteSchema, client).Resolve(context.Background(),
+			&schema.Request{Query: query})
+		require.Empty(t, resp.Errors, query)
+		require.Equal(t, []string{expected}, client.queries, query)
+	}
+}
+
+func TestSoftDeleteMutation(t *testing.T) {
+	client := &mockDgraph{results: []string{`{"deletePost": [{"uid": "0x1"}]}`}}
+	res := resolveMutationFor(t, softDeleteSchema, client, `mutation {
+		deletePost(filter: { title: { anyofterms: "GraphQL" } }) { msg }
+	}`)
+	require.NoError(t, res.err)
+
+	require.Equal(t, []string{`query {
+  deletePost(func: type(Post)) @filter((anyofterms(Post.title, "GraphQL") AND NOT (has(Post.deletedAt)))) {
+    uid
+  }
+}`}, client.queries)
+	require.Len(t, client.mutations, 1)
+	require.Empty(t, client.mutations[0].DeleteJson)
+	require.Regexp(t, `^\[\{"Post.deletedAt":"[0-9T:-]+Z","uid":"0x1"\}\]$`,
+		string(client.mutations[0].SetJson))
+	require.Equal(t, `"deletePost": {"msg": "Deleted"}`, string(res.data))
+}
+
+func TestRestoreMutation(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"restorePost": [{"uid": "0x1"}]}`,
+		`{"post": [{"title": "Restored"}]}`,
+	}}
+	res := resolveMutationFor(t, softDeleteSchema, client, `mutation {
+		restorePost(filter: { id: ["0x1", "0x2"] }) { post { title } }
+	}`)
+	require.NoError(t, res.err)
+
+	require.Equal(t, `query {
+  restorePost(func: type(Post)) @filter((uid(0x1, 0x2) AND has(Post.deletedAt))) {
+    uid
+  }
+}`, client.queries[0])
+	require.Len(t, client.mutations, 1)
+	require.JSONEq(t, `[{"uid": "0x1", "Post.deletedAt": null}]`,
+		string(client.mutations[0].DeleteJson))
+	require.Equal(t, `"restorePost": {"post": [{"title": "Restored"}]}`, string(res.data))
+}
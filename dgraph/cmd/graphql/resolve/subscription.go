@@ -0,0 +1,135 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+)
+
+// DefaultPollInterval is how often subscriptions look for changes made by
+// anything other than this server's mutations.
+const DefaultPollInterval = 2 * time.Second
+
+// A changeFeed lets subscriptions wait for data to change.  Dgraph can't
+// report changes, so the feed is triggered by the mutations this server runs;
+// changes made any other way are found by polling.
+type changeFeed struct {
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{changed: make(chan struct{})}
+}
+
+// wait returns a channel that's closed at the next change.
+func (cf *changeFeed) wait() <-chan struct{} {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	return cf.changed
+}
+
+// notify wakes everything waiting for a change.
+func (cf *changeFeed) notify() {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	close(cf.changed)
+	cf.changed = make(chan struct{})
+}
+
+// WithPollInterval makes r's subscriptions look for changes made outside
+// this server every interval.  It returns r, so calls can be chained.
+func (r *RequestResolver) WithPollInterval(interval time.Duration) *RequestResolver {
+	r.pollInterval = interval
+	return r
+}
+
+// Subscribe resolves gqlReq as a stream of responses.  For a subscription,
+// the first response is the current answer and another is sent each time the
+// answer changes, until ctx is done.  A query or mutation gets a single
+// response.  The channel is closed when there'll be no more responses.
+//
+// If gqlReq isn't valid, there's no stream; the error response is returned
+// instead.
+func (r *RequestResolver) Subscribe(ctx context.Context,
+	gqlReq *schema.Request) (<-chan *schema.Response, *schema.Response) {
+
+	ctx = requestContext(ctx)
+	sch := r.Schema()
+	if sch == nil {
+		return nil, r.Resolve(ctx, gqlReq)
+	}
+	op, errResp := r.operation(ctx, sch, gqlReq)
+	if errResp != nil {
+		return nil, finishErrors(ctx, errResp)
+	}
+
+	out := make(chan *schema.Response, 1)
+	if !op.IsSubscription() {
+		out <- r.Resolve(ctx, gqlReq)
+		close(out)
+		return out, nil
+	}
+
+	go func() {
+		defer close(out)
+
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+
+		var last []byte
+		for {
+			// The change feed is checked before resolving, so that a change
+			// made while resolving isn't missed.
+			changed := r.changes.wait()
+
+			resp := &schema.Response{}
+			resolveCtx, cancel := r.withTimeout(ctx, op)
+			r.resolveQueries(resolveCtx, op.Subscriptions(), resp)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			finishErrors(ctx, resp)
+
+			var buf bytes.Buffer
+			_, _ = resp.WriteTo(&buf)
+			if !bytes.Equal(buf.Bytes(), last) {
+				last = buf.Bytes()
+				select {
+				case out <- resp:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return out, nil
+}
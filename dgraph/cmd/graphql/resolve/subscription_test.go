@@ -0,0 +1,173 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+// liveDgraph answers every query with its current result, which a mutation
+// can change.  It's safe for subscriptions to query it while it's mutated.
+type liveDgraph struct {
+	mu      sync.Mutex
+	result  string
+	queries int
+	onMut   string
+}
+
+func (d *liveDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries++
+	return []byte(d.result), nil
+}
+
+func (d *liveDgraph) NewTxn() dgraph.Txn {
+	return d
+}
+
+func (d *liveDgraph) Alter(ctx context.Context, schema string) error {
+	return nil
+}
+
+func (d *liveDgraph) Schema(ctx context.Context, preds []string) ([]*api.SchemaNode, error) {
+	return nil, nil
+}
+
+func (d *liveDgraph) DescribeSchema(ctx context.Context) (*dgraph.FullSchema, error) {
+	return &dgraph.FullSchema{}, nil
+}
+
+func (d *liveDgraph) Mutate(ctx context.Context, mut *api.Mutation) (map[string]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.result = d.onMut
+	return map[string]string{"Author1": "0x2"}, nil
+}
+
+func (d *liveDgraph) Commit(ctx context.Context) error {
+	return nil
+}
+
+func (d *liveDgraph) Discard(ctx context.Context) error {
+	return nil
+}
+
+func (d *liveDgraph) set(result string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.result = result
+}
+
+func next(t *testing.T, results <-chan *schema.Response) string {
+	select {
+	case resp, ok := <-results:
+		require.True(t, ok, "subscription ended")
+		var buf bytes.Buffer
+		_, err := resp.WriteTo(&buf)
+		require.NoError(t, err)
+		return buf.String()
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response from subscription")
+		return ""
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	client := &liveDgraph{
+		result: `{"subscribeAuthor": [{"name": "A"}]}`,
+		onMut: `{"subscribeAuthor": [{"name": "A"}, {"name": "B"}],
+			"author": [{"name": "B"}]}`,
+	}
+	// A long poll interval, so that only mutations cause updates.
+	resolver := resolverFor(t, testSchema, client).WithPollInterval(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, errResp := resolver.Subscribe(ctx, &schema.Request{
+		Query: `subscription { subscribeAuthor { name } }`,
+	})
+	require.Nil(t, errResp)
+	require.JSONEq(t, `{"data": {"subscribeAuthor": [{"name": "A"}]}}`, next(t, results))
+
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query:     `mutation add($auth: AddAuthorInput!) { addAuthor(input: [$auth]) { author { name } } }`,
+		Variables: map[string]interface{}{"auth": map[string]interface{}{"name": "B"}},
+	})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"data": {"subscribeAuthor": [{"name": "A"}, {"name": "B"}]}}`,
+		next(t, results))
+
+	cancel()
+	select {
+	case _, ok := <-results:
+		require.False(t, ok, "no more results after the subscription is cancelled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription didn't end")
+	}
+}
+
+func TestSubscriptionPolls(t *testing.T) {
+	client := &liveDgraph{result: `{"subscribeAuthor": [{"name": "A"}]}`}
+	resolver := resolverFor(t, testSchema, client).WithPollInterval(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results, errResp := resolver.Subscribe(ctx, &schema.Request{
+		Query: `subscription { subscribeAuthor(filter: {name: {eq: "A"}}) { name } }`,
+	})
+	require.Nil(t, errResp)
+	require.JSONEq(t, `{"data": {"subscribeAuthor": [{"name": "A"}]}}`, next(t, results))
+
+	// A change made outside the server is found by polling, and polls that
+	// find nothing new don't send anything.
+	time.Sleep(50 * time.Millisecond)
+	client.set(`{"subscribeAuthor": []}`)
+	require.JSONEq(t, `{"data": {"subscribeAuthor": []}}`, next(t, results))
+}
+
+func TestSubscribeNotASubscription(t *testing.T) {
+	resolver := resolverFor(t, testSchema, &liveDgraph{result: `{"queryAuthor": []}`})
+
+	results, errResp := resolver.Subscribe(context.Background(), &schema.Request{
+		Query: `query { queryAuthor { name } }`,
+	})
+	require.Nil(t, errResp)
+	require.JSONEq(t, `{"data": {"queryAuthor": []}}`, next(t, results))
+	_, ok := <-results
+	require.False(t, ok, "a query gets just one result")
+
+	_, errResp = resolver.Subscribe(context.Background(), &schema.Request{
+		Query: `subscription { subscribeAuthor { notAField } }`,
+	})
+	require.NotNil(t, errResp)
+	require.Len(t, errResp.Errors, 1)
+
+	resp := resolver.Resolve(context.Background(), &schema.Request{
+		Query: `subscription { subscribeAuthor { name } }`,
+	})
+	require.Equal(t, "Subscriptions are only served over WebSockets", resp.Errors[0].Message)
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+)
+
+// Timeouts bound how long each kind of operation can take; 0 is no timeout.
+// When an operation runs out of time, or the request it came in is
+// abandoned, its context is cancelled, and so is whatever it was doing:
+// nothing more is rewritten or sent to Dgraph, and the Dgraph calls that are
+// running are cancelled too.
+type Timeouts struct {
+	Query    time.Duration
+	Mutation time.Duration
+
+	// Subscription bounds each time a subscription's answer is resolved, not
+	// how long the subscription lasts.
+	Subscription time.Duration
+}
+
+// of returns the timeout for op.
+func (t Timeouts) of(op schema.Operation) time.Duration {
+	switch {
+	case op.IsMutation():
+		return t.Mutation
+	case op.IsSubscription():
+		return t.Subscription
+	default:
+		return t.Query
+	}
+}
+
+type timeoutKey struct{}
+
+// WithTimeout returns a copy of ctx in which operations are given at most
+// timeout, e.g. as a request asked for in a header.  It can only shorten the
+// RequestResolver's timeouts, not lengthen them.
+func WithTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, timeout)
+}
+
+// withTimeout returns a copy of ctx that's cancelled when op runs out of
+// time, and the function that releases it.
+func (r *RequestResolver) withTimeout(ctx context.Context,
+	op schema.Operation) (context.Context, context.CancelFunc) {
+
+	timeout := r.timeouts.of(op)
+	if asked, ok := ctx.Value(timeoutKey{}).(time.Duration); ok && asked > 0 &&
+		(timeout == 0 || asked < timeout) {
+		timeout = asked
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
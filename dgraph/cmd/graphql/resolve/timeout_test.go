@@ -0,0 +1,93 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// slowDgraph doesn't answer queries until they're cancelled.
+type slowDgraph struct {
+	mockDgraph
+}
+
+func (s *slowDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	s.queries = append(s.queries, dgraph.AsString(query))
+	<-ctx.Done()
+	return nil, errors.Wrap(ctx.Err(), "while querying Dgraph")
+}
+
+func TestTimeouts(t *testing.T) {
+	req := &schema.Request{Query: `query { getNote(id: "0x1") { text } }`}
+
+	tests := []struct {
+		name     string
+		timeouts Timeouts
+		asked    time.Duration
+	}{
+		{name: "query timeout", timeouts: Timeouts{Query: 10 * time.Millisecond}},
+		{name: "asked for a shorter one", timeouts: Timeouts{Query: time.Hour},
+			asked: 10 * time.Millisecond},
+		{name: "asked for a longer one", timeouts: Timeouts{Query: 10 * time.Millisecond},
+			asked: time.Hour},
+		{name: "asked for one", asked: 10 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.asked > 0 {
+				ctx = WithTimeout(ctx, test.asked)
+			}
+			start := time.Now()
+			resp := resolverFor(t, errorsSchema, &slowDgraph{}).WithTimeouts(test.timeouts).
+				Resolve(ctx, req)
+
+			require.True(t, time.Since(start) < time.Minute)
+			require.Len(t, resp.Errors, 1)
+			require.Equal(t, timeoutCode, resp.Errors[0].Extensions["code"])
+			require.JSONEq(t, `{"getNote": null}`, resp.Data.String())
+		})
+	}
+}
+
+func TestCancelledRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := &slowDgraph{}
+	resp := resolverFor(t, errorsSchema, client).Resolve(ctx,
+		&schema.Request{Query: `query { getNote(id: "0x1") { text } }`})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, cancelledCode, resp.Errors[0].Extensions["code"])
+
+	resp = resolverFor(t, errorsSchema, client).Resolve(ctx,
+		&schema.Request{Query: `mutation { addNote(input: [{text: "A"}]) { note { text } } }`})
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, cancelledCode, resp.Errors[0].Extensions["code"])
+
+	require.Empty(t, client.queries, "nothing is sent to Dgraph")
+	require.Empty(t, client.mutations)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// A FileStore stores the files uploaded to @upload fields.  DirStore keeps
+// them on disk; a store for S3, or any other object store, just has to put
+// the file somewhere it can be fetched from by URL.
+type FileStore interface {
+	// Store stores upload's file, and returns the URL it can be fetched from.
+	Store(ctx context.Context, upload *schema.Upload) (string, error)
+}
+
+// DirStore is a FileStore that keeps uploaded files in a directory.  Each
+// file gets a new, random name, keeping the extension it was uploaded with,
+// so files never overwrite each other.
+type DirStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewDirStore returns a DirStore that writes files to dir, creating it if
+// need be, and that serves them at baseURL, e.g. https://cdn.example.com/
+// or /uploads/.
+func NewDirStore(dir, baseURL string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "while creating the uploads directory")
+	}
+	return &DirStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/") + "/"}, nil
+}
+
+// Store writes upload's file to the directory.
+func (s *DirStore) Store(ctx context.Context, upload *schema.Upload) (string, error) {
+	in, err := upload.Open()
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	name := uuid.New().String() + fileExt(upload.Filename)
+	path := filepath.Join(s.dir, name)
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return s.baseURL + name, nil
+}
+
+// safeExt is what's kept of the extension of an uploaded file's name.
+var safeExt = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+// fileExt returns the extension of filename, lower case, or "" if it isn't a
+// plain extension like .png.
+func fileExt(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !safeExt.MatchString(ext) {
+		return ""
+	}
+	return ext
+}
+
+// storeUploads stores, in store, the files uploaded in args, the arguments of
+// a mutation, and sets their URLs.  A file that's already been stored, by an
+// earlier try of the mutation or another mutation in the request, isn't
+// stored again.
+func storeUploads(ctx context.Context, store FileStore, args map[string]interface{}) error {
+	var walk func(val interface{}) error
+	walk = func(val interface{}) error {
+		switch v := val.(type) {
+		case *schema.Upload:
+			if v.URL != "" {
+				return nil
+			}
+			if store == nil {
+				return errors.New("this server doesn't accept file uploads")
+			}
+			url, err := store.Store(ctx, v)
+			if err != nil {
+				return errors.Wrapf(err, "while storing uploaded file %q", v.Filename)
+			}
+			v.URL = url
+		case map[string]interface{}:
+			for _, item := range v {
+				if err := walk(item); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				if err := walk(item); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk(args)
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const uploadSchema = `
+type Author {
+	id: ID!
+	name: String!
+	avatar: String @upload
+	photos: [Photo]
+}
+
+type Photo {
+	id: ID!
+	image: String! @upload
+}
+`
+
+// memStore is a FileStore that keeps files in memory.
+type memStore struct {
+	files map[string]string
+}
+
+func (s *memStore) Store(ctx context.Context, upload *schema.Upload) (string, error) {
+	in, err := upload.Open()
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	content, err := ioutil.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+	url := "https://files/" + upload.Filename
+	s.files[url] = string(content)
+	return url, nil
+}
+
+func testUpload(filename, content string) *schema.Upload {
+	return &schema.Upload{
+		Filename: filename,
+		Size:     int64(len(content)),
+		Open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(content)), nil
+		},
+	}
+}
+
+func TestMutationWithUploads(t *testing.T) {
+	req := &schema.Request{
+		Query: `mutation add($avatar: Upload, $p1: Upload!, $p2: Upload!) {
+			addAuthor(input: [{
+				name: "A",
+				avatar: $avatar,
+				photos: [{image: $p1}, {image: $p2}]
+			}]) { author { name } }
+		}`,
+	}
+	avatar := testUpload("me.png", "PNG")
+	req.Variables = map[string]interface{}{
+		"avatar": avatar,
+		"p1":     testUpload("1.jpg", "JPEG 1"),
+		"p2":     testUpload("2.jpg", "JPEG 2"),
+	}
+
+	t.Run("stored", func(t *testing.T) {
+		client := &mockDgraph{
+			assigned: map[string]string{"Author1": "0x1"},
+			results:  []string{`{"author": [{"name": "A"}]}`},
+		}
+		store := &memStore{files: make(map[string]string)}
+		resp := resolverFor(t, uploadSchema, client).WithFileStore(store).
+			Resolve(context.Background(), req)
+		require.Empty(t, resp.Errors)
+
+		require.Equal(t, map[string]string{
+			"https://files/me.png": "PNG",
+			"https://files/1.jpg":  "JPEG 1",
+			"https://files/2.jpg":  "JPEG 2",
+		}, store.files)
+		require.JSONEq(t, `[{
+			"uid": "_:Author1", "dgraph.type": "Author",
+			"Author.name": "A",
+			"Author.avatar": "https://files/me.png",
+			"Author.photos": [
+				{"uid": "_:Photo2", "dgraph.type": "Photo", "Photo.image": "https://files/1.jpg"},
+				{"uid": "_:Photo3", "dgraph.type": "Photo", "Photo.image": "https://files/2.jpg"}
+			]
+		}]`, string(client.mutations[0].SetJson))
+	})
+
+	t.Run("not accepted", func(t *testing.T) {
+		avatar.URL = ""
+		client := &mockDgraph{}
+		resp := resolverFor(t, uploadSchema, client).Resolve(context.Background(), req)
+		require.Len(t, resp.Errors, 1)
+		require.Equal(t, "this server doesn't accept file uploads", resp.Errors[0].Message)
+		require.Empty(t, client.mutations)
+	})
+
+	t.Run("not a file", func(t *testing.T) {
+		resp := resolverFor(t, uploadSchema, &mockDgraph{}).Resolve(context.Background(),
+			&schema.Request{
+				Query: `mutation add($a: Upload) {
+					addAuthor(input: [{name: "A", avatar: $a}]) { author { name } }
+				}`,
+				Variables: map[string]interface{}{"a": "me.png"},
+			})
+		require.Len(t, resp.Errors, 1)
+		require.Contains(t, resp.Errors[0].Message,
+			`variable "a": expected Upload, got String "me.png"; files are uploaded in `+
+				"multipart requests")
+	})
+}
+
+func TestDirStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploads")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := NewDirStore(filepath.Join(dir, "files"), "/uploads")
+	require.NoError(t, err)
+
+	for _, test := range []struct{ filename, ext string }{
+		{"me.PNG", ".png"},
+		{"../../etc/passwd", ""},
+		{"archive.tar.gz", ".gz"},
+		{"notes.a b", ""},
+	} {
+		url, err := store.Store(context.Background(), testUpload(test.filename, "content"))
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(url, "/uploads/"), url)
+		require.Equal(t, test.ext, filepath.Ext(url))
+
+		content, err := ioutil.ReadFile(
+			filepath.Join(dir, "files", strings.TrimPrefix(url, "/uploads/")))
+		require.NoError(t, err)
+		require.Equal(t, "content", string(content))
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+)
+
+// versionConflictCode is the error code, in the error's extensions, for
+// updates that fail because an object isn't at the version they expected.
+const versionConflictCode = "VERSION_CONFLICT"
+
+// A VersionConflictError is the error from an update that expected the
+// objects it found to be at some version, when one of them had moved on.
+type VersionConflictError struct {
+	Type     string
+	ID       string
+	Expected int64
+	Actual   int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s %s is at version %d, not version %d, so it's been updated since "+
+		"that version was read", e.Type, e.ID, e.Actual, e.Expected)
+}
+
+// Extensions gives the details of the error for the extensions of a GraphQL
+// error.
+func (e *VersionConflictError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":     versionConflictCode,
+		"id":       e.ID,
+		"expected": e.Expected,
+		"actual":   e.Actual,
+	}
+}
+
+// expectedVersion returns the version that the input of m, an update of a
+// type with a @version field, expects the objects to be at.
+func expectedVersion(m schema.Mutation) (int64, error) {
+	input, _ := m.ArgValue(schema.InputArgName).(map[string]interface{})
+	version, ok := asInt(input[schema.VersionArgName])
+	if !ok {
+		return 0, errors.Errorf("%s needs the %s that the objects are expected to be at",
+			m.Name(), schema.VersionArgName)
+	}
+	return version, nil
+}
+
+// checkVersions checks that the nodes, found by an update of type typ, are all
+// at the expected version.  Each node has its uid and its version, in the
+// predicate of version, typ's @version field.  A node without a version is
+// at version 0.
+func checkVersions(typ schema.Type, version schema.FieldDefinition,
+	nodes []map[string]interface{}, expected int64) error {
+
+	for _, node := range nodes {
+		actual, _ := asInt(node[version.DgraphPredicate()])
+		if actual != expected {
+			id, _ := node["uid"].(string)
+			return &VersionConflictError{
+				Type: typ.Name(), ID: id, Expected: expected, Actual: actual}
+		}
+	}
+	return nil
+}
+
+// asInt returns val, a number from a GraphQL argument or a Dgraph result, as
+// an int64.
+func asInt(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), float64(int64(v)) == v
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const versionSchema = `
+type Document {
+	id: ID!
+	text: String!
+	version: Int! @version
+}
+`
+
+func TestVersionAdd(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Document1": "0x1"},
+		results:  []string{`{"document": [{"version": 1}]}`},
+	}
+	resp := resolveRequest(t, versionSchema, client, `mutation {
+		addDocument(input: [{text: "A"}]) { document { version } }
+	}`)
+	require.Empty(t, resp.Errors)
+
+	require.JSONEq(t, `[{"uid": "_:Document1", "dgraph.type": "Document",
+		"Document.text": "A", "Document.version": 1}]`, string(client.mutations[0].SetJson))
+}
+
+func TestVersionUpdate(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"updateDocument": [{"uid": "0x1", "Document.version": 3}]}`,
+		`{"document": [{"text": "B", "version": 4}]}`,
+	}}
+	resp := resolveRequest(t, versionSchema, client, `mutation {
+		updateDocument(input: {filter: {id: ["0x1"]}, set: {text: "B"}, version: 3}) {
+			document { text version }
+		}
+	}`)
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, `query {
+  updateDocument(func: type(Document)) @filter(uid(0x1)) {
+    uid
+    Document.version
+  }
+}`, client.queries[0])
+	require.JSONEq(t, `[{"uid": "0x1", "Document.text": "B", "Document.version": 4}]`,
+		string(client.mutations[0].SetJson))
+	require.True(t, client.committed)
+}
+
+func TestVersionConflict(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"updateDocument": [{"uid": "0x1", "Document.version": 4}]}`,
+	}}
+	resp := resolveRequest(t, versionSchema, client, `mutation {
+		updateDocument(input: {filter: {id: ["0x1"]}, set: {text: "B"}, version: 3}) {
+			document { text }
+		}
+	}`)
+
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "Document 0x1 is at version 4, not version 3, so it's been updated "+
+		"since that version was read", resp.Errors[0].Message)
+	require.Equal(t, map[string]interface{}{"code": versionConflictCode, "id": "0x1",
+		"expected": int64(3), "actual": int64(4), "requestId": "req-1"},
+		resp.Errors[0].Extensions)
+	require.Empty(t, client.mutations)
+	require.False(t, client.committed)
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+)
+
+// getXid returns the external ID that query, a getT, asks for, if it finds
+// the object by T's @xid field rather than by its ID.
+func getXid(query schema.Query) (string, bool, error) {
+	xidField := query.Type().XidField()
+	if xidField == nil {
+		return "", false, nil
+	}
+	xid, byXid := query.ArgValue(xidField.Name()).(string)
+	idField := query.Type().IDField()
+	switch {
+	case byXid && query.ArgValue(idField.Name()) != nil:
+		return "", false, errors.Errorf("%s takes either %s or %s, not both",
+			query.Name(), idField.Name(), xidField.Name())
+	case !byXid && query.ArgValue(idField.Name()) == nil:
+		return "", false, errors.Errorf("%s needs either %s or %s",
+			query.Name(), idField.Name(), xidField.Name())
+	}
+	return xid, byXid, nil
+}
+
+// rewriteAsGetByXid builds a query for the single node of field's type with
+// the external ID xid.
+func rewriteAsGetByXid(field schema.Field, xid string,
+	auth *authorizer) (*gql.GraphQuery, error) {
+
+	return rewriteAsGetAt(field, &gql.Function{
+		Name: "eq", Attr: schema.XidPredicate, Args: []gql.Arg{{Value: xid}}}, auth)
+}
+
+// mutationXids returns the external IDs in the input of m, an add or update,
+// that might already be in Dgraph: the @xid values of the objects that an add
+// adds, and of the references, without an ID, in either.
+func mutationXids(m schema.Mutation) []string {
+	xids := make(map[string]bool)
+	typ := m.MutatedType()
+	switch m.MutationType() {
+	case schema.AddMutation:
+		inputs, _ := m.ArgValue(schema.InputArgName).([]interface{})
+		for _, input := range inputs {
+			obj, _ := input.(map[string]interface{})
+			collectXids(typ, obj, xids)
+		}
+	case schema.UpdateMutation:
+		// The set patch's own @xid value is a change of the updated objects'
+		// external IDs, not a reference.
+		input, _ := m.ArgValue(schema.InputArgName).(map[string]interface{})
+		set, _ := input["set"].(map[string]interface{})
+		collectRefXids(typ, set, xids)
+	}
+
+	result := make([]string, 0, len(xids))
+	for xid := range xids {
+		result = append(result, xid)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// collectXids adds the external ID of obj, an object of type typ that's
+// added, and of the references in it, to xids.
+func collectXids(typ schema.Type, obj map[string]interface{}, xids map[string]bool) {
+	if xid := objectXid(typ, obj); xid != "" {
+		xids[xid] = true
+	}
+	collectRefXids(typ, obj, xids)
+}
+
+// collectRefXids adds the external IDs of the references in obj, an input
+// object of type typ, to xids.
+func collectRefXids(typ schema.Type, obj map[string]interface{}, xids map[string]bool) {
+	for name, val := range obj {
+		fld := typ.Field(name)
+		if fld == nil || !isReference(fld) {
+			continue
+		}
+		refs := asList(val)
+		if edgeOf := fld.EdgeOf(); edgeOf != nil {
+			fld, refs = edgeOf, edgeNodes(val)
+		}
+		for _, r := range refs {
+			ref, _ := r.(map[string]interface{})
+			if _, ok := referenceID(fld.Type(), ref); !ok {
+				collectXids(fld.Type(), ref, xids)
+			}
+		}
+	}
+}
+
+// objectXid returns the @xid value of obj, an input object of type typ that
+// doesn't have an ID, or "" if it doesn't have one.
+func objectXid(typ schema.Type, obj map[string]interface{}) string {
+	xidField := typ.XidField()
+	if xidField == nil {
+		return ""
+	}
+	if _, ok := referenceID(typ, obj); ok {
+		return ""
+	}
+	xid, _ := obj[xidField.Name()].(string)
+	return xid
+}
+
+// lookupXids finds, in txn, the nodes with the given external IDs, mapping
+// each external ID that's in Dgraph to the uid of its node.
+func lookupXids(ctx context.Context, txn dgraph.Txn,
+	xids []string) (map[string]string, error) {
+
+	found := make(map[string]string, len(xids))
+	if len(xids) == 0 {
+		return found, nil
+	}
+
+	args := make([]gql.Arg, len(xids))
+	for i, xid := range xids {
+		args[i] = gql.Arg{Value: xid}
+	}
+	nodes, err := queryNodes(ctx, txn, &gql.GraphQuery{
+		Attr:     "xids",
+		Func:     &gql.Function{Name: "eq", Attr: schema.XidPredicate, Args: args},
+		Children: []*gql.GraphQuery{{Attr: "uid"}, {Attr: schema.XidPredicate}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		xid, _ := node[schema.XidPredicate].(string)
+		uid, _ := node["uid"].(string)
+		if xid != "" && uid != "" {
+			found[xid] = uid
+		}
+	}
+	return found, nil
+}
+
+// rewriteNode builds the JSON for obj, an object of type typ that's added by
+// an addT mutation or by a reference without an ID.  If obj's @xid value is
+// already in Dgraph, or was added earlier in the mutation, obj is written to
+// that node; otherwise, it's a new node.  It returns the JSON and the uid, or
+// blank node, of the node.
+func (mrw *mutationRewriter) rewriteNode(typ schema.Type,
+	obj map[string]interface{}) (map[string]interface{}, string, error) {
+
+	xid := objectXid(typ, obj)
+	if uid, ok := mrw.xids[xid]; xid != "" && ok {
+		node, err := mrw.rewriteFields(typ, uid, obj)
+		if err != nil {
+			return nil, "", err
+		}
+		// Loaded data mightn't have been typed; it is now.
+		node[dgraphTypePredicate] = dgraphTypes(typ)
+		if !strings.HasPrefix(uid, "_:") {
+			mrw.linked.add(typ, uid)
+		}
+		return node, uid, nil
+	}
+
+	uid := "_:" + mrw.nextBlankNode(typ)
+	node, err := mrw.rewriteNewNode(typ, uid, obj)
+	if err != nil {
+		return nil, "", err
+	}
+	mrw.added.add(typ, uid)
+	if xid != "" {
+		if mrw.xids == nil {
+			mrw.xids = make(map[string]string)
+		}
+		mrw.xids[xid] = uid
+	}
+	return node, uid, nil
+}
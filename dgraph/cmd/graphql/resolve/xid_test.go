@@ -0,0 +1,122 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const xidSchema = `
+type Customer {
+	id: ID!
+	customerID: String! @xid
+	name: String
+}
+
+type Order {
+	id: ID!
+	orderNumber: String! @xid
+	customer: Customer
+}
+`
+
+func TestGetByXid(t *testing.T) {
+	client := &mockDgraph{results: []string{
+		`{"getCustomer": [{"customerID": "c1", "name": "A"}]}`,
+	}}
+	resp := resolveRequest(t, xidSchema, client,
+		`query { getCustomer(customerID: "c1") { customerID name } }`)
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, `query {
+  getCustomer(func: eq(xid, "c1")) @filter(type(Customer)) {
+    customerID : xid
+    name : Customer.name
+  }
+}`, client.queries[0])
+	require.JSONEq(t, `{"getCustomer": {"customerID": "c1", "name": "A"}}`, resp.Data.String())
+
+	resp = resolveRequest(t, xidSchema, &mockDgraph{},
+		`query { getCustomer(id: "0x1", customerID: "c1") { name } }`)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "getCustomer takes either id or customerID, not both",
+		resp.Errors[0].Message)
+
+	resp = resolveRequest(t, xidSchema, &mockDgraph{}, `query { getCustomer { name } }`)
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "getCustomer needs either id or customerID", resp.Errors[0].Message)
+}
+
+func TestAddUpsertsByXid(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Customer1": "0x2"},
+		results: []string{
+			`{"xids": [{"uid": "0x1", "xid": "c1"}]}`,
+			`{"customer": [{"customerID": "c1"}, {"customerID": "c2"}]}`,
+		},
+	}
+	resp := resolveRequest(t, xidSchema, client, `mutation {
+		addCustomer(input: [
+			{customerID: "c1", name: "A"},
+			{customerID: "c2", name: "B"}
+		]) { customer { customerID } }
+	}`)
+	require.Empty(t, resp.Errors)
+
+	require.Equal(t, `query {
+  xids(func: eq(xid, ["c1", "c2"])) {
+    uid
+    xid
+  }
+}`, client.queries[0])
+	require.JSONEq(t, `[
+		{"uid": "0x1", "dgraph.type": "Customer", "xid": "c1", "Customer.name": "A"},
+		{"uid": "_:Customer1", "dgraph.type": "Customer", "xid": "c2", "Customer.name": "B"}
+	]`, string(client.mutations[0].SetJson))
+	require.Contains(t, client.queries[1], "uid(0x1, 0x2)")
+}
+
+func TestReferenceByXid(t *testing.T) {
+	client := &mockDgraph{
+		assigned: map[string]string{"Order1": "0x3", "Order2": "0x4", "Order4": "0x5"},
+		results: []string{
+			`{"xids": [{"uid": "0x1", "xid": "c1"}]}`,
+			`{"order": []}`,
+		},
+	}
+	resp := resolveRequest(t, xidSchema, client, `mutation {
+		addOrder(input: [
+			{orderNumber: "o1", customer: {customerID: "c1"}},
+			{orderNumber: "o2", customer: {customerID: "c9", name: "New"}},
+			{orderNumber: "o3", customer: {customerID: "c9"}}
+		]) { order { orderNumber } }
+	}`)
+	require.Empty(t, resp.Errors)
+
+	// c1 is in Dgraph, and c9 is added once, by the first reference to it.
+	require.JSONEq(t, `[
+		{"uid": "_:Order1", "dgraph.type": "Order", "xid": "o1",
+			"Order.customer": {"uid": "0x1", "dgraph.type": "Customer", "xid": "c1"}},
+		{"uid": "_:Order2", "dgraph.type": "Order", "xid": "o2",
+			"Order.customer": {"uid": "_:Customer3", "dgraph.type": "Customer", "xid": "c9",
+				"Customer.name": "New"}},
+		{"uid": "_:Order4", "dgraph.type": "Order", "xid": "o3",
+			"Order.customer": {"uid": "_:Customer3", "dgraph.type": "Customer", "xid": "c9"}}
+	]`, string(client.mutations[0].SetJson))
+}
@@ -0,0 +1,622 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package graphql builds the "dgraph graphql" command, which serves a GraphQL
+// API, generated from a GraphQL schema, that's backed by Dgraph.
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/admin"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/secrets"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/signing"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/web"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.opencensus.io/plugin/ocgrpc"
+	otrace "go.opencensus.io/trace"
+	"google.golang.org/grpc"
+)
+
+// GraphQL is the sub-command invoked when running "dgraph graphql".
+var GraphQL x.SubCommand
+
+// secretsEnvPrefix starts the names of the environment variables that hold
+// secrets, unless secrets.env_prefix says otherwise: {{secrets.NAME}} is read
+// from DGRAPH_GRAPHQL_SECRET_NAME.
+const secretsEnvPrefix = "DGRAPH_GRAPHQL_SECRET_"
+
+// healthCheckInterval is how often an unreachable Dgraph is checked, so the
+// server recovers even when there are no requests.
+const healthCheckInterval = 5 * time.Second
+
+func init() {
+	GraphQL.Cmd = &cobra.Command{
+		Use:   "graphql",
+		Short: "Run the Dgraph GraphQL API server",
+		Long: `
+The GraphQL API server generates a GraphQL API (queries, mutations and input
+types) from a GraphQL schema of types, and serves it at /graphql, storing the
+data in Dgraph.  The schema can be updated while the server is running with
+the admin API at /admin.  The schema is stored in Dgraph, so it's reloaded on
+restart and shared by every GraphQL server using the same Dgraph cluster.
+Opening /graphql, or /ui, in a browser gives an interactive explorer for the
+API.  /health reports whether Dgraph is reachable: while it isn't, requests
+fail straight away with UNAVAILABLE errors, and the server recovers by itself
+once Dgraph is back.  /probe/graphql and /probe/graphql/ready are liveness and
+readiness probes: they report whether a schema is loaded, when it was built,
+and how long Dgraph takes to answer, as does the admin API's health query;
+the readiness probe fails until there's a schema and Dgraph answers.
+Whenever a schema is loaded or applied, Dgraph's schema is checked for the
+predicates and indexes the API relies on; --schema_check decides whether
+problems are only logged or stop the schema being served.
+Each schema served records a changelog of how the generated API changed,
+which the admin API reports; --schema_webhook is sent every schema update,
+with its changelog, that the server applies.  An update that would make
+breaking changes - like removing a field, or adding a required argument - is
+refused unless it's forced.  The last 20 schemas applied are kept in Dgraph,
+and the admin API's rollbackGQLSchema applies one again.  The admin API also
+keeps a registry of named, versioned schemas, with dev, staging and prod tags
+that registerGQLSchema, promoteGQLSchema and rollbackGQLSchemaTag move, and
+that applyRegisteredGQLSchema applies from.  The admin API's diffGQLSchema,
+and "dgraph graphql schema diff", show what a schema would change before it's
+applied, and "dgraph graphql validate" checks a schema file offline.
+"dgraph graphql print-schema" and "dgraph graphql print-dql" print the GraphQL
+API generated from a schema file and the Dgraph schema it's stored with, and
+"dgraph graphql gen-go" generates a Go client for the API.  For a
+database that already has data, "dgraph graphql import-dgraph", or the admin
+API's importDgraphSchema, works out a starting schema from Dgraph's types,
+using @dgraph(pred: ...) for predicates that aren't named Type.field.
+
+For development, --graphql_schema_file applies a schema file and then watches
+it, applying it again each time it's saved.  Changes are applied even if they
+break the API.  A change that can't be applied leaves the last good schema
+being served, and the error is logged and reported as JSON at /dev/status.
+
+With a key configured in the jwt section of the config file, requests are
+authenticated by a JWT in the Authorization header (or the header set by
+jwt.header), and the token's claims are what @auth rules are checked against.
+jwt.anonymous limits the kinds of operation that requests without a token can
+run, and jwt.anonymous_allow and jwt.anonymous_deny list queries, mutations and
+subscriptions they can or can't run whatever their kind - e.g. anonymous: [],
+anonymous_allow: [queryPost, getPost] for a public, read-only view of posts.
+
+A field with @auth(roles: [...]) can only be seen, filtered by or set by
+requests whose ROLE claim (or the directive's claim) is, or lists, one of the
+roles; for others, it's null with an error.  A type's rules can check claims without looking at
+the data, as in @auth(rule: "{ or: { $ROLE: { eq: \"ADMIN\" } }, owner: ... }");
+rule applies to every operation that doesn't have a rule of its own.
+
+Fields, queries and mutations marked @custom are resolved by calling the HTTP
+endpoint given in the directive.  A call can reference a secret, such as an API
+key, as {{secrets.NAME}}, so it never has to be written in the schema.  By
+default, its value is read from the environment variable
+DGRAPH_GRAPHQL_SECRET_NAME; secrets.providers can add Vault, configured by
+secrets.vault.addr, token and path.  jwt.hmac_secret, schema_webhook and
+audit.webhook can reference secrets in the same way.  A
+@custom call with a graphql query calls another GraphQL API; the types it
+returns are marked @remote, because they aren't stored in Dgraph.  With
+mode: BATCH, a field's call is made once for all the objects it's asked for,
+with a list of their inputs.  A query makes each distinct call only once.
+Those marked @lambda are resolved by the lambda server at lambda.url, which is
+sent the field's arguments, the request's claims and, for fields of a type,
+the stored fields of every object the field is asked for.
+
+Calls can be signed, so the services they go to can tell they came from this
+server, by the signers configured in the signers section: an hmac signer adds
+X-Dgraph-Timestamp and X-Dgraph-Signature, an HMAC-SHA256 of the call made
+with its secret, and an oauth2 signer adds a bearer token got from its
+token_url.  A @custom call is signed by the signer its signer argument names,
+and lambda.signer names the signer for calls to the lambda server.
+
+Calls to other services time out, are retried and trip a circuit breaker as
+the calls section says: calls.default sets the timeout, max_retries, backoff,
+max_backoff, breaker_threshold and breaker_cooldown of every call, and
+calls.hosts is a list of policies for particular hosts, each a host[:port] and
+the settings it changes.  A @custom directive's policy argument, e.g.
+policy: {timeout: "30s", maxRetries: 0}, changes them for that call, whose
+breaker is its own, not its host's.
+
+Types with a @key are Apollo Federation entities.  Once a schema has one, the
+API also answers the _service and _entities queries, so a federation gateway
+can compose it with the APIs of other services.
+
+A POST whose body is a JSON array of requests is a batch, answered with an
+array of the responses.  Consecutive queries in a batch read the same snapshot
+of Dgraph, and batch.concurrent resolves them concurrently; mutations are run
+one at a time, in order.
+
+Requests can send a query by its SHA-256 hash, in the persistedQuery
+extension, as with Apollo's automatic persisted queries.  Queries registered
+with the admin API can always be sent by hash, and with --allowlist they're
+the only queries the server runs.  A registered query can also be run by
+sending just the name of one of its operations as the operationName, and
+--allowlist_introspection lets introspection queries through the allow-list,
+for tools in development.
+
+The limits section bounds how much an operation can ask for: limits.max_depth
+(how deeply it's nested), limits.max_fields and limits.max_complexity (its
+fields, with those on list items counted once per item - a list's first
+argument, or 10).  Operations over a limit are refused before they reach Dgraph.
+limits.max_cost is a budget for an operation's estimated cost, which counts the
+nodes of each type that a list could have, as Dgraph last counted them (at most
+every 5 minutes); an operation over it gets a COST_EXCEEDED error.  With
+limits.report_cost, each response has the estimate in its extensions, as cost.
+
+Queries whose types and fields have @cacheControl(maxAge:) hints are cached
+for that long, and their responses have a Cache-Control header so that CDNs
+can cache them too.  Responses to authenticated requests are private.  The
+last 1000 distinct operations are kept parsed and validated, so that those sent
+again skip that work.  For operations without variables, the Dgraph queries
+they're rewritten to are kept too, for each set of JWT claims they're sent
+with; operations with variables are rewritten each time.  The
+graphql_operation_cache_lookups_total and graphql_plan_cache_lookups_total
+metrics count the hits and misses, and the admin API's flushOperationCache
+empties the cache.
+
+Requests are traced with OpenCensus - parsing, validation, rewriting, the
+Dgraph queries and mutations, and completing the response - and the traces
+carry on into Dgraph's.  --jaeger.collector sends them to Jaeger.  A request
+whose tracing extension is true gets its timings in Apollo's tracing format,
+in the response's tracing extension.  With --explain, a request with an
+X-Dgraph-Explain: true header, or a true explain extension, gets the DQL queries
+and mutations that its operation ran, and how long each took, in the response's
+explain extension.
+
+A type with @lambdaOnMutate(add: true, update: true, delete: true) sends an
+event, once they're committed, for each of those mutations that changes
+something: to the lambda server, or to the directive's url.  Events are sent
+in the background, and retried, until they're delivered, as the events
+section's max_attempts, initial_backoff and max_backoff say.
+
+The audit section records each mutation that's committed: the mutation, its
+arguments (redacted as audit.redact, or else request_log.redact, says), the
+audit.actor_claim of its token (sub by default), the uids of the nodes it
+added, updated or deleted, and the time.  Entries are appended to audit.file
+as JSON lines, POSTed to audit.webhook, and, if audit.dgraph is true, stored
+in Dgraph as dgraph.graphql.audit nodes; any combination can be set.
+
+With cdc.kafka_brokers, the changes that committed mutations make are
+published to Kafka, one message per changed object, keyed by its uid, on the
+topic cdc.topic_prefix (graphql. by default) followed by its type's name.  A
+message has the operation, the mutation, and the object's stored fields before
+and after the change, where there are any; @private fields are left out.
+Changes are published in the background, in the order they were committed,
+and retried for about five minutes if Kafka can't take them.  Changes that are
+given up on, or that arrive while 10000 are already waiting, are dropped, and
+counted by the graphql_dropped_changes_total metric.
+
+The request_log section logs requests as JSON: each one's operation, its
+variables, the subject of its token, how long it took and the kinds of errors
+it got.  request_log.sample_rate is the fraction of requests logged, and
+request_log.errors logs every request with errors.  The values of variables,
+and of input fields, named in request_log.redact aren't logged; by default,
+that's password, secret and token.
+
+With --tls_dir, the server is HTTPS, using the same ca.crt, node.crt and
+node.key as alpha; "dgraph cert" creates them.  --tls_client_auth
+REQUIREANDVERIFY makes it mutual TLS: clients need a certificate issued by
+ca.crt.
+
+Responses of at least compression.min_size bytes (1KB by default) are gzipped
+for clients that accept it, as they're written; compression.gzip: false turns
+that off.
+
+Fields marked @upload are set by uploading files, in multipart requests as in
+the GraphQL multipart request spec.  With uploads.dir set, the files are
+written there, and the fields store the URLs they're served at: by default,
+this server serves them at /uploads/, but uploads.url can point at a CDN.
+
+Browser apps served from other origins can call /graphql directly once their
+origins are listed in cors.allowed_origins.  cors.allowed_headers,
+cors.allow_credentials and cors.max_age (how long browsers cache preflight
+requests) tune what they're allowed.
+
+One server can serve a separate GraphQL API for each of several tenants:
+namespaces.alphas maps each namespace's name to the Dgraph cluster its data
+and schema are stored in.  Requests name their namespace in the
+X-Dgraph-Namespace header (or namespaces.header), or, with namespaces.claim
+set, in that claim of their JWT.  Requests that don't name one get the default
+API, except with namespaces.claim, where the claim is what keeps tenants
+apart: requests without a token are refused with an HTTP 401, and those whose
+token doesn't have the claim with a 403.  The admin API for a namespace is
+chosen by the header.
+
+The rate_limit section bounds how fast each client can make requests
+(rate_limit.requests_per_second) and run mutations
+(rate_limit.writes_per_second), with bursts of up to request_burst and
+write_burst.  Clients are told apart by the API key in
+rate_limit.api_key_header, if that's set, then by their token's subject, and
+then by their IP address.  Requests over a limit get an HTTP 429 with a
+Retry-After header, and are counted in the graphql_throttled_requests_total
+metric.
+
+introspection.mode says who can ask the API about its schema: everyone
+(enabled, the default), no one (disabled), requests with a valid JWT
+(authenticated), or admins (admin) - requests whose JWT's
+introspection.role_claim claim (role) is, or lists,
+introspection.admin_role (admin).  The admin API's setIntrospectionMode
+changes the mode for every server using the Dgraph cluster.
+
+Settings can be given as flags, DGRAPH_GRAPHQL_* environment variables or in a
+config file (--config).  "dgraph graphql config check" checks them.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer x.StartProfile(GraphQL.Conf).Stop()
+			run(GraphQL.Conf)
+		},
+	}
+	GraphQL.EnvPrefix = "DGRAPH_GRAPHQL"
+	GraphQL.Cmd.AddCommand(configCmd())
+	GraphQL.Cmd.AddCommand(schemaCmd())
+	GraphQL.Cmd.AddCommand(validateCmd())
+	GraphQL.Cmd.AddCommand(printSchemaCmd())
+	GraphQL.Cmd.AddCommand(printDQLCmd())
+	GraphQL.Cmd.AddCommand(genGoCmd())
+	GraphQL.Cmd.AddCommand(importDgraphCmd())
+	GraphQL.Cmd.AddCommand(loadCmd())
+
+	flag := GraphQL.Cmd.Flags()
+	flag.StringP("alpha", "a", "127.0.0.1:9080",
+		"Comma-separated list of Dgraph alpha gRPC server addresses.")
+	flag.IntP("port", "p", 9000, "Port on which to run the HTTP service.")
+	flag.String("tls_dir", "",
+		"Directory with the ca.crt, node.crt and node.key (as written by \"dgraph cert\") "+
+			"to serve HTTPS with.  If it's not set, the server is plain HTTP.")
+	flag.String("tls_client_auth", "VERIFYIFGIVEN",
+		"Whether HTTPS clients must have a certificate issued by tls_dir's ca.crt: "+
+			"REQUEST, REQUIREANY, VERIFYIFGIVEN or REQUIREANDVERIFY.")
+	flag.StringP("schema", "s", "",
+		"Location of the GraphQL schema file.  If it's not set, the schema stored "+
+			"in Dgraph is served, or one can be added later with the admin API.")
+	flag.String("graphql_schema_file", "",
+		"Development mode: apply the GraphQL schema in this file, and apply it again "+
+			"each time it's saved, even if it makes breaking changes.  Errors are logged "+
+			"and reported at /dev/status.")
+	flag.Duration("schema_poll_interval", 30*time.Second,
+		"How often to check Dgraph for a GraphQL schema updated by another server. "+
+			"0 disables checking.")
+	flag.String("schema_check", string(admin.SchemaCheckWarn),
+		"What to do when Dgraph's schema is missing predicates or indexes that the GraphQL "+
+			"schema needs: warn (log the problems), fail (don't serve the GraphQL schema) "+
+			"or off.")
+	flag.String("schema_webhook", "",
+		"URL that's sent each schema update applied by this server, with a changelog of "+
+			"how the generated API changed.")
+	flag.StringSlice("remote", nil,
+		"Remote GraphQL APIs to stitch into the schema, each as field=url or "+
+			"field:Prefix=url.  The remote API is served under the root field, with its "+
+			"types renamed with the prefix (by default, the field name capitalised).")
+	flag.Bool("ui", true,
+		"Serve GraphiQL, an interactive explorer for the GraphQL API, at /ui and to "+
+			"browsers at /graphql.")
+	flag.String("ui_assets", "",
+		"A directory with the files GraphiQL loads ("+strings.Join(web.UIAssets, ", ")+
+			"), to serve them at /ui/assets/ rather than load them from unpkg.")
+	flag.Bool("allowlist", false,
+		"Only run the queries registered with the admin API's registerQueries.")
+	flag.Bool("allowlist_introspection", false,
+		"With --allowlist, still run introspection queries, e.g. in development.")
+	flag.Float64("trace", 1.0, "The ratio of requests to trace.")
+	flag.Bool("explain", false,
+		"Let requests ask for the DQL that their operations run, with an X-Dgraph-Explain: "+
+			"true header.  The DQL shows what @auth rules add, so it's for development.")
+	flag.String("jaeger.collector", "", "Send opencensus traces to Jaeger.")
+	flag.Int("retries", 10, "How many times to retry setting up the connection to Dgraph.")
+	// TLS configuration
+	x.RegisterClientTLSFlags(flag)
+}
+
+func run(conf *viper.Viper) {
+	cfg, err := loadConfig(conf)
+	x.Check(err)
+
+	tlsCfg, err := cfg.tlsConfig()
+	x.Checkf(err, "While setting up TLS")
+
+	x.RegisterExporters(conf, "dgraph.graphql")
+	otrace.ApplyConfig(otrace.Config{DefaultSampler: otrace.ProbabilitySampler(cfg.Trace)})
+
+	dg, closeFunc := x.GetDgraphClient(conf, false, traceDgraph)
+	defer closeFunc()
+	health := dgraph.NewHealth()
+	reads := dgraph.ReadRouting{BestEffort: cfg.Reads.BestEffort}
+	if cfg.Reads.Replicas != "" {
+		replicas, closeReplicas := connectAlphas(conf, cfg.Reads.Replicas)
+		defer closeReplicas()
+		reads.Replicas = replicas
+		glog.Infof("Routing GraphQL queries to replicas %s", cfg.Reads.Replicas)
+	}
+	dgraphClient := dgraph.WithHealth(dgraph.AsDgraphWithReads(dg, reads), health)
+	go health.Watch(context.Background(), dgraphClient, healthCheckInterval)
+
+	sh := &shared{secrets: cfg.Secrets.provider()}
+	verifier, err := cfg.JWT.verifier(sh.secrets)
+	x.Checkf(err, "While setting up JWT verification")
+	sh.anonymous, err = cfg.JWT.anonymousPolicy()
+	x.Check(err)
+
+	if cfg.RateLimit.enabled() {
+		sh.limiter = resolve.NewRateLimiter(cfg.RateLimit.limits())
+	}
+
+	sh.audit, err = cfg.Audit.sinks(sh.secrets, cfg.Calls.policies())
+	x.Checkf(err, "While setting up the audit log")
+
+	sh.changes, err = cfg.CDC.sink()
+	x.Checkf(err, "While setting up change data capture")
+
+	sh.signers, err = cfg.Signers.signers(sh.secrets)
+	x.Checkf(err, "While setting up request signing")
+
+	resolver, adm := newAPI(cfg, dgraphClient, sh, "")
+	if cfg.Uploads.Dir != "" && cfg.Uploads.served() {
+		http.Handle(cfg.Uploads.URL, web.UploadsHandler(cfg.Uploads.URL, cfg.Uploads.Dir))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	if schemaFile := cfg.Schema; schemaFile != "" {
+		input, err := ioutil.ReadFile(schemaFile)
+		x.Checkf(err, "While reading GraphQL schema file %s", schemaFile)
+
+		err = adm.UpdateSchema(ctx, string(input), false)
+		x.Checkf(err, "While applying GraphQL schema")
+	} else if schemaFile := cfg.SchemaFile; schemaFile != "" {
+		// Even if the file can't be applied, the server starts, so that the
+		// file can be fixed.
+		watcher := adm.WatchSchemaFile(schemaFile)
+		_ = watcher.Apply(ctx)
+		go func() {
+			if err := watcher.Run(context.Background()); err != nil {
+				glog.Errorf("Stopped watching GraphQL schema file %s: %v", schemaFile, err)
+			}
+		}()
+		http.Handle("/dev/status", web.DevStatusHandler(watcher))
+		glog.Infof("Watching GraphQL schema file %s for changes", schemaFile)
+	} else {
+		err = adm.LoadStoredSchema(ctx)
+		x.Checkf(err, "While loading the GraphQL schema stored in Dgraph")
+		if resolver.Schema() == nil {
+			glog.Infof("No GraphQL schema yet; add one with the admin API at /admin")
+		}
+	}
+	cancel()
+
+	if interval := cfg.SchemaPollInterval; interval > 0 {
+		go adm.PollStoredSchema(context.Background(), interval)
+	}
+
+	gqlHandler := web.GraphQLHTTPHandler(resolver)
+	adminHandler := web.GraphQLHTTPHandler(adm.Resolver())
+	if cfg.Namespaces.Alphas != nil {
+		gqlNamespaces := web.Namespaces{
+			Header:   cfg.Namespaces.Header,
+			Claim:    cfg.Namespaces.Claim,
+			Handlers: make(map[string]http.Handler),
+		}
+		adminNamespaces := web.Namespaces{
+			Header:   cfg.Namespaces.Header,
+			Handlers: make(map[string]http.Handler),
+		}
+		for _, name := range cfg.Namespaces.names() {
+			nsResolver, nsAdmin, closeNamespace := namespaceAPI(conf, cfg, sh, name)
+			defer closeNamespace()
+			gqlNamespaces.Handlers[name] = web.GraphQLHTTPHandler(nsResolver)
+			adminNamespaces.Handlers[name] = web.GraphQLHTTPHandler(nsAdmin.Resolver())
+		}
+		gqlHandler = web.WithNamespaces(gqlHandler, gqlNamespaces)
+		adminHandler = web.WithNamespaces(adminHandler, adminNamespaces)
+	}
+	if sh.limiter != nil {
+		gqlHandler = web.WithRateLimit(gqlHandler, sh.limiter, cfg.RateLimit.APIKeyHeader)
+	}
+	if verifier != nil {
+		gqlHandler = web.WithJWT(gqlHandler, verifier)
+	}
+	if cfg.UI {
+		var assets string
+		if cfg.UIAssets != "" {
+			assets = "/ui/assets/"
+			http.Handle(assets, web.UIAssetsHandler(assets, cfg.UIAssets))
+		}
+		gqlHandler = web.WithUI(gqlHandler, "/graphql", assets)
+		http.Handle("/ui", web.UIHandler("/graphql", assets))
+	}
+	if cfg.Compression.Gzip {
+		gqlHandler = web.WithCompression(gqlHandler, cfg.Compression.MinSize)
+		adminHandler = web.WithCompression(adminHandler, cfg.Compression.MinSize)
+	}
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		gqlHandler = web.WithCORS(gqlHandler, cfg.CORS.cors())
+	}
+	http.Handle("/graphql", gqlHandler)
+	http.Handle("/admin", adminHandler)
+	http.Handle("/health", web.HealthHandler(health))
+	http.Handle("/probe/graphql", web.ProbeHandler(adm, false))
+	http.Handle("/probe/graphql/ready", web.ProbeHandler(adm, true))
+
+	laddr := "localhost"
+	if conf.GetBool("bindall") {
+		laddr = "0.0.0.0"
+	}
+	srv := &http.Server{Addr: fmt.Sprintf("%s:%d", laddr, cfg.Port), TLSConfig: tlsCfg}
+
+	if tlsCfg == nil {
+		glog.Infof("GraphQL server listening at http://%s/graphql", srv.Addr)
+		glog.Fatal(srv.ListenAndServe())
+	}
+	glog.Infof("GraphQL server listening at https://%s/graphql, with client auth %s",
+		srv.Addr, cfg.TLSClientAuth)
+	glog.Fatal(srv.ListenAndServeTLS("", ""))
+}
+
+// shared is what every API shares: the anonymous policy and the rate limiter,
+// so a client's limits are the same across namespaces; the audit sinks, which
+// each API adds its own Dgraph sink to if entries are stored in Dgraph; the
+// sink that changes are published to; the secrets provider, so Vault's
+// secrets are cached once; and the signers, so OAuth2 tokens are too.
+type shared struct {
+	anonymous *authorization.AnonymousPolicy
+	limiter   *resolve.RateLimiter
+	audit     []resolve.AuditSink
+	changes   resolve.ChangeSink
+	secrets   secrets.Provider
+	signers   map[string]signing.Signer
+}
+
+// newAPI builds the resolver for a GraphQL API stored in dgraphClient's
+// cluster, and the Admin that manages its schema.  namespace is "" for the
+// default API; each namespace's uploaded files are kept in a directory, and
+// served at a path, of their own.
+func newAPI(cfg *Config, dgraphClient dgraph.Client, sh *shared,
+	namespace string) (*resolve.RequestResolver, *admin.Admin) {
+
+	introspection, err := cfg.Introspection.policy()
+	x.Check(err)
+	resolver := resolve.New(nil, dgraphClient).
+		WithPollInterval(cfg.Subscriptions.PollInterval).
+		WithRateLimiter(sh.limiter).
+		WithAnonymousPolicy(sh.anonymous).
+		WithIntrospectionPolicy(introspection).
+		WithSecrets(sh.secrets).
+		WithSigners(sh.signers).
+		WithCallPolicies(cfg.Calls.policies()).
+		WithLambda(cfg.Lambda.URL).
+		WithConcurrentBatches(cfg.Batch.Concurrent).
+		WithRegisteredQueriesOnly(cfg.Allowlist).
+		WithIntrospection(cfg.AllowlistIntrospection).
+		WithExplain(cfg.Explain).
+		WithRetries(resolve.Retries{
+			Reads:  cfg.Retries.Reads.policy(),
+			Writes: cfg.Retries.Writes.policy(),
+		}).
+		WithTimeouts(resolve.Timeouts{
+			Query:        cfg.Timeouts.Query,
+			Mutation:     cfg.Timeouts.Mutation,
+			Subscription: cfg.Timeouts.Subscription,
+		}).
+		WithLimits(resolve.QueryLimits{
+			MaxDepth:      cfg.Limits.MaxDepth,
+			MaxFields:     cfg.Limits.MaxFields,
+			MaxComplexity: cfg.Limits.MaxComplexity,
+			MaxCost:       cfg.Limits.MaxCost,
+			ReportCost:    cfg.Limits.ReportCost,
+		}).
+		WithRequestLogging(resolve.RequestLogging{
+			SampleRate: cfg.RequestLog.SampleRate,
+			Errors:     cfg.RequestLog.Errors,
+			Redact:     cfg.RequestLog.Redact,
+		})
+	if cfg.Lambda.Signer != "" {
+		resolver.WithLambdaSigner(sh.signers[cfg.Lambda.Signer])
+	}
+	resolver.WithEventDelivery(cfg.Events.policy())
+	sinks := append([]resolve.AuditSink{}, sh.audit...)
+	if cfg.Audit.Dgraph {
+		sinks = append(sinks, resolve.NewDgraphAuditSink(dgraphClient))
+	}
+	resolver.WithAuditing(resolve.Auditing{
+		Sinks:      sinks,
+		Redact:     cfg.Audit.Redact,
+		ActorClaim: cfg.Audit.ActorClaim,
+		Namespace:  namespace,
+	})
+	resolver.WithChangeCapture(resolve.ChangeCapture{Sink: sh.changes, Namespace: namespace})
+	if cfg.Uploads.Dir != "" {
+		dir, url := cfg.Uploads.Dir, cfg.Uploads.URL
+		if namespace != "" {
+			dir = filepath.Join(dir, namespace)
+			url = strings.TrimSuffix(url, "/") + "/" + namespace
+		}
+		store, err := resolve.NewDirStore(dir, url)
+		x.Check(err)
+		resolver.WithFileStore(store)
+	}
+	adm, err := admin.New(dgraphClient, resolver, cfg.Remotes...)
+	x.Checkf(err, "While building the admin API")
+	schemaCheck, err := admin.ParseSchemaCheck(cfg.SchemaCheck)
+	x.Check(err)
+	adm.SetSchemaCheck(schemaCheck)
+	adm.SetSchemaWebhook(cfg.SchemaWebhook)
+	adm.SetSecrets(sh.secrets)
+	adm.SetCallPolicies(cfg.Calls.policies())
+	return resolver, adm
+}
+
+// namespaceAPI connects to the Dgraph cluster of namespace name, and builds
+// its GraphQL API, serving the schema stored there.  The connections are
+// closed by the function it returns.
+func namespaceAPI(conf *viper.Viper, cfg *Config, sh *shared,
+	name string) (*resolve.RequestResolver, *admin.Admin, func()) {
+
+	dg, closeFunc := connectAlphas(conf, cfg.Namespaces.Alphas[name])
+	health := dgraph.NewHealth()
+	reads := dgraph.ReadRouting{BestEffort: cfg.Reads.BestEffort}
+	dgraphClient := dgraph.WithHealth(dgraph.AsDgraphWithReads(dg, reads), health)
+	go health.Watch(context.Background(), dgraphClient, healthCheckInterval)
+
+	resolver, adm := newAPI(cfg, dgraphClient, sh, name)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err := adm.LoadStoredSchema(ctx)
+	x.Checkf(err, "While loading the GraphQL schema of namespace %s", name)
+	if interval := cfg.SchemaPollInterval; interval > 0 {
+		go adm.PollStoredSchema(context.Background(), interval)
+	}
+
+	glog.Infof("Serving GraphQL namespace %s from Dgraph at %s", name,
+		cfg.Namespaces.Alphas[name])
+	return resolver, adm, closeFunc
+}
+
+// traceDgraph is the dial option for the server's connections to Dgraph, so
+// that the requests resolvers make are part of the GraphQL request's trace.
+var traceDgraph = grpc.WithStatsHandler(&ocgrpc.ClientHandler{})
+
+// connectAlphas connects to the alphas at addrs, a comma-separated list.  The
+// connections are closed by the function it returns.
+func connectAlphas(conf *viper.Viper, addrs string) (*dgo.Dgraph, func()) {
+	tlsCfg, err := x.LoadClientTLSConfig(conf)
+	x.Checkf(err, "While loading TLS configuration")
+
+	var conns []*grpc.ClientConn
+	var clients []api.DgraphClient
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		conn, err := x.SetupConnection(addr, tlsCfg, false, traceDgraph)
+		x.Checkf(err, "While connecting to the alpha at %s", addr)
+		conns = append(conns, conn)
+		clients = append(clients, api.NewDgraphClient(conn))
+	}
+	return dgo.NewDgraphClient(clients...), func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+}
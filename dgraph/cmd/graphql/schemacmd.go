@@ -0,0 +1,300 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/admin"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+func validateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a GraphQL schema file without a Dgraph cluster",
+		Long: `
+Checks the schema in the file given with --schema just as the server would
+when it's applied - validating the types and directives and generating the
+complete GraphQL API from them - but offline.  Each problem is printed with
+its position in the file, as FILE:LINE:COLUMN, and if there are any the
+command fails, so schemas can be checked in CI before they're deployed.`,
+		Args: cobra.NoArgs,
+	}
+	file := cmd.Flags().String("schema", "", "File with the GraphQL schema to check.")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *file == "" {
+			return errors.New("a --schema file is needed")
+		}
+		input, err := ioutil.ReadFile(*file)
+		if err != nil {
+			return errors.Wrapf(err, "while reading schema file %s", *file)
+		}
+
+		_, err = schema.NewHandler(string(input))
+		if err == nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is a valid GraphQL schema.\n", *file)
+			return nil
+		}
+		for _, problem := range schemaProblems(*file, err) {
+			fmt.Fprintln(cmd.OutOrStdout(), problem)
+		}
+		return errors.Errorf("%s isn't a valid GraphQL schema", *file)
+	}
+	return cmd
+}
+
+// printCmd returns a command that prints what print makes of the schema in
+// the file given with --schema.
+func printCmd(use, short, long string, print func(schema.Handler) string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  long,
+		Args:  cobra.NoArgs,
+	}
+	file := cmd.Flags().String("schema", "", "File with the GraphQL schema.")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *file == "" {
+			return errors.New("a --schema file is needed")
+		}
+		handler, err := loadSchemaFile(*file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), print(handler))
+		return nil
+	}
+	return cmd
+}
+
+func printSchemaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "print-schema",
+		Short: "Print the complete GraphQL API generated from a schema file",
+		Long: `
+Prints the GraphQL SDL that's served for the schema in the file given with
+--schema: its types, along with the generated queries, mutations, inputs and
+filters.  Nothing needs to be running, so the API can be reviewed before the
+schema is applied.  --generated=false prints only the input schema's types, as
+they're completed, and --directives=false leaves out their directives.`,
+		Args: cobra.NoArgs,
+	}
+	file := cmd.Flags().String("schema", "", "File with the GraphQL schema.")
+	indent := cmd.Flags().Int("indent", 0,
+		"Indent fields with this many spaces, instead of with a tab.")
+	generated := cmd.Flags().Bool("generated", true,
+		"Print the generated types, inputs, queries and mutations.")
+	directives := cmd.Flags().Bool("directives", true, "Print the directives.")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *file == "" {
+			return errors.New("a --schema file is needed")
+		}
+		if *indent < 0 {
+			return errors.New("--indent can't be negative")
+		}
+		handler, err := loadSchemaFile(*file)
+		if err != nil {
+			return err
+		}
+		return handler.WriteGQLSchema(cmd.OutOrStdout(), schema.FormatOptions{
+			Indent:         strings.Repeat(" ", *indent),
+			OmitGenerated:  !*generated,
+			OmitDirectives: !*directives,
+		})
+	}
+	return cmd
+}
+
+func printDQLCmd() *cobra.Command {
+	return printCmd("print-dql",
+		"Print the Dgraph schema derived from a GraphQL schema file",
+		`
+Prints the Dgraph schema - the types, predicates and indexes - that applying
+the schema in the file given with --schema would alter Dgraph's schema with.
+Nothing needs to be running, so what's stored can be reviewed before the
+schema is applied.`,
+		schema.Handler.DGSchema)
+}
+
+func genGoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gen-go",
+		Short: "Generate a Go client for the GraphQL API generated from a schema file",
+		Long: `
+Prints the source of a Go package for calling the GraphQL API generated from
+the schema in the file given with --schema.  It has a struct for each type,
+input and enum that the queries and mutations use, and a function for each
+query and mutation that builds its request - the query and its variables - to
+be POSTed as JSON to the GraphQL endpoint.  The data in the response decodes
+into the operation's Response struct.  A function that would have the name of
+a type, like query country's with @api(naming: PLURAL), is named for its
+operation instead: CountryQuery.`,
+		Args: cobra.NoArgs,
+	}
+	file := cmd.Flags().String("schema", "", "File with the GraphQL schema.")
+	pkg := cmd.Flags().String("package", "client", "Name of the generated Go package.")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *file == "" {
+			return errors.New("a --schema file is needed")
+		}
+		handler, err := loadSchemaFile(*file)
+		if err != nil {
+			return err
+		}
+		src, err := handler.GoClient(*pkg)
+		if err != nil {
+			return err
+		}
+		_, err = cmd.OutOrStdout().Write(src)
+		return err
+	}
+	return cmd
+}
+
+func importDgraphCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import-dgraph",
+		Short: "Print a GraphQL schema for the data already in Dgraph",
+		Long: `
+Reads Dgraph's schema - its types, predicates and indexes - from the Alpha
+given with --alpha and prints a GraphQL schema for the data, so that an
+existing database can be served without writing its schema from scratch.
+Each Dgraph type becomes a GraphQL type, predicates that aren't named
+Type.field are mapped with @dgraph, and the indexes that @search supports are
+kept.  It's a best effort: review it before it's applied, because applying it
+alters Dgraph's schema to fit, and what couldn't be mapped is listed in
+comments at the end.  TLS is set up as for "dgraph graphql".`,
+		Args: cobra.NoArgs,
+	}
+	alpha := cmd.Flags().StringP("alpha", "a", "127.0.0.1:9080",
+		"Dgraph Alpha gRPC server address.")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		tlsCfg, err := x.LoadClientTLSConfig(GraphQL.Conf)
+		if err != nil {
+			return errors.Wrap(err, "while loading the TLS configuration")
+		}
+		conn, err := x.SetupConnection(*alpha, tlsCfg, false)
+		if err != nil {
+			return errors.Wrapf(err, "while connecting to Dgraph at %s", *alpha)
+		}
+		defer conn.Close()
+
+		dg := dgraph.AsDgraph(dgo.NewDgraphClient(api.NewDgraphClient(conn)))
+		sdl, err := admin.ImportDgraphSchema(context.Background(), dg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), sdl)
+		return nil
+	}
+	return cmd
+}
+
+func schemaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Work with GraphQL schemas",
+	}
+	diff := &cobra.Command{
+		Use:   "diff",
+		Short: "Print how the GraphQL API generated from one schema file differs from another",
+		Long: `
+Builds the GraphQL API for the schemas in the files given with --old and --new,
+just as the server would, and prints every change from the first to the second
+- the types, fields, arguments and directives that were added, removed or
+changed.  Changes that could break requests that work now are marked BREAKING,
+and if there are any, the command fails, so it can be used to check schema
+changes before they're applied.`,
+		Args: cobra.NoArgs,
+	}
+	oldFile := diff.Flags().String("old", "", "File with the schema being served.")
+	newFile := diff.Flags().String("new", "", "File with the schema to compare with it.")
+	diff.RunE = func(cmd *cobra.Command, args []string) error {
+		if *oldFile == "" || *newFile == "" {
+			return errors.New("both --old and --new schema files are needed")
+		}
+		oldSch, err := loadSchemaFile(*oldFile)
+		if err != nil {
+			return err
+		}
+		newSch, err := loadSchemaFile(*newFile)
+		if err != nil {
+			return err
+		}
+
+		breaking := 0
+		for _, change := range schema.Changelog(oldSch.Schema(), newSch.Schema()) {
+			marker := "         "
+			if change.Breaking {
+				marker = "BREAKING "
+				breaking++
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), marker+change.Message)
+		}
+		if breaking > 0 {
+			return errors.Errorf("%d of the changes could break requests", breaking)
+		}
+		return nil
+	}
+	cmd.AddCommand(diff)
+	return cmd
+}
+
+// loadSchemaFile builds the schema in file, just as the server would.
+func loadSchemaFile(file string) (schema.Handler, error) {
+	input, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while reading schema file %s", file)
+	}
+	handler, err := schema.NewHandler(string(input))
+	if err != nil {
+		return nil, errors.Errorf("couldn't build the schema in %s:\n%s",
+			file, strings.Join(schemaProblems(file, err), "\n"))
+	}
+	return handler, nil
+}
+
+// schemaProblems describes the errors from building the schema in file, each
+// one as "FILE:LINE:COLUMN: message" if its position is known.
+func schemaProblems(file string, err error) []string {
+	list, ok := err.(gqlerror.List)
+	if !ok {
+		return []string{fmt.Sprintf("%s: %s", file, err)}
+	}
+
+	problems := make([]string, len(list))
+	for i, gqlErr := range list {
+		pos := file
+		if len(gqlErr.Locations) > 0 {
+			loc := gqlErr.Locations[0]
+			pos = fmt.Sprintf("%s:%d:%d", file, loc.Line, loc.Column)
+		}
+		problems[i] = pos + ": " + gqlErr.Message
+	}
+	return problems
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// runSchemaFileCmd runs cmd with sch in the file it's given with --schema,
+// and args, returning what it prints.
+func runSchemaFileCmd(t *testing.T, cmd *cobra.Command, sch string,
+	args ...string) (string, error) {
+
+	dir, err := ioutil.TempDir("", "graphql-schema")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "schema.graphql")
+	require.NoError(t, ioutil.WriteFile(file, []byte(sch), 0644))
+
+	var out bytes.Buffer
+	cmd.SetOutput(&out)
+	cmd.SetArgs(append([]string{"--schema", file}, args...))
+	cmd.SilenceUsage = true
+	err = cmd.Execute()
+	return out.String(), err
+}
+
+func TestValidateCmd(t *testing.T) {
+	run := func(sch string) (string, error) {
+		return runSchemaFileCmd(t, validateCmd(), sch)
+	}
+
+	out, err := run(`type Author { id: ID! name: String! }`)
+	require.NoError(t, err)
+	require.Contains(t, out, "schema.graphql is a valid GraphQL schema.")
+
+	out, err = run("type Author {\n  id: ID!\n  name: Strin\n}")
+	require.Error(t, err)
+	require.Contains(t, out, "schema.graphql:3:9: Undefined type Strin.")
+}
+
+func TestSchemaFileCmds(t *testing.T) {
+	sch := `type Author { id: ID! name: String! @search(by: [hash]) }`
+
+	out, err := runSchemaFileCmd(t, printSchemaCmd(), sch)
+	require.NoError(t, err)
+	require.Contains(t, out, "type Author {")
+	require.Contains(t, out, "getAuthor(id: ID!): Author")
+	require.Contains(t, out, "addAuthor(input: [AddAuthorInput!]!): AddAuthorPayload")
+
+	out, err = runSchemaFileCmd(t, printSchemaCmd(), sch,
+		"--generated=false", "--directives=false", "--indent", "2")
+	require.NoError(t, err)
+	require.Contains(t, out, "type Author {\n  id: ID!\n  name: String!\n}")
+	require.NotContains(t, out, "getAuthor")
+
+	out, err = runSchemaFileCmd(t, printDQLCmd(), sch)
+	require.NoError(t, err)
+	require.Contains(t, out, "Author.name: string @index(hash) .")
+
+	out, err = runSchemaFileCmd(t, genGoCmd(), sch)
+	require.NoError(t, err)
+	require.Contains(t, out, "package client\n")
+	require.Contains(t, out, "func GetAuthor(args GetAuthorArgs, selection string) *GraphQLRequest {")
+
+	_, err = runSchemaFileCmd(t, printDQLCmd(), `type Author { name: Strin }`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "schema.graphql:1:21: Undefined type Strin.")
+}
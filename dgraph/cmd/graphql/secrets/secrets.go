@@ -0,0 +1,237 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package secrets resolves named secrets that are referenced from the GraphQL
+// layer's directive configuration (e.g. @custom URLs and headers, @lambda keys
+// and JWT verification keys).
+//
+// A GraphQL schema is stored in Dgraph and is readable through the admin API,
+// so it must never contain credentials.  Instead, a schema references a secret
+// by name, as in
+//
+//	@custom(http: {url: "https://api.example.com/?key={{secrets.API_KEY}}"})
+//
+// and the reference is expanded at request time from the configured providers
+// (the environment, Vault, ...).
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned by a Provider that doesn't know the requested secret.
+var ErrNotFound = errors.New("secret not found")
+
+var secretRef = regexp.MustCompile(`{{\s*secrets\.([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// A Provider looks up the value of a named secret.
+type Provider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// References returns the names of all the secrets referenced in s, in the order
+// they first appear.
+func References(s string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range secretRef.FindAllStringSubmatch(s, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// Expand replaces every secret reference in s with the value that p returns
+// for it.  It's an error for a referenced secret to be missing.
+func Expand(ctx context.Context, p Provider, s string) (string, error) {
+	names := References(s)
+	if len(names) == 0 {
+		return s, nil
+	}
+	if p == nil {
+		return "", errors.Errorf("secret %q referenced, but no secret provider is configured",
+			names[0])
+	}
+
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		val, err := p.Secret(ctx, name)
+		if err != nil {
+			return "", errors.Wrapf(err, "while resolving secret %q", name)
+		}
+		values[name] = val
+	}
+
+	return secretRef.ReplaceAllStringFunc(s, func(ref string) string {
+		return values[secretRef.FindStringSubmatch(ref)[1]]
+	}), nil
+}
+
+type envProvider struct {
+	prefix string
+}
+
+// NewEnvProvider returns a Provider that reads a secret NAME from the
+// environment variable prefix + NAME.
+func NewEnvProvider(prefix string) Provider {
+	return &envProvider{prefix: prefix}
+}
+
+func (e *envProvider) Secret(ctx context.Context, name string) (string, error) {
+	if val, ok := os.LookupEnv(e.prefix + name); ok {
+		return val, nil
+	}
+	return "", ErrNotFound
+}
+
+type vaultProvider struct {
+	addr   string
+	token  string
+	path   string
+	client *http.Client
+}
+
+// NewVaultProvider returns a Provider that reads secrets from the key/value
+// secret at path in the Vault server at addr.  Both version 1 and version 2 of
+// Vault's kv secrets engine are understood.
+func NewVaultProvider(addr, token, path string) Provider {
+	return &vaultProvider{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		path:   strings.Trim(path, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *vaultProvider) Secret(ctx context.Context, name string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/%s", v.addr, v.path), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "while contacting Vault")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "while reading Vault response")
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return "", errors.Errorf("Vault responded with status %d: %s",
+			resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", errors.Wrap(err, "while decoding Vault response")
+	}
+
+	data := secret.Data
+	// kv version 2 nests the secret's data and adds metadata alongside it.
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	val, ok := data[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if s, ok := val.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprintf("%v", val), nil
+}
+
+type chain []Provider
+
+// Chain returns a Provider that asks each of providers in turn and returns the
+// first value found.
+func Chain(providers ...Provider) Provider {
+	return chain(providers)
+}
+
+func (c chain) Secret(ctx context.Context, name string) (string, error) {
+	for _, p := range c {
+		val, err := p.Secret(ctx, name)
+		if err == ErrNotFound {
+			continue
+		}
+		return val, err
+	}
+	return "", ErrNotFound
+}
+
+type cacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+type cache struct {
+	sync.Mutex
+	p       Provider
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+// WithCache returns a Provider that remembers the values found by p for ttl, so
+// that a remote secret store isn't queried on every request.
+func WithCache(p Provider, ttl time.Duration) Provider {
+	return &cache{p: p, ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+func (c *cache) Secret(ctx context.Context, name string) (string, error) {
+	c.Lock()
+	entry, ok := c.entries[name]
+	c.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	val, err := c.p.Secret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	c.Lock()
+	c.entries[name] = cacheEntry{value: val, expires: time.Now().Add(c.ttl)}
+	c.Unlock()
+	return val, nil
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingProvider struct {
+	calls int
+}
+
+func (c *countingProvider) Secret(ctx context.Context, name string) (string, error) {
+	c.calls++
+	return fmt.Sprintf("value-%d", c.calls), nil
+}
+
+func TestReferences(t *testing.T) {
+	require.Equal(t, []string{"A", "B_2"},
+		References("http://x/{{secrets.A}}?k={{ secrets.B_2 }}&again={{secrets.A}}"))
+	require.Empty(t, References("http://x/$id"))
+}
+
+func TestExpandFromEnv(t *testing.T) {
+	require.NoError(t, os.Setenv("TEST_SECRET_KEY", "s3cr3t"))
+	defer os.Unsetenv("TEST_SECRET_KEY")
+
+	p := NewEnvProvider("TEST_SECRET_")
+	got, err := Expand(context.Background(), p, "Bearer {{secrets.KEY}}")
+	require.NoError(t, err)
+	require.Equal(t, "Bearer s3cr3t", got)
+
+	_, err = Expand(context.Background(), p, "{{secrets.MISSING}}")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"MISSING"`)
+}
+
+func TestExpandWithoutProvider(t *testing.T) {
+	got, err := Expand(context.Background(), nil, "no references")
+	require.NoError(t, err)
+	require.Equal(t, "no references", got)
+
+	_, err = Expand(context.Background(), nil, "{{secrets.KEY}}")
+	require.Error(t, err)
+}
+
+func TestVaultProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/graphql":
+			fmt.Fprint(w, `{"data": {"data": {"KEY": "from-v2"}, "metadata": {"version": 1}}}`)
+		case "/v1/kv/graphql":
+			fmt.Fprint(w, `{"data": {"KEY": "from-v1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	val, err := NewVaultProvider(srv.URL, "root", "secret/data/graphql").Secret(ctx, "KEY")
+	require.NoError(t, err)
+	require.Equal(t, "from-v2", val)
+
+	val, err = NewVaultProvider(srv.URL, "root", "/kv/graphql/").Secret(ctx, "KEY")
+	require.NoError(t, err)
+	require.Equal(t, "from-v1", val)
+
+	_, err = NewVaultProvider(srv.URL, "root", "kv/graphql").Secret(ctx, "OTHER")
+	require.Equal(t, ErrNotFound, err)
+
+	_, err = NewVaultProvider(srv.URL, "wrong", "kv/graphql").Secret(ctx, "KEY")
+	require.Error(t, err)
+	require.NotEqual(t, ErrNotFound, err)
+}
+
+func TestChain(t *testing.T) {
+	require.NoError(t, os.Setenv("TEST_CHAIN_B", "b"))
+	defer os.Unsetenv("TEST_CHAIN_B")
+
+	p := Chain(NewEnvProvider("TEST_CHAIN_"), &countingProvider{})
+	val, err := p.Secret(context.Background(), "B")
+	require.NoError(t, err)
+	require.Equal(t, "b", val)
+
+	val, err = p.Secret(context.Background(), "C")
+	require.NoError(t, err)
+	require.Equal(t, "value-1", val)
+
+	_, err = Chain().Secret(context.Background(), "B")
+	require.Equal(t, ErrNotFound, err)
+}
+
+func TestCache(t *testing.T) {
+	cp := &countingProvider{}
+	p := WithCache(cp, time.Hour)
+	for i := 0; i < 3; i++ {
+		val, err := p.Secret(context.Background(), "KEY")
+		require.NoError(t, err)
+		require.Equal(t, "value-1", val)
+	}
+	require.Equal(t, 1, cp.calls)
+
+	expiring := WithCache(cp, 0)
+	_, err := expiring.Secret(context.Background(), "KEY")
+	require.NoError(t, err)
+	val, err := expiring.Secret(context.Background(), "KEY")
+	require.NoError(t, err)
+	require.Equal(t, "value-3", val)
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signing
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// expiryDelta is how long before its expiry a cached token is refreshed, so a
+// token doesn't expire while a request is in flight.
+const expiryDelta = 10 * time.Second
+
+// defaultTokenLifetime is used when the token endpoint doesn't say how long a
+// token lasts.
+const defaultTokenLifetime = 5 * time.Minute
+
+// ClientCredentials configures an OAuth2 client credentials grant.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// OAuth2Signer adds a bearer token, fetched with the OAuth2 client
+// credentials grant, to requests.  Tokens are cached and shared by all the
+// requests it signs until they are about to expire.
+type OAuth2Signer struct {
+	conf   ClientCredentials
+	client *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// NewOAuth2Signer returns a Signer that authenticates requests with tokens
+// from the token endpoint in conf.  Tokens are fetched with client, or
+// http.DefaultClient if client is nil.
+func NewOAuth2Signer(conf ClientCredentials, client *http.Client) *OAuth2Signer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OAuth2Signer{conf: conf, client: client, now: time.Now}
+}
+
+// Sign sets the Authorization header of req to a valid bearer token.
+func (s *OAuth2Signer) Sign(req *http.Request) error {
+	token, err := s.Token(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token returns the cached token, or fetches a new one if there's no cached
+// token or it's about to expire.  The token is fetched with req's context.
+func (s *OAuth2Signer) Token(req *http.Request) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && s.now().Add(expiryDelta).Before(s.expiry) {
+		return s.token, nil
+	}
+
+	token, lifetime, err := s.fetchToken(req)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	s.expiry = s.now().Add(lifetime)
+	return s.token, nil
+}
+
+func (s *OAuth2Signer) fetchToken(orig *http.Request) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.conf.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.conf.Scopes, " "))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.conf.TokenURL,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, errors.Wrap(err, "while building OAuth2 token request")
+	}
+	req = req.WithContext(orig.Context())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.conf.ClientID), url.QueryEscape(s.conf.ClientSecret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "while fetching OAuth2 token")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, errors.Wrap(err, "while reading OAuth2 token response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, errors.Errorf("OAuth2 token endpoint returned %s: %s",
+			resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return "", 0, errors.Wrap(err, "while parsing OAuth2 token response")
+	}
+	if tok.AccessToken == "" {
+		return "", 0, errors.New("OAuth2 token response had no access_token")
+	}
+	if tok.TokenType != "" && !strings.EqualFold(tok.TokenType, "bearer") {
+		return "", 0, errors.Errorf("OAuth2 token type %s isn't supported", tok.TokenType)
+	}
+
+	lifetime := defaultTokenLifetime
+	if tok.ExpiresIn > 0 {
+		lifetime = time.Duration(tok.ExpiresIn) * time.Second
+	}
+	return tok.AccessToken, lifetime, nil
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package signing signs the HTTP requests the GraphQL layer makes to external
+// services (e.g. for @custom fields), so those services can authenticate that
+// a request really came from Dgraph.
+//
+// Two schemes are supported:
+//   - HMAC: each request carries a timestamp and an HMAC-SHA256 signature of
+//     the method, URL, timestamp and body, made with a shared secret.
+//   - OAuth2 client credentials: a bearer token is fetched from a token
+//     endpoint, cached until shortly before it expires, and added to each
+//     request.
+package signing
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// A Signer adds authentication to an outgoing HTTP request.
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// NewTransport returns an http.RoundTripper that signs every request with s
+// before sending it with base.  If base is nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, s Signer) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, signer: s}
+}
+
+type transport struct {
+	base   http.RoundTripper
+	signer Signer
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper mustn't modify the request it's given.
+	signed := req.WithContext(req.Context())
+	signed.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		signed.Header[k] = append([]string(nil), v...)
+	}
+
+	if err := t.signer.Sign(signed); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(signed)
+}
+
+// readBody reads the body of req, leaving the body in place so the request
+// can still be sent.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading request body for signing")
+	}
+	if err := req.Body.Close(); err != nil {
+		return nil, errors.Wrap(err, "while reading request body for signing")
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMACSignAndVerify(t *testing.T) {
+	signer := NewHMACSigner("s3cret")
+
+	req := httptest.NewRequest(http.MethodPost, "http://svc/users?id=1",
+		strings.NewReader(`{"q": 1}`))
+	require.NoError(t, signer.Sign(req))
+	require.NotEmpty(t, req.Header.Get(TimestampHeader))
+	require.True(t, strings.HasPrefix(req.Header.Get(SignatureHeader), "sha256="))
+
+	// Signing leaves the body to be sent.
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"q": 1}`, string(body))
+
+	req.Body = ioutil.NopCloser(strings.NewReader(`{"q": 1}`))
+	require.NoError(t, VerifyHMAC(req, "s3cret", time.Minute))
+
+	req.Body = ioutil.NopCloser(strings.NewReader(`{"q": 1}`))
+	require.EqualError(t, VerifyHMAC(req, "wrong", time.Minute),
+		"request signature doesn't match")
+
+	req.Body = ioutil.NopCloser(strings.NewReader(`{"q": 2}`))
+	require.EqualError(t, VerifyHMAC(req, "s3cret", time.Minute),
+		"request signature doesn't match")
+}
+
+func TestHMACVerifyExpired(t *testing.T) {
+	signer := NewHMACSigner("s3cret")
+	signer.now = func() time.Time { return time.Now().Add(-time.Hour) }
+
+	req := httptest.NewRequest(http.MethodGet, "http://svc/users", nil)
+	require.NoError(t, signer.Sign(req))
+	require.EqualError(t, VerifyHMAC(req, "s3cret", time.Minute),
+		"request signature has expired")
+
+	unsigned := httptest.NewRequest(http.MethodGet, "http://svc/users", nil)
+	require.EqualError(t, VerifyHMAC(unsigned, "s3cret", time.Minute),
+		"request isn't signed")
+}
+
+func TestOAuth2TokenCaching(t *testing.T) {
+	fetches := 0
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "client", id)
+		require.Equal(t, "pass", secret)
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		require.Equal(t, "read write", r.Form.Get("scope"))
+
+		fetches++
+		fmt.Fprintf(w, `{"access_token": "tok%d", "token_type": "bearer", "expires_in": 60}`,
+			fetches)
+	}))
+	defer tokenSrv.Close()
+
+	now := time.Now()
+	signer := NewOAuth2Signer(ClientCredentials{
+		TokenURL:     tokenSrv.URL,
+		ClientID:     "client",
+		ClientSecret: "pass",
+		Scopes:       []string{"read", "write"},
+	}, nil)
+	signer.now = func() time.Time { return now }
+
+	sign := func() string {
+		req := httptest.NewRequest(http.MethodGet, "http://svc/", nil)
+		require.NoError(t, signer.Sign(req))
+		return req.Header.Get("Authorization")
+	}
+
+	require.Equal(t, "Bearer tok1", sign())
+	require.Equal(t, "Bearer tok1", sign())
+	require.Equal(t, 1, fetches)
+
+	// Close to expiry, the token is refreshed.
+	now = now.Add(55 * time.Second)
+	require.Equal(t, "Bearer tok2", sign())
+	require.Equal(t, 2, fetches)
+}
+
+func TestOAuth2TokenError(t *testing.T) {
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+	}))
+	defer tokenSrv.Close()
+
+	signer := NewOAuth2Signer(ClientCredentials{TokenURL: tokenSrv.URL}, nil)
+	err := signer.Sign(httptest.NewRequest(http.MethodGet, "http://svc/", nil))
+	require.EqualError(t, err,
+		"OAuth2 token endpoint returned 401 Unauthorized: invalid_client")
+}
+
+func TestTransportSignsRequests(t *testing.T) {
+	svc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyHMAC(r, "s3cret", time.Minute); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer svc.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, NewHMACSigner("s3cret"))}
+	resp, err := client.Post(svc.URL+"/hook", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	require.Equal(t, "ok", string(body))
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/pkg/errors"
+)
+
+type contextKey int
+
+const verifierKey contextKey = iota
+
+// WithJWT authenticates the requests that reach handler with the JWT in the
+// header that verifier reads.  The claims of a valid token are added to the
+// request's context, for @auth rules and custom resolvers.  A request without
+// a token is passed on as anonymous, and a request with an invalid token is
+// refused with an HTTP 401.
+//
+// WebSocket clients can't always set headers, so they can also send the
+// token in the connection_init payload, keyed by the header's name.
+func WithJWT(handler http.Handler, verifier *authorization.Verifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(requestContext(w, r), verifierKey, verifier)
+
+		if token := authorization.Token(r.Header.Get(verifier.Header())); token != "" {
+			claims, err := verifier.Verify(token)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				write(w, resolve.ErrorResponse(ctx, resolve.UnauthenticatedCode,
+					errors.Wrap(err, "Unauthorized")))
+				return
+			}
+			ctx = authorization.WithClaims(ctx, claims)
+		}
+
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// verifierFrom returns the verifier that WithJWT added to ctx, or nil.
+func verifierFrom(ctx context.Context) *authorization.Verifier {
+	v, _ := ctx.Value(verifierKey).(*authorization.Verifier)
+	return v
+}
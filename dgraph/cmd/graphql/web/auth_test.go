@@ -0,0 +1,164 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+// jwtServer serves a schema where getAuthor answers with the USER claim, and
+// anonymous requests can only run queries other than queryAuthor.
+func jwtServer(t *testing.T) *httptest.Server {
+	handler, err := schema.NewHandler(`type Author { id: ID! name: String! }`)
+	require.NoError(t, err)
+
+	policy, err := authorization.NewAnonymousPolicy(authorization.Query)
+	require.NoError(t, err)
+	resolver := resolve.New(handler.Schema(), &staticDgraph{}).
+		WithPollInterval(time.Hour).
+		WithAnonymousPolicy(policy.Deny("queryAuthor")).
+		WithFieldResolver("getAuthor",
+			func(ctx context.Context, field schema.Field) (interface{}, error) {
+				name, _ := authorization.Claims(ctx)["USER"].(string)
+				return map[string]interface{}{"name": name}, nil
+			})
+
+	verifier, err := authorization.NewVerifier(authorization.JWTConfig{HMACSecret: "secret"})
+	require.NoError(t, err)
+	return httptest.NewServer(WithJWT(GraphQLHTTPHandler(resolver), verifier))
+}
+
+func token(t *testing.T, secret string, user string) string {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256,
+		jwt.MapClaims{"USER": user}).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return signed
+}
+
+func TestWithJWT(t *testing.T) {
+	srv := jwtServer(t)
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		token    string
+		query    string
+		status   int
+		response string
+	}{
+		{
+			name:     "valid token",
+			token:    "Bearer " + token(t, "secret", "alice"),
+			query:    `{ getAuthor(id: "0x1") { name } }`,
+			status:   http.StatusOK,
+			response: `{"data": {"getAuthor": {"name": "alice"}}}`,
+		},
+		{
+			name:     "anonymous query",
+			query:    `{ getAuthor(id: "0x1") { name } }`,
+			status:   http.StatusOK,
+			response: `{"data": {"getAuthor": {"name": ""}}}`,
+		},
+		{
+			name:   "anonymous mutation",
+			query:  `mutation { deleteAuthor(filter: {id: ["0x1"]}) { msg } }`,
+			status: http.StatusOK,
+			response: `{"errors": [{"message": "A valid JWT is required to run mutations",
+				"extensions": {"code": "UNAUTHENTICATED", "requestId": "req-1"}}]}`,
+		},
+		{
+			name:   "anonymous query that's denied by name",
+			query:  `{ getAuthor(id: "0x1") { name } queryAuthor { name } }`,
+			status: http.StatusOK,
+			response: `{"errors": [{"message": "A valid JWT is required to run queryAuthor",
+				"extensions": {"code": "UNAUTHENTICATED", "requestId": "req-1"}}]}`,
+		},
+		{
+			name:   "invalid token",
+			token:  token(t, "guess", "mallory"),
+			query:  `{ getAuthor(id: "0x1") { name } }`,
+			status: http.StatusUnauthorized,
+			response: `{"errors": [{"message":
+				"Unauthorized: invalid JWT: signature is invalid",
+				"extensions": {"code": "UNAUTHENTICATED", "requestId": "req-1"}}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(test.query))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/graphql")
+			req.Header.Set("X-Request-Id", "req-1")
+			if test.token != "" {
+				req.Header.Set("Authorization", test.token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, test.status, resp.StatusCode)
+			require.JSONEq(t, test.response, string(body))
+		})
+	}
+}
+
+func TestWebSocketJWT(t *testing.T) {
+	srv := jwtServer(t)
+	defer srv.Close()
+
+	c := dialWS(t, srv, "graphql-transport-ws")
+	defer c.conn.Close()
+	c.send(`{"type": "connection_init"}`)
+	c.expect(`{"type": "connection_ack"}`)
+	c.send(`{"id": "1", "type": "subscribe",
+		"payload": {"query": "subscription { subscribeAuthor { name } }"}}`)
+	c.expect(`{"id": "1", "type": "error", "payload": [{"message":
+		"A valid JWT is required to run subscriptions",
+		"extensions": {"code": "UNAUTHENTICATED", "requestId": "conn-1/1"}}]}`)
+
+	c = dialWS(t, srv, "graphql-transport-ws")
+	defer c.conn.Close()
+	c.send(`{"type": "connection_init",
+		"payload": {"authorization": "Bearer ` + token(t, "secret", "bob") + `"}}`)
+	c.expect(`{"type": "connection_ack"}`)
+	c.send(`{"id": "1", "type": "subscribe",
+		"payload": {"query": "{ getAuthor(id: \"0x1\") { name } }"}}`)
+	c.expect(`{"id": "1", "type": "next", "payload": {"data": {"getAuthor": {"name": "bob"}}}}`)
+	c.expect(`{"id": "1", "type": "complete"}`)
+
+	c = dialWS(t, srv, "graphql-transport-ws")
+	defer c.conn.Close()
+	c.send(`{"type": "connection_init",
+		"payload": {"Authorization": "` + token(t, "guess", "mallory") + `"}}`)
+	c.expectClose(closeForbidden)
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// gzipWriters are reused across responses; a gzip.Writer is expensive to
+// allocate.
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// WithCompression gzips handler's responses to requests that accept gzip
+// encoding.  Responses shorter than minSize bytes aren't worth compressing,
+// and are sent as they are.  A response is buffered only until it reaches
+// minSize; after that, it's compressed as it's written.
+func WithCompression(handler http.Handler, minSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWebSocketUpgrade(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+		defer func() {
+			if err := gw.close(); err != nil {
+				glog.Errorf("Error compressing response: %v", err)
+			}
+		}()
+		handler.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip returns true if r's Accept-Encoding header allows gzip: if it
+// gives gzip a q-value above 0, or, if it doesn't mention gzip, gives * one.
+func acceptsGzip(r *http.Request) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, v := range r.Header["Accept-Encoding"] {
+		for _, enc := range strings.Split(v, ",") {
+			params := strings.Split(enc, ";")
+			name := strings.TrimSpace(params[0])
+			if !strings.EqualFold(name, "gzip") && name != "*" {
+				continue
+			}
+			q := 1.0
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if len(param) > 2 && strings.EqualFold(param[:2], "q=") {
+					q, _ = strconv.ParseFloat(param[2:], 64)
+				}
+			}
+			if name == "*" {
+				anyQ = q
+			} else {
+				gzipQ = q
+			}
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
+
+// gzipResponseWriter holds back the start of a response until it knows
+// whether the response is long enough to compress.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status int
+	buf    []byte
+
+	// started is set once the header's been written, and gz if the body is
+	// being compressed.
+	started bool
+	gz      *gzip.Writer
+}
+
+func (gw *gzipResponseWriter) WriteHeader(status int) {
+	if gw.status == 0 {
+		gw.status = status
+	}
+}
+
+func (gw *gzipResponseWriter) Write(p []byte) (int, error) {
+	if gw.status == 0 {
+		gw.status = http.StatusOK
+	}
+	switch {
+	case gw.gz != nil:
+		return gw.gz.Write(p)
+	case gw.started:
+		return gw.ResponseWriter.Write(p)
+	}
+
+	gw.buf = append(gw.buf, p...)
+	if len(gw.buf) < gw.minSize {
+		return len(p), nil
+	}
+	if err := gw.start(true); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start writes the header, and what's been buffered of the body, compressed
+// if compress is true and the handler hasn't already encoded the body.
+func (gw *gzipResponseWriter) start(compress bool) error {
+	gw.started = true
+	h := gw.Header()
+	if compress && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		gw.gz = gzipWriters.Get().(*gzip.Writer)
+		gw.gz.Reset(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(gw.status)
+
+	buf := gw.buf
+	gw.buf = nil
+	var err error
+	if gw.gz != nil {
+		_, err = gw.gz.Write(buf)
+	} else if len(buf) > 0 {
+		_, err = gw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends what's been written so far, compressed, so that a response
+// that's streamed isn't held back until it's long enough to compress.
+func (gw *gzipResponseWriter) Flush() {
+	if gw.status == 0 {
+		return
+	}
+	if !gw.started {
+		if err := gw.start(true); err != nil {
+			glog.Errorf("Error compressing response: %v", err)
+			return
+		}
+	}
+	if gw.gz != nil {
+		if err := gw.gz.Flush(); err != nil {
+			glog.Errorf("Error compressing response: %v", err)
+			return
+		}
+	}
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close finishes the response: it flushes the compressed body, or sends a
+// response that was too short to compress.
+func (gw *gzipResponseWriter) close() error {
+	if gw.gz != nil {
+		err := gw.gz.Close()
+		gzipWriters.Put(gw.gz)
+		gw.gz = nil
+		return err
+	}
+	if gw.started || gw.status == 0 {
+		return nil
+	}
+	return gw.start(false)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCompression(t *testing.T) {
+	long := `{"data": {"queryAuthor": [` +
+		strings.Repeat(`{"name": "A.N. Author"},`, 100) + `{"name": "Last"}]}}`
+	short := `{"data": {"getAuthor": null}}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		status         int
+		body           string
+		gzipped        bool
+	}{
+		{name: "long", acceptEncoding: "gzip, deflate, br", status: http.StatusOK,
+			body: long, gzipped: true},
+		{name: "long error", acceptEncoding: "gzip", status: http.StatusBadRequest,
+			body: long, gzipped: true},
+		{name: "short", acceptEncoding: "gzip", status: http.StatusOK, body: short},
+		{name: "no body", acceptEncoding: "gzip", status: http.StatusNoContent},
+		{name: "gzip not accepted", status: http.StatusOK, body: long},
+		{name: "gzip refused", acceptEncoding: "gzip;q=0, identity", status: http.StatusOK,
+			body: long},
+		{name: "anything accepted", acceptEncoding: "*", status: http.StatusOK, body: long,
+			gzipped: true},
+		{name: "anything but gzip accepted", acceptEncoding: "*;q=0.5, gzip;q=0",
+			status: http.StatusOK, body: long},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := WithCompression(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(test.status)
+					// written in pieces, like a response is streamed
+					for body := test.body; body != ""; {
+						n := 100
+						if n > len(body) {
+							n = len(body)
+						}
+						_, err := w.Write([]byte(body[:n]))
+						require.NoError(t, err)
+						body = body[n:]
+					}
+				}), 1024)
+
+			req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, test.status, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			if !test.gzipped {
+				require.Empty(t, rec.Header().Get("Content-Encoding"))
+				require.Equal(t, test.body, rec.Body.String())
+				return
+			}
+
+			require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+			require.True(t, rec.Body.Len() < len(test.body))
+			gz, err := gzip.NewReader(rec.Body)
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(gz)
+			require.NoError(t, err)
+			require.Equal(t, test.body, string(body))
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for acceptEncoding, accepted := range map[string]bool{
+		"":                        false,
+		"gzip":                    true,
+		"GZIP;Q=0.5":              true,
+		"gzip;q=0.000":            false,
+		"deflate, br":             false,
+		"*":                       true,
+		"*;q=0":                   false,
+		"*;q=0.5, gzip;q=0":       false,
+		"gzip;q=0, *":             false,
+		"*;q=0, gzip;q=0.1":       true,
+		"br;q=1.0, *;q=0.1":       true,
+		"identity, deflate;q=0.5": false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/graphql", nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		require.Equal(t, accepted, acceptsGzip(r), "Accept-Encoding: %s", acceptEncoding)
+	}
+}
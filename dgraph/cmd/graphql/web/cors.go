@@ -0,0 +1,141 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSHeaders are the request headers that cross-origin requests can
+// send, if CORS.AllowedHeaders isn't set.
+var DefaultCORSHeaders = []string{"Content-Type", "Authorization", requestIDHeader, timeoutHeader}
+
+// CORS is which cross-origin requests - requests that browsers make for pages
+// served from other origins - WithCORS allows.
+type CORS struct {
+	// AllowedOrigins are the origins, like https://app.example.com, whose
+	// pages can call the API.  An origin's host can start with a wildcard,
+	// as in https://*.example.com, and "*" allows every origin.
+	AllowedOrigins []string
+
+	// AllowedHeaders are the request headers that the pages can send.
+	AllowedHeaders []string
+
+	// AllowCredentials lets the pages send cookies and HTTP authentication.
+	// It can't be used with an AllowedOrigins of "*".
+	AllowCredentials bool
+
+	// MaxAge is how long browsers can cache the answer to a preflight
+	// request; 0 leaves it to the browser.
+	MaxAge time.Duration
+}
+
+// WithCORS answers the CORS preflight requests for handler, and adds the CORS
+// headers to the responses to requests from the origins that cors allows.
+// Requests without an Origin header, and those from other origins, are
+// passed on unchanged, so it's browsers that stop pages from other origins
+// reading the responses.
+func WithCORS(handler http.Handler, cors CORS) http.Handler {
+	headers := cors.AllowedHeaders
+	if headers == nil {
+		headers = DefaultCORSHeaders
+	}
+	allowHeaders := strings.Join(headers, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions &&
+			r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !cors.allows(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if cors.allowsAll() && !cors.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cors.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", "GET, POST")
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			if cors.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.Set("Access-Control-Expose-Headers", requestIDHeader)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// allowsAll returns true if cors allows every origin.
+func (cors CORS) allowsAll() bool {
+	for _, allowed := range cors.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if cors allows requests from origin.
+func (cors CORS) allows(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range cors.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		// https://*.example.com matches https://app.example.com, but not
+		// https://example.com.
+		if i := strings.Index(allowed, "://*."); i >= 0 {
+			scheme, domain := allowed[:i+len("://")], allowed[i+len("://*"):]
+			if len(origin) > len(scheme)+len(domain) &&
+				strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
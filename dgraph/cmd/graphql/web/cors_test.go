@@ -0,0 +1,125 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	handler := WithCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("preflight requests aren't passed on")
+	}), CORS{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	tests := []struct {
+		origin  string
+		status  int
+		allowed bool
+	}{
+		{origin: "https://app.example.com", status: http.StatusNoContent, allowed: true},
+		{origin: "https://APP.example.com", status: http.StatusNoContent, allowed: true},
+		{origin: "https://shop.example.org", status: http.StatusNoContent, allowed: true},
+		{origin: "https://example.org", status: http.StatusForbidden},
+		{origin: "http://app.example.com", status: http.StatusForbidden},
+		{origin: "https://evil.com", status: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.origin, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/graphql", nil)
+			req.Header.Set("Origin", test.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "content-type")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, test.status, rec.Code)
+			require.Contains(t, rec.Header()["Vary"], "Origin")
+			if !test.allowed {
+				require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+				return
+			}
+			require.Equal(t, test.origin, rec.Header().Get("Access-Control-Allow-Origin"))
+			require.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+			require.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+			require.Equal(t, strings.Join(DefaultCORSHeaders, ", "),
+				rec.Header().Get("Access-Control-Allow-Headers"))
+			require.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+		})
+	}
+}
+
+func TestCORSRequests(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	handler := WithCORS(srv.Config.Handler, CORS{
+		AllowedOrigins: []string{"*"},
+		AllowedHeaders: []string{"Content-Type", "X-Auth-Token"},
+	})
+	query := `{ getAuthor(id: "0x1") { name } }`
+
+	tests := []struct {
+		name   string
+		origin string
+		allow  string
+	}{
+		{name: "cross-origin", origin: "https://app.example.com", allow: "*"},
+		{name: "not from a browser"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(query))
+			req.Header.Set("Content-Type", "application/graphql")
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.JSONEq(t, `{"data": {"getAuthor": {"name": "A.N. Author"}}}`,
+				rec.Body.String())
+			require.Equal(t, test.allow, rec.Header().Get("Access-Control-Allow-Origin"))
+			require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+			if test.origin != "" {
+				require.Equal(t, "X-Request-Id",
+					rec.Header().Get("Access-Control-Expose-Headers"))
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/graphql", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "Content-Type, X-Auth-Token",
+		rec.Header().Get("Access-Control-Allow-Headers"))
+	require.Empty(t, rec.Header().Get("Access-Control-Max-Age"))
+}
@@ -0,0 +1,227 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// The formats that a query's results can be exported in, instead of as a
+// GraphQL response.
+const (
+	csvFormat    = "text/csv"
+	ndjsonFormat = "application/x-ndjson"
+)
+
+// exportPageSize is how many objects each page of an export reads.
+var exportPageSize = resolve.DefaultExportPageSize
+
+// exportExtension is the request extension that asks for an export, as
+// "csv" or "ndjson".
+const exportExtension = "export"
+
+// exportFormat returns the format that gqlReq asks for its results to be
+// exported in, in r's Accept header or its export extension, or "" if they
+// aren't to be exported.
+func exportFormat(r *http.Request, gqlReq *schema.Request) (string, error) {
+	if ext, ok := gqlReq.Extensions[exportExtension]; ok {
+		switch ext {
+		case "csv":
+			return csvFormat, nil
+		case "ndjson":
+			return ndjsonFormat, nil
+		}
+		return "", errors.Errorf("the %s extension must be csv or ndjson, not %v",
+			exportExtension, ext)
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case csvFormat, ndjsonFormat:
+			return mediaType, nil
+		case "application/json", "application/graphql-response+json":
+			return "", nil
+		}
+	}
+	return "", nil
+}
+
+// export writes the results of gqlReq to w in format, a page at a time.  If
+// the request fails before anything's written, the response is the GraphQL
+// error response; if it fails after, the response is cut off, so that the
+// client can tell the export isn't complete.
+func (gh *graphqlHandler) export(ctx context.Context, w http.ResponseWriter,
+	gqlReq *schema.Request, format string) {
+
+	var out exportWriter
+	resp := gh.resolver.Export(ctx, gqlReq, exportPageSize,
+		func(q schema.Query, page []json.RawMessage) error {
+			if out == nil {
+				w.Header().Set("Content-Type", format)
+				out = newExportWriter(w, format, q)
+			}
+			if err := out.write(page); err != nil {
+				return err
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			return nil
+		})
+	if resp == nil {
+		return
+	}
+	if out == nil {
+		if methodNotAllowed(resp) {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		write(w, resp)
+		return
+	}
+	glog.Errorf("Error exporting the results of request %s: %s",
+		resolve.RequestID(ctx), strings.TrimSpace(resp.Errors.Error()))
+	panic(http.ErrAbortHandler)
+}
+
+// An exportWriter writes pages of results in an export format.
+type exportWriter interface {
+	write(page []json.RawMessage) error
+}
+
+func newExportWriter(w http.ResponseWriter, format string, q schema.Query) exportWriter {
+	if format == csvFormat {
+		return newCSVWriter(w, q)
+	}
+	return &ndjsonWriter{w: w}
+}
+
+// ndjsonWriter writes each object as a line of JSON.
+type ndjsonWriter struct {
+	w http.ResponseWriter
+}
+
+func (nw *ndjsonWriter) write(page []json.RawMessage) error {
+	for _, obj := range page {
+		if _, err := nw.w.Write(append(obj, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvWriter writes each object as a row of CSV, after a header row.  The
+// columns are the query's fields, in order; an object's fields are
+// flattened into columns named with its field's name and theirs, like
+// author.name.  Lists are written as JSON.
+type csvWriter struct {
+	csv     *csv.Writer
+	columns [][]string
+	header  bool
+}
+
+func newCSVWriter(w http.ResponseWriter, q schema.Query) *csvWriter {
+	return &csvWriter{csv: csv.NewWriter(w), columns: csvColumns(q.SelectionSet(), nil)}
+}
+
+// csvColumns returns the columns of fields, as the path of response names to
+// each one's value, under path.
+func csvColumns(fields []schema.Field, path []string) [][]string {
+	var columns [][]string
+	for _, f := range fields {
+		col := append(append([]string{}, path...), f.ResponseName())
+		if f.Type().ListType() == nil && len(f.SelectionSet()) > 0 {
+			columns = append(columns, csvColumns(f.SelectionSet(), col)...)
+			continue
+		}
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+func (cw *csvWriter) write(page []json.RawMessage) error {
+	if !cw.header {
+		cw.header = true
+		names := make([]string, len(cw.columns))
+		for i, col := range cw.columns {
+			names[i] = strings.Join(col, ".")
+		}
+		if err := cw.csv.Write(names); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(cw.columns))
+	for _, raw := range page {
+		var obj map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&obj); err != nil {
+			return errors.Wrap(err, "couldn't read an object of the results")
+		}
+		for i, col := range cw.columns {
+			row[i] = csvValue(obj, col)
+		}
+		if err := cw.csv.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.csv.Flush()
+	return cw.csv.Error()
+}
+
+// csvValue returns the value at path in obj, as it's written in a CSV cell.
+// A null, or a value under a null object, is an empty cell.
+func csvValue(obj map[string]interface{}, path []string) string {
+	var val interface{} = obj
+	for _, name := range path {
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		val = m[name]
+	}
+
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	js, _ := json.Marshal(val)
+	return string(js)
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const exportSchema = `
+type Post {
+	id: ID!
+	title: String!
+	likes: Int
+	tags: [String]
+	author: Author
+}
+
+type Author {
+	id: ID!
+	name: String!
+}
+`
+
+// pagedDgraph answers each query with the page of posts that its first and
+// offset ask for, or all of them if it doesn't ask for a page.  A page past
+// the end of posts fails, if fail is set.
+type pagedDgraph struct {
+	staticDgraph
+	posts []interface{}
+	fail  bool
+}
+
+func (d *pagedDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	first, err := strconv.Atoi(query.Args["first"])
+	if err != nil {
+		first = len(d.posts)
+	}
+	offset, _ := strconv.Atoi(query.Args["offset"])
+	if offset >= len(d.posts) && d.fail {
+		return nil, errors.New("Dgraph went away")
+	}
+	to := offset + first
+	if to > len(d.posts) {
+		to = len(d.posts)
+	}
+	if offset > to {
+		offset = to
+	}
+	return json.Marshal(map[string]interface{}{"queryPost": d.posts[offset:to]})
+}
+
+func exportServer(t *testing.T, client *pagedDgraph) *httptest.Server {
+	handler, err := schema.NewHandler(exportSchema)
+	require.NoError(t, err)
+	return httptest.NewServer(GraphQLHTTPHandler(resolve.New(handler.Schema(), client)))
+}
+
+// export POSTs query to srv, with the Accept header accept, and returns the
+// response and its body.
+func export(t *testing.T, srv *httptest.Server, accept, query string) (*http.Response,
+	string, error) {
+
+	body, err := json.Marshal(map[string]interface{}{"query": query})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	out, err := ioutil.ReadAll(resp.Body)
+	return resp, string(out), err
+}
+
+func TestExport(t *testing.T) {
+	defer func(size int) { exportPageSize = size }(exportPageSize)
+	exportPageSize = 2
+
+	client := &pagedDgraph{posts: []interface{}{
+		map[string]interface{}{"title": "Hello, world", "likes": 3,
+			"tags": []string{"a", "b"}, "author": map[string]interface{}{"name": "Ann"}},
+		map[string]interface{}{"title": "Second", "tags": []string{}},
+		map[string]interface{}{"title": "Third", "likes": 0,
+			"author": map[string]interface{}{"name": "Bob"}},
+	}}
+	srv := exportServer(t, client)
+	defer srv.Close()
+	query := `query { queryPost { title likes tags author { name } } }`
+
+	resp, body, err := export(t, srv, "text/csv", query)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	require.Equal(t, "title,likes,tags,author.name\n"+
+		"\"Hello, world\",3,\"[\"\"a\"\",\"\"b\"\"]\",Ann\n"+
+		"Second,,[],\n"+
+		"Third,0,,Bob\n", body)
+
+	resp, body, err = export(t, srv, "application/x-ndjson", query)
+	require.NoError(t, err)
+	require.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	require.Equal(t,
+		`{"title":"Hello, world","likes":3,"tags":["a","b"],"author":{"name":"Ann"}}`+"\n"+
+			`{"title":"Second","likes":null,"tags":[],"author":null}`+"\n"+
+			`{"title":"Third","likes":0,"tags":null,"author":{"name":"Bob"}}`+"\n", body)
+
+	// JSON is preferred if it's accepted first.
+	resp, body, err = export(t, srv, "application/json, text/csv", query)
+	require.NoError(t, err)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.Contains(t, body, `"data":{"queryPost":[{"title":"Hello, world"`)
+
+	// No results is just the header.
+	_, body, err = export(t, exportServer(t, &pagedDgraph{}), "text/csv", query)
+	require.NoError(t, err)
+	require.Equal(t, "title,likes,tags,author.name\n", body)
+}
+
+func TestExportExtension(t *testing.T) {
+	client := &pagedDgraph{posts: []interface{}{map[string]interface{}{"title": "Hello"}}}
+	srv := exportServer(t, client)
+	defer srv.Close()
+
+	post := func(ext string) (*http.Response, string) {
+		body := fmt.Sprintf(`{"query": "{ queryPost { title } }", "extensions": {"export": %s}}`,
+			ext)
+		resp, err := http.Post(srv.URL, "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		out, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(out)
+	}
+
+	resp, body := post(`"csv"`)
+	require.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	require.Equal(t, "title\nHello\n", body)
+
+	resp, body = post(`"xml"`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Contains(t, body, "the export extension must be csv or ndjson, not xml")
+}
+
+func TestExportErrors(t *testing.T) {
+	defer func(size int) { exportPageSize = size }(exportPageSize)
+	exportPageSize = 1
+
+	client := &pagedDgraph{posts: []interface{}{map[string]interface{}{"title": "Hello"}}}
+	srv := exportServer(t, client)
+	defer srv.Close()
+
+	// A request that fails before anything's exported gets a GraphQL error.
+	resp, body, err := export(t, srv, "text/csv", `query { getPost(id: "0x1") { title } }`)
+	require.NoError(t, err)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.Contains(t, body, "Only a single query of a list of objects can be exported")
+
+	// One that fails part way is cut off.
+	client.fail = true
+	_, body, err = export(t, srv, "text/csv", `query { queryPost { title } }`)
+	require.Error(t, err)
+	require.Equal(t, "title\nHello\n", body)
+}
+
+func TestExportCompressed(t *testing.T) {
+	client := &pagedDgraph{posts: []interface{}{map[string]interface{}{"title": "Hello"}}}
+	handler, err := schema.NewHandler(exportSchema)
+	require.NoError(t, err)
+	srv := httptest.NewServer(WithCompression(
+		GraphQLHTTPHandler(resolve.New(handler.Schema(), client)), 1024))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL,
+		bytes.NewBufferString(`{"query": "{ queryPost { title } }"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// A flushed export is compressed, however short it is.
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	gz, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	out, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, `{"title":"Hello"}`+"\n", string(out))
+}
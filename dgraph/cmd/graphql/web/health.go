@@ -0,0 +1,91 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/admin"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/golang/glog"
+)
+
+// HealthHandler returns an http.Handler that reports whether Dgraph is
+// reachable, as tracked by health.  It responds with HTTP 200 while Dgraph
+// is available and 503 while it isn't, so load balancers can route around a
+// degraded server; the body is the dgraph.HealthStatus as JSON.
+func HealthHandler(health *dgraph.Health) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := health.Status()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !st.Available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(st); err != nil {
+			glog.Errorf("Error writing health status: %v", err)
+		}
+	})
+}
+
+// A Prober checks whether the GraphQL API is ready to be served; an
+// *admin.Admin is one.
+type Prober interface {
+	Probe(ctx context.Context) *admin.Probe
+}
+
+// ProbeHandler returns an http.Handler for orchestration systems' probes.  A
+// liveness probe only checks that the server answers, so it always gets an
+// HTTP 200; a readiness probe gets a 503 until the server has a schema and
+// Dgraph answers.  Either way, the body is the admin.Probe as JSON.
+func ProbeHandler(prober Prober, readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probe := prober.Probe(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if readiness && !probe.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(probe); err != nil {
+			glog.Errorf("Error writing probe: %v", err)
+		}
+	})
+}
+
+// A SchemaFileWatcher reports how applying a watched schema file last went;
+// an *admin.SchemaWatcher is one.
+type SchemaFileWatcher interface {
+	Status() admin.SchemaFileStatus
+}
+
+// DevStatusHandler returns an http.Handler that reports, as an
+// admin.SchemaFileStatus in JSON, whether the last change to the schema file
+// that watcher follows was applied, and if not, why not.  It's for the
+// development loop, so it always responds with HTTP 200; the GraphQL API is
+// still served, with the last schema that was applied.
+func DevStatusHandler(watcher SchemaFileWatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(watcher.Status()); err != nil {
+			glog.Errorf("Error writing dev status: %v", err)
+		}
+	})
+}
@@ -0,0 +1,477 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package web serves GraphQL over HTTP.
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// maxRequestSize limits the size of the body of a GraphQL request.
+const maxRequestSize = 32 << 20
+
+// maxUploadMemory is how much of the files uploaded in a multipart request
+// are kept in memory; the rest are written to temporary files.
+const maxUploadMemory = 8 << 20
+
+// maxBatchSize limits how many GraphQL requests can be sent in a batch.
+const maxBatchSize = 50
+
+// requestIDHeader is the HTTP header with the ID of a request.  A client can
+// send one, so its logs and the server's agree; otherwise, the server makes
+// one up.  Either way, it's sent back in the response.
+const requestIDHeader = "X-Request-Id"
+
+// maxRequestIDSize limits the size of a request ID that a client sends.
+const maxRequestIDSize = 128
+
+// timeoutHeader is the HTTP header in which a client can ask for a shorter
+// timeout than the server's, as a duration like "500ms" or "5s".
+const timeoutHeader = "X-Request-Timeout"
+
+// explainHeader is the request header that asks, when it's "true", for the
+// request's operations to be explained.
+const explainHeader = "X-Dgraph-Explain"
+
+type graphqlHandler struct {
+	resolver *resolve.RequestResolver
+}
+
+// GraphQLHTTPHandler returns an http.Handler that serves GraphQL requests,
+// resolving them with resolver.
+//
+// Requests can be:
+//   - GET, with the query, operationName, variables (as JSON) and extensions
+//     (as JSON) in the URL query parameters,
+//   - POST, with Content-Type application/json and a body like
+//     {"query": "...", "operationName": "...", "variables": {...}}, or
+//   - POST, with Content-Type application/json and a body that's a JSON
+//     array of such requests: a batch, which is answered with an array of
+//     the responses, in the same order, or
+//   - POST, with Content-Type application/graphql and the query as the body,
+//     or
+//   - a WebSocket upgrade, speaking either the graphql-ws or the
+//     graphql-transport-ws protocol, which is how subscriptions are served.
+//
+// Valid requests get an HTTP 200 and a GraphQL response, even if the GraphQL
+// request itself has errors, unless a request's mutation is refused because
+// its client is over its rate limit; that's an HTTP 429.  A batch is still an
+// HTTP 200 then, but with a Retry-After header, like a 429's.  GET requests
+// can only run queries: a mutation sent by GET gets an HTTP 405, with an
+// Allow: POST header.  A response that the schema's @cacheControl hints allow
+// to be cached has a Cache-Control header, so browsers and CDNs can cache it
+// too.  Each request has an ID, from its X-Request-Id header or made up,
+// that's in the X-Request-Id header of the response and the extensions of any
+// errors.  A request can shorten the server's timeouts with an
+// X-Request-Timeout header; if the client goes away, its request is
+// cancelled.  If the resolver allows it, a request with an X-Dgraph-Explain:
+// true header has the DQL that it ran, and how long that took, in its
+// response's explain extension.
+//
+// The results of a single query of a list can be exported instead, as CSV or
+// newline-delimited JSON, by accepting text/csv or application/x-ndjson, or
+// with an export extension of "csv" or "ndjson".  They're read and written a
+// page at a time, so a large export doesn't have to fit in memory.
+//
+// A client that accepts multipart/mixed can use @defer and @stream: the
+// response is then in parts, the initial payload first and the deferred
+// fragments and streamed list items after it.  For other clients, the
+// directives don't change the response.
+func GraphQLHTTPHandler(resolver *resolve.RequestResolver) http.Handler {
+	return &graphqlHandler{resolver: resolver}
+}
+
+func (gh *graphqlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isWebSocketUpgrade(r) {
+		gh.serveWebSocket(w, r)
+		return
+	}
+
+	ctx := requestContext(w, r)
+	w.Header().Set("Content-Type", "application/json")
+
+	gqlReq, batch, status, err := getRequest(r)
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err == nil {
+		ctx, err = withTimeout(ctx, r)
+		status = http.StatusBadRequest
+	}
+	if r.Header.Get(explainHeader) == "true" {
+		ctx = resolve.WithExplainRequest(ctx)
+	}
+	if err != nil {
+		w.WriteHeader(status)
+		write(w, resolve.ErrorResponse(ctx, resolve.BadRequestCode, err))
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		ctx = resolve.WithReadOnly(ctx)
+	}
+	if batch == nil {
+		format, err := exportFormat(r, gqlReq)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			write(w, resolve.ErrorResponse(ctx, resolve.BadRequestCode, err))
+			return
+		}
+		if format != "" {
+			gh.export(ctx, w, gqlReq, format)
+			return
+		}
+
+		var resp *schema.Response
+		var parts []*schema.Incremental
+		if acceptsIncremental(r) {
+			resp, parts = gh.resolver.ResolveIncremental(ctx, gqlReq)
+		} else {
+			resp = gh.resolver.Resolve(ctx, gqlReq)
+		}
+		setCacheControl(w, resp)
+		if parts != nil {
+			w.Header().Set("Content-Type", incrementalResponse)
+		}
+		if secs := retryAfter(resp); secs > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+		if methodNotAllowed(resp) {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		if parts != nil {
+			writeIncremental(w, resp, parts)
+		} else {
+			write(w, resp)
+		}
+		return
+	}
+
+	resps := gh.resolver.ResolveBatch(ctx, batch)
+	setCacheControl(w, resps...)
+	// The batch's other requests may have run, so it's still a 200.
+	if secs := retryAfter(resps...); secs > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+	}
+	w.Write([]byte("["))
+	for i, resp := range resps {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		write(w, resp)
+	}
+	w.Write([]byte("]"))
+}
+
+// requestContext returns r's context with r's ID, and sets the ID in w's
+// header.  The ID is the one the client sent, if it's a short string of
+// printable ASCII, or a new one.  A request that's already been given an ID,
+// by WithJWT, keeps it.
+func requestContext(w http.ResponseWriter, r *http.Request) context.Context {
+	ctx := r.Context()
+	if resolve.RequestID(ctx) != "" {
+		return ctx
+	}
+
+	id := r.Header.Get(requestIDHeader)
+	unprintable := func(c rune) bool { return c <= ' ' || c > '~' }
+	if id == "" || len(id) > maxRequestIDSize || strings.IndexFunc(id, unprintable) >= 0 {
+		id = resolve.NewRequestID()
+	}
+	w.Header().Set(requestIDHeader, id)
+	return resolve.WithRequestID(ctx, id)
+}
+
+// withTimeout returns ctx with the timeout that r asks for, if it asks for
+// one.  If the timeout isn't valid, ctx is returned as it is, with the error.
+func withTimeout(ctx context.Context, r *http.Request) (context.Context, error) {
+	header := r.Header.Get(timeoutHeader)
+	if header == "" {
+		return ctx, nil
+	}
+	timeout, err := time.ParseDuration(header)
+	if err != nil || timeout <= 0 {
+		return ctx, errors.Errorf("%s must be a positive duration, like 5s, not %q",
+			timeoutHeader, header)
+	}
+	return resolve.WithTimeout(ctx, timeout), nil
+}
+
+// getRequest reads the GraphQL request from r, or, if r's body is a JSON
+// array, the batch of requests.  If r isn't a valid GraphQL request, the
+// error comes with the HTTP status to respond with.  A multipart request's
+// uploaded files are left in r.MultipartForm, for the caller to remove.
+func getRequest(r *http.Request) (*schema.Request, []*schema.Request, int, error) {
+	gqlReq := &schema.Request{}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		gqlReq.Query = query.Get("query")
+		gqlReq.OperationName = query.Get("operationName")
+		if vars := query.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &gqlReq.Variables); err != nil {
+				return nil, nil, http.StatusBadRequest,
+					errors.Wrap(err, "Not a valid GraphQL request body")
+			}
+		}
+		if ext := query.Get("extensions"); ext != "" {
+			if err := json.Unmarshal([]byte(ext), &gqlReq.Extensions); err != nil {
+				return nil, nil, http.StatusBadRequest,
+					errors.Wrap(err, "Not a valid GraphQL request body")
+			}
+		}
+	case http.MethodPost:
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			return nil, nil, http.StatusUnsupportedMediaType,
+				errors.Wrap(err, "Unable to parse media type")
+		}
+		if mediaType == "multipart/form-data" {
+			gqlReq, batch, err := getMultipartRequest(r)
+			if err != nil {
+				return nil, nil, http.StatusBadRequest, err
+			}
+			return gqlReq, batch, http.StatusOK, nil
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestSize))
+		if err != nil {
+			return nil, nil, http.StatusBadRequest, errors.Wrap(err, "Unable to read request body")
+		}
+
+		switch mediaType {
+		case "application/json":
+			if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+				batch, err := getBatch(body)
+				if err != nil {
+					return nil, nil, http.StatusBadRequest, err
+				}
+				return nil, batch, http.StatusOK, nil
+			}
+			if err := json.Unmarshal(body, gqlReq); err != nil {
+				return nil, nil, http.StatusBadRequest,
+					errors.Wrap(err, "Not a valid GraphQL request body")
+			}
+		case "application/graphql":
+			gqlReq.Query = string(body)
+		default:
+			return nil, nil, http.StatusUnsupportedMediaType,
+				errors.Errorf("Unrecognised Content-Type %s", mediaType)
+		}
+	default:
+		return nil, nil, http.StatusMethodNotAllowed,
+			errors.Errorf("Method %s isn't supported, use GET or POST", r.Method)
+	}
+
+	return gqlReq, nil, http.StatusOK, nil
+}
+
+// getMultipartRequest reads a request that uploads files, sent as the GraphQL
+// multipart request spec (github.com/jaydenseric/graphql-multipart-request-spec)
+// says: the operations field is the request, or batch, as JSON, with null for
+// each file; the map field maps the name of each file part to the paths, like
+// variables.file or 1.variables.files.0, of the values it's for.
+func getMultipartRequest(r *http.Request) (*schema.Request, []*schema.Request, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, maxRequestSize)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		return nil, nil, errors.Wrap(err, "Unable to read multipart request")
+	}
+	form := r.MultipartForm
+
+	ops := form.Value["operations"]
+	if len(ops) != 1 {
+		return nil, nil, errors.New("A multipart request needs one operations field")
+	}
+	var gqlReq *schema.Request
+	var batch []*schema.Request
+	if body := []byte(ops[0]); bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var err error
+		if batch, err = getBatch(body); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		gqlReq = &schema.Request{}
+		if err := json.Unmarshal(body, gqlReq); err != nil {
+			return nil, nil, errors.Wrap(err, "Not a valid GraphQL request in operations")
+		}
+	}
+
+	var files map[string][]string
+	if m := form.Value["map"]; len(m) > 0 {
+		if err := json.Unmarshal([]byte(m[0]), &files); err != nil {
+			return nil, nil, errors.Wrap(err, "Not a valid multipart request map")
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		parts := form.File[name]
+		if len(parts) == 0 {
+			return nil, nil, errors.Errorf("File %s is in the map, but wasn't sent", name)
+		}
+		upload := newUpload(parts[0])
+		for _, path := range files[name] {
+			if !setUpload(gqlReq, batch, strings.Split(path, "."), upload) {
+				return nil, nil, errors.Errorf(
+					"File %s can't go at %s; files can only be the values of variables",
+					name, path)
+			}
+		}
+	}
+	return gqlReq, batch, nil
+}
+
+func newUpload(part *multipart.FileHeader) *schema.Upload {
+	return &schema.Upload{
+		Filename:    part.Filename,
+		ContentType: part.Header.Get("Content-Type"),
+		Size:        part.Size,
+		Open: func() (io.ReadCloser, error) {
+			return part.Open()
+		},
+	}
+}
+
+// setUpload puts upload in the variables of gqlReq, or of a request in batch,
+// at path.  It returns false if there's nowhere for it at path.
+func setUpload(gqlReq *schema.Request, batch []*schema.Request, path []string,
+	upload *schema.Upload) bool {
+
+	if batch != nil {
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(batch) {
+			return false
+		}
+		gqlReq, path = batch[i], path[1:]
+	}
+	if len(path) < 2 || path[0] != "variables" || gqlReq.Variables == nil {
+		return false
+	}
+
+	var val interface{} = gqlReq.Variables
+	for i, key := range path[1:] {
+		last := i == len(path)-2
+		switch v := val.(type) {
+		case map[string]interface{}:
+			if last {
+				v[key] = upload
+				return true
+			}
+			val = v[key]
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return false
+			}
+			if last {
+				v[idx] = upload
+				return true
+			}
+			val = v[idx]
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// getBatch decodes body, a JSON array of GraphQL requests.
+func getBatch(body []byte) ([]*schema.Request, error) {
+	var batch []*schema.Request
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, errors.Wrap(err, "Not a valid GraphQL request body")
+	}
+	switch {
+	case len(batch) == 0:
+		return nil, errors.New("A batch must have at least one request")
+	case len(batch) > maxBatchSize:
+		return nil, errors.Errorf("A batch can have at most %d requests, but this one has %d",
+			maxBatchSize, len(batch))
+	}
+	for i, gqlReq := range batch {
+		if gqlReq == nil {
+			return nil, errors.Errorf("Request %d of the batch is null", i)
+		}
+	}
+	return batch, nil
+}
+
+// setCacheControl sets the Cache-Control header of the HTTP response that
+// carries resps, if they can all be cached.  They can be cached for as long
+// as the one that can be cached for the shortest time, and they're private if
+// any of them are.
+func setCacheControl(w http.ResponseWriter, resps ...*schema.Response) {
+	var maxAge time.Duration
+	scope := schema.PublicCache
+	for i, resp := range resps {
+		if resp == nil || resp.Cache == nil {
+			return
+		}
+		if i == 0 || resp.Cache.MaxAge < maxAge {
+			maxAge = resp.Cache.MaxAge
+		}
+		if resp.Cache.Scope == schema.PrivateCache {
+			scope = schema.PrivateCache
+		}
+	}
+	if len(resps) == 0 || maxAge < time.Second {
+		return
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d, %s",
+		int(maxAge/time.Second), strings.ToLower(string(scope))))
+}
+
+// methodNotAllowed returns true if resp is the error for an operation that
+// isn't a query, sent in a GET request.
+func methodNotAllowed(resp *schema.Response) bool {
+	if resp == nil || len(resp.Errors) == 0 {
+		return false
+	}
+	code, _ := resp.Errors[0].Extensions["code"].(string)
+	return code == resolve.MethodNotAllowedCode
+}
+
+func write(w http.ResponseWriter, resp *schema.Response) {
+	if _, err := resp.WriteTo(w); err != nil {
+		glog.Errorf("Error writing GraphQL response: %v", err)
+	}
+}
@@ -0,0 +1,466 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/admin"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/dgraph"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// staticDgraph answers every query with the same result.
+type staticDgraph struct {
+	result string
+}
+
+func (d *staticDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	return []byte(d.result), nil
+}
+
+func (d *staticDgraph) NewTxn() dgraph.Txn {
+	panic("these tests don't run mutations")
+}
+
+func (d *staticDgraph) Alter(ctx context.Context, schema string) error {
+	return nil
+}
+
+func (d *staticDgraph) Schema(ctx context.Context, preds []string) ([]*api.SchemaNode, error) {
+	return nil, nil
+}
+
+func (d *staticDgraph) DescribeSchema(ctx context.Context) (*dgraph.FullSchema, error) {
+	return &dgraph.FullSchema{}, nil
+}
+
+func testServer(t *testing.T) *httptest.Server {
+	handler, err := schema.NewHandler(`type Author { id: ID! name: String! }`)
+	require.NoError(t, err)
+
+	client := &staticDgraph{result: `{"getAuthor": [{"name": "A.N. Author"}]}`}
+	return httptest.NewServer(GraphQLHTTPHandler(resolve.New(handler.Schema(), client)))
+}
+
+func TestGraphQLHTTPHandler(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	query := `query q($id: ID!) { getAuthor(id: $id) { name } }`
+	vars := `{"id": "0x1"}`
+	expected := `{"data": {"getAuthor": {"name": "A.N. Author"}}}`
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		timeout     string
+		body        string
+		status      int
+		response    string
+	}{
+		{
+			name:   "GET",
+			method: http.MethodGet,
+			url: "?" + url.Values{
+				"query":     {query},
+				"variables": {vars},
+			}.Encode(),
+			status:   http.StatusOK,
+			response: expected,
+		},
+		{
+			name:        "POST JSON",
+			method:      http.MethodPost,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"query": "` + query + `", "variables": ` + vars + `}`,
+			status:      http.StatusOK,
+			response:    expected,
+		},
+		{
+			name:        "POST GraphQL",
+			method:      http.MethodPost,
+			contentType: "application/graphql",
+			body:        `{ getAuthor(id: "0x1") { name } }`,
+			status:      http.StatusOK,
+			response:    expected,
+		},
+		{
+			name:        "timeout",
+			method:      http.MethodPost,
+			contentType: "application/graphql",
+			timeout:     "5s",
+			body:        `{ getAuthor(id: "0x1") { name } }`,
+			status:      http.StatusOK,
+			response:    expected,
+		},
+		{
+			name:        "bad timeout",
+			method:      http.MethodPost,
+			contentType: "application/graphql",
+			timeout:     "soon",
+			body:        `{ getAuthor(id: "0x1") { name } }`,
+			status:      http.StatusBadRequest,
+			response: `{"errors": [{"message": ` +
+				`"X-Request-Timeout must be a positive duration, like 5s, not \"soon\"", ` +
+				`"extensions": {"code": "BAD_REQUEST", "requestId": "req-1"}}]}`,
+		},
+		{
+			name:        "GraphQL errors are a valid response",
+			method:      http.MethodPost,
+			contentType: "application/graphql",
+			body:        `{ getAuthor { name } }`,
+			status:      http.StatusOK,
+			response: `{"errors": [{"message": "Field \"getAuthor\" argument \"id\" ` +
+				`of type \"ID!\" is required but not provided.",` +
+				`"locations": [{"line": 1, "column": 3}],` +
+				`"extensions": {"code": "VALIDATION_FAILED", "requestId": "req-1"}}]}`,
+		},
+		{
+			name:        "bad JSON",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"query": `,
+			status:      http.StatusBadRequest,
+			response: `{"errors": [{"message": ` +
+				`"Not a valid GraphQL request body: unexpected end of JSON input", ` +
+				`"extensions": {"code": "BAD_REQUEST", "requestId": "req-1"}}]}`,
+		},
+		{
+			name:        "POST batch",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body: `[{"query": "` + query + `", "variables": ` + vars + `},
+				{"query": "{ getAuthor { name } }"}]`,
+			status: http.StatusOK,
+			response: `[` + expected + `, {"errors": [{"message": "Field \"getAuthor\" ` +
+				`argument \"id\" of type \"ID!\" is required but not provided.",` +
+				`"locations": [{"line": 1, "column": 3}],` +
+				`"extensions": {"code": "VALIDATION_FAILED", "requestId": "req-1"}}]}]`,
+		},
+		{
+			name:        "empty batch",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        ` [] `,
+			status:      http.StatusBadRequest,
+			response: `{"errors": [{"message": "A batch must have at least one request", ` +
+				`"extensions": {"code": "BAD_REQUEST", "requestId": "req-1"}}]}`,
+		},
+		{
+			name:        "batch too big",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        "[" + strings.Repeat(`{"query": "{ __typename }"},`, 50) + "{}]",
+			status:      http.StatusBadRequest,
+			response: `{"errors": [{"message": ` +
+				`"A batch can have at most 50 requests, but this one has 51", ` +
+				`"extensions": {"code": "BAD_REQUEST", "requestId": "req-1"}}]}`,
+		},
+		{
+			name:        "unknown content type",
+			method:      http.MethodPost,
+			contentType: "text/plain",
+			body:        `{ getAuthor(id: "0x1") { name } }`,
+			status:      http.StatusUnsupportedMediaType,
+			response: `{"errors": [{"message": "Unrecognised Content-Type text/plain", ` +
+				`"extensions": {"code": "BAD_REQUEST", "requestId": "req-1"}}]}`,
+		},
+		{
+			name:   "unsupported method",
+			method: http.MethodPut,
+			status: http.StatusMethodNotAllowed,
+			response: `{"errors": [{"message": "Method PUT isn't supported, use GET or POST", ` +
+				`"extensions": {"code": "BAD_REQUEST", "requestId": "req-1"}}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, srv.URL+test.url, strings.NewReader(test.body))
+			require.NoError(t, err)
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			req.Header.Set("X-Request-Id", "req-1")
+			if test.timeout != "" {
+				req.Header.Set("X-Request-Timeout", test.timeout)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, test.status, resp.StatusCode)
+			require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			require.Equal(t, "req-1", resp.Header.Get("X-Request-Id"))
+			require.JSONEq(t, test.response, string(body))
+		})
+	}
+}
+
+func TestGETMutation(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"?"+url.Values{
+		"query": {`mutation { deleteAuthor(filter: {id: ["0x1"]}) { msg } }`},
+	}.Encode(), nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-Id", "req-1")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	require.Equal(t, "POST", resp.Header.Get("Allow"))
+	require.JSONEq(t, `{"errors": [{"message": "A mutation can't be sent in a read-only `+
+		`request, like an HTTP GET.  Use POST.", `+
+		`"extensions": {"code": "METHOD_NOT_ALLOWED", "requestId": "req-1"}}]}`, string(body))
+}
+
+func TestCacheControl(t *testing.T) {
+	handler, err := schema.NewHandler(`
+		type Author @cacheControl(maxAge: 60) { id: ID! name: String! posts: [Post] }
+		type Post { id: ID! title: String! }`)
+	require.NoError(t, err)
+	client := &staticDgraph{result: `{"getAuthor": [{"name": "A.N. Author", "posts": []}]}`}
+	srv := httptest.NewServer(GraphQLHTTPHandler(resolve.New(handler.Schema(), client)))
+	defer srv.Close()
+
+	cacheControl := func(query string) string {
+		resp, err := http.Get(srv.URL + "?" + url.Values{"query": {query}}.Encode())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp.Header.Get("Cache-Control")
+	}
+
+	require.Equal(t, "max-age=60, public", cacheControl(`{ getAuthor(id: "0x1") { name } }`))
+	require.Empty(t, cacheControl(`{ getAuthor(id: "0x1") { name posts { title } } }`),
+		"Post has no maxAge, so it can't be cached")
+}
+
+func TestWithUI(t *testing.T) {
+	handler, err := schema.NewHandler(`type Author { id: ID! name: String! }`)
+	require.NoError(t, err)
+	client := &staticDgraph{result: `{"getAuthor": [{"name": "A.N. Author"}]}`}
+	srv := httptest.NewServer(
+		WithUI(GraphQLHTTPHandler(resolve.New(handler.Schema(), client)), "/graphql", ""))
+	defer srv.Close()
+
+	get := func(query, accept string) (string, string) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+query, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.Header.Get("Content-Type"), string(body)
+	}
+
+	contentType, body := get("", "text/html,application/xhtml+xml,*/*;q=0.8")
+	require.Equal(t, "text/html; charset=utf-8", contentType)
+	require.Contains(t, body, "https://unpkg.com/graphiql@0.13.2/graphiql.min.js")
+	require.Contains(t, body, `fetch("/graphql"`)
+
+	contentType, body = get("?"+url.Values{"query": {`{ getAuthor(id: "0x1") { name } }`}}.Encode(),
+		"text/html")
+	require.Equal(t, "application/json", contentType, "queries from a browser are answered")
+	require.JSONEq(t, `{"data": {"getAuthor": {"name": "A.N. Author"}}}`, body)
+
+	contentType, _ = get("", "application/json")
+	require.Equal(t, "application/json", contentType)
+}
+
+func TestUIAssets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ui")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, file := range append(UIAssets, "secret.txt") {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, file), []byte(file), 0644))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/ui", UIHandler("/graphql", "/ui/assets/"))
+	mux.Handle("/ui/assets/", UIAssetsHandler("/ui/assets/", dir))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	code, page := get("/ui")
+	require.Equal(t, http.StatusOK, code)
+	require.NotContains(t, page, "unpkg.com")
+	for _, file := range UIAssets {
+		require.Contains(t, page, `"/ui/assets/`+file+`"`)
+		code, body := get("/ui/assets/" + file)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, file, body)
+	}
+
+	code, _ = get("/ui/assets/secret.txt")
+	require.Equal(t, http.StatusNotFound, code, "only the UI's files are served")
+	code, _ = get("/ui/assets/")
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+func TestHealthHandler(t *testing.T) {
+	health := dgraph.NewHealth()
+	srv := httptest.NewServer(HealthHandler(health))
+	defer srv.Close()
+
+	get := func() (int, map[string]interface{}) {
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var st map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+		return resp.StatusCode, st
+	}
+
+	code, st := get()
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, true, st["available"])
+	require.NotContains(t, st, "error")
+
+	client := dgraph.WithHealth(&unavailableDgraph{}, health)
+	_, err := client.Query(context.Background(), &gql.GraphQuery{})
+	require.Error(t, err)
+
+	code, st = get()
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, false, st["available"])
+	require.Equal(t, "rpc error: code = Unavailable desc = connection refused", st["error"])
+}
+
+// fixedProber always reports probe.
+type fixedProber struct {
+	probe admin.Probe
+}
+
+func (p *fixedProber) Probe(ctx context.Context) *admin.Probe {
+	return &p.probe
+}
+
+func TestProbeHandler(t *testing.T) {
+	prober := &fixedProber{}
+
+	get := func(readiness bool) (int, map[string]interface{}) {
+		srv := httptest.NewServer(ProbeHandler(prober, readiness))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		require.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+		var probe map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&probe))
+		return resp.StatusCode, probe
+	}
+
+	code, probe := get(false)
+	require.Equal(t, http.StatusOK, code, "liveness doesn't depend on readiness")
+	require.Equal(t, false, probe["ready"])
+
+	code, _ = get(true)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+
+	prober.probe = admin.Probe{Ready: true, SchemaLoaded: true,
+		Dgraph: admin.DgraphProbe{Available: true, Latency: "1ms"}}
+	code, probe = get(true)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, true, probe["ready"])
+	require.Equal(t, "1ms", probe["dgraph"].(map[string]interface{})["latency"])
+}
+
+// fixedWatcher always reports status.
+type fixedWatcher struct {
+	status admin.SchemaFileStatus
+}
+
+func (w *fixedWatcher) Status() admin.SchemaFileStatus {
+	return w.status
+}
+
+func TestDevStatusHandler(t *testing.T) {
+	srv := httptest.NewServer(DevStatusHandler(&fixedWatcher{status: admin.SchemaFileStatus{
+		File:  "schema.graphql",
+		Error: "input:1: Unexpected <EOF>",
+	}}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "errors are in the body")
+	require.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+	var st map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+	require.Equal(t, "schema.graphql", st["file"])
+	require.Equal(t, false, st["ok"])
+	require.Equal(t, "input:1: Unexpected <EOF>", st["error"])
+	require.NotContains(t, st, "appliedAt")
+}
+
+// unavailableDgraph can't be reached.
+type unavailableDgraph struct {
+	staticDgraph
+}
+
+func (d *unavailableDgraph) Query(ctx context.Context, query *gql.GraphQuery) ([]byte, error) {
+	return nil, status.Error(codes.Unavailable, "connection refused")
+}
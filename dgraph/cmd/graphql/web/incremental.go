@@ -0,0 +1,109 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A response with @defer or @stream parts is written as a multipart/mixed
+// response, as in the GraphQL incremental delivery proposal: the initial
+// payload, and then each part, with hasNext false in the last of them.
+const (
+	multipartMixed      = "multipart/mixed"
+	incrementalResponse = `multipart/mixed; boundary="-"; deferSpec=20220824`
+	incrementalPart     = "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"
+	incrementalEnd      = "\r\n-----\r\n"
+)
+
+// acceptsIncremental returns true if r's Accept header takes multipart/mixed,
+// and so its response can be delivered incrementally.  Otherwise, @defer and
+// @stream have no effect.
+func acceptsIncremental(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == multipartMixed {
+			return true
+		}
+	}
+	return false
+}
+
+// writeIncremental writes resp, the initial payload of a response, and then
+// parts, flushing each as it's written.
+func writeIncremental(w http.ResponseWriter, resp *schema.Response,
+	parts []*schema.Incremental) {
+
+	initial := struct {
+		Errors     []*gqlerror.Error      `json:"errors,omitempty"`
+		Data       json.RawMessage        `json:"data,omitempty"`
+		Extensions map[string]interface{} `json:"extensions,omitempty"`
+		HasNext    bool                   `json:"hasNext"`
+	}{
+		Errors:     resp.Errors,
+		Data:       resp.Data.Bytes(),
+		Extensions: resp.Extensions,
+		HasNext:    true,
+	}
+	if !writePart(w, initial) {
+		return
+	}
+	for i, part := range parts {
+		next := struct {
+			Incremental []*schema.Incremental `json:"incremental"`
+			HasNext     bool                  `json:"hasNext"`
+		}{
+			Incremental: []*schema.Incremental{part},
+			HasNext:     i < len(parts)-1,
+		}
+		if !writePart(w, next) {
+			return
+		}
+	}
+	if _, err := w.Write([]byte(incrementalEnd)); err != nil {
+		glog.Errorf("Error writing GraphQL response: %v", err)
+	}
+}
+
+// writePart writes payload to w as one part of a multipart response, and
+// returns false if it couldn't be written.
+func writePart(w http.ResponseWriter, payload interface{}) bool {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		glog.Errorf("Error writing GraphQL response: %v", err)
+		return false
+	}
+	var buf bytes.Buffer
+	buf.WriteString(incrementalPart)
+	buf.Write(js)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		glog.Errorf("Error writing GraphQL response: %v", err)
+		return false
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return true
+}
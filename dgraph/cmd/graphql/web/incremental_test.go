@@ -0,0 +1,78 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrementalDelivery(t *testing.T) {
+	client := &pagedDgraph{posts: []interface{}{
+		map[string]interface{}{"title": "Hello, world", "tags": []string{"a", "b", "c"},
+			"author": map[string]interface{}{"name": "Ann"}},
+	}}
+	srv := exportServer(t, client)
+	defer srv.Close()
+	query := `query {
+		queryPost {
+			title
+			... @defer(label: "author") { author { name } }
+			tags @stream(initialCount: 1)
+		}
+	}`
+
+	resp, body, err := export(t, srv, "multipart/mixed, application/json", query)
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/mixed", mediaType)
+
+	var parts []string
+	reader := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		require.Equal(t, "application/json; charset=utf-8", part.Header.Get("Content-Type"))
+		js, err := ioutil.ReadAll(part)
+		require.NoError(t, err)
+		parts = append(parts, string(js))
+	}
+	require.True(t, strings.HasSuffix(body, "\r\n-----\r\n"))
+
+	require.Len(t, parts, 3)
+	require.JSONEq(t, `{"data": {"queryPost": [{"title": "Hello, world", "tags": ["a"]}]},
+		"hasNext": true}`, parts[0])
+	require.JSONEq(t, `{"incremental": [{"items": ["b", "c"], "path": ["queryPost", 0, "tags", 1]}],
+		"hasNext": true}`, parts[1])
+	require.JSONEq(t, `{"incremental": [{"data": {"author": {"name": "Ann"}},
+		"path": ["queryPost", 0], "label": "author"}], "hasNext": false}`, parts[2])
+
+	// Without multipart/mixed, the directives make no difference.
+	resp, body, err = export(t, srv, "application/json", query)
+	require.NoError(t, err)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"data": {"queryPost": [{"title": "Hello, world",
+		"author": {"name": "Ann"}, "tags": ["a", "b", "c"]}]}}`, body)
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"net/http"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/pkg/errors"
+)
+
+// DefaultNamespaceHeader is the request header that names a request's
+// namespace, if Namespaces.Header isn't set.
+const DefaultNamespaceHeader = "X-Dgraph-Namespace"
+
+// Namespaces are the tenants that WithNamespaces routes requests to.  Each
+// namespace has its own GraphQL API, with its own schema.
+type Namespaces struct {
+	// Header is the request header that names the namespace.
+	Header string
+
+	// Claim, if it's set, is the JWT claim that names the namespace, and
+	// Header is ignored.  The claims must already be in the request's
+	// context, so WithJWT has to come before WithNamespaces.
+	Claim string
+
+	// Handlers serve each namespace's API, keyed by the namespace's name.
+	Handlers map[string]http.Handler
+}
+
+// WithNamespaces passes each request to the handler for the namespace it
+// names.  Requests that don't name a namespace are passed to handler, and
+// those that name a namespace there's no handler for are refused with an HTTP
+// 404.
+//
+// When namespaces are named by a claim, every request has to name one, so
+// that a tenant can't reach the default API by leaving out its token:
+// anonymous requests are refused with an HTTP 401, and requests whose token
+// doesn't have the claim with a 403.
+//
+// When namespaces are named by a claim, WebSocket clients must send their
+// token in a header: a token in the connection_init payload comes too late
+// to pick the API.
+func WithNamespaces(handler http.Handler, ns Namespaces) http.Handler {
+	header := ns.Header
+	if header == "" {
+		header = DefaultNamespaceHeader
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get(header)
+		if ns.Claim != "" {
+			claims := authorization.Claims(r.Context())
+			name, _ = claims[ns.Claim].(string)
+			if name == "" {
+				refuseUnnamed(w, r, claims == nil, ns.Claim)
+				return
+			}
+		}
+		if name == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		nsHandler, ok := ns.Handlers[name]
+		if !ok {
+			ctx := requestContext(w, r)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			write(w, resolve.ErrorResponse(ctx, resolve.BadRequestCode,
+				errors.Errorf("There's no GraphQL API for namespace %q", name)))
+			return
+		}
+		nsHandler.ServeHTTP(w, r)
+	})
+}
+
+// refuseUnnamed refuses a request whose token doesn't have claim, the claim
+// that names its namespace, or that has no token, if anonymous is true.
+func refuseUnnamed(w http.ResponseWriter, r *http.Request, anonymous bool, claim string) {
+	ctx := requestContext(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	if anonymous {
+		w.WriteHeader(http.StatusUnauthorized)
+		write(w, resolve.ErrorResponse(ctx, resolve.UnauthenticatedCode,
+			errors.New("A token naming the namespace is required")))
+		return
+	}
+	w.WriteHeader(http.StatusForbidden)
+	write(w, resolve.ErrorResponse(ctx, resolve.ForbiddenCode,
+		errors.Errorf("The token doesn't have the claim %s, which names the namespace", claim)))
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/stretchr/testify/require"
+)
+
+// named answers with its name.
+func named(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	})
+}
+
+func TestWithNamespaces(t *testing.T) {
+	handlers := map[string]http.Handler{"acme": named("acme"), "globex": named("globex")}
+
+	tests := []struct {
+		name     string
+		ns       Namespaces
+		header   string
+		claims   map[string]interface{}
+		status   int
+		response string
+	}{
+		{
+			name:     "header",
+			ns:       Namespaces{Handlers: handlers},
+			header:   "globex",
+			status:   http.StatusOK,
+			response: "globex",
+		},
+		{
+			name:     "no namespace",
+			ns:       Namespaces{Handlers: handlers},
+			status:   http.StatusOK,
+			response: "default",
+		},
+		{
+			name:   "unknown namespace",
+			ns:     Namespaces{Handlers: handlers},
+			header: "initech",
+			status: http.StatusNotFound,
+			response: `{"errors":[{"message":"There's no GraphQL API for namespace \"initech\"",` +
+				`"extensions":{"code":"BAD_REQUEST"`,
+		},
+		{
+			name:     "claim",
+			ns:       Namespaces{Claim: "tenant", Handlers: handlers},
+			claims:   map[string]interface{}{"tenant": "acme"},
+			status:   http.StatusOK,
+			response: "acme",
+		},
+		{
+			name:     "claim wins over header",
+			ns:       Namespaces{Claim: "tenant", Handlers: handlers},
+			header:   "globex",
+			claims:   map[string]interface{}{"tenant": "acme"},
+			status:   http.StatusOK,
+			response: "acme",
+		},
+		{
+			name:   "anonymous in claim mode",
+			ns:     Namespaces{Claim: "tenant", Handlers: handlers},
+			header: "globex",
+			status: http.StatusUnauthorized,
+			response: `{"errors":[{"message":"A token naming the namespace is required",` +
+				`"extensions":{"code":"UNAUTHENTICATED"`,
+		},
+		{
+			name:   "token without claim",
+			ns:     Namespaces{Claim: "tenant", Handlers: handlers},
+			header: "globex",
+			claims: map[string]interface{}{"sub": "wile"},
+			status: http.StatusForbidden,
+			response: `{"errors":[{"message":"The token doesn't have the claim tenant, ` +
+				`which names the namespace","extensions":{"code":"FORBIDDEN"`,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.name, func(t *testing.T) {
+			handler := WithNamespaces(named("default"), tcase.ns)
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					if tcase.claims != nil {
+						r = r.WithContext(authorization.WithClaims(r.Context(), tcase.claims))
+					}
+					handler.ServeHTTP(w, r)
+				}))
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL, nil)
+			require.NoError(t, err)
+			if tcase.header != "" {
+				req.Header.Set(DefaultNamespaceHeader, tcase.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tcase.status, resp.StatusCode)
+			require.Contains(t, string(body), tcase.response)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+)
+
+// WithRateLimit refuses requests from clients that are over their limit in
+// limiter with an HTTP 429, whose Retry-After header says how many seconds to
+// wait.  Clients are told apart by their API key, in apiKeyHeader, if that's
+// set and the request has one; then by their token's subject, so the claims
+// must already be in the request's context, as WithJWT puts them; and then
+// by their IP address.  API keys aren't checked here, so apiKeyHeader should
+// only be set behind a gateway that checks them.
+//
+// The client's key is added to the request's context, so that the resolver
+// can limit its mutations too.
+func WithRateLimit(handler http.Handler, limiter *resolve.RateLimiter,
+	apiKeyHeader string) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := resolve.WithClientKey(r.Context(), clientKey(r, apiKeyHeader))
+		r = r.WithContext(ctx)
+
+		if ok, wait := limiter.AllowRequest(ctx); !ok {
+			ctx = requestContext(w, r)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(resolve.RetryAfter(wait)))
+			w.WriteHeader(http.StatusTooManyRequests)
+			write(w, resolve.ErrorResponse(ctx, resolve.RateLimitedCode,
+				resolve.RateLimitError("Too many requests; try again in "+
+					strconv.Itoa(resolve.RetryAfter(wait))+"s", wait)))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// clientKey is what r's client is rate limited as.
+func clientKey(r *http.Request, apiKeyHeader string) string {
+	if apiKeyHeader != "" {
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			return "key:" + key
+		}
+	}
+	if sub, _ := authorization.Claims(r.Context())["sub"].(string); sub != "" {
+		return "sub:" + sub
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// retryAfter returns how many seconds the client has to wait if any of resps
+// was refused because the client is over its rate limit - the longest wait,
+// if there's more than one - and 0 otherwise.
+func retryAfter(resps ...*schema.Response) int {
+	longest := 0
+	for _, resp := range resps {
+		if resp == nil || len(resp.Errors) == 0 {
+			continue
+		}
+		e := resp.Errors[0]
+		if code, _ := e.Extensions["code"].(string); code != resolve.RateLimitedCode {
+			continue
+		}
+		if secs, _ := e.Extensions["retryAfter"].(int); secs > longest {
+			longest = secs
+		}
+	}
+	return longest
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitServer serves a schema whose mutations don't go to Dgraph, limiting
+// clients, who can send API keys in X-Api-Key, to limits.
+func rateLimitServer(t *testing.T, limits resolve.RateLimits) *httptest.Server {
+	handler, err := schema.NewHandler(`type Author { id: ID! name: String! }`)
+	require.NoError(t, err)
+
+	limiter := resolve.NewRateLimiter(limits)
+	resolver := resolve.New(handler.Schema(), &staticDgraph{}).
+		WithPollInterval(time.Hour).
+		WithRateLimiter(limiter).
+		WithFieldResolver("addAuthor",
+			func(ctx context.Context, field schema.Field) (interface{}, error) {
+				return map[string]interface{}{}, nil
+			})
+	return httptest.NewServer(WithRateLimit(GraphQLHTTPHandler(resolver), limiter, "X-Api-Key"))
+}
+
+func TestWithRateLimit(t *testing.T) {
+	srv := rateLimitServer(t, resolve.RateLimits{Requests: 0.001, RequestBurst: 1})
+	defer srv.Close()
+
+	post := func(apiKey, query string) (*http.Response, map[string]interface{}) {
+		body, err := json.Marshal(map[string]string{"query": query})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(string(body)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set("X-Api-Key", apiKey)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var gqlResp map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&gqlResp))
+		return resp, gqlResp
+	}
+	query := `query { getAuthor(id: "0x1") { name } }`
+
+	resp, _ := post("", query)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, gqlResp := post("", query)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "1000", resp.Header.Get("Retry-After"))
+	errs := gqlResp["errors"].([]interface{})
+	require.Len(t, errs, 1)
+	ext := errs[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, resolve.RateLimitedCode, ext["code"])
+	require.Equal(t, float64(1000), ext["retryAfter"])
+
+	// Clients with API keys have limits of their own.
+	resp, _ = post("key-1", query)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = post("key-1", query)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	resp, _ = post("key-2", query)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestWithRateLimitWrites(t *testing.T) {
+	srv := rateLimitServer(t, resolve.RateLimits{Writes: 0.001, WriteBurst: 1})
+	defer srv.Close()
+
+	mutation := `{"query": "mutation { addAuthor(input: [{name: \"A\"}]) { author { name } } }"}`
+	post := func() *http.Response {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(mutation))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	require.Equal(t, http.StatusOK, post().StatusCode)
+	resp := post()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "1000", resp.Header.Get("Retry-After"))
+
+	resp, err := http.Get(srv.URL + `?query={getAuthor(id:"0x1"){name}}`)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "queries aren't writes")
+}
+
+func TestWithRateLimitBatch(t *testing.T) {
+	srv := rateLimitServer(t, resolve.RateLimits{Writes: 0.001, WriteBurst: 1})
+	defer srv.Close()
+
+	batch := `[{"query": "{ getAuthor(id: \"0x1\") { name } }"},
+		{"query": "mutation { addAuthor(input: [{name: \"A\"}]) { author { name } } }"}]`
+	post := func() (*http.Response, []map[string]interface{}) {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(batch))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var gqlResps []map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&gqlResps))
+		return resp, gqlResps
+	}
+
+	resp, _ := post()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Retry-After"))
+
+	// The query still runs, so the batch is a 200, but its mutation is refused.
+	resp, gqlResps := post()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "1000", resp.Header.Get("Retry-After"))
+	require.Len(t, gqlResps, 2)
+	require.Nil(t, gqlResps[0]["errors"])
+	errs := gqlResps[1]["errors"].([]interface{})
+	ext := errs[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	require.Equal(t, resolve.RateLimitedCode, ext["code"])
+}
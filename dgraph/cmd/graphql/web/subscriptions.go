@@ -0,0 +1,396 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/authorization"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/golang/glog"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// GraphQL over WebSockets comes in two protocols, negotiated as the
+// WebSocket subprotocol:
+//   - graphql-ws, from the subscriptions-transport-ws library, and
+//   - graphql-transport-ws, from the graphql-ws library.
+//
+// They differ mostly in the names of their messages.
+const (
+	graphqlWS          = "graphql-ws"
+	graphqlTransportWS = "graphql-transport-ws"
+)
+
+var (
+	// keepAliveInterval is how often graphql-ws clients are sent a keep-alive
+	// message.
+	keepAliveInterval = 20 * time.Second
+
+	// initTimeout is how long a client has, after connecting, to send
+	// connection_init.
+	initTimeout = 10 * time.Second
+)
+
+// Close codes that graphql-transport-ws defines.
+const (
+	closeBadRequest       = 4400
+	closeUnauthorized     = 4401
+	closeForbidden        = 4403
+	closeInitTimeout      = 4408
+	closeDuplicateID      = 4409
+	closeTooManyInitCalls = 4429
+)
+
+// wsProtocol names the messages of a GraphQL over WebSocket protocol.
+type wsProtocol struct {
+	start     string // client starts an operation
+	stop      string // client stops an operation
+	terminate string // client ends the connection; "" if there's no message
+	data      string // server sends a result
+	keepAlive string // server keep-alive; "" if the server doesn't send one
+}
+
+var wsProtocols = map[string]*wsProtocol{
+	graphqlWS: {
+		start:     "start",
+		stop:      "stop",
+		terminate: "connection_terminate",
+		data:      "data",
+		keepAlive: "ka",
+	},
+	graphqlTransportWS: {
+		start: "subscribe",
+		stop:  "complete",
+		data:  "next",
+	},
+}
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsSession is one client's WebSocket connection, and the operations it's
+// running.
+type wsSession struct {
+	conn     *wsConn
+	name     string
+	protocol *wsProtocol
+	resolver *resolve.RequestResolver
+
+	// verifier checks a token sent with connection_init; it's nil if the
+	// server doesn't use JWTs.
+	verifier *authorization.Verifier
+
+	// requestID is the ID of the connection's HTTP request.  An operation's
+	// request ID is that, then a slash and the operation's id.
+	requestID string
+
+	mu         sync.Mutex
+	acked      bool
+	claims     map[string]interface{}
+	operations map[string]*operation
+}
+
+// operation is a running operation; it's identified by pointer so that an
+// operation that's finishing doesn't forget a newer one with the same id.
+type operation struct {
+	cancel context.CancelFunc
+}
+
+// serveWebSocket runs GraphQL operations, usually subscriptions, over a
+// WebSocket connection until the client disconnects.
+func (gh *graphqlHandler) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	requestID := resolve.RequestID(requestContext(w, r))
+	conn, name, err := upgradeWebSocket(w, r,
+		[]string{graphqlTransportWS, graphqlWS}, maxRequestSize)
+	if err != nil {
+		glog.V(2).Infof("WebSocket connection failed: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &wsSession{
+		conn:       conn,
+		name:       name,
+		protocol:   wsProtocols[name],
+		resolver:   gh.resolver,
+		verifier:   verifierFrom(r.Context()),
+		requestID:  requestID,
+		claims:     authorization.Claims(r.Context()),
+		operations: make(map[string]*operation),
+	}
+	defer s.stopAll()
+
+	initTimer := time.AfterFunc(initTimeout, func() {
+		if !s.isAcked() {
+			_ = conn.close(closeInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+
+	if s.protocol.keepAlive != "" {
+		go s.keepAlive(ctx)
+	}
+
+	for {
+		msg, err := conn.readMessage()
+		if err != nil {
+			if err != errWSClosed {
+				glog.V(2).Infof("WebSocket connection ended: %v", err)
+			}
+			_ = conn.close(wsNormalClosure, "")
+			return
+		}
+		if !s.handle(ctx, msg) {
+			return
+		}
+	}
+}
+
+// handle acts on one message from the client.  It returns false if the
+// connection has been closed.
+func (s *wsSession) handle(ctx context.Context, raw []byte) bool {
+	var msg wsMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return s.fail(msg.ID, closeBadRequest, "Invalid message received")
+	}
+
+	switch msg.Type {
+	case "connection_init":
+		s.mu.Lock()
+		alreadyAcked := s.acked
+		s.acked = true
+		s.mu.Unlock()
+		if alreadyAcked {
+			return s.fail(msg.ID, closeTooManyInitCalls, "Too many initialisation requests")
+		}
+		if err := s.authenticate(msg.Payload); err != nil {
+			_ = s.conn.close(closeForbidden, "Forbidden")
+			return false
+		}
+		s.send(wsMessage{Type: "connection_ack"})
+		if s.protocol.keepAlive != "" {
+			s.send(wsMessage{Type: s.protocol.keepAlive})
+		}
+	case "ping":
+		s.send(wsMessage{Type: "pong", Payload: msg.Payload})
+	case "pong":
+	case s.protocol.start:
+		if !s.isAcked() {
+			return s.fail(msg.ID, closeUnauthorized, "Unauthorized")
+		}
+		if msg.ID == "" {
+			return s.fail(msg.ID, closeBadRequest, "Operations must have an id")
+		}
+		var req schema.Request
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			return s.fail(msg.ID, closeBadRequest, "Invalid operation payload")
+		}
+		return s.start(ctx, msg.ID, &req)
+	case s.protocol.stop:
+		s.stop(msg.ID)
+	case s.protocol.terminate:
+		_ = s.conn.close(wsNormalClosure, "")
+		return false
+	default:
+		return s.fail(msg.ID, closeBadRequest, fmt.Sprintf("Unknown message type %q", msg.Type))
+	}
+	return true
+}
+
+// authenticate checks the token, if there's one, in a connection_init
+// payload, and takes its claims for the session's operations.  The token is
+// keyed by the name of the JWT header, in any case, e.g.
+// {"Authorization": "Bearer ..."}.
+func (s *wsSession) authenticate(payload json.RawMessage) error {
+	if s.verifier == nil || len(payload) == 0 {
+		return nil
+	}
+
+	var params map[string]interface{}
+	if err := json.Unmarshal(payload, &params); err != nil {
+		// Clients can send anything in the payload; it's only a token
+		// that's of interest here.
+		return nil
+	}
+	for key, val := range params {
+		value, ok := val.(string)
+		if !ok || !strings.EqualFold(key, s.verifier.Header()) {
+			continue
+		}
+		claims, err := s.verifier.Verify(authorization.Token(value))
+		if err != nil {
+			glog.V(2).Infof("WebSocket connection refused: %v", err)
+			return err
+		}
+		s.mu.Lock()
+		s.claims = claims
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// start runs operation id, sending its results until it's finished or
+// stopped.
+func (s *wsSession) start(ctx context.Context, id string, req *schema.Request) bool {
+	s.mu.Lock()
+	if _, running := s.operations[id]; running {
+		s.mu.Unlock()
+		return s.fail(id, closeDuplicateID, "Subscriber for "+id+" already exists")
+	}
+	if s.claims != nil {
+		ctx = authorization.WithClaims(ctx, s.claims)
+	}
+	ctx = resolve.WithRequestID(ctx, s.requestID+"/"+id)
+	opCtx, cancel := context.WithCancel(ctx)
+	op := &operation{cancel: cancel}
+	s.operations[id] = op
+	s.mu.Unlock()
+
+	go func() {
+		defer s.finished(id, op)
+
+		results, errResp := s.resolver.Subscribe(opCtx, req)
+		if errResp != nil {
+			s.sendErrors(id, errResp.Errors)
+			return
+		}
+		for resp := range results {
+			var buf bytes.Buffer
+			if _, err := resp.WriteTo(&buf); err != nil {
+				glog.Errorf("Error writing GraphQL response: %v", err)
+				continue
+			}
+			s.send(wsMessage{ID: id, Type: s.protocol.data, Payload: buf.Bytes()})
+		}
+		if opCtx.Err() == nil {
+			s.send(wsMessage{ID: id, Type: "complete"})
+		}
+	}()
+	return true
+}
+
+// finished forgets op, which has ended.
+func (s *wsSession) finished(id string, op *operation) {
+	op.cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.operations[id] == op {
+		delete(s.operations, id)
+	}
+}
+
+// stop stops operation id at the client's request.  Nothing more is sent for
+// it, and the client can reuse id straight away.
+func (s *wsSession) stop(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if op, ok := s.operations[id]; ok {
+		op.cancel()
+		delete(s.operations, id)
+	}
+}
+
+func (s *wsSession) stopAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, op := range s.operations {
+		op.cancel()
+	}
+}
+
+func (s *wsSession) isAcked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acked
+}
+
+func (s *wsSession) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.isAcked() {
+				continue
+			}
+			s.send(wsMessage{Type: s.protocol.keepAlive})
+		}
+	}
+}
+
+// sendErrors reports that operation id couldn't be run.  graphql-transport-ws
+// has an error message for that; graphql-ws sends a result with just errors,
+// and completes the operation.
+func (s *wsSession) sendErrors(id string, errs gqlerror.List) {
+	if s.name == graphqlWS {
+		var buf bytes.Buffer
+		if _, err := (&schema.Response{Errors: errs}).WriteTo(&buf); err != nil {
+			glog.Errorf("Error writing GraphQL response: %v", err)
+			return
+		}
+		s.send(wsMessage{ID: id, Type: s.protocol.data, Payload: buf.Bytes()})
+		s.send(wsMessage{ID: id, Type: "complete"})
+		return
+	}
+
+	payload, err := json.Marshal(errs)
+	if err != nil {
+		glog.Errorf("Error writing GraphQL errors: %v", err)
+		return
+	}
+	s.send(wsMessage{ID: id, Type: "error", Payload: payload})
+}
+
+// fail reports a protocol error.  graphql-transport-ws closes the connection
+// with code; graphql-ws reports the error and carries on.  It returns false
+// if the connection was closed.
+func (s *wsSession) fail(id string, code int, msg string) bool {
+	if s.name == graphqlTransportWS {
+		_ = s.conn.close(code, msg)
+		return false
+	}
+	payload, _ := json.Marshal(map[string]string{"message": msg})
+	s.send(wsMessage{ID: id, Type: "error", Payload: payload})
+	return true
+}
+
+func (s *wsSession) send(msg wsMessage) {
+	js, err := json.Marshal(msg)
+	if err != nil {
+		glog.Errorf("Error writing WebSocket message: %v", err)
+		return
+	}
+	if err := s.conn.writeMessage(js); err != nil && err != errWSClosed {
+		glog.V(2).Infof("Error writing WebSocket message: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/dgraph/cmd/graphql/resolve"
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+// wsClient is a bare WebSocket client, just enough to test the server.
+type wsClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWS(t *testing.T, srv *httptest.Server, protocol string) *wsClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\n" +
+		"Host: " + srv.Listener.Addr().String() + "\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Upgrade: websocket\r\n" +
+		"X-Request-Id: conn-1\r\n" +
+		"Sec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Protocol: " + protocol + "\r\n\r\n"))
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	// The example accept key from RFC 6455.
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	require.Equal(t, protocol, resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	return &wsClient{t: t, conn: conn, br: br}
+}
+
+func (c *wsClient) send(msg string) {
+	payload := []byte(msg)
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | wsText}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := c.conn.Write(frame)
+	require.NoError(c.t, err)
+}
+
+// read returns the next frame's opcode and payload.
+func (c *wsClient) read() (byte, []byte) {
+	require.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var hdr [2]byte
+	_, err := io.ReadFull(c.br, hdr[:])
+	require.NoError(c.t, err)
+	length := int(hdr[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		require.NoError(c.t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		require.NoError(c.t, err)
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.br, payload)
+	require.NoError(c.t, err)
+	return hdr[0] & 0x0F, payload
+}
+
+func (c *wsClient) expect(expected string) {
+	opcode, payload := c.read()
+	require.Equal(c.t, byte(wsText), opcode, string(payload))
+	require.JSONEq(c.t, expected, string(payload))
+}
+
+func (c *wsClient) expectClose(code int) {
+	opcode, payload := c.read()
+	require.Equal(c.t, byte(wsClose), opcode)
+	require.Equal(c.t, code, int(binary.BigEndian.Uint16(payload)))
+}
+
+func subscriptionServer(t *testing.T) *httptest.Server {
+	handler, err := schema.NewHandler(`type Author { id: ID! name: String! }`)
+	require.NoError(t, err)
+
+	client := &staticDgraph{result: `{"subscribeAuthor": [{"name": "A.N. Author"}],
+		"getAuthor": [{"name": "A.N. Author"}]}`}
+	resolver := resolve.New(handler.Schema(), client).WithPollInterval(time.Hour)
+	return httptest.NewServer(GraphQLHTTPHandler(resolver))
+}
+
+func TestGraphQLTransportWS(t *testing.T) {
+	srv := subscriptionServer(t)
+	defer srv.Close()
+
+	c := dialWS(t, srv, "graphql-transport-ws")
+	defer c.conn.Close()
+
+	c.send(`{"type": "connection_init"}`)
+	c.expect(`{"type": "connection_ack"}`)
+
+	c.send(`{"type": "ping"}`)
+	c.expect(`{"type": "pong"}`)
+
+	c.send(`{"id": "1", "type": "subscribe",
+		"payload": {"query": "subscription { subscribeAuthor { name } }"}}`)
+	c.expect(`{"id": "1", "type": "next",
+		"payload": {"data": {"subscribeAuthor": [{"name": "A.N. Author"}]}}}`)
+
+	c.send(`{"id": "2", "type": "subscribe",
+		"payload": {"query": "query { getAuthor(id: \"0x1\") { name } }"}}`)
+	c.expect(`{"id": "2", "type": "next",
+		"payload": {"data": {"getAuthor": {"name": "A.N. Author"}}}}`)
+	c.expect(`{"id": "2", "type": "complete"}`)
+
+	c.send(`{"id": "3", "type": "subscribe",
+		"payload": {"query": "subscription { subscribeAuthor { notAField } }"}}`)
+	c.expect(`{"id": "3", "type": "error", "payload": [{
+		"message": "Cannot query field \"notAField\" on type \"Author\".",
+		"locations": [{"line": 1, "column": 34}],
+		"extensions": {"code": "VALIDATION_FAILED", "requestId": "conn-1/3"}}]}`)
+
+	c.send(`{"id": "1", "type": "complete"}`)
+
+	// Once 1 is stopped, its id can be used again; a duplicate is an error.
+	c.send(`{"id": "1", "type": "subscribe",
+		"payload": {"query": "subscription { subscribeAuthor { name } }"}}`)
+	c.expect(`{"id": "1", "type": "next",
+		"payload": {"data": {"subscribeAuthor": [{"name": "A.N. Author"}]}}}`)
+	c.send(`{"id": "1", "type": "subscribe",
+		"payload": {"query": "subscription { subscribeAuthor { name } }"}}`)
+	c.expectClose(closeDuplicateID)
+}
+
+func TestGraphQLTransportWSUnauthorized(t *testing.T) {
+	srv := subscriptionServer(t)
+	defer srv.Close()
+
+	c := dialWS(t, srv, "graphql-transport-ws")
+	defer c.conn.Close()
+
+	c.send(`{"id": "1", "type": "subscribe",
+		"payload": {"query": "subscription { subscribeAuthor { name } }"}}`)
+	c.expectClose(closeUnauthorized)
+}
+
+func TestGraphQLWS(t *testing.T) {
+	srv := subscriptionServer(t)
+	defer srv.Close()
+
+	c := dialWS(t, srv, "graphql-ws")
+	defer c.conn.Close()
+
+	c.send(`{"type": "connection_init", "payload": {}}`)
+	c.expect(`{"type": "connection_ack"}`)
+	c.expect(`{"type": "ka"}`)
+
+	c.send(`{"id": "1", "type": "start",
+		"payload": {"query": "subscription { subscribeAuthor { name } }"}}`)
+	c.expect(`{"id": "1", "type": "data",
+		"payload": {"data": {"subscribeAuthor": [{"name": "A.N. Author"}]}}}`)
+
+	c.send(`{"id": "2", "type": "start",
+		"payload": {"query": "subscription { subscribeAuthor { notAField } }"}}`)
+	c.expect(`{"id": "2", "type": "data", "payload": {"errors": [{
+		"message": "Cannot query field \"notAField\" on type \"Author\".",
+		"locations": [{"line": 1, "column": 34}],
+		"extensions": {"code": "VALIDATION_FAILED", "requestId": "conn-1/2"}}]}}`)
+	c.expect(`{"id": "2", "type": "complete"}`)
+
+	c.send(`{"type": "bogus"}`)
+	c.expect(`{"type": "error", "payload": {"message": "Unknown message type \"bogus\""}}`)
+
+	c.send(`{"id": "1", "type": "stop"}`)
+	c.send(`{"type": "connection_terminate"}`)
+	c.expectClose(wsNormalClosure)
+}
+
+func TestWebSocketProtocolNegotiation(t *testing.T) {
+	srv := subscriptionServer(t)
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Protocol", "some-other-protocol")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
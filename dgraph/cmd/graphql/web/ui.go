@@ -0,0 +1,150 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// UIAssets are the files the UI loads: GraphiQL, and the version of React it
+// needs.  Unless they're served by this server, with UIAssetsHandler, they're
+// loaded from unpkg, at these exact versions.
+var UIAssets = []string{
+	"graphiql.css",
+	"react.production.min.js",
+	"react-dom.production.min.js",
+	"graphiql.min.js",
+}
+
+// unpkgAssets are where unpkg serves UIAssets.
+var unpkgAssets = map[string]string{
+	"graphiql.css":                "graphiql@0.13.2/graphiql.css",
+	"react.production.min.js":     "react@16.8.6/umd/react.production.min.js",
+	"react-dom.production.min.js": "react-dom@16.8.6/umd/react-dom.production.min.js",
+	"graphiql.min.js":             "graphiql@0.13.2/graphiql.min.js",
+}
+
+var uiPage = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Dgraph GraphQL</title>
+  <style>
+    body { height: 100vh; margin: 0; overflow: hidden; }
+    #graphiql { height: 100vh; }
+  </style>
+  <link rel="stylesheet" href="{{index .Assets "graphiql.css"}}">
+  <script src="{{index .Assets "react.production.min.js"}}"></script>
+  <script src="{{index .Assets "react-dom.production.min.js"}}"></script>
+  <script src="{{index .Assets "graphiql.min.js"}}"></script>
+</head>
+<body>
+  <div id="graphiql">Loading...</div>
+  <script>
+    function fetcher(params) {
+      return fetch({{.Endpoint}}, {
+        method: "POST",
+        headers: { "Content-Type": "application/json", "Accept": "application/json" },
+        body: JSON.stringify(params),
+        credentials: "same-origin"
+      }).then(function (resp) { return resp.json(); });
+    }
+    ReactDOM.render(
+      React.createElement(GraphiQL, { fetcher: fetcher }),
+      document.getElementById("graphiql"));
+  </script>
+</body>
+</html>
+`))
+
+type uiHandler struct {
+	endpoint string
+	assets   map[string]string
+}
+
+// UIHandler returns an http.Handler that serves GraphiQL, an interactive
+// explorer for the GraphQL API served at endpoint.  The explorer loads the
+// API's schema by introspection, so the generated queries and mutations can
+// be browsed, and run, without any other tools.  If assets isn't empty, it's
+// the path that UIAssetsHandler serves the UI's files at; otherwise they're
+// loaded from unpkg.
+func UIHandler(endpoint, assets string) http.Handler {
+	uh := &uiHandler{endpoint: endpoint, assets: make(map[string]string, len(UIAssets))}
+	for _, file := range UIAssets {
+		if assets != "" {
+			uh.assets[file] = path.Join(assets, file)
+		} else {
+			uh.assets[file] = "https://unpkg.com/" + unpkgAssets[file]
+		}
+	}
+	return uh
+}
+
+// UIAssetsHandler returns an http.Handler that serves the UIAssets in dir at
+// prefix, so that the UI doesn't load any code from elsewhere.  Nothing else
+// in dir is served.
+func UIAssetsHandler(prefix, dir string) http.Handler {
+	files := http.StripPrefix(prefix, http.FileServer(http.Dir(dir)))
+	assets := make(map[string]bool, len(UIAssets))
+	for _, file := range UIAssets {
+		assets[path.Join(prefix, file)] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !assets[r.URL.Path] {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
+
+func (uh *uiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := uiPage.Execute(w, struct {
+		Endpoint string
+		Assets   map[string]string
+	}{uh.endpoint, uh.assets})
+	if err != nil {
+		glog.Errorf("Error writing GraphQL UI: %v", err)
+	}
+}
+
+// WithUI serves the UI for the GraphQL API served by handler at endpoint to
+// browsers - GET requests, without a query, that accept HTML - and passes
+// every other request on to handler.  assets is as for UIHandler.
+func WithUI(handler http.Handler, endpoint, assets string) http.Handler {
+	ui := UIHandler(endpoint, assets)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wantsUI(r) {
+			ui.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func wantsUI(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		r.URL.Query().Get("query") == "" &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
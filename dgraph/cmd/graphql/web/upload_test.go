@@ -0,0 +1,143 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+// multipartRequest builds a request with the operations and map fields, and
+// a file part for each of files.
+func multipartRequest(t *testing.T, operations, fileMap string,
+	files map[string]string) *http.Request {
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("operations", operations))
+	if fileMap != "" {
+		require.NoError(t, mw.WriteField("map", fileMap))
+	}
+	for name, content := range files {
+		part, err := mw.CreateFormFile(name, name+".txt")
+		require.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func uploadContent(t *testing.T, val interface{}) string {
+	upload, ok := val.(*schema.Upload)
+	require.True(t, ok, "%v isn't an upload", val)
+	in, err := upload.Open()
+	require.NoError(t, err)
+	defer in.Close()
+	content, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), upload.Size)
+	return string(content)
+}
+
+func TestMultipartRequest(t *testing.T) {
+	r := multipartRequest(t,
+		`{"query": "mutation ($file: Upload, $files: [Upload]) { f }",
+			"variables": {"file": null, "files": [null, null]}}`,
+		`{"0": ["variables.file", "variables.files.1"], "1": ["variables.files.0"]}`,
+		map[string]string{"0": "zero", "1": "one"})
+	gqlReq, batch, status, err := getRequest(r)
+	require.NoError(t, err)
+	defer r.MultipartForm.RemoveAll()
+	require.Equal(t, http.StatusOK, status)
+	require.Nil(t, batch)
+
+	require.Equal(t, "0.txt", gqlReq.Variables["file"].(*schema.Upload).Filename)
+	require.Equal(t, "zero", uploadContent(t, gqlReq.Variables["file"]))
+	files := gqlReq.Variables["files"].([]interface{})
+	require.Equal(t, "one", uploadContent(t, files[0]))
+	require.True(t, files[1] == gqlReq.Variables["file"], "a file can be used twice")
+}
+
+func TestMultipartBatch(t *testing.T) {
+	r := multipartRequest(t,
+		`[{"query": "mutation { f }"},
+			{"query": "mutation ($file: Upload) { f }", "variables": {"file": null}}]`,
+		`{"a": ["1.variables.file"]}`,
+		map[string]string{"a": "A"})
+	gqlReq, batch, _, err := getRequest(r)
+	require.NoError(t, err)
+	defer r.MultipartForm.RemoveAll()
+	require.Nil(t, gqlReq)
+	require.Len(t, batch, 2)
+	require.Equal(t, "A", uploadContent(t, batch[1].Variables["file"]))
+}
+
+func TestMultipartRequestErrors(t *testing.T) {
+	ops := `{"query": "mutation ($file: Upload) { f }", "variables": {"file": null}}`
+	tests := []struct {
+		name       string
+		operations string
+		fileMap    string
+		files      map[string]string
+		err        string
+	}{
+		{name: "no operations", fileMap: `{}`,
+			err: "A multipart request needs one operations field"},
+		{name: "bad map", operations: ops, fileMap: `["variables.file"]`,
+			err: "Not a valid multipart request map"},
+		{name: "missing file", operations: ops, fileMap: `{"0": ["variables.file"]}`,
+			err: "File 0 is in the map, but wasn't sent"},
+		{name: "not a variable", operations: ops, fileMap: `{"0": ["query"]}`,
+			files: map[string]string{"0": "zero"},
+			err:   "File 0 can't go at query; files can only be the values of variables"},
+		{name: "past the end of a list", operations: ops,
+			fileMap: `{"0": ["variables.file.3"]}`, files: map[string]string{"0": "zero"},
+			err: "File 0 can't go at variables.file.3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var r *http.Request
+			if test.operations == "" {
+				var body bytes.Buffer
+				mw := multipart.NewWriter(&body)
+				require.NoError(t, mw.WriteField("map", test.fileMap))
+				require.NoError(t, mw.Close())
+				r = httptest.NewRequest(http.MethodPost, "/graphql", &body)
+				r.Header.Set("Content-Type", mw.FormDataContentType())
+			} else {
+				r = multipartRequest(t, test.operations, test.fileMap, test.files)
+			}
+
+			_, _, status, err := getRequest(r)
+			require.Error(t, err)
+			require.Equal(t, http.StatusBadRequest, status)
+			require.Contains(t, err.Error(), test.err)
+		})
+	}
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// This is just enough of RFC 6455 (WebSockets) to serve GraphQL subscriptions:
+// unfragmented or fragmented text messages, pings and closing.  Extensions
+// such as compression aren't negotiated.
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+
+	wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// Close codes.
+	wsNormalClosure   = 1000
+	wsProtocolError   = 1002
+	wsMessageTooBig   = 1009
+	wsWriteTimeout    = 10 * time.Second
+	wsMaxControlFrame = 125
+)
+
+// errWSClosed is returned by readMessage when the peer closes the connection.
+var errWSClosed = errors.New("WebSocket closed")
+
+type wsConn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	maxLen int64
+
+	// wmu serialises writes; messages are written from several goroutines.
+	wmu    sync.Mutex
+	closed bool
+}
+
+// isWebSocketUpgrade reports whether r asks to switch to the WebSocket
+// protocol.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		headerHasToken(r.Header, "Connection", "upgrade") &&
+		headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket completes the WebSocket handshake for r, choosing the
+// first of the client's subprotocols that's in protocols.  If the handshake
+// can't be done, an HTTP error has already been sent.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, protocols []string,
+	maxLen int64) (*wsConn, string, error) {
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "Unsupported WebSocket version", http.StatusBadRequest)
+		return nil, "", errors.New("bad WebSocket handshake")
+	}
+
+	var protocol string
+	for _, offered := range r.Header["Sec-Websocket-Protocol"] {
+		for _, p := range strings.Split(offered, ",") {
+			p = strings.TrimSpace(p)
+			for _, supported := range protocols {
+				if protocol == "" && p == supported {
+					protocol = p
+				}
+			}
+		}
+	}
+	if protocol == "" {
+		http.Error(w, "Unsupported WebSocket subprotocol, use one of "+
+			strings.Join(protocols, ", "), http.StatusBadRequest)
+		return nil, "", errors.New("no supported WebSocket subprotocol")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSockets aren't supported", http.StatusInternalServerError)
+		return nil, "", errors.New("can't hijack the connection")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "while hijacking the connection")
+	}
+
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	_, err = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n" +
+		"Sec-WebSocket-Protocol: " + protocol + "\r\n\r\n"))
+	if err != nil {
+		conn.Close()
+		return nil, "", errors.Wrap(err, "while completing the WebSocket handshake")
+	}
+
+	return &wsConn{conn: conn, br: rw.Reader, maxLen: maxLen}, protocol, nil
+}
+
+// readMessage reads the next text message, answering pings along the way.
+// It returns errWSClosed once the peer closes the connection.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			_ = c.close(wsNormalClosure, "")
+			return nil, errWSClosed
+		case wsText, wsBinary:
+			if started {
+				_ = c.close(wsProtocolError, "expected a continuation frame")
+				return nil, errors.New("WebSocket message interrupted")
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				_ = c.close(wsProtocolError, "unexpected continuation frame")
+				return nil, errors.New("unexpected WebSocket continuation frame")
+			}
+		default:
+			_ = c.close(wsProtocolError, "unknown opcode")
+			return nil, errors.Errorf("unknown WebSocket opcode %d", opcode)
+		}
+
+		if int64(len(msg)+len(payload)) > c.maxLen {
+			_ = c.close(wsMessageTooBig, "message too big")
+			return nil, errors.New("WebSocket message too big")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(c.br, hdr[:]); err != nil {
+		return
+	}
+	fin = hdr[0]&0x80 != 0
+	opcode = hdr[0] & 0x0F
+	masked := hdr[1]&0x80 != 0
+	length := int64(hdr[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	// Clients must mask what they send (RFC 6455, section 5.1).
+	if !masked {
+		_ = c.close(wsProtocolError, "frames from clients must be masked")
+		err = errors.New("unmasked WebSocket frame")
+		return
+	}
+	if length < 0 || length > c.maxLen ||
+		(opcode >= wsClose && length > wsMaxControlFrame) {
+		_ = c.close(wsMessageTooBig, "frame too big")
+		err = errors.New("WebSocket frame too big")
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// writeMessage sends data as a single text message.
+func (c *wsConn) writeMessage(data []byte) error {
+	return c.writeFrame(wsText, data)
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return errWSClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *wsConn) writeFrameLocked(opcode byte, payload []byte) error {
+	hdr := []byte{0x80 | opcode, 0}
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xFFFF:
+		hdr[1] = 126
+		hdr = append(hdr, 0, 0)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = append(hdr, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(append(hdr, payload...)); err != nil {
+		return errors.Wrap(err, "while writing to the WebSocket")
+	}
+	return nil
+}
+
+// close sends a close frame with code and reason, and closes the
+// connection.  Closing an already closed connection does nothing.
+func (c *wsConn) close(code int, reason string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	if len(reason) > wsMaxControlFrame-2 {
+		reason = reason[:wsMaxControlFrame-2]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	_ = c.writeFrameLocked(wsClose, payload)
+	return c.conn.Close()
+}
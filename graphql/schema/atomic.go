@@ -0,0 +1,58 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A mutation operation with @atomic, e.g.
+//
+//	mutation @atomic {
+//		addAuthor(input: [{ name: "A. N. Author" }]) { author { id } }
+//		updatePost(input: { filter: { id: ["0x1"] }, set: { title: "New" } }) { post { id } }
+//	}
+//
+// runs all its mutations in one Dgraph transaction: either they all commit,
+// or, if any of them fails, none of them does.  Without @atomic, each
+// mutation commits on its own, in order.  Only the mutations that Dgraph
+// resolves can share a transaction, so an @atomic operation can't have
+// @custom, @lambda or @remote mutations.
+
+// Atomic returns true if o is a mutation operation with @atomic.
+func (o *operation) Atomic() bool {
+	return o.IsMutation() && o.op.Directives.ForName(atomicDirective) != nil
+}
+
+// validateAtomic checks that, if o is @atomic, all its mutations can run in
+// the one Dgraph transaction.
+func (o *operation) validateAtomic() gqlerror.List {
+	if !o.Atomic() {
+		return nil
+	}
+
+	var errs gqlerror.List
+	for _, f := range o.rootFields() {
+		switch o.inSchema.mutationType(f.Name) {
+		case CustomMutation, LambdaMutation, RemoteMutation:
+			errs = append(errs, gqlerror.ErrorPosf(f.Position,
+				"@atomic: mutation %s isn't resolved by Dgraph, so it can't be part of "+
+					"an atomic operation.", f.Name))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,658 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+	"github.com/vektah/gqlparser/parser"
+)
+
+// AuthOperation is the kind of access that an @auth rule controls.
+type AuthOperation string
+
+// The operations that @auth has rules for.
+const (
+	AuthQuery  AuthOperation = "query"
+	AuthAdd    AuthOperation = "add"
+	AuthUpdate AuthOperation = "update"
+	AuthDelete AuthOperation = "delete"
+)
+
+const (
+	authDirective = "auth"
+	authRuleArg   = "rule"
+	authRolesArg  = "roles"
+	authClaimArg  = "claim"
+)
+
+// claimKeyPrefix stands in for the $ of a claim condition's key, which isn't
+// valid GraphQL, while a rule is parsed.
+const claimKeyPrefix = "__claim_"
+
+// DefaultRoleClaim is the claim that holds a request's roles, for the @auth
+// directives of fields that don't say which claim to use.
+const DefaultRoleClaim = "ROLE"
+
+var authOperations = []AuthOperation{AuthQuery, AuthAdd, AuthUpdate, AuthDelete}
+
+// An AuthRule is one rule from an @auth directive.  A rule is a filter on
+// its type, written like the filter argument of queryT, that can use the
+// claims of the request as variables.  For example,
+//
+//	type Todo @auth(query: "{ owner: { eq: $USER } }") { ... }
+//
+// only lets a request see the todos whose owner is its USER claim.
+//
+// A rule can also have claim conditions, which are checked against the
+// request's claims alone, without looking at the data.  They're keyed by the
+// claim, and compare it with eq or in; a claim that's a list meets the
+// condition if any of its values does.  So
+//
+//	type Todo @auth(rule: "{ or: { $ROLE: { eq: \"ADMIN\" } }, owner: { eq: $USER } }")
+//
+// lets admins do anything with any todo, and others only with their own.  The
+// rule argument is the rule for every operation without a rule of its own.
+type AuthRule struct {
+	rule  *ast.Value
+	claim []string
+
+	// reads is every claim the rule looks at: its variables, and the claims
+	// of its claim conditions.
+	reads []string
+
+	// conditions is true if the rule has claim conditions.
+	conditions bool
+}
+
+// Claims returns the names of the claims that the rule's filter depends on,
+// in order.
+func (r *AuthRule) Claims() []string {
+	return r.reads
+}
+
+// Filter returns the rule's filter with the claims substituted for its
+// variables.  It's an error if the rule uses a claim that's not in claims,
+// unless its claim conditions make that part of the rule irrelevant, or if
+// its claim conditions mean it can never hold.  A rule that always holds
+// returns an empty filter.
+func (r *AuthRule) Filter(claims map[string]interface{}) (map[string]interface{}, error) {
+	var missing error
+	for _, c := range r.claim {
+		if _, ok := claims[c]; !ok {
+			missing = errors.Errorf("the claim %s is required", c)
+			break
+		}
+	}
+	if missing != nil && !r.conditions {
+		return nil, missing
+	}
+
+	val, err := r.rule.Value(claims)
+	if err != nil {
+		return nil, err
+	}
+	filter, _ := val.(map[string]interface{})
+	if !r.conditions {
+		return filter, nil
+	}
+
+	filter, res := applyClaimConditions(filter, claims)
+	switch {
+	case res == ruleAlways:
+		return map[string]interface{}{}, nil
+	case missing != nil:
+		return nil, missing
+	case res == ruleNever:
+		return nil, errors.New("the rule's claim conditions aren't met")
+	}
+	return filter, nil
+}
+
+// A ruleResult is what's known about a rule, or a level of one, once its
+// claim conditions are checked.
+type ruleResult int
+
+const (
+	// ruleFilter is a rule that still has to be checked against the data.
+	ruleFilter ruleResult = iota
+	ruleAlways
+	ruleNever
+)
+
+// applyClaimConditions checks the claim conditions in filter, a level of a
+// rule, against claims.  It returns the filter that's left, which has no claim
+// conditions, and whether that always holds, never holds or is a filter to
+// check against the data.  As in buildFilter, a level is
+// ((conditions and and) or or) and not not.
+func applyClaimConditions(filter map[string]interface{},
+	claims map[string]interface{}) (map[string]interface{}, ruleResult) {
+
+	conds := make(map[string]interface{})
+	res := ruleAlways
+	for key, val := range filter {
+		switch {
+		case key == "or" || key == "not":
+			continue
+		case key == "and":
+			sub, _ := val.(map[string]interface{})
+			sub, subRes := applyClaimConditions(sub, claims)
+			switch subRes {
+			case ruleNever:
+				res = ruleNever
+			case ruleFilter:
+				conds[key] = sub
+			}
+		case strings.HasPrefix(key, claimKeyPrefix):
+			if !claimMeets(claims[strings.TrimPrefix(key, claimKeyPrefix)], val) {
+				res = ruleNever
+			}
+		default:
+			conds[key] = val
+		}
+	}
+	if res == ruleAlways && len(conds) > 0 {
+		res = ruleFilter
+	}
+
+	result := conds
+	if or, ok := filter["or"].(map[string]interface{}); ok {
+		orFilter, orRes := applyClaimConditions(or, claims)
+		switch {
+		case res == ruleAlways || orRes == ruleAlways:
+			result, res = nil, ruleAlways
+		case res == ruleNever:
+			result, res = orFilter, orRes
+		case orRes == ruleFilter:
+			result["or"] = orFilter
+		}
+	}
+	if not, ok := filter["not"].(map[string]interface{}); ok && res != ruleNever {
+		notFilter, notRes := applyClaimConditions(not, claims)
+		switch {
+		case notRes == ruleAlways:
+			result, res = nil, ruleNever
+		case notRes == ruleFilter && res == ruleAlways:
+			result, res = map[string]interface{}{"not": notFilter}, ruleFilter
+		case notRes == ruleFilter:
+			result = map[string]interface{}{"and": result, "not": notFilter}
+		}
+	}
+	return result, res
+}
+
+// claimMeets returns true if claim, or one of its values if it's a list, meets
+// cond, a claim condition's { eq: value } or { in: [values] }.
+func claimMeets(claim interface{}, cond interface{}) bool {
+	c, _ := cond.(map[string]interface{})
+	var want []interface{}
+	if eq, ok := c["eq"]; ok {
+		want = append(want, eq)
+	}
+	if in, ok := c["in"].([]interface{}); ok {
+		want = append(want, in...)
+	}
+
+	have, ok := claim.([]interface{})
+	if !ok {
+		if claim == nil {
+			return false
+		}
+		have = []interface{}{claim}
+	}
+	for _, h := range have {
+		for _, w := range want {
+			// Claims are JSON, so numbers are float64s; rules' are int64s.
+			if fmt.Sprint(h) == fmt.Sprint(w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// A FieldAuth is the @auth directive of a field, which restricts the field,
+// rather than its type's objects, to requests with one of Roles.  For example,
+//
+//	type Employee {
+//		...
+//		salary: Int @auth(roles: ["HR", "ADMIN"])
+//	}
+//
+// only lets requests whose ROLE claim is HR or ADMIN, or is a list with
+// either in it, see or set salary; for others, it's null.  Claim is the claim
+// with the request's roles.
+type FieldAuth struct {
+	Roles []string
+	Claim string
+}
+
+// fieldAuth returns the @auth directive of fld, or nil if it hasn't got one.
+// The directive must already be known to be valid.
+func fieldAuth(fld *ast.FieldDefinition) *FieldAuth {
+	dir := fld.Directives.ForName(authDirective)
+	if dir == nil {
+		return nil
+	}
+	fa := &FieldAuth{Claim: DefaultRoleClaim}
+	if arg := dir.Arguments.ForName(authRolesArg); arg != nil && arg.Value != nil {
+		for _, child := range arg.Value.Children {
+			fa.Roles = append(fa.Roles, child.Value.Raw)
+		}
+		if arg.Value.Kind == ast.StringValue {
+			// A single value is coerced to a list of one.
+			fa.Roles = []string{arg.Value.Raw}
+		}
+	}
+	if arg := dir.Arguments.ForName(authClaimArg); arg != nil && arg.Value != nil {
+		fa.Claim = arg.Value.Raw
+	}
+	return fa
+}
+
+// authFieldRule checks the @auth directive of field, if it has one.  It has
+// to name roles, and nothing else, and the field has to be one that can be
+// null for requests without the roles.  A field inherited from an interface
+// must have the same directive as the interface's field, because they're the
+// same data.
+func authFieldRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(authDirective)
+	if dir != nil && customRootTypes[defn.Name] {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @auth is only allowed on the fields of types.",
+			defn.Name, field.Name)
+	}
+	if dir != nil {
+		for _, arg := range dir.Arguments {
+			if arg.Name != authRolesArg && arg.Name != authClaimArg {
+				return gqlerror.ErrorPosf(arg.Position,
+					"Type %s; Field %s: @auth %s rules are only allowed on types; "+
+						"on a field, @auth takes roles.", defn.Name, field.Name, arg.Name)
+			}
+		}
+		roles := dir.Arguments.ForName(authRolesArg)
+		if roles == nil || roles.Value == nil ||
+			(roles.Value.Kind == ast.ListValue && len(roles.Value.Children) == 0) {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: @auth needs the roles that can access the field.",
+				defn.Name, field.Name)
+		}
+		if claim := dir.Arguments.ForName(authClaimArg); claim != nil &&
+			claim.Value != nil && claim.Value.Raw == "" {
+			return gqlerror.ErrorPosf(claim.Position,
+				"Type %s; Field %s: @auth claim can't be empty.", defn.Name, field.Name)
+		}
+		if field.Type.NonNull {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: has @auth, so it must be nullable: it's null for "+
+					"requests that can't see it.", defn.Name, field.Name)
+		}
+	}
+
+	for _, iface := range defn.Interfaces {
+		idefn := doc.Definitions.ForName(iface)
+		if idefn == nil {
+			continue
+		}
+		ifld := idefn.Fields.ForName(field.Name)
+		if ifld == nil {
+			continue
+		}
+		if !sameFieldAuth(fieldAuth(ifld), fieldAuth(field)) {
+			return gqlerror.ErrorPosf(field.Position,
+				"Type %s; Field %s: must have the same @auth directive as field %s of "+
+					"interface %s.", defn.Name, field.Name, field.Name, iface)
+		}
+	}
+	return nil
+}
+
+func sameFieldAuth(a, b *FieldAuth) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Claim != b.Claim || len(a.Roles) != len(b.Roles) {
+		return false
+	}
+	for i := range a.Roles {
+		if a.Roles[i] != b.Roles[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAuthRules parses the rules in the @auth directive of defn, if there
+// is one.  The rules must already be known to be valid.
+func parseAuthRules(defn *ast.Definition) map[AuthOperation]*AuthRule {
+	dir := defn.Directives.ForName(authDirective)
+	if dir == nil {
+		return nil
+	}
+
+	rules := make(map[AuthOperation]*AuthRule)
+	for _, op := range authOperations {
+		arg := dir.Arguments.ForName(string(op))
+		if arg == nil {
+			arg = dir.Arguments.ForName(authRuleArg)
+		}
+		if arg == nil || arg.Value == nil || arg.Value.Kind != ast.StringValue {
+			continue
+		}
+		val, err := parseAuthRule(arg.Value.Raw)
+		if err != nil {
+			continue
+		}
+		rules[op] = &AuthRule{rule: val, claim: variables(val), reads: claimsRead(val),
+			conditions: hasConditions(val)}
+	}
+	return rules
+}
+
+// parseAuthRule parses rule as a GraphQL value.  There's no parser entry
+// point for a lone value, so it's parsed as the argument of a query.  The
+// keys of claim conditions, like $ROLE, are parsed as claimKeyPrefix+ROLE.
+func parseAuthRule(rule string) (*ast.Value, *gqlerror.Error) {
+	rule = claimKeys(rule)
+	doc, gqlErr := parser.ParseQuery(&ast.Source{Input: "{ rule(filter: " + rule + ") }"})
+	if gqlErr != nil {
+		return nil, gqlErr
+	}
+	if len(doc.Operations) != 1 || len(doc.Operations[0].SelectionSet) != 1 {
+		return nil, gqlerror.Errorf("a rule must be a single filter")
+	}
+	fld, ok := doc.Operations[0].SelectionSet[0].(*ast.Field)
+	if !ok || len(fld.Arguments) != 1 || len(fld.SelectionSet) != 0 {
+		return nil, gqlerror.Errorf("a rule must be a single filter")
+	}
+	return fld.Arguments[0].Value, nil
+}
+
+// claimKeys replaces the $ of each claim condition's key in rule - a $NAME
+// that's followed by a colon, outside of any string - with claimKeyPrefix.
+func claimKeys(rule string) string {
+	var buf strings.Builder
+	inString := false
+	for i := 0; i < len(rule); i++ {
+		c := rule[i]
+		switch {
+		case c == '\\' && inString && i+1 < len(rule):
+			buf.WriteByte(c)
+			i++
+			c = rule[i]
+		case c == '"':
+			inString = !inString
+		case c == '$' && !inString:
+			end := i + 1
+			for end < len(rule) && isNameByte(rule[end]) {
+				end++
+			}
+			next := end
+			for next < len(rule) && (rule[next] == ' ' || rule[next] == '\t' ||
+				rule[next] == '\n') {
+				next++
+			}
+			if end > i+1 && next < len(rule) && rule[next] == ':' {
+				buf.WriteString(claimKeyPrefix)
+				continue
+			}
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// hasConditions returns true if val, a rule, has claim conditions.
+func hasConditions(val *ast.Value) bool {
+	if val == nil {
+		return false
+	}
+	for _, child := range val.Children {
+		if strings.HasPrefix(child.Name, claimKeyPrefix) || hasConditions(child.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// variables returns the names of the variables in val, in order.
+func variables(val *ast.Value) []string {
+	seen := make(map[string]bool)
+	var walk func(v *ast.Value)
+	walk = func(v *ast.Value) {
+		if v == nil {
+			return
+		}
+		if v.Kind == ast.Variable {
+			seen[v.Raw] = true
+		}
+		for _, child := range v.Children {
+			walk(child.Value)
+		}
+	}
+	walk(val)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// claimsRead returns the names of the variables in val, and of the claims its
+// claim conditions check, in order.
+func claimsRead(val *ast.Value) []string {
+	seen := make(map[string]bool)
+	for _, name := range variables(val) {
+		seen[name] = true
+	}
+	var walk func(v *ast.Value)
+	walk = func(v *ast.Value) {
+		if v == nil {
+			return
+		}
+		for _, child := range v.Children {
+			if strings.HasPrefix(child.Name, claimKeyPrefix) {
+				seen[strings.TrimPrefix(child.Name, claimKeyPrefix)] = true
+			}
+			walk(child.Value)
+		}
+	}
+	walk(val)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateAuthRules checks that the @auth rules on the types of sch are
+// filters on their types.  That can only be checked once the filter types
+// have been generated.
+func validateAuthRules(sch *ast.Schema) gqlerror.List {
+	var errs gqlerror.List
+	for _, name := range definitionNames(sch) {
+		defn := sch.Types[name]
+		dir := defn.Directives.ForName(authDirective)
+		if dir == nil {
+			continue
+		}
+		if defn.Kind != ast.Object {
+			errs = append(errs, gqlerror.ErrorPosf(dir.Position,
+				"Type %s; @auth directive is only allowed on types.", defn.Name))
+			continue
+		}
+		if len(dir.Arguments) == 0 {
+			errs = append(errs, gqlerror.ErrorPosf(dir.Position,
+				"Type %s; @auth directive doesn't have any rules.", defn.Name))
+			continue
+		}
+		if arg := dir.Arguments.ForName(authRolesArg); arg != nil {
+			errs = append(errs, gqlerror.ErrorPosf(arg.Position,
+				"Type %s; @auth roles are only allowed on fields.", defn.Name))
+			continue
+		}
+		if arg := dir.Arguments.ForName(authClaimArg); arg != nil {
+			errs = append(errs, gqlerror.ErrorPosf(arg.Position,
+				"Type %s; @auth claim is only allowed on fields.", defn.Name))
+			continue
+		}
+
+		filter := ast.NamedType(defn.Name+"Filter", nil)
+		for _, arg := range dir.Arguments {
+			if arg.Value == nil || arg.Value.Kind != ast.StringValue {
+				continue
+			}
+			val, gqlErr := parseAuthRule(arg.Value.Raw)
+			if gqlErr == nil {
+				gqlErr = checkRuleValue(sch, val, filter)
+			}
+			if gqlErr != nil {
+				errs = append(errs, gqlerror.ErrorPosf(arg.Value.Position,
+					"Type %s; @auth %s rule is invalid: %s", defn.Name, arg.Name,
+					gqlErr.Message))
+			}
+		}
+	}
+	return errs
+}
+
+// checkRuleValue checks that val could be a value of type typ.  A variable
+// can stand for any value, because claims aren't typed.
+func checkRuleValue(sch *ast.Schema, val *ast.Value, typ *ast.Type) *gqlerror.Error {
+	switch val.Kind {
+	case ast.Variable:
+		return nil
+	case ast.NullValue:
+		if typ.NonNull {
+			return gqlerror.Errorf("null isn't allowed for %s", typ)
+		}
+		return nil
+	}
+
+	if typ.Elem != nil {
+		if val.Kind != ast.ListValue {
+			// A single value is coerced to a list of one.
+			return checkRuleValue(sch, val, typ.Elem)
+		}
+		for _, child := range val.Children {
+			if err := checkRuleValue(sch, child.Value, typ.Elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	defn := sch.Types[typ.NamedType]
+	if defn == nil {
+		return gqlerror.Errorf("unknown type %s", typ.NamedType)
+	}
+
+	switch defn.Kind {
+	case ast.InputObject:
+		if val.Kind != ast.ObjectValue {
+			return gqlerror.Errorf("%s isn't a %s", val, defn.Name)
+		}
+		for _, child := range val.Children {
+			if strings.HasPrefix(child.Name, claimKeyPrefix) {
+				if err := checkClaimCondition(defn, child); err != nil {
+					return err
+				}
+				continue
+			}
+			fld := defn.Fields.ForName(child.Name)
+			if fld == nil {
+				return gqlerror.Errorf("%s isn't a field of %s", child.Name, defn.Name)
+			}
+			if err := checkRuleValue(sch, child.Value, fld.Type); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.Enum:
+		if val.Kind == ast.EnumValue && defn.EnumValues.ForName(val.Raw) != nil {
+			return nil
+		}
+	default:
+		if scalarValueKinds[defn.Name][val.Kind] {
+			return nil
+		}
+	}
+	return gqlerror.Errorf("%s isn't a %s", val, defn.Name)
+}
+
+// checkClaimCondition checks that child, a claim condition in a value of defn,
+// compares the claim with eq or in.  Conditions are only allowed in the
+// filters of types, which, unlike the filters of fields, have a not.
+func checkClaimCondition(defn *ast.Definition, child *ast.ChildValue) *gqlerror.Error {
+	claim := "$" + strings.TrimPrefix(child.Name, claimKeyPrefix)
+	if defn.Fields.ForName("not") == nil {
+		return gqlerror.Errorf("%s can only be used at the top of a rule, or in its and, "+
+			"or or not", claim)
+	}
+
+	val := child.Value
+	if val.Kind == ast.ObjectValue && len(val.Children) == 1 {
+		cond := val.Children[0]
+		switch {
+		case cond.Name == "eq" && isClaimValue(cond.Value):
+			return nil
+		case cond.Name == "in" && cond.Value.Kind == ast.ListValue:
+			ok := true
+			for _, item := range cond.Value.Children {
+				ok = ok && isClaimValue(item.Value)
+			}
+			if ok {
+				return nil
+			}
+		}
+	}
+	return gqlerror.Errorf("%s must be compared with { eq: value } or { in: [values] }", claim)
+}
+
+func isClaimValue(val *ast.Value) bool {
+	switch val.Kind {
+	case ast.StringValue, ast.IntValue, ast.FloatValue, ast.BooleanValue, ast.EnumValue:
+		return true
+	}
+	return false
+}
+
+// scalarValueKinds are the kinds of literal that can be given for each
+// scalar.
+var scalarValueKinds = map[string]map[ast.ValueKind]bool{
+	"ID":       {ast.StringValue: true, ast.IntValue: true},
+	"Boolean":  {ast.BooleanValue: true},
+	"Int":      {ast.IntValue: true},
+	"Float":    {ast.IntValue: true, ast.FloatValue: true},
+	"String":   {ast.StringValue: true, ast.BlockValue: true},
+	"DateTime": {ast.StringValue: true},
+}
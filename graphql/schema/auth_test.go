@@ -0,0 +1,152 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/ast"
+)
+
+func TestAuthRules(t *testing.T) {
+	handler, err := NewHandler(`
+		type Todo @auth(
+			query: "{ or: { owner: { eq: $USER }, isPublic: true }, owner: { eq: $ADMIN } }",
+			delete: "{ owner: { eq: \"admin\" } }") {
+			id: ID!
+			owner: String! @search(by: [hash])
+			isPublic: Boolean @search
+		}
+		type Note { id: ID! }`)
+	require.NoError(t, err)
+	sch := handler.Schema().(*schema)
+
+	todo := &astType{typ: &ast.Type{NamedType: "Todo"}, inSchema: sch}
+	require.Nil(t, todo.AuthRule(AuthAdd))
+	require.Nil(t, todo.AuthRule(AuthUpdate))
+
+	del := todo.AuthRule(AuthDelete)
+	require.NotNil(t, del)
+	filter, err := del.Filter(nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"owner": map[string]interface{}{"eq": "admin"},
+	}, filter)
+
+	query := todo.AuthRule(AuthQuery)
+	require.NotNil(t, query)
+	_, err = query.Filter(map[string]interface{}{"USER": "alice"})
+	require.EqualError(t, err, "the claim ADMIN is required")
+
+	filter, err = query.Filter(map[string]interface{}{"USER": "alice", "ADMIN": "root"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"or": map[string]interface{}{
+			"owner":    map[string]interface{}{"eq": "alice"},
+			"isPublic": true,
+		},
+		"owner": map[string]interface{}{"eq": "root"},
+	}, filter)
+
+	note := &astType{typ: &ast.Type{NamedType: "Note"}, inSchema: sch}
+	require.Nil(t, note.AuthRule(AuthQuery))
+}
+
+func TestFieldAuth(t *testing.T) {
+	handler, err := NewHandler(`
+		interface Person { id: ID! email: String @auth(roles: "HR") }
+		type Employee implements Person {
+			id: ID!
+			email: String @auth(roles: "HR")
+			salary: Int @auth(roles: ["HR", "ADMIN"], claim: "roles")
+			name: String
+		}`)
+	require.NoError(t, err)
+	sch := handler.Schema().(*schema)
+
+	emp := &astType{typ: &ast.Type{NamedType: "Employee"}, inSchema: sch}
+	require.Equal(t, &FieldAuth{Roles: []string{"HR", "ADMIN"}, Claim: "roles"},
+		emp.Field("salary").Auth())
+	require.Equal(t, &FieldAuth{Roles: []string{"HR"}, Claim: DefaultRoleClaim},
+		emp.Field("email").Auth())
+	require.Nil(t, emp.Field("name").Auth())
+
+	op, err := handler.Schema().Operation(&Request{
+		Query: `query { queryPerson { email } queryEmployee { salary name } }`})
+	require.NoError(t, err)
+	require.Equal(t, []string{"HR"}, op.Queries()[0].SelectionSet()[0].Auth().Roles)
+	sel := op.Queries()[1].SelectionSet()
+	require.Equal(t, "roles", sel[0].Auth().Claim)
+	require.Nil(t, sel[1].Auth())
+}
+
+func TestAuthClaimConditions(t *testing.T) {
+	handler, err := NewHandler(`
+		type Todo @auth(
+			rule: "{ or: { $ROLE: { eq: \"ADMIN\" } }, owner: { eq: $USER } }",
+			delete: "{ $ROLE: { in: [\"ADMIN\", \"OWNER\"] }, not: { $BANNED: { eq: true } } }",
+			query: "{ $LEVEL: { eq: 2 }, and: { isPublic: true } }") {
+			id: ID!
+			owner: String! @search(by: [hash])
+			isPublic: Boolean @search
+		}`)
+	require.NoError(t, err)
+	sch := handler.Schema().(*schema)
+	todo := &astType{typ: &ast.Type{NamedType: "Todo"}, inSchema: sch}
+
+	// The rule argument is the rule for add and update.
+	add := todo.AuthRule(AuthAdd)
+	require.Equal(t, add, todo.AuthRule(AuthUpdate))
+
+	filter, err := add.Filter(map[string]interface{}{"ROLE": "ADMIN"})
+	require.NoError(t, err)
+	require.Empty(t, filter)
+	_, err = add.Filter(map[string]interface{}{"ROLE": "USER"})
+	require.EqualError(t, err, "the claim USER is required")
+
+	filter, err = add.Filter(map[string]interface{}{"ROLE": []interface{}{"USER"}, "USER": "alice"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"owner": map[string]interface{}{"eq": "alice"},
+	}, filter)
+
+	filter, err = add.Filter(map[string]interface{}{"ROLE": []interface{}{"USER", "ADMIN"},
+		"USER": "alice"})
+	require.NoError(t, err)
+	require.Empty(t, filter)
+
+	del := todo.AuthRule(AuthDelete)
+	filter, err = del.Filter(map[string]interface{}{"ROLE": "OWNER"})
+	require.NoError(t, err)
+	require.Empty(t, filter)
+	_, err = del.Filter(map[string]interface{}{"ROLE": "OWNER", "BANNED": true})
+	require.EqualError(t, err, "the rule's claim conditions aren't met")
+	_, err = del.Filter(map[string]interface{}{"ROLE": "USER"})
+	require.EqualError(t, err, "the rule's claim conditions aren't met")
+	_, err = del.Filter(map[string]interface{}{})
+	require.EqualError(t, err, "the rule's claim conditions aren't met")
+
+	query := todo.AuthRule(AuthQuery)
+	filter, err = query.Filter(map[string]interface{}{"LEVEL": 2.0})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"and": map[string]interface{}{"isPublic": true},
+	}, filter)
+	_, err = query.Filter(map[string]interface{}{"LEVEL": 1.0})
+	require.EqualError(t, err, "the rule's claim conditions aren't met")
+}
@@ -0,0 +1,335 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+)
+
+// A TypeBuilder builds a type of a GraphQL schema in Go, for programs that
+// embed the GraphQL layer and would rather not write the schema as a string,
+// e.g.
+//
+//	author := schema.NewType("Author").
+//		Field("id", schema.ID.NonNull()).
+//		Field("name", schema.String.NonNull(), schema.Searchable(schema.SearchHash)).
+//		Field("posts", schema.ListOf(schema.Named("Post")), schema.HasInverse("author"))
+//
+// Build makes a schema from the types, by way of the SDL that they print as,
+// so it's checked and generated exactly as the same schema written in SDL
+// would be.  The builder doesn't check anything itself, except that the
+// directive arguments can be written in GraphQL; mistakes are reported by
+// Build.
+type TypeBuilder struct {
+	defn *ast.Definition
+	err  error
+}
+
+// A TypeRef is the type of a field: a named type, or a list, either of which
+// can be non-null.
+type TypeRef struct {
+	typ *ast.Type
+}
+
+// The scalars that fields can be.
+var (
+	ID       = Named(IDType)
+	String   = Named("String")
+	Int      = Named("Int")
+	Float    = Named("Float")
+	Boolean  = Named("Boolean")
+	DateTime = Named("DateTime")
+)
+
+// Named is the type called name, e.g. an object, interface or enum type of
+// the schema.
+func Named(name string) TypeRef {
+	return TypeRef{typ: ast.NamedType(name, nil)}
+}
+
+// ListOf is a list of elem.
+func ListOf(elem TypeRef) TypeRef {
+	return TypeRef{typ: ast.ListType(elem.typ, nil)}
+}
+
+// NonNull is t, non-null.
+func (t TypeRef) NonNull() TypeRef {
+	typ := *t.typ
+	typ.NonNull = true
+	return TypeRef{typ: &typ}
+}
+
+// A SearchIndex is an index that Searchable makes a field searchable by.
+type SearchIndex string
+
+// The search indexes.  Which can be used depends on the field's type; see
+// @search.
+const (
+	SearchHash     SearchIndex = "hash"
+	SearchExact    SearchIndex = "exact"
+	SearchTerm     SearchIndex = "term"
+	SearchFulltext SearchIndex = "fulltext"
+	SearchTrigram  SearchIndex = "trigram"
+	SearchInt      SearchIndex = "int"
+	SearchFloat    SearchIndex = "float"
+	SearchBool     SearchIndex = "bool"
+	SearchYear     SearchIndex = "year"
+	SearchMonth    SearchIndex = "month"
+	SearchDay      SearchIndex = "day"
+	SearchHour     SearchIndex = "hour"
+)
+
+// A DirectiveArg is an argument of a directive.  Its Value is written as a
+// GraphQL value: strings, booleans and numbers as themselves, an Enum as an
+// enum value, nil as null, slices as lists and maps with string keys as
+// input objects.
+type DirectiveArg struct {
+	Name  string
+	Value interface{}
+}
+
+// An Enum is a DirectiveArg value that's an enum value, like the hash in
+// @search(by: [hash]), rather than a string.
+type Enum string
+
+// Arg is a directive argument.
+func Arg(name string, value interface{}) DirectiveArg {
+	return DirectiveArg{Name: name, Value: value}
+}
+
+// A FieldOption adds to a field, e.g. a directive or a description.
+type FieldOption func(fld *ast.FieldDefinition) error
+
+// NewType starts building an object type.
+func NewType(name string) *TypeBuilder {
+	return &TypeBuilder{defn: &ast.Definition{Kind: ast.Object, Name: name}}
+}
+
+// NewInterface starts building an interface.
+func NewInterface(name string) *TypeBuilder {
+	return &TypeBuilder{defn: &ast.Definition{Kind: ast.Interface, Name: name}}
+}
+
+// NewEnum builds an enum type with values.  Enum types don't have fields.
+func NewEnum(name string, values ...string) *TypeBuilder {
+	defn := &ast.Definition{Kind: ast.Enum, Name: name}
+	for _, val := range values {
+		defn.EnumValues = append(defn.EnumValues, &ast.EnumValueDefinition{Name: val})
+	}
+	return &TypeBuilder{defn: defn}
+}
+
+// Field adds a field called name, of type typ, to the type.
+func (b *TypeBuilder) Field(name string, typ TypeRef, opts ...FieldOption) *TypeBuilder {
+	if b.defn.Kind == ast.Enum {
+		b.fail(errors.Errorf("enum %s can't have field %s", b.defn.Name, name))
+		return b
+	}
+	fld := &ast.FieldDefinition{Name: name, Type: typ.typ}
+	for _, opt := range opts {
+		if err := opt(fld); err != nil {
+			b.fail(errors.Wrapf(err, "type %s; field %s", b.defn.Name, name))
+		}
+	}
+	b.defn.Fields = append(b.defn.Fields, fld)
+	return b
+}
+
+// Implements makes the type implement the interfaces called names.
+func (b *TypeBuilder) Implements(names ...string) *TypeBuilder {
+	if b.defn.Kind != ast.Object {
+		b.fail(errors.Errorf("only object types can implement interfaces, but %s isn't one",
+			b.defn.Name))
+		return b
+	}
+	b.defn.Interfaces = append(b.defn.Interfaces, names...)
+	return b
+}
+
+// Description documents the type.
+func (b *TypeBuilder) Description(desc string) *TypeBuilder {
+	b.defn.Description = desc
+	return b
+}
+
+// Directive adds the directive called name, e.g. "softDelete" or "auth", to
+// the type.
+func (b *TypeBuilder) Directive(name string, args ...DirectiveArg) *TypeBuilder {
+	dir, err := directive(name, args)
+	if err != nil {
+		b.fail(errors.Wrapf(err, "type %s", b.defn.Name))
+		return b
+	}
+	b.defn.Directives = append(b.defn.Directives, dir)
+	return b
+}
+
+// fail records the first mistake in building the type.
+func (b *TypeBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Searchable makes a field searchable with @search, by the given indexes or,
+// if there are none, by the default index for the field's type.
+func Searchable(by ...SearchIndex) FieldOption {
+	if len(by) == 0 {
+		return WithDirective(searchDirective)
+	}
+	indexes := make([]Enum, len(by))
+	for i, idx := range by {
+		indexes[i] = Enum(idx)
+	}
+	return WithDirective(searchDirective, Arg(searchArgs, indexes))
+}
+
+// HasInverse makes a field the inverse of field, the field of the type it
+// links to that links back, with @hasInverse.
+func HasInverse(field string) FieldOption {
+	return WithDirective(inverseDirective, Arg(inverseArg, field))
+}
+
+// Xid makes a String! field an external ID with @xid.
+func Xid() FieldOption {
+	return WithDirective(xidDirective)
+}
+
+// Private hides a field from the API with @private.
+func Private() FieldOption {
+	return WithDirective(privateDirective)
+}
+
+// Description documents a field.
+func Description(desc string) FieldOption {
+	return func(fld *ast.FieldDefinition) error {
+		fld.Description = desc
+		return nil
+	}
+}
+
+// WithDirective adds the directive called name to a field, e.g.
+// WithDirective("default", Arg("add", "draft")).
+func WithDirective(name string, args ...DirectiveArg) FieldOption {
+	return func(fld *ast.FieldDefinition) error {
+		dir, err := directive(name, args)
+		if err != nil {
+			return err
+		}
+		fld.Directives = append(fld.Directives, dir)
+		return nil
+	}
+}
+
+func directive(name string, args []DirectiveArg) (*ast.Directive, error) {
+	dir := &ast.Directive{Name: name}
+	for _, arg := range args {
+		val, err := literal(arg.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "@%s argument %s", name, arg.Name)
+		}
+		dir.Arguments = append(dir.Arguments, &ast.Argument{Name: arg.Name, Value: val})
+	}
+	return dir, nil
+}
+
+// literal is the GraphQL value for v, as DirectiveArg describes.
+func literal(v interface{}) (*ast.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return &ast.Value{Kind: ast.NullValue, Raw: "null"}, nil
+	case Enum:
+		return &ast.Value{Kind: ast.EnumValue, Raw: string(v)}, nil
+	case string:
+		return &ast.Value{Kind: ast.StringValue, Raw: v}, nil
+	case bool:
+		return &ast.Value{Kind: ast.BooleanValue, Raw: strconv.FormatBool(v)}, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &ast.Value{Kind: ast.IntValue, Raw: strconv.FormatInt(rv.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &ast.Value{Kind: ast.IntValue, Raw: strconv.FormatUint(rv.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		raw := strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+		if !strings.ContainsAny(raw, ".eE") {
+			raw += ".0"
+		}
+		return &ast.Value{Kind: ast.FloatValue, Raw: raw}, nil
+	case reflect.Slice, reflect.Array:
+		list := &ast.Value{Kind: ast.ListValue}
+		for i := 0; i < rv.Len(); i++ {
+			item, err := literal(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			list.Children = append(list.Children, &ast.ChildValue{Value: item})
+		}
+		return list, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		obj := &ast.Value{Kind: ast.ObjectValue}
+		for _, key := range keys {
+			item, err := literal(rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key())).
+				Interface())
+			if err != nil {
+				return nil, err
+			}
+			obj.Children = append(obj.Children, &ast.ChildValue{Name: key, Value: item})
+		}
+		return obj, nil
+	}
+	return nil, errors.Errorf("can't write a %T as a GraphQL value", v)
+}
+
+// SDL returns the schema made of types, as GraphQL SDL.
+func SDL(types ...*TypeBuilder) (string, error) {
+	var sb strings.Builder
+	p := newPrinter(&sb, FormatOptions{})
+	for _, typ := range types {
+		if typ.err != nil {
+			return "", typ.err
+		}
+		p.definition(typ.defn)
+	}
+	return sb.String(), nil
+}
+
+// Build makes a schema of types, checking it and generating its API just as
+// NewHandler does for SDL.  The Handler's Input is the SDL.
+func Build(types ...*TypeBuilder) (Handler, error) {
+	sdl, err := SDL(types...)
+	if err != nil {
+		return nil, err
+	}
+	return NewHandler(sdl)
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	status := NewEnum("Status", "DRAFT", "PUBLISHED")
+	node := NewInterface("Node").
+		Field("id", ID.NonNull())
+	author := NewType("Author").
+		Implements("Node").
+		Description("Someone who writes posts").
+		Field("id", ID.NonNull()).
+		Field("handle", String.NonNull(), Xid()).
+		Field("name", String.NonNull(), Searchable(SearchHash, SearchTerm),
+			Description("The author's name")).
+		Field("posts", ListOf(Named("Post").NonNull()), HasInverse("author"))
+	post := NewType("Post").
+		Implements("Node").
+		Directive("softDelete").
+		Field("id", ID.NonNull()).
+		Field("title", String.NonNull(), Searchable()).
+		Field("status", Named("Status"), Searchable(),
+			WithDirective("default", Arg("add", map[string]string{"value": "DRAFT"}))).
+		Field("score", Float,
+			WithDirective("default", Arg("add", map[string]interface{}{"value": "1.0"}))).
+		Field("author", Named("Author"))
+
+	sdl, err := SDL(status, node, author, post)
+	require.NoError(t, err)
+	require.Equal(t, `enum Status {
+	DRAFT
+	PUBLISHED
+}
+
+interface Node {
+	id: ID!
+}
+
+"""Someone who writes posts"""
+type Author implements Node {
+	id: ID!
+	handle: String! @xid
+	"""The author's name"""
+	name: String! @search(by: [hash,term])
+	posts: [Post!] @hasInverse(field: "author")
+}
+
+type Post implements Node @softDelete {
+	id: ID!
+	title: String! @search
+	status: Status @search @default(add: {value:"DRAFT"})
+	score: Float @default(add: {value:"1.0"})
+	author: Author
+}
+
+`, sdl)
+
+	built, err := Build(status, node, author, post)
+	require.NoError(t, err)
+	fromSDL, err := NewHandler(sdl)
+	require.NoError(t, err)
+	require.Equal(t, sdl, built.Input())
+	require.Equal(t, fromSDL.GQLSchema(), built.GQLSchema())
+	require.Equal(t, fromSDL.DGSchema(), built.DGSchema())
+	require.Contains(t, built.GQLSchema(), "getAuthor(id: ID, handle: String): Author")
+}
+
+func TestBuildErrors(t *testing.T) {
+	// Mistakes that the schema's validation finds are reported as they are for
+	// SDL.
+	_, err := Build(NewType("Author").
+		Field("id", ID.NonNull()).
+		Field("age", Int, Searchable(SearchHash)))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Type Author; Field age: has the @search directive but "+
+		"the argument hash doesn't apply to field type Int.")
+
+	_, err = Build(NewEnum("Status", "DRAFT").Field("label", String))
+	require.EqualError(t, err, "enum Status can't have field label")
+
+	_, err = Build(NewInterface("Node").Implements("Other"))
+	require.EqualError(t, err,
+		"only object types can implement interfaces, but Node isn't one")
+
+	_, err = Build(NewType("Author").
+		Field("id", ID.NonNull(), WithDirective("custom", Arg("http", struct{}{}))))
+	require.EqualError(t, err,
+		"type Author; field id: @custom argument http: can't write a struct {} as a GraphQL value")
+}
+
+func TestLiteral(t *testing.T) {
+	for _, tc := range []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, "null"},
+		{"a \"quoted\" string", `"a \"quoted\" string"`},
+		{true, "true"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.5, "2.5"},
+		{float32(3), "3.0"},
+		{Enum("ASC"), "ASC"},
+		{[]string{"a", "b"}, `["a","b"]`},
+		{map[string]interface{}{"url": "http://api", "method": Enum("GET"),
+			"body": map[string]int{"n": 1}},
+			`{body:{n:1},method:GET,url:"http://api"}`},
+	} {
+		val, err := literal(tc.value)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, val.String())
+	}
+
+	_, err := literal(map[int]string{1: "a"})
+	require.Error(t, err)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A CacheScope says who a cached value can be shared with.
+type CacheScope string
+
+const (
+	// PublicCache values are the same for everyone, so shared caches, like
+	// CDNs, can keep them.
+	PublicCache CacheScope = "PUBLIC"
+
+	// PrivateCache values are for the user that asked for them, so only their
+	// own cache can keep them.
+	PrivateCache CacheScope = "PRIVATE"
+)
+
+// A CacheHint says how long a field's value can be cached, and by whom.  It's
+// set with @cacheControl on the field, or on the type the field returns for
+// every field of that type:
+//
+//	type Post @cacheControl(maxAge: 60) {
+//		id: ID!
+//		title: String!
+//		views: Int @cacheControl(maxAge: 5)
+//		drafts: [Draft] @cacheControl(scope: PRIVATE)
+//	}
+//
+// Each of the hint's settings on the field overrides that setting on the
+// type.
+type CacheHint struct {
+	// MaxAge is how long the value can be cached for, if HasMaxAge.
+	MaxAge    time.Duration
+	HasMaxAge bool
+
+	// Scope is who the value can be cached for, or "" if the hint doesn't
+	// say.
+	Scope CacheScope
+}
+
+// cacheHint returns the hint for field fld, which returns type typ; typ is
+// nil if it isn't in the schema.
+func cacheHint(fld *ast.FieldDefinition, typ *ast.Definition) CacheHint {
+	var hint CacheHint
+	dirs := []ast.DirectiveList{fld.Directives}
+	if typ != nil {
+		dirs = append(dirs, typ.Directives)
+	}
+	for _, d := range dirs {
+		dir := d.ForName(cacheControlDirective)
+		if dir == nil {
+			continue
+		}
+		if arg := dir.Arguments.ForName(cacheControlMaxAgeArg); arg != nil && !hint.HasMaxAge {
+			// The directive was checked when the schema was validated.
+			secs, _ := strconv.Atoi(arg.Value.Raw)
+			hint.MaxAge, hint.HasMaxAge = time.Duration(secs)*time.Second, true
+		}
+		if arg := dir.Arguments.ForName(cacheControlScopeArg); arg != nil && hint.Scope == "" {
+			hint.Scope = CacheScope(arg.Value.Raw)
+		}
+	}
+	return hint
+}
+
+// cacheControlRule checks that the @cacheControl directive of defn, if it has
+// one, is on a type or an interface and has valid settings.
+func cacheControlRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	dir := defn.Directives.ForName(cacheControlDirective)
+	if dir == nil {
+		return nil
+	}
+	if defn.Kind != ast.Object && defn.Kind != ast.Interface {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @cacheControl directive is only allowed on types, interfaces and "+
+				"their fields.", defn.Name)
+	}
+	return checkCacheControl(dir, "Type "+defn.Name)
+}
+
+// cacheControlFieldRule checks that the @cacheControl directive of field, if
+// it has one, has valid settings.
+func cacheControlFieldRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(cacheControlDirective)
+	if dir == nil {
+		return nil
+	}
+	return checkCacheControl(dir, "Type "+defn.Name+"; Field "+field.Name)
+}
+
+func checkCacheControl(dir *ast.Directive, where string) *gqlerror.Error {
+	if arg := dir.Arguments.ForName(cacheControlMaxAgeArg); arg != nil {
+		secs, err := strconv.Atoi(arg.Value.Raw)
+		if arg.Value.Kind != ast.IntValue || err != nil || secs < 0 {
+			return gqlerror.ErrorPosf(dir.Position,
+				"%s: @cacheControl needs a maxAge that's a whole number of seconds, "+
+					"0 or more.", where)
+		}
+	}
+	if arg := dir.Arguments.ForName(cacheControlScopeArg); arg != nil {
+		if arg.Value.Kind != ast.EnumValue || (CacheScope(arg.Value.Raw) != PublicCache &&
+			CacheScope(arg.Value.Raw) != PrivateCache) {
+			return gqlerror.ErrorPosf(dir.Position,
+				"%s: @cacheControl needs a scope of %s or %s.", where, PublicCache, PrivateCache)
+		}
+	}
+	return nil
+}
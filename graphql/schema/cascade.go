@@ -0,0 +1,75 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// validateCascade checks the @cascade directives in sel: each must be on a
+// field of an object type, and the fields it names must be fields of that
+// type that are stored in Dgraph, because that's where they're checked.
+func (s *schema) validateCascade(sel ast.SelectionSet,
+	vars map[string]interface{}) gqlerror.List {
+
+	var errs gqlerror.List
+	for _, sel := range sel {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if dir := sel.Directives.ForName(cascadeDirective); dir != nil {
+				errs = append(errs, s.checkCascade(sel, dir, vars)...)
+			}
+			errs = append(errs, s.validateCascade(sel.SelectionSet, vars)...)
+		case *ast.InlineFragment:
+			errs = append(errs, s.validateCascade(sel.SelectionSet, vars)...)
+		case *ast.FragmentSpread:
+			if sel.Definition != nil {
+				errs = append(errs, s.validateCascade(sel.Definition.SelectionSet, vars)...)
+			}
+		}
+	}
+	return errs
+}
+
+func (s *schema) checkCascade(fld *ast.Field, dir *ast.Directive,
+	vars map[string]interface{}) gqlerror.List {
+
+	typName := fld.Definition.Type.Name()
+	info := s.types[typName]
+	if len(fld.SelectionSet) == 0 || info == nil {
+		return gqlerror.List{gqlerror.ErrorPosf(dir.Position,
+			"@cascade on %s: only fields that are objects can have @cascade.", fld.Name)}
+	}
+
+	var errs gqlerror.List
+	names, _ := dir.ArgumentMap(vars)[cascadeFieldsArg].([]interface{})
+	for _, name := range names {
+		name, _ := name.(string)
+		fd := info.fields[name]
+		switch {
+		case fd == nil:
+			errs = append(errs, gqlerror.ErrorPosf(dir.Position,
+				"@cascade on %s: type %s has no field called %s.", fld.Name, typName, name))
+		case fd.predicate == "" && !fd.IsID():
+			errs = append(errs, gqlerror.ErrorPosf(dir.Position,
+				"@cascade on %s: field %s isn't stored in Dgraph, so @cascade can't check it.",
+				fld.Name, name))
+		}
+	}
+	return errs
+}
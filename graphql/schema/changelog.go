@@ -0,0 +1,316 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vektah/gqlparser/ast"
+)
+
+// An APIChange is a difference between the GraphQL APIs generated from two
+// schemas, e.g. a query that was added or an argument that changed type.
+// Breaking changes are those that can stop requests that used to work, like
+// removing a field or adding a required argument.
+type APIChange struct {
+	Breaking bool
+	Message  string
+}
+
+// rootTypes are the types whose fields are the API's operations.  They're
+// listed first in a changelog, and their fields are described as queries,
+// mutations and subscriptions rather than fields.
+var rootTypes = []string{"Query", "Mutation", "Subscription"}
+
+// Changelog returns how the API generated for new differs from the one
+// generated for old, as Diff describes it.  If there's no old schema, there's
+// no changelog.
+func Changelog(old, new Schema) []APIChange {
+	oldSch, ok := old.(*schema)
+	if !ok {
+		return nil
+	}
+	newSch, ok := new.(*schema)
+	if !ok {
+		return nil
+	}
+	return Diff(oldSch.schema, newSch.schema)
+}
+
+// Diff returns how GraphQL schema new differs from old: the added, removed
+// and changed operations first, then the types and their fields and
+// arguments, in name order, and then the directives that clients can use in
+// requests.  Built in types, like the filters for scalars, and built in
+// directives aren't compared, because they're the same for every schema.
+func Diff(old, new *ast.Schema) []APIChange {
+	cl := &changelog{}
+	for _, name := range rootTypes {
+		cl.fields(old.Types[name], new.Types[name], name)
+	}
+
+	for _, name := range comparedTypeNames(old, new) {
+		oldDefn, newDefn := old.Types[name], new.Types[name]
+		switch {
+		case oldDefn == nil:
+			cl.add(false, "Type %s was added.", name)
+		case newDefn == nil:
+			cl.add(true, "Type %s was removed.", name)
+		case oldDefn.Kind != newDefn.Kind:
+			cl.add(true, "Type %s changed from %s to %s.",
+				name, kindName(oldDefn.Kind), kindName(newDefn.Kind))
+		case newDefn.Kind == ast.Enum:
+			cl.enumValues(oldDefn, newDefn)
+		default:
+			cl.fields(oldDefn, newDefn, "")
+		}
+	}
+
+	cl.directives(old.Directives, new.Directives)
+	return cl.changes
+}
+
+type changelog struct {
+	changes []APIChange
+}
+
+func (cl *changelog) add(breaking bool, format string, args ...interface{}) {
+	cl.changes = append(cl.changes,
+		APIChange{Breaking: breaking, Message: fmt.Sprintf(format, args...)})
+}
+
+// fields records the changes to the fields of a type, in the order they're
+// in the new type, followed by the fields that were removed.  Either
+// definition can be nil for a root type that only one schema has.  root is
+// the name of the root type, or "" for any other type.
+func (cl *changelog) fields(oldDefn, newDefn *ast.Definition, root string) {
+	var oldFields, newFields ast.FieldList
+	if oldDefn != nil {
+		oldFields = oldDefn.Fields
+	}
+	if newDefn != nil {
+		newFields = newDefn.Fields
+	}
+	input := newDefn != nil && newDefn.Kind == ast.InputObject
+	describe := func(fld *ast.FieldDefinition) string {
+		return describeField(newDefn, fld, root)
+	}
+
+	for _, newFld := range newFields {
+		oldFld := oldFields.ForName(newFld.Name)
+		if oldFld == nil {
+			if input && required(newFld.Type, newFld.DefaultValue) {
+				cl.add(true, "Required %s was added.", lowerFirst(describe(newFld)))
+			} else {
+				cl.add(false, "%s was added.", describe(newFld))
+			}
+			continue
+		}
+
+		if oldType, newType := oldFld.Type.String(), newFld.Type.String(); oldType != newType {
+			compatible := outputCompatible(oldFld.Type, newFld.Type)
+			if input {
+				compatible = outputCompatible(newFld.Type, oldFld.Type)
+			}
+			cl.add(!compatible, "%s changed type from %s to %s.",
+				describe(newFld), oldType, newType)
+		}
+		cl.arguments(oldFld.Arguments, newFld.Arguments, describe(newFld))
+	}
+
+	for _, oldFld := range oldFields {
+		if newFields.ForName(oldFld.Name) == nil {
+			cl.add(true, "%s was removed.", describeField(oldDefn, oldFld, root))
+		}
+	}
+}
+
+// describeField names fld, a field of defn, in a changelog message, e.g.
+// "Query getAuthor" or "Field Author.name".
+func describeField(defn *ast.Definition, fld *ast.FieldDefinition, root string) string {
+	switch {
+	case root != "":
+		return root + " " + fld.Name
+	case defn.Kind == ast.InputObject:
+		return "Input field " + defn.Name + "." + fld.Name
+	default:
+		return "Field " + defn.Name + "." + fld.Name
+	}
+}
+
+// arguments records the changes to the arguments of field, which is
+// described by what.
+func (cl *changelog) arguments(oldArgs, newArgs ast.ArgumentDefinitionList, what string) {
+	for _, newArg := range newArgs {
+		oldArg := oldArgs.ForName(newArg.Name)
+		switch {
+		case oldArg == nil && required(newArg.Type, newArg.DefaultValue):
+			cl.add(true, "Required argument %s was added to %s.", newArg.Name, what)
+		case oldArg == nil:
+			cl.add(false, "Argument %s was added to %s.", newArg.Name, what)
+		case oldArg.Type.String() != newArg.Type.String():
+			cl.add(!outputCompatible(newArg.Type, oldArg.Type),
+				"Argument %s of %s changed type from %s to %s.",
+				newArg.Name, what, oldArg.Type.String(), newArg.Type.String())
+		}
+	}
+	for _, oldArg := range oldArgs {
+		if newArgs.ForName(oldArg.Name) == nil {
+			cl.add(true, "Argument %s was removed from %s.", oldArg.Name, what)
+		}
+	}
+}
+
+func (cl *changelog) enumValues(oldDefn, newDefn *ast.Definition) {
+	for _, val := range newDefn.EnumValues {
+		if oldDefn.EnumValues.ForName(val.Name) == nil {
+			cl.add(false, "Enum value %s.%s was added.", newDefn.Name, val.Name)
+		}
+	}
+	for _, val := range oldDefn.EnumValues {
+		if newDefn.EnumValues.ForName(val.Name) == nil {
+			cl.add(true, "Enum value %s.%s was removed.", newDefn.Name, val.Name)
+		}
+	}
+}
+
+// directives records the changes to the directives that requests can use.
+// The directives that only apply to schema definitions, like @search, don't
+// affect requests.  The built in directives are the same on both sides, so
+// they never show up.
+func (cl *changelog) directives(oldDirs, newDirs map[string]*ast.DirectiveDefinition) {
+	names := make([]string, 0, len(oldDirs)+len(newDirs))
+	for name := range oldDirs {
+		names = append(names, name)
+	}
+	for name := range newDirs {
+		if _, ok := oldDirs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldDir, newDir := oldDirs[name], newDirs[name]
+		switch {
+		case !executable(oldDir) && !executable(newDir):
+			continue
+		case !executable(oldDir):
+			cl.add(false, "Directive @%s was added.", name)
+		case !executable(newDir):
+			cl.add(true, "Directive @%s was removed.", name)
+		default:
+			for _, loc := range oldDir.Locations {
+				if !hasLocation(newDir, loc) {
+					cl.add(true, "Directive @%s can no longer be used on %s.", name, loc)
+				}
+			}
+			cl.arguments(oldDir.Arguments, newDir.Arguments, "Directive @"+name)
+		}
+	}
+}
+
+// executable returns true if dir is a directive that requests can use, as
+// opposed to one that's only used in schemas.  dir can be nil.
+func executable(dir *ast.DirectiveDefinition) bool {
+	if dir == nil {
+		return false
+	}
+	for _, loc := range dir.Locations {
+		switch loc {
+		case ast.LocationQuery, ast.LocationMutation, ast.LocationSubscription,
+			ast.LocationField, ast.LocationFragmentDefinition, ast.LocationFragmentSpread,
+			ast.LocationInlineFragment:
+			return true
+		}
+	}
+	return false
+}
+
+func hasLocation(dir *ast.DirectiveDefinition, loc ast.DirectiveLocation) bool {
+	for _, l := range dir.Locations {
+		if l == loc {
+			return true
+		}
+	}
+	return false
+}
+
+// comparedTypeNames returns, in order, the names of the types in either
+// schema that are worth comparing: those that aren't built in or root types.
+func comparedTypeNames(old, new *ast.Schema) []string {
+	seen := make(map[string]bool)
+	for _, name := range rootTypes {
+		seen[name] = true
+	}
+
+	var names []string
+	for _, sch := range []*ast.Schema{old, new} {
+		for name, defn := range sch.Types {
+			if seen[name] || defn.BuiltIn {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// outputCompatible returns true if a client that expects values of type old
+// can also handle values of type new: they're the same, except that new
+// might not allow nulls where old did.  For inputs, it's the other way
+// round: a client that sends values of type new can keep going if the type
+// changes to old.
+func outputCompatible(old, new *ast.Type) bool {
+	if new.NonNull && !old.NonNull {
+		nullable := *new
+		nullable.NonNull = false
+		return outputCompatible(old, &nullable)
+	}
+	if old.NonNull != new.NonNull {
+		return false
+	}
+	if old.Elem != nil || new.Elem != nil {
+		return old.Elem != nil && new.Elem != nil && outputCompatible(old.Elem, new.Elem)
+	}
+	return old.NamedType == new.NamedType
+}
+
+// required returns true if a value has to be given for an argument or input
+// field of type typ.
+func required(typ *ast.Type, defaultValue *ast.Value) bool {
+	return typ.NonNull && defaultValue == nil
+}
+
+func kindName(kind ast.DefinitionKind) string {
+	switch kind {
+	case ast.Object:
+		return "an object"
+	case ast.Interface:
+		return "an interface"
+	case ast.InputObject:
+		return "an input"
+	case ast.Enum:
+		return "an enum"
+	case ast.Union:
+		return "a union"
+	default:
+		return "a scalar"
+	}
+}
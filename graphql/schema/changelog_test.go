@@ -0,0 +1,109 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/validator"
+)
+
+func TestChangelog(t *testing.T) {
+	old, err := NewHandler(`
+		type Author { id: ID! name: String! @search(by: [hash]) age: Int posts: [Post] }
+		type Post { id: ID! title: String }
+		type Comment { id: ID! text: String }`)
+	require.NoError(t, err)
+	new, err := NewHandler(`
+		type Author { id: ID! name: String @search(by: [hash]) bio: String! posts: [Post] }
+		type Post { id: ID! title: String! }
+		type Tag { id: ID! name: String }`)
+	require.NoError(t, err)
+
+	changes := Changelog(old.Schema(), new.Schema())
+	for _, change := range []APIChange{
+		{Breaking: false, Message: "Query queryTag was added."},
+		{Breaking: true, Message: "Query getComment was removed."},
+		{Breaking: true, Message: "Mutation deleteComment was removed."},
+		{Breaking: true, Message: "Field Author.name changed type from String! to String."},
+		{Breaking: false, Message: "Field Post.title changed type from String to String!."},
+		{Breaking: false, Message: "Field Author.bio was added."},
+		{Breaking: true, Message: "Field Author.age was removed."},
+		{Breaking: true, Message: "Required input field AddAuthorInput.bio was added."},
+		{Breaking: false,
+			Message: "Input field AddAuthorInput.name changed type from String! to String."},
+		{Breaking: true,
+			Message: "Input field AddPostInput.title changed type from String to String!."},
+		{Breaking: false, Message: "Input field AuthorPatch.bio was added."},
+		{Breaking: true, Message: "Enum value AuthorOrderable.age was removed."},
+		{Breaking: false, Message: "Type Tag was added."},
+		{Breaking: true, Message: "Type Comment was removed."},
+	} {
+		require.Contains(t, changes, change)
+	}
+	require.Equal(t, "Query getTag was added.", changes[0].Message, "operations come first")
+
+	require.Empty(t, Changelog(new.Schema(), new.Schema()))
+	require.Nil(t, Changelog(nil, new.Schema()))
+}
+
+func TestChangelogArguments(t *testing.T) {
+	load := func(sdl string) Schema {
+		sch, gqlErr := validator.LoadSchema(validator.Prelude, &ast.Source{Input: sdl})
+		require.Nil(t, gqlErr)
+		return AsSchema(sch)
+	}
+	old := load(`type Query { f(a: Int, b: String): String  g(a: Int!): String }`)
+	new := load(`type Query {
+		f(a: Int!, c: String, d: Int!): String
+		g(a: Int, e: Int! = 1): String }`)
+
+	require.Equal(t, []APIChange{
+		{Breaking: true, Message: "Argument a of Query f changed type from Int to Int!."},
+		{Breaking: false, Message: "Argument c was added to Query f."},
+		{Breaking: true, Message: "Required argument d was added to Query f."},
+		{Breaking: true, Message: "Argument b was removed from Query f."},
+		{Breaking: false, Message: "Argument a of Query g changed type from Int! to Int."},
+		{Breaking: false, Message: "Argument e was added to Query g."},
+	}, Changelog(old, new))
+}
+
+func TestDiffDirectives(t *testing.T) {
+	load := func(sdl string) *ast.Schema {
+		sch, gqlErr := validator.LoadSchema(validator.Prelude, &ast.Source{Input: sdl})
+		require.Nil(t, gqlErr)
+		return sch
+	}
+	old := load(`type Query { f: String }
+		directive @cached(ttl: Int) on FIELD | QUERY
+		directive @trace on QUERY
+		directive @internal on FIELD_DEFINITION`)
+	new := load(`type Query { f: String }
+		directive @cached(ttl: Int!) on FIELD
+		directive @live on QUERY
+		directive @secret on FIELD_DEFINITION`)
+
+	require.Equal(t, []APIChange{
+		{Breaking: true, Message: "Directive @cached can no longer be used on QUERY."},
+		{Breaking: true,
+			Message: "Argument ttl of Directive @cached changed type from Int to Int!."},
+		{Breaking: false, Message: "Directive @live was added."},
+		{Breaking: true, Message: "Directive @trace was removed."},
+	}, Diff(old, new))
+}
@@ -0,0 +1,361 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A @computed field is read-only, and its value is worked out by Dgraph from
+// other fields of the same object whenever it's queried, e.g.:
+//
+//	type Author {
+//		id: ID!
+//		name: String!
+//		posts: [Post]
+//		postCount: Int @computed(expr: "count(posts)")
+//		likes: Int
+//		dislikes: Int
+//		score: Float @computed(expr: "(likes - dislikes) / max(count(posts), 1)")
+//	}
+//
+// An expression is numbers, Int and Float fields, and count(f) of list
+// fields, combined with + - * / %, parentheses and the functions min, max,
+// pow, logbase, sqrt, ln, exp, floor and ceil.  Nothing's stored for the
+// field, and it's not in the inputs, filters or orders of the type.
+//
+// Counts aren't filtered by @auth rules, so a type with @auth can't be
+// counted.
+
+// binaryFunctions and unaryFunctions are the functions that @computed
+// expressions can use, other than count.
+var (
+	binaryFunctions = map[string]bool{"min": true, "max": true, "pow": true, "logbase": true}
+	unaryFunctions  = map[string]bool{
+		"sqrt": true, "ln": true, "exp": true, "floor": true, "ceil": true,
+	}
+)
+
+// A ComputedExpr is a parsed @computed expression.  It's either an operator
+// (+ - * / %, or - with one argument for negation) or function (like max)
+// applied to Args, or a Number, or the value, or the Count, of a Field, which
+// is stored in Predicate.
+type ComputedExpr struct {
+	Op        string
+	Args      []*ComputedExpr
+	Number    float64
+	Field     string
+	Predicate string
+	Count     bool
+}
+
+// IsNumber returns true if e is just a number.
+func (e *ComputedExpr) IsNumber() bool {
+	return e.Op == "" && e.Field == ""
+}
+
+// fields returns the count(f) and value leaves of e.
+func (e *ComputedExpr) fields() []*ComputedExpr {
+	if e.Field != "" {
+		return []*ComputedExpr{e}
+	}
+	var flds []*ComputedExpr
+	for _, arg := range e.Args {
+		flds = append(flds, arg.fields()...)
+	}
+	return flds
+}
+
+func isComputed(fld *ast.FieldDefinition) bool {
+	return fld.Directives.ForName(computedDirective) != nil
+}
+
+// computedExpr returns the expression of fld, a field of defn, if it's a
+// @computed field, with the predicate of each field it uses filled in.
+func computedExpr(sch *ast.Schema, defn *ast.Definition,
+	fld *ast.FieldDefinition) *ComputedExpr {
+
+	dir := fld.Directives.ForName(computedDirective)
+	if dir == nil {
+		return nil
+	}
+	// The expression was checked when the schema was validated.
+	expr, err := parseComputed(dir.Arguments.ForName(computedExprArg).Value.Raw)
+	if err != nil {
+		return nil
+	}
+	for _, leaf := range expr.fields() {
+		leaf.Predicate = dgraphPredicate(sch, defn, leaf.Field)
+	}
+	return expr
+}
+
+// computedRule checks that a @computed field is an Int or Float of a type
+// that's stored in Dgraph, and that its expression only uses fields that
+// can be computed with.
+func computedRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(computedDirective)
+	if dir == nil {
+		return nil
+	}
+
+	errf := func(format string, args ...interface{}) *gqlerror.Error {
+		return gqlerror.ErrorPosf(dir.Position, "Type %s; Field %s: %s", defn.Name, field.Name,
+			fmt.Sprintf(format, args...))
+	}
+
+	switch {
+	case defn.Kind != ast.Object || isRemote(defn) || reservedTypeNames[defn.Name]:
+		return errf("@computed is only allowed on fields of object types that are stored " +
+			"in Dgraph.")
+	case field.Type.Elem != nil || (field.Type.Name() != "Int" && field.Type.Name() != "Float"):
+		return errf("@computed fields must be of type Int or Float.")
+	}
+	for _, other := range []string{customDirective, lambdaDirective, searchDirective,
+		inverseDirective} {
+		if field.Directives.ForName(other) != nil {
+			return errf("a @computed field isn't stored in Dgraph, so it can't have @%s.", other)
+		}
+	}
+	for _, iface := range defn.Interfaces {
+		if idefn := doc.Definitions.ForName(iface); idefn != nil &&
+			idefn.Fields.ForName(field.Name) != nil {
+			return errf("@computed isn't allowed on fields from interface %s.", iface)
+		}
+	}
+
+	arg := dir.Arguments.ForName(computedExprArg)
+	if arg == nil || arg.Value == nil {
+		return errf("@computed needs an expression.")
+	}
+	expr, err := parseComputed(arg.Value.Raw)
+	if err != nil {
+		return errf("@computed expression %q %s.", arg.Value.Raw, err)
+	}
+
+	leaves := expr.fields()
+	if len(leaves) == 0 {
+		return errf("@computed expression %q doesn't use any fields.", arg.Value.Raw)
+	}
+	for _, leaf := range leaves {
+		fld := defn.Fields.ForName(leaf.Field)
+		if fld == nil {
+			return errf("@computed uses %s, but type %s has no field called %s.",
+				leaf.Field, defn.Name, leaf.Field)
+		}
+		if private, _ := isPrivate(fld); private || isCustom(fld) || isIDField(defn, fld) {
+			return errf("@computed uses %s, but only fields that are stored in Dgraph "+
+				"can be used.", leaf.Field)
+		}
+
+		if leaf.Count {
+			if fld.Type.Elem == nil {
+				return errf("@computed uses count(%s), but %s isn't a list.",
+					leaf.Field, leaf.Field)
+			}
+			if typ := doc.Definitions.ForName(fld.Type.Name()); typ != nil &&
+				typ.Directives.ForName(authDirective) != nil {
+				return errf("@computed uses count(%s), but type %s has @auth rules, which "+
+					"counts don't apply.", leaf.Field, typ.Name)
+			}
+			continue
+		}
+		if fld.Type.Elem != nil || (fld.Type.Name() != "Int" && fld.Type.Name() != "Float") {
+			return errf("@computed uses %s, but only Int and Float fields, and count() of "+
+				"lists, can be computed with.", leaf.Field)
+		}
+	}
+	return nil
+}
+
+// parseComputed parses a @computed expression.
+func parseComputed(expr string) (*ComputedExpr, error) {
+	p := &computedParser{expr: expr}
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.expr) {
+		return nil, p.errorf("has an unexpected %q", p.expr[p.pos])
+	}
+	return e, nil
+}
+
+// A computedParser is a recursive descent parser of @computed expressions:
+//
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | "(" sum ")" | number | name | name "(" [ sum { "," sum } ] ")"
+type computedParser struct {
+	expr string
+	pos  int
+}
+
+func (p *computedParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.pos+1)
+}
+
+func (p *computedParser) skipSpace() {
+	for p.pos < len(p.expr) && unicode.IsSpace(rune(p.expr[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept skips past the next token, and returns true, if it's one of ops.
+func (p *computedParser) accept(ops string) (string, bool) {
+	p.skipSpace()
+	if p.pos < len(p.expr) && strings.IndexByte(ops, p.expr[p.pos]) >= 0 {
+		p.pos++
+		return p.expr[p.pos-1 : p.pos], true
+	}
+	return "", false
+}
+
+func (p *computedParser) sum() (*ComputedExpr, error) {
+	return p.binary("+-", p.product)
+}
+
+func (p *computedParser) product() (*ComputedExpr, error) {
+	return p.binary("*/%", p.unary)
+}
+
+// binary parses operands, separated by the operators in ops, as left
+// associative operations.
+func (p *computedParser) binary(ops string,
+	operand func() (*ComputedExpr, error)) (*ComputedExpr, error) {
+
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &ComputedExpr{Op: op, Args: []*ComputedExpr{left, right}}
+	}
+}
+
+func (p *computedParser) unary() (*ComputedExpr, error) {
+	if _, ok := p.accept("-"); ok {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &ComputedExpr{Op: "-", Args: []*ComputedExpr{e}}, nil
+	}
+	if _, ok := p.accept("("); ok {
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, p.errorf("is missing a )")
+		}
+		return e, nil
+	}
+
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.expr) && (p.expr[p.pos] == '_' || p.expr[p.pos] == '.' ||
+		unicode.IsLetter(rune(p.expr[p.pos])) || unicode.IsDigit(rune(p.expr[p.pos]))) {
+		p.pos++
+	}
+	tok := p.expr[start:p.pos]
+	switch {
+	case tok == "" && p.pos == len(p.expr):
+		return nil, p.errorf("ends unexpectedly")
+	case tok == "":
+		return nil, p.errorf("has an unexpected %q", p.expr[p.pos])
+	case unicode.IsDigit(rune(tok[0])) || tok[0] == '.':
+		n, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, errors.Errorf("has %s, which isn't a number, at position %d", tok,
+				start+1)
+		}
+		return &ComputedExpr{Number: n}, nil
+	case strings.Contains(tok, "."):
+		return nil, errors.Errorf("has %s, which isn't a field name, at position %d", tok,
+			start+1)
+	}
+
+	if _, ok := p.accept("("); !ok {
+		return &ComputedExpr{Field: tok}, nil
+	}
+	return p.call(tok, start)
+}
+
+// call parses the arguments of function fn, which started at start.
+func (p *computedParser) call(fn string, start int) (*ComputedExpr, error) {
+	if fn == "count" {
+		p.skipSpace()
+		nameStart := p.pos
+		for p.pos < len(p.expr) && (p.expr[p.pos] == '_' ||
+			unicode.IsLetter(rune(p.expr[p.pos])) || unicode.IsDigit(rune(p.expr[p.pos]))) {
+			p.pos++
+		}
+		name := p.expr[nameStart:p.pos]
+		if _, ok := p.accept(")"); !ok || name == "" {
+			return nil, errors.Errorf("can only count a field, like count(posts), at "+
+				"position %d", start+1)
+		}
+		return &ComputedExpr{Field: name, Count: true}, nil
+	}
+
+	args := 1
+	switch {
+	case binaryFunctions[fn]:
+		args = 2
+	case !unaryFunctions[fn]:
+		return nil, errors.Errorf("uses %s, which isn't a function, at position %d", fn,
+			start+1)
+	}
+
+	e := &ComputedExpr{Op: fn}
+	for i := 0; i < args; i++ {
+		if i > 0 {
+			if _, ok := p.accept(","); !ok {
+				return nil, errors.Errorf("needs %d arguments for %s, at position %d", args,
+					fn, start+1)
+			}
+		}
+		arg, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		e.Args = append(e.Args, arg)
+	}
+	if _, ok := p.accept(")"); !ok {
+		return nil, errors.Errorf("needs %d arguments for %s, at position %d", args, fn,
+			start+1)
+	}
+	return e, nil
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import "github.com/vektah/gqlparser/ast"
+
+// Updates and deletes can have a condition, as well as a filter, e.g.
+//
+//	updatePost(input: {
+//		filter: { id: ["0x1"] },
+//		condition: { status: { eq: "DRAFT" } },
+//		set: { status: "PUBLISHED" }
+//	})
+//
+// The filter finds the objects to change, and the condition is what they must
+// all still be like for the mutation to go ahead.  It's Dgraph's conditional
+// upsert (@if): the objects are checked in the mutation's transaction, so if
+// any of them don't satisfy the condition, or are changed by another
+// transaction before the mutation commits, nothing is changed.  A mutation
+// that fails its condition fails with a CONDITION_FAILED error.
+
+// ConditionArgName is the field of the input of updateT, and the argument of
+// deleteT, that's the condition the objects must satisfy.
+const ConditionArgName = "condition"
+
+// conditionType is the type of the condition of mutations of defn: its
+// filter type.
+func conditionType(defn *ast.Definition) *ast.Type {
+	return ast.NamedType(defn.Name+"Filter", nil)
+}
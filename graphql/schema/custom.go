@@ -0,0 +1,536 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+	"github.com/vektah/gqlparser/parser"
+)
+
+// A CustomHTTP is the HTTP call, from a @custom directive, that resolves a
+// field.  For example,
+//
+//	type User {
+//		username: String!
+//		avatar: String @custom(http: {
+//			url: "https://avatars.example.com/$username",
+//			method: GET
+//		})
+//	}
+//
+// resolves the avatar of each user by calling the URL with the user's
+// username.  URL, Body and Headers are templates: $name in them stands for
+// the argument called name of a @custom query or mutation, or for the field
+// called name of the parent object of any other @custom field.  In the body,
+// which must be JSON, variables stand for JSON values, so they aren't quoted.
+// Each header is written "Name: value".  A call to a GraphQL API has a
+// GraphQL query instead of a body.
+//
+// A field of a type with mode: BATCH is resolved for all its parents with one
+// call, whose body is a JSON list with the filled-in body for each parent -
+// or, if there's no body, an object of the variables - and whose response
+// must be a list of the results, in the same order:
+//
+//	type User {
+//		username: String!
+//		avatar: String @custom(http: {
+//			url: "https://avatars.example.com/lookup",
+//			method: POST,
+//			body: "{\"user\": $username}",
+//			mode: BATCH
+//		})
+//	}
+//
+// With signer: "name", the call is signed by the server's signer called name,
+// so the endpoint can tell the call came from Dgraph.  policy overrides how
+// the server makes the call, e.g. policy: {timeout: "30s", maxRetries: 0}.
+type CustomHTTP struct {
+	URL     string
+	Method  string
+	Body    string
+	Headers []string
+	GraphQL *CustomGraphQL
+
+	// Batch is true if the call is made once for all the field's parents.
+	Batch bool
+
+	// Signer names the signer, configured on the server, that the call is
+	// signed with; "" if it isn't signed.  Names aren't case sensitive, so
+	// it's in lower case.
+	Signer string
+
+	// Policy overrides the server's policy for the call; nil if it doesn't.
+	Policy *CustomPolicy
+
+	// Variables are the names of the variables in the templates, in the order
+	// they first appear.
+	Variables []string
+}
+
+// A CustomPolicy overrides, for one @custom call, the server's policy for
+// calls: how long each attempt can take, how many times a failed call is
+// retried, and after how many failures in a row, and for how long, the
+// call's circuit breaker stops it being made.  Only the settings given are
+// overridden; the rest are zero, or nil.
+type CustomPolicy struct {
+	Timeout          time.Duration
+	MaxRetries       *int
+	BreakerThreshold *int
+	BreakerCooldown  time.Duration
+}
+
+// A CustomGraphQL is the query, from the graphql argument of a @custom
+// directive, that a call to a GraphQL API makes.  For example,
+//
+//	type Post {
+//		authorID: String!
+//		author: User @custom(http: {
+//			url: "https://users.example.com/graphql",
+//			method: POST,
+//			graphql: "user(id: $authorID)"
+//		})
+//	}
+//
+//	type User @remote {
+//		name: String
+//	}
+//
+// resolves the author of each post with the remote user query, asking it for
+// the fields of User that the request selects.  Calls for many posts are made
+// in one request to the API.
+type CustomGraphQL struct {
+	// Name is the name of the remote query, or mutation for the fields of
+	// the Mutation type.
+	Name string
+
+	args ast.ArgumentList
+}
+
+// Args returns the arguments of the remote query, with vars giving the values
+// of its variables.
+func (cg *CustomGraphQL) Args(vars map[string]interface{}) map[string]interface{} {
+	args := make(map[string]interface{}, len(cg.args))
+	for _, arg := range cg.args {
+		args[arg.Name] = graphqlValue(arg.Value, vars)
+	}
+	return args
+}
+
+// graphqlValue is the value of v, with vars giving the values of variables.
+// Enum values are EnumValues, so they stay enums when they're sent on.
+func graphqlValue(v *ast.Value, vars map[string]interface{}) interface{} {
+	switch v.Kind {
+	case ast.Variable:
+		return vars[v.Raw]
+	case ast.EnumValue:
+		return EnumValue(v.Raw)
+	case ast.ListValue:
+		list := make([]interface{}, len(v.Children))
+		for i, child := range v.Children {
+			list[i] = graphqlValue(child.Value, vars)
+		}
+		return list
+	case ast.ObjectValue:
+		obj := make(map[string]interface{}, len(v.Children))
+		for _, child := range v.Children {
+			obj[child.Name] = graphqlValue(child.Value, vars)
+		}
+		return obj
+	default:
+		val, _ := v.Value(nil)
+		return val
+	}
+}
+
+// parseCustomGraphQL parses the graphql argument of @custom, which is a
+// single query field without a selection set, like user(id: $id).
+func parseCustomGraphQL(query string) (*CustomGraphQL, error) {
+	bad := errors.Errorf("graphql should be a single query, like \"user(id: $id)\", "+
+		"but it's %q", query)
+
+	doc, gqlErr := parser.ParseQuery(&ast.Source{Input: "{ " + query + " }"})
+	if gqlErr != nil || len(doc.Operations) != 1 ||
+		len(doc.Operations[0].SelectionSet) != 1 {
+		return nil, bad
+	}
+	fld, ok := doc.Operations[0].SelectionSet[0].(*ast.Field)
+	if !ok || fld.Alias != fld.Name || len(fld.SelectionSet) > 0 || len(fld.Directives) > 0 {
+		return nil, bad
+	}
+	return &CustomGraphQL{Name: fld.Name, args: fld.Arguments}, nil
+}
+
+// The modes of a @custom call: made for each parent of a field, or once for
+// all of them.
+const (
+	customSingleMode = "SINGLE"
+	customBatchMode  = "BATCH"
+)
+
+// customVariable matches a $name variable in a @custom template.
+var customVariable = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// customMethods are the HTTP methods that @custom calls can use, and whether
+// the call can have a body.
+var customMethods = map[string]bool{
+	"GET":    false,
+	"POST":   true,
+	"PUT":    true,
+	"PATCH":  true,
+	"DELETE": false,
+}
+
+// FillTemplate fills in a @custom template: each $name variable is replaced
+// with variable(name), and each piece of text between the variables with
+// text(piece).  Keeping them apart means that text can, for example, expand
+// secret references without ever seeing a value that came from a variable.
+func FillTemplate(tmpl string, text func(string) (string, error),
+	variable func(name string) string) (string, error) {
+
+	var buf strings.Builder
+	last := 0
+	for _, loc := range customVariable.FindAllStringSubmatchIndex(tmpl, -1) {
+		piece, err := text(tmpl[last:loc[0]])
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(piece)
+		buf.WriteString(variable(tmpl[loc[2]:loc[3]]))
+		last = loc[1]
+	}
+	piece, err := text(tmpl[last:])
+	if err != nil {
+		return "", err
+	}
+	buf.WriteString(piece)
+	return buf.String(), nil
+}
+
+// customHTTP parses the @custom directive of fld.  It returns nil if fld
+// isn't @custom.
+func customHTTP(fld *ast.FieldDefinition) (*CustomHTTP, error) {
+	dir := fld.Directives.ForName(customDirective)
+	if dir == nil {
+		return nil, nil
+	}
+	arg := dir.Arguments.ForName(customHTTPArg)
+	if arg == nil || arg.Value == nil {
+		return nil, errors.New("@custom directive doesn't have the http argument")
+	}
+	val, err := arg.Value.Value(nil)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("the http argument of @custom must be an object, "+
+			"but it's %s", arg.Value)
+	}
+
+	ch := &CustomHTTP{}
+	ch.URL, _ = obj["url"].(string)
+	ch.Method, _ = obj["method"].(string)
+	ch.Body, _ = obj["body"].(string)
+	signer, _ := obj["signer"].(string)
+	ch.Signer = strings.ToLower(signer)
+	if policy, ok := obj["policy"].(map[string]interface{}); ok {
+		if ch.Policy, err = customPolicy(policy); err != nil {
+			return nil, err
+		}
+	}
+	switch mode, _ := obj["mode"].(string); mode {
+	case "", customSingleMode:
+	case customBatchMode:
+		ch.Batch = true
+	default:
+		return nil, errors.Errorf("mode %q isn't supported, use %s or %s",
+			mode, customSingleMode, customBatchMode)
+	}
+	graphql, _ := obj["graphql"].(string)
+	if graphql != "" {
+		if ch.GraphQL, err = parseCustomGraphQL(graphql); err != nil {
+			return nil, err
+		}
+	}
+	switch hdrs := obj["headers"].(type) {
+	case string:
+		ch.Headers = []string{hdrs}
+	case []interface{}:
+		for _, hdr := range hdrs {
+			if h, ok := hdr.(string); ok {
+				ch.Headers = append(ch.Headers, h)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, tmpl := range append([]string{ch.URL, ch.Body, graphql}, ch.Headers...) {
+		for _, match := range customVariable.FindAllStringSubmatch(tmpl, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				ch.Variables = append(ch.Variables, match[1])
+			}
+		}
+	}
+	return ch, nil
+}
+
+// customPolicy parses the policy argument of a @custom directive's http.
+func customPolicy(obj map[string]interface{}) (*CustomPolicy, error) {
+	duration := func(name string) (time.Duration, error) {
+		s, _ := obj[name].(string)
+		if s == "" {
+			return 0, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return 0, errors.Errorf("the policy's %s %q isn't a positive duration, "+
+				"like \"30s\"", name, s)
+		}
+		return d, nil
+	}
+	count := func(name string) (*int, error) {
+		n, ok := obj[name].(int64)
+		if !ok {
+			return nil, nil
+		}
+		if n < 0 {
+			return nil, errors.Errorf("the policy's %s can't be negative", name)
+		}
+		i := int(n)
+		return &i, nil
+	}
+
+	var p CustomPolicy
+	var err error
+	if p.Timeout, err = duration("timeout"); err != nil {
+		return nil, err
+	}
+	if p.BreakerCooldown, err = duration("breakerCooldown"); err != nil {
+		return nil, err
+	}
+	if p.MaxRetries, err = count("maxRetries"); err != nil {
+		return nil, err
+	}
+	if p.BreakerThreshold, err = count("breakerThreshold"); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// checkCustomHTTP checks that the call in ch can be made: that it's to an
+// HTTP(S) URL, with a supported method, and a JSON body and well formed
+// headers.
+func checkCustomHTTP(ch *CustomHTTP) error {
+	if ch.URL == "" {
+		return errors.New("the url is missing")
+	}
+	u, err := url.Parse(ch.URL)
+	if err != nil {
+		return errors.Errorf("%s isn't a valid URL", ch.URL)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("%s isn't an http or https URL", ch.URL)
+	}
+
+	canHaveBody, ok := customMethods[ch.Method]
+	if !ok {
+		return errors.Errorf("method %q isn't supported, use GET, POST, PUT, PATCH or DELETE",
+			ch.Method)
+	}
+	if ch.Body != "" {
+		if !canHaveBody {
+			return errors.Errorf("a %s call can't have a body", ch.Method)
+		}
+		noValues := customVariable.ReplaceAllString(ch.Body, "null")
+		if !json.Valid([]byte(noValues)) {
+			return errors.New("the body isn't JSON; it should be JSON with $variables " +
+				"in place of values, like {\"id\": $id}")
+		}
+	}
+
+	for _, hdr := range ch.Headers {
+		colon := strings.Index(hdr, ":")
+		if colon <= 0 || strings.TrimSpace(hdr[:colon]) == "" {
+			return errors.Errorf("header %q should be written \"Name: value\"", hdr)
+		}
+	}
+
+	if ch.GraphQL != nil {
+		if ch.Method != "POST" {
+			return errors.New("a graphql call must use the POST method")
+		}
+		if ch.Body != "" {
+			return errors.New("a graphql call can't have a body; the query is its body")
+		}
+		if ch.Batch {
+			return errors.New("a graphql call is always batched, so it can't have a mode")
+		}
+		// The calls of all the parents of a field are made in one request,
+		// so only the query can differ between them.
+		for _, tmpl := range append([]string{ch.URL}, ch.Headers...) {
+			if customVariable.MatchString(tmpl) {
+				return errors.New("a graphql call can only use $variables in its query")
+			}
+		}
+	}
+
+	if ch.Batch {
+		if !canHaveBody {
+			return errors.Errorf("a BATCH call sends a list in its body, so it can't use %s",
+				ch.Method)
+		}
+		for _, tmpl := range append([]string{ch.URL}, ch.Headers...) {
+			if customVariable.MatchString(tmpl) {
+				return errors.New("a BATCH call can only use $variables in its body")
+			}
+		}
+	}
+	return nil
+}
+
+// customRule checks the @custom directive of field.  A @custom field isn't
+// stored in Dgraph, so it can't be an ID, searched, an inverse or @private,
+// and it can't come from an interface, whose fields are stored.  Its
+// variables must be arguments of the field, for the fields of the Query and
+// Mutation types, and otherwise fields of defn that are stored in Dgraph and
+// aren't objects.
+func customRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(customDirective)
+	if dir == nil {
+		return nil
+	}
+
+	ch, err := customHTTP(field)
+	if err == nil {
+		err = checkCustomHTTP(ch)
+	}
+	if err != nil {
+		return gqlerror.ErrorPosf(dir.Position, "Type %s; Field %s: @custom is invalid: %s.",
+			defn.Name, field.Name, err)
+	}
+
+	if err := unstoredFieldRule(doc, defn, field, dir); err != nil {
+		return err
+	}
+
+	if ch.Batch && reservedTypeNames[defn.Name] {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @custom is invalid: only the fields of types can be BATCH "+
+				"calls.", defn.Name, field.Name)
+	}
+
+	for _, name := range ch.Variables {
+		if reservedTypeNames[defn.Name] {
+			if field.Arguments.ForName(name) == nil {
+				return gqlerror.ErrorPosf(dir.Position,
+					"Type %s; Field %s: @custom uses $%s, but there's no argument called %s.",
+					defn.Name, field.Name, name, name)
+			}
+			continue
+		}
+
+		fld := defn.Fields.ForName(name)
+		if fld == nil {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: @custom uses $%s, but type %s has no field called %s.",
+				defn.Name, field.Name, name, defn.Name, name)
+		}
+		if isCustom(fld) {
+			other := customDirective
+			if isLambda(fld) {
+				other = lambdaDirective
+			} else if isComputed(fld) {
+				other = computedDirective
+			}
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: @custom uses $%s, but field %s is @%s.  "+
+					"Only fields that are stored in Dgraph can be used.",
+				defn.Name, field.Name, name, name, other)
+		}
+		if typ := doc.Definitions.ForName(fld.Type.Name()); typ != nil &&
+			(typ.Kind == ast.Object || typ.Kind == ast.Interface) {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: @custom uses $%s, but field %s is an object.  Only "+
+					"fields with scalar or enum types can be used.",
+				defn.Name, field.Name, name, name)
+		}
+	}
+
+	return nil
+}
+
+// lambdaRule checks the @lambda directive of field.  Like a @custom field, a
+// @lambda field isn't stored in Dgraph.  The lambda server is given the
+// field's arguments and, for fields that aren't queries or mutations, the
+// stored fields of the parent object, so there's nothing else to check.
+func lambdaRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(lambdaDirective)
+	if dir == nil {
+		return nil
+	}
+	if field.Directives.ForName(customDirective) != nil {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: can't be both @custom and @lambda.", defn.Name, field.Name)
+	}
+	return unstoredFieldRule(doc, defn, field, dir)
+}
+
+// unstoredFieldRule checks that field, which dir says isn't stored in
+// Dgraph, doesn't need to be: that it isn't an ID, searched, an inverse or
+// @private, and doesn't come from an interface, whose fields are stored.
+func unstoredFieldRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition, dir *ast.Directive) *gqlerror.Error {
+
+	if field.Type.Name() == IDType {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: ID fields can't be @%s.", defn.Name, field.Name, dir.Name)
+	}
+	for _, other := range []string{searchDirective, inverseDirective, privateDirective} {
+		if field.Directives.ForName(other) != nil {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: @%s fields aren't stored in Dgraph, so they can't "+
+					"also be @%s.", defn.Name, field.Name, dir.Name, other)
+		}
+	}
+	if defn.Kind == ast.Interface {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: interfaces can't have @%s fields.",
+			defn.Name, field.Name, dir.Name)
+	}
+	for _, iface := range defn.Interfaces {
+		if idefn := doc.Definitions.ForName(iface); idefn != nil &&
+			idefn.Fields.ForName(field.Name) != nil {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: can't be @%s, because it's a field of interface %s.",
+				defn.Name, field.Name, dir.Name, iface)
+		}
+	}
+	return nil
+}
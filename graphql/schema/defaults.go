@@ -0,0 +1,176 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A field's @default gives it a value in the mutations that don't set it, so
+// the server, not the client, fills in things like timestamps and statuses:
+//
+//	type Ticket {
+//		id: ID!
+//		status: Status! @default(add: {value: "OPEN"})
+//		created: DateTime @default(add: {value: "$now"})
+//		modified: DateTime @default(add: {value: "$now"}, update: {value: "$now"})
+//	}
+//
+// The add value is used when a new object is added without the field, and
+// the update value is set on every object an update changes, unless the
+// update sets the field itself.  $now is the time of the mutation, for
+// DateTime fields.  A non-nullable field with an add value needn't be given
+// in the add input.
+
+// defaultNow is the @default value that's the time of the mutation.
+const defaultNow = "$now"
+
+// defaultOperations are the arguments of @default, and the mutations each
+// applies to.
+var defaultOperations = map[string]MutationType{
+	defaultAddArg:    AddMutation,
+	defaultUpdateArg: UpdateMutation,
+}
+
+// defaultValues returns the values that fld's @default sets, by mutation.
+func defaultValues(fld *ast.FieldDefinition) map[MutationType]string {
+	dir := fld.Directives.ForName(defaultDirective)
+	if dir == nil {
+		return nil
+	}
+	vals := make(map[MutationType]string)
+	for arg, mut := range defaultOperations {
+		if val := defaultArgValue(dir, arg); val != nil {
+			vals[mut] = val.Raw
+		}
+	}
+	return vals
+}
+
+// defaultArgValue returns the value in dir's arg, e.g. "OPEN" in
+// add: {value: "OPEN"}, or nil if there isn't one.
+func defaultArgValue(dir *ast.Directive, arg string) *ast.Value {
+	a := dir.Arguments.ForName(arg)
+	if a == nil || a.Value == nil {
+		return nil
+	}
+	for _, child := range a.Value.Children {
+		if child.Name == defaultValueArg {
+			return child.Value
+		}
+	}
+	return nil
+}
+
+// hasAddDefault returns true if fld has a value when it's not added.
+func hasAddDefault(fld *ast.FieldDefinition) bool {
+	_, ok := defaultValues(fld)[AddMutation]
+	return ok
+}
+
+// defaultRule checks that a field's @default is on a scalar or enum field
+// that's stored in Dgraph, and that its values fit the field's type.
+func defaultRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(defaultDirective)
+	if dir == nil {
+		return nil
+	}
+
+	typ := doc.Definitions.ForName(field.Type.Name())
+	isEnum := typ != nil && typ.Kind == ast.Enum
+	_, isScalar := scalarToDgraph[field.Type.Name()]
+	switch {
+	case defn.Kind != ast.Object || isRemote(defn) || isCustom(field) || isLambda(field):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @default is only allowed on fields that are stored in Dgraph.",
+			defn.Name, field.Name)
+	case field.Type.Elem != nil || (!isEnum && !isScalar) || isIDField(defn, field) ||
+		field.Type.Name() == VectorType:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @default is only allowed on fields of type Int, Float, "+
+				"Boolean, String, DateTime or an enum.", defn.Name, field.Name)
+	case len(dir.Arguments) == 0:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @default needs an add or an update value.",
+			defn.Name, field.Name)
+	}
+
+	for _, arg := range dir.Arguments {
+		val := defaultArgValue(dir, arg.Name)
+		if val == nil {
+			return gqlerror.ErrorPosf(arg.Position,
+				"Type %s; Field %s: @default needs %s to have a value, like %s: {value: \"x\"}.",
+				defn.Name, field.Name, arg.Name, arg.Name)
+		}
+		if val.Raw == defaultNow && field.Type.Name() != "DateTime" {
+			return gqlerror.ErrorPosf(val.Position,
+				"Type %s; Field %s: @default value $now is only allowed on DateTime fields.",
+				defn.Name, field.Name)
+		}
+		ok := true
+		if isEnum {
+			ok = typ.EnumValues.ForName(val.Raw) != nil
+		} else {
+			_, err := defaultValue(field.Type.Name(), val.Raw, time.Time{})
+			ok = err == nil
+		}
+		if !ok {
+			return gqlerror.ErrorPosf(val.Position,
+				"Type %s; Field %s: @default value %q isn't a valid %s.",
+				defn.Name, field.Name, val.Raw, field.Type.Name())
+		}
+	}
+	return nil
+}
+
+// defaultValue converts val, a @default value of a field of type typName, to
+// the value that's stored.  $now is now.
+func defaultValue(typName, val string, now time.Time) (interface{}, error) {
+	switch typName {
+	case "Int":
+		return strconv.ParseInt(val, 10, 64)
+	case "Float":
+		return strconv.ParseFloat(val, 64)
+	case "Boolean":
+		return strconv.ParseBool(val)
+	case "DateTime":
+		if val == defaultNow {
+			return now.UTC().Format(time.RFC3339), nil
+		}
+		if _, err := time.Parse(time.RFC3339, val); err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+// DefaultValue returns the value that fd's @default sets in mutations of
+// type mut, if it has one, with $now being now.
+func (fd *fieldDefinition) DefaultValue(mut MutationType, now time.Time) (interface{}, bool) {
+	val, ok := fd.defaults[mut]
+	if !ok {
+		return nil, false
+	}
+	v, err := defaultValue(fd.fieldDef.Type.Name(), val, now)
+	return v, err == nil
+}
@@ -0,0 +1,294 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// dgraphPredicate returns the Dgraph predicate that stores field fld of type
+// defn.  A field is stored as TypeName.fieldName, unless @dgraph(pred: ...)
+// names the predicate, except that fields inherited from an interface are
+// stored as the interface's are, so all the implementations of the interface
+// share the predicate.
+func dgraphPredicate(sch *ast.Schema, defn *ast.Definition, fld string) string {
+	for _, iface := range defn.Interfaces {
+		if idefn := sch.Types[iface]; idefn != nil && idefn.Fields.ForName(fld) != nil {
+			return dgraphPredicate(sch, idefn, fld)
+		}
+	}
+	if pred := namedPredicate(defn.Fields.ForName(fld)); pred != "" {
+		return pred
+	}
+	if isXid(defn.Fields.ForName(fld)) {
+		return XidPredicate
+	}
+	if f := defn.Fields.ForName(fld); f != nil && isReverse(f) {
+		if invType := sch.Types[f.Type.Name()]; invType != nil {
+			return "~" + dgraphPredicate(sch, invType, inverseName(f))
+		}
+	}
+	return defn.Name + "." + fld
+}
+
+// inverseName returns the name of fld's @hasInverse field.
+func inverseName(fld *ast.FieldDefinition) string {
+	dir := fld.Directives.ForName(inverseDirective)
+	if dir == nil {
+		return ""
+	}
+	if arg := dir.Arguments.ForName(inverseArg); arg != nil && arg.Value != nil {
+		return arg.Value.Raw
+	}
+	return ""
+}
+
+// reversedPredicates returns the predicates that are read backwards, by
+// reverse @hasInverse fields or when deleting the objects that @onDelete
+// fields link to, and so need Dgraph's @reverse.
+func reversedPredicates(sch *ast.Schema) map[string]bool {
+	reversed := make(map[string]bool)
+	for _, defn := range sch.Types {
+		if defn.Position == nil || isRemote(defn) || isEdge(defn) {
+			continue
+		}
+		for _, fld := range defn.Fields {
+			switch {
+			case isReverse(fld):
+				reversed[strings.TrimPrefix(dgraphPredicate(sch, defn, fld.Name), "~")] = true
+			case deleteAction(fld) != "":
+				reversed[dgraphPredicate(sch, defn, fld.Name)] = true
+			}
+		}
+	}
+	return reversed
+}
+
+// namedPredicate returns the predicate that fld's @dgraph directive names, or
+// "" if it doesn't have one.
+func namedPredicate(fld *ast.FieldDefinition) string {
+	if fld == nil {
+		return ""
+	}
+	dir := fld.Directives.ForName(dgraphDirective)
+	if dir == nil {
+		return ""
+	}
+	if arg := dir.Arguments.ForName(dgraphPredArg); arg != nil && arg.Value != nil {
+		return arg.Value.Raw
+	}
+	return ""
+}
+
+func dgraphPredRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(dgraphDirective)
+	if dir == nil {
+		return nil
+	}
+
+	pred := namedPredicate(field)
+	switch {
+	case pred == "" || strings.ContainsAny(pred, " \t\n<>"):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @dgraph needs a predicate name, but %q isn't one.",
+			defn.Name, field.Name, pred)
+	case strings.HasPrefix(pred, "~"):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @dgraph can't store a field in reverse predicate %s.  "+
+				"Use @hasInverse to link fields both ways.", defn.Name, field.Name, pred)
+	case strings.HasPrefix(pred, "dgraph."):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: predicate %s is reserved by Dgraph.",
+			defn.Name, field.Name, pred)
+	case field.Type.Name() == IDType || isCustom(field) || isLambda(field) ||
+		reservedTypeNames[defn.Name] || isRemote(defn) || isEdge(defn):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: isn't stored in Dgraph, so it can't have @dgraph.",
+			defn.Name, field.Name)
+	}
+
+	for _, iface := range defn.Interfaces {
+		if idefn := doc.Definitions.ForName(iface); idefn != nil &&
+			idefn.Fields.ForName(field.Name) != nil {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: is stored in the predicate of interface %s's field, so it "+
+					"can't have @dgraph.  Put @dgraph on the interface's field instead.",
+				defn.Name, field.Name, iface)
+		}
+	}
+	return nil
+}
+
+// checkSharedPredicates checks that fields stored in the same predicate, by
+// @dgraph, agree on how it's stored: its Dgraph type and its indexes.
+// Otherwise, the Dgraph schema can't fit them both.  Fields inherited from
+// an interface aren't checked, because they're stored as the interface's
+// field is.
+func checkSharedPredicates(sch *ast.Schema) gqlerror.List {
+	type storage struct {
+		field   string
+		typ     string
+		indexes string
+	}
+
+	var errs gqlerror.List
+	seen := make(map[string]storage)
+	for _, name := range definitionNames(sch) {
+		defn := sch.Types[name]
+		if defn.BuiltIn || defn.Position == nil || reservedTypeNames[name] || isRemote(defn) ||
+			isEdge(defn) || (defn.Kind != ast.Object && defn.Kind != ast.Interface) {
+			continue
+		}
+		for _, fld := range defn.Fields {
+			if fld.Type.Name() == IDType || isCustom(fld) || isLambda(fld) ||
+				inherited(sch, defn, fld.Name) || isEdges(defn, fld) || isReverse(fld) {
+				continue
+			}
+
+			indexes := append([]string(nil), searchIndexes(sch, fld)...)
+			sort.Strings(indexes)
+			here := storage{
+				field:   name + "." + fld.Name,
+				typ:     dgraphType(sch, fld.Type),
+				indexes: strings.Join(indexes, ", "),
+			}
+			pred := dgraphPredicate(sch, defn, fld.Name)
+			there, ok := seen[pred]
+			if !ok {
+				seen[pred] = here
+				continue
+			}
+			if there.typ != here.typ || there.indexes != here.indexes {
+				errs = append(errs, gqlerror.ErrorPosf(fld.Position,
+					"Type %s; Field %s: is stored in predicate %s, like field %s, so it must "+
+						"have the same Dgraph type (%s) and @search indexes (%s).",
+					name, fld.Name, pred, there.field, there.typ, there.indexes))
+			}
+		}
+	}
+	return errs
+}
+
+// inherited returns true if field fld of defn comes from an interface.
+func inherited(sch *ast.Schema, defn *ast.Definition, fld string) bool {
+	for _, iface := range defn.Interfaces {
+		if idefn := sch.Types[iface]; idefn != nil && idefn.Fields.ForName(fld) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// A Predicate is a Dgraph predicate that the generated schema stores data in,
+// and that the resolvers rely on being there.
+type Predicate struct {
+	Name string
+
+	// Type is the Dgraph type, as it's written in a Dgraph schema, e.g.
+	// "string" or "[uid]".
+	Type string
+
+	// Indexes are the tokenizers the predicate must be indexed with, so that
+	// the filters generated for it work.
+	Indexes []string
+
+	// Reverse is true if the predicate needs Dgraph's @reverse, because a
+	// @hasInverse field or an @onDelete reads its edges backwards.
+	Reverse bool
+
+	// Upsert is true if the predicate needs Dgraph's @upsert, so that
+	// transactions that add the same value conflict.  That's the xid
+	// predicate of @xid fields.
+	Upsert bool
+}
+
+// genDgraphSchema generates the Dgraph schema (predicates and types) that's
+// needed to store the GraphQL types in sch, and lists the predicates in it.
+func genDgraphSchema(sch *ast.Schema) (string, []Predicate) {
+	var typeDefs, preds strings.Builder
+	var predicates []Predicate
+	seen := make(map[string]bool)
+	reversed := reversedPredicates(sch)
+
+	for _, name := range definitionNames(sch) {
+		defn := sch.Types[name]
+		// Types added by GenerateCompleteSchema have no source position; they
+		// are part of the API, but aren't stored in Dgraph.  Nor are @remote
+		// types.
+		if defn.BuiltIn || defn.Position == nil || reservedTypeNames[name] || isRemote(defn) ||
+			isEdge(defn) || (defn.Kind != ast.Object && defn.Kind != ast.Interface) {
+			continue
+		}
+
+		var typeDef strings.Builder
+		fmt.Fprintf(&typeDef, "type %s {\n", name)
+		for _, fld := range defn.Fields {
+			if fld.Type.Name() == "ID" || isCustom(fld) || isEdges(defn, fld) || isReverse(fld) {
+				continue
+			}
+
+			pred := dgraphPredicate(sch, defn, fld.Name)
+			typ := dgraphType(sch, fld.Type)
+			fmt.Fprintf(&typeDef, "\t%s: %s\n", pred, typ)
+
+			if seen[pred] {
+				continue
+			}
+			seen[pred] = true
+
+			indexes := searchIndexes(sch, fld)
+			var directives string
+			if len(indexes) > 0 {
+				directives = fmt.Sprintf(" @index(%s)", strings.Join(indexes, ", "))
+			}
+			if reversed[pred] {
+				directives += " @reverse"
+			}
+			upsert := isXid(fld)
+			if upsert {
+				directives += " @upsert"
+			}
+			fmt.Fprintf(&preds, "%s: %s%s .\n", pred, typ, directives)
+			predicates = append(predicates, Predicate{Name: pred, Type: typ, Indexes: indexes,
+				Reverse: reversed[pred], Upsert: upsert})
+		}
+		typeDef.WriteString("}\n")
+		typeDefs.WriteString(typeDef.String())
+	}
+
+	return typeDefs.String() + preds.String(), predicates
+}
+
+func dgraphType(sch *ast.Schema, typ *ast.Type) string {
+	if typ.Elem != nil {
+		return "[" + dgraphType(sch, typ.Elem) + "]"
+	}
+	if dt, ok := scalarToDgraph[typ.NamedType]; ok {
+		return dt
+	}
+	if defn := sch.Types[typ.NamedType]; defn != nil && defn.Kind == ast.Enum {
+		return "string"
+	}
+	return "uid"
+}
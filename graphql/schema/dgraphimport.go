@@ -0,0 +1,203 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A DgraphType is a type in Dgraph's schema.
+type DgraphType struct {
+	Name   string
+	Fields []DgraphField
+}
+
+// A DgraphField is a field of a type in Dgraph's schema: the predicate, and
+// its type in the type, e.g. "string", "[uid]" or "[Person]".
+type DgraphField struct {
+	Predicate string
+	Type      string
+}
+
+// dgraphScalars maps the Dgraph scalar types to GraphQL scalars.
+var dgraphScalars = map[string]string{
+	"string":   "String",
+	"default":  "String",
+	"int":      "Int",
+	"float":    "Float",
+	"bool":     "Boolean",
+	"datetime": "DateTime",
+}
+
+// SDLFromDgraph returns a GraphQL schema for data that's stored with Dgraph
+// schema types and preds, so that an existing database can be served without
+// writing its GraphQL schema from scratch.  It's a best effort, to be reviewed
+// before it's applied: each Dgraph type becomes a GraphQL type, with a field
+// for each of its predicates that has a GraphQL equivalent, and predicates
+// that aren't named Type.field are mapped with @dgraph.  The indexes that
+// @search supports are kept, but applying the schema alters Dgraph's schema
+// to fit it, so other indexes on the predicates are dropped.  What's left out
+// is listed in comments.
+func SDLFromDgraph(types []DgraphType, preds []Predicate) string {
+	predicates := make(map[string]Predicate, len(preds))
+	for _, pred := range preds {
+		predicates[pred.Name] = pred
+	}
+
+	types = append([]DgraphType(nil), types...)
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	known := make(map[string]bool, len(types))
+	var skipped []string
+	for _, typ := range types {
+		switch {
+		case strings.HasPrefix(typ.Name, "dgraph."):
+		case !graphqlName.MatchString(typ.Name) || reservedTypeNames[typ.Name]:
+			skipped = append(skipped, fmt.Sprintf("type %s: isn't a GraphQL type name", typ.Name))
+		default:
+			known[typ.Name] = true
+		}
+	}
+
+	var sdl strings.Builder
+	inTypes := make(map[string]bool)
+	for _, typ := range types {
+		if !known[typ.Name] {
+			continue
+		}
+		fmt.Fprintf(&sdl, "type %s {\n\tid: ID!\n", typ.Name)
+		names := map[string]bool{"id": true}
+		for _, fld := range typ.Fields {
+			inTypes[fld.Predicate] = true
+			if strings.HasPrefix(fld.Predicate, "dgraph.") {
+				continue
+			}
+			field, problem := importField(typ.Name, fld, predicates[fld.Predicate], known, names)
+			if problem != "" {
+				skipped = append(skipped, fmt.Sprintf("%s.%s: %s", typ.Name, fld.Predicate, problem))
+				continue
+			}
+			sdl.WriteString("\t" + field + "\n")
+		}
+		sdl.WriteString("}\n\n")
+	}
+
+	var loose []string
+	for _, pred := range preds {
+		if !inTypes[pred.Name] && !strings.HasPrefix(pred.Name, "dgraph.") {
+			loose = append(loose, pred.Name)
+		}
+	}
+	sort.Strings(loose)
+	if len(loose) > 0 {
+		skipped = append(skipped, "predicates that aren't in any type: "+strings.Join(loose, ", "))
+	}
+
+	if len(skipped) > 0 {
+		sdl.WriteString("# Left out of the schema:\n")
+		for _, s := range skipped {
+			sdl.WriteString("#   " + s + "\n")
+		}
+	}
+	return strings.TrimRight(sdl.String(), "\n") + "\n"
+}
+
+// importField returns the GraphQL field for Dgraph field fld of type typName,
+// or why there can't be one.  names are the names of the fields of the type so
+// far, which fld's name mustn't clash with.
+func importField(typName string, fld DgraphField, pred Predicate, known map[string]bool,
+	names map[string]bool) (string, string) {
+
+	// The type says what's required, e.g. "[string!]!".
+	base := fld.Type
+	list, listNonNull := false, false
+	if strings.HasPrefix(base, "[") {
+		list, listNonNull = true, strings.HasSuffix(base, "]!")
+		base = strings.TrimSuffix(strings.TrimSuffix(base[1:], "!"), "]")
+	}
+	nonNull := strings.HasSuffix(base, "!")
+	base = strings.TrimSuffix(base, "!")
+	if pred.Type != "" {
+		// But the predicate's schema says how it's stored.
+		list = strings.HasPrefix(pred.Type, "[")
+	}
+
+	var gqlType string
+	switch scalar, ok := dgraphScalars[strings.ToLower(base)]; {
+	case ok:
+		gqlType = scalar
+	case known[base]:
+		gqlType = base
+	case strings.ToLower(base) == "uid":
+		return "", "edges whose type Dgraph doesn't know can't be typed"
+	default:
+		return "", fmt.Sprintf("GraphQL has no %s type", base)
+	}
+
+	var search string
+	if pred.Name != "" {
+		var indexes []string
+		for _, index := range supportedSearches[gqlType] {
+			if contains(pred.Indexes, index) {
+				indexes = append(indexes, index)
+			}
+		}
+		if len(indexes) > 0 {
+			search = fmt.Sprintf(" @search(by: [%s])", strings.Join(indexes, ", "))
+		}
+	}
+
+	name := strings.TrimPrefix(fld.Predicate, typName+".")
+	if name == fld.Predicate || !graphqlName.MatchString(name) {
+		name = importedName(fld.Predicate)
+	}
+	for base, i := name, 2; names[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	names[name] = true
+	var dgraph string
+	if typName+"."+name != fld.Predicate {
+		dgraph = fmt.Sprintf(" @dgraph(pred: %q)", fld.Predicate)
+	}
+
+	if nonNull {
+		gqlType += "!"
+	}
+	if list {
+		gqlType = "[" + gqlType + "]"
+	}
+	if list && listNonNull {
+		gqlType += "!"
+	}
+	return fmt.Sprintf("%s: %s%s%s", name, gqlType, dgraph, search), ""
+}
+
+// importedName makes predicate pred into a GraphQL field name, e.g.
+// "birth_date" for "birth.date".
+func importedName(pred string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, pred)
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
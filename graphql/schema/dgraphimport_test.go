@@ -0,0 +1,83 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSDLFromDgraph(t *testing.T) {
+	types := []DgraphType{
+		{Name: "Person", Fields: []DgraphField{
+			{Predicate: "name", Type: "string!"},
+			{Predicate: "Person.age", Type: "int"},
+			{Predicate: "friend", Type: "[Person]"},
+			{Predicate: "birth.date", Type: "datetime"},
+			{Predicate: "location", Type: "geo"},
+			{Predicate: "dgraph.type", Type: "[string]"},
+		}},
+		{Name: "Film", Fields: []DgraphField{
+			{Predicate: "name", Type: "string!"},
+			{Predicate: "starring", Type: "[uid]"},
+			{Predicate: "genre", Type: "[string]"},
+		}},
+		{Name: "dgraph.graphql", Fields: []DgraphField{
+			{Predicate: "dgraph.graphql.schema", Type: "string"},
+		}},
+		{Name: "Film-Review"},
+	}
+	preds := []Predicate{
+		{Name: "name", Type: "string", Indexes: []string{"term", "fulltext", "hash"}},
+		{Name: "Person.age", Type: "int", Indexes: []string{"int"}},
+		{Name: "friend", Type: "[uid]"},
+		{Name: "birth.date", Type: "datetime", Indexes: []string{"year"}},
+		{Name: "location", Type: "geo"},
+		{Name: "starring", Type: "[uid]"},
+		{Name: "genre", Type: "[string]", Indexes: []string{"exact"}},
+		{Name: "rating", Type: "float"},
+		{Name: "dgraph.type", Type: "[string]"},
+	}
+
+	sdl := SDLFromDgraph(types, preds)
+	require.Equal(t, `type Film {
+	id: ID!
+	name: String! @dgraph(pred: "name") @search(by: [term, hash, fulltext])
+	genre: [String] @dgraph(pred: "genre") @search(by: [exact])
+}
+
+type Person {
+	id: ID!
+	name: String! @dgraph(pred: "name") @search(by: [term, hash, fulltext])
+	age: Int @search(by: [int])
+	friend: [Person] @dgraph(pred: "friend")
+	birth_date: DateTime @dgraph(pred: "birth.date") @search(by: [year])
+}
+
+# Left out of the schema:
+#   type Film-Review: isn't a GraphQL type name
+#   Film.starring: edges whose type Dgraph doesn't know can't be typed
+#   Person.location: GraphQL has no geo type
+#   predicates that aren't in any type: rating
+`, sdl)
+
+	handler, err := NewHandler(sdl)
+	require.NoError(t, err)
+	require.Contains(t, handler.DGSchema(), "name: string @index(term, hash, fulltext) .")
+	require.Contains(t, handler.DGSchema(), "birth.date: dateTime @index(year) .")
+}
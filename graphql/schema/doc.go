@@ -0,0 +1,39 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package schema turns a GraphQL schema written for Dgraph - types, with
+// directives like @search and @hasInverse - into the GraphQL API that serves
+// it, and the Dgraph schema that stores its data.  It's used by the Dgraph
+// GraphQL server, and can be used on its own, e.g. to check and generate
+// schemas in build tools.
+//
+// The stable API is the steps that the server takes with a schema:
+//
+//	doc, err := schema.Parse(input)         // the syntax
+//	sch, err := schema.Validate(doc)        // what Dgraph can serve
+//	err = schema.Generate(doc, sch)         // the queries, mutations and inputs
+//	sdl := schema.Stringify(sch, names)     // the generated API, as SDL
+//	changes := schema.Diff(old, sch)        // how the API changed
+//
+// Stringify prints the types named in names, those of the input, ahead of
+// the generated ones.
+//
+// NewHandler takes all the steps at once, and works out the Dgraph schema
+// too, and Build does the same for a schema built in Go with NewType.  The
+// Schema, Operation and Field interfaces are what the server's resolvers
+// work with; they're exported for the server's packages, and might change
+// along with it.
+package schema
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// VectorType is the scalar of embeddings: lists of floats, like the output of
+// a machine learning model, that are compared by distance.  Dgraph has no
+// vector type, so a vector is stored as its JSON text.
+const VectorType = "Float32Vector"
+
+// An @embedding field is a vector that objects can be searched by.  A type
+// with @embedding fields, e.g.
+//
+//	type Product {
+//		id: ID!
+//		title: String
+//		description_v: Float32Vector @embedding
+//	}
+//
+// gets querySimilarProductByEmbedding(by: description_v, topK: 5, vector: [...]),
+// which answers with the topK products whose vector in the by field is nearest
+// to vector, nearest first.  Distance is Euclidean.  Dgraph can't index
+// vectors, so every object of the type that satisfies the search's filter is
+// compared, and a search that would compare more than 10000 is an error:
+// similarity search suits types with thousands of objects, not millions.  For
+// the same reason, @search(by: [hnsw]) is an error.
+
+// embeddingRule checks that @embedding is only on Float32Vector fields that
+// are stored in Dgraph.
+func embeddingRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(embeddingDirective)
+	switch {
+	case dir == nil && field.Type.Name() == VectorType && field.Type.Elem != nil:
+		return gqlerror.ErrorPosf(field.Position,
+			"Type %s; Field %s: lists of %s aren't supported.", defn.Name, field.Name,
+			VectorType)
+	case dir == nil:
+		return nil
+	case field.Type.Name() != VectorType || field.Type.Elem != nil:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: has the @embedding directive, but only fields of type %s "+
+				"can be embeddings.", defn.Name, field.Name, VectorType)
+	case defn.Kind != ast.Object || isRemote(defn) || isCustom(field) || isLambda(field):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @embedding is only allowed on fields of object types that "+
+				"are stored in Dgraph.", defn.Name, field.Name)
+	}
+	return nil
+}
+
+// embeddingFields returns the @embedding fields of defn.
+func embeddingFields(defn *ast.Definition) []*ast.FieldDefinition {
+	var flds []*ast.FieldDefinition
+	for _, fld := range defn.Fields {
+		if fld.Directives.ForName(embeddingDirective) != nil {
+			flds = append(flds, fld)
+		}
+	}
+	return flds
+}
+
+// addSimilarQuery adds the similarity search query of defn, and TEmbedding,
+// the enum of the fields it can search by, if defn has @embedding fields.
+func addSimilarQuery(schema *ast.Schema, defn *ast.Definition, name string) {
+	flds := embeddingFields(defn)
+	if len(flds) == 0 {
+		return
+	}
+
+	by := &ast.Definition{Kind: ast.Enum, Name: defn.Name + "Embedding"}
+	for _, fld := range flds {
+		by.EnumValues = append(by.EnumValues, &ast.EnumValueDefinition{Name: fld.Name})
+	}
+	schema.Types[by.Name] = by
+
+	schema.Query.Fields = append(schema.Query.Fields,
+		&ast.FieldDefinition{
+			Name: name,
+			Type: ast.ListType(ast.NamedType(defn.Name, nil), nil),
+			Arguments: ast.ArgumentDefinitionList{
+				{Name: "by", Type: ast.NonNullNamedType(by.Name, nil)},
+				{Name: "topK", Type: ast.NonNullNamedType("Int", nil)},
+				{Name: "vector",
+					Type: ast.NonNullListType(ast.NonNullNamedType("Float", nil), nil)},
+				{Name: "filter", Type: ast.NamedType(defn.Name+"Filter", nil)},
+			},
+		})
+}
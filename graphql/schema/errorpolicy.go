@@ -0,0 +1,109 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// An ErrorPolicy says what happens when a field can't be resolved - e.g.
+// because its @custom call failed, or what's stored for it doesn't fit its
+// type.  It's set with @onError on the field, or on its type for all the
+// type's fields:
+//
+//	type Order @onError(policy: ABORT_OBJECT) {
+//		id: ID!
+//		total: Float
+//		discount: Float @onError(policy: NULL_FIELD)
+//	}
+//
+// A field that's non-nullable always aborts its object, as the GraphQL spec
+// requires, whatever its policy.
+type ErrorPolicy string
+
+const (
+	// NullField makes a field that can't be resolved null, with an error
+	// for the field.  It's the default.
+	NullField ErrorPolicy = "NULL_FIELD"
+
+	// AbortObject makes the object that a field is in null if the field
+	// can't be resolved, as if the field were non-nullable.
+	AbortObject ErrorPolicy = "ABORT_OBJECT"
+)
+
+// errorPolicy returns the error policy of field fld in type defn.
+func errorPolicy(defn *ast.Definition, fld *ast.FieldDefinition) ErrorPolicy {
+	if p := directivePolicy(fld.Directives); p != "" {
+		return p
+	}
+	if p := directivePolicy(defn.Directives); p != "" {
+		return p
+	}
+	return NullField
+}
+
+// directivePolicy returns the policy of the @onError directive in dirs, or ""
+// if there isn't one.
+func directivePolicy(dirs ast.DirectiveList) ErrorPolicy {
+	dir := dirs.ForName(onErrorDirective)
+	if dir == nil {
+		return ""
+	}
+	arg := dir.Arguments.ForName(onErrorPolicyArg)
+	if arg == nil || arg.Value == nil {
+		return ""
+	}
+	return ErrorPolicy(arg.Value.Raw)
+}
+
+// onErrorRule checks that the @onError directive of defn, if it has one,
+// gives a policy.
+func onErrorRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	dir := defn.Directives.ForName(onErrorDirective)
+	if dir == nil {
+		return nil
+	}
+	if defn.Kind != ast.Object {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @onError directive is only allowed on types and their fields.",
+			defn.Name)
+	}
+	return checkPolicy(dir, "Type "+defn.Name)
+}
+
+// onErrorFieldRule checks that the @onError directive of field, if it has
+// one, gives a policy.
+func onErrorFieldRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(onErrorDirective)
+	if dir == nil {
+		return nil
+	}
+	return checkPolicy(dir, "Type "+defn.Name+"; Field "+field.Name)
+}
+
+func checkPolicy(dir *ast.Directive, where string) *gqlerror.Error {
+	arg := dir.Arguments.ForName(onErrorPolicyArg)
+	if arg == nil || arg.Value == nil || arg.Value.Kind != ast.EnumValue ||
+		(ErrorPolicy(arg.Value.Raw) != NullField && ErrorPolicy(arg.Value.Raw) != AbortObject) {
+		return gqlerror.ErrorPosf(dir.Position,
+			"%s: @onError needs a policy of %s or %s.", where, NullField, AbortObject)
+	}
+	return nil
+}
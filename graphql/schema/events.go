@@ -0,0 +1,123 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"net/url"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A @lambdaOnMutate type sends an event, once they're committed, for each of
+// the kinds of mutation that it names:
+//
+//	type Post @lambdaOnMutate(add: true, delete: true) {
+//		id: ID!
+//		title: String!
+//	}
+//
+// The events go to the lambda server, or to url if it's given, so that other
+// systems can be kept in step with the posts, or notified of them.  An event
+// has the type, the mutation, the uids of the objects it changed and its
+// input; a mutation that doesn't change anything doesn't send one.
+
+// MutationEvents says which mutations of a type send events, and where to.
+type MutationEvents struct {
+	Add    bool
+	Update bool
+	Delete bool
+
+	// URL is where the events are sent; "" sends them to the lambda server.
+	URL string
+}
+
+// Sends returns true if mutations of kind send events.
+func (me *MutationEvents) Sends(kind MutationType) bool {
+	if me == nil {
+		return false
+	}
+	switch kind {
+	case AddMutation:
+		return me.Add
+	case UpdateMutation:
+		return me.Update
+	case DeleteMutation:
+		return me.Delete
+	}
+	return false
+}
+
+// mutationEvents returns the events that defn sends, from its
+// @lambdaOnMutate directive, or nil if it doesn't send any.
+func mutationEvents(defn *ast.Definition) *MutationEvents {
+	dir := defn.Directives.ForName(lambdaOnMutateDirective)
+	if dir == nil {
+		return nil
+	}
+	isTrue := func(name string) bool {
+		arg := dir.Arguments.ForName(name)
+		return arg != nil && arg.Value != nil && arg.Value.Raw == "true"
+	}
+	me := &MutationEvents{
+		Add:    isTrue(lambdaOnMutateAddArg),
+		Update: isTrue(lambdaOnMutateUpdateArg),
+		Delete: isTrue(lambdaOnMutateDeleteArg),
+	}
+	if arg := dir.Arguments.ForName(lambdaOnMutateURLArg); arg != nil && arg.Value != nil {
+		me.URL = arg.Value.Raw
+	}
+	return me
+}
+
+func lambdaOnMutateRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	dir := defn.Directives.ForName(lambdaOnMutateDirective)
+	if dir == nil {
+		return nil
+	}
+
+	if defn.Kind != ast.Object || isRemote(defn) || isEdge(defn) ||
+		reservedTypeNames[defn.Name] {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @lambdaOnMutate is only allowed on object types that are stored in "+
+				"Dgraph.", defn.Name)
+	}
+	me := mutationEvents(defn)
+	if !me.Add && !me.Update && !me.Delete {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @lambdaOnMutate doesn't send any events; set add, update or delete "+
+				"to true.", defn.Name)
+	}
+	if me.URL != "" {
+		u, err := url.Parse(me.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; @lambdaOnMutate url %s isn't an http or https URL.", defn.Name,
+				me.URL)
+		}
+	}
+	return nil
+}
+
+// MutationEvents returns the events that t's mutations send, or nil if they
+// don't send any.
+func (t *astType) MutationEvents() *MutationEvents {
+	if info := t.inSchema.types[t.Name()]; info != nil {
+		return info.events
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"strings"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// Facets are values stored on the edges between objects, rather than on the
+// objects.  An @edge type declares facets, and a field that links to other
+// objects can store them on its edges with @facets:
+//
+//	type Friendship @edge {
+//		since: DateTime
+//		closeness: Int
+//	}
+//
+//	type Person {
+//		id: ID!
+//		name: String!
+//		friends: [Person] @facets(type: "Friendship")
+//	}
+//
+// An edge type, like PersonFriendsEdge, is generated for the field, with the
+// object at the end of the edge and the facets:
+//
+//	type PersonFriendsEdge {
+//		node: Person!
+//		since: DateTime
+//		closeness: Int
+//	}
+//
+// and the field's edges, facets and all, are read and written as field
+// friendsEdges (or friendsEdge, if friends isn't a list), alongside friends.
+// Only the edges of the field itself have facets, not those of its
+// @hasInverse.
+
+// EdgeNodeField is the field of a generated edge type that's the object at
+// the end of the edge.
+const EdgeNodeField = "node"
+
+// isEdge returns true if defn is an @edge type, which declares facets and
+// isn't stored in Dgraph.
+func isEdge(defn *ast.Definition) bool {
+	return defn.Directives.ForName(edgeDirective) != nil
+}
+
+// facetsType returns the name of the @edge type whose facets fld stores on
+// its edges, or "" if fld isn't @facets.
+func facetsType(fld *ast.FieldDefinition) string {
+	dir := fld.Directives.ForName(facetsDirective)
+	if dir == nil {
+		return ""
+	}
+	if arg := dir.Arguments.ForName(facetsTypeArg); arg != nil && arg.Value != nil {
+		return arg.Value.Raw
+	}
+	return ""
+}
+
+// edgeTypeName is the name of the generated edge type of fld, a @facets
+// field of defn, e.g. PersonFriendsEdge.
+func edgeTypeName(defn *ast.Definition, fld *ast.FieldDefinition) string {
+	return defn.Name + strings.ToUpper(fld.Name[:1]) + fld.Name[1:] + "Edge"
+}
+
+// edgesFieldName is the name of the field that has the edges of fld, a
+// @facets field, e.g. friendsEdges.
+func edgesFieldName(fld *ast.FieldDefinition) string {
+	if fld.Type.Elem != nil {
+		return fld.Name + "Edges"
+	}
+	return fld.Name + "Edge"
+}
+
+// isEdges returns true if fld is the generated field of defn that has the
+// edges of one of its @facets fields, so it's not stored itself.
+func isEdges(defn *ast.Definition, fld *ast.FieldDefinition) bool {
+	for _, f := range defn.Fields {
+		if facetsType(f) != "" && edgesFieldName(f) == fld.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// edgesFieldType is the type of a field that has the edges of fld: typName,
+// or a list of them, like fld.  Unless keepNonNull, the field is nullable.
+func edgesFieldType(fld *ast.FieldDefinition, typName string, keepNonNull bool) *ast.Type {
+	if fld.Type.Elem != nil {
+		return &ast.Type{
+			Elem:    &ast.Type{NamedType: typName, NonNull: fld.Type.Elem.NonNull},
+			NonNull: keepNonNull && fld.Type.NonNull,
+		}
+	}
+	return &ast.Type{NamedType: typName, NonNull: keepNonNull && fld.Type.NonNull}
+}
+
+// addEdgeTypes adds the edge types of the @facets fields of defn, the fields
+// of defn that have the edges, and those fields to the inputs for defn.
+func addEdgeTypes(schema *ast.Schema, defn *ast.Definition) {
+	for _, fld := range defn.Fields {
+		facets := schema.Types[facetsType(fld)]
+		if facets == nil {
+			continue
+		}
+
+		edgeName := edgeTypeName(defn, fld)
+		edge := &ast.Definition{Kind: ast.Object, Name: edgeName}
+		ref := &ast.Definition{Kind: ast.InputObject, Name: edgeName + "Ref"}
+		edge.Fields = append(edge.Fields, &ast.FieldDefinition{
+			Name: EdgeNodeField,
+			Type: ast.NonNullNamedType(fld.Type.Name(), nil),
+		})
+		ref.Fields = append(ref.Fields, &ast.FieldDefinition{
+			Name: EdgeNodeField,
+			Type: ast.NonNullNamedType(fld.Type.Name()+"Ref", nil),
+		})
+		for _, facet := range facets.Fields {
+			edge.Fields = append(edge.Fields,
+				&ast.FieldDefinition{Name: facet.Name, Type: facet.Type})
+			ref.Fields = append(ref.Fields,
+				&ast.FieldDefinition{Name: facet.Name, Type: facet.Type})
+		}
+		schema.Types[edgeName] = edge
+		schema.Types[ref.Name] = ref
+
+		defn.Fields = append(defn.Fields, &ast.FieldDefinition{
+			Name: edgesFieldName(fld),
+			Type: edgesFieldType(fld, edgeName, true),
+		})
+		// The edges can be given instead of, or as well as, fld's objects.  The
+		// inputs are copied, rather than changed, because they're kept to be
+		// reused for the next version of the schema.
+		for _, input := range []string{defn.Name + "Ref", "Add" + defn.Name + "Input",
+			defn.Name + "Patch"} {
+			if in := schema.Types[input]; in != nil {
+				with := *in
+				with.Fields = append(in.Fields[:len(in.Fields):len(in.Fields)],
+					&ast.FieldDefinition{
+						Name: edgesFieldName(fld),
+						Type: edgesFieldType(fld, ref.Name, false),
+					})
+				schema.Types[input] = &with
+			}
+		}
+	}
+}
+
+// edgeTypeRule checks that an @edge type is just facets: fields that are
+// Int, Float, Boolean, String, DateTime or enum values.
+func edgeTypeRule(schema *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	dir := defn.Directives.ForName(edgeDirective)
+	if dir == nil {
+		return nil
+	}
+	if defn.Kind != ast.Object || reservedTypeNames[defn.Name] || len(defn.Interfaces) > 0 ||
+		len(defn.Directives) > 1 {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s: @edge types can't implement interfaces or have other directives.",
+			defn.Name)
+	}
+
+	for _, fld := range defn.Fields {
+		typ := schema.Definitions.ForName(fld.Type.Name())
+		_, isScalar := scalarToDgraph[fld.Type.Name()]
+		switch {
+		case fld.Name == EdgeNodeField:
+			return gqlerror.ErrorPosf(fld.Position,
+				"Type %s; Field %s: is the object at the end of the edge in generated edge "+
+					"types, so it can't be a facet.", defn.Name, fld.Name)
+		case fld.Type.Elem != nil || fld.Type.Name() == IDType ||
+			fld.Type.Name() == VectorType || (!isScalar && (typ == nil || typ.Kind != ast.Enum)):
+			return gqlerror.ErrorPosf(fld.Position,
+				"Type %s; Field %s: facets can only be of type Int, Float, Boolean, String, "+
+					"DateTime or an enum.", defn.Name, fld.Name)
+		case len(fld.Directives) > 0:
+			return gqlerror.ErrorPosf(fld.Position,
+				"Type %s; Field %s: facets can't have directives.", defn.Name, fld.Name)
+		}
+	}
+	return nil
+}
+
+// facetsRule checks that a @facets field links to objects stored in Dgraph,
+// with an @edge type's facets, and that @edge types are only used by @facets.
+func facetsRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	typ := doc.Definitions.ForName(field.Type.Name())
+	dir := field.Directives.ForName(facetsDirective)
+	if dir == nil {
+		if typ != nil && isEdge(typ) {
+			return gqlerror.ErrorPosf(field.Position,
+				"Type %s; Field %s: %s is an @edge type, so it can only be used in @facets.",
+				defn.Name, field.Name, typ.Name)
+		}
+		return nil
+	}
+
+	facets := doc.Definitions.ForName(facetsType(field))
+	switch {
+	case defn.Kind != ast.Object || isRemote(defn) || isEdge(defn) ||
+		reservedTypeNames[defn.Name] || isCustom(field):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @facets is only allowed on fields of object types that are "+
+				"stored in Dgraph.", defn.Name, field.Name)
+	case typ == nil || typ.Kind != ast.Object || isRemote(typ) || isEdge(typ):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @facets is only allowed on fields that link to objects stored "+
+				"in Dgraph.", defn.Name, field.Name)
+	case facets == nil || !isEdge(facets):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @facets needs the name of an @edge type, but %q isn't one.",
+			defn.Name, field.Name, facetsType(field))
+	case defn.Fields.ForName(edgesFieldName(field)) != nil:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: the edges of %s are field %s, but there's already a field "+
+				"with that name.", defn.Name, field.Name, field.Name, edgesFieldName(field))
+	case doc.Definitions.ForName(edgeTypeName(defn, field)) != nil:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: the edge type of %s is %s, but there's already a type with "+
+				"that name.", defn.Name, field.Name, field.Name, edgeTypeName(defn, field))
+	}
+	return nil
+}
+
+// IsEdge returns true if t is a generated edge type, whose values are the
+// facets of an edge and the object at its end.
+func (t *astType) IsEdge() bool {
+	info := t.inSchema.types[t.Name()]
+	return info != nil && info.edge
+}
+
+// EdgeOf returns the @facets field whose edges fd has, or nil if fd doesn't
+// have edges.
+func (fd *fieldDefinition) EdgeOf() FieldDefinition {
+	if fd.edgeOf == nil {
+		return nil
+	}
+	return fd.edgeOf
+}
@@ -0,0 +1,412 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// Apollo Federation lets a gateway join the GraphQL API of this server with
+// the APIs of other services into one graph.  A type with a @key is an
+// entity: other services can refer to its objects by the key fields, and
+// the gateway fetches the rest of each object from this server with the
+// _entities query.  The gateway learns the API from the _service query.
+//
+//	type Author @key(fields: "id") {
+//		id: ID!
+//		name: String!
+//	}
+//
+// A type that @extends an entity of another service has @key fields that
+// are @external, because the other service owns them, and they're stored
+// here so that the fields this server adds can be found by them.
+//
+//	type User @key(fields: "email") @extends {
+//		email: String! @external @search(by: [hash])
+//		posts: [Post]
+//	}
+const (
+	entitiesQuery      = "_entities"
+	serviceQuery       = "_service"
+	representationsArg = "representations"
+	typenameField      = "__typename"
+)
+
+// specScalars are the scalars every GraphQL schema has, so they aren't
+// written in the SDL given to a gateway.
+var specScalars = map[string]bool{
+	"Int":     true,
+	"Float":   true,
+	"String":  true,
+	"Boolean": true,
+	"ID":      true,
+}
+
+// federationDirectives are the directives that a gateway understands.  The
+// rest say how this server stores and resolves fields, so they're left out
+// of the SDL it's given.
+var federationDirectives = map[string]bool{
+	keyDirective:      true,
+	externalDirective: true,
+	extendsDirective:  true,
+}
+
+// An Entity is an object that an _entities query asks for: one of the
+// representations the query is given.
+type Entity struct {
+	// Field resolves the entity.  It's the _entities query, but of the
+	// entity's type and with the fields that the query asks for on objects
+	// of that type.
+	Field Field
+
+	// Keys are the values that the representation gives for the @key fields
+	// of the entity's type.
+	Keys map[string]interface{}
+}
+
+// isEntity returns true if defn is an object type with a @key.
+func isEntity(defn *ast.Definition) bool {
+	return defn != nil && defn.Kind == ast.Object &&
+		defn.Directives.ForName(keyDirective) != nil
+}
+
+// keyFields returns the names of the fields in the @key of defn.
+func keyFields(defn *ast.Definition) []string {
+	dir := defn.Directives.ForName(keyDirective)
+	if dir == nil {
+		return nil
+	}
+	arg := dir.Arguments.ForName(keyFieldsArg)
+	if arg == nil || arg.Value == nil {
+		return nil
+	}
+	return strings.Fields(arg.Value.Raw)
+}
+
+// keyRule checks the @key of defn, if it has one.  Each key field must be
+// one that Dgraph can find objects by exactly: the ID, or a field with an
+// index, like hash, that's searched with eq.
+func keyRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	var keys []*ast.Directive
+	for _, dir := range defn.Directives {
+		if dir.Name == keyDirective {
+			keys = append(keys, dir)
+		}
+	}
+	if len(keys) == 0 {
+		if dir := defn.Directives.ForName(extendsDirective); dir != nil {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s: @extends types need a @key, so that the gateway can find them.",
+				defn.Name)
+		}
+		return nil
+	}
+
+	dir := keys[0]
+	switch {
+	case defn.Kind != ast.Object || reservedTypeNames[defn.Name]:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @key directive is only allowed on types.", defn.Name)
+	case isRemote(defn):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s: remote types aren't stored in Dgraph, so they can't have a @key.",
+			defn.Name)
+	case len(keys) > 1:
+		return gqlerror.ErrorPosf(keys[1].Position,
+			"Type %s: only one @key is supported.", defn.Name)
+	}
+
+	arg := dir.Arguments.ForName(keyFieldsArg)
+	if arg != nil && arg.Value != nil && strings.ContainsAny(arg.Value.Raw, "{}") {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @key can only use fields of the type itself, not of the objects it "+
+				"links to.", defn.Name)
+	}
+	names := keyFields(defn)
+	if len(names) == 0 {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @key needs the fields of the key, e.g. @key(fields: \"id\").", defn.Name)
+	}
+
+	extends := defn.Directives.ForName(extendsDirective) != nil
+	for _, name := range names {
+		fld := defn.Fields.ForName(name)
+		if fld == nil {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; @key uses %s, but type %s has no field called %s.",
+				defn.Name, name, defn.Name, name)
+		}
+		if !isKeyable(doc, defn, fld) {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; @key uses %s, but a key field must be the ID, or a field that's "+
+					"stored with a @search index that finds exact values, like hash.",
+				defn.Name, name)
+		}
+		if extends && fld.Directives.ForName(externalDirective) == nil {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; @key uses %s, which must be @external, because the type @extends "+
+					"a type of another service.", defn.Name, name)
+		}
+	}
+	return nil
+}
+
+// isKeyable returns true if fld, a field of defn, can be in a @key.
+func isKeyable(doc *ast.SchemaDocument, defn *ast.Definition, fld *ast.FieldDefinition) bool {
+	if isIDField(defn, fld) {
+		return true
+	}
+	if private, _ := isPrivate(fld); private || isCustom(fld) || fld.Type.Elem != nil {
+		return false
+	}
+
+	search := fld.Directives.ForName(searchDirective)
+	if search == nil {
+		return false
+	}
+	arg := search.Arguments.ForName(searchArgs)
+	if arg == nil || arg.Value == nil || len(arg.Value.Children) == 0 {
+		// Only strings are indexed by default for something other than eq.
+		typ := doc.Definitions.ForName(fld.Type.Name())
+		return (typ != nil && typ.Kind == ast.Enum) || fld.Type.Name() != "String"
+	}
+	for _, child := range arg.Value.Children {
+		switch child.Value.Raw {
+		case "term", "trigram", "fulltext":
+			// These indexes don't support eq.
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// externalRule checks that only the @key fields of @extends types are
+// @external.
+func externalRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(externalDirective)
+	if dir == nil {
+		return nil
+	}
+	if defn.Directives.ForName(extendsDirective) == nil {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: only fields of @extends types can be @external.",
+			defn.Name, field.Name)
+	}
+	if isIDField(defn, field) {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: ID fields can't be @external, because Dgraph assigns IDs.",
+			defn.Name, field.Name)
+	}
+	for _, name := range keyFields(defn) {
+		if name == field.Name {
+			return nil
+		}
+	}
+	return gqlerror.ErrorPosf(dir.Position,
+		"Type %s; Field %s: only @key fields can be @external, because this server "+
+			"stores the rest of the fields of %s.", defn.Name, field.Name, defn.Name)
+}
+
+// addFederation adds what a federation gateway needs to sch, if it has any
+// entities: the _Entity union of the entity types, and the _entities and
+// _service queries.
+func addFederation(sch *ast.Schema) {
+	entity := &ast.Definition{Kind: ast.Union, Name: "_Entity"}
+	for _, name := range definitionNames(sch) {
+		if defn := sch.Types[name]; isEntity(defn) {
+			entity.Types = append(entity.Types, name)
+			sch.AddPossibleType(entity.Name, defn)
+			sch.AddImplements(name, entity)
+		}
+	}
+	if len(entity.Types) == 0 {
+		return
+	}
+
+	service := &ast.Definition{
+		Kind: ast.Object,
+		Name: "_Service",
+		Fields: ast.FieldList{{
+			Name:        "sdl",
+			Type:        ast.NamedType("String", nil),
+			Description: "The SDL of the API, as a federation gateway composes it.",
+		}},
+	}
+	sch.Types[entity.Name] = entity
+	sch.Types[service.Name] = service
+
+	sch.Query.Fields = append(sch.Query.Fields,
+		&ast.FieldDefinition{
+			Name: entitiesQuery,
+			Arguments: ast.ArgumentDefinitionList{{
+				Name: representationsArg,
+				Type: ast.NonNullListType(ast.NonNullNamedType("_Any", nil), nil),
+			}},
+			Type: ast.NonNullListType(ast.NamedType(entity.Name, nil), nil),
+		},
+		&ast.FieldDefinition{
+			Name: serviceQuery,
+			Type: ast.NonNullNamedType(service.Name, nil),
+		})
+}
+
+// federationSDL is the SDL of the API that sch serves, as a federation
+// gateway composes it.  That's the types that the queries and mutations
+// use, with only the directives that a gateway understands.  The queries
+// that are just for the gateway, the introspection queries and
+// subscriptions are left out.
+func federationSDL(sch *ast.Schema) string {
+	types := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		defn := sch.Types[name]
+		if defn == nil || types[name] || specScalars[name] {
+			return
+		}
+		types[name] = true
+		for _, fld := range federatedFields(defn) {
+			visit(fld.Type.Name())
+			for _, arg := range fld.Arguments {
+				visit(arg.Type.Name())
+			}
+		}
+		for _, iface := range defn.Interfaces {
+			visit(iface)
+		}
+		if defn.Kind == ast.Interface {
+			for _, impl := range sch.PossibleTypes[name] {
+				visit(impl.Name)
+			}
+		}
+	}
+	visit("Query")
+	visit("Mutation")
+
+	var sdl strings.Builder
+	for _, name := range definitionNames(sch) {
+		if !types[name] {
+			continue
+		}
+		defn := *sch.Types[name]
+		defn.Directives = gatewayDirectives(defn.Directives)
+		defn.Fields = nil
+		for _, fld := range federatedFields(sch.Types[name]) {
+			f := *fld
+			f.Directives = gatewayDirectives(fld.Directives)
+			defn.Fields = append(defn.Fields, &f)
+		}
+		newPrinter(&sdl, FormatOptions{}).definition(&defn)
+	}
+	return sdl.String()
+}
+
+// federatedFields returns the fields of defn that a gateway is told about.
+func federatedFields(defn *ast.Definition) ast.FieldList {
+	var flds ast.FieldList
+	for _, fld := range defn.Fields {
+		if fld.Name == entitiesQuery || fld.Name == serviceQuery ||
+			strings.HasPrefix(fld.Name, "__") {
+			continue
+		}
+		flds = append(flds, fld)
+	}
+	return flds
+}
+
+func gatewayDirectives(dirs ast.DirectiveList) ast.DirectiveList {
+	var res ast.DirectiveList
+	for _, dir := range dirs {
+		if federationDirectives[dir.Name] {
+			res = append(res, dir)
+		}
+	}
+	return res
+}
+
+// ServiceSDL returns the SDL that answers q, a _service query.
+func ServiceSDL(q Query) (string, error) {
+	qry, ok := q.(*query)
+	if !ok || q.QueryType() != ServiceQuery {
+		return "", errors.Errorf("%s is not a _service query", q.Name())
+	}
+	return qry.op.inSchema.sdl, nil
+}
+
+// Entities returns the entities that q, an _entities query, asks for, in the
+// order of its representations.  Each representation must name an entity
+// type in __typename and give a value for each of the type's @key fields.
+func Entities(q Query) ([]*Entity, error) {
+	qry, ok := q.(*query)
+	if !ok || q.QueryType() != EntitiesQuery {
+		return nil, errors.Errorf("%s is not an _entities query", q.Name())
+	}
+
+	reps, _ := q.ArgValue(representationsArg).([]interface{})
+	fields := make(map[string]Field)
+	entities := make([]*Entity, len(reps))
+	for i, rep := range reps {
+		obj, ok := rep.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("representation %d isn't an object", i)
+		}
+		typName, _ := obj[typenameField].(string)
+		defn := qry.op.inSchema.schema.Types[typName]
+		if !isEntity(defn) {
+			return nil, errors.Errorf("representation %d is of %q, which isn't an entity type",
+				i, typName)
+		}
+
+		keys := make(map[string]interface{})
+		for _, name := range keyFields(defn) {
+			val, ok := obj[name]
+			if !ok || val == nil {
+				return nil, errors.Errorf("representation %d of %s has no value for key field %s",
+					i, typName, name)
+			}
+			keys[name] = val
+		}
+
+		if fields[typName] == nil {
+			fields[typName] = qry.entityField(typName)
+		}
+		entities[i] = &Entity{Field: fields[typName], Keys: keys}
+	}
+	return entities, nil
+}
+
+// entityField returns q, an _entities query, as a field of type typName with
+// the fields that q asks for on objects of that type.
+func (q *query) entityField(typName string) Field {
+	fld := *q.field
+	fld.SelectionSet = nil
+	for _, f := range collectFields(q.op.inSchema.schema, q.field.SelectionSet, typName,
+		q.op.vars) {
+		fld.SelectionSet = append(fld.SelectionSet, f)
+	}
+
+	def := *q.field.Definition
+	def.Type = ast.NamedType(typName, nil)
+	fld.Definition = &def
+	return &field{field: &fld, op: q.op}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func federationHandler(t *testing.T) Handler {
+	sdl, err := ioutil.ReadFile("testdata/schemagen/input/federation.graphql")
+	require.NoError(t, err)
+	handler, err := NewHandler(string(sdl))
+	require.NoError(t, err)
+	return handler
+}
+
+func TestServiceSDL(t *testing.T) {
+	handler := federationHandler(t)
+	op, err := handler.Schema().Operation(&Request{Query: `query { _service { sdl } }`})
+	require.NoError(t, err)
+	q := op.Queries()[0]
+	require.Equal(t, ServiceQuery, q.QueryType())
+
+	sdl, err := ServiceSDL(q)
+	require.NoError(t, err)
+	for _, want := range []string{
+		"type Author @key(fields: \"id\") {\n\tid: ID!\n\tname: String!\n",
+		"type User @key(fields: \"email\") @extends {\n\temail: String! @external\n",
+		"input AuthorFilter {",
+		"input StringHashFilter {",
+		"\tgetAuthor(id: ID!): Author\n",
+		"\taddUser(input: [AddUserInput!]!): AddUserPayload\n",
+	} {
+		require.Contains(t, sdl, want)
+	}
+	for _, unwanted := range []string{
+		"@search", "_entities", "_Service", "__schema", "subscribeAuthor", "DgraphIndex",
+		"scalar String",
+	} {
+		require.NotContains(t, sdl, unwanted)
+	}
+
+	// Schemas without entities aren't federated.
+	plain, err := NewHandler(`type Author { id: ID! name: String }`)
+	require.NoError(t, err)
+	require.NotContains(t, plain.GQLSchema(), "_service")
+}
+
+func TestEntities(t *testing.T) {
+	handler := federationHandler(t)
+	query := `query($reps: [_Any!]!) {
+		_entities(representations: $reps) {
+			__typename
+			... on Author { name }
+			... on User { email posts { title } }
+		}
+	}`
+
+	op, err := handler.Schema().Operation(&Request{Query: query,
+		Variables: map[string]interface{}{"reps": []interface{}{
+			map[string]interface{}{"__typename": "User", "email": "a@b.com"},
+			map[string]interface{}{"__typename": "Author", "id": "0x1"},
+			map[string]interface{}{"__typename": "User", "email": "c@d.com"},
+		}}})
+	require.NoError(t, err)
+	q := op.Queries()[0]
+	require.Equal(t, EntitiesQuery, q.QueryType())
+
+	entities, err := Entities(q)
+	require.NoError(t, err)
+	require.Len(t, entities, 3)
+	require.Equal(t, map[string]interface{}{"email": "a@b.com"}, entities[0].Keys)
+	require.Equal(t, map[string]interface{}{"id": "0x1"}, entities[1].Keys)
+	require.True(t, entities[0].Field == entities[2].Field, "entities of a type share a field")
+
+	user := entities[0].Field
+	require.Equal(t, "User", user.Type().Name())
+	require.True(t, user.Type().Nullable())
+	require.Equal(t, "_entities", user.ResponseName())
+	var names []string
+	for _, f := range user.SelectionSet() {
+		names = append(names, f.Name())
+	}
+	require.Equal(t, []string{"__typename", "email", "posts"}, names)
+	require.Equal(t, "User.email", user.SelectionSet()[1].DgraphPredicate())
+	require.Len(t, entities[1].Field.SelectionSet(), 2)
+}
+
+func TestEntitiesInvalid(t *testing.T) {
+	handler := federationHandler(t)
+	tests := []struct {
+		rep    interface{}
+		errMsg string
+	}{
+		{rep: "Author", errMsg: "representation 0 isn't an object"},
+		{
+			rep:    map[string]interface{}{"__typename": "AuthorFilter", "id": "0x1"},
+			errMsg: `representation 0 is of "AuthorFilter", which isn't an entity type`,
+		},
+		{
+			rep:    map[string]interface{}{"__typename": "User", "id": "0x1"},
+			errMsg: "representation 0 of User has no value for key field email",
+		},
+	}
+
+	for _, test := range tests {
+		op, err := handler.Schema().Operation(&Request{
+			Query:     `query($reps: [_Any!]!) { _entities(representations: $reps) { __typename } }`,
+			Variables: map[string]interface{}{"reps": []interface{}{test.rep}},
+		})
+		require.NoError(t, err)
+		_, err = Entities(op.Queries()[0])
+		require.EqualError(t, err, test.errMsg)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// generateArgs are the arguments of @generate, and the settings each takes.
+var generateArgs = map[string][]string{
+	generateQueryArg:        {"get", "query"},
+	generateMutationArg:     {"add", "update", "delete", "nonNullPayload"},
+	generateSubscriptionArg: nil,
+}
+
+// A generation says which queries, mutations and subscription are generated
+// for a type.  Everything is generated unless the type's @generate turns it
+// off, e.g.
+//
+//	type AuditEntry @generate(
+//		query: {get: false},
+//		mutation: {update: false, delete: false},
+//		subscription: false
+//	) {
+//		...
+//	}
+//
+// The input and payload types of a mutation are only generated along with
+// the mutation.  A payload's list of objects is [T!]!, unless the type's
+// mutation settings have nonNullPayload: false, which makes it [T], so an
+// object that fails to resolve is null on its own instead of nulling the
+// whole payload.  Without add, new objects of the type can't be created through
+// the API at all - not even nested in other types' mutations, which can only
+// link to existing objects by ID - so the type needs an ID field.  That suits
+// read-only reference data, like a list of countries, loaded into Dgraph
+// directly.
+type generation struct {
+	get, query, subscribe bool
+	add, update, delete   bool
+	nonNullPayload        bool
+}
+
+// generationFor returns the generation of defn, which has been validated.
+func generationFor(defn *ast.Definition) generation {
+	gen := generation{
+		get: true, query: true, subscribe: true,
+		add: true, update: true, delete: true,
+		nonNullPayload: true,
+	}
+	dir := defn.Directives.ForName(generateDirective)
+	if dir == nil {
+		return gen
+	}
+
+	setting := func(arg, name string) bool {
+		a := dir.Arguments.ForName(arg)
+		if a == nil {
+			return true
+		}
+		if name == "" {
+			return a.Value.Raw != "false"
+		}
+		for _, child := range a.Value.Children {
+			if child.Name == name {
+				return child.Value.Raw != "false"
+			}
+		}
+		return true
+	}
+	gen.get = setting(generateQueryArg, "get")
+	gen.query = setting(generateQueryArg, "query")
+	gen.add = setting(generateMutationArg, "add")
+	gen.update = setting(generateMutationArg, "update")
+	gen.delete = setting(generateMutationArg, "delete")
+	gen.nonNullPayload = setting(generateMutationArg, "nonNullPayload")
+	gen.subscribe = setting(generateSubscriptionArg, "")
+	return gen
+}
+
+// generateRule checks that the @generate directive of defn, if it has one,
+// is on a type that has queries and mutations generated, that its settings
+// are all true or false, and that add is only off if defn has an ID field.
+func generateRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	dir := defn.Directives.ForName(generateDirective)
+	if dir == nil {
+		return nil
+	}
+	if reservedTypeNames[defn.Name] || defn.Directives.ForName(remoteDirective) != nil {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @generate directive isn't allowed here.  Nothing is generated for "+
+				"%s, so there's nothing to turn off.", defn.Name, defn.Name)
+	}
+
+	for _, arg := range dir.Arguments {
+		settings, ok := generateArgs[arg.Name]
+		switch {
+		case !ok:
+			return gqlerror.ErrorPosf(arg.Position,
+				"Type %s; @generate has no %s setting.", defn.Name, arg.Name)
+		case settings == nil:
+			if arg.Value.Kind != ast.BooleanValue {
+				return gqlerror.ErrorPosf(arg.Position,
+					"Type %s; @generate needs %s to be true or false.", defn.Name, arg.Name)
+			}
+			continue
+		case arg.Value.Kind != ast.ObjectValue:
+			return gqlerror.ErrorPosf(arg.Position,
+				"Type %s; @generate needs %s to be an object like {%s: false}.",
+				defn.Name, arg.Name, settings[0])
+		}
+		for _, child := range arg.Value.Children {
+			if !contains(settings, child.Name) {
+				return gqlerror.ErrorPosf(child.Position,
+					"Type %s; @generate has no %s.%s setting.", defn.Name, arg.Name, child.Name)
+			}
+			if child.Value.Kind != ast.BooleanValue {
+				return gqlerror.ErrorPosf(child.Position,
+					"Type %s; @generate needs %s.%s to be true or false.",
+					defn.Name, arg.Name, child.Name)
+			}
+		}
+	}
+
+	if !generationFor(defn).add && idField(defn) == nil {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @generate can only turn off add for types with an ID field.  "+
+				"Otherwise, there'd be no way to refer to %s objects in mutations.",
+			defn.Name, defn.Name)
+	}
+	return nil
+}
+
+// Addable returns false if t's @generate turns off add, so new t objects
+// can't be created through the API, and true otherwise.
+func (t *astType) Addable() bool {
+	defn := t.inSchema.schema.Types[t.Name()]
+	return defn == nil || generationFor(defn).add
+}
@@ -0,0 +1,386 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+)
+
+// goScalars maps the GraphQL scalars to the Go types a client uses for them.
+// Any other scalar is left as raw JSON.
+var goScalars = map[string]string{
+	"ID":       "string",
+	"String":   "string",
+	"Int":      "int64",
+	"Float":    "float64",
+	"Boolean":  "bool",
+	"DateTime": "time.Time",
+	VectorType: "[]float64",
+}
+
+// graphqlKinds are how definitions are declared in GraphQL.
+var graphqlKinds = map[ast.DefinitionKind]string{
+	ast.Object:      "type",
+	ast.Interface:   "interface",
+	ast.InputObject: "input",
+	ast.Enum:        "enum",
+}
+
+// goInitialisms are the words that Go names spell in capitals.
+var goInitialisms = map[string]string{"Id": "ID", "Url": "URL", "Uid": "UID"}
+
+// goClient generates a Go client for a schema's API.
+type goClient struct {
+	sch     *ast.Schema
+	types   map[string]bool
+	imports map[string]bool
+	body    bytes.Buffer
+
+	// declared are the names declared in the package so far.
+	declared map[string]bool
+}
+
+// GoClient returns the source of Go package pkg, which has typed structs for
+// the types and enums that the queries and mutations in sch use, and a
+// builder function for each query and mutation.  A builder returns a
+// GraphQLRequest, ready to be sent as JSON to the GraphQL endpoint, and the
+// data in its response decodes into the operation's Response struct.
+func GoClient(sch *ast.Schema, pkg string) ([]byte, error) {
+	gen := &goClient{
+		sch:      sch,
+		types:    make(map[string]bool),
+		imports:  make(map[string]bool),
+		declared: map[string]bool{"GraphQLRequest": true},
+	}
+
+	var ops []*ast.FieldDefinition
+	for _, root := range []*ast.Definition{sch.Query, sch.Mutation} {
+		if root == nil {
+			continue
+		}
+		for _, fld := range root.Fields {
+			if strings.HasPrefix(fld.Name, "_") {
+				continue
+			}
+			ops = append(ops, fld)
+			gen.use(fld.Type)
+			for _, arg := range fld.Arguments {
+				gen.use(arg.Type)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(gen.types))
+	for name := range gen.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		gen.definition(sch.Types[name])
+	}
+	for _, op := range ops {
+		operation := "query"
+		if sch.Mutation != nil && sch.Mutation.Fields.ForName(op.Name) == op {
+			operation = "mutation"
+		}
+		gen.operation(operation, op)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by dgraph graphql gen-go. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	if len(gen.imports) > 0 {
+		imports := make([]string, 0, len(gen.imports))
+		for imp := range gen.imports {
+			imports = append(imports, fmt.Sprintf("%q", imp))
+		}
+		sort.Strings(imports)
+		fmt.Fprintf(&src, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	}
+	src.WriteString(`// GraphQLRequest is a GraphQL request.  It's sent as the JSON body of a POST to
+// the GraphQL endpoint.
+type GraphQLRequest struct {
+	Query         string                 ` + "`json:\"query\"`" + `
+	OperationName string                 ` + "`json:\"operationName,omitempty\"`" + `
+	Variables     map[string]interface{} ` + "`json:\"variables,omitempty\"`" + `
+}
+
+`)
+	src.Write(gen.body.Bytes())
+
+	out, err := format.Source(src.Bytes())
+	return out, errors.Wrap(err, "while formatting the generated Go client")
+}
+
+// use records that the client needs the type named in typ, and the types
+// that it needs in turn.
+func (gen *goClient) use(typ *ast.Type) {
+	name := typ.Name()
+	defn := gen.sch.Types[name]
+	if gen.types[name] || defn == nil {
+		return
+	}
+	switch defn.Kind {
+	case ast.Object, ast.Interface, ast.InputObject, ast.Enum:
+		if strings.HasPrefix(name, "__") {
+			return
+		}
+		gen.types[name] = true
+		for _, fld := range defn.Fields {
+			if !strings.HasPrefix(fld.Name, "__") {
+				gen.use(fld.Type)
+			}
+		}
+	}
+}
+
+func (gen *goClient) definition(defn *ast.Definition) {
+	name := goName(defn.Name)
+	gen.declared[name] = true
+	gen.comment(defn.Description, fmt.Sprintf("%s is the GraphQL %s %s.", name,
+		graphqlKinds[defn.Kind], defn.Name))
+
+	if defn.Kind == ast.Enum {
+		fmt.Fprintf(&gen.body, "type %s string\n\nconst (\n", name)
+		for _, val := range defn.EnumValues {
+			gen.declared[name+goName(val.Name)] = true
+			fmt.Fprintf(&gen.body, "%s%s %s = %q\n", name, goName(val.Name), name, val.Name)
+		}
+		gen.body.WriteString(")\n\n")
+		return
+	}
+
+	fmt.Fprintf(&gen.body, "type %s struct {\n", name)
+	for _, fld := range defn.Fields {
+		if strings.HasPrefix(fld.Name, "__") {
+			continue
+		}
+		tag := fld.Name
+		if !fld.Type.NonNull {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&gen.body, "%s %s `json:%q`\n", goName(fld.Name), gen.goType(fld.Type), tag)
+	}
+	if defn.Kind == ast.Interface {
+		gen.body.WriteString("Typename string `json:\"__typename,omitempty\"`\n")
+	}
+	gen.body.WriteString("}\n\n")
+
+	if defn.Kind != ast.InputObject {
+		gen.declared[name+"Fields"] = true
+		fmt.Fprintf(&gen.body, "// %sFields is the default selection from %s.\n",
+			name, defn.Name)
+		fmt.Fprintf(&gen.body, "const %sFields = %q\n\n", name, gen.selection(defn, true))
+	}
+}
+
+// selection returns the selection set of defn's scalar and enum fields that
+// don't need arguments.  If there aren't any, like in a mutation's payload,
+// and nest, its object fields are selected instead, with their scalar and
+// enum fields.
+func (gen *goClient) selection(defn *ast.Definition, nest bool) string {
+	var fields, objects []string
+	for _, fld := range defn.Fields {
+		if strings.HasPrefix(fld.Name, "__") || needsArguments(fld) {
+			continue
+		}
+		typ := gen.sch.Types[fld.Type.Name()]
+		switch {
+		case typ == nil || typ.Kind == ast.Scalar || typ.Kind == ast.Enum:
+			fields = append(fields, fld.Name)
+		case nest && (typ.Kind == ast.Object || typ.Kind == ast.Interface):
+			objects = append(objects, fld.Name+" "+gen.selection(typ, false))
+		}
+	}
+	if len(fields) == 0 {
+		fields = objects
+	}
+	if defn.Kind == ast.Interface || len(fields) == 0 {
+		fields = append(fields, "__typename")
+	}
+	return "{ " + strings.Join(fields, " ") + " }"
+}
+
+func needsArguments(fld *ast.FieldDefinition) bool {
+	for _, arg := range fld.Arguments {
+		if arg.Type.NonNull && arg.DefaultValue == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// operation writes the builder, and the arguments and response structs, for
+// query or mutation op.
+func (gen *goClient) operation(operation string, op *ast.FieldDefinition) {
+	name := gen.operationName(operation, op)
+
+	var params, vars, args []string
+	if len(op.Arguments) > 0 {
+		fmt.Fprintf(&gen.body, "// %sArgs are the arguments of %s %s.\n", name, operation, op.Name)
+		fmt.Fprintf(&gen.body, "type %sArgs struct {\n", name)
+		for _, arg := range op.Arguments {
+			gen.comment(arg.Description, "")
+			fmt.Fprintf(&gen.body, "%s %s\n", goName(arg.Name), gen.goType(arg.Type))
+			params = append(params, fmt.Sprintf("$%s: %s", arg.Name, arg.Type.String()))
+			args = append(args, fmt.Sprintf("%s: $%s", arg.Name, arg.Name))
+			vars = append(vars, fmt.Sprintf("%q: args.%s", arg.Name, goName(arg.Name)))
+		}
+		gen.body.WriteString("}\n\n")
+	}
+
+	fmt.Fprintf(&gen.body, "// %sResponse is the data in the response to %s %s.\n",
+		name, operation, op.Name)
+	fmt.Fprintf(&gen.body, "type %sResponse struct {\n%s %s `json:%q`\n}\n\n",
+		name, name, gen.goType(op.Type), op.Name)
+
+	query := operation + " " + name
+	if len(params) > 0 {
+		query += "(" + strings.Join(params, ", ") + ")"
+	}
+	query += " { " + op.Name
+	if len(args) > 0 {
+		query += "(" + strings.Join(args, ", ") + ")"
+	}
+
+	var sig []string
+	if len(op.Arguments) > 0 {
+		sig = append(sig, fmt.Sprintf("args %sArgs", name))
+	}
+	ret := gen.sch.Types[op.Type.Name()]
+	selects := ret != nil && ret.Kind != ast.Scalar && ret.Kind != ast.Enum
+	if selects {
+		sig = append(sig, "selection string")
+	}
+
+	gen.comment(op.Description, "")
+	if selects {
+		fmt.Fprintf(&gen.body, "// %s builds %s %s, selecting selection from its result, or\n"+
+			"// %sFields if selection is \"\".\n", name, operation, op.Name, goName(ret.Name))
+	} else {
+		fmt.Fprintf(&gen.body, "// %s builds %s %s.\n", name, operation, op.Name)
+	}
+	fmt.Fprintf(&gen.body, "func %s(%s) *GraphQLRequest {\n", name, strings.Join(sig, ", "))
+	if selects {
+		fmt.Fprintf(&gen.body, "if selection == \"\" {\nselection = %sFields\n}\n",
+			goName(ret.Name))
+		fmt.Fprintf(&gen.body, "query := %q + selection + \" }\"\n", query+" ")
+	} else {
+		fmt.Fprintf(&gen.body, "query := %q\n", query+" }")
+	}
+	fmt.Fprintf(&gen.body, "return &GraphQLRequest{\nQuery: query,\nOperationName: %q,\n", name)
+	if len(vars) > 0 {
+		fmt.Fprintf(&gen.body, "Variables: map[string]interface{}{\n%s,\n},\n",
+			strings.Join(vars, ",\n"))
+	}
+	gen.body.WriteString("}\n}\n\n")
+}
+
+// operationName returns the Go name of the builder for query or mutation op,
+// which its Args and Response structs are named after too.  That's op's name,
+// unless it's already declared, like a type's is when the queries are named
+// with @api(naming: PLURAL), and then it's followed by the operation, e.g.
+// CountryQuery.
+func (gen *goClient) operationName(operation string, op *ast.FieldDefinition) string {
+	base := goName(op.Name)
+	name := base
+	for i := 0; gen.declared[name] || gen.declared[name+"Args"] ||
+		gen.declared[name+"Response"]; i++ {
+
+		name = base + goName(operation)
+		if i > 0 {
+			name += fmt.Sprint(i + 1)
+		}
+	}
+	gen.declared[name] = true
+	gen.declared[name+"Args"] = true
+	gen.declared[name+"Response"] = true
+	return name
+}
+
+// goType returns the Go type for GraphQL type typ.  Nullable values are
+// pointers, so that null and the zero value can be told apart.
+func (gen *goClient) goType(typ *ast.Type) string {
+	if typ.Elem != nil {
+		return "[]" + gen.goType(typ.Elem)
+	}
+
+	name, ok := goScalars[typ.NamedType]
+	switch {
+	case ok:
+		if name == "time.Time" {
+			gen.imports["time"] = true
+		}
+	case gen.types[typ.NamedType]:
+		name = goName(typ.NamedType)
+	default:
+		// Unions, and scalars the client doesn't know, are left for the
+		// caller to decode.
+		gen.imports["encoding/json"] = true
+		return "json.RawMessage"
+	}
+
+	if !typ.NonNull && !strings.HasPrefix(name, "[]") {
+		return "*" + name
+	}
+	return name
+}
+
+// comment writes description as a Go comment, or dflt if there's no
+// description.
+func (gen *goClient) comment(description, dflt string) {
+	if description == "" {
+		description = dflt
+	}
+	if description == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(description), "\n") {
+		fmt.Fprintf(&gen.body, "// %s\n", strings.TrimSpace(line))
+	}
+}
+
+// goName returns GraphQL name as an exported Go name, e.g. "ID" for "id",
+// "AuthorURL" for "author_url" and "FieldDefinition" for "FIELD_DEFINITION".
+func goName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == '_' })
+	var res strings.Builder
+	for _, word := range words {
+		if strings.ToUpper(word) == word {
+			word = strings.ToLower(word)
+		}
+		word = string(unicode.ToUpper(rune(word[0]))) + word[1:]
+		for lower, upper := range goInitialisms {
+			if strings.HasSuffix(word, lower) {
+				word = strings.TrimSuffix(word, lower) + upper
+			}
+		}
+		res.WriteString(word)
+	}
+	if res.Len() == 0 {
+		return "X"
+	}
+	return res.String()
+}
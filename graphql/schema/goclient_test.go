@@ -0,0 +1,126 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoClient(t *testing.T) {
+	handler, err := NewHandler(`
+		type Author { id: ID! name: String! @search(by: [hash]) dob: DateTime posts: [Post] }
+		type Post { id: ID! title: String! kind: Kind }
+		enum Kind { NEWS LONG_READ }`)
+	require.NoError(t, err)
+
+	src, err := handler.GoClient("blog")
+	require.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "blog.go", src, parser.AllErrors)
+	require.NoError(t, err)
+
+	code := string(src)
+	for _, snippet := range []string{
+		"package blog\n",
+		"\"time\"",
+		"type Author struct {\n\tID    string     `json:\"id\"`\n" +
+			"\tName  string     `json:\"name\"`\n\tDob   *time.Time `json:\"dob,omitempty\"`\n" +
+			"\tPosts []*Post    `json:\"posts,omitempty\"`\n}",
+		"const AuthorFields = \"{ id name dob }\"",
+		"const AddAuthorPayloadFields = \"{ author { id name dob } }\"",
+		"type Kind string",
+		"KindLongRead Kind = \"LONG_READ\"",
+		"type GetAuthorArgs struct {\n\tID string\n}",
+		"type QueryAuthorResponse struct {\n\tQueryAuthor []*Author `json:\"queryAuthor\"`\n}",
+		"func GetAuthor(args GetAuthorArgs, selection string) *GraphQLRequest {",
+		"query := \"query GetAuthor($id: ID!) { getAuthor(id: $id) \" + selection + \" }\"",
+		"query := \"mutation DeletePost($filter: PostFilter!, $condition: PostFilter) { " +
+			"deletePost(filter: $filter, condition: $condition) \" + selection + \" }\"",
+	} {
+		require.Contains(t, code, snippet)
+	}
+}
+
+// TestGoClientCompiles checks that the client of each of the schemagen
+// inputs type-checks.
+func TestGoClientCompiles(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/schemagen/input/*.graphql")
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	fset := token.NewFileSet()
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			sdl, err := ioutil.ReadFile(input)
+			require.NoError(t, err)
+			handler, err := NewHandler(string(sdl))
+			require.NoError(t, err)
+			src, err := handler.GoClient("client")
+			require.NoError(t, err)
+
+			file, err := parser.ParseFile(fset, "client.go", src, parser.AllErrors)
+			require.NoError(t, err)
+			_, err = conf.Check("client", fset, []*ast.File{file}, nil)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGoClientNameClashes(t *testing.T) {
+	sdl, err := ioutil.ReadFile("testdata/schemagen/input/naming.graphql")
+	require.NoError(t, err)
+	handler, err := NewHandler(string(sdl))
+	require.NoError(t, err)
+	src, err := handler.GoClient("client")
+	require.NoError(t, err)
+
+	// The query country is named like type Country, but query countries
+	// isn't named like anything.
+	code := string(src)
+	for _, snippet := range []string{
+		"type Country struct {",
+		"func CountryQuery(args CountryQueryArgs, selection string) *GraphQLRequest {",
+		"type CountryQueryResponse struct {\n\tCountryQuery *Country `json:\"country\"`\n}",
+		"func Countries(args CountriesArgs, selection string) *GraphQLRequest {",
+	} {
+		require.Contains(t, code, snippet)
+	}
+	require.False(t, strings.Contains(code, "func Country("))
+}
+
+func TestGoName(t *testing.T) {
+	for name, want := range map[string]string{
+		"id":               "ID",
+		"authorId":         "AuthorID",
+		"author_url":       "AuthorURL",
+		"FIELD_DEFINITION": "FieldDefinition",
+		"numUids":          "NumUids",
+		"_":                "X",
+	} {
+		require.Equal(t, want, goName(name), name)
+	}
+}
@@ -0,0 +1,1077 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/parser"
+)
+
+const (
+	inverseDirective  = "hasInverse"
+	inverseArg        = "field"
+	inverseReverseArg = "reverse"
+
+	searchDirective = "search"
+	searchArgs      = "by"
+
+	privateDirective   = "private"
+	privateWritableArg = "writable"
+
+	customDirective = "custom"
+	customHTTPArg   = "http"
+
+	lambdaDirective = "lambda"
+
+	remoteDirective = "remote"
+
+	onErrorDirective = "onError"
+	onErrorPolicyArg = "policy"
+
+	keyDirective      = "key"
+	keyFieldsArg      = "fields"
+	externalDirective = "external"
+	extendsDirective  = "extends"
+
+	cascadeDirective = "cascade"
+	cascadeFieldsArg = "fields"
+
+	cacheControlDirective = "cacheControl"
+	cacheControlMaxAgeArg = "maxAge"
+	cacheControlScopeArg  = "scope"
+
+	generateDirective       = "generate"
+	generateQueryArg        = "query"
+	generateMutationArg     = "mutation"
+	generateSubscriptionArg = "subscription"
+
+	apiDirective = "api"
+	apiNameArg   = "name"
+	apiPluralArg = "plural"
+	apiNamingArg = "naming"
+
+	dgraphDirective = "dgraph"
+	dgraphPredArg   = "pred"
+
+	embeddingDirective = "embedding"
+
+	defaultDirective = "default"
+	defaultAddArg    = "add"
+	defaultUpdateArg = "update"
+	defaultValueArg  = "value"
+
+	computedDirective = "computed"
+	computedExprArg   = "expr"
+
+	edgeDirective   = "edge"
+	facetsDirective = "facets"
+	facetsTypeArg   = "type"
+
+	onDeleteDirective = "onDelete"
+	onDeleteActionArg = "action"
+
+	softDeleteDirective = "softDelete"
+
+	lambdaOnMutateDirective = "lambdaOnMutate"
+	lambdaOnMutateAddArg    = "add"
+	lambdaOnMutateUpdateArg = "update"
+	lambdaOnMutateDeleteArg = "delete"
+	lambdaOnMutateURLArg    = "url"
+
+	versionDirective = "version"
+
+	atomicDirective = "atomic"
+
+	deferDirective        = "defer"
+	streamDirective       = "stream"
+	incrementalIfArg      = "if"
+	incrementalLabelArg   = "label"
+	streamInitialCountArg = "initialCount"
+
+	xidDirective = "xid"
+
+	uploadDirective = "upload"
+
+	// schemaExtras is everything that we add to a user's schema to make it a
+	// valid GraphQL schema for Dgraph: the extra scalars, our directives and
+	// the filters that those directives imply.
+	schemaExtras = `
+scalar DateTime
+scalar Float32Vector
+scalar Upload
+scalar _Any
+scalar _FieldSet
+
+enum DgraphIndex {
+	int
+	float
+	bool
+	hash
+	exact
+	term
+	trigram
+	fulltext
+	year
+	month
+	day
+	hour
+}
+
+enum HTTPMethod {
+	GET
+	POST
+	PUT
+	PATCH
+	DELETE
+}
+
+enum ErrorPolicy {
+	NULL_FIELD
+	ABORT_OBJECT
+}
+
+enum CacheControlScope {
+	PUBLIC
+	PRIVATE
+}
+
+enum APINaming {
+	PREFIXED
+	PLURAL
+}
+
+enum DeleteAction {
+	CASCADE
+	DISCONNECT
+	RESTRICT
+}
+
+enum CustomMode {
+	SINGLE
+	BATCH
+}
+
+input DgraphDefault {
+	value: String
+}
+
+input GenerateQueryParams {
+	get: Boolean
+	query: Boolean
+}
+
+input GenerateMutationParams {
+	add: Boolean
+	update: Boolean
+	delete: Boolean
+	nonNullPayload: Boolean
+}
+
+input CustomHTTP {
+	url: String!
+	method: HTTPMethod!
+	body: String
+	headers: [String!]
+	graphql: String
+	mode: CustomMode
+	signer: String
+	policy: CustomPolicy
+}
+
+input CustomPolicy {
+	timeout: String
+	maxRetries: Int
+	breakerThreshold: Int
+	breakerCooldown: String
+}
+
+directive @hasInverse(field: String!, reverse: Boolean) on FIELD_DEFINITION
+directive @search(by: [DgraphIndex!]) on FIELD_DEFINITION
+directive @auth(query: String, add: String, update: String, delete: String, rule: String, roles: [String!], claim: String) on OBJECT | FIELD_DEFINITION
+directive @private(writable: Boolean = true) on FIELD_DEFINITION
+directive @custom(http: CustomHTTP!) on FIELD_DEFINITION
+directive @lambda on FIELD_DEFINITION
+directive @remote on OBJECT
+directive @onError(policy: ErrorPolicy!) on OBJECT | FIELD_DEFINITION
+directive @key(fields: _FieldSet!) on OBJECT
+directive @external on FIELD_DEFINITION
+directive @extends on OBJECT
+directive @cascade(fields: [String!]) on FIELD
+directive @cacheControl(maxAge: Int, scope: CacheControlScope) on OBJECT | INTERFACE | FIELD_DEFINITION
+directive @api(name: String, plural: String, naming: APINaming) on SCHEMA | OBJECT
+directive @generate(query: GenerateQueryParams, mutation: GenerateMutationParams, subscription: Boolean) on OBJECT
+directive @dgraph(pred: String!) on FIELD_DEFINITION
+directive @embedding on FIELD_DEFINITION
+directive @default(add: DgraphDefault, update: DgraphDefault) on FIELD_DEFINITION
+directive @computed(expr: String!) on FIELD_DEFINITION
+directive @edge on OBJECT
+directive @facets(type: String!) on FIELD_DEFINITION
+directive @onDelete(action: DeleteAction!) on FIELD_DEFINITION
+directive @softDelete on OBJECT
+directive @lambdaOnMutate(add: Boolean, update: Boolean, delete: Boolean, url: String) on OBJECT
+directive @version on FIELD_DEFINITION
+directive @atomic on MUTATION
+directive @defer(if: Boolean = true, label: String) on FRAGMENT_SPREAD | INLINE_FRAGMENT
+directive @stream(if: Boolean = true, label: String, initialCount: Int = 0) on FIELD
+directive @xid on FIELD_DEFINITION
+directive @upload on FIELD_DEFINITION
+
+input IntFilter {
+	eq: Int
+	le: Int
+	lt: Int
+	ge: Int
+	gt: Int
+	between: IntRange
+}
+
+input IntRange {
+	min: Int!
+	max: Int!
+}
+
+input FloatFilter {
+	eq: Float
+	le: Float
+	lt: Float
+	ge: Float
+	gt: Float
+	between: FloatRange
+}
+
+input FloatRange {
+	min: Float!
+	max: Float!
+}
+
+input DateTimeFilter {
+	eq: DateTime
+	le: DateTime
+	lt: DateTime
+	ge: DateTime
+	gt: DateTime
+	between: DateTimeRange
+}
+
+input DateTimeRange {
+	min: DateTime!
+	max: DateTime!
+}
+
+input StringTermFilter {
+	allofterms: String
+	anyofterms: String
+}
+
+input StringRegExpFilter {
+	regexp: String
+	eqIgnoreCase: String
+}
+
+input StringFullTextFilter {
+	alloftext: String
+	anyoftext: String
+}
+
+input StringExactFilter {
+	eq: String
+	le: String
+	lt: String
+	ge: String
+	gt: String
+	between: StringRange
+	in: [String!]
+}
+
+input StringRange {
+	min: String!
+	max: String!
+}
+
+input StringHashFilter {
+	eq: String
+	in: [String!]
+}
+`
+)
+
+// supportedSearches maps the GraphQL scalars to the indexes that can be
+// used with @search on fields of that scalar type.  The first index listed is
+// the one used when @search is given without arguments.
+var supportedSearches = map[string][]string{
+	"Int":      {"int"},
+	"Float":    {"float"},
+	"Boolean":  {"bool"},
+	"String":   {"term", "hash", "exact", "trigram", "fulltext"},
+	"DateTime": {"year", "month", "day", "hour"},
+}
+
+// enumSearches are the indexes that are allowed on enum fields.
+var enumSearches = []string{"hash", "exact"}
+
+// indexFilter is the name of the filter input type for an index.  The
+// DateTime indexes all filter the same way, as do the numeric indexes.
+//
+// Matching by term ignores case, because the term index does.  The hash and
+// exact indexes are case sensitive, so a string is matched ignoring case with
+// eqIgnoreCase, which needs the trigram index.
+var indexFilter = map[string]string{
+	"int":      "IntFilter",
+	"float":    "FloatFilter",
+	"bool":     "Boolean",
+	"hash":     "StringHashFilter",
+	"exact":    "StringExactFilter",
+	"term":     "StringTermFilter",
+	"trigram":  "StringRegExpFilter",
+	"fulltext": "StringFullTextFilter",
+	"year":     "DateTimeFilter",
+	"month":    "DateTimeFilter",
+	"day":      "DateTimeFilter",
+	"hour":     "DateTimeFilter",
+}
+
+// orderable scalars are those that Dgraph can sort by.
+var orderable = map[string]bool{
+	"Int":      true,
+	"Float":    true,
+	"String":   true,
+	"DateTime": true,
+}
+
+// scalarToDgraph maps the GraphQL scalars to Dgraph types.
+var scalarToDgraph = map[string]string{
+	"ID":       "uid",
+	"Boolean":  "bool",
+	"Int":      "int",
+	"Float":    "float",
+	"String":   "string",
+	"DateTime": "dateTime",
+	VectorType: "string",
+}
+
+// AddScalars adds the scalars, directives and filter types that Dgraph's
+// GraphQL layer supports to doc.
+func AddScalars(doc *ast.SchemaDocument) {
+	extras, gqlErr := parser.ParseSchema(&ast.Source{Input: schemaExtras, BuiltIn: true})
+	if gqlErr != nil {
+		panic(gqlErr)
+	}
+	doc.Definitions = append(doc.Definitions, extras.Definitions...)
+	doc.Directives = append(doc.Directives, extras.Directives...)
+}
+
+// GenerateCompleteSchema generates all the required query/mutation/input types
+// for all the object types in the schema, except those that a type's @generate
+// turns off.  The queries and mutations are named by naming, unless a type's
+// @api says otherwise.  The @custom queries and mutations declared in the
+// schema's own Query and Mutation types come first.  Interfaces only get the
+// get and query queries, which find objects of any type that implements the
+// interface; objects are added, updated and deleted as their own types.
+func GenerateCompleteSchema(sch *ast.Schema, naming APINaming) {
+	generateCompleteSchema(sch, naming, nil)
+}
+
+// generateCompleteSchema is GenerateCompleteSchema, reusing the APIs in prev,
+// those of the types of an earlier version of the schema, that would be
+// generated the same again.  It returns the APIs of sch's types.
+func generateCompleteSchema(sch *ast.Schema, naming APINaming,
+	prev map[string]*typeAPI) map[string]*typeAPI {
+
+	sch.Query = &ast.Definition{
+		Kind:   ast.Object,
+		Name:   "Query",
+		Fields: customFields(sch.Query),
+	}
+
+	sch.Mutation = &ast.Definition{
+		Kind:   ast.Object,
+		Name:   "Mutation",
+		Fields: customFields(sch.Mutation),
+	}
+
+	sch.Subscription = &ast.Definition{
+		Kind:   ast.Object,
+		Name:   "Subscription",
+		Fields: make([]*ast.FieldDefinition, 0),
+	}
+
+	var generated []string
+	for _, key := range definitionNames(sch) {
+		defn := sch.Types[key]
+		if (defn.Kind == ast.Object || defn.Kind == ast.Interface) && !defn.BuiltIn &&
+			!reservedTypeNames[key] && !isRemote(defn) && !isEdge(defn) {
+			generated = append(generated, key)
+		}
+	}
+	apis := generateTypes(sch, generated, naming, prev)
+
+	for _, key := range definitionNames(sch) {
+		defn := sch.Types[key]
+		if (defn.Kind == ast.Object || defn.Kind == ast.Interface) && defn.Position != nil &&
+			!defn.BuiltIn && !reservedTypeNames[key] && !isRemote(defn) && !isEdge(defn) {
+			addRelationArgs(sch, defn)
+			addEdgeTypes(sch, defn)
+		}
+	}
+
+	addFederation(sch)
+	sch.Query.Fields = append(sch.Query.Fields, introspectionQueries()...)
+
+	sch.Types["Query"] = sch.Query
+	sch.Types["Mutation"] = sch.Mutation
+	sch.Types["Subscription"] = sch.Subscription
+	return apis
+}
+
+// generateType adds the inputs, payloads, queries, mutations and
+// subscription for defn, an object or interface type of the input schema, to
+// sch.  All it reads of sch is defn, the kinds of the types of defn's fields,
+// the built in types and which of the types that implement defn are
+// @softDelete; apiKey depends on the same.  A @softDelete type's deletedAt
+// field has to have been added already.
+func generateType(sch *ast.Schema, defn *ast.Definition, naming APINaming) {
+	gen := generationFor(defn)
+	names := namesFor(defn, naming)
+	addFilterType(sch, defn)
+	addTypeOrderable(sch, defn)
+	if defn.Kind == ast.Interface {
+		addGetQuery(sch, defn, names.get)
+		addFilterQuery(sch, defn, names.query)
+		return
+	}
+	addRefType(sch, defn)
+	if gen.add {
+		addInputType(sch, defn)
+		addAddPayloadType(sch, defn)
+	}
+	if gen.update {
+		addPatchType(sch, defn)
+		addUpdateType(sch, defn)
+		addUpdatePayloadType(sch, defn)
+	}
+	if gen.delete {
+		addDeletePayloadType(sch, defn)
+		if isSoftDelete(defn) {
+			addRestorePayloadType(sch, defn)
+		}
+	}
+	if gen.get {
+		addGetQuery(sch, defn, names.get)
+	}
+	if gen.query {
+		addFilterQuery(sch, defn, names.query)
+		addSimilarQuery(sch, defn, names.similar)
+	}
+	if gen.add {
+		addAddMutation(sch, defn, names.add)
+	}
+	if gen.update {
+		addUpdateMutation(sch, defn, names.update)
+	}
+	if gen.delete {
+		addDeleteMutation(sch, defn, names.delete)
+		if isSoftDelete(defn) {
+			addRestoreMutation(sch, defn, names.restore)
+		}
+	}
+	if gen.subscribe {
+		addSubscription(sch, defn, names.subscribe)
+	}
+}
+
+// customFields returns the fields of root, the Query or Mutation type from the
+// input schema, which can only be @custom fields.  root can be nil.  The
+// __schema and __type fields that the validator adds to Query aren't
+// included: every Query gets introspectionQueries.
+func customFields(root *ast.Definition) ast.FieldList {
+	flds := make(ast.FieldList, 0)
+	if root != nil {
+		for _, fld := range root.Fields {
+			if !strings.HasPrefix(fld.Name, "__") {
+				flds = append(flds, fld)
+			}
+		}
+	}
+	return flds
+}
+
+// definitionNames returns the names of the types in sch, sorted so that
+// generation is deterministic.
+func definitionNames(sch *ast.Schema) []string {
+	names := make([]string, 0, len(sch.Types))
+	for name := range sch.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func addFilterType(schema *ast.Schema, defn *ast.Definition) {
+	filterName := defn.Name + "Filter"
+	filter := &ast.Definition{
+		Kind: ast.InputObject,
+		Name: filterName,
+	}
+
+	if idField := idField(defn); idField != nil {
+		filter.Fields = append(filter.Fields,
+			&ast.FieldDefinition{
+				Name: idField.Name,
+				Type: ast.ListType(ast.NonNullNamedType("ID", nil), nil),
+			})
+	}
+
+	for _, fld := range defn.Fields {
+		if private, _ := isPrivate(fld); private {
+			continue
+		}
+		filterType := searchFilterType(schema, fld)
+		if filterType == "" {
+			continue
+		}
+		filter.Fields = append(filter.Fields,
+			&ast.FieldDefinition{
+				Name: fld.Name,
+				Type: ast.NamedType(filterType, nil),
+			})
+	}
+
+	if hasName := addHasFilter(schema, defn); hasName != "" {
+		filter.Fields = append(filter.Fields,
+			&ast.FieldDefinition{
+				Name: "has",
+				Type: ast.ListType(ast.NamedType(hasName, nil), nil),
+			})
+	}
+
+	for _, op := range []string{"and", "or", "not"} {
+		filter.Fields = append(filter.Fields,
+			&ast.FieldDefinition{Name: op, Type: ast.NamedType(filterName, nil)})
+	}
+
+	schema.Types[filterName] = filter
+}
+
+// addHasFilter adds THasFilter, the enum of the fields of T that are stored
+// in Dgraph, other than the ID.  A filter's has picks the objects that have
+// a value for each field it lists, which, for optional fields and edges,
+// might not be all of them.  It returns "" if T has no such fields.
+func addHasFilter(schema *ast.Schema, defn *ast.Definition) string {
+	has := &ast.Definition{Kind: ast.Enum, Name: defn.Name + "HasFilter"}
+	for _, fld := range defn.Fields {
+		if private, _ := isPrivate(fld); private || isCustom(fld) || isLambda(fld) ||
+			isIDField(defn, fld) || isReverse(fld) {
+			continue
+		}
+		has.EnumValues = append(has.EnumValues, &ast.EnumValueDefinition{Name: fld.Name})
+	}
+	if len(has.EnumValues) == 0 {
+		return ""
+	}
+	schema.Types[has.Name] = has
+	return has.Name
+}
+
+// searchFilterType returns the name of the filter input type for fld, adding
+// any required combination of filters to the schema.  It returns "" for
+// fields that can't be searched.
+func searchFilterType(schema *ast.Schema, fld *ast.FieldDefinition) string {
+	indexes := searchIndexes(schema, fld)
+	if len(indexes) == 0 {
+		return ""
+	}
+
+	if schema.Types[fld.Type.Name()].Kind == ast.Enum {
+		return addEnumFilter(schema, fld.Type.Name())
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, idx := range indexes {
+		if name := indexFilter[idx]; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+
+	// A field with more than one index gets a filter with all the operators
+	// of each, e.g. StringExactFilter_StringTermFilter.
+	sort.Strings(names)
+	combined := strings.Join(names, "_")
+	if _, ok := schema.Types[combined]; ok {
+		return combined
+	}
+
+	filter := &ast.Definition{Kind: ast.InputObject, Name: combined}
+	for _, name := range names {
+		for _, f := range schema.Types[name].Fields {
+			if filter.Fields.ForName(f.Name) == nil {
+				filter.Fields = append(filter.Fields, f)
+			}
+		}
+	}
+	schema.Types[combined] = filter
+	return combined
+}
+
+// addEnumFilter adds, if not already present, the filter for enum type
+// enumName.
+func addEnumFilter(schema *ast.Schema, enumName string) string {
+	name := enumName + "Filter"
+	if _, ok := schema.Types[name]; !ok {
+		schema.Types[name] = &ast.Definition{
+			Kind: ast.InputObject,
+			Name: name,
+			Fields: ast.FieldList{
+				{Name: "eq", Type: ast.NamedType(enumName, nil)},
+				{Name: "in", Type: ast.ListType(ast.NonNullNamedType(enumName, nil), nil)},
+			},
+		}
+	}
+	return name
+}
+
+// searchIndexes returns the Dgraph indexes for fld as determined by its
+// @search directive, or nil if the field isn't searchable.
+func searchIndexes(schema *ast.Schema, fld *ast.FieldDefinition) []string {
+	if isXid(fld) {
+		return []string{xidIndex}
+	}
+
+	search := fld.Directives.ForName(searchDirective)
+	if search == nil {
+		return nil
+	}
+
+	arg := search.Arguments.ForName(searchArgs)
+	if arg == nil || arg.Value == nil || len(arg.Value.Children) == 0 {
+		if defn := schema.Types[fld.Type.Name()]; defn != nil && defn.Kind == ast.Enum {
+			return []string{enumSearches[0]}
+		}
+		if dflt, ok := supportedSearches[fld.Type.Name()]; ok {
+			return dflt[:1]
+		}
+		return nil
+	}
+
+	indexes := make([]string, 0, len(arg.Value.Children))
+	for _, child := range arg.Value.Children {
+		indexes = append(indexes, child.Value.Raw)
+	}
+	return indexes
+}
+
+func addTypeOrderable(schema *ast.Schema, defn *ast.Definition) {
+	orderName := defn.Name + "Orderable"
+	order := &ast.Definition{
+		Kind: ast.Enum,
+		Name: orderName,
+	}
+
+	for _, fld := range defn.Fields {
+		if private, _ := isPrivate(fld); private || isCustom(fld) {
+			continue
+		}
+		if fld.Type.Elem == nil && orderable[fld.Type.Name()] {
+			order.EnumValues = append(order.EnumValues,
+				&ast.EnumValueDefinition{Name: fld.Name})
+		}
+	}
+
+	if len(order.EnumValues) == 0 {
+		return
+	}
+	schema.Types[orderName] = order
+
+	orderType := defn.Name + "Order"
+	schema.Types[orderType] = &ast.Definition{
+		Kind: ast.InputObject,
+		Name: orderType,
+		Fields: ast.FieldList{
+			&ast.FieldDefinition{Name: "asc", Type: ast.NamedType(orderName, nil)},
+			&ast.FieldDefinition{Name: "desc", Type: ast.NamedType(orderName, nil)},
+			&ast.FieldDefinition{Name: "then", Type: ast.NamedType(orderType, nil)},
+		},
+	}
+}
+
+// addRefType adds TRef, the input that other types' mutations use to link to
+// an existing T, by ID, or to add a new one.  If defn's @generate turns off
+// add, TRef can only link to existing objects, so its ID is required.
+func addRefType(schema *ast.Schema, defn *ast.Definition) {
+	refTypeName := defn.Name + "Ref"
+	refType := &ast.Definition{
+		Kind: ast.InputObject,
+		Name: refTypeName,
+	}
+
+	add := generationFor(defn).add
+	if id := idField(defn); id != nil {
+		idType := ast.NonNullNamedType("ID", nil)
+		if add {
+			idType = ast.NamedType("ID", nil)
+		}
+		refType.Fields = append(refType.Fields,
+			&ast.FieldDefinition{Name: id.Name, Type: idType})
+	}
+	if add {
+		refType.Fields = append(refType.Fields, getNonIDFields(schema, defn, false)...)
+	}
+
+	schema.Types[refTypeName] = refType
+}
+
+func addInputType(schema *ast.Schema, defn *ast.Definition) {
+	schema.Types["Add"+defn.Name+"Input"] = &ast.Definition{
+		Kind:   ast.InputObject,
+		Name:   "Add" + defn.Name + "Input",
+		Fields: getNonIDFields(schema, defn, true),
+	}
+}
+
+func addPatchType(schema *ast.Schema, defn *ast.Definition) {
+	schema.Types[defn.Name+"Patch"] = &ast.Definition{
+		Kind:   ast.InputObject,
+		Name:   defn.Name + "Patch",
+		Fields: getNonIDFields(schema, defn, false),
+	}
+}
+
+func addUpdateType(schema *ast.Schema, defn *ast.Definition) {
+	updName := "Update" + defn.Name + "Input"
+	schema.Types[updName] = &ast.Definition{
+		Kind: ast.InputObject,
+		Name: updName,
+		Fields: ast.FieldList{
+			&ast.FieldDefinition{
+				Name: "filter",
+				Type: ast.NonNullNamedType(defn.Name+"Filter", nil),
+			},
+			&ast.FieldDefinition{
+				Name: "set",
+				Type: ast.NamedType(defn.Name+"Patch", nil),
+			},
+			&ast.FieldDefinition{
+				Name: "remove",
+				Type: ast.NamedType(defn.Name+"Patch", nil),
+			},
+			&ast.FieldDefinition{
+				Name: ConditionArgName,
+				Type: conditionType(defn),
+			},
+		},
+	}
+	if versionField(defn) != nil {
+		schema.Types[updName].Fields = append(schema.Types[updName].Fields,
+			&ast.FieldDefinition{Name: VersionArgName, Type: ast.NonNullNamedType("Int", nil)})
+	}
+}
+
+func addAddPayloadType(schema *ast.Schema, defn *ast.Definition) {
+	addPayloadType(schema, "Add"+defn.Name+"Payload", defn)
+}
+
+func addUpdatePayloadType(schema *ast.Schema, defn *ast.Definition) {
+	addPayloadType(schema, "Update"+defn.Name+"Payload", defn)
+}
+
+// addPayloadType adds the payload type name, which lists the defn objects a
+// mutation changed.  The list is [T!]! unless defn's @generate turns off
+// nonNullPayload.
+func addPayloadType(schema *ast.Schema, name string, defn *ast.Definition) {
+	typ := ast.NonNullListType(ast.NonNullNamedType(defn.Name, nil), nil)
+	if !generationFor(defn).nonNullPayload {
+		typ = ast.ListType(ast.NamedType(defn.Name, nil), nil)
+	}
+	schema.Types[name] = &ast.Definition{
+		Kind: ast.Object,
+		Name: name,
+		Fields: ast.FieldList{
+			&ast.FieldDefinition{
+				Name: lowerFirst(defn.Name),
+				Type: typ,
+			},
+		},
+	}
+}
+
+func addDeletePayloadType(schema *ast.Schema, defn *ast.Definition) {
+	schema.Types["Delete"+defn.Name+"Payload"] = &ast.Definition{
+		Kind: ast.Object,
+		Name: "Delete" + defn.Name + "Payload",
+		Fields: ast.FieldList{
+			&ast.FieldDefinition{Name: "msg", Type: ast.NamedType("String", nil)},
+		},
+	}
+}
+
+func addGetQuery(schema *ast.Schema, defn *ast.Definition, name string) {
+	id := idField(defn)
+	if id == nil {
+		return
+	}
+
+	// An object with an @xid can be found by either ID, so neither is required.
+	idType := ast.NonNullNamedType("ID", nil)
+	if xidField(defn) != nil {
+		idType = ast.NamedType("ID", nil)
+	}
+	args := ast.ArgumentDefinitionList{{Name: id.Name, Type: idType}}
+	args = append(args, xidArg(defn)...)
+	schema.Query.Fields = append(schema.Query.Fields,
+		&ast.FieldDefinition{
+			Name:      name,
+			Type:      ast.NamedType(defn.Name, nil),
+			Arguments: append(args, includeDeletedArg(schema, defn)...),
+		})
+}
+
+func addFilterQuery(schema *ast.Schema, defn *ast.Definition, name string) {
+	schema.Query.Fields = append(schema.Query.Fields, filterQuery(schema, name, defn))
+}
+
+// addSubscription adds subscribeT, which takes the same arguments as queryT
+// and gives the same answer, but again each time the answer changes.
+func addSubscription(schema *ast.Schema, defn *ast.Definition, name string) {
+	schema.Subscription.Fields = append(schema.Subscription.Fields,
+		filterQuery(schema, name, defn))
+}
+
+func filterQuery(schema *ast.Schema, name string, defn *ast.Definition) *ast.FieldDefinition {
+	qry := &ast.FieldDefinition{
+		Name: name,
+		Type: ast.ListType(ast.NamedType(defn.Name, nil), nil),
+		Arguments: ast.ArgumentDefinitionList{
+			{Name: "filter", Type: ast.NamedType(defn.Name+"Filter", nil)},
+		},
+	}
+	if _, ok := schema.Types[defn.Name+"Order"]; ok {
+		qry.Arguments = append(qry.Arguments,
+			&ast.ArgumentDefinition{Name: "order", Type: ast.NamedType(defn.Name+"Order", nil)})
+	}
+	qry.Arguments = append(qry.Arguments,
+		&ast.ArgumentDefinition{Name: "first", Type: ast.NamedType("Int", nil)},
+		&ast.ArgumentDefinition{Name: "offset", Type: ast.NamedType("Int", nil)})
+	qry.Arguments = append(qry.Arguments, includeDeletedArg(schema, defn)...)
+
+	return qry
+}
+
+// addRelationArgs adds the filter, order and pagination arguments of queryT
+// to the fields of defn that are lists of T, so, e.g., an author's posts can
+// be queried with Author.posts(filter: PostFilter, first: 10).  It runs once
+// every type's filter and order have been added, and only for the types in
+// the input schema, not the generated payloads.
+func addRelationArgs(schema *ast.Schema, defn *ast.Definition) {
+	for _, fld := range defn.Fields {
+		if fld.Type.Elem == nil || len(fld.Arguments) > 0 || isCustom(fld) || isLambda(fld) {
+			continue
+		}
+		typ := schema.Types[fld.Type.Name()]
+		if _, ok := schema.Types[fld.Type.Name()+"Filter"]; !ok || typ == nil ||
+			(typ.Kind != ast.Object && typ.Kind != ast.Interface) {
+			continue
+		}
+		fld.Arguments = filterQuery(schema, fld.Name, typ).Arguments
+	}
+}
+
+func addAddMutation(schema *ast.Schema, defn *ast.Definition, name string) {
+	schema.Mutation.Fields = append(schema.Mutation.Fields,
+		&ast.FieldDefinition{
+			Name: name,
+			Type: ast.NamedType("Add"+defn.Name+"Payload", nil),
+			Arguments: ast.ArgumentDefinitionList{
+				{
+					Name: "input",
+					Type: ast.NonNullListType(ast.NonNullNamedType("Add"+defn.Name+"Input", nil),
+						nil),
+				},
+			},
+		})
+}
+
+func addUpdateMutation(schema *ast.Schema, defn *ast.Definition, name string) {
+	schema.Mutation.Fields = append(schema.Mutation.Fields,
+		&ast.FieldDefinition{
+			Name: name,
+			Type: ast.NamedType("Update"+defn.Name+"Payload", nil),
+			Arguments: ast.ArgumentDefinitionList{
+				{Name: "input", Type: ast.NonNullNamedType("Update"+defn.Name+"Input", nil)},
+			},
+		})
+}
+
+func addDeleteMutation(schema *ast.Schema, defn *ast.Definition, name string) {
+	schema.Mutation.Fields = append(schema.Mutation.Fields,
+		&ast.FieldDefinition{
+			Name: name,
+			Type: ast.NamedType("Delete"+defn.Name+"Payload", nil),
+			Arguments: ast.ArgumentDefinitionList{
+				{Name: "filter", Type: ast.NonNullNamedType(defn.Name+"Filter", nil)},
+				{Name: ConditionArgName, Type: conditionType(defn)},
+			},
+		})
+}
+
+// getNonIDFields returns the input versions of defn's fields, skipping the ID
+// field, @custom fields and the @private fields that can't be written.  Object-typed fields
+// become references (TRef).  If keepNonNull, the nullability of scalar fields
+// is kept, otherwise every field is nullable.
+func getNonIDFields(schema *ast.Schema, defn *ast.Definition, keepNonNull bool) ast.FieldList {
+	fldList := make([]*ast.FieldDefinition, 0, len(defn.Fields))
+	for _, fld := range defn.Fields {
+		if isIDField(defn, fld) || isDeletedAt(defn, fld) || isVersion(fld) {
+			continue
+		}
+		if private, writable := isPrivate(fld); (private && !writable) || isCustom(fld) {
+			continue
+		}
+
+		// Fields that get a value when they're not added needn't be added.
+		typ := inputType(schema, fld.Type, keepNonNull && !hasAddDefault(fld))
+		if isUpload(fld) {
+			typ.NamedType = UploadType
+		}
+		fldList = append(fldList, &ast.FieldDefinition{Name: fld.Name, Type: typ})
+	}
+	return fldList
+}
+
+func inputType(schema *ast.Schema, typ *ast.Type, keepNonNull bool) *ast.Type {
+	if typ.Elem != nil {
+		return &ast.Type{
+			Elem:    inputType(schema, typ.Elem, true),
+			NonNull: typ.NonNull && keepNonNull,
+		}
+	}
+
+	name := typ.NamedType
+	if defn := schema.Types[name]; defn != nil && defn.Kind == ast.Object {
+		name += "Ref"
+	}
+	return &ast.Type{NamedType: name, NonNull: typ.NonNull && keepNonNull}
+}
+
+// isPrivate returns true if fld is @private, and so isn't part of the GraphQL
+// API's types, and whether mutations can still set it.
+func isPrivate(fld *ast.FieldDefinition) (private, writable bool) {
+	dir := fld.Directives.ForName(privateDirective)
+	if dir == nil {
+		return false, false
+	}
+	arg := dir.Arguments.ForName(privateWritableArg)
+	return true, arg == nil || arg.Value == nil || arg.Value.Raw != "false"
+}
+
+// isCustom returns true if fld is resolved by a @custom HTTP call, by the
+// lambda server or from a @computed expression, rather than being stored in
+// Dgraph.
+func isCustom(fld *ast.FieldDefinition) bool {
+	return fld.Directives.ForName(customDirective) != nil || isLambda(fld) || isComputed(fld)
+}
+
+// isReverse returns true if fld is read from Dgraph's reverse edges of the
+// predicate of its @hasInverse field, rather than being stored itself.
+func isReverse(fld *ast.FieldDefinition) bool {
+	dir := fld.Directives.ForName(inverseDirective)
+	if dir == nil {
+		return false
+	}
+	arg := dir.Arguments.ForName(inverseReverseArg)
+	return arg != nil && arg.Value != nil && arg.Value.Raw == "true"
+}
+
+// isRemote returns true if defn is a @remote type, which isn't stored in
+// Dgraph; its values only come from @custom and @lambda fields.
+func isRemote(defn *ast.Definition) bool {
+	return defn.Directives.ForName(remoteDirective) != nil
+}
+
+// isLambda returns true if fld is resolved by the lambda server.
+func isLambda(fld *ast.FieldDefinition) bool {
+	return fld.Directives.ForName(lambdaDirective) != nil
+}
+
+// A hiddenField is a @private field that's been taken out of its type.
+// Mutations can still write it, so the resolvers need to know where it's
+// stored.
+type hiddenField struct {
+	fieldDef  *ast.FieldDefinition
+	predicate string
+}
+
+// hidePrivateFields removes the @private fields from the types in sch, so
+// they can't be queried or filtered on, and returns them by type name.  It's
+// applied after the inputs and the Dgraph schema have been generated.
+func hidePrivateFields(sch *ast.Schema) map[string][]hiddenField {
+	hidden := make(map[string][]hiddenField)
+	for _, name := range definitionNames(sch) {
+		defn := sch.Types[name]
+		if defn.BuiltIn || defn.Position == nil ||
+			(defn.Kind != ast.Object && defn.Kind != ast.Interface) {
+			continue
+		}
+		for _, fld := range defn.Fields {
+			if private, _ := isPrivate(fld); private {
+				// The predicates are worked out before anything's removed,
+				// because fields inherited from an interface are found
+				// through the interface's fields.
+				hidden[name] = append(hidden[name],
+					hiddenField{fieldDef: fld, predicate: dgraphPredicate(sch, defn, fld.Name)})
+			}
+		}
+	}
+
+	for name, flds := range hidden {
+		defn := sch.Types[name]
+		visible := make(ast.FieldList, 0, len(defn.Fields)-len(flds))
+		for _, fld := range defn.Fields {
+			if private, _ := isPrivate(fld); !private {
+				visible = append(visible, fld)
+			}
+		}
+		defn.Fields = visible
+	}
+	return hidden
+}
+
+func idField(defn *ast.Definition) *ast.FieldDefinition {
+	for _, fld := range defn.Fields {
+		if isIDField(defn, fld) {
+			return fld
+		}
+	}
+	return nil
+}
+
+func isIDField(defn *ast.Definition, fld *ast.FieldDefinition) bool {
+	return fld.Type.Name() == "ID"
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,383 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// Parts of a query's result can be delivered after the rest of it, e.g.
+//
+//	query {
+//		queryPost {
+//			title
+//			... @defer(label: "comments") { comments { text } }
+//			tags @stream(initialCount: 2)
+//		}
+//	}
+//
+// gets an initial payload with each post's title and first 2 tags, and then
+// each post's comments, and the rest of its tags, in payloads of their own.
+// The directives decide how the result is delivered, not how it's read: it's
+// still resolved all together, and a client that can't take incremental
+// delivery gets it all at once.  A @defer inside a deferred fragment is
+// delivered with that fragment, as is a @stream list.
+
+// streamChunk is how many of the items of a @stream list go in each payload.
+const streamChunk = 100
+
+// An Incremental is part of a response that's delivered after its initial
+// payload: the data of a @defer fragment, or some of the items of a @stream
+// list.  Path is where it goes: the object that the fragment's fields are
+// added to, or the index in the list of the first of the items.
+type Incremental struct {
+	Data  json.RawMessage   `json:"data,omitempty"`
+	Items []json.RawMessage `json:"items,omitempty"`
+	Path  []interface{}     `json:"path"`
+	Label string            `json:"label,omitempty"`
+}
+
+// Incremental splits data, the data of a response to o, into its initial
+// payload and the parts delivered after it, as o's @defer and @stream
+// directives ask.  If o has neither, data is the initial payload and there
+// are no parts.
+func (o *operation) Incremental(data []byte) ([]byte, []*Incremental, error) {
+	if !hasIncremental(o.op.SelectionSet, make(map[string]bool)) {
+		return data, nil, nil
+	}
+	s := &splitter{vars: o.vars}
+	initial, err := s.object(data, []ast.SelectionSet{o.op.SelectionSet}, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "couldn't split the response into its payloads")
+	}
+	return initial, s.parts, nil
+}
+
+// hasIncremental returns true if there's a @defer or @stream in sel, or the
+// fragments it spreads that aren't already in seen.
+func hasIncremental(sel ast.SelectionSet, seen map[string]bool) bool {
+	for _, s := range sel {
+		switch s := s.(type) {
+		case *ast.Field:
+			if s.Directives.ForName(streamDirective) != nil ||
+				hasIncremental(s.SelectionSet, seen) {
+				return true
+			}
+		case *ast.InlineFragment:
+			if s.Directives.ForName(deferDirective) != nil ||
+				hasIncremental(s.SelectionSet, seen) {
+				return true
+			}
+		case *ast.FragmentSpread:
+			if s.Directives.ForName(deferDirective) != nil {
+				return true
+			}
+			if s.Definition != nil && !seen[s.Name] {
+				seen[s.Name] = true
+				if hasIncremental(s.Definition.SelectionSet, seen) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// validateIncremental checks o's @stream directives: they have to be on
+// lists, and can't be in subscriptions, and nor can @defer.
+func (o *operation) validateIncremental() gqlerror.List {
+	var errs gqlerror.List
+	seen := make(map[string]bool)
+	var check func(sel ast.SelectionSet)
+	check = func(sel ast.SelectionSet) {
+		for _, s := range sel {
+			var dir *ast.Directive
+			switch s := s.(type) {
+			case *ast.Field:
+				dir = s.Directives.ForName(streamDirective)
+				if dir != nil && s.Definition != nil && s.Definition.Type.Elem == nil {
+					errs = append(errs, gqlerror.ErrorPosf(dir.Position,
+						"@stream can only be used on lists, and %s isn't one.", s.Name))
+				}
+				if st := streamed(s, o.vars); st != nil && st.initialCount < 0 {
+					errs = append(errs, gqlerror.ErrorPosf(dir.Position,
+						"@stream: initialCount can't be negative."))
+				}
+				check(s.SelectionSet)
+			case *ast.InlineFragment:
+				dir = s.Directives.ForName(deferDirective)
+				check(s.SelectionSet)
+			case *ast.FragmentSpread:
+				dir = s.Directives.ForName(deferDirective)
+				if s.Definition != nil && !seen[s.Name] {
+					seen[s.Name] = true
+					check(s.Definition.SelectionSet)
+				}
+			}
+			if dir != nil && o.IsSubscription() {
+				errs = append(errs, gqlerror.ErrorPosf(dir.Position,
+					"@%s can't be used in subscriptions.", dir.Name))
+			}
+		}
+	}
+	check(o.op.SelectionSet)
+	return errs
+}
+
+// A deferral is one @defer fragment, where it applies to one object.
+type deferral struct {
+	label string
+	data  bytes.Buffer
+}
+
+// A streaming is a @stream on a list field.
+type streaming struct {
+	label        string
+	initialCount int
+}
+
+// deferred returns the deferral that a fragment with directives starts, if it
+// has a @defer that applies, in an object where in is the deferral it's
+// already in, if any.
+func deferred(directives ast.DirectiveList, vars map[string]interface{},
+	in *deferral) *deferral {
+
+	if in != nil {
+		return in
+	}
+	dir := directives.ForName(deferDirective)
+	if dir == nil {
+		return nil
+	}
+	args := dir.ArgumentMap(vars)
+	if on, ok := args[incrementalIfArg].(bool); ok && !on {
+		return nil
+	}
+	label, _ := args[incrementalLabelArg].(string)
+	return &deferral{label: label}
+}
+
+// streamed returns f's @stream, if it has one that applies.
+func streamed(f *ast.Field, vars map[string]interface{}) *streaming {
+	dir := f.Directives.ForName(streamDirective)
+	if dir == nil {
+		return nil
+	}
+	args := dir.ArgumentMap(vars)
+	if on, ok := args[incrementalIfArg].(bool); ok && !on {
+		return nil
+	}
+	st := &streaming{}
+	st.label, _ = args[incrementalLabelArg].(string)
+	if count, ok := asInt(args[streamInitialCountArg]); ok {
+		st.initialCount = int(count)
+	}
+	return st
+}
+
+// A selected is what an object's selection sets select under one response
+// name.
+type selected struct {
+	// sels are the selection sets of the fields, to be merged.
+	sels []ast.SelectionSet
+
+	// immediate is true if it's selected outside of any @defer fragment, and
+	// then stream is its @stream, if it has one.  Otherwise, it's delivered
+	// with the deferral that first selects it.
+	immediate bool
+	stream    *streaming
+	deferral  *deferral
+}
+
+// A splitter splits the data of a response into its payloads.
+type splitter struct {
+	vars  map[string]interface{}
+	parts []*Incremental
+}
+
+// collect adds what sel selects to names, keyed by response name, with the
+// names in the order they're first selected in order.  in is the deferral
+// that sel is part of, if any.
+func (s *splitter) collect(sel ast.SelectionSet, in *deferral, names map[string]*selected,
+	order *[]string, deferrals *[]*deferral) {
+
+	for _, sl := range sel {
+		switch sl := sl.(type) {
+		case *ast.Field:
+			if !included(sl.Directives, s.vars) {
+				continue
+			}
+			name := responseName(sl)
+			n := names[name]
+			if n == nil {
+				n = &selected{}
+				names[name] = n
+				*order = append(*order, name)
+			}
+			n.sels = append(n.sels, sl.SelectionSet)
+			switch {
+			case in == nil && !n.immediate:
+				n.immediate, n.stream = true, streamed(sl, s.vars)
+			case in != nil && n.deferral == nil:
+				n.deferral = in
+			}
+		case *ast.InlineFragment:
+			if included(sl.Directives, s.vars) {
+				d := deferred(sl.Directives, s.vars, in)
+				if d != nil && d != in {
+					*deferrals = append(*deferrals, d)
+				}
+				s.collect(sl.SelectionSet, d, names, order, deferrals)
+			}
+		case *ast.FragmentSpread:
+			if sl.Definition != nil && included(sl.Directives, s.vars) {
+				d := deferred(sl.Directives, s.vars, in)
+				if d != nil && d != in {
+					*deferrals = append(*deferrals, d)
+				}
+				s.collect(sl.Definition.SelectionSet, d, names, order, deferrals)
+			}
+		}
+	}
+}
+
+// object splits raw, the JSON of an object at path that sels select from.
+// It returns the object's initial payload, and adds its deferred fragments,
+// and any parts of the objects and lists in it, to s's parts.
+func (s *splitter) object(raw []byte, sels []ast.SelectionSet,
+	path []interface{}) ([]byte, error) {
+
+	names := make(map[string]*selected)
+	var order []string
+	var deferrals []*deferral
+	for _, sel := range sels {
+		s.collect(sel, nil, names, &order, &deferrals)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return nil, err
+		}
+
+		n := names[key]
+		if n != nil && !n.immediate {
+			writeMember(&n.deferral.data, key, val)
+			continue
+		}
+		if n != nil {
+			val, err = s.value(val, n.sels, n.stream, appendPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+		}
+		writeMember(&out, key, val)
+	}
+	out.WriteByte('}')
+
+	for _, d := range deferrals {
+		if d.data.Len() == 0 {
+			continue
+		}
+		d.data.WriteByte('}')
+		s.parts = append(s.parts, &Incremental{
+			Data:  d.data.Bytes(),
+			Path:  appendPath(path),
+			Label: d.label,
+		})
+	}
+	return out.Bytes(), nil
+}
+
+// value splits raw, the JSON of the value at path that sels select from.  If
+// it's a list, stream is its @stream, if it has one.
+func (s *splitter) value(raw json.RawMessage, sels []ast.SelectionSet, stream *streaming,
+	path []interface{}) (json.RawMessage, error) {
+
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return raw, nil
+	}
+	switch trimmed[0] {
+	case '{':
+		return s.object(trimmed, sels, path)
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+		keep := len(items)
+		if stream != nil && stream.initialCount < keep {
+			keep = stream.initialCount
+		}
+		for i := range items[:keep] {
+			var err error
+			items[i], err = s.value(items[i], sels, nil, appendPath(path, i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		for from := keep; from < len(items); from += streamChunk {
+			to := from + streamChunk
+			if to > len(items) {
+				to = len(items)
+			}
+			s.parts = append(s.parts, &Incremental{
+				Items: items[from:to],
+				Path:  appendPath(path, from),
+				Label: stream.label,
+			})
+		}
+		return json.Marshal(items[:keep])
+	}
+	return raw, nil
+}
+
+// writeMember writes key and val to buf, the JSON of an object that's still
+// being written.
+func writeMember(buf *bytes.Buffer, key string, val json.RawMessage) {
+	if buf.Len() == 0 {
+		buf.WriteByte('{')
+	} else if buf.Bytes()[buf.Len()-1] != '{' {
+		buf.WriteByte(',')
+	}
+	name, _ := json.Marshal(key)
+	buf.Write(name)
+	buf.WriteByte(':')
+	buf.Write(val)
+}
+
+// appendPath returns a copy of path with elems added.
+func appendPath(path []interface{}, elems ...interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(path)+len(elems)), path...), elems...)
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncremental(t *testing.T) {
+	handler, err := NewHandler(metadataSchema)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		query   string
+		vars    map[string]interface{}
+		data    string
+		initial string
+		parts   string
+	}{
+		"no directives": {
+			query:   `query { queryHuman { name } }`,
+			data:    `{"queryHuman":[{"name":"Han"}]}`,
+			initial: `{"queryHuman":[{"name":"Han"}]}`,
+			parts:   `null`,
+		},
+		"defer": {
+			query: `query {
+				queryHuman {
+					name
+					... @defer(label: "ships") { starships { shipID } }
+				}
+			}`,
+			data:    `{"queryHuman":[{"name":"Han","starships":[{"shipID":"0x1"}]},{"name":"Leia"}]}`,
+			initial: `{"queryHuman":[{"name":"Han"},{"name":"Leia"}]}`,
+			parts: `[{"data":{"starships":[{"shipID":"0x1"}]},` +
+				`"path":["queryHuman",0],"label":"ships"}]`,
+		},
+		"fields selected outside the fragment aren't deferred": {
+			query: `query {
+				queryHuman {
+					name
+					...HumanShips @defer
+				}
+			}
+			fragment HumanShips on Human { name starships { shipID } }`,
+			data:    `{"queryHuman":[{"name":"Han","starships":[]}]}`,
+			initial: `{"queryHuman":[{"name":"Han"}]}`,
+			parts:   `[{"data":{"starships":[]},"path":["queryHuman",0]}]`,
+		},
+		"defer if false": {
+			query: `query ($d: Boolean!) {
+				queryHuman { name ... @defer(if: $d) { starships { shipID } } }
+			}`,
+			vars:    map[string]interface{}{"d": false},
+			data:    `{"queryHuman":[{"name":"Han","starships":[]}]}`,
+			initial: `{"queryHuman":[{"name":"Han","starships":[]}]}`,
+			parts:   `null`,
+		},
+		"nested defer is delivered with the outer fragment": {
+			query: `query {
+				queryHuman {
+					... @defer {
+						name
+						... @defer { starships { shipID } }
+					}
+				}
+			}`,
+			data:    `{"queryHuman":[{"name":"Han","starships":[]}]}`,
+			initial: `{"queryHuman":[{}]}`,
+			parts:   `[{"data":{"name":"Han","starships":[]},"path":["queryHuman",0]}]`,
+		},
+		"stream": {
+			query: `query {
+				queryStarship @stream(initialCount: 1, label: "ships") {
+					shipID
+					crew @stream { name }
+				}
+			}`,
+			data: `{"queryStarship":[{"shipID":"0x1","crew":[{"name":"Han"}]},` +
+				`{"shipID":"0x2","crew":[]}]}`,
+			initial: `{"queryStarship":[{"shipID":"0x1","crew":[]}]}`,
+			parts: `[{"items":[{"name":"Han"}],"path":["queryStarship",0,"crew",0]},` +
+				`{"items":[{"shipID":"0x2","crew":[]}],"path":["queryStarship",1],` +
+				`"label":"ships"}]`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			op, err := handler.Schema().Operation(
+				&Request{Query: test.query, Variables: test.vars})
+			require.NoError(t, err)
+
+			initial, parts, err := op.Incremental([]byte(test.data))
+			require.NoError(t, err)
+			require.JSONEq(t, test.initial, string(initial))
+			got, err := json.Marshal(parts)
+			require.NoError(t, err)
+			require.JSONEq(t, test.parts, string(got))
+		})
+	}
+}
+
+func TestIncrementalStreamChunks(t *testing.T) {
+	handler, err := NewHandler(metadataSchema)
+	require.NoError(t, err)
+	op, err := handler.Schema().Operation(
+		&Request{Query: `query { queryHuman @stream { name } }`})
+	require.NoError(t, err)
+
+	var humans []map[string]interface{}
+	for i := 0; i < streamChunk+1; i++ {
+		humans = append(humans, map[string]interface{}{"name": "Han"})
+	}
+	data, err := json.Marshal(map[string]interface{}{"queryHuman": humans})
+	require.NoError(t, err)
+
+	initial, parts, err := op.Incremental(data)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"queryHuman":[]}`, string(initial))
+	require.Len(t, parts, 2)
+	require.Len(t, parts[0].Items, streamChunk)
+	require.Equal(t, []interface{}{"queryHuman", streamChunk}, parts[1].Path)
+	require.Len(t, parts[1].Items, 1)
+}
+
+func TestIncrementalValidation(t *testing.T) {
+	handler, err := NewHandler(metadataSchema)
+	require.NoError(t, err)
+
+	tests := []struct {
+		query  string
+		errMsg string
+	}{
+		{
+			query:  `query { queryHuman { name @stream } }`,
+			errMsg: "@stream can only be used on lists, and name isn't one.",
+		},
+		{
+			query:  `query { queryHuman @stream(initialCount: -1) { name } }`,
+			errMsg: "@stream: initialCount can't be negative.",
+		},
+		{
+			query:  `subscription { subscribeHuman { ... @defer { name } } }`,
+			errMsg: "@defer can't be used in subscriptions.",
+		},
+		{
+			query:  `query { queryHuman { name } ... @stream { __typename } }`,
+			errMsg: `Directive "stream" may not be used on INLINE_FRAGMENT.`,
+		},
+	}
+	for _, test := range tests {
+		_, err := handler.Schema().Operation(&Request{Query: test.query})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), test.errMsg)
+	}
+}
@@ -0,0 +1,394 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+)
+
+// Introspection is answered straight from the schema, without going to
+// Dgraph.  The introspection types (__Schema, __Type, etc.) are in the
+// gqlparser prelude; this file writes the values of those types, following
+// the selection sets of the query - including fragments, which introspection
+// queries from tools like GraphiQL use heavily.
+
+const (
+	schemaQuery = "__schema"
+	typeQuery   = "__type"
+
+	deprecatedDirective = "deprecated"
+	defaultDeprecation  = "No longer supported"
+)
+
+// introspectionQueries are added to the Query type of every generated schema.
+func introspectionQueries() []*ast.FieldDefinition {
+	return []*ast.FieldDefinition{
+		{
+			Name: schemaQuery,
+			Type: ast.NonNullNamedType("__Schema", nil),
+		},
+		{
+			Name: typeQuery,
+			Type: ast.NamedType("__Type", nil),
+			Arguments: ast.ArgumentDefinitionList{
+				{Name: "name", Type: ast.NonNullNamedType("String", nil)},
+			},
+		},
+	}
+}
+
+type introspector struct {
+	schema *ast.Schema
+	vars   map[string]interface{}
+	buf    bytes.Buffer
+}
+
+// Introspect answers an introspection query (one with QueryType
+// SchemaQuery), giving the JSON value of the query's result.  That's
+// __schema, __type, or __typename at the root of a query or subscription,
+// which is the name of the root operation type.
+func Introspect(q Query) ([]byte, error) {
+	qry, ok := q.(*query)
+	if !ok || q.QueryType() != SchemaQuery {
+		return nil, errors.Errorf("%s is not an introspection query", q.Name())
+	}
+
+	in := &introspector{schema: qry.op.inSchema.schema, vars: qry.op.vars}
+	switch qry.field.Name {
+	case schemaQuery:
+		in.writeSchema(qry.field.SelectionSet)
+	case typeQuery:
+		name, _ := qry.field.ArgumentMap(qry.op.vars)["name"].(string)
+		if in.schema.Types[name] == nil {
+			in.buf.WriteString("null")
+		} else {
+			in.writeType(ast.NamedType(name, nil), qry.field.SelectionSet)
+		}
+	case "__typename":
+		in.writeString(qry.field.ObjectDefinition.Name)
+	default:
+		return nil, errors.Errorf("%s is not an introspection query", q.Name())
+	}
+	return in.buf.Bytes(), nil
+}
+
+// writeObject writes an object of type typ, getting the value of each field
+// with write.  write returns false if the field isn't known for typ.
+func (in *introspector) writeObject(typ string, sel ast.SelectionSet,
+	write func(f *ast.Field) bool) {
+
+	in.buf.WriteRune('{')
+	for i, f := range collectFields(in.schema, sel, typ, in.vars) {
+		if i > 0 {
+			in.buf.WriteRune(',')
+		}
+		in.buf.WriteString(strconv.Quote(responseName(f)))
+		in.buf.WriteRune(':')
+
+		if f.Name == "__typename" {
+			in.writeString(typ)
+		} else if !write(f) {
+			in.buf.WriteString("null")
+		}
+	}
+	in.buf.WriteRune('}')
+}
+
+func (in *introspector) writeSchema(sel ast.SelectionSet) {
+	in.writeObject("__Schema", sel, func(f *ast.Field) bool {
+		switch f.Name {
+		case "types":
+			names := definitionNames(in.schema)
+			in.writeList(len(names), func(i int) {
+				in.writeType(ast.NamedType(names[i], nil), f.SelectionSet)
+			})
+		case "queryType":
+			in.writeNamedType(in.schema.Query, f.SelectionSet)
+		case "mutationType":
+			in.writeNamedType(in.schema.Mutation, f.SelectionSet)
+		case "subscriptionType":
+			in.writeNamedType(in.schema.Subscription, f.SelectionSet)
+		case "directives":
+			names := make([]string, 0, len(in.schema.Directives))
+			for name := range in.schema.Directives {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			in.writeList(len(names), func(i int) {
+				in.writeDirective(in.schema.Directives[names[i]], f.SelectionSet)
+			})
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+func (in *introspector) writeNamedType(defn *ast.Definition, sel ast.SelectionSet) {
+	if defn == nil {
+		in.buf.WriteString("null")
+		return
+	}
+	in.writeType(ast.NamedType(defn.Name, nil), sel)
+}
+
+// writeType writes a __Type.  Named types are described by their
+// definitions, while non-null and list types wrap their ofType.
+func (in *introspector) writeType(typ *ast.Type, sel ast.SelectionSet) {
+	var defn *ast.Definition
+	var kind string
+	var ofType *ast.Type
+	switch {
+	case typ.NonNull:
+		kind = "NON_NULL"
+		ofType = &ast.Type{NamedType: typ.NamedType, Elem: typ.Elem}
+	case typ.Elem != nil:
+		kind = "LIST"
+		ofType = typ.Elem
+	default:
+		defn = in.schema.Types[typ.NamedType]
+		if defn == nil {
+			in.buf.WriteString("null")
+			return
+		}
+		kind = string(defn.Kind)
+	}
+
+	in.writeObject("__Type", sel, func(f *ast.Field) bool {
+		switch {
+		case f.Name == "kind":
+			in.writeString(kind)
+		case f.Name == "ofType" && ofType != nil:
+			in.writeType(ofType, f.SelectionSet)
+		case defn == nil:
+			return false
+		case f.Name == "name":
+			in.writeString(defn.Name)
+		case f.Name == "description":
+			in.writeDescription(defn.Description)
+		case f.Name == "fields" && (defn.Kind == ast.Object || defn.Kind == ast.Interface):
+			fields := in.visibleFields(defn.Fields, in.includeDeprecated(f))
+			in.writeList(len(fields), func(i int) {
+				in.writeField(fields[i], f.SelectionSet)
+			})
+		case f.Name == "interfaces" && defn.Kind == ast.Object:
+			in.writeList(len(defn.Interfaces), func(i int) {
+				in.writeType(ast.NamedType(defn.Interfaces[i], nil), f.SelectionSet)
+			})
+		case f.Name == "possibleTypes" && defn.IsAbstractType():
+			possible := in.possibleTypes(defn)
+			in.writeList(len(possible), func(i int) {
+				in.writeType(ast.NamedType(possible[i], nil), f.SelectionSet)
+			})
+		case f.Name == "enumValues" && defn.Kind == ast.Enum:
+			includeDeprecated := in.includeDeprecated(f)
+			var values []*ast.EnumValueDefinition
+			for _, v := range defn.EnumValues {
+				if includeDeprecated || v.Directives.ForName(deprecatedDirective) == nil {
+					values = append(values, v)
+				}
+			}
+			in.writeList(len(values), func(i int) {
+				in.writeEnumValue(values[i], f.SelectionSet)
+			})
+		case f.Name == "inputFields" && defn.Kind == ast.InputObject:
+			in.writeList(len(defn.Fields), func(i int) {
+				fd := defn.Fields[i]
+				in.writeInputValue(fd.Name, fd.Description, fd.Type, fd.DefaultValue,
+					f.SelectionSet)
+			})
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+func (in *introspector) writeField(fd *ast.FieldDefinition, sel ast.SelectionSet) {
+	in.writeObject("__Field", sel, func(f *ast.Field) bool {
+		switch f.Name {
+		case "name":
+			in.writeString(fd.Name)
+		case "description":
+			in.writeDescription(fd.Description)
+		case "args":
+			in.writeList(len(fd.Arguments), func(i int) {
+				arg := fd.Arguments[i]
+				in.writeInputValue(arg.Name, arg.Description, arg.Type, arg.DefaultValue,
+					f.SelectionSet)
+			})
+		case "type":
+			in.writeType(fd.Type, f.SelectionSet)
+		default:
+			return in.writeDeprecation(fd.Directives, f.Name)
+		}
+		return true
+	})
+}
+
+func (in *introspector) writeInputValue(name, description string, typ *ast.Type,
+	defaultValue *ast.Value, sel ast.SelectionSet) {
+
+	in.writeObject("__InputValue", sel, func(f *ast.Field) bool {
+		switch f.Name {
+		case "name":
+			in.writeString(name)
+		case "description":
+			in.writeDescription(description)
+		case "type":
+			in.writeType(typ, f.SelectionSet)
+		case "defaultValue":
+			if defaultValue == nil {
+				return false
+			}
+			in.writeString(defaultValue.String())
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+func (in *introspector) writeEnumValue(ev *ast.EnumValueDefinition, sel ast.SelectionSet) {
+	in.writeObject("__EnumValue", sel, func(f *ast.Field) bool {
+		switch f.Name {
+		case "name":
+			in.writeString(ev.Name)
+		case "description":
+			in.writeDescription(ev.Description)
+		default:
+			return in.writeDeprecation(ev.Directives, f.Name)
+		}
+		return true
+	})
+}
+
+func (in *introspector) writeDirective(dir *ast.DirectiveDefinition, sel ast.SelectionSet) {
+	in.writeObject("__Directive", sel, func(f *ast.Field) bool {
+		switch f.Name {
+		case "name":
+			in.writeString(dir.Name)
+		case "description":
+			in.writeDescription(dir.Description)
+		case "locations":
+			in.writeList(len(dir.Locations), func(i int) {
+				in.writeString(string(dir.Locations[i]))
+			})
+		case "args":
+			in.writeList(len(dir.Arguments), func(i int) {
+				arg := dir.Arguments[i]
+				in.writeInputValue(arg.Name, arg.Description, arg.Type, arg.DefaultValue,
+					f.SelectionSet)
+			})
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// writeDeprecation writes the isDeprecated or deprecationReason field of
+// something with directives dirs.
+func (in *introspector) writeDeprecation(dirs ast.DirectiveList, field string) bool {
+	dep := dirs.ForName(deprecatedDirective)
+	switch field {
+	case "isDeprecated":
+		in.buf.WriteString(strconv.FormatBool(dep != nil))
+	case "deprecationReason":
+		if dep == nil {
+			return false
+		}
+		reason := defaultDeprecation
+		if arg := dep.Arguments.ForName("reason"); arg != nil {
+			reason = arg.Value.Raw
+		}
+		in.writeString(reason)
+	default:
+		return false
+	}
+	return true
+}
+
+// visibleFields are the fields that introspection shows: the fields that
+// aren't themselves for introspection, and are either not deprecated or
+// deprecated fields were asked for.
+func (in *introspector) visibleFields(fields ast.FieldList,
+	includeDeprecated bool) []*ast.FieldDefinition {
+
+	var result []*ast.FieldDefinition
+	for _, fd := range fields {
+		if strings.HasPrefix(fd.Name, "__") {
+			continue
+		}
+		if includeDeprecated || fd.Directives.ForName(deprecatedDirective) == nil {
+			result = append(result, fd)
+		}
+	}
+	return result
+}
+
+func (in *introspector) includeDeprecated(f *ast.Field) bool {
+	include, _ := f.ArgumentMap(in.vars)["includeDeprecated"].(bool)
+	return include
+}
+
+func (in *introspector) possibleTypes(defn *ast.Definition) []string {
+	if defn.Kind == ast.Union {
+		return defn.Types
+	}
+
+	var result []string
+	for _, name := range definitionNames(in.schema) {
+		for _, iface := range in.schema.Types[name].Interfaces {
+			if iface == defn.Name {
+				result = append(result, name)
+			}
+		}
+	}
+	return result
+}
+
+func (in *introspector) writeList(n int, writeItem func(i int)) {
+	in.buf.WriteRune('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			in.buf.WriteRune(',')
+		}
+		writeItem(i)
+	}
+	in.buf.WriteRune(']')
+}
+
+func (in *introspector) writeDescription(desc string) {
+	if desc == "" {
+		in.buf.WriteString("null")
+		return
+	}
+	in.writeString(desc)
+}
+
+func (in *introspector) writeString(s string) {
+	js, _ := json.Marshal(s)
+	in.buf.Write(js)
+}
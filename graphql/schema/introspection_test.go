@@ -0,0 +1,177 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const introspectionSchema = `
+"An author of posts"
+type Author {
+	id: ID!
+	name: String!
+	nickname: String @deprecated(reason: "use name")
+	role: Role
+}
+
+enum Role {
+	ADMIN
+	USER @deprecated
+}`
+
+func introspect(t *testing.T, query string, vars map[string]interface{}) string {
+	handler, err := NewHandler(introspectionSchema)
+	require.NoError(t, err)
+
+	op, err := handler.Schema().Operation(&Request{Query: query, Variables: vars})
+	require.NoError(t, err)
+	require.Len(t, op.Queries(), 1)
+	require.Equal(t, SchemaQuery, op.Queries()[0].QueryType())
+
+	js, err := Introspect(op.Queries()[0])
+	require.NoError(t, err)
+	return string(js)
+}
+
+func TestIntrospectType(t *testing.T) {
+	got := introspect(t, `query {
+		__type(name: "Author") {
+			kind
+			name
+			description
+			interfaces { name }
+			fields {
+				name
+				type { ...TypeRef }
+			}
+			all: fields(includeDeprecated: true) {
+				name
+				isDeprecated
+				deprecationReason
+			}
+		}
+	}
+
+	fragment TypeRef on __Type {
+		kind
+		name
+		ofType { kind name }
+	}`, nil)
+
+	require.JSONEq(t, `{
+		"kind": "OBJECT",
+		"name": "Author",
+		"description": "An author of posts",
+		"interfaces": [],
+		"fields": [
+			{"name": "id", "type": {"kind": "NON_NULL", "name": null,
+				"ofType": {"kind": "SCALAR", "name": "ID"}}},
+			{"name": "name", "type": {"kind": "NON_NULL", "name": null,
+				"ofType": {"kind": "SCALAR", "name": "String"}}},
+			{"name": "role", "type": {"kind": "ENUM", "name": "Role", "ofType": null}}
+		],
+		"all": [
+			{"name": "id", "isDeprecated": false, "deprecationReason": null},
+			{"name": "name", "isDeprecated": false, "deprecationReason": null},
+			{"name": "nickname", "isDeprecated": true, "deprecationReason": "use name"},
+			{"name": "role", "isDeprecated": false, "deprecationReason": null}
+		]
+	}`, got)
+}
+
+func TestIntrospectEnum(t *testing.T) {
+	got := introspect(t, `query($all: Boolean) {
+		__type(name: "Role") {
+			enumValues(includeDeprecated: $all) {
+				name
+				... on __EnumValue { isDeprecated deprecationReason }
+			}
+		}
+	}`, map[string]interface{}{"all": true})
+
+	require.JSONEq(t, `{"enumValues": [
+		{"name": "ADMIN", "isDeprecated": false, "deprecationReason": null},
+		{"name": "USER", "isDeprecated": true, "deprecationReason": "No longer supported"}
+	]}`, got)
+}
+
+func TestIntrospectUnknownType(t *testing.T) {
+	require.Equal(t, "null", introspect(t, `query { __type(name: "Nope") { name } }`, nil))
+}
+
+func TestIntrospectRootTypename(t *testing.T) {
+	require.Equal(t, `"Query"`, introspect(t, `query { __typename }`, nil))
+}
+
+func TestIntrospectSchema(t *testing.T) {
+	got := introspect(t, `query {
+		__schema {
+			__typename
+			queryType { name }
+			mutationType { name }
+			subscriptionType { name }
+			directives { name locations args { name defaultValue } }
+			types { name }
+		}
+	}`, nil)
+
+	var res struct {
+		Typename     string `json:"__typename"`
+		QueryType    struct{ Name string }
+		MutationType struct{ Name string }
+		Subscription *struct{ Name string } `json:"subscriptionType"`
+		Directives   []struct {
+			Name      string
+			Locations []string
+			Args      []struct {
+				Name         string
+				DefaultValue *string
+			}
+		}
+		Types []struct{ Name string }
+	}
+	require.NoError(t, json.Unmarshal([]byte(got), &res))
+
+	require.Equal(t, "__Schema", res.Typename)
+	require.Equal(t, "Query", res.QueryType.Name)
+	require.Equal(t, "Mutation", res.MutationType.Name)
+	require.NotNil(t, res.Subscription)
+	require.Equal(t, "Subscription", res.Subscription.Name)
+
+	var types []string
+	for _, typ := range res.Types {
+		types = append(types, typ.Name)
+	}
+	require.Contains(t, types, "Author")
+	require.Contains(t, types, "AuthorFilter")
+	require.Contains(t, types, "__Schema")
+	require.Contains(t, types, "String")
+
+	for _, dir := range res.Directives {
+		if dir.Name == "deprecated" {
+			require.Equal(t, []string{"FIELD_DEFINITION", "ENUM_VALUE"}, dir.Locations)
+			require.Len(t, dir.Args, 1)
+			require.Equal(t, `"No longer supported"`, *dir.Args[0].DefaultValue)
+			return
+		}
+	}
+	t.Fatal("@deprecated wasn't in the introspected directives")
+}
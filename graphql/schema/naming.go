@@ -0,0 +1,215 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// An APINaming is a convention for naming the queries, mutations and
+// subscription generated for a type.  A schema picks one with
+//
+//	schema @api(naming: PLURAL)
+//
+// and a type can pick its own with @api(naming:).  The default is
+// PrefixedNaming.
+type APINaming string
+
+const (
+	// PrefixedNaming names them after the type, with a prefix: getPost,
+	// queryPost, addPost, updatePost, deletePost and subscribePost,
+	// querySimilarPostByEmbedding if Post has @embedding fields, and
+	// restorePost if it's @softDelete.
+	PrefixedNaming APINaming = "PREFIXED"
+
+	// PluralNaming names the queries after the type, and its plural: post and
+	// posts.  The subscription is posts too, the mutations are addPost,
+	// updatePost and deletePost, and the similarity search is similarPosts.
+	PluralNaming APINaming = "PLURAL"
+)
+
+// apiNames are the names of the queries, mutations and subscription generated
+// for a type.
+type apiNames struct {
+	get, query, add, update, delete, restore, subscribe, similar string
+}
+
+// graphqlName matches the names that GraphQL allows.
+var graphqlName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// namesFor returns the names generated for defn, in a schema whose naming is
+// naming.  A type's @api can set the name it's known by in the API, its
+// plural and its own naming:
+//
+//	type BlogPost @api(name: "Article", plural: "articles") { ... }
+//
+// gets getArticle and queryArticle, or article and articles.
+func namesFor(defn *ast.Definition, naming APINaming) apiNames {
+	name, plural := defn.Name, ""
+	if dir := defn.Directives.ForName(apiDirective); dir != nil {
+		if arg := dir.Arguments.ForName(apiNameArg); arg != nil {
+			name = arg.Value.Raw
+		}
+		if arg := dir.Arguments.ForName(apiPluralArg); arg != nil {
+			plural = arg.Value.Raw
+		}
+		if arg := dir.Arguments.ForName(apiNamingArg); arg != nil {
+			naming = APINaming(arg.Value.Raw)
+		}
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+
+	names := apiNames{
+		get:       "get" + name,
+		query:     "query" + name,
+		add:       "add" + name,
+		update:    "update" + name,
+		delete:    "delete" + name,
+		restore:   "restore" + name,
+		subscribe: "subscribe" + name,
+		similar:   "querySimilar" + name + "ByEmbedding",
+	}
+	if naming == PluralNaming {
+		if plural == "" {
+			plural = pluralize(lowerFirst(name))
+		}
+		names.get = lowerFirst(name)
+		names.query = plural
+		names.subscribe = plural
+		names.similar = "similar" + strings.ToUpper(plural[:1]) + plural[1:]
+	}
+	return names
+}
+
+// pluralize returns the English plural of name, by the common rules; @api
+// sets the plural of names the rules get wrong.
+func pluralize(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 &&
+		!strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return name[:len(name)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"),
+		strings.HasSuffix(lower, "z"), strings.HasSuffix(lower, "ch"),
+		strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	}
+	return name + "s"
+}
+
+// schemaNaming returns the naming that doc's schema definition, or schema
+// extension, picks with @api.  doc has been validated.
+func schemaNaming(doc *ast.SchemaDocument) APINaming {
+	naming := PrefixedNaming
+	for _, schm := range append(doc.Schema, doc.SchemaExtension...) {
+		if dir := schm.Directives.ForName(apiDirective); dir != nil {
+			if arg := dir.Arguments.ForName(apiNamingArg); arg != nil {
+				naming = APINaming(arg.Value.Raw)
+			}
+		}
+	}
+	return naming
+}
+
+// schemaDefinitionRule checks schm, a schema definition or extension.  The
+// operation types are generated, so it can only set the API's naming.
+func schemaDefinitionRule(schm *ast.SchemaDefinition) *gqlerror.Error {
+	if len(schm.OperationTypes) > 0 {
+		return gqlerror.ErrorPosf(schm.Position,
+			"You don't need to define the GraphQL Schema type.  That's generated for you.")
+	}
+	for _, dir := range schm.Directives {
+		if dir.Name != apiDirective {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Schema; @%s directive isn't allowed on the schema, only @api is.", dir.Name)
+		}
+		for _, arg := range dir.Arguments {
+			if arg.Name != apiNamingArg {
+				return gqlerror.ErrorPosf(dir.Position,
+					"Schema; @api can only set the naming of the whole API, not its %s.",
+					arg.Name)
+			}
+		}
+		if err := checkAPI(dir, "Schema"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apiRule checks that the @api directive of defn, if it has one, is on a type
+// that has queries and mutations generated, and has valid settings.
+func apiRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	dir := defn.Directives.ForName(apiDirective)
+	if dir == nil {
+		return nil
+	}
+	if reservedTypeNames[defn.Name] || defn.Directives.ForName(remoteDirective) != nil {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @api directive isn't allowed here.  Nothing is generated for %s, so "+
+				"there's nothing to name.", defn.Name, defn.Name)
+	}
+	return checkAPI(dir, "Type "+defn.Name)
+}
+
+func checkAPI(dir *ast.Directive, where string) *gqlerror.Error {
+	for _, argName := range []string{apiNameArg, apiPluralArg} {
+		arg := dir.Arguments.ForName(argName)
+		if arg == nil {
+			continue
+		}
+		if arg.Value.Kind != ast.StringValue || !graphqlName.MatchString(arg.Value.Raw) {
+			return gqlerror.ErrorPosf(dir.Position,
+				"%s: @api needs a %s that's a valid GraphQL name.", where, argName)
+		}
+	}
+	if arg := dir.Arguments.ForName(apiNamingArg); arg != nil {
+		if arg.Value.Kind != ast.EnumValue || (APINaming(arg.Value.Raw) != PrefixedNaming &&
+			APINaming(arg.Value.Raw) != PluralNaming) {
+			return gqlerror.ErrorPosf(dir.Position,
+				"%s: @api needs a naming of %s or %s.", where, PrefixedNaming, PluralNaming)
+		}
+	}
+	return nil
+}
+
+// checkGeneratedNames checks that the generated queries, mutations and
+// subscriptions of sch don't have the same names as each other, or as the
+// schema's own @custom queries and mutations.
+func checkGeneratedNames(sch *ast.Schema) gqlerror.List {
+	var errs gqlerror.List
+	for _, root := range []*ast.Definition{sch.Query, sch.Mutation, sch.Subscription} {
+		seen := make(map[string]bool, len(root.Fields))
+		for _, fld := range root.Fields {
+			if strings.HasPrefix(fld.Name, "__") {
+				continue
+			}
+			if seen[fld.Name] {
+				errs = append(errs, gqlerror.Errorf(
+					"%s has more than one field called %s.  Use @api to name the "+
+						"generated queries and mutations of your types differently.",
+					root.Name, fld.Name))
+			}
+			seen[fld.Name] = true
+		}
+	}
+	return errs
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluralize(t *testing.T) {
+	for name, plural := range map[string]string{
+		"post":     "posts",
+		"country":  "countries",
+		"day":      "days",
+		"address":  "addresses",
+		"box":      "boxes",
+		"match":    "matches",
+		"wish":     "wishes",
+		"blogPost": "blogPosts",
+	} {
+		require.Equal(t, plural, pluralize(name))
+	}
+}
+
+func TestMutationName(t *testing.T) {
+	handler, err := NewHandler(`
+type BlogPost @api(name: "Article") {
+	id: ID!
+	title: String!
+}
+
+type Comment @softDelete {
+	id: ID!
+	text: String!
+}
+`)
+	require.NoError(t, err)
+	sch := handler.Schema()
+	require.Equal(t, "addArticle", sch.MutationName(AddMutation, "BlogPost"))
+	require.Equal(t, "deleteComment", sch.MutationName(DeleteMutation, "Comment"))
+	require.Equal(t, "restoreComment", sch.MutationName(RestoreMutation, "Comment"))
+	require.Equal(t, "", sch.MutationName(RestoreMutation, "BlogPost"))
+	require.Equal(t, "", sch.MutationName(AddMutation, "Article"))
+}
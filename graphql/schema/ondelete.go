@@ -0,0 +1,145 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A DeleteAction says what happens to the objects that link to an object,
+// by some field, when the object is deleted.  It's set with @onDelete on the
+// field that links to it:
+//
+//	type Post {
+//		id: ID!
+//		author: Author! @onDelete(action: CASCADE)
+//		editor: Author @onDelete(action: DISCONNECT)
+//		reviewer: Author @onDelete(action: RESTRICT)
+//	}
+//
+// Deleting an author then deletes the posts they wrote, takes them off as
+// editor of any posts, and fails if they're still the reviewer of any post.
+// Without @onDelete, the edges to a deleted object are left in Dgraph,
+// unless the field has a @hasInverse.
+//
+// Dgraph finds the objects linking to a deleted object by following the
+// field's predicate backwards, so the predicate has Dgraph's @reverse.
+type DeleteAction string
+
+const (
+	// Cascade deletes the objects that link to the deleted object, and what
+	// their deletion cascades to, in turn.
+	Cascade DeleteAction = "CASCADE"
+
+	// Disconnect removes the edges to the deleted object from the objects
+	// linking to it.
+	Disconnect DeleteAction = "DISCONNECT"
+
+	// Restrict refuses to delete an object while any object links to it.
+	Restrict DeleteAction = "RESTRICT"
+)
+
+// deleteAction returns the action of fld's @onDelete, or "" if it doesn't
+// have one.
+func deleteAction(fld *ast.FieldDefinition) DeleteAction {
+	dir := fld.Directives.ForName(onDeleteDirective)
+	if dir == nil {
+		return ""
+	}
+	if arg := dir.Arguments.ForName(onDeleteActionArg); arg != nil && arg.Value != nil {
+		return DeleteAction(arg.Value.Raw)
+	}
+	return ""
+}
+
+func onDeleteRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(onDeleteDirective)
+	if dir == nil {
+		return nil
+	}
+
+	typ := doc.Definitions.ForName(field.Type.Name())
+	switch {
+	case defn.Kind != ast.Object || isRemote(defn) || isEdge(defn) ||
+		reservedTypeNames[defn.Name] || isCustom(field) || isReverse(field):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @onDelete is only allowed on fields of object types that are "+
+				"stored in Dgraph.", defn.Name, field.Name)
+	case typ == nil || (typ.Kind != ast.Object && typ.Kind != ast.Interface) ||
+		isRemote(typ) || isEdge(typ):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @onDelete is only allowed on fields that link to objects "+
+				"stored in Dgraph.", defn.Name, field.Name)
+	}
+
+	for _, iface := range defn.Interfaces {
+		if idefn := doc.Definitions.ForName(iface); idefn != nil &&
+			idefn.Fields.ForName(field.Name) != nil {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: is stored in the predicate of interface %s's field, which "+
+					"the other types implementing %s share, so it can't have @onDelete.",
+				defn.Name, field.Name, iface, iface)
+		}
+	}
+	return nil
+}
+
+// linkReferences records, for each stored type, the @onDelete fields that
+// link to it, so deleting the type's objects can act on them.
+func (s *schema) linkReferences() {
+	for _, name := range definitionNames(s.schema) {
+		if !s.stored[name] {
+			continue
+		}
+		for _, fd := range s.types[name].ordered {
+			fld := fd.(*fieldDefinition)
+			if deleteAction(fld.fieldDef) == "" {
+				continue
+			}
+			for _, possible := range fld.Type().PossibleTypes() {
+				if info := s.types[possible]; info != nil && s.stored[possible] {
+					info.referencedBy = append(info.referencedBy, fld)
+				}
+			}
+		}
+	}
+}
+
+// ReferencedBy returns the @onDelete fields that link to objects of type t.
+func (t *astType) ReferencedBy() []FieldDefinition {
+	if info := t.inSchema.types[t.Name()]; info != nil {
+		return info.referencedBy
+	}
+	return nil
+}
+
+// OnDelete returns what deleting an object that fd links to does to the
+// objects linking to it, or "" if fd doesn't have @onDelete.
+func (fd *fieldDefinition) OnDelete() DeleteAction {
+	return deleteAction(fd.fieldDef)
+}
+
+// ParentType returns the type that fd is a field of.
+func (fd *fieldDefinition) ParentType() Type {
+	return &astType{
+		typ:      &ast.Type{NamedType: fd.parentType, NonNull: true},
+		inSchema: fd.inSchema,
+	}
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/vektah/gqlparser/ast"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// maxCachedOperations is how many query documents a schema keeps parsed and
+// validated; once it has that many, the oldest are forgotten.
+const maxCachedOperations = 1000
+
+// maxCachedPlans is how many plans a cached document keeps, for all the
+// queries of its operations; once it has that many, the least recently used
+// are forgotten.
+const maxCachedPlans = 64
+
+// An operationCache keeps query documents that have been parsed and
+// validated, so that the operations that are sent again and again skip that
+// work.  The documents are keyed by the SHA-256 hash of their text, and each
+// schema has a cache of its own, so a new schema starts with an empty one.
+// What depends on a request's variables - coercing them, and checking the
+// arguments - is still done for each request.
+//
+// Operations without variables are the same every time they're sent, so the
+// plans made for their queries, like the Dgraph queries they're rewritten
+// to, are cached along with their documents; see Query.Plan.
+type operationCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*cachedOperation
+	order   [][sha256.Size]byte
+}
+
+// A cachedOperation is a parsed and validated query document, and the plans
+// made for the queries of its operations, most recently used first.
+type cachedOperation struct {
+	doc *ast.QueryDocument
+
+	mu    sync.Mutex
+	plans map[string]*list.Element
+	lru   *list.List
+}
+
+// A cachedPlan is an element of a cachedOperation's lru.
+type cachedPlan struct {
+	key  string
+	plan interface{}
+}
+
+func newOperationCache() *operationCache {
+	return &operationCache{entries: make(map[[sha256.Size]byte]*cachedOperation)}
+}
+
+// get returns the cached document for query, if there is one.
+func (c *operationCache) get(query string) (*cachedOperation, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[sha256.Sum256([]byte(query))]
+	c.mu.Unlock()
+
+	recordLookup(operationCacheLookups, ok)
+	return entry, ok
+}
+
+// put caches doc, which has been parsed from query and validated, and returns
+// its entry in the cache.
+func (c *operationCache) put(query string, doc *ast.QueryDocument) *cachedOperation {
+	key := sha256.Sum256([]byte(query))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok {
+		return entry
+	}
+	if len(c.order) >= maxCachedOperations {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	entry := &cachedOperation{doc: doc}
+	c.entries[key] = entry
+	c.order = append(c.order, key)
+	return entry
+}
+
+// flush forgets every cached document, and the plans made for it, and
+// returns how many documents there were.
+func (c *operationCache) flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.entries)
+	c.entries = make(map[[sha256.Size]byte]*cachedOperation)
+	c.order = nil
+	return n
+}
+
+// plan returns the plan cached as key, if there is one.
+func (e *cachedOperation) plan(key string) (interface{}, bool) {
+	e.mu.Lock()
+	var p interface{}
+	elem, ok := e.plans[key]
+	if ok {
+		e.lru.MoveToFront(elem)
+		p = elem.Value.(*cachedPlan).plan
+	}
+	e.mu.Unlock()
+
+	recordLookup(planCacheLookups, ok)
+	return p, ok
+}
+
+// setPlan caches p as key, forgetting the least recently used plan if e
+// already has maxCachedPlans.
+func (e *cachedOperation) setPlan(key string, p interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.plans == nil {
+		e.plans = make(map[string]*list.Element)
+		e.lru = list.New()
+	}
+	if elem, ok := e.plans[key]; ok {
+		elem.Value.(*cachedPlan).plan = p
+		e.lru.MoveToFront(elem)
+		return
+	}
+	if e.lru.Len() >= maxCachedPlans {
+		oldest := e.lru.Back()
+		delete(e.plans, oldest.Value.(*cachedPlan).key)
+		e.lru.Remove(oldest)
+	}
+	e.plans[key] = e.lru.PushFront(&cachedPlan{key: key, plan: p})
+}
+
+// Plan returns what's been cached, with SetPlan, for q and key, if there's
+// anything.  Plans are only cached for operations without variables, since
+// everything else about them is the same each time they're sent; key has to
+// distinguish whatever else the plan depends on, like the request's claims.
+func (q *query) Plan(key string) (interface{}, bool) {
+	if q.op.cached == nil {
+		return nil, false
+	}
+	return q.op.cached.plan(q.planKey(key))
+}
+
+// SetPlan caches p, a plan made for q, as key, if q's operation has no
+// variables.
+func (q *query) SetPlan(key string, p interface{}) {
+	if q.op.cached != nil {
+		q.op.cached.setPlan(q.planKey(key), p)
+	}
+}
+
+// planKey is key, for q's plans, among those of the other queries in q's
+// document.
+func (q *query) planKey(key string) string {
+	return q.op.op.Name + "\x00" + q.ResponseName() + "\x00" + key
+}
+
+// FlushOperationCache forgets the operations that s has cached, parsed and
+// validated, and the plans made for them, and returns how many there were.
+func (s *schema) FlushOperationCache() int {
+	return s.operations.flush()
+}
+
+// recordLookup counts a lookup in the cache that m measures.
+func recordLookup(m *stats.Int64Measure, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	ctx, _ := tag.New(context.Background(), tag.Upsert(resultKey, result))
+	stats.Record(ctx, m.M(1))
+}
+
+var (
+	operationCacheLookups = stats.Int64("graphql_operation_cache_lookups_total",
+		"Number of GraphQL operations looked up in the cache of parsed and validated "+
+			"operations", stats.UnitDimensionless)
+	planCacheLookups = stats.Int64("graphql_plan_cache_lookups_total",
+		"Number of plans, like rewritten Dgraph queries, looked up in the cache of "+
+			"operations without variables", stats.UnitDimensionless)
+	resultKey, _ = tag.NewKey("result")
+)
+
+func init() {
+	for _, m := range []*stats.Int64Measure{operationCacheLookups, planCacheLookups} {
+		if err := view.Register(&view.View{
+			Name:        m.Name(),
+			Measure:     m,
+			Description: m.Description(),
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{resultKey},
+		}); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationCache(t *testing.T) {
+	handler, err := NewHandler(metadataSchema)
+	require.NoError(t, err)
+	sch := handler.Schema().(*schema)
+
+	query := `query ($id: ID!) { getHuman(id: $id) { name } }`
+	_, cached := sch.operations.get(query)
+	require.False(t, cached)
+
+	op, err := sch.Operation(&Request{Query: query, Variables: map[string]interface{}{"id": "0x1"}})
+	require.NoError(t, err)
+	id, err := op.Queries()[0].IDArgValue()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), id)
+	entry, cached := sch.operations.get(query)
+	require.True(t, cached)
+
+	// The cached document is used again, with the request's own variables,
+	// which are still checked.
+	op, err = sch.Operation(&Request{Query: query, Variables: map[string]interface{}{"id": "0x2"}})
+	require.NoError(t, err)
+	id, err = op.Queries()[0].IDArgValue()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), id)
+	again, _ := sch.operations.get(query)
+	require.True(t, entry == again)
+	_, err = sch.Operation(&Request{Query: query})
+	require.Error(t, err)
+
+	// Invalid queries aren't cached.
+	_, err = sch.Operation(&Request{Query: `query { getHuman { notAField } }`})
+	require.Error(t, err)
+	_, cached = sch.operations.get(`query { getHuman { notAField } }`)
+	require.False(t, cached)
+
+	require.Equal(t, 1, sch.FlushOperationCache())
+	_, cached = sch.operations.get(query)
+	require.False(t, cached)
+}
+
+func TestPlanCache(t *testing.T) {
+	handler, err := NewHandler(metadataSchema)
+	require.NoError(t, err)
+	sch := handler.Schema()
+
+	query := `query { a: getHuman(id: "0x1") { name } b: getHuman(id: "0x2") { name } }`
+	op, err := sch.Operation(&Request{Query: query})
+	require.NoError(t, err)
+	_, ok := op.Queries()[0].Plan("k")
+	require.False(t, ok)
+	op.Queries()[0].SetPlan("k", "plan a")
+
+	// The plan is for the same query, with the same key, in the same operation.
+	op, err = sch.Operation(&Request{Query: query})
+	require.NoError(t, err)
+	plan, ok := op.Queries()[0].Plan("k")
+	require.True(t, ok)
+	require.Equal(t, "plan a", plan)
+	_, ok = op.Queries()[0].Plan("other")
+	require.False(t, ok)
+	_, ok = op.Queries()[1].Plan("k")
+	require.False(t, ok)
+
+	// Operations with variables can differ each time, so they have no plans.
+	withVars := `query ($id: ID!) { getHuman(id: $id) { name } }`
+	vars := map[string]interface{}{"id": "0x1"}
+	op, err = sch.Operation(&Request{Query: withVars, Variables: vars})
+	require.NoError(t, err)
+	op.Queries()[0].SetPlan("k", "plan")
+	op, err = sch.Operation(&Request{Query: withVars, Variables: vars})
+	require.NoError(t, err)
+	_, ok = op.Queries()[0].Plan("k")
+	require.False(t, ok)
+
+	sch.FlushOperationCache()
+	op, err = sch.Operation(&Request{Query: query})
+	require.NoError(t, err)
+	_, ok = op.Queries()[0].Plan("k")
+	require.False(t, ok)
+}
+
+func TestOperationCacheLimit(t *testing.T) {
+	cache := newOperationCache()
+	query := func(i int) string { return "query { q" + strconv.Itoa(i) + " }" }
+	for i := 0; i <= maxCachedOperations; i++ {
+		cache.put(query(i), nil)
+	}
+	_, cached := cache.get(query(0))
+	require.False(t, cached, "the oldest is forgotten")
+	_, cached = cache.get(query(maxCachedOperations))
+	require.True(t, cached)
+	require.Equal(t, maxCachedOperations, cache.flush())
+}
+
+func TestPlanCacheLimit(t *testing.T) {
+	entry := &cachedOperation{}
+	for i := 0; i < maxCachedPlans; i++ {
+		entry.setPlan(strconv.Itoa(i), i)
+	}
+
+	// Using the oldest plan keeps it, and the next oldest is forgotten.
+	_, ok := entry.plan("0")
+	require.True(t, ok)
+	entry.setPlan("new", "plan")
+	_, ok = entry.plan("1")
+	require.False(t, ok, "the least recently used is forgotten")
+	for _, key := range []string{"0", "2", "new"} {
+		_, ok = entry.plan(key)
+		require.True(t, ok, key)
+	}
+	require.Len(t, entry.plans, maxCachedPlans)
+}
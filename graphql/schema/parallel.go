@@ -0,0 +1,213 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vektah/gqlparser/ast"
+)
+
+// minTypesPerWorker is the fewest types that a generation worker is started
+// for.  Below it, starting the worker costs more than it saves, so small
+// schemas are generated in one goroutine.
+const minTypesPerWorker = 64
+
+// generationWorkers is the most goroutines that generateTypes uses.  It's a
+// variable so that tests can check the parallel and sequential generation
+// agree.
+var generationWorkers = runtime.GOMAXPROCS(0)
+
+// A typeAPI is what generating one type of the input schema adds: the
+// definitions named after the type, like TFilter and AddTPayload, along with
+// the shared filters it uses, and its queries, mutations and subscription.
+// The definitions are shared with the schemas the API is added to, so they
+// mustn't be changed once they're generated.  key is the type's apiKey, so
+// the API can be reused for the same type of a new version of the schema if
+// it would be generated the same.
+type typeAPI struct {
+	key          string
+	types        []*ast.Definition
+	query        ast.FieldList
+	mutation     ast.FieldList
+	subscription ast.FieldList
+}
+
+// generateTypes generates the API of the types called names, which are
+// sorted, and adds it to sch.  Each type's API is generated on its own, in a
+// partial schema with just what generateType reads, so the APIs of large
+// schemas, e.g. those generated from ontologies with thousands of types, are
+// generated in parallel.  A type's API in prev is reused, rather than
+// generated again, if its key is the same.  The APIs are then added to sch in
+// the order of names, so the Query, Mutation and Subscription fields are in
+// the same order however they were generated.
+//
+// The only definitions that two types' APIs can both have are the shared
+// filters, like StringExactFilter_StringTermFilter and the enum filters,
+// which are the same whichever type adds them; the first is kept.  It returns
+// the APIs by type name.
+func generateTypes(sch *ast.Schema, names []string, naming APINaming,
+	prev map[string]*typeAPI) map[string]*typeAPI {
+
+	apis := make([]*typeAPI, len(names))
+	var todo []int
+	for _, name := range names {
+		if defn := sch.Types[name]; isSoftDelete(defn) {
+			addDeletedAt(defn)
+		}
+	}
+	for i, name := range names {
+		key := apiKey(sch, sch.Types[name], naming)
+		if api := prev[name]; api != nil && api.key == key {
+			apis[i] = api
+			continue
+		}
+		apis[i] = &typeAPI{key: key}
+		todo = append(todo, i)
+	}
+
+	base := builtinSchema(sch)
+	workers := generationWorkers
+	if most := len(todo) / minTypesPerWorker; workers > most {
+		workers = most
+	}
+	generateRun := func(run []int) {
+		part := partialSchema(base)
+		for _, i := range run {
+			apis[i].generate(part, base, sch, sch.Types[names[i]], naming)
+		}
+	}
+	if workers <= 1 {
+		generateRun(todo)
+	} else {
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			run := todo[w*len(todo)/workers : (w+1)*len(todo)/workers]
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				generateRun(run)
+			}()
+		}
+		wg.Wait()
+	}
+
+	added := make(map[string]bool)
+	byName := make(map[string]*typeAPI, len(names))
+	for i, api := range apis {
+		byName[names[i]] = api
+		for _, defn := range api.types {
+			if !added[defn.Name] {
+				added[defn.Name] = true
+				sch.Types[defn.Name] = defn
+			}
+		}
+		sch.Query.Fields = append(sch.Query.Fields, api.query...)
+		sch.Mutation.Fields = append(sch.Mutation.Fields, api.mutation...)
+		sch.Subscription.Fields = append(sch.Subscription.Fields, api.subscription...)
+	}
+	return byName
+}
+
+// generate generates api, the API of defn, a type of sch, in part, a partial
+// schema of base, which has sch's built in types.  part is left as it was, so
+// it can be used for the next type.
+func (api *typeAPI) generate(part, base, sch *ast.Schema, defn *ast.Definition,
+	naming APINaming) {
+
+	part.Types[defn.Name] = defn
+	for _, fld := range defn.Fields {
+		if typ := sch.Types[fld.Type.Name()]; typ != nil {
+			part.Types[typ.Name] = typ
+		}
+	}
+	generateType(part, defn, naming)
+
+	for name, typ := range part.Types {
+		if typ != sch.Types[name] {
+			api.types = append(api.types, typ)
+		}
+		if builtin := base.Types[name]; builtin == nil {
+			delete(part.Types, name)
+		} else if typ != builtin {
+			part.Types[name] = builtin
+		}
+	}
+	sort.Slice(api.types, func(i, j int) bool { return api.types[i].Name < api.types[j].Name })
+	api.query = part.Query.Fields
+	api.mutation = part.Mutation.Fields
+	api.subscription = part.Subscription.Fields
+	part.Query = &ast.Definition{Kind: ast.Object, Name: "Query"}
+	part.Mutation = &ast.Definition{Kind: ast.Object, Name: "Mutation"}
+	part.Subscription = &ast.Definition{Kind: ast.Object, Name: "Subscription"}
+}
+
+// apiKey identifies everything that generateType reads to generate defn's
+// API, other than the built in types, which don't change: defn, the kinds of
+// the types of its fields, which of the types that implement it are
+// @softDelete, and naming.
+func apiKey(sch *ast.Schema, defn *ast.Definition, naming APINaming) string {
+	var sb strings.Builder
+	sb.WriteString(string(naming))
+	sb.WriteString("\n")
+	newPrinter(&sb, FormatOptions{}).definition(defn)
+	for _, fld := range defn.Fields {
+		if typ := sch.Types[fld.Type.Name()]; typ != nil {
+			fmt.Fprintf(&sb, "%s %s\n", typ.Name, typ.Kind)
+		}
+	}
+	for _, possible := range sch.GetPossibleTypes(defn) {
+		fmt.Fprintf(&sb, "%s %t\n", possible.Name, isSoftDelete(possible))
+	}
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// builtinSchema returns a copy of sch with just its built in types.
+func builtinSchema(sch *ast.Schema) *ast.Schema {
+	base := *sch
+	base.Types = make(map[string]*ast.Definition)
+	for name, defn := range sch.Types {
+		if defn.BuiltIn {
+			base.Types[name] = defn
+		}
+	}
+	return &base
+}
+
+// partialSchema returns a copy of sch that generateType can add to without
+// changing sch: its type map is a copy of sch's and its root types start
+// empty.  Everything else, including the definitions themselves, is shared
+// with sch.
+func partialSchema(sch *ast.Schema) *ast.Schema {
+	part := *sch
+	part.Types = make(map[string]*ast.Definition, len(sch.Types))
+	for name, defn := range sch.Types {
+		part.Types[name] = defn
+	}
+	part.Query = &ast.Definition{Kind: ast.Object, Name: "Query"}
+	part.Mutation = &ast.Definition{Kind: ast.Object, Name: "Mutation"}
+	part.Subscription = &ast.Definition{Kind: ast.Object, Name: "Subscription"}
+	return &part
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/parser"
+	"github.com/vektah/gqlparser/validator"
+)
+
+// ontology returns a schema of n types, like those generated from
+// ontologies, that share the combined string filters and an enum filter.
+func ontology(n int) string {
+	var sb strings.Builder
+	sb.WriteString(`
+enum Status { DRAFT PUBLISHED }
+
+interface Node {
+	id: ID!
+	label: String! @search(by: [hash, term])
+}
+`)
+	for i := 0; i < n; i++ {
+		softDelete := ""
+		if i%7 == 0 {
+			softDelete = " @softDelete"
+		}
+		fmt.Fprintf(&sb, `
+type T%04d implements Node%s {
+	id: ID!
+	label: String! @search(by: [hash, term])
+	status: Status @search
+	score: Int @search
+	related: [T%04d]
+}
+`, i, softDelete, (i+1)%n)
+	}
+	return sb.String()
+}
+
+func TestParallelGeneration(t *testing.T) {
+	defer func(workers int) { generationWorkers = workers }(generationWorkers)
+	input := ontology(4 * minTypesPerWorker)
+
+	generationWorkers = 1
+	sequential, err := NewHandler(input)
+	require.NoError(t, err)
+
+	// Four workers, so the shared filters are added by all of them.
+	generationWorkers = 4
+	parallel, err := NewHandler(input)
+	require.NoError(t, err)
+
+	require.Equal(t, sequential.GQLSchema(), parallel.GQLSchema())
+	require.Equal(t, sequential.DGSchema(), parallel.DGSchema())
+}
+
+func TestPartialSchema(t *testing.T) {
+	gen, err := NewHandler(ontology(2))
+	require.NoError(t, err)
+	sch := gen.(*handler).completeSchema
+
+	part := partialSchema(sch)
+	part.Types["T0002"] = part.Types["T0000"]
+	part.Query.Fields = append(part.Query.Fields, sch.Query.Fields[0])
+
+	require.NotContains(t, sch.Types, "T0002")
+	require.True(t, part.Types["T0001"] == sch.Types["T0001"])
+	require.Len(t, part.Query.Fields, 1)
+}
+
+// inputSchema returns the validated, but not yet completed, schema for input.
+func inputSchema(b *testing.B, input string) *ast.Schema {
+	doc, gqlErr := parser.ParseSchemas(validator.Prelude, &ast.Source{Input: input})
+	require.Nil(b, gqlErr)
+	AddScalars(doc)
+	sch, gqlErr := validator.ValidateSchemaDocument(doc)
+	require.Nil(b, gqlErr)
+	return sch
+}
+
+func BenchmarkGenerateCompleteSchema(b *testing.B) {
+	defer func(workers int) { generationWorkers = workers }(generationWorkers)
+	for _, n := range []int{10, 100, 1000, 5000} {
+		input := ontology(n)
+		for _, workers := range []int{1, runtime.GOMAXPROCS(0)} {
+			b.Run(fmt.Sprintf("types=%d/workers=%d", n, workers), func(b *testing.B) {
+				generationWorkers = workers
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					sch := inputSchema(b, input)
+					b.StartTimer()
+					GenerateCompleteSchema(sch, PrefixedNaming)
+				}
+			})
+		}
+	}
+}
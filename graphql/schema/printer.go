@@ -0,0 +1,403 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/vektah/gqlparser/ast"
+)
+
+const sectionRule = "#######################\n"
+
+// extendedText is the text of the extended definitions, as Stringify prints
+// them.
+var extendedText = strings.TrimLeft(schemaExtras, "\n") + "\n"
+
+// FormatOptions say how Write prints a schema as SDL.  With only its
+// OriginalTypes set, it's printed just as Stringify prints it.
+type FormatOptions struct {
+	// OriginalTypes are the types of the input schema, which are printed
+	// first, in this order.
+	OriginalTypes []string
+
+	// Indent is what fields and enum values are indented with; "" is a tab.
+	Indent string
+
+	// OmitGenerated leaves out everything that's generated from the input
+	// schema: the extended definitions, and the generated types, inputs,
+	// queries and mutations.
+	OmitGenerated bool
+
+	// OmitDirectives leaves out the directives of types, fields, arguments
+	// and enum values, and the declarations of the extended definitions'
+	// directives.
+	OmitDirectives bool
+}
+
+// Stringify the schema as a GraphQL SDL string.  It's assumed that the schema
+// was built from a SDL schema that contained the types in originalTypes, and
+// that it has subsequently been completed by GenerateCompleteSchema.
+//
+// The output is deterministic: the input types are printed in the order given,
+// followed by the extended definitions and then the generated types, each in
+// alphabetical order.
+//
+// The schema is written in one pass, into a builder that's big enough for it
+// from the start, as it's printed whenever the admin API is asked for it.
+func Stringify(schema *ast.Schema, originalTypes []string) string {
+	if schema.Types == nil {
+		return ""
+	}
+	secs := sections(schema, FormatOptions{OriginalTypes: originalTypes})
+
+	var sch strings.Builder
+	size := 0
+	for _, sec := range secs {
+		size += len(sectionRule) + len(sec.title) + len(sec.text) + sdlSize(sec.defs) + 32
+	}
+	sch.Grow(size)
+	newPrinter(&sch, FormatOptions{}).sections(secs)
+	return sch.String()
+}
+
+// Write writes schema to w as SDL, as opts say, so that it can be streamed
+// to a file or an HTTP response without being built up in memory first.  It's
+// assumed that the schema has been completed by GenerateCompleteSchema.
+func Write(w io.Writer, schema *ast.Schema, opts FormatOptions) error {
+	if schema.Types == nil {
+		return nil
+	}
+	buf := bufio.NewWriter(w)
+	newPrinter(buf, opts).sections(sections(schema, opts))
+	return buf.Flush()
+}
+
+// An sdlSection is one of the commented sections of a printed schema: either
+// definitions, or the text of the extended definitions.
+type sdlSection struct {
+	title string
+	defs  []*ast.Definition
+	text  string
+
+	// skipIntrospection leaves out the introspection fields, of Query.
+	skipIntrospection bool
+}
+
+// sections returns the sections that schema is printed in, as opts say.
+// Sections with nothing in them are left out.
+func sections(schema *ast.Schema, opts FormatOptions) []sdlSection {
+	printed := make(map[string]bool)
+	for _, name := range []string{"Query", "Mutation", "Subscription"} {
+		printed[name] = true
+	}
+
+	var original, object, input, enum []*ast.Definition
+	for _, typName := range opts.OriginalTypes {
+		typ := schema.Types[typName]
+		// The input's Query and Mutation fields are printed with the
+		// generated ones.
+		if typ == nil || reservedTypeNames[typName] {
+			continue
+		}
+		original = append(original, typ)
+		printed[typName] = true
+	}
+
+	secs := []sdlSection{{title: "Input Schema", defs: original}}
+	if !opts.OmitGenerated {
+		for _, typName := range definitionNames(schema) {
+			typ := schema.Types[typName]
+			if printed[typName] || typ.BuiltIn {
+				continue
+			}
+			switch typ.Kind {
+			case ast.Object, ast.Interface, ast.Union, ast.Scalar:
+				object = append(object, typ)
+			case ast.InputObject:
+				input = append(input, typ)
+			case ast.Enum:
+				enum = append(enum, typ)
+			}
+		}
+
+		secs = append(secs,
+			sdlSection{title: "Extended Definitions", text: extendedDefinitions(opts)},
+			sdlSection{title: "Generated Types", defs: object},
+			sdlSection{title: "Generated Enums", defs: enum},
+			sdlSection{title: "Generated Inputs", defs: input})
+		if schema.Query != nil {
+			secs = append(secs, sdlSection{title: "Generated Query",
+				defs: []*ast.Definition{schema.Query}, skipIntrospection: true})
+		}
+		if schema.Mutation != nil {
+			secs = append(secs, sdlSection{title: "Generated Mutations",
+				defs: []*ast.Definition{schema.Mutation}})
+		}
+		if schema.Subscription != nil {
+			secs = append(secs, sdlSection{title: "Generated Subscriptions",
+				defs: []*ast.Definition{schema.Subscription}})
+		}
+	}
+
+	nonEmpty := secs[:0]
+	for _, sec := range secs {
+		if len(sec.defs) > 0 || sec.text != "" {
+			nonEmpty = append(nonEmpty, sec)
+		}
+	}
+	return nonEmpty
+}
+
+// extendedDefinitions returns the text of the extended definitions, as opts
+// say to print them.
+func extendedDefinitions(opts FormatOptions) string {
+	text := extendedText
+	if opts.OmitDirectives {
+		var kept []string
+		for _, line := range strings.Split(text, "\n") {
+			if !strings.HasPrefix(line, "directive @") {
+				kept = append(kept, line)
+			}
+		}
+		text = strings.Join(kept, "\n")
+	}
+	if opts.Indent != "" {
+		text = strings.Replace(text, "\n\t", "\n"+opts.Indent, -1)
+	}
+	return text
+}
+
+// sdlSize estimates how long the SDL of defs is, so that a builder can be
+// made big enough for it.  It's a little generous, rather than exact.
+func sdlSize(defs []*ast.Definition) int {
+	size := 0
+	for _, def := range defs {
+		size += len(def.Name) + len(def.Description) + 32 + 16*len(def.Directives)
+		for _, fld := range def.Fields {
+			size += len(fld.Name) + len(fld.Description) + 32 + 16*len(fld.Directives)
+			for _, arg := range fld.Arguments {
+				size += len(arg.Name) + 32
+			}
+		}
+		for _, val := range def.EnumValues {
+			size += len(val.Name) + len(val.Description) + 8
+		}
+	}
+	return size
+}
+
+// stringWriter is what an sdlPrinter writes to: a strings.Builder, or a
+// bufio.Writer, which keeps the first error for when it's flushed.
+type stringWriter interface {
+	WriteString(s string) (int, error)
+}
+
+// An sdlPrinter writes definitions as SDL, straight into its writer.
+type sdlPrinter struct {
+	sch            stringWriter
+	indent         string
+	omitDirectives bool
+}
+
+func newPrinter(w stringWriter, opts FormatOptions) sdlPrinter {
+	p := sdlPrinter{sch: w, indent: opts.Indent, omitDirectives: opts.OmitDirectives}
+	if p.indent == "" {
+		p.indent = "\t"
+	}
+	return p
+}
+
+func (p sdlPrinter) sections(secs []sdlSection) {
+	for _, sec := range secs {
+		p.sch.WriteString(sectionRule)
+		p.sch.WriteString("# ")
+		p.sch.WriteString(sec.title)
+		p.sch.WriteString("\n")
+		p.sch.WriteString(sectionRule)
+		p.sch.WriteString("\n")
+		p.sch.WriteString(sec.text)
+		for _, def := range sec.defs {
+			if sec.skipIntrospection {
+				p.object(def, true)
+			} else {
+				p.definition(def)
+			}
+		}
+	}
+}
+
+func (p sdlPrinter) definition(def *ast.Definition) {
+	switch def.Kind {
+	case ast.Object, ast.Interface:
+		p.object(def, false)
+	case ast.InputObject:
+		p.description(def.Description, "")
+		p.sch.WriteString("input ")
+		p.sch.WriteString(def.Name)
+		p.directives(def.Directives)
+		p.sch.WriteString(" {\n")
+		for _, fld := range def.Fields {
+			p.field(fld)
+		}
+		p.sch.WriteString("}\n\n")
+	case ast.Enum:
+		p.description(def.Description, "")
+		p.sch.WriteString("enum ")
+		p.sch.WriteString(def.Name)
+		p.directives(def.Directives)
+		p.sch.WriteString(" {\n")
+		for _, val := range def.EnumValues {
+			p.description(val.Description, p.indent)
+			p.sch.WriteString(p.indent)
+			p.sch.WriteString(val.Name)
+			p.directives(val.Directives)
+			p.sch.WriteString("\n")
+		}
+		p.sch.WriteString("}\n\n")
+	case ast.Scalar:
+		p.description(def.Description, "")
+		p.sch.WriteString("scalar ")
+		p.sch.WriteString(def.Name)
+		p.directives(def.Directives)
+		p.sch.WriteString("\n\n")
+	case ast.Union:
+		p.description(def.Description, "")
+		p.sch.WriteString("union ")
+		p.sch.WriteString(def.Name)
+		p.directives(def.Directives)
+		p.sch.WriteString(" = ")
+		p.list(def.Types, " | ")
+		p.sch.WriteString("\n\n")
+	}
+}
+
+func (p sdlPrinter) object(def *ast.Definition, skipIntrospection bool) {
+	p.description(def.Description, "")
+	if def.Kind == ast.Interface {
+		p.sch.WriteString("interface ")
+	} else {
+		p.sch.WriteString("type ")
+	}
+	p.sch.WriteString(def.Name)
+	if len(def.Interfaces) > 0 {
+		p.sch.WriteString(" implements ")
+		p.list(def.Interfaces, " & ")
+	}
+	p.directives(def.Directives)
+	p.sch.WriteString(" {\n")
+	for _, fld := range def.Fields {
+		if skipIntrospection && strings.HasPrefix(fld.Name, "__") {
+			continue
+		}
+		p.field(fld)
+	}
+	p.sch.WriteString("}\n\n")
+}
+
+func (p sdlPrinter) field(fld *ast.FieldDefinition) {
+	p.description(fld.Description, p.indent)
+	p.sch.WriteString(p.indent)
+	p.sch.WriteString(fld.Name)
+	if len(fld.Arguments) > 0 {
+		p.sch.WriteString("(")
+		for i, arg := range fld.Arguments {
+			if i > 0 {
+				p.sch.WriteString(", ")
+			}
+			p.argument(arg)
+		}
+		p.sch.WriteString(")")
+	}
+	p.sch.WriteString(": ")
+	p.typ(fld.Type)
+	if fld.DefaultValue != nil {
+		p.sch.WriteString(" = ")
+		p.sch.WriteString(fld.DefaultValue.String())
+	}
+	p.directives(fld.Directives)
+	p.sch.WriteString("\n")
+}
+
+func (p sdlPrinter) argument(arg *ast.ArgumentDefinition) {
+	p.sch.WriteString(arg.Name)
+	p.sch.WriteString(": ")
+	p.typ(arg.Type)
+	if arg.DefaultValue != nil {
+		p.sch.WriteString(" = ")
+		p.sch.WriteString(arg.DefaultValue.String())
+	}
+	p.directives(arg.Directives)
+}
+
+// typ writes t as ast.Type's String would.
+func (p sdlPrinter) typ(t *ast.Type) {
+	if t.NamedType != "" {
+		p.sch.WriteString(t.NamedType)
+	} else {
+		p.sch.WriteString("[")
+		p.typ(t.Elem)
+		p.sch.WriteString("]")
+	}
+	if t.NonNull {
+		p.sch.WriteString("!")
+	}
+}
+
+func (p sdlPrinter) directives(dirs ast.DirectiveList) {
+	if p.omitDirectives {
+		return
+	}
+	for _, dir := range dirs {
+		p.sch.WriteString(" @")
+		p.sch.WriteString(dir.Name)
+		if len(dir.Arguments) == 0 {
+			continue
+		}
+		p.sch.WriteString("(")
+		for i, arg := range dir.Arguments {
+			if i > 0 {
+				p.sch.WriteString(", ")
+			}
+			p.sch.WriteString(arg.Name)
+			p.sch.WriteString(": ")
+			p.sch.WriteString(arg.Value.String())
+		}
+		p.sch.WriteString(")")
+	}
+}
+
+func (p sdlPrinter) description(desc, indent string) {
+	if desc == "" {
+		return
+	}
+	p.sch.WriteString(indent)
+	p.sch.WriteString(`"""`)
+	p.sch.WriteString(desc)
+	p.sch.WriteString(`"""` + "\n")
+}
+
+func (p sdlPrinter) list(items []string, sep string) {
+	for i, item := range items {
+		if i > 0 {
+			p.sch.WriteString(sep)
+		}
+		p.sch.WriteString(item)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func BenchmarkStringify(b *testing.B) {
+	in, err := ioutil.ReadFile("testdata/schemagen/input/custom.graphql")
+	require.NoError(b, err)
+	gen, err := NewHandler(string(in))
+	require.NoError(b, err)
+	h := gen.(*handler)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Stringify(h.completeSchema, h.originalDefs)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	gen, err := NewHandler(`
+type Author @auth(query: { rule: "{ $ROLE: { eq: \"ADMIN\" } }" }) {
+	id: ID!
+	"""The author's name"""
+	name: String! @search(by: [hash])
+}`)
+	require.NoError(t, err)
+	h := gen.(*handler)
+
+	// By default, it's what Stringify prints.
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, h.completeSchema, FormatOptions{OriginalTypes: h.originalDefs}))
+	require.Equal(t, h.GQLSchema(), buf.String())
+
+	buf.Reset()
+	require.NoError(t, h.WriteGQLSchema(&buf, FormatOptions{
+		Indent:         "  ",
+		OmitGenerated:  true,
+		OmitDirectives: true,
+	}))
+	require.Equal(t, "#######################\n# Input Schema\n#######################\n\n"+
+		"type Author {\n"+
+		"  id: ID!\n"+
+		"  \"\"\"The author's name\"\"\"\n"+
+		"  name: String!\n"+
+		"}\n\n", buf.String())
+
+	// Without the directives, the extended definitions don't declare them.
+	buf.Reset()
+	require.NoError(t, h.WriteGQLSchema(&buf, FormatOptions{OmitDirectives: true}))
+	require.NotContains(t, buf.String(), "@")
+	require.Contains(t, buf.String(), "input StringHashFilter {")
+	require.Contains(t, buf.String(), "getAuthor(id: ID!): Author\n")
+
+	require.Error(t, h.WriteGQLSchema(failingWriter{}, FormatOptions{}))
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
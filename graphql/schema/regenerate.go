@@ -0,0 +1,132 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser/ast"
+)
+
+// A SchemaDelta is how the schema that Regenerate built differs from the one
+// it was given.
+type SchemaDelta struct {
+	// Regenerated are the types of the input schema whose API was generated
+	// again, because they changed, or something their API depends on did,
+	// like the kind of a type they have a field of.  The API of every other
+	// type was reused.
+	Regenerated []string
+
+	// Added, Changed and Removed are the generated definitions, like
+	// PostFilter and AddPostInput, that are new, different or gone.
+	Added, Changed, Removed []string
+
+	// Changes are the changes to the API, as Changelog describes them.
+	Changes []APIChange
+}
+
+// Breaking returns the changes in d that can stop requests that used to work,
+// or nil if there aren't any.
+func (d *SchemaDelta) Breaking() []APIChange {
+	var breaking []APIChange
+	for _, change := range d.Changes {
+		if change.Breaking {
+			breaking = append(breaking, change)
+		}
+	}
+	return breaking
+}
+
+// Regenerate builds the Handler for input, a new version of the schema that
+// prev was built from, and returns how it differs from prev.  Only the API of
+// the types that changed, or whose API depends on something that did, is
+// generated; the rest is reused from prev.  The Handler is the same as
+// NewHandler would build.  If prev is nil, everything is generated, and
+// everything is added.
+func Regenerate(prev Handler, input string, remotes ...RemoteAPI) (Handler, *SchemaDelta,
+	error) {
+
+	old, _ := prev.(*handler)
+	var oldAPIs map[string]*typeAPI
+	if old != nil {
+		oldAPIs = old.apis
+	}
+	h, err := newHandler(input, oldAPIs, remotes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	delta := &SchemaDelta{}
+	oldDefns := make(map[string]*ast.Definition)
+	newDefns := make(map[string]*ast.Definition)
+	for name, api := range h.apis {
+		if oldAPIs[name] == api {
+			continue
+		}
+		delta.Regenerated = append(delta.Regenerated, name)
+		for _, defn := range api.types {
+			newDefns[defn.Name] = defn
+		}
+		if oldAPI := oldAPIs[name]; oldAPI != nil {
+			for _, defn := range oldAPI.types {
+				oldDefns[defn.Name] = defn
+			}
+		}
+	}
+	for name, api := range oldAPIs {
+		if h.apis[name] == nil {
+			for _, defn := range api.types {
+				oldDefns[defn.Name] = defn
+			}
+		}
+	}
+
+	// A shared filter can be in the API of a type that was regenerated, and
+	// of one that wasn't, so whether it's new or gone is decided by the
+	// whole schema.
+	for name, defn := range newDefns {
+		oldDefn, ok := oldDefns[name]
+		switch {
+		case ok && sdl(oldDefn) != sdl(defn):
+			delta.Changed = append(delta.Changed, name)
+		case !ok && (old == nil || old.completeSchema.Types[name] == nil):
+			delta.Added = append(delta.Added, name)
+		}
+	}
+	for name := range oldDefns {
+		if newDefns[name] == nil && h.completeSchema.Types[name] == nil {
+			delta.Removed = append(delta.Removed, name)
+		}
+	}
+	sort.Strings(delta.Regenerated)
+	sort.Strings(delta.Added)
+	sort.Strings(delta.Changed)
+	sort.Strings(delta.Removed)
+
+	if old != nil {
+		delta.Changes = Changelog(old.Schema(), h.Schema())
+	}
+	return h, delta, nil
+}
+
+// sdl returns defn as it's written in a schema.
+func sdl(defn *ast.Definition) string {
+	var sb strings.Builder
+	newPrinter(&sb, FormatOptions{}).definition(defn)
+	return sb.String()
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requireSameAsFull checks that h, which Regenerate built for input, is what
+// NewHandler builds for it.
+func requireSameAsFull(t *testing.T, input string, h Handler) {
+	full, err := NewHandler(input)
+	require.NoError(t, err)
+	require.Equal(t, full.GQLSchema(), h.GQLSchema())
+	require.Equal(t, full.DGSchema(), h.DGSchema())
+}
+
+func TestRegenerateGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/schemagen/input/*.graphql")
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	var prev Handler
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".graphql")
+		t.Run(name, func(t *testing.T) {
+			in, err := ioutil.ReadFile(input)
+			require.NoError(t, err)
+
+			// From the previous input, which shares some types with this one.
+			h, _, err := Regenerate(prev, string(in))
+			require.NoError(t, err)
+			requireSameAsFull(t, string(in), h)
+
+			again, delta, err := Regenerate(h, string(in))
+			require.NoError(t, err)
+			require.Equal(t, h.GQLSchema(), again.GQLSchema())
+			require.Equal(t, &SchemaDelta{}, delta, "nothing's generated again")
+			prev = h
+		})
+	}
+}
+
+const regenerateSchema = `
+interface Node {
+	id: ID!
+}
+
+type Author implements Node {
+	id: ID!
+	name: String! @search(by: [hash])
+	posts: [Post]
+}
+
+type Post implements Node {
+	id: ID!
+	title: String! @search(by: [term])
+	author: Author
+}
+`
+
+func TestRegenerate(t *testing.T) {
+	prev, err := NewHandler(regenerateSchema)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		input    string
+		delta    SchemaDelta
+		breaking bool
+	}{
+		"a field added": {
+			input: strings.Replace(regenerateSchema, "author: Author",
+				"author: Author\n\tscore: Int @search", 1),
+			delta: SchemaDelta{
+				Regenerated: []string{"Post"},
+				Changed: []string{"AddPostInput", "PostFilter", "PostHasFilter",
+					"PostOrderable", "PostPatch", "PostRef"},
+			},
+		},
+		"a type added": {
+			input: regenerateSchema + "type Tag {\n\tname: String! @search(by: [exact])\n}\n",
+			delta: SchemaDelta{
+				Regenerated: []string{"Tag"},
+				Added: []string{"AddTagInput", "AddTagPayload", "DeleteTagPayload", "TagFilter",
+					"TagHasFilter", "TagOrder", "TagOrderable", "TagPatch", "TagRef",
+					"UpdateTagInput", "UpdateTagPayload"},
+			},
+		},
+		"a type removed": {
+			input: strings.Replace(regenerateSchema[:strings.Index(regenerateSchema, "type Post")],
+				"\tposts: [Post]\n", "", 1),
+			delta: SchemaDelta{
+				// Node's API depends on which of its implementations are
+				// @softDelete, so it's regenerated when they change.
+				Regenerated: []string{"Author", "Node"},
+				Changed:     []string{"AddAuthorInput", "AuthorHasFilter", "AuthorPatch", "AuthorRef"},
+				Removed: []string{"AddPostInput", "AddPostPayload", "DeletePostPayload",
+					"PostFilter", "PostHasFilter", "PostOrder", "PostOrderable", "PostPatch",
+					"PostRef", "UpdatePostInput", "UpdatePostPayload"},
+			},
+			breaking: true,
+		},
+		"an implementation made @softDelete": {
+			input: strings.Replace(regenerateSchema, "type Post implements Node {",
+				"type Post implements Node @softDelete {", 1),
+			delta: SchemaDelta{
+				Regenerated: []string{"Node", "Post"},
+				Added:       []string{"RestorePostPayload"},
+				Changed:     []string{"PostHasFilter", "PostOrderable"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h, delta, err := Regenerate(prev, test.input)
+			require.NoError(t, err)
+			requireSameAsFull(t, test.input, h)
+
+			require.Equal(t, test.delta.Regenerated, delta.Regenerated)
+			require.Equal(t, test.delta.Added, delta.Added)
+			require.Equal(t, test.delta.Changed, delta.Changed)
+			require.Equal(t, test.delta.Removed, delta.Removed)
+			require.Equal(t, Changelog(prev.Schema(), h.Schema()), delta.Changes)
+			require.Equal(t, test.breaking, len(delta.Breaking()) > 0)
+		})
+	}
+}
+
+func TestRegenerateOntology(t *testing.T) {
+	defer func(workers int) { generationWorkers = workers }(generationWorkers)
+	generationWorkers = 4
+	input := ontology(4 * minTypesPerWorker)
+	prev, err := NewHandler(input)
+	require.NoError(t, err)
+
+	changed := strings.Replace(input, "type T0100 implements Node {\n",
+		"type T0100 implements Node {\n\tnote: String\n", 1)
+	h, delta, err := Regenerate(prev, changed)
+	require.NoError(t, err)
+	requireSameAsFull(t, changed, h)
+	require.Equal(t, []string{"T0100"}, delta.Regenerated)
+	require.Empty(t, delta.Breaking())
+}
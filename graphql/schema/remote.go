@@ -0,0 +1,83 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// remoteTypeRule checks a @remote type.  Remote types aren't stored in
+// Dgraph - their values come from @custom and @lambda fields, often from
+// another GraphQL API - so they can't implement interfaces, which are stored,
+// or have @auth rules, which filter what's stored.
+func remoteTypeRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	dir := defn.Directives.ForName(remoteDirective)
+	if dir == nil {
+		return nil
+	}
+	if defn.Kind != ast.Object {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @remote directive is only allowed on types.", defn.Name)
+	}
+	if len(defn.Interfaces) > 0 {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s: remote types can't implement interfaces, because interfaces are "+
+				"stored in Dgraph.", defn.Name)
+	}
+	if defn.Directives.ForName(authDirective) != nil {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s: remote types aren't stored in Dgraph, so they can't have @auth rules.",
+			defn.Name)
+	}
+	return nil
+}
+
+// remoteFieldRule checks the fields that involve remote types.  A field of a
+// remote type is just a value in the remote result, so it can't have any of
+// the directives that say how a field is stored or resolved, and if it's an
+// object, that object is remote too.  A field of a stored type that is a
+// remote type can only be resolved by @custom or @lambda.
+func remoteFieldRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	typ := doc.Definitions.ForName(field.Type.Name())
+	if isRemote(defn) {
+		for _, dir := range []string{searchDirective, inverseDirective, privateDirective,
+			customDirective, lambdaDirective} {
+			if field.Directives.ForName(dir) != nil {
+				return gqlerror.ErrorPosf(field.Position,
+					"Type %s; Field %s: remote types aren't stored in Dgraph, so their "+
+						"fields can't be @%s.", defn.Name, field.Name, dir)
+			}
+		}
+		if typ != nil && (typ.Kind == ast.Object || typ.Kind == ast.Interface) &&
+			!isRemote(typ) {
+			return gqlerror.ErrorPosf(field.Position,
+				"Type %s; Field %s: remote types can only have fields of remote types, but "+
+					"%s is stored in Dgraph.", defn.Name, field.Name, typ.Name)
+		}
+		return nil
+	}
+
+	if typ != nil && isRemote(typ) && !isCustom(field) {
+		return gqlerror.ErrorPosf(field.Position,
+			"Type %s; Field %s: is of remote type %s, which isn't stored in Dgraph, so it "+
+				"must be @custom or @lambda.", defn.Name, field.Name, typ.Name)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"context"
+
+	"github.com/dgraph-io/dgraph/graphql/tracing"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+	"github.com/vektah/gqlparser/parser"
+	"github.com/vektah/gqlparser/validator"
+
+	// Importing the rules registers them with the validator.
+	_ "github.com/vektah/gqlparser/validator/rules"
+)
+
+// A Request represents a GraphQL request.  It makes no guarantees that the
+// request is valid.  Extensions are what the client adds to the protocol,
+// e.g. the persistedQuery extension sends a query by its hash.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Operation finds the operation in req, if it is a valid request for GraphQL
+// schema s. If the request is GraphQL valid, it must contain a single valid
+// Operation.  If either the request is malformed or doesn't contain a valid
+// operation, all GraphQL errors encountered are returned.
+func (s *schema) Operation(req *Request) (Operation, error) {
+	return s.OperationContext(context.Background(), req)
+}
+
+// OperationContext is Operation, with parsing and validating req traced as
+// phases of the request in ctx.
+func (s *schema) OperationContext(ctx context.Context, req *Request) (Operation, error) {
+	if req == nil || req.Query == "" {
+		return nil, errors.New("no query string supplied in request")
+	}
+
+	// An operation that's been sent before has been parsed and validated
+	// already, but its phases are still traced, for tools that expect them.
+	_, end := tracing.StartPhase(ctx, tracing.Parse)
+	entry, cached := s.operations.get(req.Query)
+	var doc *ast.QueryDocument
+	var gqlErr *gqlerror.Error
+	if cached {
+		doc = entry.doc
+	} else {
+		doc, gqlErr = parser.ParseQuery(&ast.Source{Input: req.Query})
+	}
+	end()
+	if gqlErr != nil {
+		return nil, gqlErr
+	}
+
+	_, end = tracing.StartPhase(ctx, tracing.Validate)
+	defer end()
+	if !cached {
+		if listErr := validator.Validate(s.schema, doc); len(listErr) != 0 {
+			return nil, listErr
+		}
+		entry = s.operations.put(req.Query, doc)
+	}
+
+	if len(doc.Operations) > 1 && req.OperationName == "" {
+		return nil, errors.New("Operation name must by supplied when query has more " +
+			"than 1 operation.")
+	}
+
+	op := doc.Operations.ForName(req.OperationName)
+	if op == nil {
+		return nil, errors.Errorf("Supplied operation name %s isn't present in the request.",
+			req.OperationName)
+	}
+
+	vars, errs := coerceVariables(s.schema, op, req.Variables)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs := checkArguments(s.schema, op.SelectionSet, vars); errs != nil {
+		return nil, errs
+	}
+	if errs := s.validateCascade(op.SelectionSet, vars); errs != nil {
+		return nil, errs
+	}
+
+	o := &operation{op: op, vars: vars, inSchema: s}
+	if len(op.VariableDefinitions) == 0 {
+		o.cached = entry
+	}
+	if errs := o.validateAtomic(); errs != nil {
+		return nil, errs
+	}
+	if errs := o.validateIncremental(); errs != nil {
+		return nil, errs
+	}
+	return o, nil
+}
+
+// AsGQLErrors formats an error as a list of GraphQL errors.
+func AsGQLErrors(err error) gqlerror.List {
+	if err == nil {
+		return nil
+	}
+
+	switch e := errors.Cause(err).(type) {
+	case *gqlerror.Error:
+		return gqlerror.List{e}
+	case gqlerror.List:
+		return e
+	default:
+		return gqlerror.List{&gqlerror.Error{Message: err.Error()}}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// Response represents a GraphQL response
+type Response struct {
+	Errors     gqlerror.List
+	Data       bytes.Buffer
+	Extensions map[string]interface{}
+
+	// Cache is how long the response can be cached, and by whom; nil if it
+	// can't be.  It isn't part of the JSON response.
+	Cache *CacheHint
+}
+
+// ErrorResponse formats an error as a list of GraphQL errors and builds
+// a response with that error list and no data.
+func ErrorResponse(err error) *Response {
+	return &Response{Errors: AsGQLErrors(err)}
+}
+
+// WithError generates GraphQL errors from err and records those in r.
+func (r *Response) WithError(err error) {
+	r.Errors = append(r.Errors, AsGQLErrors(err)...)
+}
+
+// nullData is the data of a response whose data is null.
+var nullData = []byte("null")
+
+// NullData makes r's data null.  That's the result of an operation when one of
+// its non-nullable root fields is null: the error propagates up to the data.
+// Data added after that is ignored.
+func (r *Response) NullData() {
+	if r == nil {
+		return
+	}
+	r.Data.Reset()
+	r.Data.Write(nullData)
+}
+
+// AddData adds p to r's data buffer.  If p is empty, or r's data is null, the
+// call has no effect.
+// If r.Data is empty before the call, then r.Data becomes {p}, otherwise
+// r.Data gets p added to its object as a new field.  That is, if r.Data is
+// {a}, then after the call it's {a, p}.
+//
+// p should be a fragment of valid JSON; i.e. `"q": {...}`.
+func (r *Response) AddData(p []byte) {
+	if r == nil || len(p) == 0 || bytes.Equal(r.Data.Bytes(), nullData) {
+		return
+	}
+
+	if r.Data.Len() > 0 {
+		// The end of the buffer is always the closing `}`
+		r.Data.Truncate(r.Data.Len() - 1)
+		r.Data.WriteRune(',')
+	}
+
+	if r.Data.Len() == 0 {
+		r.Data.WriteRune('{')
+	}
+
+	r.Data.Write(p)
+	r.Data.WriteRune('}')
+}
+
+// WriteTo writes the GraphQL response as unindented JSON to w
+// and returns the number of bytes written and error, if any.
+func (r *Response) WriteTo(w io.Writer) (int64, error) {
+	if r == nil {
+		i, err := w.Write([]byte(
+			`{ "errors": [ { "message": "Internal error - no response to write." } ], ` +
+				` "data": null }`))
+		return int64(i), err
+	}
+
+	js, err := json.Marshal(struct {
+		Errors     []*gqlerror.Error      `json:"errors,omitempty"`
+		Data       json.RawMessage        `json:"data,omitempty"`
+		Extensions map[string]interface{} `json:"extensions,omitempty"`
+	}{
+		Errors:     r.Errors,
+		Data:       r.Data.Bytes(),
+		Extensions: r.Extensions,
+	})
+
+	if err != nil {
+		msg := "Internal error - failed to marshal a valid JSON response"
+		i, err := w.Write([]byte(fmt.Sprintf(
+			`{ "errors": [ { "message": "%s" } ], "data": null }`, msg)))
+		return int64(i), err
+	}
+
+	i, err := w.Write(js)
+	return int64(i), err
+}
@@ -0,0 +1,429 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"strings"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A defnRule checks a whole type definition.
+type defnRule func(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error
+
+// A fieldRule checks a single field of a type definition.
+type fieldRule func(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error
+
+var defnRules = []defnRule{
+	supportedKindRule,
+	reservedNameRule,
+	oneIDFieldRule,
+	visibleFieldRule,
+	onErrorRule,
+	cacheControlRule,
+	generateRule,
+	apiRule,
+	remoteTypeRule,
+	keyRule,
+	edgeTypeRule,
+	softDeleteRule,
+	lambdaOnMutateRule,
+}
+
+var fieldRules = []fieldRule{
+	listTypeRule,
+	idFieldTypeRule,
+	searchRule,
+	inverseRule,
+	privateRule,
+	authFieldRule,
+	customRule,
+	lambdaRule,
+	onErrorFieldRule,
+	cacheControlFieldRule,
+	remoteFieldRule,
+	externalRule,
+	dgraphPredRule,
+	embeddingRule,
+	defaultRule,
+	computedRule,
+	facetsRule,
+	onDeleteRule,
+	versionRule,
+	xidRule,
+	uploadRule,
+}
+
+var reservedTypeNames = map[string]bool{
+	"Query":        true,
+	"Mutation":     true,
+	"Subscription": true,
+}
+
+// ValidateSchema checks that doc, which should have already had AddScalars
+// applied, is a schema that Dgraph's GraphQL layer can serve.  These are the
+// checks on top of GraphQL's own validity rules.
+func ValidateSchema(doc *ast.SchemaDocument) gqlerror.List {
+	var errs gqlerror.List
+
+	for _, schm := range append(doc.Schema, doc.SchemaExtension...) {
+		if err := schemaDefinitionRule(schm); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, dir := range doc.Directives {
+		if dir.Position == nil || !dir.Position.Src.BuiltIn {
+			errs = append(errs, gqlerror.ErrorPosf(dir.Position,
+				"You don't need to define directives.  Directive @%s isn't supported.",
+				dir.Name))
+		}
+	}
+
+	for _, defn := range doc.Definitions {
+		if defn.BuiltIn {
+			continue
+		}
+		for _, rule := range defnRules {
+			if err := rule(doc, defn); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if defn.Kind != ast.Object && defn.Kind != ast.Interface {
+			continue
+		}
+		for _, field := range defn.Fields {
+			for _, rule := range fieldRules {
+				if err := rule(doc, defn, field); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+func supportedKindRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	switch defn.Kind {
+	case ast.Object, ast.Interface, ast.Enum:
+		return nil
+	}
+	return gqlerror.ErrorPosf(defn.Position,
+		"You can't add %s definitions.  Only type, interface and enum definitions are "+
+			"allowed in the initial schema.", kindKeyword[defn.Kind])
+}
+
+// kindKeyword is the SDL keyword that declares each kind of definition.
+var kindKeyword = map[ast.DefinitionKind]string{
+	ast.Scalar:      "scalar",
+	ast.Object:      "type",
+	ast.Interface:   "interface",
+	ast.Union:       "union",
+	ast.Enum:        "enum",
+	ast.InputObject: "input",
+}
+
+// customRootTypes are the reserved types that a schema can declare, to add
+// @custom and @lambda queries and mutations to the generated ones.
+var customRootTypes = map[string]bool{
+	"Query":    true,
+	"Mutation": true,
+}
+
+func reservedNameRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	if !reservedTypeNames[defn.Name] {
+		return nil
+	}
+	if !customRootTypes[defn.Name] || defn.Kind != ast.Object {
+		return gqlerror.ErrorPosf(defn.Position,
+			"%s is a reserved word, so you can't declare a type with this name.  "+
+				"Pick a different name for the type.", defn.Name)
+	}
+	for _, fld := range defn.Fields {
+		if !isCustom(fld) {
+			return gqlerror.ErrorPosf(fld.Position,
+				"Type %s; Field %s: only @custom and @lambda fields can be declared in "+
+					"type %s.  The other queries and mutations are generated from your types.",
+				defn.Name, fld.Name, defn.Name)
+		}
+	}
+	return nil
+}
+
+func oneIDFieldRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	var ids []string
+	for _, fld := range defn.Fields {
+		if fld.Type.Name() == "ID" {
+			ids = append(ids, fld.Name)
+		}
+	}
+	if len(ids) > 1 {
+		return gqlerror.ErrorPosf(defn.Position,
+			"Fields %s are listed as IDs for type %s, but a type can have only one ID field.  "+
+				"Pick a single field as the ID for type %s.",
+			strings.Join(ids, " and "), defn.Name, defn.Name)
+	}
+	return nil
+}
+
+func visibleFieldRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	if defn.Kind != ast.Object && defn.Kind != ast.Interface {
+		return nil
+	}
+	for _, fld := range defn.Fields {
+		if private, _ := isPrivate(fld); !private {
+			return nil
+		}
+	}
+	if len(defn.Fields) == 0 {
+		return nil
+	}
+	return gqlerror.ErrorPosf(defn.Position,
+		"Type %s: every field is @private, but a type needs at least one field that "+
+			"isn't.", defn.Name)
+}
+
+func listTypeRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	if field.Type.Elem == nil {
+		return nil
+	}
+	if field.Type.Elem.Elem != nil {
+		return gqlerror.ErrorPosf(field.Position,
+			"Type %s; Field %s: Nested lists are invalid.", defn.Name, field.Name)
+	}
+	if field.Type.Name() == "Boolean" {
+		return gqlerror.ErrorPosf(field.Position,
+			"Type %s; Field %s: Boolean lists are invalid, Dgraph doesn't store lists of "+
+				"booleans.", defn.Name, field.Name)
+	}
+	return nil
+}
+
+func idFieldTypeRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	if field.Type.Name() == "ID" && field.Type.Elem != nil {
+		return gqlerror.ErrorPosf(field.Position,
+			"Type %s; Field %s: ID lists are invalid.", defn.Name, field.Name)
+	}
+	return nil
+}
+
+func searchRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(searchDirective)
+	if dir == nil {
+		return nil
+	}
+
+	typName := field.Type.Name()
+	if typName == VectorType {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: has the @search directive, but Dgraph can't index vectors, "+
+				"so there's no hnsw or other vector index.  Use @embedding to search %s "+
+				"fields by similarity.", defn.Name, field.Name, VectorType)
+	}
+	typDefn := doc.Definitions.ForName(typName)
+	allowed, ok := supportedSearches[typName]
+	if typDefn != nil && typDefn.Kind == ast.Enum {
+		allowed, ok = enumSearches, true
+	}
+	if !ok {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: has the @search directive but fields of type %s "+
+				"can't have the @search directive.", defn.Name, field.Name, typName)
+	}
+
+	arg := dir.Arguments.ForName(searchArgs)
+	if arg == nil || arg.Value == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var sortable []string
+	for _, idx := range arg.Value.Children {
+		name := idx.Value.Raw
+		if name == "hnsw" {
+			return gqlerror.ErrorPosf(idx.Value.Position,
+				"Type %s; Field %s: the argument hnsw to @search is a vector index, which "+
+					"Dgraph doesn't have.  Use @embedding on a %s field to search by "+
+					"similarity.", defn.Name, field.Name, VectorType)
+		}
+		if !contains(allowed, name) {
+			return gqlerror.ErrorPosf(idx.Value.Position,
+				"Type %s; Field %s: has the @search directive but the argument %s "+
+					"doesn't apply to field type %s.  Search by %s applies to fields of type %s.",
+				defn.Name, field.Name, name, typName, name, typesForIndex(name))
+		}
+		if seen[name] {
+			return gqlerror.ErrorPosf(idx.Value.Position,
+				"Type %s; Field %s: the argument to @search %s is repeated.",
+				defn.Name, field.Name, name)
+		}
+		seen[name] = true
+		if isSortableIndex(name) {
+			sortable = append(sortable, name)
+		}
+	}
+
+	if seen["hash"] && seen["exact"] {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: the arguments hash and exact can't be used together.  "+
+				"exact supports the same filters as hash, so use only exact.",
+			defn.Name, field.Name)
+	}
+	if len(sortable) > 1 {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: has the @search directive but the arguments %s can't "+
+				"be used together.  Dgraph allows only one sortable index per field.",
+			defn.Name, field.Name, strings.Join(sortable, " and "))
+	}
+
+	return nil
+}
+
+func inverseRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(inverseDirective)
+	if dir == nil {
+		return nil
+	}
+
+	invTypeName := field.Type.Name()
+	invType := doc.Definitions.ForName(invTypeName)
+	if invType == nil || (invType.Kind != ast.Object && invType.Kind != ast.Interface) {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: Field %s is of type %s, but @hasInverse directive only applies"+
+				" to fields with object types.", defn.Name, field.Name, field.Name, invTypeName)
+	}
+
+	arg := dir.Arguments.ForName(inverseArg)
+	if arg == nil || arg.Value == nil || arg.Value.Raw == "" {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @hasInverse directive doesn't have field argument.",
+			defn.Name, field.Name)
+	}
+
+	invFieldName := arg.Value.Raw
+	invField := invType.Fields.ForName(invFieldName)
+	if invField == nil {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: inverse field %s doesn't exist for type %s.",
+			defn.Name, field.Name, invFieldName, invTypeName)
+	}
+
+	if invField.Type.Name() != defn.Name && !contains(defn.Interfaces, invField.Type.Name()) {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @hasInverse is required to link the fields of same type, "+
+				"but the field %s is of the type %s instead of %s. To link these make sure "+
+				"the fields are of the same type.",
+			defn.Name, field.Name, invFieldName, invField.Type.Name(), defn.Name)
+	}
+
+	if invDir := invField.Directives.ForName(inverseDirective); invDir != nil {
+		invArg := invDir.Arguments.ForName(inverseArg)
+		if invArg != nil && invArg.Value != nil && invArg.Value.Raw != field.Name {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: @hasInverse should be consistent. "+
+					"%s.%s is the inverse of %s.%s, but %s.%s is the inverse of %s.%s.",
+				defn.Name, field.Name, defn.Name, field.Name, invTypeName, invFieldName,
+				invTypeName, invFieldName, defn.Name, invArg.Value.Raw)
+		}
+	}
+
+	if !isReverse(field) {
+		return nil
+	}
+	switch {
+	case isReverse(invField):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: only one of %s.%s and %s.%s can be reverse, because the "+
+				"other one's edges are the ones stored in Dgraph.",
+			defn.Name, field.Name, defn.Name, field.Name, invTypeName, invFieldName)
+	case field.Directives.ForName(dgraphDirective) != nil || facetsType(field) != "":
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: is read from the reverse edges of %s.%s, so it can't have "+
+				"@dgraph or @facets.", defn.Name, field.Name, invTypeName, invFieldName)
+	}
+	return nil
+}
+
+func privateRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	private, writable := isPrivate(field)
+	if private && field.Type.Name() == "ID" {
+		return gqlerror.ErrorPosf(field.Position,
+			"Type %s; Field %s: ID fields can't be @private.", defn.Name, field.Name)
+	}
+
+	// A field inherited from an interface is stored in the interface's
+	// predicate, so it must be just as private as the interface's field.
+	for _, iface := range defn.Interfaces {
+		idefn := doc.Definitions.ForName(iface)
+		if idefn == nil {
+			continue
+		}
+		ifld := idefn.Fields.ForName(field.Name)
+		if ifld == nil {
+			continue
+		}
+		if ip, iw := isPrivate(ifld); ip != private || iw != writable {
+			return gqlerror.ErrorPosf(field.Position,
+				"Type %s; Field %s: must have the same @private directive as field %s of "+
+					"interface %s.", defn.Name, field.Name, field.Name, iface)
+		}
+	}
+
+	return nil
+}
+
+func isSortableIndex(idx string) bool {
+	switch idx {
+	case "int", "float", "exact", "year", "month", "day", "hour":
+		return true
+	}
+	return false
+}
+
+func typesForIndex(idx string) string {
+	var typs []string
+	for _, typ := range []string{"Int", "Float", "Boolean", "String", "DateTime"} {
+		if contains(supportedSearches[typ], idx) {
+			typs = append(typs, typ)
+		}
+	}
+	if contains(enumSearches, idx) {
+		typs = append(typs, "enum")
+	}
+	return strings.Join(typs, " or ")
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
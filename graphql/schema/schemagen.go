@@ -0,0 +1,211 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"io"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+	"github.com/vektah/gqlparser/parser"
+	"github.com/vektah/gqlparser/validator"
+)
+
+// A Handler can produce valid GraphQL and Dgraph schemas given an input of
+// types and relationships
+type Handler interface {
+	Input() string
+	DGSchema() string
+	DGPredicates() []Predicate
+	GQLSchema() string
+	WriteGQLSchema(w io.Writer, opts FormatOptions) error
+	GoClient(pkg string) ([]byte, error)
+	Schema() Schema
+}
+
+type handler struct {
+	input          string
+	remotes        []RemoteAPI
+	originalDefs   []string
+	completeSchema *ast.Schema
+	dgraphSchema   string
+	predicates     []Predicate
+	hidden         map[string][]hiddenField
+	naming         APINaming
+	apis           map[string]*typeAPI
+}
+
+// NewHandler processes the input schema, stitching in any remote APIs.  If
+// there are no errors, it returns a valid Handler, otherwise it returns nil
+// and an error.  It's Parse, Validate and Generate, and then it works out the
+// Dgraph schema.
+func NewHandler(input string, remotes ...RemoteAPI) (Handler, error) {
+	return newHandler(input, nil, remotes)
+}
+
+// newHandler is NewHandler, reusing the APIs in prev that would be generated
+// the same again.
+func newHandler(input string, prev map[string]*typeAPI,
+	remotes []RemoteAPI) (*handler, error) {
+
+	doc, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	defns := make([]string, 0, len(doc.Definitions))
+	for _, defn := range doc.Definitions {
+		if defn.BuiltIn {
+			continue
+		}
+		defns = append(defns, defn.Name)
+	}
+
+	sch, err := Validate(doc)
+	if err != nil {
+		return nil, err
+	}
+	apis, err := generate(doc, sch, prev)
+	if err != nil {
+		return nil, err
+	}
+
+	dgSchema, predicates := genDgraphSchema(sch)
+	hidden := hidePrivateFields(sch)
+
+	for _, remote := range remotes {
+		if gqlErr := stitch(sch, remote); gqlErr != nil {
+			return nil, gqlerror.List{gqlErr}
+		}
+	}
+
+	return &handler{
+		input:          input,
+		remotes:        remotes,
+		originalDefs:   defns,
+		completeSchema: sch,
+		dgraphSchema:   dgSchema,
+		predicates:     predicates,
+		hidden:         hidden,
+		naming:         schemaNaming(doc),
+		apis:           apis,
+	}, nil
+}
+
+// Parse parses input, a GraphQL schema for Dgraph, along with GraphQL's
+// built in types and the scalars, directives and filters that Dgraph adds.
+// It only checks the syntax; Validate checks the rest.  Errors are
+// gqlerror.Errors, or gqlerror.Lists, with the positions in input.
+func Parse(input string) (*ast.SchemaDocument, error) {
+	if input == "" {
+		return nil, gqlerror.Errorf("No schema specified")
+	}
+
+	doc, gqlErr := parser.ParseSchemas(validator.Prelude, &ast.Source{Input: input})
+	if gqlErr != nil {
+		return nil, gqlerror.List{gqlErr}
+	}
+	AddScalars(doc)
+	return doc, nil
+}
+
+// Validate checks that doc, as Parse returned it, is a GraphQL schema that
+// Dgraph can serve, and returns it as an ast.Schema, ready for Generate.
+// Every problem that's found is in the error, which is a gqlerror.List.
+func Validate(doc *ast.SchemaDocument) (*ast.Schema, error) {
+	if gqlErrList := ValidateSchema(doc); gqlErrList != nil {
+		return nil, gqlErrList
+	}
+	sch, gqlErr := validator.ValidateSchemaDocument(doc)
+	if gqlErr != nil {
+		return nil, gqlerror.List{gqlErr}
+	}
+	return sch, nil
+}
+
+// Generate adds the API that Dgraph serves for sch, which Validate returned
+// for doc, to sch: the filters, inputs and payloads for each type, and the
+// queries, mutations and subscriptions, named as doc's @api says.  It then
+// checks the API, e.g. that no generated name clashes with a type of the
+// schema; if it's not valid, the error is a gqlerror.List, and sch shouldn't
+// be used.
+func Generate(doc *ast.SchemaDocument, sch *ast.Schema) error {
+	_, err := generate(doc, sch, nil)
+	return err
+}
+
+// generate is Generate, reusing the APIs in prev that would be generated the
+// same again.  It returns the APIs of sch's types.
+func generate(doc *ast.SchemaDocument, sch *ast.Schema,
+	prev map[string]*typeAPI) (map[string]*typeAPI, error) {
+
+	apis := generateCompleteSchema(sch, schemaNaming(doc), prev)
+	if gqlErrList := checkGeneratedNames(sch); gqlErrList != nil {
+		return nil, gqlErrList
+	}
+	if gqlErrList := checkSharedPredicates(sch); gqlErrList != nil {
+		return nil, gqlErrList
+	}
+	if gqlErrList := validateAuthRules(sch); gqlErrList != nil {
+		return nil, gqlErrList
+	}
+	return apis, nil
+}
+
+// Input returns the schema the Handler was built from.
+func (s *handler) Input() string {
+	return s.input
+}
+
+// DGSchema returns the Dgraph schema that stores the GraphQL types.
+func (s *handler) DGSchema() string {
+	return s.dgraphSchema
+}
+
+// DGPredicates lists the predicates in the Dgraph schema.
+func (s *handler) DGPredicates() []Predicate {
+	return s.predicates
+}
+
+// GQLSchema returns the complete GraphQL schema, including all the generated
+// queries, mutations and input types.
+func (s *handler) GQLSchema() string {
+	return Stringify(s.completeSchema, s.originalDefs)
+}
+
+// WriteGQLSchema writes the complete GraphQL schema to w, formatted as opts
+// say.  The input schema's types are the original types.
+func (s *handler) WriteGQLSchema(w io.Writer, opts FormatOptions) error {
+	opts.OriginalTypes = s.originalDefs
+	return Write(w, s.completeSchema, opts)
+}
+
+// GoClient returns the source of a Go client package, called pkg, for the
+// generated API.
+func (s *handler) GoClient(pkg string) ([]byte, error) {
+	return GoClient(s.completeSchema, pkg)
+}
+
+// Schema returns the complete schema, wrapped for use by the resolvers.
+func (s *handler) Schema() Schema {
+	sch := asSchema(s.completeSchema, s.hidden, s.naming)
+	for _, remote := range s.remotes {
+		remote := remote
+		sch.remotes[remote.Field] = &remote
+	}
+	return sch
+}
@@ -0,0 +1,832 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaGeneration(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/schemagen/input/*.graphql")
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".graphql")
+		t.Run(name, func(t *testing.T) {
+			in, err := ioutil.ReadFile(input)
+			require.NoError(t, err)
+
+			handler, err := NewHandler(string(in))
+			require.NoError(t, err)
+
+			gql, err := ioutil.ReadFile("testdata/schemagen/output/" + name + ".graphql")
+			require.NoError(t, err)
+			require.Equal(t, string(gql), handler.GQLSchema())
+
+			dg, err := ioutil.ReadFile("testdata/schemagen/output/" + name + ".dgraph")
+			require.NoError(t, err)
+			require.Equal(t, string(dg), handler.DGSchema())
+		})
+	}
+}
+
+func TestSchemaValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		errMsg string
+	}{
+		{
+			name:   "empty schema",
+			schema: "",
+			errMsg: "No schema specified",
+		},
+		{
+			name:   "input types aren't allowed",
+			schema: `input X { f: Int }`,
+			errMsg: "You can't add input definitions.",
+		},
+		{
+			name:   "reserved type names",
+			schema: `type Subscription { f: Int }`,
+			errMsg: "Subscription is a reserved word",
+		},
+		{
+			name:   "query that isn't custom",
+			schema: `type Query { f: Int }`,
+			errMsg: "Type Query; Field f: only @custom and @lambda fields can be declared in " +
+				"type Query.",
+		},
+		{
+			name:   "only one ID field",
+			schema: `type X { id1: ID! id2: ID! }`,
+			errMsg: "a type can have only one ID field",
+		},
+		{
+			name:   "no nested lists",
+			schema: `type X { f: [[Int]] }`,
+			errMsg: "Type X; Field f: Nested lists are invalid.",
+		},
+		{
+			name:   "no Boolean lists",
+			schema: `type X { f: [Boolean] }`,
+			errMsg: "Type X; Field f: Boolean lists are invalid",
+		},
+		{
+			name:   "search on an object",
+			schema: `type X { f: Y @search } type Y { g: Int }`,
+			errMsg: "fields of type Y can't have the @search directive",
+		},
+		{
+			name:   "search argument for the wrong type",
+			schema: `type X { f: Int @search(by: [term]) }`,
+			errMsg: "the argument term doesn't apply to field type Int",
+		},
+		{
+			name:   "regexp search on a number",
+			schema: `type X { f: Int @search(by: [trigram]) }`,
+			errMsg: "Search by trigram applies to fields of type String.",
+		},
+		{
+			name:   "embedding that isn't a vector",
+			schema: `type X { id: ID! v: String @embedding }`,
+			errMsg: "Type X; Field v: has the @embedding directive, but only fields of type " +
+				"Float32Vector can be embeddings.",
+		},
+		{
+			name:   "vector index",
+			schema: `type X { id: ID! v: Float32Vector @search(by: [hnsw]) }`,
+			errMsg: "Type X; Field v: has the @search directive, but Dgraph can't index " +
+				"vectors, so there's no hnsw or other vector index.",
+		},
+		{
+			name:   "vector index on another type",
+			schema: `type X { id: ID! s: String @search(by: [hnsw]) }`,
+			errMsg: "Type X; Field s: the argument hnsw to @search is a vector index, which " +
+				"Dgraph doesn't have.",
+		},
+		{
+			name:   "list of vectors",
+			schema: `type X { id: ID! v: [Float32Vector] }`,
+			errMsg: "Type X; Field v: lists of Float32Vector aren't supported.",
+		},
+		{
+			name:   "embedding on an interface",
+			schema: `interface I { id: ID! v: Float32Vector @embedding }`,
+			errMsg: "Type I; Field v: @embedding is only allowed on fields of object types",
+		},
+		{
+			name:   "default on a list",
+			schema: `type X { id: ID! f: [String] @default(add: {value: "a"}) }`,
+			errMsg: "Type X; Field f: @default is only allowed on fields of type Int, Float, " +
+				"Boolean, String, DateTime or an enum.",
+		},
+		{
+			name:   "default of the wrong type",
+			schema: `type X { id: ID! f: Int @default(add: {value: "one"}) }`,
+			errMsg: "Type X; Field f: @default value \"one\" isn't a valid Int.",
+		},
+		{
+			name:   "now default that isn't a DateTime",
+			schema: `type X { id: ID! f: String @default(update: {value: "$now"}) }`,
+			errMsg: "Type X; Field f: @default value $now is only allowed on DateTime fields.",
+		},
+		{
+			name:   "default that isn't an enum value",
+			schema: `enum E { A } type X { id: ID! f: E @default(add: {value: "B"}) }`,
+			errMsg: "Type X; Field f: @default value \"B\" isn't a valid E.",
+		},
+		{
+			name:   "default without a value",
+			schema: `type X { id: ID! f: Int @default(add: {}) }`,
+			errMsg: "Type X; Field f: @default needs add to have a value, like add: {value: \"x\"}.",
+		},
+		{
+			name:   "computed field that isn't a number",
+			schema: `type X { id: ID! n: Int f: String @computed(expr: "n") }`,
+			errMsg: "Type X; Field f: @computed fields must be of type Int or Float.",
+		},
+		{
+			name:   "computed expression that doesn't parse",
+			schema: `type X { id: ID! n: Int f: Int @computed(expr: "(n + 1") }`,
+			errMsg: "Type X; Field f: @computed expression \"(n + 1\" is missing a ) at " +
+				"position 7.",
+		},
+		{
+			name:   "computed from an unknown field",
+			schema: `type X { id: ID! f: Int @computed(expr: "n * 2") }`,
+			errMsg: "Type X; Field f: @computed uses n, but type X has no field called n.",
+		},
+		{
+			name:   "computed from a string",
+			schema: `type X { id: ID! n: String f: Int @computed(expr: "n * 2") }`,
+			errMsg: "Type X; Field f: @computed uses n, but only Int and Float fields, and " +
+				"count() of lists, can be computed with.",
+		},
+		{
+			name:   "computed count of a scalar",
+			schema: `type X { id: ID! n: Int f: Int @computed(expr: "count(n)") }`,
+			errMsg: "Type X; Field f: @computed uses count(n), but n isn't a list.",
+		},
+		{
+			name: "computed count of a type with auth",
+			schema: `type X { id: ID! ys: [Y] f: Int @computed(expr: "count(ys)") }
+				type Y @auth(query: "{ f: { eq: $USER } }") { id: ID! f: String }`,
+			errMsg: "Type X; Field f: @computed uses count(ys), but type Y has @auth rules",
+		},
+		{
+			name: "computed from a computed field",
+			schema: `type X { id: ID! n: Int @computed(expr: "count(xs)") xs: [X]
+				f: Int @computed(expr: "n") }`,
+			errMsg: "Type X; Field f: @computed uses n, but only fields that are stored in " +
+				"Dgraph can be used.",
+		},
+		{
+			name:   "computed with an unknown function",
+			schema: `type X { id: ID! n: Int f: Int @computed(expr: "avg(n)") }`,
+			errMsg: "Type X; Field f: @computed expression \"avg(n)\" uses avg, which isn't a " +
+				"function, at position 1.",
+		},
+		{
+			name:   "computed field that's searched",
+			schema: `type X { id: ID! n: Int f: Int @computed(expr: "n") @search }`,
+			errMsg: "Type X; Field f: a @computed field isn't stored in Dgraph, so it can't " +
+				"have @search.",
+		},
+		{
+			name:   "facets that aren't an edge type",
+			schema: `type F { id: ID! } type X { id: ID! xs: [X] @facets(type: "F") }`,
+			errMsg: "Type X; Field xs: @facets needs the name of an @edge type, but \"F\" " +
+				"isn't one.",
+		},
+		{
+			name:   "facets on a scalar",
+			schema: `type F @edge { f: Int } type X { id: ID! n: Int @facets(type: "F") }`,
+			errMsg: "Type X; Field n: @facets is only allowed on fields that link to objects " +
+				"stored in Dgraph.",
+		},
+		{
+			name:   "edge type used as a field",
+			schema: `type F @edge { f: Int } type X { id: ID! f: F }`,
+			errMsg: "Type X; Field f: F is an @edge type, so it can only be used in @facets.",
+		},
+		{
+			name:   "edge type with an object",
+			schema: `type F @edge { x: X } type X { id: ID! xs: [X] @facets(type: "F") }`,
+			errMsg: "Type F; Field x: facets can only be of type Int, Float, Boolean, String, " +
+				"DateTime or an enum.",
+		},
+		{
+			name:   "edge type with a node",
+			schema: `type F @edge { node: Int } type X { id: ID! xs: [X] @facets(type: "F") }`,
+			errMsg: "Type F; Field node: is the object at the end of the edge in generated " +
+				"edge types, so it can't be a facet.",
+		},
+		{
+			name: "facets with a clashing edges field",
+			schema: `type F @edge { f: Int }
+				type X { id: ID! xs: [X] @facets(type: "F") xsEdges: Int }`,
+			errMsg: "Type X; Field xs: the edges of xs are field xsEdges, but there's already " +
+				"a field with that name.",
+		},
+		{
+			name: "both sides reverse",
+			schema: `type A { id: ID! bs: [B] @hasInverse(field: a, reverse: true) }
+				type B { id: ID! a: A @hasInverse(field: bs, reverse: true) }`,
+			errMsg: "Type A; Field bs: only one of A.bs and B.a can be reverse, because the " +
+				"other one's edges are the ones stored in Dgraph.",
+		},
+		{
+			name: "reverse with @dgraph",
+			schema: `type A { id: ID! bs: [B] @hasInverse(field: a, reverse: true) @dgraph(pred: "x") }
+				type B { id: ID! a: A }`,
+			errMsg: "Type A; Field bs: is read from the reverse edges of B.a, so it can't have " +
+				"@dgraph or @facets.",
+		},
+		{
+			name:   "onDelete on a scalar",
+			schema: `type X { id: ID! name: String @onDelete(action: CASCADE) }`,
+			errMsg: "Type X; Field name: @onDelete is only allowed on fields that link to " +
+				"objects stored in Dgraph.",
+		},
+		{
+			name: "onDelete on an interface's field",
+			schema: `interface I { id: ID! x: X }
+				type X implements I { id: ID! x: X @onDelete(action: CASCADE) }`,
+			errMsg: "Type X; Field x: is stored in the predicate of interface I's field, which " +
+				"the other types implementing I share, so it can't have @onDelete.",
+		},
+		{
+			name:   "lambdaOnMutate on an interface",
+			schema: `interface I @lambdaOnMutate(add: true) { id: ID! }`,
+			errMsg: "Type I; @lambdaOnMutate is only allowed on object types that are stored " +
+				"in Dgraph.",
+		},
+		{
+			name:   "lambdaOnMutate without events",
+			schema: `type X @lambdaOnMutate(add: false) { id: ID! }`,
+			errMsg: "Type X; @lambdaOnMutate doesn't send any events; set add, update or " +
+				"delete to true.",
+		},
+		{
+			name:   "lambdaOnMutate to a URL that isn't http",
+			schema: `type X @lambdaOnMutate(delete: true, url: "ftp://hooks/x") { id: ID! }`,
+			errMsg: "Type X; @lambdaOnMutate url ftp://hooks/x isn't an http or https URL.",
+		},
+		{
+			name:   "softDelete on an interface",
+			schema: `interface I @softDelete { id: ID! }`,
+			errMsg: "Type I; @softDelete is only allowed on object types that are stored in " +
+				"Dgraph.",
+		},
+		{
+			name:   "softDelete with a deletedAt that isn't a DateTime",
+			schema: `type X @softDelete { id: ID! deletedAt: String }`,
+			errMsg: "Type X; Field deletedAt: @softDelete stores when an object was deleted in " +
+				"field deletedAt, so it must be a DateTime that can be null.",
+		},
+		{
+			name:   "version that isn't an Int",
+			schema: `type X { id: ID! v: String @version }`,
+			errMsg: "Type X; Field v: @version is only allowed on Int fields.",
+		},
+		{
+			name:   "two version fields",
+			schema: `type X { id: ID! v: Int @version w: Int @version }`,
+			errMsg: "Type X; Field w: type X already has @version field v, and can only " +
+				"have one.",
+		},
+		{
+			name:   "xid that isn't a String!",
+			schema: `type X { id: ID! key: String @xid }`,
+			errMsg: "Type X; Field key: @xid is only allowed on String! fields.",
+		},
+		{
+			name:   "xid without an ID",
+			schema: `type X { key: String! @xid }`,
+			errMsg: "Type X; Field key: @xid maps external IDs to Dgraph's, so type X needs an " +
+				"ID field too.",
+		},
+		{
+			name:   "xid with search",
+			schema: `type X { id: ID! key: String! @xid @search(by: [exact]) }`,
+			errMsg: "Type X; Field key: an @xid field is stored and indexed in predicate xid, " +
+				"so it can't have @dgraph or @search.",
+		},
+		{
+			name:   "two xid fields",
+			schema: `type X { id: ID! k: String! @xid l: String! @xid }`,
+			errMsg: "Type X; Field l: type X already has @xid field k, and can only have one.",
+		},
+		{
+			name:   "upload that isn't a String",
+			schema: `type X { id: ID! f: Int @upload }`,
+			errMsg: "Type X; Field f: @upload is only allowed on String fields.",
+		},
+		{
+			name:   "field of type Upload",
+			schema: `type X { id: ID! f: Upload }`,
+			errMsg: "Type X; Field f: fields can't be of type Upload; an uploaded file is " +
+				"stored as its URL, in a String @upload field.",
+		},
+		{
+			name: "argument of type Upload",
+			schema: `type X { id: ID! }
+				type Mutation { store(file: Upload!): X @lambda }`,
+			errMsg: "Type Mutation; Field store: argument file can't be an Upload; files can " +
+				"only be uploaded to @upload fields.",
+		},
+		{
+			name:   "upload on a remote type",
+			schema: `type X @remote { f: String @upload }`,
+			errMsg: "Type X; Field f: @upload is only allowed on fields of object types that " +
+				"are stored in Dgraph.",
+		},
+		{
+			name:   "hash and exact together",
+			schema: `type X { f: String @search(by: [hash, exact]) }`,
+			errMsg: "the arguments hash and exact can't be used together",
+		},
+		{
+			name:   "inverse of a missing field",
+			schema: `type X { f: Y @hasInverse(field: g) } type Y { h: X }`,
+			errMsg: "inverse field g doesn't exist for type Y",
+		},
+		{
+			name:   "inverse of the wrong type",
+			schema: `type X { f: Y @hasInverse(field: g) } type Y { g: Y }`,
+			errMsg: "the field g is of the type Y instead of X",
+		},
+		{
+			name:   "auth rule that isn't a filter",
+			schema: `type X @auth(query: "{ f: { eq: $USER } }") { id: ID! f: String }`,
+			errMsg: "Type X; @auth query rule is invalid: f isn't a field of XFilter",
+		},
+		{
+			name: "auth rule with the wrong kind of value",
+			schema: `type X @auth(delete: "{ f: { eq: true } }") {
+				id: ID! f: String @search(by: [exact]) }`,
+			errMsg: "Type X; @auth delete rule is invalid: true isn't a String",
+		},
+		{
+			name:   "auth rule that doesn't parse",
+			schema: `type X @auth(add: "{ id: [") { id: ID! }`,
+			errMsg: "Type X; @auth add rule is invalid: Unexpected )",
+		},
+		{
+			name: "claim condition in a field's filter",
+			schema: `type X @auth(rule: "{ f: { $ROLE: { eq: \"A\" } } }") {
+				id: ID! f: String @search(by: [exact]) }`,
+			errMsg: "Type X; @auth rule rule is invalid: $ROLE can only be used at the top of " +
+				"a rule, or in its and, or or not",
+		},
+		{
+			name:   "claim condition that isn't eq or in",
+			schema: `type X @auth(query: "{ not: { $ROLE: { le: 3 } } }") { id: ID! }`,
+			errMsg: "Type X; @auth query rule is invalid: $ROLE must be compared with " +
+				"{ eq: value } or { in: [values] }",
+		},
+		{
+			name:   "auth rule on a field",
+			schema: `type X { id: ID! f: String @auth(query: "{ f: { eq: $USER } }") }`,
+			errMsg: "Type X; Field f: @auth query rules are only allowed on types",
+		},
+		{
+			name:   "field auth without roles",
+			schema: `type X { id: ID! f: String @auth(roles: []) }`,
+			errMsg: "Type X; Field f: @auth needs the roles that can access the field.",
+		},
+		{
+			name:   "field auth with an empty claim",
+			schema: `type X { id: ID! f: String @auth(roles: "HR", claim: "") }`,
+			errMsg: "Type X; Field f: @auth claim can't be empty.",
+		},
+		{
+			name:   "non-nullable field with auth",
+			schema: `type X { id: ID! f: String! @auth(roles: "HR") }`,
+			errMsg: "Type X; Field f: has @auth, so it must be nullable",
+		},
+		{
+			name: "field auth on a custom query",
+			schema: `type X { id: ID! }
+				type Query { x: X @lambda @auth(roles: "HR") }`,
+			errMsg: "Type Query; Field x: @auth is only allowed on the fields of types.",
+		},
+		{
+			name: "field auth that differs from the interface's",
+			schema: `interface I { id: ID! f: String @auth(roles: "HR") }
+				type X implements I { id: ID! f: String }`,
+			errMsg: "Type X; Field f: must have the same @auth directive as field f of " +
+				"interface I.",
+		},
+		{
+			name:   "roles on a type",
+			schema: `type X @auth(roles: "HR") { id: ID! f: String }`,
+			errMsg: "Type X; @auth roles are only allowed on fields.",
+		},
+		{
+			name:   "private ID",
+			schema: `type X { id: ID! @private f: String }`,
+			errMsg: "Type X; Field id: ID fields can't be @private.",
+		},
+		{
+			name:   "every field private",
+			schema: `type X { f: String @private }`,
+			errMsg: "Type X: every field is @private",
+		},
+		{
+			name: "private differs from the interface",
+			schema: `interface I { f: String @private(writable: false) }
+				type X implements I { f: String @private }`,
+			errMsg: "Type X; Field f: must have the same @private directive as field f of " +
+				"interface I.",
+		},
+		{
+			name: "custom URL that isn't HTTP",
+			schema: `type X { id: ID! f: String @custom(http: {url: "ftp://x.com/$id", ` +
+				`method: GET}) }`,
+			errMsg: "Type X; Field f: @custom is invalid: ftp://x.com/$id isn't an http or " +
+				"https URL.",
+		},
+		{
+			name: "custom GET with a body",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com", ` +
+				`method: GET, body: "{}"}) }`,
+			errMsg: "Type X; Field f: @custom is invalid: a GET call can't have a body.",
+		},
+		{
+			name: "custom body that isn't JSON",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com", ` +
+				`method: POST, body: "{id: $id}"}) }`,
+			errMsg: "Type X; Field f: @custom is invalid: the body isn't JSON",
+		},
+		{
+			name: "custom header without a name",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com", ` +
+				`method: GET, headers: ["token"]}) }`,
+			errMsg: `Type X; Field f: @custom is invalid: header "token" should be written`,
+		},
+		{
+			name: "custom variable that isn't a field",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com/$g", ` +
+				`method: GET}) }`,
+			errMsg: "Type X; Field f: @custom uses $g, but type X has no field called g.",
+		},
+		{
+			name: "custom variable that's an object",
+			schema: `type X { id: ID! y: Y f: String @custom(http: {url: "http://x.com/$y", ` +
+				`method: GET}) } type Y { id: ID! }`,
+			errMsg: "Type X; Field f: @custom uses $y, but field y is an object.",
+		},
+		{
+			name: "custom query variable that isn't an argument",
+			schema: `type X { id: ID! } type Query { f(a: Int): String ` +
+				`@custom(http: {url: "http://x.com/$b", method: GET}) }`,
+			errMsg: "Type Query; Field f: @custom uses $b, but there's no argument called b.",
+		},
+		{
+			name: "custom and search",
+			schema: `type X { id: ID! f: String @search @custom(http: {url: "http://x.com", ` +
+				`method: GET}) }`,
+			errMsg: "Type X; Field f: @custom fields aren't stored in Dgraph, so they can't " +
+				"also be @search.",
+		},
+		{
+			name: "custom field of an interface",
+			schema: `interface I { id: ID! f: String } type X implements I { id: ID! ` +
+				`f: String @custom(http: {url: "http://x.com", method: GET}) }`,
+			errMsg: "Type X; Field f: can't be @custom, because it's a field of interface I.",
+		},
+		{
+			name:   "lambda ID field",
+			schema: `type X { id: ID! @lambda }`,
+			errMsg: "Type X; Field id: ID fields can't be @lambda.",
+		},
+		{
+			name: "lambda and custom",
+			schema: `type X { id: ID! f: String @lambda @custom(http: {url: "http://x.com", ` +
+				`method: GET}) }`,
+			errMsg: "Type X; Field f: can't be both @custom and @lambda.",
+		},
+		{
+			name:   "lambda and search",
+			schema: `type X { id: ID! f: String @search @lambda }`,
+			errMsg: "Type X; Field f: @lambda fields aren't stored in Dgraph, so they can't " +
+				"also be @search.",
+		},
+		{
+			name: "custom variable that's a lambda field",
+			schema: `type X { id: ID! g: String @lambda ` +
+				`f: String @custom(http: {url: "http://x.com/$g", method: GET}) }`,
+			errMsg: "Type X; Field f: @custom uses $g, but field g is @lambda.",
+		},
+		{
+			name:   "onError with an unknown policy",
+			schema: `type X { id: ID! f: String @onError(policy: RETRY) }`,
+			errMsg: "Type X; Field f: @onError needs a policy of NULL_FIELD or ABORT_OBJECT.",
+		},
+		{
+			name:   "onError on an interface",
+			schema: `interface X @onError(policy: ABORT_OBJECT) { id: ID! }`,
+			errMsg: "Type X; @onError directive is only allowed on types and their fields.",
+		},
+		{
+			name:   "cacheControl with a negative maxAge",
+			schema: `type X { id: ID! f: String @cacheControl(maxAge: -1) }`,
+			errMsg: "Type X; Field f: @cacheControl needs a maxAge that's a whole number of " +
+				"seconds, 0 or more.",
+		},
+		{
+			name:   "cacheControl with an unknown scope",
+			schema: `type X @cacheControl(maxAge: 60, scope: SHARED) { id: ID! }`,
+			errMsg: "Type X: @cacheControl needs a scope of PUBLIC or PRIVATE.",
+		},
+		{
+			name:   "schema with operation types",
+			schema: "schema { query: X }\ntype X { id: ID! }",
+			errMsg: "You don't need to define the GraphQL Schema type.",
+		},
+		{
+			name:   "schema api with a type's settings",
+			schema: "schema @api(name: \"X\")\ntype X { id: ID! }",
+			errMsg: "Schema; @api can only set the naming of the whole API, not its name.",
+		},
+		{
+			name:   "api with a name that isn't a GraphQL name",
+			schema: `type X @api(name: "my-x") { id: ID! }`,
+			errMsg: "Type X: @api needs a name that's a valid GraphQL name.",
+		},
+		{
+			name:   "api with an unknown naming",
+			schema: `type X @api(naming: SNAKE) { id: ID! }`,
+			errMsg: "Type X: @api needs a naming of PREFIXED or PLURAL.",
+		},
+		{
+			name:   "api names that clash",
+			schema: "schema @api(naming: PLURAL)\ntype Post @api(plural: \"post\") { id: ID! }",
+			errMsg: "Query has more than one field called post.",
+		},
+		{
+			name:   "generate on a remote type",
+			schema: `type X @remote @generate(subscription: false) { id: ID! }`,
+			errMsg: "Type X; @generate directive isn't allowed here.",
+		},
+		{
+			name:   "generate with an unknown setting",
+			schema: `type X @generate(query: {list: false}) { id: ID! }`,
+			errMsg: "Type X; @generate has no query.list setting.",
+		},
+		{
+			name:   "generate turning off add for a type without an ID",
+			schema: `type X @generate(mutation: {add: false}) { f: String }`,
+			errMsg: "Type X; @generate can only turn off add for types with an ID field.",
+		},
+		{
+			name:   "generate with a setting that isn't a boolean",
+			schema: `type X @generate(mutation: {add: "no"}) { id: ID! }`,
+			errMsg: "Type X; @generate needs mutation.add to be true or false.",
+		},
+		{
+			name:   "remote field that isn't custom",
+			schema: `type X { id: ID! r: R } type R @remote { f: String }`,
+			errMsg: "Type X; Field r: is of remote type R, which isn't stored in Dgraph, so it " +
+				"must be @custom or @lambda.",
+		},
+		{
+			name:   "remote type that implements an interface",
+			schema: `interface I { f: String } type R implements I @remote { f: String }`,
+			errMsg: "Type R: remote types can't implement interfaces",
+		},
+		{
+			name:   "remote type with a stored field",
+			schema: `type X { id: ID! } type R @remote { x: X }`,
+			errMsg: "Type R; Field x: remote types can only have fields of remote types, but " +
+				"X is stored in Dgraph.",
+		},
+		{
+			name:   "remote type with a search",
+			schema: `type R @remote { f: String @search }`,
+			errMsg: "Type R; Field f: remote types aren't stored in Dgraph, so their fields " +
+				"can't be @search.",
+		},
+		{
+			name: "graphql that isn't a query",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com", ` +
+				`method: POST, graphql: "a b"}) }`,
+			errMsg: `Type X; Field f: @custom is invalid: graphql should be a single query, ` +
+				`like "user(id: $id)", but it's "a b".`,
+		},
+		{
+			name: "graphql with GET",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com", ` +
+				`method: GET, graphql: "f(id: $id)"}) }`,
+			errMsg: "Type X; Field f: @custom is invalid: a graphql call must use the POST method.",
+		},
+		{
+			name: "graphql with a variable in the URL",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com/$id", ` +
+				`method: POST, graphql: "f(id: $id)"}) }`,
+			errMsg: "Type X; Field f: @custom is invalid: a graphql call can only use " +
+				"$variables in its query.",
+		},
+		{
+			name: "batch call with GET",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com", ` +
+				`method: GET, mode: BATCH}) }`,
+			errMsg: "Type X; Field f: @custom is invalid: a BATCH call sends a list in its " +
+				"body, so it can't use GET.",
+		},
+		{
+			name: "batch call with a variable in the URL",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com/$id", ` +
+				`method: POST, mode: BATCH}) }`,
+			errMsg: "Type X; Field f: @custom is invalid: a BATCH call can only use " +
+				"$variables in its body.",
+		},
+		{
+			name: "batch query",
+			schema: `type X { id: ID! } type Query { xs: [X] @custom(http: {` +
+				`url: "http://x.com", method: POST, mode: BATCH}) }`,
+			errMsg: "Type Query; Field xs: @custom is invalid: only the fields of types can " +
+				"be BATCH calls.",
+		},
+		{
+			name: "policy with a bad timeout",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com", ` +
+				`method: GET, policy: {timeout: "soon"}}) }`,
+			errMsg: `Type X; Field f: @custom is invalid: the policy's timeout "soon" isn't a ` +
+				`positive duration, like "30s".`,
+		},
+		{
+			name: "policy with negative retries",
+			schema: `type X { id: ID! f: String @custom(http: {url: "http://x.com", ` +
+				`method: GET, policy: {maxRetries: -1}}) }`,
+			errMsg: "Type X; Field f: @custom is invalid: the policy's maxRetries can't be " +
+				"negative.",
+		},
+		{
+			name:   "key field that doesn't exist",
+			schema: `type X @key(fields: "name") { id: ID! }`,
+			errMsg: "Type X; @key uses name, but type X has no field called name.",
+		},
+		{
+			name:   "key field without an exact index",
+			schema: `type X @key(fields: "name") { id: ID! name: String @search }`,
+			errMsg: "Type X; @key uses name, but a key field must be the ID, or a field that's " +
+				"stored with a @search index that finds exact values, like hash.",
+		},
+		{
+			name:   "key on a remote type",
+			schema: `type R @remote @key(fields: "f") { f: String }`,
+			errMsg: "Type R: remote types aren't stored in Dgraph, so they can't have a @key.",
+		},
+		{
+			name:   "extends without a key",
+			schema: `type X @extends { id: ID! }`,
+			errMsg: "Type X: @extends types need a @key, so that the gateway can find them.",
+		},
+		{
+			name: "extends with a key that isn't external",
+			schema: `type X @key(fields: "name") @extends { id: ID! ` +
+				`name: String @search(by: [hash]) }`,
+			errMsg: "Type X; @key uses name, which must be @external, because the type " +
+				"@extends a type of another service.",
+		},
+		{
+			name:   "external field of a type that doesn't extend",
+			schema: `type X @key(fields: "id") { id: ID! name: String @external }`,
+			errMsg: "Type X; Field name: only fields of @extends types can be @external.",
+		},
+		{
+			name: "external field that isn't a key",
+			schema: `type X @key(fields: "name") @extends { id: ID! ` +
+				`name: String @external @search(by: [hash]) age: Int @external }`,
+			errMsg: "Type X; Field age: only @key fields can be @external, because this " +
+				"server stores the rest of the fields of X.",
+		},
+		{
+			name:   "dgraph without a predicate",
+			schema: `type X { id: ID! name: String @dgraph(pred: "") }`,
+			errMsg: "Type X; Field name: @dgraph needs a predicate name, but \"\" isn't one.",
+		},
+		{
+			name:   "dgraph with a reverse predicate",
+			schema: `type X { id: ID! friends: [X] @dgraph(pred: "~friend") }`,
+			errMsg: "Type X; Field friends: @dgraph can't store a field in reverse predicate " +
+				"~friend.",
+		},
+		{
+			name:   "dgraph with a reserved predicate",
+			schema: `type X { id: ID! types: [String] @dgraph(pred: "dgraph.type") }`,
+			errMsg: "Type X; Field types: predicate dgraph.type is reserved by Dgraph.",
+		},
+		{
+			name:   "dgraph on an ID field",
+			schema: `type X { id: ID! @dgraph(pred: "xid") }`,
+			errMsg: "Type X; Field id: isn't stored in Dgraph, so it can't have @dgraph.",
+		},
+		{
+			name: "dgraph on an inherited field",
+			schema: `interface I { name: String } ` +
+				`type X implements I { id: ID! name: String @dgraph(pred: "name") }`,
+			errMsg: "Type X; Field name: is stored in the predicate of interface I's field, " +
+				"so it can't have @dgraph.",
+		},
+		{
+			name: "dgraph predicate shared with a different type",
+			schema: `type X { id: ID! name: String @dgraph(pred: "name") } ` +
+				`type Y { id: ID! name: [String] @dgraph(pred: "name") }`,
+			errMsg: "Type Y; Field name: is stored in predicate name, like field X.name, so it " +
+				"must have the same Dgraph type (string) and @search indexes ().",
+		},
+		{
+			name: "dgraph predicate shared with different indexes",
+			schema: `type X { id: ID! name: String @dgraph(pred: "name") @search(by: [hash]) } ` +
+				`type Y { id: ID! name: String @dgraph(pred: "name") @search(by: [term]) }`,
+			errMsg: "Type Y; Field name: is stored in predicate name, like field X.name, so it " +
+				"must have the same Dgraph type (string) and @search indexes (hash).",
+		},
+		{
+			name:   "auth on an interface",
+			schema: `interface X @auth(query: "{ id: [$USER] }") { id: ID! }`,
+			errMsg: "Type X; @auth directive is only allowed on types.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewHandler(test.schema)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.errMsg)
+		})
+	}
+}
+
+func TestParseValidateGenerate(t *testing.T) {
+	input := `
+schema @api(naming: PLURAL)
+
+type Author {
+	id: ID!
+	name: String! @search(by: [hash])
+	posts: [Post] @hasInverse(field: author)
+}
+
+type Post {
+	id: ID!
+	title: String!
+	author: Author
+}`
+	handler, err := NewHandler(input)
+	require.NoError(t, err)
+
+	doc, err := Parse(input)
+	require.NoError(t, err)
+	sch, err := Validate(doc)
+	require.NoError(t, err)
+	require.NoError(t, Generate(doc, sch))
+	require.Equal(t, handler.GQLSchema(), Stringify(sch, []string{"Author", "Post"}))
+	require.NotNil(t, sch.Query.Fields.ForName("authors"), "the naming is doc's")
+	require.Empty(t, Diff(sch, sch))
+
+	_, err = Parse("")
+	require.EqualError(t, err, "input: No schema specified")
+	_, err = Parse("type Author {")
+	require.Error(t, err)
+
+	doc, err = Parse(`type Author { name: String! @search(by: [int]) }`)
+	require.NoError(t, err, "Parse only checks the syntax")
+	_, err = Validate(doc)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Type Author; Field name: has the @search directive but "+
+		"the argument int doesn't apply to field type String.")
+
+	doc, err = Parse(`type Author @api(name: "Writer") { id: ID! }
+type Writer { id: ID! }`)
+	require.NoError(t, err)
+	sch, err = Validate(doc)
+	require.NoError(t, err)
+	err = Generate(doc, sch)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "more than one field called getWriter")
+}
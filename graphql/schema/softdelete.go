@@ -0,0 +1,134 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A @softDelete type isn't removed from Dgraph by deleteT.  Its objects are
+// marked as deleted instead, by setting when they were deleted in field
+// deletedAt, which is added to the type if it doesn't declare it:
+//
+//	type Post @softDelete {
+//		id: ID!
+//		title: String!
+//	}
+//
+// Queries leave out deleted posts, unless they're asked for with
+// includeDeleted: true on getPost, queryPost or a list of posts, and
+// restorePost(filter: PostFilter!) undoes the deletion of the posts the
+// filter finds.  deletedAt can be read and filtered on, but isn't in the
+// inputs of add and update mutations.  Deleted posts can't be updated, and
+// since they're still in Dgraph, their edges are kept and @onDelete doesn't
+// act on them.
+
+// deletedAtField is the field of a @softDelete type that's set when an object
+// is deleted.
+const deletedAtField = "deletedAt"
+
+// isSoftDelete returns true if defn is a @softDelete type.
+func isSoftDelete(defn *ast.Definition) bool {
+	return defn.Directives.ForName(softDeleteDirective) != nil
+}
+
+// isDeletedAt returns true if fld is the deletedAt field of defn, a
+// @softDelete type.
+func isDeletedAt(defn *ast.Definition, fld *ast.FieldDefinition) bool {
+	return fld.Name == deletedAtField && isSoftDelete(defn)
+}
+
+// hasSoftDelete returns true if defn, or a type implementing it, is a
+// @softDelete type, so queries for it can ask for the deleted objects.
+func hasSoftDelete(sch *ast.Schema, defn *ast.Definition) bool {
+	for _, possible := range sch.GetPossibleTypes(defn) {
+		if isSoftDelete(possible) {
+			return true
+		}
+	}
+	return isSoftDelete(defn)
+}
+
+func softDeleteRule(doc *ast.SchemaDocument, defn *ast.Definition) *gqlerror.Error {
+	dir := defn.Directives.ForName(softDeleteDirective)
+	if dir == nil {
+		return nil
+	}
+
+	if defn.Kind != ast.Object || isRemote(defn) || isEdge(defn) ||
+		reservedTypeNames[defn.Name] {
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; @softDelete is only allowed on object types that are stored in Dgraph.",
+			defn.Name)
+	}
+	if fld := defn.Fields.ForName(deletedAtField); fld != nil &&
+		(fld.Type.NamedType != "DateTime" || fld.Type.NonNull || isCustom(fld)) {
+		return gqlerror.ErrorPosf(fld.Position,
+			"Type %s; Field %s: @softDelete stores when an object was deleted in field %s, so "+
+				"it must be a DateTime that can be null.", defn.Name, fld.Name, deletedAtField)
+	}
+	return nil
+}
+
+// addDeletedAt adds the deletedAt field to defn, a @softDelete type, if it
+// doesn't declare it.
+func addDeletedAt(defn *ast.Definition) {
+	if defn.Fields.ForName(deletedAtField) != nil {
+		return
+	}
+	defn.Fields = append(defn.Fields, &ast.FieldDefinition{
+		Name:     deletedAtField,
+		Type:     ast.NamedType("DateTime", nil),
+		Position: defn.Position,
+	})
+}
+
+func addRestorePayloadType(schema *ast.Schema, defn *ast.Definition) {
+	addPayloadType(schema, "Restore"+defn.Name+"Payload", defn)
+}
+
+func addRestoreMutation(schema *ast.Schema, defn *ast.Definition, name string) {
+	schema.Mutation.Fields = append(schema.Mutation.Fields,
+		&ast.FieldDefinition{
+			Name: name,
+			Type: ast.NamedType("Restore"+defn.Name+"Payload", nil),
+			Arguments: ast.ArgumentDefinitionList{
+				{Name: "filter", Type: ast.NonNullNamedType(defn.Name+"Filter", nil)},
+			},
+		})
+}
+
+// includeDeletedArg is the argument of the queries for defn that asks for
+// the deleted objects too, if defn has any @softDelete objects.
+func includeDeletedArg(sch *ast.Schema, defn *ast.Definition) ast.ArgumentDefinitionList {
+	if !hasSoftDelete(sch, defn) {
+		return nil
+	}
+	return ast.ArgumentDefinitionList{
+		{Name: IncludeDeletedArgName, Type: ast.NamedType("Boolean", nil)},
+	}
+}
+
+// SoftDelete returns the deletedAt field of t, if it's a @softDelete type,
+// and nil otherwise.
+func (t *astType) SoftDelete() FieldDefinition {
+	if info := t.inSchema.types[t.Name()]; info != nil && info.deletedAt != nil {
+		return info.deletedAt
+	}
+	return nil
+}
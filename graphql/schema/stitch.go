@@ -0,0 +1,178 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"strings"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+	"github.com/vektah/gqlparser/validator"
+)
+
+// A RemoteAPI is a remote GraphQL API that's stitched into the generated
+// schema under a root field.  For example, with Field "payments" and Prefix
+// "Payments", the remote API's types are added to the schema as PaymentsT
+// for each remote type T, and its queries are reachable as
+//
+//	query { payments { ... } }
+//
+// SDL is the remote API's schema, usually found by introspecting URL when the
+// schema is applied.
+type RemoteAPI struct {
+	Field  string `json:"field"`
+	Prefix string `json:"prefix"`
+	URL    string `json:"url"`
+	SDL    string `json:"-"`
+}
+
+// stitch adds the types of remote to sch, renamed with remote's prefix, and
+// adds remote.Field to the Query and Mutation types so the remote's queries
+// and mutations can be reached.  The added types have no source position, so
+// they aren't stored in Dgraph.
+func stitch(sch *ast.Schema, remote RemoteAPI) *gqlerror.Error {
+	remoteSch, gqlErr := validator.LoadSchema(validator.Prelude,
+		&ast.Source{Name: remote.URL, Input: remote.SDL})
+	if gqlErr != nil {
+		return gqlerror.Errorf("The schema of remote API %s (at %s) isn't valid: %s",
+			remote.Field, remote.URL, gqlErr.Message)
+	}
+
+	rename := func(name string) string {
+		if defn := remoteSch.Types[name]; defn == nil || defn.BuiltIn ||
+			strings.HasPrefix(name, "__") {
+			return name
+		}
+		return remote.Prefix + name
+	}
+
+	var added []*ast.Definition
+	for _, name := range definitionNames(remoteSch) {
+		defn := remoteSch.Types[name]
+		if defn.BuiltIn || strings.HasPrefix(name, "__") {
+			continue
+		}
+
+		newName := rename(name)
+		if sch.Types[newName] != nil {
+			return gqlerror.Errorf("Remote API %s has type %s, which becomes %s, but "+
+				"there's already a type with that name.", remote.Field, name, newName)
+		}
+		copied := copyDefinition(defn, rename)
+		sch.Types[newName] = copied
+		added = append(added, copied)
+	}
+
+	for _, defn := range added {
+		for _, iface := range defn.Interfaces {
+			sch.AddPossibleType(iface, defn)
+			sch.AddImplements(defn.Name, sch.Types[iface])
+		}
+		for _, member := range defn.Types {
+			sch.AddPossibleType(defn.Name, sch.Types[member])
+		}
+	}
+
+	roots := []struct {
+		local, remote *ast.Definition
+	}{
+		{sch.Query, remoteSch.Query},
+		{sch.Mutation, remoteSch.Mutation},
+	}
+	for _, root := range roots {
+		if root.local == nil || root.remote == nil {
+			continue
+		}
+		if root.local.Fields.ForName(remote.Field) != nil {
+			return gqlerror.Errorf("Remote API %s can't be added to %s because it "+
+				"already has a field with that name.", remote.Field, root.local.Name)
+		}
+		root.local.Fields = append(root.local.Fields, &ast.FieldDefinition{
+			Name: remote.Field,
+			Type: ast.NonNullNamedType(rename(root.remote.Name), nil),
+		})
+	}
+
+	return nil
+}
+
+// copyDefinition copies defn, renaming it and every type it refers to with
+// rename.  Only @deprecated is kept of the remote's directives, because the
+// others aren't defined in the schema being stitched into.
+func copyDefinition(defn *ast.Definition, rename func(string) string) *ast.Definition {
+	copied := &ast.Definition{
+		Kind:        defn.Kind,
+		Description: defn.Description,
+		Name:        rename(defn.Name),
+		Directives:  deprecation(defn.Directives),
+	}
+
+	for _, iface := range defn.Interfaces {
+		copied.Interfaces = append(copied.Interfaces, rename(iface))
+	}
+	for _, member := range defn.Types {
+		copied.Types = append(copied.Types, rename(member))
+	}
+	for _, ev := range defn.EnumValues {
+		copied.EnumValues = append(copied.EnumValues, &ast.EnumValueDefinition{
+			Name:        ev.Name,
+			Description: ev.Description,
+			Directives:  deprecation(ev.Directives),
+		})
+	}
+	for _, fld := range defn.Fields {
+		if strings.HasPrefix(fld.Name, "__") {
+			continue
+		}
+		copiedFld := &ast.FieldDefinition{
+			Name:         fld.Name,
+			Description:  fld.Description,
+			DefaultValue: fld.DefaultValue,
+			Type:         copyType(fld.Type, rename),
+			Directives:   deprecation(fld.Directives),
+		}
+		for _, arg := range fld.Arguments {
+			copiedFld.Arguments = append(copiedFld.Arguments, &ast.ArgumentDefinition{
+				Name:         arg.Name,
+				Description:  arg.Description,
+				DefaultValue: arg.DefaultValue,
+				Type:         copyType(arg.Type, rename),
+			})
+		}
+		copied.Fields = append(copied.Fields, copiedFld)
+	}
+
+	return copied
+}
+
+func copyType(typ *ast.Type, rename func(string) string) *ast.Type {
+	if typ == nil {
+		return nil
+	}
+	copied := &ast.Type{NonNull: typ.NonNull, Elem: copyType(typ.Elem, rename)}
+	if typ.NamedType != "" {
+		copied.NamedType = rename(typ.NamedType)
+	}
+	return copied
+}
+
+func deprecation(dirs ast.DirectiveList) ast.DirectiveList {
+	if dep := dirs.ForName(deprecatedDirective); dep != nil {
+		return ast.DirectiveList{dep}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const paymentsSDL = `
+type Payment implements Node {
+	id: ID!
+	amount: Float!
+	status: Status @deprecated(reason: "use state")
+}
+
+interface Node {
+	id: ID!
+}
+
+enum Status {
+	PAID
+	PENDING
+}
+
+input PaymentInput {
+	amount: Float!
+}
+
+type Query {
+	payment(id: ID!): Payment
+}
+
+type Mutation {
+	pay(input: PaymentInput!): Payment
+}`
+
+const localSchema = `
+type Author {
+	id: ID!
+	name: String!
+}`
+
+var payments = RemoteAPI{
+	Field:  "payments",
+	Prefix: "Payments",
+	URL:    "http://payments/graphql",
+	SDL:    paymentsSDL,
+}
+
+func TestStitch(t *testing.T) {
+	handler, err := NewHandler(localSchema, payments)
+	require.NoError(t, err)
+
+	gql := handler.GQLSchema()
+	require.Contains(t, gql, "type PaymentsPayment implements PaymentsNode {")
+	require.Contains(t, gql, "status: PaymentsStatus @deprecated(reason: \"use state\")")
+	require.Contains(t, gql, "input PaymentsPaymentInput {")
+	require.Contains(t, gql, "payments: PaymentsQuery!")
+	require.Contains(t, gql, "payments: PaymentsMutation!")
+	require.Contains(t, gql, "pay(input: PaymentsPaymentInput!): PaymentsPayment")
+
+	require.NotContains(t, handler.DGSchema(), "Payment",
+		"remote types aren't stored in Dgraph")
+
+	op, err := handler.Schema().Operation(&Request{
+		Query: `query { getAuthor(id: "0x1") { name }
+			payments { payment(id: "1") { amount } } }`,
+	})
+	require.NoError(t, err)
+	require.Len(t, op.Queries(), 2)
+	require.Equal(t, GetQuery, op.Queries()[0].QueryType())
+	require.Nil(t, op.Queries()[0].Remote())
+	require.Equal(t, RemoteQuery, op.Queries()[1].QueryType())
+	require.Equal(t, &payments, op.Queries()[1].Remote())
+
+	op, err = handler.Schema().Operation(&Request{
+		Query: `mutation { payments { pay(input: {amount: 1.5}) { id } } }`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, RemoteMutation, op.Mutations()[0].MutationType())
+}
+
+func TestStitchConflicts(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		remote RemoteAPI
+		err    string
+	}{
+		"type name taken": {
+			input:  localSchema + "\ntype PaymentsPayment { id: ID! }",
+			remote: payments,
+			err: "Remote API payments has type Payment, which becomes PaymentsPayment, " +
+				"but there's already a type with that name.",
+		},
+		"field name taken": {
+			input:  localSchema,
+			remote: RemoteAPI{Field: "getAuthor", Prefix: "P", SDL: paymentsSDL},
+			err: "Remote API getAuthor can't be added to Query because it already " +
+				"has a field with that name.",
+		},
+		"invalid remote schema": {
+			input:  localSchema,
+			remote: RemoteAPI{Field: "p", Prefix: "P", URL: "http://p", SDL: "type Query { x: Y }"},
+			err:    "The schema of remote API p (at http://p) isn't valid",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewHandler(test.input, test.remote)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.err)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"io"
+
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// UploadType is the scalar of files uploaded with a request.
+const UploadType = "Upload"
+
+// An @upload field is a String field that's set by uploading a file, e.g.
+//
+//	type Author {
+//		id: ID!
+//		name: String!
+//		avatar: String @upload
+//	}
+//
+// In AddAuthorInput and AuthorPatch, avatar is an Upload: a file sent with
+// the mutation in a multipart request, as in the GraphQL multipart request
+// spec.  The server stores the file, and what's stored in the field, and
+// returned by queries, is the URL the file can be fetched from.
+
+// Upload is a file uploaded with a request, the value of an Upload variable.
+// It's written to JSON, as in the request log, as its name, type and size.
+type Upload struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int64  `json:"size"`
+
+	// Open opens the file's contents.
+	Open func() (io.ReadCloser, error) `json:"-"`
+
+	// URL is where the file was stored, once it has been.
+	URL string `json:"url,omitempty"`
+}
+
+// isUpload returns true if fld is an @upload field.
+func isUpload(fld *ast.FieldDefinition) bool {
+	return fld.Directives.ForName(uploadDirective) != nil
+}
+
+func uploadRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	for _, arg := range field.Arguments {
+		if arg.Type.Name() == UploadType {
+			return gqlerror.ErrorPosf(arg.Position,
+				"Type %s; Field %s: argument %s can't be an %s; files can only be uploaded "+
+					"to @upload fields.", defn.Name, field.Name, arg.Name, UploadType)
+		}
+	}
+
+	dir := field.Directives.ForName(uploadDirective)
+	switch {
+	case dir == nil && field.Type.Name() == UploadType:
+		return gqlerror.ErrorPosf(field.Position,
+			"Type %s; Field %s: fields can't be of type %s; an uploaded file is stored as "+
+				"its URL, in a String @upload field.", defn.Name, field.Name, UploadType)
+	case dir == nil:
+		return nil
+	case field.Type.NamedType != "String":
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @upload is only allowed on String fields.",
+			defn.Name, field.Name)
+	case defn.Kind != ast.Object || isRemote(defn) || isEdge(defn) ||
+		isCustom(field) || isLambda(field):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @upload is only allowed on fields of object types that are "+
+				"stored in Dgraph.", defn.Name, field.Name)
+	case isXid(field) || field.Directives.ForName(defaultDirective) != nil:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: an @upload field is set by uploading a file, so it can't "+
+				"have @xid or @default.", defn.Name, field.Name)
+	}
+	return nil
+}
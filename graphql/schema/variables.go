@@ -0,0 +1,428 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/types"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// coerceVariables coerces the variables given with a request into the types
+// that op declares for them, as per the GraphQL spec's input coercion.  The
+// values are as they come from decoding JSON (or as Go values of the same
+// kinds), and the result is canonical: Int is int64, Float is float64, ID,
+// String, DateTime and enums are strings, and a single value given for a list
+// becomes a list of one.  Variables that aren't given take their default.
+//
+// Every problem is reported, each with the path to the bad value, e.g.
+//
+//	variable "input.posts[2].title": expected String!, got Int
+func coerceVariables(sch *ast.Schema, op *ast.OperationDefinition,
+	vars map[string]interface{}) (map[string]interface{}, gqlerror.List) {
+
+	c := &coercer{schema: sch, what: "variable"}
+	coerced := make(map[string]interface{}, len(op.VariableDefinitions))
+	for _, vd := range op.VariableDefinitions {
+		c.pos = vd.Position
+
+		val, given := vars[vd.Variable]
+		if !given && vd.DefaultValue != nil {
+			def, err := vd.DefaultValue.Value(nil)
+			if err != nil {
+				c.errorf(vd.Variable, "the default value %s isn't valid: %s", vd.DefaultValue, err)
+				continue
+			}
+			val, given = def, true
+		}
+		if !given {
+			if vd.Type.NonNull {
+				c.errorf(vd.Variable, "expected %s, but it wasn't given", vd.Type)
+			}
+			continue
+		}
+
+		if res, ok := c.coerce(vd.Variable, vd.Type, val); ok {
+			coerced[vd.Variable] = res
+		}
+	}
+
+	if len(c.errs) > 0 {
+		return nil, c.errs
+	}
+	return coerced, nil
+}
+
+// argumentValues gives the values of args, the arguments given to a field
+// with argument definitions defs, coerced as variables are: input objects
+// get their defaults, a single value given for a list becomes a list of one,
+// and an Int given for a Float becomes a float64.  A variable that wasn't
+// given leaves its argument, or its field of an input object, out, so the
+// definition's default, if there is one, applies.
+func argumentValues(sch *ast.Schema, defs ast.ArgumentDefinitionList, args ast.ArgumentList,
+	vars map[string]interface{}) (map[string]interface{}, gqlerror.List) {
+
+	c := &coercer{schema: sch, what: "argument"}
+	res := make(map[string]interface{}, len(defs))
+	for _, def := range defs {
+		var val interface{}
+		var given bool
+		if arg := args.ForName(def.Name); arg != nil {
+			c.pos = arg.Position
+			val, given = literalValue(arg.Value, vars)
+		}
+		if !given && def.DefaultValue != nil {
+			c.pos = def.Position
+			val, given = literalValue(def.DefaultValue, nil)
+		}
+		if !given {
+			continue
+		}
+
+		if coerced, ok := c.coerce(def.Name, def.Type, val); ok {
+			val = coerced
+		}
+		res[def.Name] = val
+	}
+	return res, c.errs
+}
+
+// literalValue gives the value of v, as written in a request or schema, with
+// the variables in it substituted.  It's false if v is a variable that wasn't
+// given.
+func literalValue(v *ast.Value, vars map[string]interface{}) (interface{}, bool) {
+	switch v.Kind {
+	case ast.Variable:
+		val, given := vars[v.Raw]
+		return val, given
+	case ast.ListValue:
+		list := make([]interface{}, 0, len(v.Children))
+		for _, item := range v.Children {
+			// A variable that wasn't given is null in a list.
+			val, _ := literalValue(item.Value, vars)
+			list = append(list, val)
+		}
+		return list, true
+	case ast.ObjectValue:
+		obj := make(map[string]interface{}, len(v.Children))
+		for _, fld := range v.Children {
+			if val, given := literalValue(fld.Value, vars); given {
+				obj[fld.Name] = val
+			}
+		}
+		return obj, true
+	default:
+		val, err := v.Value(nil)
+		return val, err == nil
+	}
+}
+
+// checkArguments checks the arguments given to the fields in sel.  The
+// validator has already checked that they're the right kinds of values, but
+// it doesn't know what's a valid value of scalars, like DateTime, that aren't
+// built into GraphQL.
+func checkArguments(sch *ast.Schema, sel ast.SelectionSet,
+	vars map[string]interface{}) gqlerror.List {
+
+	var errs gqlerror.List
+	checked := make(map[string]bool)
+
+	var check func(sel ast.SelectionSet)
+	check = func(sel ast.SelectionSet) {
+		for _, s := range sel {
+			switch s := s.(type) {
+			case *ast.Field:
+				if s.Definition != nil {
+					_, argErrs := argumentValues(sch, s.Definition.Arguments, s.Arguments, vars)
+					errs = append(errs, argErrs...)
+				}
+				check(s.SelectionSet)
+			case *ast.InlineFragment:
+				check(s.SelectionSet)
+			case *ast.FragmentSpread:
+				if s.Definition != nil && !checked[s.Name] {
+					checked[s.Name] = true
+					check(s.Definition.SelectionSet)
+				}
+			}
+		}
+	}
+
+	check(sel)
+	return errs
+}
+
+// A coercer coerces the values of variables, or of arguments, gathering up
+// the problems.
+type coercer struct {
+	schema *ast.Schema
+	what   string
+	pos    *ast.Position
+	errs   gqlerror.List
+}
+
+func (c *coercer) errorf(path, format string, args ...interface{}) {
+	err := gqlerror.ErrorPosf(c.pos, "%s %q: %s", c.what, path, fmt.Sprintf(format, args...))
+	c.errs = append(c.errs, err)
+}
+
+// coerce coerces val, found at path, to typ.  It returns false, having
+// recorded why, if val isn't a typ.
+func (c *coercer) coerce(path string, typ *ast.Type, val interface{}) (interface{}, bool) {
+	if val == nil {
+		if typ.NonNull {
+			c.errorf(path, "expected %s, got null", typ)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	if typ.Elem != nil {
+		items, isList := asList(val)
+		if !isList {
+			// A single value is coerced to a list of one.
+			item, ok := c.coerce(path, typ.Elem, val)
+			return []interface{}{item}, ok
+		}
+
+		res := make([]interface{}, len(items))
+		ok := true
+		for i, item := range items {
+			var itemOK bool
+			res[i], itemOK = c.coerce(fmt.Sprintf("%s[%d]", path, i), typ.Elem, item)
+			ok = ok && itemOK
+		}
+		return res, ok
+	}
+
+	defn := c.schema.Types[typ.NamedType]
+	if defn == nil {
+		c.errorf(path, "unknown type %s", typ.NamedType)
+		return nil, false
+	}
+
+	switch defn.Kind {
+	case ast.InputObject:
+		return c.coerceObject(path, typ, defn, val)
+	case ast.Enum:
+		if s, ok := val.(string); ok && defn.EnumValues.ForName(s) != nil {
+			return s, true
+		}
+		values := make([]string, len(defn.EnumValues))
+		for i, ev := range defn.EnumValues {
+			values[i] = ev.Name
+		}
+		c.errorf(path, "expected %s (one of %s), got %s", typ,
+			strings.Join(values, ", "), describe(val))
+		return nil, false
+	default:
+		res, ok := coerceScalar(typ.NamedType, val)
+		if !ok {
+			if i, isInt := asInt(val); isInt && typ.NamedType == "Int" {
+				c.errorf(path, "expected %s, got %d, which doesn't fit in a 32-bit Int",
+					typ, i)
+			} else if str, isStr := val.(string); isStr && typ.NamedType == "DateTime" {
+				c.errorf(path, "expected %s, got %q, which isn't a date and time "+
+					"(such as 2019-05-01 or 2019-05-01T10:30:00Z)", typ, str)
+			} else if typ.NamedType == UploadType {
+				c.errorf(path, "expected %s, got %s; files are uploaded in multipart "+
+					"requests", typ, describe(val))
+			} else {
+				c.errorf(path, "expected %s, got %s", typ, describe(val))
+			}
+		}
+		return res, ok
+	}
+}
+
+func (c *coercer) coerceObject(path string, typ *ast.Type, defn *ast.Definition,
+	val interface{}) (interface{}, bool) {
+
+	obj, isObj := val.(map[string]interface{})
+	if !isObj {
+		c.errorf(path, "expected %s, got %s", typ, describe(val))
+		return nil, false
+	}
+
+	var unknown []string
+	for name := range obj {
+		if defn.Fields.ForName(name) == nil {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		c.errorf(path+"."+name, "%s has no field %s", defn.Name, name)
+	}
+
+	ok := len(unknown) == 0
+
+	res := make(map[string]interface{}, len(obj))
+	for _, fld := range defn.Fields {
+		fldPath := path + "." + fld.Name
+		fv, given := obj[fld.Name]
+		if !given && fld.DefaultValue != nil {
+			def, err := fld.DefaultValue.Value(nil)
+			if err == nil {
+				fv, given = def, true
+			}
+		}
+		if !given {
+			if fld.Type.NonNull {
+				c.errorf(fldPath, "expected %s, but it wasn't given", fld.Type)
+				ok = false
+			}
+			continue
+		}
+
+		coerced, fldOK := c.coerce(fldPath, fld.Type, fv)
+		res[fld.Name] = coerced
+		ok = ok && fldOK
+	}
+	return res, ok
+}
+
+// coerceScalar coerces val to the scalar called name.  A DateTime must be in
+// one of the formats Dgraph accepts for datetime predicates.  Scalars that
+// Dgraph doesn't know are passed through as they are.
+func coerceScalar(name string, val interface{}) (interface{}, bool) {
+	switch name {
+	case "Int":
+		i, ok := asInt(val)
+		if !ok || i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, false
+		}
+		return i, true
+	case "Float":
+		return asFloat(val)
+	case "Boolean":
+		b, ok := val.(bool)
+		return b, ok
+	case "String":
+		s, ok := val.(string)
+		return s, ok
+	case "DateTime":
+		s, ok := val.(string)
+		if !ok {
+			return nil, false
+		}
+		if _, err := types.ParseTime(s); err != nil {
+			return nil, false
+		}
+		return s, true
+	case UploadType:
+		u, ok := val.(*Upload)
+		return u, ok
+	case "ID":
+		if s, ok := val.(string); ok {
+			return s, true
+		}
+		if i, ok := asInt(val); ok {
+			return strconv.FormatInt(i, 10), true
+		}
+		return nil, false
+	default:
+		return val, true
+	}
+}
+
+// asInt returns val as an int64, if it's a whole number.
+func asInt(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case json.Number:
+		i, err := v.Int64()
+		if err != nil {
+			f, ferr := v.Float64()
+			if ferr != nil || f != math.Trunc(f) {
+				return 0, false
+			}
+			return asInt(f)
+		}
+		return i, true
+	case float32, float64:
+		f := reflect.ValueOf(v).Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	case int, int8, int16, int32, int64:
+		return reflect.ValueOf(v).Int(), true
+	}
+	return 0, false
+}
+
+func asFloat(val interface{}) (interface{}, bool) {
+	switch v := val.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float32, float64:
+		return reflect.ValueOf(v).Float(), true
+	case int, int8, int16, int32, int64:
+		return float64(reflect.ValueOf(v).Int()), true
+	}
+	return nil, false
+}
+
+// asList returns val as a list, if it is one.
+func asList(val interface{}) ([]interface{}, bool) {
+	if l, ok := val.([]interface{}); ok {
+		return l, true
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	l := make([]interface{}, rv.Len())
+	for i := range l {
+		l[i] = rv.Index(i).Interface()
+	}
+	return l, true
+}
+
+// describe describes val, for errors, by the GraphQL kind of value it is.
+// Strings are shown too, so that, say, a misspelt enum value can be seen.
+func describe(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "Boolean"
+	case string:
+		return "String " + strconv.Quote(v)
+	case map[string]interface{}:
+		return "an object"
+	}
+	if _, ok := asInt(val); ok {
+		return "Int"
+	}
+	if _, ok := asFloat(val); ok {
+		return "Float"
+	}
+	if _, ok := asList(val); ok {
+		return "a list"
+	}
+	return fmt.Sprintf("%T", val)
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const variablesSchema = `
+type Author {
+	id: ID!
+	name: String! @search(by: [hash])
+	posts: [Post]
+}
+
+type Post {
+	id: ID!
+	title: String!
+	numLikes: Int
+	category: Category
+	published: DateTime
+}
+
+enum Category {
+	Tech
+	Fun
+}`
+
+func TestVariableCoercion(t *testing.T) {
+	handler, err := NewHandler(variablesSchema)
+	require.NoError(t, err)
+
+	const addAuthor = `mutation($input: [AddAuthorInput!]!) { addAuthor(input: $input) {
+		author { id } } }`
+	tests := []struct {
+		name     string
+		query    string
+		vars     string
+		coerced  string
+		messages []string
+	}{
+		{
+			name:  "JSON values are made canonical",
+			query: addAuthor,
+			vars: `{"input": {"name": "A.N. Author", "posts": [
+				{"title": "GraphQL", "numLikes": 10.0, "category": "Tech"}]}}`,
+			coerced: `{"input": [{"name": "A.N. Author", "posts": [
+				{"title": "GraphQL", "numLikes": 10, "category": "Tech"}]}]}`,
+		},
+		{
+			// Posts are references, so all their fields are nullable.
+			name:     "nested path",
+			query:    addAuthor,
+			vars:     `{"input": [{"name": "A", "posts": [{"title": "a"}, {"title": "b"}, {"title": 3}]}]}`,
+			messages: []string{`variable "input[0].posts[2].title": expected String, got Int`},
+		},
+		{
+			name:  "every problem is reported",
+			query: addAuthor,
+			vars: `{"input": [{"posts": {"title": "a", "numLikes": 1.5, "category": "Science"},
+				"age": 42}]}`,
+			messages: []string{
+				`variable "input[0].age": AddAuthorInput has no field age`,
+				`variable "input[0].name": expected String!, but it wasn't given`,
+				`variable "input[0].posts.numLikes": expected Int, got Float`,
+				`variable "input[0].posts.category": expected Category (one of Tech, Fun), ` +
+					`got String "Science"`,
+			},
+		},
+		{
+			name:  "Int out of range",
+			query: `query($n: Int) { queryAuthor(first: $n) { name } }`,
+			vars:  `{"n": 3000000000}`,
+			messages: []string{
+				`variable "n": expected Int, got 3000000000, which doesn't fit in a 32-bit Int`},
+		},
+		{
+			name:     "missing variable",
+			query:    `query($id: ID!) { getAuthor(id: $id) { name } }`,
+			vars:     `{}`,
+			messages: []string{`variable "id": expected ID!, but it wasn't given`},
+		},
+		{
+			name:     "null for a non-null variable",
+			query:    `query($id: ID!) { getAuthor(id: $id) { name } }`,
+			vars:     `{"id": null}`,
+			messages: []string{`variable "id": expected ID!, got null`},
+		},
+		{
+			name:    "an Int is an ID",
+			query:   `query($id: ID!) { getAuthor(id: $id) { name } }`,
+			vars:    `{"id": 1}`,
+			coerced: `{"id": "1"}`,
+		},
+		{
+			name:     "an object isn't a String",
+			query:    `query($f: AuthorFilter) { queryAuthor(filter: $f) { name } }`,
+			vars:     `{"f": {"name": {"eq": {"x": 1}}}}`,
+			messages: []string{`variable "f.name.eq": expected String, got an object`},
+		},
+		{
+			name:  "DateTime",
+			query: addAuthor,
+			vars: `{"input": [{"name": "A", "posts": [
+				{"title": "a", "published": "2019-05-01T10:30:00Z"},
+				{"title": "b", "published": "yesterday"}]}]}`,
+			messages: []string{`variable "input[0].posts[1].published": expected DateTime, ` +
+				`got "yesterday", which isn't a date and time ` +
+				`(such as 2019-05-01 or 2019-05-01T10:30:00Z)`},
+		},
+		{
+			name:    "defaults",
+			query:   `query($n: Int = 10) { queryAuthor(first: $n) { name } }`,
+			vars:    `{}`,
+			coerced: `{"n": 10}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var vars map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(test.vars), &vars))
+
+			op, err := handler.Schema().Operation(&Request{Query: test.query, Variables: vars})
+			if test.messages != nil {
+				require.Error(t, err)
+				var messages []string
+				for _, e := range AsGQLErrors(err) {
+					messages = append(messages, e.Message)
+				}
+				require.Equal(t, test.messages, messages)
+				return
+			}
+
+			require.NoError(t, err)
+			coerced, err := json.Marshal(op.(*operation).vars)
+			require.NoError(t, err)
+			require.JSONEq(t, test.coerced, string(coerced))
+		})
+	}
+}
+
+func TestArgumentCoercion(t *testing.T) {
+	handler, err := NewHandler(variablesSchema)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		query string
+		vars  map[string]interface{}
+		args  string
+	}{
+		{
+			name:  "a single value is a list of one",
+			query: `query { queryAuthor(filter: {id: "0x1"}) { name } }`,
+			args:  `{"filter": {"id": ["0x1"]}}`,
+		},
+		{
+			name: "a variable that isn't given is left out",
+			query: `query($n: String, $m: Int) {
+				queryAuthor(filter: {name: {eq: $n}}, first: $m) { name } }`,
+			args: `{"filter": {"name": {}}}`,
+		},
+		{
+			name:  "a variable that's given null is null",
+			query: `query($m: Int) { queryAuthor(first: $m) { name } }`,
+			vars:  map[string]interface{}{"m": nil},
+			args:  `{"first": null}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			op, err := handler.Schema().Operation(&Request{Query: test.query, Variables: test.vars})
+			require.NoError(t, err)
+
+			args, err := json.Marshal(op.Queries()[0].Arguments())
+			require.NoError(t, err)
+			require.JSONEq(t, test.args, string(args))
+		})
+	}
+
+	_, err = handler.Schema().Operation(&Request{Query: `mutation {
+		addAuthor(input: [{name: "A", posts: [{title: "a", published: "soon"}]}]) {
+			author { id } } }`})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `argument "input[0].posts[0].published": `+
+		`expected DateTime, got "soon"`)
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// A @version field counts the updates of an object, so that clients can't
+// overwrite each other's changes without knowing it:
+//
+//	type Document {
+//		id: ID!
+//		text: String!
+//		version: Int! @version
+//	}
+//
+// An object starts at version 1, and each update moves it on to the next
+// version.  updateDocument takes the version that the client last read, and
+// fails with a VERSION_CONFLICT error, changing nothing, if any object the
+// filter finds has moved on from that version.  The field can be read and
+// filtered on, but isn't in the inputs of add and update mutations.
+
+// VersionArgName is the field of the input of updateT, for a type T with a
+// @version field, that's the version the objects are expected to be at.
+const VersionArgName = "version"
+
+// isVersion returns true if fld is a @version field.
+func isVersion(fld *ast.FieldDefinition) bool {
+	return fld.Directives.ForName(versionDirective) != nil
+}
+
+// versionField returns the @version field of defn, or nil if it doesn't have
+// one.
+func versionField(defn *ast.Definition) *ast.FieldDefinition {
+	for _, fld := range defn.Fields {
+		if isVersion(fld) {
+			return fld
+		}
+	}
+	return nil
+}
+
+func versionRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(versionDirective)
+	if dir == nil {
+		return nil
+	}
+
+	switch {
+	case defn.Kind != ast.Object || isRemote(defn) || isEdge(defn) ||
+		reservedTypeNames[defn.Name] || isCustom(field):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @version is only allowed on fields of object types that are "+
+				"stored in Dgraph.", defn.Name, field.Name)
+	case field.Type.NamedType != "Int":
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @version is only allowed on Int fields.",
+			defn.Name, field.Name)
+	case field.Directives.ForName(defaultDirective) != nil:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: a @version field's value is set by Dgraph, so it can't have "+
+				"@default.", defn.Name, field.Name)
+	case versionField(defn) != field:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: type %s already has @version field %s, and can only have one.",
+			defn.Name, field.Name, defn.Name, versionField(defn).Name)
+	}
+	return nil
+}
+
+// VersionField returns the @version field of t, or nil if it doesn't have
+// one.
+func (t *astType) VersionField() FieldDefinition {
+	if info := t.inSchema.types[t.Name()]; info != nil && info.version != nil {
+		return info.version
+	}
+	return nil
+}
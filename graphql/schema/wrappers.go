@@ -0,0 +1,1273 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// Wrap the github.com/vektah/gqlparser/ast defintions so that the bulk of the
+// GraphQL layer need not know anything about the underlying GraphQL library,
+// and so that the resolvers can ask Dgraph-specific questions (e.g. "what
+// predicate stores this field?") of the schema.
+
+// QueryType is the kind of a generated query.
+type QueryType string
+
+// MutationType is the kind of a generated mutation.
+type MutationType string
+
+// Query/Mutation types and arg names
+const (
+	GetQuery             QueryType    = "get"
+	FilterQuery          QueryType    = "query"
+	SchemaQuery          QueryType    = "schema"
+	RemoteQuery          QueryType    = "remote"
+	CustomQuery          QueryType    = "custom"
+	LambdaQuery          QueryType    = "lambda"
+	EntitiesQuery        QueryType    = "entities"
+	ServiceQuery         QueryType    = "service"
+	SimilarQuery         QueryType    = "similar"
+	NotSupportedQuery    QueryType    = "notsupported"
+	AddMutation          MutationType = "add"
+	UpdateMutation       MutationType = "update"
+	DeleteMutation       MutationType = "delete"
+	RestoreMutation      MutationType = "restore"
+	RemoteMutation       MutationType = "remote"
+	CustomMutation       MutationType = "custom"
+	LambdaMutation       MutationType = "lambda"
+	TypenameMutation     MutationType = "typename"
+	NotSupportedMutation MutationType = "notsupported"
+	IDType                            = "ID"
+	IDArgName                         = "id"
+	InputArgName                      = "input"
+	FilterArgName                     = "filter"
+)
+
+// IncludeDeletedArgName is the argument of queries for @softDelete types that
+// asks for the deleted objects too.
+const IncludeDeletedArgName = "includeDeleted"
+
+// An EnumValue is the value of an enum, as given by Field.Arguments, so that
+// it can be told apart from a string.
+type EnumValue string
+
+// Schema represents a valid GraphQL schema
+type Schema interface {
+	Operation(r *Request) (Operation, error)
+	OperationContext(ctx context.Context, r *Request) (Operation, error)
+	Queries(t QueryType) []string
+	Mutations(t MutationType) []string
+
+	// MutationName returns the name of the mutation of kind t that's
+	// generated for the type typeName, or "" if there isn't one.
+	MutationName(t MutationType, typeName string) string
+
+	// StoredTypes returns the object types that are stored in Dgraph, in
+	// order of name.
+	StoredTypes() []Type
+
+	// FlushOperationCache forgets the operations that have been parsed and
+	// validated against the schema, and returns how many there were.
+	FlushOperationCache() int
+}
+
+// An Operation is a single valid GraphQL operation.  It contains either
+// Queries, Mutations or Subscriptions.
+type Operation interface {
+	Queries() []Query
+	Mutations() []Mutation
+	Subscriptions() []Query
+	IsQuery() bool
+	IsMutation() bool
+	IsSubscription() bool
+	Atomic() bool
+	Incremental(data []byte) ([]byte, []*Incremental, error)
+}
+
+// A Field is one field from an Operation.
+type Field interface {
+	Name() string
+	Alias() string
+	ResponseName() string
+	ArgValue(name string) interface{}
+	Arguments() map[string]interface{}
+	IDArgValue() (uint64, error)
+	Type() Type
+	SelectionSet() []Field
+	SelectionSetFor(typName string) []Field
+	Location() *gqlerror.Location
+	DgraphPredicate() string
+	GetObjectName() string
+	CustomHTTP() *CustomHTTP
+	Lambda() bool
+	Computed() *ComputedExpr
+	ErrorPolicy() ErrorPolicy
+	CacheHint() CacheHint
+	Cascade() (bool, []string)
+	Auth() *FieldAuth
+}
+
+// A Mutation is a field (from the schema's Mutation type) from an Operation
+type Mutation interface {
+	Field
+	MutationType() MutationType
+	MutatedType() Type
+	QueryField() Field
+	Remote() *RemoteAPI
+}
+
+// A Query is a field (from the schema's Query type) from an Operation
+type Query interface {
+	Field
+	QueryType() QueryType
+	Remote() *RemoteAPI
+	Plan(key string) (interface{}, bool)
+	SetPlan(key string, p interface{})
+}
+
+// A Type is a GraphQL type like: Float, T, T! and [T!]!.  If it's not a list,
+// then ListType is nil.  If it's an object type then Field gets field
+// definitions by name from the definition of the type, Fields gets them all
+// and IDField gets the ID field of the type.
+type Type interface {
+	Field(name string) FieldDefinition
+	Fields() []FieldDefinition
+	IDField() FieldDefinition
+	IsEdge() bool
+	ReferencedBy() []FieldDefinition
+	SoftDelete() FieldDefinition
+	MutationEvents() *MutationEvents
+	VersionField() FieldDefinition
+	XidField() FieldDefinition
+	Addable() bool
+	Name() string
+	DgraphName() string
+	Nullable() bool
+	ListType() Type
+	Interfaces() []string
+	PossibleTypes() []string
+	Implementations() []Type
+	AuthRule(op AuthOperation) *AuthRule
+	fmt.Stringer
+}
+
+// A FieldDefinition is a field as defined in some Type in the schema.  As
+// opposed to a Field, which is an instance of a query or mutation asking for
+// a field (which in turn must have a FieldDefinition of the right type in the
+// schema.)
+type FieldDefinition interface {
+	Name() string
+	Type() Type
+	IsID() bool
+	Private() bool
+	Inverse() FieldDefinition
+	DgraphPredicate() string
+	DefaultValue(mut MutationType, now time.Time) (interface{}, bool)
+	EdgeOf() FieldDefinition
+	OnDelete() DeleteAction
+	ParentType() Type
+	Auth() *FieldAuth
+}
+
+type schema struct {
+	schema *ast.Schema
+
+	// queries and mutations map the name of each generated query and
+	// mutation to its kind and the type it's for.
+	queries   map[string]generated
+	mutations map[string]generated
+
+	// stored are the object types stored in Dgraph, which have queries and
+	// mutations generated.
+	stored map[string]bool
+
+	// types holds what the resolvers need to know about each type that has
+	// fields.  It's worked out once, when the schema is built, so resolving a
+	// request doesn't have to search the AST.
+	types map[string]*typeInfo
+
+	// remotes maps the root fields that remote APIs are stitched in at to
+	// the remote API.
+	remotes map[string]*RemoteAPI
+
+	// sdl is the SDL that a federation gateway is given, if the schema has
+	// any entities.
+	sdl string
+
+	// operations are the query documents that have been parsed and validated
+	// against the schema.
+	operations *operationCache
+}
+
+type typeInfo struct {
+	fields  map[string]*fieldDefinition
+	ordered []FieldDefinition
+	idField *fieldDefinition
+	auth    map[AuthOperation]*AuthRule
+
+	// edge is true for generated edge types.
+	edge bool
+
+	// referencedBy are the @onDelete fields that link to the type.
+	referencedBy []FieldDefinition
+
+	// deletedAt is the field that marks the deleted objects of a @softDelete
+	// type.
+	deletedAt *fieldDefinition
+
+	// version is the @version field of the type, if it has one.
+	version *fieldDefinition
+
+	// xid is the @xid field of the type, if it has one.
+	xid *fieldDefinition
+
+	// events are the events that the type's mutations send.
+	events *MutationEvents
+}
+
+type generated struct {
+	kind string
+	typ  string
+}
+
+type operation struct {
+	op   *ast.OperationDefinition
+	vars map[string]interface{}
+
+	inSchema *schema
+
+	// cached is op's document in the schema's operation cache, if op has no
+	// variables, so the plans for its queries can be cached too.
+	cached *cachedOperation
+}
+
+type field struct {
+	field *ast.Field
+	op    *operation
+}
+
+type fieldDefinition struct {
+	fieldDef   *ast.FieldDefinition
+	parentType string
+	inSchema   *schema
+
+	// predicate is the Dgraph predicate that stores the field, or "" if it
+	// isn't stored in Dgraph.
+	predicate string
+	inverse   *fieldDefinition
+
+	// custom is the HTTP call that resolves a @custom field.
+	custom *CustomHTTP
+
+	// lambda is true for a @lambda field, which the lambda server resolves.
+	lambda bool
+
+	// onError is what happens to the field's object if the field can't be
+	// resolved.
+	onError ErrorPolicy
+
+	// cache is how long the field's value can be cached, and by whom.
+	cache CacheHint
+
+	// defaults are the values the field's @default sets, by mutation.
+	defaults map[MutationType]string
+
+	// computed is the expression of a @computed field.
+	computed *ComputedExpr
+
+	// edgeOf is the @facets field whose edges this field has.
+	edgeOf *fieldDefinition
+
+	// auth restricts the field to requests with some roles.
+	auth *FieldAuth
+}
+
+type mutation field
+type query field
+
+type astType struct {
+	typ      *ast.Type
+	inSchema *schema
+}
+
+// AsSchema wraps a github.com/vektah/gqlparser/ast.Schema that's been
+// completed by GenerateCompleteSchema, with PrefixedNaming.
+func AsSchema(s *ast.Schema) Schema {
+	return asSchema(s, nil, PrefixedNaming)
+}
+
+// asSchema wraps s, which was completed with naming and has had the fields in
+// hidden taken out of its types by hidePrivateFields.
+func asSchema(s *ast.Schema, hidden map[string][]hiddenField, naming APINaming) *schema {
+	sch := &schema{
+		schema:     s,
+		queries:    make(map[string]generated),
+		mutations:  make(map[string]generated),
+		stored:     make(map[string]bool),
+		types:      make(map[string]*typeInfo),
+		remotes:    make(map[string]*RemoteAPI),
+		operations: newOperationCache(),
+	}
+
+	for _, name := range definitionNames(s) {
+		defn := s.Types[name]
+		if defn.BuiltIn || len(defn.Fields) == 0 {
+			continue
+		}
+		// Only the types from the input schema, other than @remote types,
+		// are stored in Dgraph.
+		stored := defn.Position != nil && !isRemote(defn) && !isEdge(defn) &&
+			(defn.Kind == ast.Object || defn.Kind == ast.Interface)
+
+		info := &typeInfo{
+			fields: make(map[string]*fieldDefinition, len(defn.Fields)),
+			auth:   parseAuthRules(defn),
+			events: mutationEvents(defn),
+		}
+		for _, fld := range defn.Fields {
+			fd := &fieldDefinition{fieldDef: fld, parentType: name, inSchema: sch}
+			// The directive was checked when the schema was validated.
+			fd.custom, _ = customHTTP(fld)
+			fd.lambda = isLambda(fld)
+			fd.onError = errorPolicy(defn, fld)
+			fd.cache = cacheHint(fld, s.Types[fld.Type.Name()])
+			fd.defaults = defaultValues(fld)
+			fd.auth = fieldAuth(fld)
+			if stored {
+				fd.computed = computedExpr(s, defn, fld)
+			}
+			if stored && fd.custom == nil && !fd.lambda && fd.computed == nil {
+				fd.predicate = dgraphPredicate(s, defn, fld.Name)
+			}
+			info.fields[fld.Name] = fd
+			info.ordered = append(info.ordered, fd)
+		}
+		for _, hf := range hidden[name] {
+			fd := &fieldDefinition{fieldDef: hf.fieldDef, parentType: name, inSchema: sch,
+				predicate: hf.predicate}
+			info.fields[hf.fieldDef.Name] = fd
+			info.ordered = append(info.ordered, fd)
+		}
+		if idFld := idField(defn); idFld != nil {
+			info.idField = info.fields[idFld.Name]
+		}
+		if stored {
+			if vFld := versionField(defn); vFld != nil {
+				info.version = info.fields[vFld.Name]
+			}
+			if xFld := xidField(defn); xFld != nil {
+				info.xid = info.fields[xFld.Name]
+			}
+		}
+		sch.types[name] = info
+
+		if !stored {
+			continue
+		}
+		names := namesFor(defn, naming)
+		sch.queries[names.get] = generated{kind: string(GetQuery), typ: name}
+		sch.queries[names.query] = generated{kind: string(FilterQuery), typ: name}
+		if defn.Kind == ast.Interface {
+			continue
+		}
+		sch.mutations[names.add] = generated{kind: string(AddMutation), typ: name}
+		sch.mutations[names.update] = generated{kind: string(UpdateMutation), typ: name}
+		sch.mutations[names.delete] = generated{kind: string(DeleteMutation), typ: name}
+		if isSoftDelete(defn) {
+			sch.mutations[names.restore] = generated{kind: string(RestoreMutation), typ: name}
+			info.deletedAt = info.fields[deletedAtField]
+		}
+		if len(embeddingFields(defn)) > 0 {
+			sch.queries[names.similar] = generated{kind: string(SimilarQuery), typ: name}
+		}
+		// A subscription is answered by running the query it mirrors.
+		sch.queries[names.subscribe] = generated{kind: string(FilterQuery), typ: name}
+		sch.stored[name] = true
+	}
+
+	if s.Query != nil && s.Query.Fields.ForName(entitiesQuery) != nil {
+		sch.queries[entitiesQuery] = generated{kind: string(EntitiesQuery)}
+		sch.queries[serviceQuery] = generated{kind: string(ServiceQuery)}
+		sch.sdl = federationSDL(s)
+	}
+
+	// The edges of @facets fields are stored in the same predicates as the
+	// fields, and the facets by their names.
+	for name := range sch.stored {
+		defn := s.Types[name]
+		for _, fld := range defn.Fields {
+			edgeInfo := sch.types[edgeTypeName(defn, fld)]
+			if facetsType(fld) == "" || edgeInfo == nil {
+				continue
+			}
+			edges := sch.types[name].fields[edgesFieldName(fld)]
+			edges.edgeOf = sch.types[name].fields[fld.Name]
+			edges.predicate = edges.edgeOf.predicate
+			edgeInfo.edge = true
+			for facet, fd := range edgeInfo.fields {
+				if facet != EdgeNodeField {
+					fd.predicate = facet
+				}
+			}
+		}
+	}
+
+	// Inverses can only be linked up once every field is known.
+	for _, info := range sch.types {
+		for _, fd := range info.fields {
+			fd.inverse = sch.findInverse(fd)
+		}
+	}
+	sch.linkReferences()
+
+	return sch
+}
+
+// fieldDefinition returns the definition of field fld of type typ, or nil if
+// there's no such field.
+func (s *schema) fieldDefinition(typ, fld string) *fieldDefinition {
+	if info := s.types[typ]; info != nil {
+		return info.fields[fld]
+	}
+	return nil
+}
+
+func (s *schema) Queries(t QueryType) []string {
+	var result []string
+	if s.schema.Query == nil {
+		return result
+	}
+	for _, q := range s.schema.Query.Fields {
+		if s.queryType(q.Name) == t {
+			result = append(result, q.Name)
+		}
+	}
+	return result
+}
+
+func (s *schema) Mutations(t MutationType) []string {
+	var result []string
+	if s.schema.Mutation == nil {
+		return result
+	}
+	for _, m := range s.schema.Mutation.Fields {
+		if s.mutationType(m.Name) == t {
+			result = append(result, m.Name)
+		}
+	}
+	return result
+}
+
+func (s *schema) MutationName(t MutationType, typeName string) string {
+	for name, g := range s.mutations {
+		if g.kind == string(t) && g.typ == typeName {
+			return name
+		}
+	}
+	return ""
+}
+
+func (s *schema) StoredTypes() []Type {
+	var types []Type
+	for _, name := range definitionNames(s.schema) {
+		if s.stored[name] {
+			types = append(types,
+				&astType{typ: &ast.Type{NamedType: name, NonNull: true}, inSchema: s})
+		}
+	}
+	return types
+}
+
+func (s *schema) queryType(name string) QueryType {
+	if strings.HasPrefix(name, "__") {
+		return SchemaQuery
+	}
+	if _, ok := s.remotes[name]; ok {
+		return RemoteQuery
+	}
+	if fd := s.fieldDefinition("Query", name); fd != nil && fd.custom != nil {
+		return CustomQuery
+	}
+	if fd := s.fieldDefinition("Query", name); fd != nil && fd.lambda {
+		return LambdaQuery
+	}
+	if g, ok := s.queries[name]; ok {
+		return QueryType(g.kind)
+	}
+	return NotSupportedQuery
+}
+
+func (s *schema) mutationType(name string) MutationType {
+	if name == "__typename" {
+		return TypenameMutation
+	}
+	if _, ok := s.remotes[name]; ok {
+		return RemoteMutation
+	}
+	if fd := s.fieldDefinition("Mutation", name); fd != nil && fd.custom != nil {
+		return CustomMutation
+	}
+	if fd := s.fieldDefinition("Mutation", name); fd != nil && fd.lambda {
+		return LambdaMutation
+	}
+	if g, ok := s.mutations[name]; ok {
+		return MutationType(g.kind)
+	}
+	return NotSupportedMutation
+}
+
+func (o *operation) IsQuery() bool {
+	return o.op.Operation == ast.Query
+}
+
+func (o *operation) IsMutation() bool {
+	return o.op.Operation == ast.Mutation
+}
+
+func (o *operation) IsSubscription() bool {
+	return o.op.Operation == ast.Subscription
+}
+
+func (o *operation) Queries() (qs []Query) {
+	if !o.IsQuery() {
+		return
+	}
+
+	for _, f := range o.rootFields() {
+		qs = append(qs, &query{field: f, op: o})
+	}
+
+	return
+}
+
+// Subscriptions returns the fields of a subscription operation.  Each is
+// answered like the query it mirrors, so it's a Query.
+func (o *operation) Subscriptions() (qs []Query) {
+	if !o.IsSubscription() {
+		return
+	}
+
+	for _, f := range o.rootFields() {
+		qs = append(qs, &query{field: f, op: o})
+	}
+
+	return
+}
+
+func (o *operation) Mutations() (ms []Mutation) {
+	if !o.IsMutation() {
+		return
+	}
+
+	for _, f := range o.rootFields() {
+		ms = append(ms, &mutation{field: f, op: o})
+	}
+
+	return
+}
+
+// rootFields returns the fields the operation asks for, leaving out those
+// excluded by @skip or @include.
+func (o *operation) rootFields() []*ast.Field {
+	var root *ast.Definition
+	switch o.op.Operation {
+	case ast.Query:
+		root = o.inSchema.schema.Query
+	case ast.Mutation:
+		root = o.inSchema.schema.Mutation
+	case ast.Subscription:
+		root = o.inSchema.schema.Subscription
+	}
+	if root == nil {
+		return nil
+	}
+	return collectFields(o.inSchema.schema, o.op.SelectionSet, root.Name, o.vars)
+}
+
+func (f *field) Name() string {
+	return f.field.Name
+}
+
+func (f *field) Alias() string {
+	return f.field.Alias
+}
+
+func (f *field) ResponseName() string {
+	return responseName(f.field)
+}
+
+func (f *field) ArgValue(name string) interface{} {
+	return f.argumentValues()[name]
+}
+
+// Arguments returns all the arguments given to f, with variables substituted
+// and coerced to the types of the arguments.  Enum values, including those
+// nested in input objects and lists, are EnumValues.
+func (f *field) Arguments() map[string]interface{} {
+	args := f.argumentValues()
+	for _, argDef := range f.field.Definition.Arguments {
+		if val, ok := args[argDef.Name]; ok {
+			args[argDef.Name] = f.op.inSchema.markEnums(argDef.Type, val)
+		}
+	}
+	return args
+}
+
+func (f *field) argumentValues() map[string]interface{} {
+	// The operation's arguments were checked when it was built, so there are
+	// no errors here.
+	args, _ := argumentValues(f.op.inSchema.schema, f.field.Definition.Arguments,
+		f.field.Arguments, f.op.vars)
+	return args
+}
+
+func (f *field) IDArgValue() (uint64, error) {
+	idField := f.Type().IDField()
+	if idField == nil {
+		return 0, errors.Errorf("type %s has no ID field", f.Type().Name())
+	}
+	return asUID(f.ArgValue(idField.Name()))
+}
+
+func (f *field) Type() Type {
+	return &astType{
+		typ:      f.field.Definition.Type,
+		inSchema: f.op.inSchema,
+	}
+}
+
+// SelectionSet returns the fields asked for on f's value.  Fragments that
+// apply to f's type are expanded, and fields excluded by @skip or @include
+// are left out, so they are neither fetched from Dgraph nor in the result.
+func (f *field) SelectionSet() []Field {
+	return f.SelectionSetFor(f.field.Definition.Type.Name())
+}
+
+// SelectionSetFor returns the fields asked for on f's value when it's an
+// object of type typName.  That's only different from SelectionSet if f's
+// type is an interface: fragments on the interface, and on typName, apply,
+// but fragments on the other types that implement it don't.
+func (f *field) SelectionSetFor(typName string) (flds []Field) {
+	sel := collectFields(f.op.inSchema.schema, f.field.SelectionSet, typName, f.op.vars)
+	for _, fld := range sel {
+		flds = append(flds, &field{field: fld, op: f.op})
+	}
+
+	return
+}
+
+func (f *field) Location() *gqlerror.Location {
+	return &gqlerror.Location{
+		Line:   f.field.Position.Line,
+		Column: f.field.Position.Column,
+	}
+}
+
+func (f *field) DgraphPredicate() string {
+	if fd := f.op.inSchema.fieldDefinition(f.field.ObjectDefinition.Name, f.field.Name); fd != nil {
+		return fd.predicate
+	}
+	return ""
+}
+
+func (f *field) GetObjectName() string {
+	return f.field.ObjectDefinition.Name
+}
+
+// CustomHTTP returns the HTTP call that resolves f, or nil if f isn't a
+// @custom field.
+func (f *field) CustomHTTP() *CustomHTTP {
+	if fd := f.op.inSchema.fieldDefinition(f.field.ObjectDefinition.Name, f.field.Name); fd != nil {
+		return fd.custom
+	}
+	return nil
+}
+
+// Lambda returns true if f is a @lambda field, which the lambda server
+// resolves.
+func (f *field) Lambda() bool {
+	fd := f.op.inSchema.fieldDefinition(f.field.ObjectDefinition.Name, f.field.Name)
+	return fd != nil && fd.lambda
+}
+
+// Computed returns the expression of f if it's a @computed field, or nil.
+func (f *field) Computed() *ComputedExpr {
+	fd := f.op.inSchema.fieldDefinition(f.field.ObjectDefinition.Name, f.field.Name)
+	if fd == nil {
+		return nil
+	}
+	return fd.computed
+}
+
+// Auth returns the @auth directive of f, or nil if anyone can see f.
+func (f *field) Auth() *FieldAuth {
+	if fd := f.op.inSchema.fieldDefinition(f.field.ObjectDefinition.Name, f.field.Name); fd != nil {
+		return fd.auth
+	}
+	return nil
+}
+
+// ErrorPolicy returns what happens to the object that f is in if f can't be
+// resolved.
+func (f *field) ErrorPolicy() ErrorPolicy {
+	if fd := f.op.inSchema.fieldDefinition(f.field.ObjectDefinition.Name, f.field.Name); fd != nil {
+		return fd.onError
+	}
+	return NullField
+}
+
+// CacheHint returns how long f's value can be cached, and by whom, as set
+// with @cacheControl.
+func (f *field) CacheHint() CacheHint {
+	if fd := f.op.inSchema.fieldDefinition(f.field.ObjectDefinition.Name, f.field.Name); fd != nil {
+		return fd.cache
+	}
+	return CacheHint{}
+}
+
+// Cascade returns true if f has the @cascade directive, which drops the
+// objects that are missing any of the fields f asks for, and the fields the
+// directive names, if it's limited to those.
+func (f *field) Cascade() (bool, []string) {
+	dir := f.field.Directives.ForName(cascadeDirective)
+	if dir == nil {
+		return false, nil
+	}
+	names, _ := dir.ArgumentMap(f.op.vars)[cascadeFieldsArg].([]interface{})
+	fields := make([]string, 0, len(names))
+	for _, name := range names {
+		if s, ok := name.(string); ok {
+			fields = append(fields, s)
+		}
+	}
+	return true, fields
+}
+
+func (q *query) Name() string {
+	return (*field)(q).Name()
+}
+
+func (q *query) Alias() string {
+	return (*field)(q).Alias()
+}
+
+func (q *query) ResponseName() string {
+	return (*field)(q).ResponseName()
+}
+
+func (q *query) ArgValue(name string) interface{} {
+	return (*field)(q).ArgValue(name)
+}
+
+func (q *query) Arguments() map[string]interface{} {
+	return (*field)(q).Arguments()
+}
+
+func (q *query) IDArgValue() (uint64, error) {
+	return (*field)(q).IDArgValue()
+}
+
+func (q *query) Type() Type {
+	return (*field)(q).Type()
+}
+
+func (q *query) SelectionSet() []Field {
+	return (*field)(q).SelectionSet()
+}
+
+func (q *query) SelectionSetFor(typName string) []Field {
+	return (*field)(q).SelectionSetFor(typName)
+}
+
+func (q *query) Location() *gqlerror.Location {
+	return (*field)(q).Location()
+}
+
+func (q *query) DgraphPredicate() string {
+	return (*field)(q).DgraphPredicate()
+}
+
+func (q *query) GetObjectName() string {
+	return (*field)(q).GetObjectName()
+}
+
+func (q *query) CustomHTTP() *CustomHTTP {
+	return (*field)(q).CustomHTTP()
+}
+
+func (q *query) Lambda() bool {
+	return (*field)(q).Lambda()
+}
+
+func (q *query) Computed() *ComputedExpr {
+	return (*field)(q).Computed()
+}
+
+func (q *query) ErrorPolicy() ErrorPolicy {
+	return (*field)(q).ErrorPolicy()
+}
+
+func (q *query) Auth() *FieldAuth {
+	return (*field)(q).Auth()
+}
+
+func (q *query) CacheHint() CacheHint {
+	return (*field)(q).CacheHint()
+}
+
+func (q *query) Cascade() (bool, []string) {
+	return (*field)(q).Cascade()
+}
+
+// Remote returns the remote API that q is forwarded to, or nil if q isn't a
+// RemoteQuery.
+func (q *query) Remote() *RemoteAPI {
+	return q.op.inSchema.remotes[q.field.Name]
+}
+
+func (q *query) QueryType() QueryType {
+	return q.op.inSchema.queryType(q.field.Name)
+}
+
+func (m *mutation) Name() string {
+	return (*field)(m).Name()
+}
+
+func (m *mutation) Alias() string {
+	return (*field)(m).Alias()
+}
+
+func (m *mutation) ResponseName() string {
+	return (*field)(m).ResponseName()
+}
+
+func (m *mutation) ArgValue(name string) interface{} {
+	return (*field)(m).ArgValue(name)
+}
+
+func (m *mutation) Arguments() map[string]interface{} {
+	return (*field)(m).Arguments()
+}
+
+func (m *mutation) IDArgValue() (uint64, error) {
+	return (*field)(m).IDArgValue()
+}
+
+func (m *mutation) Type() Type {
+	return (*field)(m).Type()
+}
+
+func (m *mutation) SelectionSet() []Field {
+	return (*field)(m).SelectionSet()
+}
+
+func (m *mutation) SelectionSetFor(typName string) []Field {
+	return (*field)(m).SelectionSetFor(typName)
+}
+
+func (m *mutation) Location() *gqlerror.Location {
+	return (*field)(m).Location()
+}
+
+func (m *mutation) DgraphPredicate() string {
+	return (*field)(m).DgraphPredicate()
+}
+
+func (m *mutation) GetObjectName() string {
+	return (*field)(m).GetObjectName()
+}
+
+func (m *mutation) CustomHTTP() *CustomHTTP {
+	return (*field)(m).CustomHTTP()
+}
+
+func (m *mutation) Lambda() bool {
+	return (*field)(m).Lambda()
+}
+
+func (m *mutation) Computed() *ComputedExpr {
+	return (*field)(m).Computed()
+}
+
+func (m *mutation) ErrorPolicy() ErrorPolicy {
+	return (*field)(m).ErrorPolicy()
+}
+
+func (m *mutation) Auth() *FieldAuth {
+	return (*field)(m).Auth()
+}
+
+func (m *mutation) CacheHint() CacheHint {
+	return (*field)(m).CacheHint()
+}
+
+func (m *mutation) Cascade() (bool, []string) {
+	return (*field)(m).Cascade()
+}
+
+// Remote returns the remote API that m is forwarded to, or nil if m isn't a
+// RemoteMutation.
+func (m *mutation) Remote() *RemoteAPI {
+	return m.op.inSchema.remotes[m.field.Name]
+}
+
+func (m *mutation) MutationType() MutationType {
+	return m.op.inSchema.mutationType(m.field.Name)
+}
+
+// MutatedType returns the type that's added, updated or deleted by m.
+func (m *mutation) MutatedType() Type {
+	g := m.op.inSchema.mutations[m.field.Name]
+	return &astType{
+		typ:      &ast.Type{NamedType: g.typ},
+		inSchema: m.op.inSchema,
+	}
+}
+
+// QueryField returns the field in m's payload that asks for the mutated
+// objects, or nil if the mutation didn't ask for them.
+func (m *mutation) QueryField() Field {
+	name := lowerFirst(m.MutatedType().Name())
+	for _, f := range m.SelectionSet() {
+		if f.Name() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (t *astType) Field(name string) FieldDefinition {
+	if fd := t.inSchema.fieldDefinition(t.Name(), name); fd != nil {
+		return fd
+	}
+	return nil
+}
+
+func (t *astType) Fields() []FieldDefinition {
+	if info := t.inSchema.types[t.Name()]; info != nil {
+		return info.ordered
+	}
+	return nil
+}
+
+func (t *astType) IDField() FieldDefinition {
+	if info := t.inSchema.types[t.Name()]; info != nil && info.idField != nil {
+		return info.idField
+	}
+	return nil
+}
+
+func (t *astType) Name() string {
+	if t.typ.NamedType == "" {
+		return t.typ.Elem.NamedType
+	}
+	return t.typ.NamedType
+}
+
+func (t *astType) DgraphName() string {
+	return t.Name()
+}
+
+func (t *astType) Nullable() bool {
+	return !t.typ.NonNull
+}
+
+func (t *astType) ListType() Type {
+	if t.typ.Elem == nil {
+		return nil
+	}
+	return &astType{typ: t.typ.Elem, inSchema: t.inSchema}
+}
+
+func (t *astType) Interfaces() []string {
+	if defn := t.inSchema.schema.Types[t.Name()]; defn != nil {
+		return defn.Interfaces
+	}
+	return nil
+}
+
+// PossibleTypes returns the names of the object types that a value of type t
+// can be: t itself if it's an object type, or the types that implement it if
+// it's an interface.  It's nil for scalars and enums.
+func (t *astType) PossibleTypes() []string {
+	defn := t.inSchema.schema.Types[t.Name()]
+	if defn == nil {
+		return nil
+	}
+	var names []string
+	for _, possible := range t.inSchema.schema.GetPossibleTypes(defn) {
+		names = append(names, possible.Name)
+	}
+	return names
+}
+
+// Implementations returns the object types that implement t if it's an
+// interface, and nil otherwise.
+func (t *astType) Implementations() []Type {
+	defn := t.inSchema.schema.Types[t.Name()]
+	if defn == nil || defn.Kind != ast.Interface {
+		return nil
+	}
+	var types []Type
+	for _, possible := range t.inSchema.schema.GetPossibleTypes(defn) {
+		types = append(types, &astType{
+			typ:      &ast.Type{NamedType: possible.Name, NonNull: true},
+			inSchema: t.inSchema,
+		})
+	}
+	return types
+}
+
+// AuthRule returns the @auth rule that controls op on t, or nil if there's
+// no rule.
+func (t *astType) AuthRule(op AuthOperation) *AuthRule {
+	if info := t.inSchema.types[t.Name()]; info != nil {
+		return info.auth[op]
+	}
+	return nil
+}
+
+func (t *astType) String() string {
+	if t == nil {
+		return ""
+	}
+	return t.typ.String()
+}
+
+// Auth returns the @auth directive of fd, or nil if anyone can see fd.
+func (fd *fieldDefinition) Auth() *FieldAuth {
+	return fd.auth
+}
+
+func (fd *fieldDefinition) Name() string {
+	return fd.fieldDef.Name
+}
+
+func (fd *fieldDefinition) Type() Type {
+	return &astType{
+		typ:      fd.fieldDef.Type,
+		inSchema: fd.inSchema,
+	}
+}
+
+func (fd *fieldDefinition) IsID() bool {
+	return fd.fieldDef.Type.Name() == IDType
+}
+
+// Private returns true if fd is @private, and so isn't in the GraphQL API.
+func (fd *fieldDefinition) Private() bool {
+	private, _ := isPrivate(fd.fieldDef)
+	return private
+}
+
+func (fd *fieldDefinition) DgraphPredicate() string {
+	return fd.predicate
+}
+
+// Inverse returns the field that's the @hasInverse of fd, or nil if fd has no
+// inverse.
+func (fd *fieldDefinition) Inverse() FieldDefinition {
+	if fd.inverse == nil {
+		return nil
+	}
+	return fd.inverse
+}
+
+// findInverse finds the field that's the @hasInverse of fd.  The inverse
+// might be declared on fd, or only on the other end of the edge.
+func (s *schema) findInverse(fd *fieldDefinition) *fieldDefinition {
+	if invDirective := fd.fieldDef.Directives.ForName(inverseDirective); invDirective != nil {
+		invFieldArg := invDirective.Arguments.ForName(inverseArg)
+		if invFieldArg == nil {
+			return nil
+		}
+		return s.fieldDefinition(fd.fieldDef.Type.Name(), invFieldArg.Value.Raw)
+	}
+
+	info := s.types[fd.fieldDef.Type.Name()]
+	if info == nil {
+		return nil
+	}
+	for _, f := range info.ordered {
+		other := f.(*fieldDefinition)
+		dir := other.fieldDef.Directives.ForName(inverseDirective)
+		if dir == nil || other.fieldDef.Type.Name() != fd.parentType {
+			continue
+		}
+		if arg := dir.Arguments.ForName(inverseArg); arg != nil && arg.Value.Raw == fd.Name() {
+			return other
+		}
+	}
+	return nil
+}
+
+// markEnums converts the enum values in val, a value of type typ, to
+// EnumValues.
+func (s *schema) markEnums(typ *ast.Type, val interface{}) interface{} {
+	if val == nil {
+		return nil
+	}
+
+	if typ.Elem != nil {
+		if items, ok := val.([]interface{}); ok {
+			res := make([]interface{}, len(items))
+			for i, item := range items {
+				res[i] = s.markEnums(typ.Elem, item)
+			}
+			return res
+		}
+		// A single value given for a list is coerced to a list of one.
+		return s.markEnums(typ.Elem, val)
+	}
+
+	defn := s.schema.Types[typ.NamedType]
+	if defn == nil {
+		return val
+	}
+	switch defn.Kind {
+	case ast.Enum:
+		if str, ok := val.(string); ok {
+			return EnumValue(str)
+		}
+	case ast.InputObject:
+		if obj, ok := val.(map[string]interface{}); ok {
+			res := make(map[string]interface{}, len(obj))
+			for k, v := range obj {
+				if fd := defn.Fields.ForName(k); fd != nil {
+					v = s.markEnums(fd.Type, v)
+				}
+				res[k] = v
+			}
+			return res
+		}
+	}
+	return val
+}
+
+// collectFields flattens sel, for an object of type typ, into the fields
+// to resolve, as the GraphQL spec's CollectFields does: fragments that apply
+// to typ are expanded, fields and fragments excluded by @skip or @include
+// are left out, and fields with the same response name are merged into one.
+func collectFields(sch *ast.Schema, sel ast.SelectionSet, typ string,
+	vars map[string]interface{}) []*ast.Field {
+
+	var fields []*ast.Field
+	index := make(map[string]int)
+
+	var collect func(sel ast.SelectionSet)
+	collect = func(sel ast.SelectionSet) {
+		for _, s := range sel {
+			switch s := s.(type) {
+			case *ast.Field:
+				if !included(s.Directives, vars) {
+					continue
+				}
+				name := responseName(s)
+				i, ok := index[name]
+				if !ok {
+					index[name] = len(fields)
+					fields = append(fields, s)
+					continue
+				}
+				merged := *fields[i]
+				merged.SelectionSet = append(
+					append(ast.SelectionSet{}, merged.SelectionSet...), s.SelectionSet...)
+				fields[i] = &merged
+			case *ast.InlineFragment:
+				if included(s.Directives, vars) && appliesTo(sch, s.TypeCondition, typ) {
+					collect(s.SelectionSet)
+				}
+			case *ast.FragmentSpread:
+				if s.Definition != nil && included(s.Directives, vars) &&
+					appliesTo(sch, s.Definition.TypeCondition, typ) {
+					collect(s.Definition.SelectionSet)
+				}
+			}
+		}
+	}
+
+	collect(sel)
+	return fields
+}
+
+// included is false if directives, those of a field or fragment, have
+// @skip(if: true) or @include(if: false).
+func included(directives ast.DirectiveList, vars map[string]interface{}) bool {
+	if dir := directives.ForName("skip"); dir != nil {
+		if skip, _ := dir.ArgumentMap(vars)["if"].(bool); skip {
+			return false
+		}
+	}
+	if dir := directives.ForName("include"); dir != nil {
+		if include, _ := dir.ArgumentMap(vars)["if"].(bool); !include {
+			return false
+		}
+	}
+	return true
+}
+
+// appliesTo is true if a fragment with type condition cond applies to
+// objects of type typ: cond is missing, is typ, or is an interface or union
+// that typ belongs to.
+func appliesTo(sch *ast.Schema, cond, typ string) bool {
+	if cond == "" || cond == typ {
+		return true
+	}
+	if defn := sch.Types[cond]; defn != nil && defn.IsAbstractType() {
+		for _, possible := range sch.GetPossibleTypes(defn) {
+			if possible.Name == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func responseName(f *ast.Field) string {
+	if f.Alias == "" {
+		return f.Name
+	}
+	return f.Alias
+}
+
+// asUID converts an ID value, as it comes from a GraphQL argument or variable,
+// into a Dgraph uid.
+func asUID(val interface{}) (uint64, error) {
+	if val == nil {
+		return 0, errors.New("ID argument was null")
+	}
+
+	id, ok := val.(string)
+	if !ok {
+		id = fmt.Sprintf("%v", val)
+	}
+	uid, err := strconv.ParseUint(id, 0, 64)
+	if err != nil || uid == 0 {
+		return 0, errors.Errorf("ID argument (%s) was not able to be parsed", id)
+	}
+
+	return uid, nil
+}
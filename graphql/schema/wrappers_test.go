@@ -0,0 +1,231 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/ast"
+)
+
+const metadataSchema = `
+interface Character {
+	id: ID!
+	name: String!
+}
+
+type Human implements Character {
+	id: ID!
+	name: String!
+	starships: [Starship] @hasInverse(field: pilot)
+}
+
+type Starship {
+	shipID: ID!
+	pilot: Human
+	crew: [Human]
+}`
+
+func TestTypeMetadata(t *testing.T) {
+	handler, err := NewHandler(metadataSchema)
+	require.NoError(t, err)
+
+	op, err := handler.Schema().Operation(&Request{
+		Query: `query { getHuman(id: "0x1") { name, starships { shipID } } }`,
+	})
+	require.NoError(t, err)
+	q := op.Queries()[0]
+
+	human := q.Type()
+	require.Equal(t, "Character.name", human.Field("name").DgraphPredicate())
+	require.Equal(t, "Character.name", q.SelectionSet()[0].DgraphPredicate())
+	require.Equal(t, "Human.starships", human.Field("starships").DgraphPredicate())
+	require.Equal(t, "id", human.IDField().Name())
+	require.Nil(t, human.Field("notAField"))
+
+	var names []string
+	for _, fd := range human.Fields() {
+		names = append(names, fd.Name())
+	}
+	require.Equal(t, []string{"id", "name", "starships"}, names)
+
+	// The inverse is found from either end of the edge.
+	starships := human.Field("starships")
+	require.Equal(t, "pilot", starships.Inverse().Name())
+	pilot := starships.Type().Field("pilot")
+	require.Equal(t, "starships", pilot.Inverse().Name())
+	require.Nil(t, starships.Type().Field("crew").Inverse())
+
+	// Generated types have fields, but aren't stored in Dgraph.
+	payload := &astType{typ: &ast.Type{NamedType: "AddHumanPayload"},
+		inSchema: handler.Schema().(*schema)}
+	require.Equal(t, "", payload.Field("human").DgraphPredicate())
+}
+
+func TestCascadeValidation(t *testing.T) {
+	handler, err := NewHandler(metadataSchema)
+	require.NoError(t, err)
+
+	tests := []struct {
+		query  string
+		errMsg string
+	}{
+		{
+			query:  `query { queryHuman { name @cascade } }`,
+			errMsg: "@cascade on name: only fields that are objects can have @cascade.",
+		},
+		{
+			query:  `query { queryHuman @cascade(fields: ["name", "age"]) { name } }`,
+			errMsg: "@cascade on queryHuman: type Human has no field called age.",
+		},
+		{
+			query: `query { getHuman(id: "0x1") { ... on Human {
+				starships @cascade(fields: ["crew", "captain"]) { shipID } } } }`,
+			errMsg: "@cascade on starships: type Starship has no field called captain.",
+		},
+	}
+	for _, test := range tests {
+		_, err := handler.Schema().Operation(&Request{Query: test.query})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), test.errMsg)
+	}
+
+	op, err := handler.Schema().Operation(&Request{
+		Query: `query { queryHuman @cascade(fields: ["id", "starships"]) { name } }`,
+	})
+	require.NoError(t, err)
+	cascade, fields := op.Queries()[0].Cascade()
+	require.True(t, cascade)
+	require.Equal(t, []string{"id", "starships"}, fields)
+}
+
+func TestSelectionSetDirectives(t *testing.T) {
+	handler, err := NewHandler(metadataSchema)
+	require.NoError(t, err)
+
+	op, err := handler.Schema().Operation(&Request{
+		Query: `query ($skip: Boolean!) {
+			getHuman(id: "0x1") {
+				id @skip(if: $skip)
+				... on Character { name }
+				...ships @include(if: false)
+				... @skip(if: $skip) { starships { shipID } }
+				starships { pilot { name } }
+			}
+			queryStarship @skip(if: $skip) { shipID }
+		}
+		fragment ships on Human { starships { crew { name } } }`,
+		Variables: map[string]interface{}{"skip": true},
+	})
+	require.NoError(t, err)
+	require.Len(t, op.Queries(), 1)
+
+	var names []string
+	for _, f := range op.Queries()[0].SelectionSet() {
+		names = append(names, f.Name())
+	}
+	require.Equal(t, []string{"name", "starships"}, names)
+
+	op, err = handler.Schema().Operation(&Request{
+		Query: `query ($skip: Boolean!) {
+			getHuman(id: "0x1") {
+				starships { shipID }
+				... @skip(if: $skip) { starships { pilot { name } } }
+			}
+		}`,
+		Variables: map[string]interface{}{"skip": false},
+	})
+	require.NoError(t, err)
+
+	// Fields with the same response name are merged.
+	sel := op.Queries()[0].SelectionSet()
+	require.Len(t, sel, 1)
+	names = nil
+	for _, f := range sel[0].SelectionSet() {
+		names = append(names, f.Name())
+	}
+	require.Equal(t, []string{"shipID", "pilot"}, names)
+}
+
+func TestAtomicValidation(t *testing.T) {
+	handler, err := NewHandler(metadataSchema + `
+type Mutation {
+	launch(shipID: ID!): Starship @lambda
+}`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		query  string
+		errMsg string
+	}{
+		{
+			query:  `query @atomic { queryHuman { name } }`,
+			errMsg: `Directive "atomic" may not be used on QUERY.`,
+		},
+		{
+			query: `mutation @atomic {
+				deleteHuman(filter: {id: ["0x1"]}) { msg }
+				launch(shipID: "0x2") { shipID }
+			}`,
+			errMsg: "@atomic: mutation launch isn't resolved by Dgraph, so it can't be part " +
+				"of an atomic operation.",
+		},
+	}
+	for _, test := range tests {
+		_, err := handler.Schema().Operation(&Request{Query: test.query})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), test.errMsg)
+	}
+
+	op, err := handler.Schema().Operation(&Request{Query: `mutation @atomic {
+		deleteHuman(filter: {id: ["0x1"]}) { msg }
+		deleteStarship(filter: {shipID: ["0x2"]}) { msg }
+	}`})
+	require.NoError(t, err)
+	require.True(t, op.Atomic())
+
+	op, err = handler.Schema().Operation(&Request{
+		Query: `mutation { launch(shipID: "0x2") { shipID } }`,
+	})
+	require.NoError(t, err)
+	require.False(t, op.Atomic())
+}
+
+func TestMutationEvents(t *testing.T) {
+	handler, err := NewHandler(`
+		type Post @lambdaOnMutate(add: true, update: true, url: "https://hooks/posts") {
+			id: ID!
+		}
+		type Comment @lambdaOnMutate(delete: true) { id: ID! }
+		type Author { id: ID! }`)
+	require.NoError(t, err)
+	sch := handler.Schema().(*schema)
+
+	post := (&astType{typ: &ast.Type{NamedType: "Post"}, inSchema: sch}).MutationEvents()
+	require.Equal(t, &MutationEvents{Add: true, Update: true, URL: "https://hooks/posts"}, post)
+	require.True(t, post.Sends(UpdateMutation))
+	require.False(t, post.Sends(DeleteMutation))
+
+	comment := (&astType{typ: &ast.Type{NamedType: "Comment"}, inSchema: sch}).MutationEvents()
+	require.True(t, comment.Sends(DeleteMutation))
+	require.False(t, comment.Sends(AddMutation))
+
+	author := (&astType{typ: &ast.Type{NamedType: "Author"}, inSchema: sch}).MutationEvents()
+	require.Nil(t, author)
+	require.False(t, author.Sends(AddMutation))
+}
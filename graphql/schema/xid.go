@@ -0,0 +1,123 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/gqlerror"
+)
+
+// An @xid field is an object's external ID: a stable key, from outside
+// Dgraph, that clients can use instead of the ID Dgraph assigns.
+//
+//	type Customer {
+//		id: ID!
+//		customerID: String! @xid
+//		name: String
+//	}
+//
+// It's stored in the xid predicate, which is where the live and bulk loaders
+// keep the IDs that nodes had in the loaded data (with --store_xids), so
+// loaded data can be found by the same keys from GraphQL.  getCustomer takes
+// either the id or the customerID.  Adding a Customer with a customerID
+// that's already in Dgraph updates that node, rather than adding another,
+// and so does a reference to a Customer by its customerID, which links to
+// the existing node if there is one.
+
+// XidPredicate is the Dgraph predicate that @xid fields are stored in.
+const XidPredicate = "xid"
+
+// xidIndex is the index of XidPredicate; it's the one the bulk loader gives
+// it.
+const xidIndex = "hash"
+
+// isXid returns true if fld is an @xid field.
+func isXid(fld *ast.FieldDefinition) bool {
+	return fld != nil && fld.Directives.ForName(xidDirective) != nil
+}
+
+// xidField returns the @xid field of defn, or nil if it doesn't have one.
+func xidField(defn *ast.Definition) *ast.FieldDefinition {
+	for _, fld := range defn.Fields {
+		if isXid(fld) {
+			return fld
+		}
+	}
+	return nil
+}
+
+func xidRule(doc *ast.SchemaDocument, defn *ast.Definition,
+	field *ast.FieldDefinition) *gqlerror.Error {
+
+	dir := field.Directives.ForName(xidDirective)
+	if dir == nil {
+		return nil
+	}
+
+	switch {
+	case defn.Kind != ast.Object || isRemote(defn) || isEdge(defn) ||
+		reservedTypeNames[defn.Name] || isCustom(field) || isLambda(field):
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @xid is only allowed on fields of object types that are "+
+				"stored in Dgraph.", defn.Name, field.Name)
+	case field.Type.NamedType != "String" || !field.Type.NonNull:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @xid is only allowed on String! fields.",
+			defn.Name, field.Name)
+	case idField(defn) == nil:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: @xid maps external IDs to Dgraph's, so type %s needs an "+
+				"ID field too.", defn.Name, field.Name, defn.Name)
+	case field.Directives.ForName(dgraphDirective) != nil ||
+		field.Directives.ForName(searchDirective) != nil:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: an @xid field is stored and indexed in predicate %s, so it "+
+				"can't have @dgraph or @search.", defn.Name, field.Name, XidPredicate)
+	case xidField(defn) != field:
+		return gqlerror.ErrorPosf(dir.Position,
+			"Type %s; Field %s: type %s already has @xid field %s, and can only have one.",
+			defn.Name, field.Name, defn.Name, xidField(defn).Name)
+	}
+
+	for _, iface := range defn.Interfaces {
+		if idefn := doc.Definitions.ForName(iface); idefn != nil &&
+			idefn.Fields.ForName(field.Name) != nil {
+			return gqlerror.ErrorPosf(dir.Position,
+				"Type %s; Field %s: is stored in the predicate of interface %s's field, so it "+
+					"can't have @xid.", defn.Name, field.Name, iface)
+		}
+	}
+	return nil
+}
+
+// xidArg is the argument of getT, for a type T with an @xid field, that finds
+// the object by its external ID.
+func xidArg(defn *ast.Definition) ast.ArgumentDefinitionList {
+	xid := xidField(defn)
+	if xid == nil {
+		return nil
+	}
+	return ast.ArgumentDefinitionList{{Name: xid.Name, Type: ast.NamedType("String", nil)}}
+}
+
+// XidField returns the @xid field of t, or nil if it doesn't have one.
+func (t *astType) XidField() FieldDefinition {
+	if info := t.inSchema.types[t.Name()]; info != nil && info.xid != nil {
+		return info.xid
+	}
+	return nil
+}
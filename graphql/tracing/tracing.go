@@ -0,0 +1,162 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tracing times the phases of resolving a GraphQL request.  Each
+// phase is an OpenCensus span, so it shows up in the same trace as the Dgraph
+// queries it runs.  A request can also ask for its timings in the response,
+// in the tracing extension, in Apollo's tracing format:
+// https://github.com/apollographql/apollo-tracing.
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	otrace "go.opencensus.io/trace"
+)
+
+// The phases of resolving a request.
+const (
+	Parse    = "graphql.parse"
+	Validate = "graphql.validate"
+	Rewrite  = "graphql.rewrite"
+	Complete = "graphql.complete"
+)
+
+// An ApolloTrace is the timings of a request, in Apollo's tracing format.
+// Offsets and durations are in nanoseconds.
+type ApolloTrace struct {
+	Version    int             `json:"version"`
+	StartTime  time.Time       `json:"startTime"`
+	EndTime    time.Time       `json:"endTime"`
+	Duration   int64           `json:"duration"`
+	Parsing    *OffsetDuration `json:"parsing,omitempty"`
+	Validation *OffsetDuration `json:"validation,omitempty"`
+	Execution  ExecutionTrace  `json:"execution"`
+
+	mu sync.Mutex
+}
+
+// An OffsetDuration is when something started, from the start of the request,
+// and how long it took.
+type OffsetDuration struct {
+	StartOffset int64 `json:"startOffset"`
+	Duration    int64 `json:"duration"`
+}
+
+// An ExecutionTrace is the timings of the resolvers that a request ran.
+type ExecutionTrace struct {
+	Resolvers []*ResolverTrace `json:"resolvers"`
+}
+
+// A ResolverTrace is the timing of resolving the field at Path.  The fields
+// that are answered by the same Dgraph query as their parent aren't resolved
+// on their own, so they don't have one.
+type ResolverTrace struct {
+	Path       []interface{} `json:"path"`
+	ParentType string        `json:"parentType"`
+	FieldName  string        `json:"fieldName"`
+	ReturnType string        `json:"returnType"`
+	OffsetDuration
+}
+
+type apolloTraceKey struct{}
+
+// NewApolloTrace starts the Apollo trace of a request.
+func NewApolloTrace() *ApolloTrace {
+	return &ApolloTrace{
+		Version:   1,
+		StartTime: time.Now(),
+		Execution: ExecutionTrace{Resolvers: []*ResolverTrace{}},
+	}
+}
+
+// WithApolloTrace returns a copy of ctx in which the phases and resolvers are
+// recorded in t.
+func WithApolloTrace(ctx context.Context, t *ApolloTrace) context.Context {
+	return context.WithValue(ctx, apolloTraceKey{}, t)
+}
+
+// Finish records that the request t is tracing is done.
+func (t *ApolloTrace) Finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.EndTime = time.Now()
+	t.Duration = t.EndTime.Sub(t.StartTime).Nanoseconds()
+}
+
+func (t *ApolloTrace) since(start time.Time) OffsetDuration {
+	return OffsetDuration{
+		StartOffset: start.Sub(t.StartTime).Nanoseconds(),
+		Duration:    time.Since(start).Nanoseconds(),
+	}
+}
+
+// StartPhase starts phase, as a span that's a child of ctx's.  It returns the
+// context of the span and a func that ends it.  Parsing and validation are
+// recorded in ctx's Apollo trace, if it has one.
+func StartPhase(ctx context.Context, phase string) (context.Context, func()) {
+	ctx, span := otrace.StartSpan(ctx, phase)
+	t, _ := ctx.Value(apolloTraceKey{}).(*ApolloTrace)
+	start := time.Now()
+
+	return ctx, func() {
+		span.End()
+		if t == nil || (phase != Parse && phase != Validate) {
+			return
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		od := t.since(start)
+		if phase == Parse {
+			t.Parsing = &od
+		} else {
+			t.Validation = &od
+		}
+	}
+}
+
+// StartResolver starts resolving fieldName of parentType, which is at path in
+// the response and returns returnType, as a span that's a child of ctx's.  It
+// returns the context of the span and a func that ends it.  The resolver is
+// recorded in ctx's Apollo trace, if it has one.
+func StartResolver(ctx context.Context, path []interface{}, parentType, fieldName,
+	returnType string) (context.Context, func()) {
+
+	ctx, span := otrace.StartSpan(ctx, "graphql.resolve")
+	span.AddAttributes(otrace.StringAttribute("field", parentType+"."+fieldName))
+	t, _ := ctx.Value(apolloTraceKey{}).(*ApolloTrace)
+	start := time.Now()
+
+	return ctx, func() {
+		span.End()
+		if t == nil {
+			return
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.Execution.Resolvers = append(t.Execution.Resolvers, &ResolverTrace{
+			Path:           path,
+			ParentType:     parentType,
+			FieldName:      fieldName,
+			ReturnType:     returnType,
+			OffsetDuration: t.since(start),
+		})
+	}
+}
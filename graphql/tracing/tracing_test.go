@@ -0,0 +1,61 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartPhase(t *testing.T) {
+	trace := NewApolloTrace()
+	ctx := WithApolloTrace(context.Background(), trace)
+
+	for _, phase := range []string{Parse, Validate, Rewrite, Complete} {
+		_, end := StartPhase(ctx, phase)
+		end()
+	}
+	trace.Finish()
+
+	require.NotNil(t, trace.Parsing)
+	require.NotNil(t, trace.Validation)
+	require.True(t, trace.Validation.StartOffset >= trace.Parsing.StartOffset)
+	require.Empty(t, trace.Execution.Resolvers, "other phases aren't resolvers")
+	require.True(t, trace.Duration >= trace.Validation.StartOffset+trace.Validation.Duration)
+
+	// Without an Apollo trace, phases are only spans.
+	_, end := StartPhase(context.Background(), Parse)
+	end()
+}
+
+func TestStartResolver(t *testing.T) {
+	trace := NewApolloTrace()
+	ctx := WithApolloTrace(context.Background(), trace)
+
+	_, end := StartResolver(ctx, []interface{}{"getAuthor"}, "Query", "getAuthor", "Author")
+	end()
+
+	require.Equal(t, []*ResolverTrace{{
+		Path:           []interface{}{"getAuthor"},
+		ParentType:     "Query",
+		FieldName:      "getAuthor",
+		ReturnType:     "Author",
+		OffsetDuration: trace.Execution.Resolvers[0].OffsetDuration,
+	}}, trace.Execution.Resolvers)
+}
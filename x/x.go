@@ -482,8 +482,11 @@ func DivideAndRule(num int) (numGo, width int) {
 	return
 }
 
-// SetupConnection starts a secure gRPC connection to the given host.
-func SetupConnection(host string, tlsCfg *tls.Config, useGz bool) (*grpc.ClientConn, error) {
+// SetupConnection starts a secure gRPC connection to the given host.  Any opts
+// are added to the dial options.
+func SetupConnection(host string, tlsCfg *tls.Config, useGz bool,
+	opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+
 	callOpts := append([]grpc.CallOption{},
 		grpc.MaxCallRecvMsgSize(GrpcMaxSize),
 		grpc.MaxCallSendMsgSize(GrpcMaxSize))
@@ -496,6 +499,7 @@ func SetupConnection(host string, tlsCfg *tls.Config, useGz bool) (*grpc.ClientC
 	dialOpts := append([]grpc.DialOption{},
 		grpc.WithDefaultCallOptions(callOpts...),
 		grpc.WithBlock())
+	dialOpts = append(dialOpts, opts...)
 
 	if tlsCfg != nil {
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
@@ -562,7 +566,11 @@ type CredOpt struct {
 // --tls_cacert, --tls_cert, --tls_key etc specify the TLS configuration of the connection
 // --retries specifies how many times we should retry the connection to each endpoint upon failures
 // --user and --password specify the credentials we should use to login with the server
-func GetDgraphClient(conf *viper.Viper, login bool) (*dgo.Dgraph, CloseFunc) {
+//
+// Any opts are added to the options each endpoint is dialed with.
+func GetDgraphClient(conf *viper.Viper, login bool,
+	opts ...grpc.DialOption) (*dgo.Dgraph, CloseFunc) {
+
 	alphas := conf.GetString("alpha")
 	if len(alphas) == 0 {
 		glog.Fatalf("The --alpha option must be set in order to connect to Dgraph")
@@ -587,7 +595,7 @@ func GetDgraphClient(conf *viper.Viper, login bool) (*dgo.Dgraph, CloseFunc) {
 	for _, d := range ds {
 		var conn *grpc.ClientConn
 		for i := 0; i < retries; retries++ {
-			conn, err = SetupConnection(d, tlsCfg, false)
+			conn, err = SetupConnection(d, tlsCfg, false, opts...)
 			if err == nil {
 				break
 			}